@@ -0,0 +1,23 @@
+package api
+
+import (
+	"b0pass/boot"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+)
+
+// ACMEChallenge 域名验证(http-01)，返回证书申请过程中ACME服务要读取的令牌内容
+// GET /.well-known/acme-challenge/:token
+func ACMEChallenge(r *ghttp.Request) {
+	if boot.Certs == nil {
+		r.Response.WriteStatus(http.StatusNotFound)
+		r.ExitAll()
+	}
+	v, ok := boot.Certs.Token(r.GetString("token"))
+	if !ok {
+		r.Response.WriteStatus(http.StatusNotFound)
+		r.ExitAll()
+	}
+	r.Response.Header().Set("Content-Type", "text/plain")
+	r.Response.Write(v)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/actions"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/metadata"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Actions 文件操作注册表，其他模块可注册新的操作或以相同ID替换
+var Actions = &actions.Registry{}
+
+var (
+	imageExts   = []string{".png", ".jpg", ".jpeg", ".gif", ".bmp", ".ico", ".webp", ".svg"}
+	mediaExts   = []string{".mp4", ".webm", ".mov", ".mkv", ".mp3", ".m4a", ".wav", ".flac", ".ogg"}
+	docExts     = []string{".pdf", ".txt", ".md", ".log", ".json", ".csv"}
+	archiveExts = []string{".zip", ".tar", ".gz", ".tgz", ".zst"}
+)
+
+// joinExts 合并扩展名列表
+func joinExts(lists ...[]string) []string {
+	var exts []string
+	for _, l := range lists {
+		exts = append(exts, l...)
+	}
+	return exts
+}
+
+// unsupported 服务端尚未实现的操作，仍出现在菜单中以便客户端显示一致
+func unsupported(actions.Target, actions.Context) string {
+	return "服务端不支持"
+}
+
+// actionURL 操作地址，f为对象的访问路径
+func actionURL(endpoint string) func(t actions.Target) string {
+	return func(t actions.Target) string {
+		return boot.BasePath + endpoint + "?f=" + url.QueryEscape(t.Path)
+	}
+}
+
+func init() {
+	Actions.Register(actions.Def{ID: "preview", Title: "预览", Method: http.MethodGet,
+		Applies: actions.Exts(joinExts(imageExts, mediaExts, docExts)...),
+		URL:     func(t actions.Target) string { return boot.BasePath + t.Path }})
+	Actions.Register(actions.Def{ID: "download", Title: "下载", Method: http.MethodGet,
+		Applies: func(actions.Target) bool { return true },
+		URL: func(t actions.Target) string {
+			if t.Dir {
+				return actionURL("/api/zip")(t)
+			}
+			return boot.BasePath + t.Path
+		}})
+	Actions.Register(actions.Def{ID: "share", Title: "分享", Method: http.MethodGet, URL: actionURL("/api/sources")})
+	Actions.Register(actions.Def{ID: "hash", Title: "校验值", Method: http.MethodGet, Caps: []string{actions.CapHeavy}, URL: actionURL("/api/hash")})
+	Actions.Register(actions.Def{ID: "cast", Title: "投屏", Method: http.MethodPost, Caps: []string{actions.CapAsync},
+		Applies: actions.Exts(joinExts(imageExts, mediaExts)...), Check: unsupported})
+	Actions.Register(actions.Def{ID: "print", Title: "打印", Method: http.MethodPost, Caps: []string{actions.CapHost, actions.CapAsync},
+		Applies: actions.Exts(joinExts(imageExts, docExts)...), Check: unsupported})
+	Actions.Register(actions.Def{ID: "extract", Title: "解压", Method: http.MethodPost, Caps: []string{actions.CapWrite, actions.CapHeavy},
+		Applies: actions.Exts(archiveExts...), Check: unsupported})
+	Actions.Register(actions.Def{ID: "delete", Title: "删除", Method: http.MethodPost, Caps: []string{actions.CapWrite},
+		Applies: func(t actions.Target) bool { return !fileinfos.Roots().IsRoot(fileinfos.FilePath(strings.TrimPrefix(t.Path, "/files"))) },
+		URL:     actionURL("/api/delete")})
+}
+
+// FileActions 文件或目录的可用操作，enabled为false时reason为不可用的原因
+// /api/actions?f=/files/a.jpg，f为空时返回全部操作的定义
+func FileActions(r *ghttp.Request) {
+	f := r.GetString("f")
+	if f == "" {
+		var defs []g.Map
+		for _, d := range Actions.Defs() {
+			defs = append(defs, g.Map{"id": d.ID, "title": d.Title, "method": d.Method, "caps": d.Caps})
+		}
+		response.JSON(r, 0, "ok", defs)
+	}
+	rel := strings.TrimPrefix(path.Clean("/"+f), "/files")
+	file := fileinfos.FilePath(rel)
+	info, err := os.Stat(file)
+	if file == "" || err != nil {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	t := actions.Target{Path: "/files" + path.Clean("/"+rel), Name: info.Name(), Dir: info.IsDir(), Size: info.Size()}
+	c := actions.Context{
+		Host:     fromHost(r),
+		Writable: CanWrite(r),
+	}
+	response.JSON(r, 0, "ok", g.Map{"path": t.Path, "dir": t.Dir, "actions": Actions.For(t, c)})
+}
+
+// Hash 文件的校验值，上传时已记录且文件未变化时直接返回，否则从磁盘计算
+// /api/hash?f=/files/a.iso&algo=sha256&fresh=1
+func Hash(r *ghttp.Request) {
+	file := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+r.GetString("f")), "/files"))
+	info, err := os.Stat(file)
+	if file == "" || err != nil || info.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	algo := strings.ToLower(r.GetString("algo", hashes.Default))
+	if _, err := hashes.New(algo); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	m := metadata.Fields(fileinfos.FileKey(file))
+	sum, recorded := m[algo], true
+	if r.GetBool("fresh") || sum == "" || m["size"] != strconv.FormatInt(info.Size(), 10) {
+		if sum, err = hashes.File(file, algo); err != nil {
+			response.Error(r, http.StatusInternalServerError, 201, err.Error())
+		}
+		recorded = false
+	}
+	response.JSON(r, 0, "ok", g.Map{"algo": algo, "hash": sum, "size": info.Size(), "recorded": recorded})
+}
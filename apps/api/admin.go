@@ -0,0 +1,182 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/logger"
+	"b0pass/library/response"
+	"encoding/json"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+)
+
+// adminSetting 可在运行中修改的设置项(与端口无关，修改后无需重启)
+type adminSetting struct {
+	Key  string   `json:"key"`
+	Type string   `json:"type"`
+	Min  float64  `json:"min,omitempty"`
+	Max  float64  `json:"max,omitempty"`
+	Enum []string `json:"enum,omitempty"`
+	Desc string   `json:"desc"`
+}
+
+// adminSettings 允许修改的设置项
+var adminSettings = []adminSetting{
+	{Key: "auth.readonly", Type: "bool", Desc: "只读模式(禁止上传、删除等写操作)"},
+	{Key: "upload.verify", Type: "bool", Desc: "写入后回读校验哈希"},
+	{Key: "scan.enable", Type: "bool", Desc: "上传完成后病毒扫描"},
+	{Key: "upload.bandwidth", Type: "float", Min: 0, Max: 100000, Desc: "上传总带宽(MB/s)，同时上传的设备平分，0为不限制"},
+	{Key: "download.changed", Type: "string", Enum: []string{"off", "abort", "snapshot"}, Desc: "下载过程中文件被修改时的处理"},
+	{Key: "archive.format", Type: "string", Enum: []string{"zip", "tar", "tar.gz", "tar.zst"}, Desc: "目录打包下载的默认格式"},
+	{Key: "archive.ziplevel", Type: "int", Min: 0, Max: 9, Desc: "zip压缩级别，0仅存储"},
+	{Key: "storage.warn", Type: "float", Min: 0, Max: 100, Desc: "磁盘使用率告警阈值(%)"},
+	{Key: "storage.block", Type: "float", Min: 0, Max: 100, Desc: "磁盘使用率达到后拒绝上传(%)"},
+	{Key: "mirror.busy", Type: "int", Min: 0, Max: 10000, Desc: "同时下载数达到该值时重定向到镜像，0为不重定向"},
+	{Key: "log.level", Type: "string", Enum: []string{"debug", "info", "warn", "error"}, Desc: "运行日志级别"},
+	{Key: "log.access", Type: "bool", Desc: "记录请求日志"},
+	{Key: "audit.maxsize", Type: "int", Min: 1, Max: 1024, Desc: "审计日志单个文件大小(MB)"},
+	{Key: "audit.backups", Type: "int", Min: 0, Max: 100, Desc: "审计日志轮转保留个数"},
+	{Key: "device.accessttl", Type: "int", Min: 1, Max: 24 * 365, Desc: "设备访问令牌有效期(小时)"},
+}
+
+// settingsValues 当前生效的设置值
+func settingsValues() map[string]interface{} {
+	c := g.Config()
+	values := make(map[string]interface{})
+	for _, s := range adminSettings {
+		switch s.Type {
+		case "bool":
+			values[s.Key] = c.GetBool(s.Key)
+		case "int":
+			values[s.Key] = c.GetInt(s.Key)
+		case "float":
+			values[s.Key] = c.GetFloat64(s.Key)
+		default:
+			values[s.Key] = c.GetString(s.Key)
+		}
+	}
+	return values
+}
+
+// checkSetting 校验设置值的类型与范围，返回规范化后的值
+func checkSetting(s adminSetting, v interface{}) (interface{}, error) {
+	switch s.Type {
+	case "bool":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("%s: expect bool", s.Key)
+	case "int", "float":
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s: expect number", s.Key)
+		}
+		if f < s.Min || f > s.Max {
+			return nil, fmt.Errorf("%s: %v out of range [%v, %v]", s.Key, f, s.Min, s.Max)
+		}
+		if s.Type == "int" {
+			if f != float64(int64(f)) {
+				return nil, fmt.Errorf("%s: expect integer", s.Key)
+			}
+			return int64(f), nil
+		}
+		return f, nil
+	default:
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: expect string", s.Key)
+		}
+		for _, e := range s.Enum {
+			if str == e {
+				return str, nil
+			}
+		}
+		if len(s.Enum) > 0 {
+			return nil, fmt.Errorf("%s: %q, expect one of %v", s.Key, str, s.Enum)
+		}
+		return str, nil
+	}
+}
+
+// AdminSettings 读取可在运行中修改的设置与设备名(仅主电脑可访问)
+func AdminSettings(r *ghttp.Request) {
+	if !fromHost(r) {
+		response.Error(r, http.StatusForbidden, 403, "请在主电脑上操作")
+	}
+	response.JSON(r, 0, "ok", g.Map{
+		"file":     boot.SettingsFile(),
+		"settings": settingsValues(),
+		"schema":   adminSettings,
+		"devices":  boot.Devices.List(),
+	})
+}
+
+// AdminSettingsSave 修改设置与设备名(仅主电脑可访问)，立即生效并写入配置文件
+// 请求体 {"settings":{"auth.readonly":true},"devices":{"设备ID":"新名称"}}
+func AdminSettingsSave(r *ghttp.Request) {
+	if !fromHost(r) {
+		response.Error(r, http.StatusForbidden, 403, "请在主电脑上操作")
+	}
+	var req struct {
+		Settings map[string]interface{} `json:"settings"`
+		Devices  map[string]string      `json:"devices"`
+	}
+	if !isJSON(r) {
+		response.Error(r, http.StatusUnsupportedMediaType, 201, "Content-Type须为application/json")
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	schema := make(map[string]adminSetting)
+	for _, s := range adminSettings {
+		schema[s.Key] = s
+	}
+	values := make(map[string]interface{})
+	for key, v := range req.Settings {
+		s, ok := schema[key]
+		if !ok {
+			response.Error(r, http.StatusBadRequest, 201, key+": not a runtime setting")
+		}
+		v, err := checkSetting(s, v)
+		if err != nil {
+			response.Error(r, http.StatusBadRequest, 201, err.Error())
+		}
+		values[key] = v
+	}
+	paired := make(map[string]bool)
+	for _, d := range boot.Devices.List() {
+		paired[d.ID] = true
+	}
+	for id, name := range req.Devices {
+		if name == "" {
+			response.Error(r, http.StatusBadRequest, 201, "device name is required")
+		}
+		if !paired[id] {
+			response.Error(r, http.StatusNotFound, 201, "device not found: "+id)
+		}
+	}
+	if len(values) > 0 {
+		if err := boot.SaveSettings(values); err != nil {
+			logger.Error("admin", "save settings", "err", err)
+			response.Error(r, http.StatusInternalServerError, 201, err.Error())
+		}
+	}
+	for id, name := range req.Devices {
+		if err := boot.Devices.Rename(id, name); err != nil {
+			response.Error(r, http.StatusInternalServerError, 201, err.Error())
+		}
+	}
+	// 环境变量或命令行参数的优先级更高，写入的值不会生效
+	current := settingsValues()
+	var overridden []string
+	for key, v := range values {
+		if fmt.Sprint(current[key]) != fmt.Sprint(v) {
+			overridden = append(overridden, key)
+		}
+	}
+	response.JSON(r, 0, "ok", g.Map{
+		"settings":   current,
+		"overridden": overridden,
+	})
+}
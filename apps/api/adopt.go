@@ -0,0 +1,163 @@
+package api
+
+import (
+	"b0pass/library/adopt"
+	"b0pass/library/audit"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/response"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventAdopt 收录主电脑上已有文件的事件
+const EventAdopt = "adopt"
+
+// adoptJob 收录任务，文件较大时哈希耗时较长，在后台执行，客户端按id查询进度
+type adoptJob struct {
+	ID       string         `json:"id"`
+	Mode     string         `json:"mode"`
+	Total    int            `json:"total"`
+	Done     int            `json:"done"`
+	Failed   int            `json:"failed"`
+	Finished bool           `json:"finished"`
+	Started  time.Time      `json:"started"`
+	Results  []adopt.Result `json:"results"`
+}
+
+var (
+	adoptMu   sync.Mutex
+	adoptJobs = make(map[string]*adoptJob)
+)
+
+// adoptItem 待收录的文件
+type adoptItem struct {
+	src, dst string
+}
+
+// AdoptStart 将主电脑上已有的文件或目录收录到共享目录(仅主电脑)
+// POST /api/adopt {"src":["/home/me/video.mkv","/home/me/photos"],"path":"目标子目录","mode":"move|link|copy","overwrite":false}
+// 目录保留其下的结构，收录时计算哈希并记录元数据，与上传的文件一样可秒传、校验与复制到镜像
+func AdoptStart(r *ghttp.Request) {
+	if !fromHost(r) {
+		response.Error(r, http.StatusForbidden, 403, "请在主电脑上操作")
+	}
+	var req struct {
+		Src       []string `json:"src"`
+		Path      string   `json:"path"`
+		Mode      string   `json:"mode"`
+		Overwrite bool     `json:"overwrite"`
+	}
+	if !isJSON(r) {
+		response.Error(r, http.StatusUnsupportedMediaType, 201, "Content-Type须为application/json")
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	if req.Mode == "" {
+		req.Mode = adopt.ModeMove
+	}
+	if req.Mode != adopt.ModeMove && req.Mode != adopt.ModeLink && req.Mode != adopt.ModeCopy {
+		response.Error(r, http.StatusBadRequest, 201, "mode为move、link或copy")
+	}
+	if len(req.Src) == 0 {
+		response.Error(r, http.StatusBadRequest, 201, "src不能为空")
+	}
+	var items []adoptItem
+	for _, src := range req.Src {
+		if !filepath.IsAbs(src) {
+			response.Error(r, http.StatusBadRequest, 201, "src须为绝对路径: "+src)
+		}
+		files, err := adopt.Walk(src)
+		if err != nil {
+			response.Error(r, http.StatusBadRequest, 201, err.Error())
+		}
+		base := filepath.Dir(filepath.Clean(src))
+		for _, rel := range files {
+			items = append(items, adoptItem{
+				src: filepath.Join(base, filepath.FromSlash(rel)),
+				dst: savePathOf(r, req.Path, rel),
+			})
+		}
+	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	job := &adoptJob{ID: hex.EncodeToString(b), Mode: req.Mode, Total: len(items), Started: time.Now(), Results: []adopt.Result{}}
+	adoptMu.Lock()
+	for id, j := range adoptJobs {
+		if j.Finished && time.Since(j.Started) > 24*time.Hour {
+			delete(adoptJobs, id)
+		}
+	}
+	adoptJobs[job.ID] = job
+	adoptMu.Unlock()
+	opts := adopt.Options{Mode: req.Mode, Algos: uploadAlgos(), Overwrite: req.Overwrite}
+	go runAdopt(job, items, opts, ClientKey(r), r.UserAgent())
+	response.JSON(r, 0, "ok", adoptStatus(job))
+}
+
+// AdoptStatus 收录任务的进度与各文件结果 /api/adopt?id=
+func AdoptStatus(r *ghttp.Request) {
+	adoptMu.Lock()
+	job, ok := adoptJobs[r.GetString("id")]
+	adoptMu.Unlock()
+	if !ok {
+		response.Error(r, http.StatusNotFound, 201, "任务不存在")
+	}
+	response.JSON(r, 0, "ok", adoptStatus(job))
+}
+
+// adoptStatus 任务的副本，避免与后台执行并发读写
+func adoptStatus(job *adoptJob) adoptJob {
+	adoptMu.Lock()
+	defer adoptMu.Unlock()
+	j := *job
+	j.Results = append([]adopt.Result{}, job.Results...)
+	return j
+}
+
+// runAdopt 逐个收录，成功的文件与上传完成一样记录元数据、审计日志、Webhook与镜像复制
+func runAdopt(job *adoptJob, items []adoptItem, opts adopt.Options, client, agent string) {
+	for _, it := range items {
+		ret := adopt.File(it.src, it.dst, opts)
+		if ret.Err == "" {
+			adopted(it.dst, ret, client, agent)
+		} else {
+			logger.Error("adopt", "file", "src", it.src, "err", ret.Err)
+		}
+		ret.Dst = fileinfos.FileURL(it.dst)
+		adoptMu.Lock()
+		job.Done++
+		if ret.Err != "" {
+			job.Failed++
+		}
+		job.Results = append(job.Results, ret)
+		adoptMu.Unlock()
+	}
+	adoptMu.Lock()
+	job.Finished = true
+	adoptMu.Unlock()
+	logger.Info("adopt", "finished", "id", job.ID, "mode", job.Mode, "total", job.Total, "failed", job.Failed)
+}
+
+// adopted 记录收录的文件
+func adopted(file string, ret adopt.Result, client, agent string) {
+	fields := map[string]string{"size": strconv.FormatInt(ret.Size, 10), "adopted": ret.Method}
+	for algo, sum := range ret.Sums {
+		fields[algo] = sum
+	}
+	metadata.Set(fileinfos.FileKey(file), fields)
+	recordChunks(file, ret.Size)
+	audit.Record(audit.Entry{Op: audit.OpAdopt, Path: fileinfos.FileURL(file), Size: ret.Size, Client: client, Agent: agent})
+	clientEvent(client, EventAdopt, file, ret.Size, ret.Sums[hashes.Default])
+	replicate(file)
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/archive"
+	"b0pass/library/audit"
+	"b0pass/library/disposition"
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"bufio"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// archiveLevels 各打包格式的默认压缩级别配置项
+var archiveLevels = map[string]string{
+	archive.FormatZip:    "archive.ziplevel",
+	archive.FormatTarGz:  "archive.gziplevel",
+	archive.FormatTarZst: "archive.zstdlevel",
+}
+
+// Zip 将共享目录下的文件夹打包流式下载
+// /api/zip?f=/files/dir&format=tar.gz&level=9，format缺省时使用设备偏好中的下载格式，其次为配置
+// 按[hidden]跳过隐藏文件，all=1打包全部，exclude追加跳过的通配符
+func Zip(r *ghttp.Request) {
+	format := r.GetString("format")
+	if format == "" {
+		format = boot.Prefs.Get(boot.DeviceIdentity(r.Request)).Download
+	}
+	if format == "" {
+		format = g.Config().GetString("archive.format", archive.FormatZip)
+	}
+	level := g.Config().GetInt(archiveLevels[format])
+	if r.GetString("level") != "" {
+		level = r.GetInt("level")
+	}
+	if err := archive.CheckLevel(format, level); err != nil {
+		r.Response.WriteStatus(http.StatusBadRequest, err.Error())
+		return
+	}
+	rules := hiddenRules(r)
+	rel := path.Clean("/" + strings.TrimPrefix(path.Clean("/"+r.GetString("f")), "/files"))
+	dir := fileinfos.FilePath(rel)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		r.Response.WriteStatus(http.StatusNotFound)
+		return
+	}
+	name := path.Base(rel)
+	if rel == "/" {
+		name = "files"
+	}
+	r.Response.Header().Set("Content-Type", archive.ContentType(format))
+	r.Response.Header().Set("Content-Disposition", disposition.Header(disposition.Attachment, name+"."+format, r.UserAgent()))
+	conn, w, err := hijackStream(r)
+	if err != nil {
+		r.Response.WriteStatus(http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	stats, err := archive.Write(r.Context(), w, dir, archive.Options{
+		Pool:   boot.Archives,
+		Batch:  g.Config().GetInt("archive.batch", 256),
+		Format: format,
+		Level:  level,
+		Hidden: hiddenUnder(rules, dir),
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// 已开始传输，无法再返回错误状态，直接关闭连接使客户端得到不完整的压缩包
+		logger.Error("archive", "stream", "path", rel, "err", err)
+		return
+	}
+	auditLog(r, audit.OpDownload, dir, stats.Size)
+	recordTransfer(r, audit.OpDownload, dir, stats.Size, requestStart(r))
+	runScript(ClientKey(r), EventDownload, dir, stats.Size, "")
+}
+
+// hijackStream 接管连接并写出响应头，之后的内容直接写入连接直到关闭
+// 框架会缓冲全部响应内容，打包大小未知且可能很大，只能绕过缓冲；
+// 同时取消服务器的写超时，大目录打包耗时可能远超超时时间；
+// 不能接管连接时(HTTP/2、HTTP/3)直接写出，返回的流在客户端断开时读到EOF
+func hijackStream(r *ghttp.Request) (io.ReadCloser, *bufio.Writer, error) {
+	conn, rw, err := hijack(r)
+	if err == errNoHijack {
+		w := rawWriter(r)
+		w.WriteHeader(http.StatusOK)
+		s := flushWriter{w: w, done: r.Context().Done()}
+		return s, bufio.NewWriter(s), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	h := r.Response.Header()
+	h.Set("Connection", "close")
+	h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	_, _ = rw.WriteString("HTTP/1.1 200 OK\r\n")
+	if err := h.Write(rw); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	_, _ = rw.WriteString("\r\n")
+	return conn, rw.Writer, nil
+}
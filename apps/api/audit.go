@@ -0,0 +1,65 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/fileinfos"
+	"b0pass/library/humanize"
+	"b0pass/library/response"
+	"github.com/gogf/gf/net/ghttp"
+	"time"
+)
+
+// auditView 审计记录及按语言格式化的大小(授权记录为时长)与时间
+type auditView struct {
+	audit.Entry
+	SizeText string `json:"size_text"`
+	TimeText string `json:"time_text"`
+}
+
+// Audit 审计日志查询
+// /api/audit?op=upload&limit=100&locale=en
+func Audit(r *ghttp.Request) {
+	entries := audit.Query(r.GetString("op"), r.GetInt("limit", 100))
+	loc, now := boot.Locale(r.Request), time.Now()
+	views := make([]auditView, 0, len(entries))
+	for _, e := range entries {
+		v := auditView{Entry: e}
+		switch e.Op {
+		case audit.OpGrant:
+			v.SizeText = humanize.Duration(time.Duration(e.Size)*time.Minute, loc)
+		case audit.OpRevoke:
+			// 撤销记录没有大小
+		default:
+			v.SizeText = humanize.Size(e.Size, loc)
+		}
+		if t, err := time.Parse(time.RFC3339, e.Time); err == nil {
+			v.TimeText = humanize.Date(t, now, loc)
+		}
+		views = append(views, v)
+	}
+	response.JSON(r, 0, "ok", views)
+}
+
+// auditLog 记录当前请求的审计日志
+func auditLog(r *ghttp.Request, op, file string, size int64) {
+	audit.Record(audit.Entry{
+		Op:     op,
+		Path:   fileinfos.FileURL(file),
+		Size:   size,
+		Client: r.GetClientIp(),
+		Agent:  r.UserAgent(),
+	})
+}
+
+// auditUpload 记录上传及其采用的同步策略
+func auditUpload(r *ghttp.Request, file string, size int64, policy string) {
+	audit.Record(audit.Entry{
+		Op:         audit.OpUpload,
+		Path:       fileinfos.FileURL(file),
+		Size:       size,
+		Client:     r.GetClientIp(),
+		Agent:      r.UserAgent(),
+		Durability: policy,
+	})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/authn"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"net/url"
+)
+
+// identityParam 认证通过后保存身份的请求参数
+const identityParam = "auth_identity"
+
+// SetIdentity 记录认证通过的身份
+func SetIdentity(r *ghttp.Request, id authn.Identity) {
+	r.SetParam(identityParam, id)
+}
+
+// Identity 请求方的身份，未启用认证时为空
+func Identity(r *ghttp.Request) (authn.Identity, bool) {
+	id, ok := r.GetParam(identityParam).(authn.Identity)
+	return id, ok
+}
+
+// CanWrite 请求方能否执行写操作: admin不受只读模式限制，guest只能浏览与下载，临时授权的设备均可写入
+func CanWrite(r *ghttp.Request) bool {
+	if Elevated(r) {
+		return true
+	}
+	id, _ := Identity(r)
+	return roleCanWrite(id.Role)
+}
+
+// roleCanWrite 该角色能否执行写操作，FTP、gRPC、S3等协议服务与网页一致
+func roleCanWrite(role string) bool {
+	switch role {
+	case authn.RoleAdmin:
+		return true
+	case authn.RoleGuest:
+		return false
+	}
+	return !g.Config().GetBool("auth.readonly")
+}
+
+// protocolAuth 以当前的认证方式校验协议服务(gRPC等)的请求，返回角色；未启用认证时允许匿名
+func protocolAuth(r *http.Request) (string, bool) {
+	chain := boot.Auth()
+	if len(chain) == 0 {
+		return authn.RoleUser, true
+	}
+	id, err := chain.Authenticate(headerWriter{}, r)
+	return id.Role, err == nil
+}
+
+// protocolLogin 以用户名、密码登录FTP等协议服务: 密码先作为Bearer令牌(访问令牌、已配对设备、OIDC)，
+// 再作为Basic认证(basic、ldap)与PIN交给认证方式校验
+func protocolLogin(client, user, password string) (string, bool) {
+	r := &http.Request{Header: http.Header{}, URL: &url.URL{Path: "/"}, RemoteAddr: client}
+	r.Header.Set("Authorization", "Bearer "+password)
+	if role, ok := protocolAuth(r); ok {
+		return role, true
+	}
+	r.SetBasicAuth(user, password)
+	r.Header.Set("X-B0Pass-Pin", password)
+	return protocolAuth(r)
+}
+
+// basicAuth 认证方式中的basic账号，没有时返回nil
+func basicAuth() *authn.Basic {
+	for _, p := range boot.Auth() {
+		if b, ok := p.(*authn.Basic); ok && b.User != "" {
+			return b
+		}
+	}
+	return nil
+}
+
+// headerWriter 协议服务认证时丢弃认证方式写出的响应(如PIN会话Cookie)
+type headerWriter struct{}
+
+func (headerWriter) Header() http.Header { return http.Header{} }
+
+func (headerWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func (headerWriter) WriteHeader(int) {}
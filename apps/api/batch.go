@@ -0,0 +1,104 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/batch"
+	"b0pass/library/fileinfos"
+	"b0pass/library/response"
+	"encoding/json"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// batches 目录树上传会话，空闲24小时后清理
+var batches = batch.NewStore(24 * time.Hour)
+
+// BatchCreate 创建目录树上传会话
+// POST /api/batch {"path":"目标目录","files":[{"path":"sub/a.txt","size":1,"mtime":0}]}
+// 返回每个文件的状态(pending/partial/done)，客户端按id与offset通过/api/upload分片续传
+func BatchCreate(r *ghttp.Request) {
+	var req struct {
+		Path  string        `json:"path"`
+		Files []batch.Entry `json:"files"`
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	if fileinfos.FilePath(req.Path) == "" {
+		response.Error(r, http.StatusBadRequest, 201, errNoRoot())
+	}
+	sess, err := batches.Create(req.Path, req.Files)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	p := sess.Progress(batchDir(sess), uploadTmpDir())
+	if boot.Storage.Blocked() {
+		batches.Remove(sess.ID)
+		response.Error(r, http.StatusInsufficientStorage, 507, "磁盘空间不足")
+	}
+	if msg := quotaError(r, p.Size-p.Completed); msg != "" {
+		batches.Remove(sess.ID)
+		response.Error(r, http.StatusInsufficientStorage, 507, msg)
+	}
+	response.JSON(r, 0, "ok", batchResult(sess, p))
+}
+
+// BatchStatus 查询会话进度 /api/batch?id=
+func BatchStatus(r *ghttp.Request) {
+	sess := batchSession(r)
+	response.JSON(r, 0, "ok", batchResult(sess, sess.Progress(batchDir(sess), uploadTmpDir())))
+}
+
+// BatchDone 结束会话并返回最终进度 /api/batch/done?id=
+func BatchDone(r *ghttp.Request) {
+	sess := batchSession(r)
+	batches.Remove(sess.ID)
+	response.JSON(r, 0, "ok", batchResult(sess, sess.Progress(batchDir(sess), uploadTmpDir())))
+}
+
+// BatchTree 递归列出目录下的文件(按[hidden]跳过隐藏文件)，用于整个目录的下载 /api/batch/tree?path=&all=1
+func BatchTree(r *ghttp.Request) {
+	dir := strings.Trim(path.Clean("/"+r.GetString("path")), "/")
+	root := fileinfos.FilePath(dir)
+	if info, err := os.Stat(root); root == "" || err != nil || !info.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "目录不存在")
+	}
+	files, err := batch.Tree(root, hiddenUnder(hiddenRules(r), root))
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	if files == nil {
+		files = []batch.Entry{}
+	}
+	response.JSON(r, 0, "ok", g.Map{"path": dir, "files": files})
+}
+
+// batchDir 会话目标目录对应的文件路径
+func batchDir(sess *batch.Session) string {
+	return fileinfos.FilePath(sess.Dir)
+}
+
+func batchSession(r *ghttp.Request) *batch.Session {
+	sess, ok := batches.Get(r.GetString("id"))
+	if !ok {
+		response.Error(r, http.StatusNotFound, 201, "会话不存在或已过期")
+	}
+	return sess
+}
+
+func batchResult(sess *batch.Session, p *batch.Progress) g.Map {
+	return g.Map{
+		"id":        sess.ID,
+		"path":      sess.Dir,
+		"files":     p.Files,
+		"done":      p.Done,
+		"size":      p.Size,
+		"completed": p.Completed,
+		"states":    p.States,
+	}
+}
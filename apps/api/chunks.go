@@ -0,0 +1,142 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/gogf/gf/util/gconv"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// chunkSize 分块哈希的分块大小(字节)
+func chunkSize() int64 {
+	if mb := g.Config().GetInt64("mirror.chunksize", 4); mb > 0 {
+		return mb << 20
+	}
+	return hashes.DefaultChunkSize
+}
+
+// recordChunks 大文件上传完成后在后台计算分块哈希并记录到元数据，
+// 镜像复制与对端校验据此只重传损坏的分块
+func recordChunks(file string, size int64) {
+	if chunkable(size) {
+		go cacheChunks(file)
+	}
+}
+
+// chunkable 文件是否达到记录分块哈希的大小
+func chunkable(size int64) bool {
+	min := g.Config().GetInt64("mirror.chunkmin", 64) << 20
+	return min > 0 && size >= min
+}
+
+// chunking 正在计算分块哈希的文件，同一文件同时只计算一次
+var chunking sync.Map
+
+// cacheChunks 计算分块哈希并记录到元数据(含计算时的大小与修改时间)，已在计算中时直接返回
+func cacheChunks(file string) {
+	if _, busy := chunking.LoadOrStore(file, true); busy {
+		return
+	}
+	defer chunking.Delete(file)
+	info, err := os.Stat(file)
+	if err != nil {
+		return
+	}
+	key := fileinfos.FileKey(file)
+	chunk := chunkSize()
+	sums, err := hashes.FileChunks(file, chunk)
+	if err != nil {
+		logger.Error("chunks", "hash", "path", key, "err", err)
+		return
+	}
+	metadata.Set(key, map[string]string{
+		"size":       strconv.FormatInt(info.Size(), 10),
+		"chunksize":  strconv.FormatInt(chunk, 10),
+		"chunkmtime": strconv.FormatInt(info.ModTime().Unix(), 10),
+		"chunks":     hashes.JoinChunks(sums),
+	})
+}
+
+// storedChunks 记录的分块大小与分块哈希，文件在记录后被修改时返回空
+func storedChunks(key string) (int64, []string) {
+	m := metadata.Fields(key)
+	info, err := os.Stat(fileinfos.FilePath(key))
+	if err != nil || m["size"] != strconv.FormatInt(info.Size(), 10) {
+		return 0, nil
+	}
+	if mtime := m["chunkmtime"]; mtime != "" && mtime != strconv.FormatInt(info.ModTime().Unix(), 10) {
+		return 0, nil
+	}
+	size, _ := strconv.ParseInt(m["chunksize"], 10, 64)
+	return size, hashes.SplitChunks(m["chunks"])
+}
+
+// chunkHeaders 大文件下载时通过X-Chunk-Hashes头给出分块哈希列表的地址，供网页端边下载边校验；
+// 尚未记录时在后台预先计算
+func chunkHeaders(r *ghttp.Request, file string, info os.FileInfo) {
+	if !chunkable(info.Size()) {
+		return
+	}
+	key := fileinfos.FileKey(file)
+	if _, sums := storedChunks(key); len(sums) == 0 {
+		go cacheChunks(file)
+	}
+	r.Response.Header().Set("X-Chunk-Hashes", boot.BasePath+"/api/chunks?f="+url.QueryEscape("/files/"+key))
+}
+
+// Chunks 文件的分块哈希，供镜像与对端比较后只重传不一致的分块，网页端下载时据此逐块校验
+// /api/chunks?f=/files/a.iso&chunk=4194304&fresh=1&async=1
+// fresh=1时从磁盘重新计算(可发现本机磁盘上的损坏)，bad为与上传时记录不一致的分块序号；
+// async=1且尚未记录时在后台计算并返回202与pending，稍后再次请求
+func Chunks(r *ghttp.Request) {
+	file := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+r.GetString("f")), "/files"))
+	info, err := os.Stat(file)
+	if file == "" || err != nil || info.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	key := fileinfos.FileKey(file)
+	chunk, recorded := storedChunks(key)
+	if want := gconv.Int64(r.Get("chunk")); want > 0 && want != chunk {
+		chunk, recorded = want, nil
+	}
+	if chunk <= 0 {
+		chunk = chunkSize()
+	}
+	sums := recorded
+	fresh := r.GetBool("fresh")
+	if !fresh && len(sums) == 0 && chunk == chunkSize() {
+		if r.GetBool("async") {
+			go cacheChunks(file)
+			r.Response.WriteHeader(http.StatusAccepted)
+			response.JSON(r, 0, "ok", g.Map{"size": info.Size(), "chunk": chunk, "pending": true})
+		}
+		cacheChunks(file)
+		_, sums = storedChunks(key)
+	}
+	if fresh || len(sums) == 0 {
+		if sums, err = hashes.FileChunks(file, chunk); err != nil {
+			response.Error(r, http.StatusInternalServerError, 201, err.Error())
+		}
+	}
+	ret := g.Map{"size": info.Size(), "chunk": chunk, "hashes": sums}
+	if len(recorded) > 0 {
+		bad := hashes.DiffChunks(recorded, sums)
+		if bad == nil {
+			bad = []int{}
+		}
+		ret["bad"] = bad
+	}
+	response.JSON(r, 0, "ok", ret)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/device"
+	"b0pass/library/notify"
+	"b0pass/library/response"
+	"encoding/json"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TrackClient 记录设备的访问；打开网页界面时为还没有标识的浏览器设置设备Cookie
+func TrackClient(r *ghttp.Request) {
+	id := boot.DeviceIdentity(r.Request)
+	if id == "" && r.Method == http.MethodGet && (boot.TrimBase(r.URL.Path) == "/" || boot.TrimBase(r.URL.Path) == "/index") {
+		id = deviceIdentity(r)
+	}
+	if id != "" {
+		boot.Clients.Seen(id, r.GetClientIp(), r.UserAgent())
+	}
+}
+
+// clientID 设备标识，无法识别设备时为客户端地址
+func clientID(r *ghttp.Request) string {
+	if id := boot.DeviceIdentity(r.Request); id != "" {
+		return id
+	}
+	return ClientKey(r)
+}
+
+// clientView 设备信息，key为发送文件(/api/send)时指定的设备，online为是否在线(已连接/api/events)，
+// browser与system为按User-Agent识别的浏览器与系统
+func clientView(c device.Client) g.Map {
+	browser, system := device.Agent(c.Agent)
+	return g.Map{"key": device.Key(c.ID), "name": c.Name, "label": c.Label(), "online": notify.Online(c.ID),
+		"browser": browser, "system": system, "addr": c.Addr, "last_seen": c.LastSeen}
+}
+
+// DeviceSelf 当前设备的标识与名称
+// /api/device
+func DeviceSelf(r *ghttp.Request) {
+	id := deviceIdentity(r)
+	c := boot.Clients.Seen(id, r.GetClientIp(), r.UserAgent())
+	if strings.HasPrefix(id, "device:") {
+		c.Name = currentDevice(r).Name
+	}
+	m := clientView(c)
+	m["id"] = id
+	response.JSON(r, 0, "ok", m)
+}
+
+// DeviceRename 设置当前设备的名称(如"小明的iPhone")，用于传输记录、收集链接的文件名与在线设备列表；name为空时恢复默认名称
+// PUT /api/device {"name":"小明的iPhone"}
+func DeviceRename(r *ghttp.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, `请求体须为JSON: {"name":"..."}`)
+	}
+	id := deviceIdentity(r)
+	if strings.HasPrefix(id, "device:") {
+		// 配对设备的名称保存在设备注册表中
+		d := currentDevice(r)
+		name, err := device.CleanName(req.Name)
+		if err != nil {
+			response.Error(r, http.StatusBadRequest, 201, err.Error())
+		}
+		if err := boot.Devices.Rename(d.ID, name); err != nil {
+			response.Error(r, http.StatusInternalServerError, 201, err.Error())
+		}
+		c, _ := boot.Clients.Get(id)
+		c.Name = name
+		response.JSON(r, 0, "ok", clientView(c))
+	}
+	c, err := boot.Clients.SetName(id, req.Name)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok", clientView(c))
+}
+
+// ClientList 最近访问过或在线的设备，按最近访问时间从新到旧排序，self为当前设备
+// /api/clients?minutes=10
+func ClientList(r *ghttp.Request) {
+	since := time.Now().Add(-time.Duration(r.GetInt("minutes", 10)) * time.Minute)
+	self := boot.DeviceIdentity(r.Request)
+	list := boot.Clients.List(time.Time{})
+	ret := make([]g.Map, 0, len(list))
+	for _, c := range list {
+		if !c.LastSeen.After(since) && !notify.Online(c.ID) {
+			continue
+		}
+		if strings.HasPrefix(c.ID, "device:") {
+			if d, ok := pairedDevice(strings.TrimPrefix(c.ID, "device:")); ok {
+				c.Name = d.Name
+			}
+		}
+		m := clientView(c)
+		// 不返回其他浏览器的Cookie标识
+		m["self"] = c.ID == self
+		ret = append(ret, m)
+	}
+	response.JSON(r, 0, "ok", ret)
+}
+
+// pairedDevice 已配对的设备
+func pairedDevice(id string) (device.Device, bool) {
+	for _, d := range boot.Devices.List() {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return device.Device{}, false
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// uploadConflict 本次上传的同名文件处理方式: 查询参数、表单字段conflict或X-Conflict请求头，
+// 未指定时为upload.conflict；只读取已解析的表单，不消耗请求体
+func uploadConflict(r *ghttp.Request) (string, error) {
+	v := r.GetQueryString("conflict")
+	if v == "" {
+		v = r.PostForm.Get("conflict")
+	}
+	if v == "" {
+		v = r.Header.Get("X-Conflict")
+	}
+	return fileinfos.ParseConflict(v, g.Config().GetString("upload.conflict", fileinfos.ConflictRename))
+}
+
+// resolveUpload 按同名处理方式决定最终的保存位置，跳过时返回fileinfos.ErrSkipped，报错时返回fileinfos.ErrExist
+func resolveUpload(r *ghttp.Request, savePath string) (string, error) {
+	policy, err := uploadConflict(r)
+	if err != nil {
+		return "", err
+	}
+	return fileinfos.ResolveConflict(savePath, policy)
+}
+
+// checkConflict 同resolveUpload，跳过或报错时由conflictReply响应
+func checkConflict(r *ghttp.Request, savePath, discard string) string {
+	final, err := resolveUpload(r, savePath)
+	if err != nil {
+		conflictReply(r, final, err, discard)
+	}
+	return final
+}
+
+// conflictReply 跳过时返回已有的文件，报错时返回409，并删除不再需要的暂存文件discard
+func conflictReply(r *ghttp.Request, file string, err error, discard string) {
+	if discard != "" {
+		_ = os.Remove(discard)
+	}
+	switch err {
+	case fileinfos.ErrSkipped:
+		response.JSON(r, 0, "skipped", skippedResult(file))
+	case fileinfos.ErrExist:
+		response.Error(r, http.StatusConflict, 409, "同名文件已存在: "+filepath.Base(file))
+	}
+	response.Error(r, http.StatusBadRequest, 201, err.Error())
+}
+
+// skippedResult 按skip保留的已有文件
+func skippedResult(file string) g.Map {
+	ret := g.Map{"name": filepath.Base(file), "url": fileinfos.FileURL(file), "skipped": true}
+	if info, err := os.Stat(file); err == nil {
+		ret["size"] = info.Size()
+	}
+	return ret
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/fileinfos"
+	"b0pass/library/metadata"
+	"b0pass/library/mirror"
+	"b0pass/library/webdav"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+)
+
+// davLocks WebDAV锁表，各请求共用
+var davLocks = webdav.NewLocks()
+
+// DAV 以WebDAV协议共享文件目录，供资源管理器/Finder/手机文件管理器挂载
+// /dav/*
+func DAV(r *ghttp.Request) {
+	h := &webdav.Handler{
+		Prefix:   boot.BasePath + "/dav",
+		Roots:    fileinfos.Roots(),
+		TempDir:  uploadTmpDir(),
+		ReadOnly: !CanWrite(r),
+		Locks:    davLocks,
+		Allow:    protocolAllowed,
+		Remove:   protocolRemove,
+		Writable: protocolWritable,
+		ServeFile: func(w http.ResponseWriter, req *http.Request, file string) {
+			r.Response.ServeFile(file)
+		},
+		OnChange: func(method, file, dest string, size int64) {
+			davChanged(r, method, file, dest, size)
+		},
+	}
+	h.ServeHTTP(r.Response.Writer, r.Request)
+}
+
+// davChanged WebDAV写操作后同步元数据并记录审计日志
+func davChanged(r *ghttp.Request, method, file, dest string, size int64) {
+	switch method {
+	case "PUT":
+		metadata.Delete(fileinfos.FileKey(file))
+		recordChunks(file, size)
+		auditLog(r, audit.OpUpload, file, size)
+		fileEvent(r, EventUpload, file, size, "")
+		scanUpload(file)
+		// 来自主机的复制不再向外复制
+		if r.Header.Get(mirror.Header) == "" {
+			replicate(file)
+		}
+	case "DELETE":
+		metadata.Delete(fileinfos.FileKey(file))
+		auditLog(r, audit.OpDelete, file, 0)
+		fileEvent(r, EventDelete, file, 0, "")
+	case "MOVE":
+		metadata.Rename(fileinfos.FileKey(file), fileinfos.FileKey(dest))
+		auditLog(r, audit.OpRename, dest, size)
+	case "COPY":
+		auditLog(r, audit.OpUpload, dest, size)
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"b0pass/boot"
+	"fmt"
+	"github.com/gogf/gf/net/ghttp"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// ServeUIFile 提供网页界面中的页面(如 public/page/get.html)，开发模式下每次读取源码目录中的文件且不缓存
+func ServeUIFile(r *ghttp.Request, name string) {
+	file := boot.UIPath(name)
+	if !boot.Dev {
+		r.Response.ServeFile(file)
+		return
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		r.Response.WriteStatus(http.StatusNotFound)
+		return
+	}
+	r.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	r.Response.Header().Set("Cache-Control", "no-store")
+	r.Response.Write(b)
+}
+
+// DevReload 开发模式(--dev)下页面自动刷新的推送 /api/dev/reload: 连接后发送本次启动的标识，界面文件变化时发送reload
+func DevReload(r *ghttp.Request) {
+	if !boot.Dev {
+		r.Response.WriteStatus(http.StatusNotFound)
+		r.Exit()
+	}
+	h := r.Response.Header()
+	h.Set("Content-Type", "text/event-stream; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	conn, w, err := hijackStream(r)
+	if err != nil {
+		r.Response.WriteStatus(http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	changes, cancel := boot.DevChanges()
+	defer cancel()
+	closed := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, conn)
+		close(closed)
+	}()
+	_, _ = fmt.Fprintf(w, "retry: 1000\ndata: %s\n\n", boot.DevID)
+	if w.Flush() != nil {
+		return
+	}
+	ping := time.NewTicker(eventsPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ping.C:
+			_, _ = w.WriteString(": ping\n\n")
+		case <-changes:
+			_, _ = w.WriteString("data: reload\n\n")
+		}
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
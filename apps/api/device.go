@@ -0,0 +1,305 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/authn"
+	"b0pass/library/bufpool"
+	"b0pass/library/device"
+	"b0pass/library/fileinfos"
+	"b0pass/library/humanize"
+	"b0pass/library/ipaddress"
+	"b0pass/library/notify"
+	"b0pass/library/response"
+	"encoding/json"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/gogf/gf/os/gfile"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deviceChunk 建议的分片大小，移动端后台任务可按需调整
+const deviceChunk = 8 << 20
+
+// DeviceCode 生成配对码(仅主电脑可访问)，返回二维码内容 b0pass://pair?host=&code=
+func DeviceCode(r *ghttp.Request) {
+	if !fromHost(r) {
+		response.Error(r, http.StatusForbidden, 403, "请在主电脑上生成配对码")
+	}
+	code, expires := boot.Devices.NewCode()
+	var urls []string
+	ips, _ := ipaddress.GetIP()
+	for _, ip := range ips {
+		host := net.JoinHostPort(ip, strconv.Itoa(boot.ServPort))
+		q := url.Values{"host": {host}, "code": {code}}
+		if boot.BasePath != "" {
+			q.Set("base", boot.BasePath)
+		}
+		urls = append(urls, "b0pass://pair?"+q.Encode())
+	}
+	response.JSON(r, 0, "ok", g.Map{"code": code, "expires": expires.Unix(), "urls": urls})
+}
+
+// DevicePair 使用配对码配对 POST {"code":"","name":"","platform":"android|ios"}
+func DevicePair(r *ghttp.Request) {
+	var req struct {
+		Code     string `json:"code"`
+		Name     string `json:"name"`
+		Platform string `json:"platform"`
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	tok, err := boot.Devices.Pair(req.Code, req.Name, req.Platform)
+	if err == device.ErrCode {
+		response.Error(r, http.StatusUnauthorized, 401, err.Error())
+	} else if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	notify.Send(notify.Event{Type: "device", Level: "info", Title: "设备已配对: " + req.Name,
+		Data: g.Map{"id": tok.DeviceID, "name": req.Name, "platform": req.Platform, "ip": r.GetClientIp()}})
+	response.JSON(r, 0, "ok", deviceToken(tok))
+}
+
+// DeviceRefresh 刷新令牌 POST {"refresh_token":""}
+func DeviceRefresh(r *ghttp.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	_ = json.Unmarshal(r.GetRaw(), &req)
+	tok, err := boot.Devices.Refresh(req.RefreshToken)
+	if err == device.ErrToken {
+		response.Error(r, http.StatusUnauthorized, 401, err.Error())
+	} else if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok", deviceToken(tok))
+}
+
+// DevicePush 注册推送 POST {"provider":"fcm|apns|webpush","token":""}，provider为空时取消
+func DevicePush(r *ghttp.Request) {
+	d := currentDevice(r)
+	var req device.Push
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	if err := boot.Devices.SetPush(d.ID, req.Provider, req.Token); err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok")
+}
+
+// DeviceList 已配对的设备(仅主电脑可访问)
+func DeviceList(r *ghttp.Request) {
+	if !fromHost(r) {
+		response.Error(r, http.StatusForbidden, 403, "仅主电脑可查看")
+	}
+	loc, now := boot.Locale(r.Request), time.Now()
+	list := make([]deviceView, 0)
+	for _, d := range boot.Devices.List() {
+		v := deviceView{Device: d, PairedText: humanize.Date(d.Paired, now, loc)}
+		if !d.LastSeen.IsZero() {
+			v.LastSeenText = humanize.Ago(d.LastSeen, now, loc)
+		}
+		list = append(list, v)
+	}
+	response.JSON(r, 0, "ok", list)
+}
+
+// deviceView 设备及按语言格式化的配对时间与最近访问时间
+type deviceView struct {
+	device.Device
+	PairedText   string `json:"paired_text"`
+	LastSeenText string `json:"last_seen_text"`
+}
+
+// DeviceRevoke 取消配对(仅主电脑可访问) /api/device/revoke?id=
+func DeviceRevoke(r *ghttp.Request) {
+	if !fromHost(r) {
+		response.Error(r, http.StatusForbidden, 403, "仅主电脑可取消配对")
+	}
+	id := r.GetString("id")
+	if err := boot.Devices.Revoke(id); err != nil {
+		response.Error(r, http.StatusNotFound, 201, err.Error())
+	}
+	_ = boot.Prefs.Delete("device:" + id)
+	_ = boot.Clients.Delete("device:" + id)
+	response.JSON(r, 0, "ok")
+}
+
+// DeviceUpload 适合移动端后台任务的分片上传
+// /api/device/upload?path=&name=&size=&mtime=&algo=&hash=
+// HEAD/GET 查询已接收的大小(Upload-Offset响应头)；PUT/POST 请求体为原始数据，从Upload-Offset请求头指定的位置追加
+// 连接中断时已接收的数据仍会保留，客户端重新查询offset后续传即可，无需在单个请求内完成整个文件
+func DeviceUpload(r *ghttp.Request) {
+	currentDevice(r)
+	// 只读取URL参数: gf在URL中找不到参数时会读取整个请求体解析表单
+	name := gfile.Basename(r.GetQueryString("name"))
+	size := r.GetQueryInt64("size")
+	if name == "" || name == "." || size <= 0 {
+		response.Error(r, http.StatusBadRequest, 201, "name and size are required")
+	}
+	checkUploadSize(r, size)
+	savePath := savePathOf(r, r.GetQueryString("path"), name)
+	id := fileinfos.UploadID(filepath.Clean(savePath), size, strings.ToLower(r.GetQueryString("hash")))
+	tmpDir := uploadTmpDir()
+	var offset int64
+	if info, err := os.Stat(fileinfos.PartFile(tmpDir, id)); err == nil {
+		offset = info.Size()
+	}
+	result := func(msg string, offset int64) {
+		r.Response.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		response.JSON(r, 0, msg, g.Map{"id": id, "offset": offset, "size": size, "chunk": deviceChunk})
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		result("ok", offset)
+	}
+	if boot.Storage.Blocked() {
+		response.Error(r, http.StatusInsufficientStorage, 507, "磁盘空间不足，已停止接收上传")
+	}
+	checkQuota(r, size-offset)
+	if offset == 0 {
+		checkConflict(r, savePath, "")
+		markUpload(savePath, requestStart(r))
+	}
+	policy := durability(r)
+	want, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || want != offset {
+		r.Response.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		response.Error(r, http.StatusConflict, 409, fmt.Sprintf("offset mismatch: have %d", offset), g.Map{"id": id, "offset": offset})
+	}
+	part, err := fileinfos.OpenPart(tmpDir, id, offset)
+	if err != nil {
+		response.Error(r, http.StatusConflict, 409, err.Error())
+	}
+	n, err := bufpool.Copy(part, io.LimitReader(r.Body, size-offset))
+	if err == nil {
+		err = syncChunk(policy, part)
+	}
+	_ = part.Close()
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	if offset+n < size {
+		result("partial", offset+n)
+	}
+	savePath = commitPart(r, fileinfos.PartFile(tmpDir, id), savePath, size)
+	r.Response.Header().Set("Upload-Offset", strconv.FormatInt(size, 10))
+	ret := g.Map{"id": id, "offset": size, "size": size, "chunk": deviceChunk, "name": filepath.Base(savePath), "url": fileinfos.FileURL(savePath)}
+	if rc := requestedReceipt(r, savePath); rc != nil {
+		ret["receipt"] = rc
+	}
+	response.JSON(r, 0, "ok", ret)
+}
+
+func deviceToken(t *device.Token) g.Map {
+	return g.Map{
+		"device_id":     t.DeviceID,
+		"access_token":  t.AccessToken,
+		"refresh_token": t.RefreshToken,
+		"expires_in":    t.ExpiresIn,
+		"chunk":         deviceChunk,
+		"time":          time.Now().Unix(),
+	}
+}
+
+// BearerToken 请求中的Bearer令牌
+func BearerToken(r *ghttp.Request) string {
+	return authn.BearerToken(r.Request)
+}
+
+// currentDevice 校验Bearer令牌，返回对应设备
+func currentDevice(r *ghttp.Request) device.Device {
+	d, ok := boot.Devices.Verify(BearerToken(r))
+	if !ok {
+		r.Response.Header().Set("WWW-Authenticate", `Bearer realm="B0Pass"`)
+		response.Error(r, http.StatusUnauthorized, 401, "invalid or expired access token")
+	}
+	return d
+}
+
+// ClientKey 区分客户端的标识: 已配对设备为设备ID，否则为连接地址
+func ClientKey(r *ghttp.Request) string {
+	if token := BearerToken(r); token != "" {
+		if d, ok := boot.Devices.Verify(token); ok {
+			return "device:" + d.ID
+		}
+	}
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	return host
+}
+
+// untrustedParam 请求经不受信任的代理转发
+const untrustedParam = "b0pass.untrusted"
+
+// MarkUntrusted 记录请求带有代理请求头但来源不在proxy.trusted中，此时连接地址是代理而不是客户端
+func MarkUntrusted(r *ghttp.Request) {
+	r.SetParam(untrustedParam, true)
+}
+
+// fromHost 请求是否来自主电脑(本机回环地址或本机网卡地址)
+// 使用连接地址而不是GetClientIp，后者信任可伪造的X-Real-IP请求头；经受信任的代理时连接地址已改为客户端地址，
+// 经不受信任的代理转发的请求不认定为主电脑(同一台电脑上的反向代理转发的请求都来自本机回环地址)；
+// 主电脑浏览器中打开的其他网页发起的请求同样来自本机，因此还要求请求不是跨域发起的
+func fromHost(r *ghttp.Request) bool {
+	if r.GetParam(untrustedParam) != nil || !sameOrigin(r) {
+		return false
+	}
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	ips, _ := ipaddress.GetIP()
+	for _, s := range ips {
+		if ip.Equal(net.ParseIP(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin 请求不是其他网页跨域发起的: Origin与Host一致、Sec-Fetch-Site不是cross-site或same-site，
+// 且Host为IP地址、localhost或本机名，防止其他网站的域名重新解析到本机(DNS重绑定)后以同源身份访问
+func sameOrigin(r *ghttp.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "cross-site", "same-site":
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(u.Host, r.Host) {
+			return false
+		}
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(strings.Trim(host, "[]")) != nil || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	name, _ := os.Hostname()
+	name = strings.ToLower(name)
+	return name != "" && (host == name || host == name+".local")
+}
+
+// isJSON 请求体为application/json；主电脑接口只接受JSON请求体，
+// 其他网页无法不经预检以表单或text/plain跨域提交
+func isJSON(r *ghttp.Request) bool {
+	t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return t == "application/json"
+}
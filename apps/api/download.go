@@ -0,0 +1,163 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/disposition"
+	"b0pass/library/fileguard"
+	"b0pass/library/fileinfos"
+	"b0pass/library/httpcache"
+	"b0pass/library/logger"
+	"b0pass/library/mimetype"
+	"b0pass/library/notify"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Files 共享目录下载(目录显示列表)
+// 按配置检测下载过程中文件是否被修改: abort中断传输，snapshot先复制快照再传输
+func Files(r *ghttp.Request) {
+	rel := path.Clean("/" + strings.TrimPrefix(boot.TrimBase(r.URL.Path), "/files"))
+	if rel == "/" && fileinfos.Roots().Multi() {
+		listDir(r, rel)
+		return
+	}
+	file := fileinfos.FilePath(rel)
+	info, err := os.Stat(file)
+	if err != nil {
+		r.Response.WriteStatus(http.StatusNotFound)
+		return
+	}
+	if info.IsDir() {
+		listDir(r, rel)
+		return
+	}
+	serveFile(r, file, info)
+}
+
+// listDir 目录列表，按符号链接与挂载点策略及[hidden]过滤(多根目录时顶层为各根目录)，列为链接的项指向链接的目标
+func listDir(r *ghttp.Request, rel string) {
+	rules := hiddenRules(r)
+	infos, err := fileinfos.ReadDir(rel)
+	if err != nil {
+		r.Response.WriteStatus(http.StatusNotFound)
+		return
+	}
+	prefix := strings.TrimSuffix(boot.BasePath+"/files"+rel, "/")
+	r.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	r.Response.Writef("<html><body><h1>Index of %s/</h1><hr /><table>", html.EscapeString(prefix))
+	if rel != "/" {
+		r.Response.Writef(`<tr><td><a href="%s/">..</a></td></tr>`, (&url.URL{Path: path.Dir(prefix)}).EscapedPath())
+	}
+	for _, info := range infos {
+		if rules.Hidden(path.Join(rel, info.Name())) {
+			continue
+		}
+		href := (&url.URL{Path: prefix + "/" + info.Name()}).EscapedPath()
+		if l := fileinfos.LinkOf(info); l != "" {
+			href = (&url.URL{Path: boot.BasePath + fileinfos.FileURL(l)}).EscapedPath()
+		}
+		name, size := info.Name(), fmt.Sprint(info.Size())
+		if info.IsDir() {
+			href, name, size = href+"/", name+"/", "-"
+		}
+		r.Response.Writef(`<tr><td><a href="%s">%s</a></td><td>%s</td><td style="text-align:right">%s</td></tr>`,
+			href, html.EscapeString(name), info.ModTime().Format("2006-01-02 15:04:05"), size)
+	}
+	r.Response.Write("</table><hr /></body></html>")
+}
+
+// serveFile 传输文件并记录审计日志
+func serveFile(r *ghttp.Request, file string, info os.FileInfo) {
+	if serveProtected(r, file, info) {
+		return
+	}
+	contentHeaders(r, file)
+	mirrorRedirect(r, file, info)
+	// 文件未变化时返回304，不计入下载记录
+	httpcache.Set(r.Response.Header(), info)
+	if httpcache.NotModified(r.Request, info) {
+		r.Response.WriteHeader(http.StatusNotModified)
+		return
+	}
+	chunkHeaders(r, file, info)
+	atomic.AddInt64(&downloading, 1)
+	defer atomic.AddInt64(&downloading, -1)
+	stamp := fileguard.Stamp{Size: info.Size(), ModTime: info.ModTime()}
+	switch g.Config().GetString("download.changed", fileguard.PolicyAbort) {
+	case fileguard.PolicyOff:
+		r.Response.ServeFile(file)
+	case fileguard.PolicySnapshot:
+		snap, cleanup, err := fileguard.Snapshot(file, uploadTmpDir())
+		if err != nil {
+			r.Response.WriteStatus(http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		defer cleanup()
+		r.Response.ServeFile(snap)
+	default:
+		interval := time.Duration(g.Config().GetInt("download.interval", 1)) * time.Second
+		w := fileguard.Watch(file, stamp, interval, func() {
+			abortWrite(r.Response.Writer.RawWriter())
+		})
+		r.Response.ServeFile(file)
+		if w.Stop() {
+			downloadChanged(r, file)
+			return
+		}
+	}
+	// 分段续传(Range非0起始)的请求不重复记录
+	if rg := r.Header.Get("Range"); rg == "" || strings.HasPrefix(rg, "bytes=0-") {
+		auditLog(r, audit.OpDownload, file, info.Size())
+		// 客户端中途断开的下载不计入传输记录
+		if r.Context().Err() == nil {
+			recordTransfer(r, audit.OpDownload, file, info.Size(), requestStart(r))
+		}
+		runScript(ClientKey(r), EventDownload, file, info.Size(), "")
+	}
+}
+
+// contentHeaders 按内容识别的Content-Type，浏览器能直接显示或播放的类型inline打开，
+// 其余类型及download=1时作为附件下载；调用方已指定Content-Disposition时不覆盖
+func contentHeaders(r *ghttp.Request, file string) {
+	h := r.Response.Header()
+	typ := mimetype.File(file)
+	h.Set("Content-Type", typ)
+	h.Set("X-Content-Type-Options", "nosniff")
+	if h.Get("Content-Disposition") == "" {
+		kind := disposition.Attachment
+		if mimetype.Inline(typ) && !r.GetQueryBool("download") {
+			kind = disposition.Inline
+		}
+		h.Set("Content-Disposition", disposition.Header(kind, filepath.Base(file), r.UserAgent()))
+	}
+}
+
+// abortWrite 使正在进行的响应写入立即失败，中断传输
+func abortWrite(w http.ResponseWriter) {
+	if d, ok := w.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		_ = d.SetWriteDeadline(time.Now())
+	}
+}
+
+// downloadChanged 下载过程中文件被修改
+func downloadChanged(r *ghttp.Request, file string) {
+	key := fileinfos.FileKey(file)
+	logger.Warn("download", "aborted", "path", key, "err", fileguard.ErrChanged)
+	notify.Send(notify.Event{
+		Type:  "download",
+		Level: "warn",
+		Title: "文件在下载过程中被修改，传输已中断: " + key,
+		Data:  map[string]string{"path": key, "client": r.GetClientIp()},
+	})
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"b0pass/library/archive"
+	"b0pass/library/fileinfos"
+	"b0pass/library/fsync"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"os"
+)
+
+// uploadDurability 本次上传的同步策略: 查询参数、multipart表单字段durability或X-Durability请求头，
+// 未指定时为upload.durability；只读取已解析的表单，不消耗请求体
+func uploadDurability(r *ghttp.Request) (string, error) {
+	v := r.GetQueryString("durability")
+	if v == "" {
+		v = r.PostForm.Get("durability")
+	}
+	if v == "" {
+		v = r.Header.Get("X-Durability")
+	}
+	return fsync.ParsePolicy(v, g.Config().GetString("upload.durability", fsync.OnComplete))
+}
+
+// durability 同uploadDurability，不合法时返回400
+func durability(r *ghttp.Request) string {
+	policy, err := uploadDurability(r)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	return policy
+}
+
+// commitUpload 将暂存文件原子地移动到目标位置；none以外的策略在rename前同步数据、rename后同步目录，
+// 断电或取消后列表中不会出现不完整的文件
+func commitUpload(policy, tmp, savePath string) error {
+	if policy == fsync.None {
+		return fileinfos.Commit(tmp, savePath)
+	}
+	return fileinfos.CommitSync(tmp, savePath)
+}
+
+// syncUpload 按同步策略同步已移动到目标位置的文件及其所在目录
+// 单个请求完成的上传只有一个分片，per-chunk与on-complete相同
+func syncUpload(policy, savePath string) error {
+	if policy == fsync.None {
+		return nil
+	}
+	return fsync.File(savePath)
+}
+
+// syncChunk per-chunk策略下同步刚写入的分片
+func syncChunk(policy string, part *os.File) error {
+	if policy != fsync.PerChunk {
+		return nil
+	}
+	return part.Sync()
+}
+
+// batchSync tar批量上传的同步方式: on-complete全部写完后一次同步，per-chunk逐个文件同步
+func batchSync(policy string) string {
+	switch policy {
+	case fsync.None:
+		return archive.SyncNone
+	case fsync.PerChunk:
+		return archive.SyncFile
+	}
+	return archive.SyncGroup
+}
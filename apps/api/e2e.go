@@ -0,0 +1,180 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/disposition"
+	"b0pass/library/e2e"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"io"
+	"net/http"
+	"time"
+)
+
+// encryptedStore 未能创建密文目录时返回503
+func encryptedStore(r *ghttp.Request) *e2e.Store {
+	if boot.Encrypted == nil {
+		response.Error(r, http.StatusServiceUnavailable, 201, "加密存储不可用")
+	}
+	return boot.Encrypted
+}
+
+// encryptedView 文件信息，meta为加密的文件名与类型，只有持有密钥的一方可以解密
+func encryptedView(b e2e.Blob) g.Map {
+	m := g.Map{"id": b.ID, "meta": b.Meta, "size": b.Size, "plain": b.Plain, "chunk": b.Chunk,
+		"created": b.Created, "downloads": b.Downloads, "url": boot.BasePath + "/e/" + b.ID}
+	if !b.Expires.IsZero() {
+		m["expires"] = b.Expires
+	}
+	return m
+}
+
+// E2EUpload 上传浏览器加密后的文件，请求体为密文，X-E2E-Meta为加密的文件信息；服务端只保存密文，
+// 返回的/e/<id>加上#密钥即为下载链接；days为保存天数，不超过e2e.days
+// POST /api/e2e?days=7
+func E2EUpload(r *ghttp.Request) {
+	store := encryptedStore(r)
+	if boot.Storage.Blocked() {
+		response.Error(r, http.StatusInsufficientStorage, 507, "磁盘空间不足，已停止接收上传")
+	}
+	if msg := quotaError(r, r.Request.ContentLength); msg != "" {
+		response.Error(r, http.StatusInsufficientStorage, 507, msg)
+	}
+	b := e2e.Blob{Meta: r.Header.Get("X-E2E-Meta"), Owner: clientID(r)}
+	days := g.Config().GetInt("e2e.days", 7)
+	if d := r.GetQueryInt("days"); d > 0 && (days == 0 || d < days) {
+		days = d
+	}
+	if days > 0 {
+		b.Expires = time.Now().AddDate(0, 0, days)
+	}
+	b, err := store.Put(r.Body, b, boot.UploadLimit())
+	switch {
+	case err == e2e.ErrFormat:
+		response.Error(r, http.StatusBadRequest, 201, "请求体须为加密后的文件(B0E1格式)，X-E2E-Meta为加密的文件信息")
+	case err == e2e.ErrTooLarge:
+		response.Error(r, http.StatusRequestEntityTooLarge, 201, err.Error())
+	case err != nil:
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	audit.Record(audit.Entry{Op: audit.OpUpload, Path: "/e/" + b.ID, Size: b.Size, Client: r.GetClientIp(), Agent: r.UserAgent()})
+	response.JSON(r, 0, "ok", encryptedView(b))
+}
+
+// E2EList 当前设备上传的加密文件
+func E2EList(r *ghttp.Request) {
+	list, err := encryptedStore(r).List(clientID(r))
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	ret := make([]g.Map, 0, len(list))
+	for _, b := range list {
+		ret = append(ret, encryptedView(b))
+	}
+	response.JSON(r, 0, "ok", ret)
+}
+
+// E2EDelete 删除加密文件，只有上传的设备可以删除
+func E2EDelete(r *ghttp.Request) {
+	store := encryptedStore(r)
+	b, err := store.Get(r.GetQueryString("id"))
+	if err != nil {
+		response.Error(r, http.StatusNotFound, 201, err.Error())
+	}
+	if b.Owner != clientID(r) {
+		response.Error(r, http.StatusForbidden, 403, "只有上传的设备可以删除")
+	}
+	if err := store.Delete(b.ID); err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok")
+}
+
+// E2EPage 加密文件的下载页面 /e/<id>#<密钥>，密钥不会发送到服务端，由页面解密
+func E2EPage(r *ghttp.Request) {
+	if boot.Encrypted == nil {
+		r.Response.WriteStatus(http.StatusNotFound, e2e.ErrNoEntry.Error())
+		r.Exit()
+	}
+	if _, err := boot.Encrypted.Get(r.GetRouterString("id")); err != nil {
+		r.Response.WriteStatus(http.StatusNotFound, "链接不存在或已过期")
+		r.Exit()
+	}
+	r.Response.Header().Set("Referrer-Policy", "no-referrer")
+	ServeUIFile(r, "public/page/e2e.html")
+}
+
+// E2EInfo 加密文件的信息 /e/<id>/info
+func E2EInfo(r *ghttp.Request) {
+	b, err := encryptedStore(r).Get(r.GetRouterString("id"))
+	if err != nil {
+		response.Error(r, http.StatusNotFound, 201, err.Error())
+	}
+	view := encryptedView(b)
+	delete(view, "downloads")
+	response.JSON(r, 0, "ok", view)
+}
+
+// E2EBlob 下载密文 /e/<id>/blob，支持Range，由浏览器解密
+func E2EBlob(r *ghttp.Request) {
+	f, b, err := encryptedStore(r).OpenBlob(r.GetRouterString("id"))
+	if err != nil {
+		r.Response.WriteStatus(http.StatusNotFound, err.Error())
+		r.Exit()
+	}
+	defer func() { _ = f.Close() }()
+	r.Response.Header().Set("Content-Type", "application/octet-stream")
+	if serveEncrypted(r, b.ID+".b0e", b.Created, f, b.Size) {
+		_ = boot.Encrypted.Downloaded(b.ID)
+	}
+}
+
+// E2EPlain 由服务端解密后下载 /e/<id>/plain，供命令行等可信的一方使用，密钥通过X-E2E-Key请求头提供，
+// 服务端只在本次请求中使用密钥而不保存；支持Range(只解密需要的分块)
+func E2EPlain(r *ghttp.Request) {
+	key, err := e2e.DecodeKey(r.Header.Get("X-E2E-Key"))
+	if err != nil {
+		r.Response.WriteStatus(http.StatusBadRequest, "X-E2E-Key须为链接#之后的密钥")
+		r.Exit()
+	}
+	f, b, err := encryptedStore(r).OpenBlob(r.GetRouterString("id"))
+	if err != nil {
+		r.Response.WriteStatus(http.StatusNotFound, err.Error())
+		r.Exit()
+	}
+	defer func() { _ = f.Close() }()
+	meta, err := e2e.OpenMeta(key, b.Meta)
+	if err != nil {
+		r.Response.WriteStatus(http.StatusForbidden, err.Error())
+		r.Exit()
+	}
+	plain, err := e2e.NewReader(f, b.Size, key)
+	if err != nil {
+		r.Response.WriteStatus(http.StatusInternalServerError, err.Error())
+		r.Exit()
+	}
+	h := r.Response.Header()
+	h.Set("Content-Disposition", disposition.Header(disposition.Attachment, meta.Name, r.UserAgent()))
+	if meta.Type != "" {
+		h.Set("Content-Type", meta.Type)
+	}
+	h.Set("Cache-Control", "no-store")
+	if serveEncrypted(r, meta.Name, b.Created, plain, plain.Size()) {
+		_ = boot.Encrypted.Downloaded(b.ID)
+	}
+}
+
+// serveEncrypted 接管连接后写出内容(框架会缓冲全部响应且有写超时)，返回是否完整下载
+func serveEncrypted(r *ghttp.Request, name string, modtime time.Time, content io.ReadSeeker, size int64) bool {
+	w, closeConn, err := newCountWriter(r)
+	if err != nil {
+		http.ServeContent(r.Response.Writer.RawWriter(), r.Request, name, modtime, content)
+		return false
+	}
+	defer closeConn()
+	http.ServeContent(w, r.Request, name, modtime, content)
+	flushed := w.w.Flush() == nil
+	return r.Method != http.MethodHead && w.status == http.StatusOK && flushed && w.finished() && w.n == size
+}
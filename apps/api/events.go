@@ -0,0 +1,151 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/device"
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/notify"
+	"b0pass/library/response"
+	"encoding/json"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// EventHandoff 其他设备发给本设备的文件或消息
+const EventHandoff = "handoff"
+
+// eventsPing 推送连接的心跳间隔，用于及时发现已断开的连接
+const eventsPing = 25 * time.Second
+
+// Events 服务端推送(Server-Sent Events): 通知事件(如收到新文件)及其他设备发给本设备的文件或消息
+// /api/events?types=upload,handoff，每条消息的data为通知事件的JSON，首条消息(type为hello)给出本设备的key与名称
+func Events(r *ghttp.Request) {
+	id := deviceIdentity(r)
+	boot.Clients.Seen(id, r.GetClientIp(), r.UserAgent())
+	types := make(map[string]bool)
+	for _, t := range strings.Split(r.GetString("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	h := r.Response.Header()
+	h.Set("Content-Type", "text/event-stream; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	conn, w, err := hijackStream(r)
+	if err != nil {
+		r.Response.WriteStatus(http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	events, cancel := notify.SubscribeAs(id, 64)
+	defer cancel()
+	// 客户端不会再发送数据，读到EOF即连接已关闭
+	closed := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, conn)
+		close(closed)
+	}()
+	send := func(e notify.Event) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", b)
+		return w.Flush()
+	}
+	hello := notify.Event{Type: "hello", Level: "info", Title: boot.ClientName(r.Request),
+		Data: g.Map{"key": device.Key(id), "name": boot.ClientName(r.Request)}, Time: time.Now().Format(time.RFC3339)}
+	if send(hello) != nil {
+		return
+	}
+	ping := time.NewTicker(eventsPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ping.C:
+			_, _ = w.WriteString(": ping\n\n")
+			if w.Flush() != nil {
+				return
+			}
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if len(types) > 0 && !types[e.Type] {
+				continue
+			}
+			if err := send(e); err != nil {
+				logger.Debug("events", "closed", "client", id, "err", err)
+				return
+			}
+		}
+	}
+}
+
+// Send 向另一台在线设备发送文件或消息(如手机上传后通知电脑打开)，对方的/api/events收到type为handoff的事件
+// POST /api/send {"to":"设备key","file":"/files/a.pdf","text":"附言"}，key见/api/clients
+func Send(r *ghttp.Request) {
+	var req struct {
+		To   string `json:"to"`
+		File string `json:"file"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil || req.To == "" || (req.File == "" && req.Text == "") {
+		response.Error(r, http.StatusBadRequest, 201, `请求体须为JSON: {"to":"设备key","file":"/files/a.pdf","text":"附言"}，file与text至少一项`)
+	}
+	if utf8.RuneCountInString(req.Text) > 1000 {
+		response.Error(r, http.StatusBadRequest, 201, "附言不能超过1000字")
+	}
+	target, ok := boot.Clients.Find(req.To)
+	if !ok || !notify.Online(target.ID) {
+		response.Error(r, http.StatusNotFound, 201, "设备不在线")
+	}
+	from := boot.ClientName(r.Request)
+	data := g.Map{"from": from, "from_key": device.Key(deviceIdentity(r)), "text": req.Text}
+	title := from + ": " + req.Text
+	if req.File != "" {
+		file := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+req.File), "/files"))
+		info, err := os.Stat(file)
+		if file == "" || err != nil || info.IsDir() {
+			response.Error(r, http.StatusNotFound, 201, "文件不存在")
+		}
+		data["file"], data["name"], data["size"] = fileinfos.FileURL(file), info.Name(), info.Size()
+		title = from + " 发来文件: " + info.Name()
+	}
+	n := notify.SendTo(target.ID, notify.Event{Type: EventHandoff, Level: "info", Title: title, Data: data})
+	if n == 0 {
+		response.Error(r, http.StatusNotFound, 201, "设备不在线")
+	}
+	response.JSON(r, 0, "ok", g.Map{"to": target.Label(), "delivered": n})
+}
+
+// uploadNotice 推送收到新文件的通知，批量上传时合并为一条
+func uploadNotice(r *ghttp.Request, files []string, size int64) {
+	if len(files) == 0 {
+		return
+	}
+	from := boot.ClientName(r.Request)
+	title := fmt.Sprintf("收到来自%s的新文件: %s", from, path.Base(files[0]))
+	if len(files) > 1 {
+		title = fmt.Sprintf("收到来自%s的%d个新文件", from, len(files))
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = fileinfos.FileURL(f)
+	}
+	notify.Send(notify.Event{Type: EventUpload, Level: "info", Title: title,
+		Data: g.Map{"from": from, "from_key": device.Key(clientID(r)), "files": paths, "size": size}})
+}
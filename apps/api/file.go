@@ -1,46 +1,336 @@
 package api
 
 import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/bufpool"
+	"b0pass/library/diskusage"
 	"b0pass/library/fileinfos"
+	"b0pass/library/formstream"
+	"b0pass/library/hashes"
+	"b0pass/library/jsonstream"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/quota"
 	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
 	"github.com/gogf/gf/net/ghttp"
 	"github.com/gogf/gf/os/gfile"
 	"github.com/gogf/gf/util/gconv"
 	"io"
-	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // 执行文件上传处理
+// 携带id字段时为分片上传(由/api/upload/negotiate协商得到)，按offset追加写入
 func Upload(r *ghttp.Request) {
-	if err := r.ParseMultipartForm(32); err != nil {
-		response.JSON(r, 201, err.Error())
+	if boot.Storage.Blocked() {
+		response.Error(r, http.StatusInsufficientStorage, 507, "磁盘空间不足，已停止接收上传")
 	}
-	if f, h, e := r.FormFile("upload-file"); e == nil {
-		defer func() { _ = f.Close() }()
+	checkQuota(r, r.Request.ContentLength)
+	batch := newUploadBatch(r)
+	defer batch.close()
+	form := parseUpload(r, batch)
+	defer form.Remove()
+	if batch.streaming() {
+		batch.respond()
+	}
+	policy := durability(r)
+	if h := form.File("upload-file"); h != nil {
 		name := gfile.Basename(h.Filename)
 		size := h.Size
 		// Get path
 		pathSub :=r.GetPostString("path")
 		fileinfos.Set("data_path",pathSub)
 		// Save path
-		savePath := fileinfos.GetRootPath() + "/files/" +pathSub+"/"+ name
-		log.Println(savePath)
-		// Upload file
-		file, err := gfile.Create(savePath)
+		savePath := savePathOf(r, pathSub, name)
+		logger.Debug("upload", "save", "path", savePath)
+		if id := r.GetPostString("id"); id != "" {
+			f, err := os.Open(h.Path)
+			if err != nil {
+				response.Error(r, http.StatusInternalServerError, 201, err.Error())
+			}
+			defer func() { _ = f.Close() }()
+			uploadPart(r, f, id, savePath)
+		}
+		// 文件已在解析时写入暂存目录并计算哈希，完成后rename到目标位置
+		algo := uploadAlgo(r)
+		sums := h.Sums
+		if sums[algo] == "" {
+			// algo字段位于文件之后，解析时未知
+			var err error
+			if sums, err = hashes.FileSums(h.Path, hashes.Default, algo); err != nil {
+				response.Error(r, http.StatusInternalServerError, 201, err.Error())
+			}
+		}
+		if hash := strings.ToLower(r.GetPostString("hash")); hash != "" && hash != sums[algo] {
+			response.Error(r, http.StatusUnprocessableEntity, 202, "hash mismatch, upload discarded")
+		}
+		final, err := resolveUpload(r, savePath)
+		if err == fileinfos.ErrSkipped {
+			batch.skip(final)
+		}
 		if err != nil {
-			r.Response.Write(err)
-			return
+			conflictReply(r, final, err, "")
 		}
-		defer func() { _ = file.Close() }()
-		if _, err := io.Copy(file, f); err != nil {
-			response.JSON(r, 201, err.Error())
-			return
+		savePath = final
+		if err := commitUpload(policy, h.Path, savePath); err != nil {
+			response.Error(r, http.StatusInternalServerError, 201, err.Error())
 		}
-		response.JSON(r, 0, "ok", size)
+		verifyUpload(r, savePath, sums)
+		finishUpload(r, savePath, size, sums)
+		batch.finish(savePath)
+		response.JSON(r, 0, "ok", uploadResult(r, savePath, size))
 	} else {
-		response.JSON(r, 201, e.Error())
+		response.Error(r, http.StatusBadRequest, 201, http.ErrMissingFile.Error())
+	}
+}
+
+// parseUpload 流式解析multipart上传，文件部分直接写入目标所在根目录的暂存目录，
+// 内存占用与文件大小无关；解析后的字段可照常通过r.GetPostString等读取；各文件的进度记录到batch
+func parseUpload(r *ghttp.Request, batch *uploadBatch) *formstream.Form {
+	first := true
+	form, err := formstream.Parse(r.Request, formstream.Options{
+		MaxSize: boot.UploadLimit(),
+		Create: func(values url.Values, field, filename string) (*os.File, error) {
+			batch.start(values, filename)
+			dir := uploadTmpDir()
+			// 分片上传追加到续传暂存文件，其余与目标位于同一文件系统以便rename
+			if file := fileinfos.FilePath(values.Get("path") + "/" + cleanName(gfile.Basename(filename))); file != "" && values.Get("id") == "" {
+				dir = rootTmpDir(file)
+			}
+			f, err := fileinfos.CreateTemp(dir, gfile.Basename(filename))
+			if err != nil {
+				return nil, err
+			}
+			// 按声明的大小预先分配，未声明时第一个文件按请求体大小；分片在续传暂存文件上分配
+			size := batch.size()
+			if size == 0 && first && values.Get("id") == "" {
+				size = r.Request.ContentLength
+			}
+			first = false
+			if err := diskusage.Preallocate(f, size); err != nil {
+				_ = f.Close()
+				_ = os.Remove(f.Name())
+				return nil, err
+			}
+			return f, nil
+		},
+		Algos: func(values url.Values) []string {
+			if values.Get("id") != "" {
+				// 分片在写满后整体计算
+				return nil
+			}
+			if algo, ok := allowedAlgo(values.Get("algo")); ok {
+				return []string{hashes.Default, algo}
+			}
+			return []string{hashes.Default}
+		},
+		Progress: batch.written,
+		Saved:    batch.saved,
+	})
+	if err == formstream.ErrTooLarge {
+		response.Error(r, http.StatusRequestEntityTooLarge, 413, "文件超过上传大小限制")
+	}
+	if err == diskusage.ErrNoSpace {
+		response.Error(r, http.StatusInsufficientStorage, 507, "磁盘空间不足")
+	}
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	// 按已解析的表单设置，框架读取参数时不再解析请求体
+	r.PostForm = form.Values
+	r.MultipartForm = &multipart.Form{Value: form.Values}
+	return form
+}
+
+// checkUploadSize 客户端声明的文件大小超过上传大小限制时拒绝
+func checkUploadSize(r *ghttp.Request, size int64) {
+	if max := boot.UploadLimit(); max > 0 && size > max {
+		response.Error(r, http.StatusRequestEntityTooLarge, 413, "文件超过上传大小限制")
+	}
+}
+
+// uploadPart 分片上传，写满声明的size后校验哈希并移动到目标位置
+func uploadPart(r *ghttp.Request, f io.Reader, id, savePath string) {
+	offset := gconv.Int64(r.GetPost("offset"))
+	total := gconv.Int64(r.GetPost("size"))
+	if total <= 0 {
+		response.Error(r, http.StatusBadRequest, 201, "size is required for chunked upload")
+	}
+	checkUploadSize(r, total)
+	if offset == 0 {
+		checkQuota(r, total)
+		// skip与fail在传输前即可决定
+		checkConflict(r, savePath, "")
+		markUpload(savePath, requestStart(r))
+	}
+	policy := durability(r)
+	tmpDir := uploadTmpDir()
+	part, err := fileinfos.OpenPart(tmpDir, id, offset)
+	if err != nil {
+		response.Error(r, http.StatusConflict, 409, err.Error())
+	}
+	if offset == 0 {
+		// 第一个分片时为整个文件预先分配
+		if err := diskusage.Preallocate(part, total); err != nil {
+			_ = part.Close()
+			_ = os.Remove(part.Name())
+			response.Error(r, http.StatusInsufficientStorage, 507, "磁盘空间不足")
+		}
+	}
+	n, err := bufpool.Copy(part, f)
+	if err == nil {
+		err = syncChunk(policy, part)
+	}
+	_ = part.Close()
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	if offset+n < total {
+		response.JSON(r, 0, "partial", g.Map{"id": id, "offset": offset + n})
+	}
+	savePath = commitPart(r, fileinfos.PartFile(tmpDir, id), savePath, offset+n)
+	response.JSON(r, 0, "ok", uploadResult(r, savePath, offset+n))
+}
+
+// commitPart 分片写满后按algo/hash参数校验并按同名处理方式移动到目标位置，返回最终的保存位置
+func commitPart(r *ghttp.Request, partFile, savePath string, size int64) string {
+	algo := uploadAlgo(r)
+	sums, err := hashes.FileSums(partFile, hashes.Default, algo)
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	if hash := strings.ToLower(r.GetString("hash")); hash != "" && hash != sums[algo] {
+		_ = os.Remove(partFile)
+		response.Error(r, http.StatusUnprocessableEntity, 202, "hash mismatch, upload discarded")
+	}
+	if final := checkConflict(r, savePath, partFile); final != savePath {
+		markUpload(final, uploadStart(r, savePath))
+		savePath = final
+	}
+	if err := commitUpload(durability(r), partFile, savePath); err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	verifyUpload(r, savePath, sums)
+	finishUpload(r, savePath, size, sums)
+	return savePath
+}
+
+// verifyUpload 开启回读校验时，从磁盘重新读取已写入的文件核对哈希，不一致则删除并报错
+func verifyUpload(r *ghttp.Request, savePath string, sums map[string]string) {
+	if err := verifyFile(savePath, sums); err != nil {
+		response.Error(r, http.StatusUnprocessableEntity, 202, err.Error())
+	}
+}
+
+// verifyFile 回读校验，不一致时删除文件
+func verifyFile(savePath string, sums map[string]string) error {
+	if !g.Config().GetBool("upload.verify") {
+		return nil
+	}
+	err := hashes.Verify(savePath, hashes.Default, sums[hashes.Default])
+	if err != nil {
+		_ = os.Remove(savePath)
+	}
+	return err
+}
+
+// finishUpload 上传完成: 恢复修改时间、记录元数据(各算法哈希)、签发回执、审计日志(含同步策略)、传输记录、Webhook、推送通知与病毒扫描
+func finishUpload(r *ghttp.Request, savePath string, size int64, sums map[string]string) {
+	protectUpload(r, savePath)
+	if mtime := gconv.Int64(r.Get("mtime")); mtime > 0 {
+		t := time.Unix(mtime, 0)
+		_ = os.Chtimes(savePath, t, t)
+	}
+	fields := map[string]string{"size": strconv.FormatInt(size, 10), quota.FieldOwner: ClientKey(r)}
+	for algo, sum := range sums {
+		fields[algo] = sum
 	}
+	metadata.Set(fileinfos.FileKey(savePath), fields)
+	issueReceipt(r, savePath, size, sums)
+	recordChunks(savePath, size)
+	auditUpload(r, savePath, size, durability(r))
+	recordTransfer(r, audit.OpUpload, savePath, size, uploadStart(r, savePath))
+	fileEvent(r, EventUpload, savePath, size, sums[hashes.Default])
+	uploadNotice(r, []string{savePath}, size)
+	scanUpload(savePath)
+	replicate(savePath)
+}
+
+// uploadAlgos 允许协商的校验算法
+func uploadAlgos() []string {
+	if algos := g.Config().GetStrings("upload.hashes"); len(algos) > 0 {
+		return algos
+	}
+	return hashes.Supported
+}
+
+// uploadAlgo 客户端声明的校验算法，未声明时为sha256，不允许的算法直接拒绝
+func uploadAlgo(r *ghttp.Request) string {
+	algo, ok := allowedAlgo(r.GetString("algo"))
+	if !ok {
+		response.Error(r, http.StatusBadRequest, 201, "unsupported hash algorithm: "+algo)
+	}
+	return algo
+}
+
+// allowedAlgo 校验算法是否允许，为空时为sha256
+func allowedAlgo(algo string) (string, bool) {
+	algo = strings.ToLower(algo)
+	if algo == "" {
+		return hashes.Default, true
+	}
+	for _, a := range uploadAlgos() {
+		if a == algo {
+			return algo, true
+		}
+	}
+	return algo, false
+}
+
+// errNoRoot 多根目录时上传路径未以根目录名开头
+func errNoRoot() string {
+	var names []string
+	for _, root := range fileinfos.Roots() {
+		names = append(names, "/"+root.Name)
+	}
+	return "请在路径中指定上传的根目录: " + strings.Join(names, ", ")
+}
+
+// uploadTmpDir 上传暂存目录(默认根目录下)，分片与断点续传的暂存文件均在此目录
+func uploadTmpDir() string {
+	return fileinfos.TempDir(
+		fileinfos.FilesRoot(),
+		g.Config().GetString("upload.tmpdir"),
+	)
+}
+
+// rootTmpDir 文件所在根目录下的暂存目录，与目标位于同一文件系统，完成后可直接rename
+func rootTmpDir(file string) string {
+	return fileinfos.TempDir(fileinfos.RootOf(file), g.Config().GetString("upload.tmpdir"))
+}
+
+// savePathOf 上传目标路径，sub为共享目录下的相对目录，多根目录时须以根目录名开头；
+// name可含子目录(如收录的目录树)，按[filename]规则逐段调整并保留目录结构
+func savePathOf(r *ghttp.Request, sub, name string) string {
+	file := fileinfos.FilePath(sub + cleanPath(name))
+	if file == "" || fileinfos.Roots().IsRoot(file) {
+		response.Error(r, http.StatusBadRequest, 201, errNoRoot())
+	}
+	// 各根目录可能位于不同磁盘，按目标所在根目录检查
+	if boot.StorageOf(file).Blocked() {
+		response.Error(r, http.StatusInsufficientStorage, 507, "磁盘空间不足，已停止接收上传")
+	}
+	return file
 }
 
 // Uploadx 以小内存上传大文件
@@ -53,16 +343,21 @@ func Uploadx(r *ghttp.Request) {
 		defer func() { _ = f.Close() }()
 		name := gfile.Basename(h.Filename)
 
-		//写入文件
-		dst, err := os.OpenFile(
-			fileinfos.GetRootPath()+"/files/"+name,
-			os.O_WRONLY|os.O_CREATE, 0666,
-		)
-		defer func() { _ = dst.Close() }()
+		//写入暂存文件，完成后rename到目标位置
+		savePath := savePathOf(r, "", name)
+		tmp, err := fileinfos.CreateTemp(rootTmpDir(savePath), name)
 		if err != nil {
 			response.JSON(r, 201, err.Error())
 		}
-		if _, err := io.Copy(dst, f); err != nil {
+		defer func() {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}()
+		if _, err := bufpool.Copy(tmp, f); err != nil {
+			response.JSON(r, 201, err.Error())
+		}
+		_ = tmp.Close()
+		if err := fileinfos.Commit(tmp.Name(), savePath); err != nil {
 			response.JSON(r, 201, err.Error())
 		}
 
@@ -72,23 +367,120 @@ func Uploadx(r *ghttp.Request) {
 	}
 }
 
-// Lists
+// listMaxLimit 列表每页数量上限
+const listMaxLimit = 1000
+
+// Lists 共享目录文件列表，支持过滤、排序与分页，过滤后的总数在X-Total-Count中返回
 func Lists(r *ghttp.Request) {
-	fp := fileinfos.GetRootPath() + "/files/*"
+	q := fileinfos.Query{
+		Filter: r.GetString("filter"),
+		Sort:   r.GetString("sort"),
+		Page:   r.GetInt("page"),
+		Limit:  r.GetInt("limit"),
+	}
+	switch r.GetString("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		response.Error(r, http.StatusBadRequest, 201, "order只能是asc或desc")
+	}
+	if !fileinfos.ValidSort(q.Sort) {
+		response.Error(r, http.StatusBadRequest, 201, "sort只能是以下之一: "+strings.Join(fileinfos.Sorts, ", "))
+	}
+	if q.Page < 0 || q.Limit < 0 {
+		response.Error(r, http.StatusBadRequest, 201, "page与limit不能为负数")
+	}
+	// 指定页码未指定数量时按每页100项
+	if q.Page > 0 && q.Limit == 0 {
+		q.Limit = 100
+	}
+	if q.Limit > listMaxLimit {
+		q.Limit = listMaxLimit
+	}
+	rules := hiddenRules(r)
+	loc, now := boot.Locale(r.Request), time.Now()
+	each := func(fn func(map[string]string) bool) error { return fileinfos.EachPath("/", "files", rules, fn) }
+	if q.Sort == "" && q.Limit == 0 {
+		listStream(r, q, each, loc, now)
+	}
 	var ret []map[string]string
-	ret = fileinfos.ListDirData(fp,"files")
+	var total int
+	if q.Sort != "" {
+		// 排序需要全部列表项
+		ret, total = q.Apply(fileinfos.ListPath("/", "files", rules))
+	} else {
+		// 不排序时只保留当前页
+		ret = make([]map[string]string, 0, q.Limit)
+		total, _ = q.Each(each, func(m map[string]string) bool {
+			ret = append(ret, m)
+			return true
+		})
+	}
+	// 只对当前页做本地化
+	fileinfos.Localize(ret, loc, now)
+	r.Response.Header().Set("X-Total-Count", strconv.Itoa(total))
 	response.JSON(r, 0, "ok", ret)
 }
 
-// Delete
+// listStream 不排序也不分页时以分块编码边读边输出列表，上万个文件的目录也不在内存中构造整个列表；
+// 响应头中没有X-Total-Count，总数即data的长度
+func listStream(r *ghttp.Request, q fileinfos.Query, each func(fn func(map[string]string) bool) error, loc string, now time.Time) {
+	r.Response.Header().Set("Content-Type", "application/json")
+	w, done := chunkedStream(r, http.StatusOK)
+	enc := jsonstream.NewArray(w, `{"err":0,"msg":"ok","data":[`, `]}`)
+	item := make([]map[string]string, 1)
+	_, err := q.Each(each, func(m map[string]string) bool {
+		item[0] = m
+		fileinfos.Localize(item, loc, now)
+		return enc.Encode(m) == nil
+	})
+	done(err == nil && enc.Close() == nil)
+	r.Exit()
+}
+
+// Delete 删除文件或目录，trash.enabled时移入回收站(可通过/api/trash还原)，permanent=1时直接删除
 func Delete(r *ghttp.Request) {
-	f := r.Get("f")
-	fp := fileinfos.GetRootPath()
-	filePath := fp + gconv.String(f)
-	_ = os.RemoveAll(filePath)
+	f := r.GetString("f")
+	filePath := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+f), "/files"))
+	// 不允许删除根目录本身
+	if filePath == "" || fileinfos.Roots().IsRoot(filePath) {
+		response.Error(r, http.StatusBadRequest, 201, "不能删除共享根目录")
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	var size int64
+	if !info.IsDir() {
+		size = info.Size()
+	}
+	key := fileinfos.FileKey(filePath)
+	hash := metadata.Get(key, hashes.Default)
+	bin := boot.TrashBin(filePath)
+	if inDir(bin.Dir, filePath) {
+		response.Error(r, http.StatusBadRequest, 201, "回收站中的文件请通过/api/trash/purge删除")
+	}
+	if g.Config().GetBool("trash.enabled", true) && !r.GetBool("permanent") {
+		item, err := bin.Move(filePath, "/"+key, "delete")
+		if err != nil {
+			response.Error(r, http.StatusInternalServerError, 201, "移入回收站失败: "+err.Error())
+		}
+		r.Response.Header().Set("X-Trash-Id", item.ID)
+	} else if err := os.RemoveAll(filePath); err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	metadata.Delete(key)
+	auditLog(r, audit.OpDelete, filePath, 0)
+	fileEvent(r, EventDelete, filePath, size, hash)
 	response.JSON(r, 0, "ok", filePath)
 }
 
+// inDir file是否为dir或位于dir下
+func inDir(dir, file string) bool {
+	return file == dir || strings.HasPrefix(file, dir+string(filepath.Separator))
+}
+
 // Dump
 func Dump(r *ghttp.Request) {
 	filePath := os.Args[0]
@@ -103,7 +495,7 @@ func UploadShow(r *ghttp.Request) {
         <title>上传文件</title>
     </head>
         <body>
-            <form enctype="multipart/form-data" action="/api/upload" method="post">
+            <form enctype="multipart/form-data" action="`+boot.BasePath+`/api/upload" method="post">
                 <input type="file" name="upload-file" />
                 <input type="submit" value="upload" />
             </form>
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/fileindex"
+	"b0pass/library/history"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/gogf/gf/util/gconv"
+	"net/http"
+)
+
+// fileIndex 已启用的文件索引，未启用时输出错误
+func fileIndex(r *ghttp.Request) *fileindex.Index {
+	x := boot.FileIndex
+	if x == nil {
+		response.Error(r, http.StatusNotFound, 201, "文件索引未启用(index.enabled)")
+	}
+	return x
+}
+
+// IndexDuplicates 内容相同的文件分组，按可节省的空间从大到小排序
+// /api/index/duplicates?min=1048576
+func IndexDuplicates(r *ghttp.Request) {
+	x := fileIndex(r)
+	dups, err := x.Duplicates(gconv.Int64(r.Get("min", 1)))
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	groups := make([]g.Map, 0)
+	var wasted int64
+	for _, files := range dups {
+		paths := make([]string, 0, len(files))
+		for _, f := range files {
+			paths = append(paths, "/files"+f.Path)
+		}
+		w := files[0].Size * int64(len(files)-1)
+		wasted += w
+		groups = append(groups, g.Map{"hash": files[0].Hash, "size": files[0].Size, "files": paths, "wasted": w})
+	}
+	response.JSON(r, 0, "ok", g.Map{"groups": groups, "wasted": wasted, "synced": x.Synced()})
+}
+
+// IndexRecent 时间段内收到(加入共享目录)的文件，经本机上传的同时返回上传的客户端
+// /api/index/recent?from=2024-05-01&to=2024-05-31
+func IndexRecent(r *ghttp.Request) {
+	x := fileIndex(r)
+	from, err := history.ParseTime(r.GetString("from"), false)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	to, err := history.ParseTime(r.GetString("to"), true)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	uploads := make(map[string]audit.Entry)
+	// 审计日志从新到旧，同一路径取最近一次上传
+	for _, e := range audit.Query(audit.OpUpload, 0) {
+		if _, ok := uploads[e.Path]; !ok {
+			uploads[e.Path] = e
+		}
+	}
+	added, err := x.Added(from, to)
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	files := make([]g.Map, 0)
+	for _, f := range added {
+		p := "/files" + f.Path
+		item := g.Map{"path": p, "name": f.Name, "size": f.Size, "mtime": f.MTime, "hash": f.Hash, "added": f.Added}
+		if e, ok := uploads[p]; ok {
+			item["client"], item["agent"], item["uploaded"] = e.Client, e.Agent, e.Time
+		}
+		files = append(files, item)
+	}
+	response.JSON(r, 0, "ok", files)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/filename"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"strings"
+)
+
+// cleanName 按[filename]规则调整客户端提交的文件名
+func cleanName(name string) string {
+	return filename.Clean(name, boot.FilenameRules())
+}
+
+// cleanPath 按[filename]规则调整相对路径中的每一段
+func cleanPath(rel string) string {
+	return filename.CleanPath(rel, boot.FilenameRules())
+}
+
+// Filename 上传前查询文件名保存时会被调整成什么，可指定多个name
+// /api/filename?name=CON.txt&name=a:b.txt&form=nfd
+func Filename(r *ghttp.Request) {
+	names := r.Request.URL.Query()["name"]
+	if len(names) == 0 {
+		response.Error(r, http.StatusBadRequest, 201, "name is required")
+	}
+	o := boot.FilenameRules()
+	if form := strings.ToLower(r.GetQueryString("form")); form != "" {
+		if form != filename.NFC && form != filename.NFD {
+			response.Error(r, http.StatusBadRequest, 201, "form must be nfc or nfd")
+		}
+		o.Form = form
+	}
+	ret := make([]filename.Result, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, filename.Sanitize(name, o))
+	}
+	response.JSON(r, 0, "ok", g.Map{"normalize": o.Form, "portable": o.Portable, "maxbytes": o.MaxBytes, "names": ret})
+}
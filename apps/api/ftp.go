@@ -0,0 +1,72 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/fileinfos"
+	"b0pass/library/ftpd"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"crypto/tls"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+)
+
+func init() {
+	if boot.FTPPort > 0 {
+		go serveFTP(boot.FTPPort)
+	}
+}
+
+// serveFTP 启动内嵌FTP服务
+func serveFTP(port int) {
+	c := g.Config()
+	s := &ftpd.Server{
+		Roots:    fileinfos.Roots(),
+		TempDir:  uploadTmpDir(),
+		Login:    protocolLogin,
+		CanWrite: roleCanWrite,
+		PublicIP: c.GetString("ftp.publicip"),
+		OnChange: ftpChanged,
+		Busy:     boot.BeginTransfer,
+		Allow:    protocolAllowed,
+		Remove:   protocolRemove,
+		Writable: protocolWritable,
+	}
+	_, _ = fmt.Sscanf(c.GetString("ftp.passive"), "%d-%d", &s.PassiveMin, &s.PassiveMax)
+	if cert, key := c.GetString("ftp.cert"), c.GetString("ftp.key"); cert != "" && key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			logger.Error("ftp", "load certificate", "err", err)
+		} else {
+			s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{pair}}
+		}
+	}
+	logger.Info("ftp", "listening", "port", port)
+	boot.OnShutdown(func() { _ = s.Close() })
+	if err := s.ListenAndServe(fmt.Sprintf(":%d", port)); err != nil && !boot.Stopping() {
+		logger.Error("ftp", "serve", "err", err)
+	}
+}
+
+// ftpChanged FTP写操作后同步元数据并记录审计日志
+func ftpChanged(client, cmd, file, dest string, size int64) {
+	entry := audit.Entry{Size: size, Client: client, Agent: "ftp"}
+	switch cmd {
+	case "STOR":
+		metadata.Delete(fileinfos.FileKey(file))
+		entry.Op, entry.Path = audit.OpUpload, file
+		scanUpload(file)
+		replicate(file)
+	case "DELE", "RMD":
+		metadata.Delete(fileinfos.FileKey(file))
+		entry.Op, entry.Path = audit.OpDelete, file
+	case "RNTO":
+		metadata.Rename(fileinfos.FileKey(file), fileinfos.FileKey(dest))
+		entry.Op, entry.Path = audit.OpRename, dest
+	default:
+		return
+	}
+	entry.Path = fileinfos.FileURL(entry.Path)
+	audit.Record(entry)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/gallery"
+	"b0pass/library/logger"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// galleryImage 相册中的一张图片
+type galleryImage struct {
+	gallery.Image
+	Path  string `json:"path"`
+	Thumb string `json:"thumb"`
+}
+
+// Gallery 目录中全部图片的尺寸、拍摄时间与EXIF方向，按拍摄时间排序，供相册网格视图布局
+// /api/gallery?path=/files/手机/DCIM
+func Gallery(r *ghttp.Request) {
+	name := path.Clean("/" + r.GetQueryString("path"))
+	dir := fileinfos.FilePath(strings.TrimPrefix(name, "/files"))
+	st, err := os.Stat(dir)
+	if dir == "" || err != nil || !st.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "目录不存在")
+	}
+	list, err := boot.Gallery.Dir(dir)
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	if err := boot.Gallery.Save(); err != nil {
+		logger.Warn("gallery", "save", "err", err)
+	}
+	images := make([]galleryImage, len(list))
+	for i, img := range list {
+		p := path.Join(name, img.Name)
+		images[i] = galleryImage{Image: img, Path: p, Thumb: boot.BasePath + "/api/thumb?path=" + url.QueryEscape(p)}
+	}
+	response.JSON(r, 0, "ok", g.Map{"path": name, "count": len(images), "images": images})
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/bootstrap"
+	"b0pass/library/bufpool"
+	"b0pass/library/disposition"
+	"b0pass/library/logger"
+	"b0pass/library/proxy"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// 客户端引导页: 另一台电脑打开 /get 或执行页面上的一行命令即可下载对应平台的b0pass
+
+// clientSource 按[get]配置的程序来源
+func clientSource() bootstrap.Source {
+	c := g.Config()
+	dir := c.GetString("get.dir", "clients")
+	if dir != "" && !filepath.IsAbs(dir) {
+		dir = filepath.Join(boot.PathRoot, dir)
+	}
+	self, _ := os.Executable()
+	return bootstrap.Source{Dir: dir, Release: c.GetString("get.release"), Self: self}
+}
+
+// clientPlatform 请求的平台: ?os=&arch= 优先(可为uname输出)，否则按User-Agent推断
+func clientPlatform(r *ghttp.Request) bootstrap.Platform {
+	detected := bootstrap.Detect(r.UserAgent())
+	goos, arch := r.GetString("os"), r.GetString("arch")
+	if goos == "" {
+		goos = detected.OS
+	}
+	if arch == "" {
+		arch = detected.Arch
+		if arch == "" {
+			arch = "amd64"
+		}
+	}
+	return bootstrap.Parse(goos, arch)
+}
+
+// baseURL 请求方访问本服务使用的地址
+func baseURL(r *ghttp.Request) string {
+	return proxy.Scheme(r.Request) + "://" + r.Host + boot.BasePath
+}
+
+// GetPage 客户端下载页 /get
+func GetPage(r *ghttp.Request) {
+	ServeUIFile(r, "public/page/get.html")
+}
+
+// GetInfo 请求方平台对应的下载地址与安装命令 /get/info?os=&arch=
+func GetInfo(r *ghttp.Request) {
+	src := clientSource()
+	p := clientPlatform(r)
+	base := baseURL(r)
+	install := map[string]string{
+		"unix":    bootstrap.Install(base, bootstrap.Platform{OS: "linux"}),
+		"windows": bootstrap.Install(base, bootstrap.Platform{OS: "windows", Arch: "amd64"}),
+	}
+	if p.OS == "windows" {
+		install["windows"] = bootstrap.Install(base, p)
+	}
+	data := g.Map{
+		"platform":  p,
+		"available": src.Available(),
+		"install":   install,
+		"server":    base,
+	}
+	if src.Local(p) != "" || src.Remote(p) != "" {
+		data["file"] = bootstrap.Name + p.Ext()
+		data["url"] = base + "/get/" + bootstrap.Name + "?os=" + p.OS + "&arch=" + p.Arch
+	}
+	response.JSON(r, 0, "ok", data)
+}
+
+// GetScript 类Unix系统的安装脚本 /get/install.sh
+func GetScript(r *ghttp.Request) {
+	r.Response.Header().Set("Content-Type", "text/x-shellscript; charset=utf-8")
+	r.Response.Write(bootstrap.Script(baseURL(r)))
+}
+
+// GetBinary 下载对应平台的程序 /get/b0pass?os=&arch=
+// 本地目录中有该平台的程序(或与本机平台相同)时直接提供，否则从发布页代理下载
+func GetBinary(r *ghttp.Request) {
+	src := clientSource()
+	p := clientPlatform(r)
+	if !p.Valid() {
+		r.Response.WriteStatus(http.StatusBadRequest, "unknown os or arch\n")
+		r.Exit()
+	}
+	name := bootstrap.Name + p.Ext()
+	if file := src.Local(p); file != "" {
+		r.Response.ServeFileDownload(file, name)
+		r.Exit()
+	}
+	remote := src.Remote(p)
+	if remote == "" {
+		r.Response.WriteStatus(http.StatusNotFound, "no "+p.File()+" on this server\n")
+		r.Exit()
+	}
+	resp, err := http.Get(remote)
+	if err != nil {
+		logger.Error("get", "proxy release", "url", remote, "err", err)
+		r.Response.WriteStatus(http.StatusBadGateway, err.Error()+"\n")
+		r.Exit()
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		r.Response.WriteStatus(http.StatusBadGateway, "release: "+resp.Status+"\n")
+		r.Exit()
+	}
+	h := r.Response.Header()
+	h.Set("Content-Type", "application/octet-stream")
+	h.Set("Content-Disposition", disposition.Header(disposition.Attachment, name, r.UserAgent()))
+	if resp.ContentLength >= 0 {
+		h.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+	conn, w, err := hijackStream(r)
+	if err != nil {
+		r.Response.WriteStatus(http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err = bufpool.Copy(w, resp.Body); err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		logger.Error("get", "proxy release", "url", remote, "err", err)
+	}
+}
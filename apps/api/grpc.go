@@ -0,0 +1,60 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/rpc"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+func init() {
+	if boot.GRPCPort > 0 {
+		go serveGRPC(boot.GRPCPort)
+	}
+}
+
+// serveGRPC 启动gRPC接口
+func serveGRPC(port int) {
+	s := &rpc.Server{
+		Roots:        fileinfos.Roots(),
+		TempDir:      uploadTmpDir(),
+		Authenticate: protocolAuth,
+		CanWrite:     roleCanWrite,
+		OnChange:     grpcChanged,
+		Busy:         boot.BeginTransfer,
+		Allow:        protocolAllowed,
+		Writable:     protocolWritable,
+	}
+	logger.Info("grpc", "listening", "port", port)
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		logger.Error("grpc", "listen", "err", err)
+		return
+	}
+	boot.OnShutdown(func() { _ = l.Close() })
+	if err := s.Serve(l); err != nil && !boot.Stopping() {
+		logger.Error("grpc", "serve", "err", err)
+	}
+}
+
+// grpcChanged gRPC上传完成后记录校验值并记录审计日志
+func grpcChanged(client, file string, size int64, sum string) {
+	key := fileinfos.FileKey(file)
+	metadata.Delete(key)
+	metadata.Set(key, map[string]string{hashes.SHA256: sum, "size": strconv.FormatInt(size, 10)})
+	scanUpload(file)
+	replicate(file)
+	audit.Record(audit.Entry{
+		Op:     audit.OpUpload,
+		Path:   fileinfos.FileURL(file),
+		Size:   size,
+		Client: client,
+		Agent:  "grpc",
+	})
+}
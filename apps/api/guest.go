@@ -0,0 +1,78 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/grants"
+	"b0pass/library/humanize"
+	"b0pass/library/response"
+	"github.com/gogf/gf/net/ghttp"
+	"net"
+	"net/http"
+	"time"
+)
+
+// GuestGrant 只读模式下临时授予访客设备上传、删除权限(仅主电脑)
+// device为客户端IP或已配对设备的ID，minutes为授权时长(1-1440分钟)，到期后自动撤销
+func GuestGrant(r *ghttp.Request) {
+	if !fromHost(r) {
+		response.Error(r, http.StatusForbidden, 403, "请在主电脑上操作")
+	}
+	device := r.GetString("device")
+	minutes := r.GetInt("minutes", 30)
+	if device == "" || minutes < 1 || minutes > 1440 {
+		response.Error(r, http.StatusBadRequest, 201, "device不能为空，minutes为1-1440")
+	}
+	g := boot.Grants.Grant(device, r.GetClientIp(), time.Duration(minutes)*time.Minute)
+	audit.Record(audit.Entry{Op: audit.OpGrant, Path: "/", Size: int64(minutes), Client: device, Agent: r.UserAgent()})
+	response.JSON(r, 0, "ok", g)
+}
+
+// GuestRevoke 撤销访客设备的临时授权(仅主电脑)
+func GuestRevoke(r *ghttp.Request) {
+	if !fromHost(r) {
+		response.Error(r, http.StatusForbidden, 403, "请在主电脑上操作")
+	}
+	device := r.GetString("device")
+	if _, ok := boot.Grants.Revoke(device); !ok {
+		response.Error(r, http.StatusNotFound, 201, "授权不存在")
+	}
+	audit.Record(audit.Entry{Op: audit.OpRevoke, Path: "/", Client: device, Agent: r.UserAgent()})
+	response.JSON(r, 0, "ok")
+}
+
+// GuestList 当前的临时授权(仅主电脑)
+func GuestList(r *ghttp.Request) {
+	if !fromHost(r) {
+		response.Error(r, http.StatusForbidden, 403, "请在主电脑上操作")
+	}
+	loc, now := boot.Locale(r.Request), time.Now()
+	list := make([]grantView, 0)
+	for _, g := range boot.Grants.List() {
+		list = append(list, grantView{Grant: g, Remaining: humanize.Duration(g.Expires.Sub(now), loc)})
+	}
+	response.JSON(r, 0, "ok", list)
+}
+
+// grantView 授权及按语言格式化的剩余时长
+type grantView struct {
+	grants.Grant
+	Remaining string `json:"remaining"`
+}
+
+// Elevated 请求方是否有临时写权限
+func Elevated(r *ghttp.Request) bool {
+	return boot.Grants.Allowed(deviceKey(r), time.Now())
+}
+
+// deviceKey 标识请求方设备: 已配对设备使用设备ID，否则使用连接地址(不信任X-Real-IP)
+func deviceKey(r *ghttp.Request) string {
+	if d, ok := boot.Devices.Verify(BearerToken(r)); ok {
+		return d.ID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hidden"
+	"b0pass/library/response"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"path/filepath"
+)
+
+// hiddenRules 本次请求的隐藏规则(all=1、exclude=*.log)，通配符有误时返回400
+func hiddenRules(r *ghttp.Request) hidden.Rules {
+	rules, err := boot.Hidden(r.Request)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, "exclude: "+err.Error())
+	}
+	return rules
+}
+
+// hiddenUnder 将隐藏规则用于目录dir下以/分隔的相对路径，匹配时换算为共享目录下的相对路径
+func hiddenUnder(rules hidden.Rules, dir string) func(rel string) bool {
+	return func(rel string) bool {
+		return rules.Hidden(fileinfos.FileKey(filepath.Join(dir, filepath.FromSlash(rel))))
+	}
+}
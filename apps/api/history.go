@@ -0,0 +1,39 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/disposition"
+	"b0pass/library/history"
+	"b0pass/library/logger"
+	"b0pass/library/response"
+	"github.com/gogf/gf/net/ghttp"
+	"strings"
+)
+
+// HistoryExport 导出传输记录、设备与审计事件
+// /api/history/export?format=csv&from=2024-05-01&to=2024-05-31
+func HistoryExport(r *ghttp.Request) {
+	format := strings.ToLower(r.GetString("format", "csv"))
+	if format != "csv" && format != "json" {
+		response.Error(r, 400, 201, "format must be csv or json", nil)
+	}
+	from, err := history.ParseTime(r.GetString("from"), false)
+	if err != nil {
+		response.Error(r, 400, 201, err.Error(), nil)
+	}
+	to, err := history.ParseTime(r.GetString("to"), true)
+	if err != nil {
+		response.Error(r, 400, 201, err.Error(), nil)
+	}
+	rows := history.Collect(audit.Query("", 0), boot.Devices.List(), from, to)
+	ctype := "text/csv; charset=utf-8"
+	if format == "json" {
+		ctype = "application/json"
+	}
+	r.Response.Header().Set("Content-Type", ctype)
+	r.Response.Header().Set("Content-Disposition", disposition.Header(disposition.Attachment, "history."+format, r.UserAgent()))
+	if err := history.Write(r.Response.Writer, format, rows); err != nil {
+		logger.Error("history", "export", "err", err)
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"b0pass/library/humanize"
+	"b0pass/library/i18n"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"strings"
+)
+
+// I18n 界面文字与接口信息的翻译 /api/i18n/<lang>.json，lang可为zh、en或语言标签(如en-US)，不支持时为setting.locale
+func I18n(r *ghttp.Request) {
+	lang := strings.TrimSuffix(r.GetRouterString("lang"), ".json")
+	lang = humanize.Negotiate(lang, "", g.Config().GetString("setting.locale", humanize.ZH))
+	catalog := i18n.Catalog(lang)
+	if catalog == nil {
+		r.Response.WriteStatus(http.StatusNotFound)
+		r.Exit()
+	}
+	r.Response.Header().Set("Content-Language", lang)
+	r.Response.Header().Set("Cache-Control", "no-cache")
+	_ = r.Response.WriteJson(catalog)
+}
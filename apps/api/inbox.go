@@ -0,0 +1,124 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/inbox"
+	"b0pass/library/response"
+	"encoding/json"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// InboxCreate 创建收集链接 POST {"path":"目标目录","template":"{date}-{device}-{orig}","ttl":24,"archive":true}
+// ttl单位为小时，0为不过期；发送者打开返回的/in/<id>上传，文件按模板命名
+// archive为true时关闭或过期后自动打包归档，缺省为inbox.archive
+func InboxCreate(r *ghttp.Request) {
+	var req struct {
+		Path     string `json:"path"`
+		Template string `json:"template"`
+		TTL      int    `json:"ttl"`
+		Archive  *bool  `json:"archive"`
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	if fileinfos.FilePath(req.Path) == "" {
+		response.Error(r, http.StatusBadRequest, 201, errNoRoot())
+	}
+	sess, err := boot.Inboxes.Create(req.Path, req.Template, time.Duration(req.TTL)*time.Hour)
+	if err == nil && (req.Archive == nil && g.Config().GetBool("inbox.archive") || req.Archive != nil && *req.Archive) {
+		sess, err = boot.Inboxes.Update(sess.ID, func(s *inbox.Session) { s.Archive = true })
+	}
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok", g.Map{"session": sess, "url": boot.BasePath + "/in/" + sess.ID})
+}
+
+// InboxList 收集链接列表
+func InboxList(r *ghttp.Request) {
+	response.JSON(r, 0, "ok", boot.Inboxes.List())
+}
+
+// InboxClose 关闭收集链接，需要归档的会话在后台打包，完成后通知
+func InboxClose(r *ghttp.Request) {
+	sess, err := boot.Inboxes.Close(r.GetString("id"))
+	if err == inbox.ErrNoEntry {
+		response.Error(r, http.StatusNotFound, 201, err.Error())
+	} else if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	if sess.Archive && sess.Archived == "" {
+		go func() { _, _ = boot.ArchiveInbox(sess.ID) }()
+	}
+	response.JSON(r, 0, "ok", sess)
+}
+
+// InboxPage 发送者打开收集链接时的上传页面 GET /in/<id>
+func InboxPage(r *ghttp.Request) {
+	sess, ok := boot.Inboxes.Get(r.GetRouterString("id"))
+	if !ok || !sess.Open(time.Now()) {
+		r.Response.WriteStatus(http.StatusNotFound, "链接不存在或已关闭")
+		r.Exit()
+	}
+	ServeUIFile(r, "public/page/inbox.html")
+}
+
+// InboxUpload 上传到收集链接 PUT /in/<id>?name= 或 POST multipart
+// 文件名由会话模板生成，同名时自动重命名
+func InboxUpload(r *ghttp.Request) {
+	id := r.GetRouterString("id")
+	sess, ok := boot.Inboxes.Get(id)
+	if !ok || !sess.Open(time.Now()) {
+		plainReply(r, http.StatusNotFound, "error: 链接不存在或已关闭\n", nil)
+	}
+	if boot.Storage.Blocked() {
+		plainReply(r, http.StatusInsufficientStorage, "error: 磁盘空间不足，已停止接收上传\n", nil)
+	}
+	if msg := quotaError(r, r.Request.ContentLength); msg != "" {
+		plainReply(r, http.StatusInsufficientStorage, "error: "+msg+"\n", nil)
+	}
+	device := uploaderName(r)
+	save := func(orig string, body io.Reader, size int64) string {
+		name := inbox.Name(sess.Template, inbox.Vars{
+			Time: time.Now(), Device: device, Orig: orig, Seq: len(sess.Files) + 1,
+		})
+		rel := plainSave(r, path.Join(sess.Path, name), body, size)
+		if err := boot.Inboxes.Add(id, rel); err == nil {
+			sess.Files = append(sess.Files, rel)
+		}
+		return rel
+	}
+	var saved []string
+	if mr, err := r.MultipartReader(); err == nil {
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				plainReply(r, http.StatusBadRequest, "error: "+err.Error()+"\n", nil)
+			}
+			if part.FileName() != "" {
+				saved = append(saved, save(part.FileName(), part, 0))
+			}
+		}
+	} else {
+		saved = append(saved, save(r.GetQueryString("name", "upload"), r.Body, r.Request.ContentLength))
+	}
+	if len(saved) == 0 {
+		plainReply(r, http.StatusBadRequest, "error: no file uploaded\n", nil)
+	}
+	plainReply(r, http.StatusOK, strings.Join(saved, "\n")+"\n", saved)
+}
+
+// uploaderName 上传者名称，用于文件名: 设备名称(见boot.ClientName)，无法识别设备时为客户端地址
+func uploaderName(r *ghttp.Request) string {
+	return strings.Replace(boot.ClientName(r.Request), ":", "-", -1)
+}
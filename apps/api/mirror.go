@@ -0,0 +1,151 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/bufpool"
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/mirror"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// downloading 正在进行的下载数，用于判断本机是否繁忙
+var downloading int64
+
+func init() {
+	if boot.Mirror != nil {
+		boot.Mirror.OnDone = mirrorDone
+		boot.Mirror.Chunks = func(rel string) (int64, []string) {
+			return storedChunks(strings.TrimPrefix(rel, "/"))
+		}
+		boot.Mirror.Start(g.Config().GetInt("mirror.workers", 2))
+	}
+}
+
+// replicate 新文件加入镜像复制队列
+func replicate(file string) {
+	if boot.Mirror == nil {
+		return
+	}
+	if err := boot.Mirror.Enqueue(fileinfos.FileKey(file)); err != nil {
+		logger.Error("mirror", "enqueue", "path", fileinfos.FileKey(file), "err", err)
+	}
+}
+
+// mirrorDone 复制完成后在元数据中记录，之后该文件可由镜像提供下载
+func mirrorDone(rel string, err error) {
+	if err != nil {
+		logger.Error("mirror", "replicate", "path", rel, "err", err)
+		return
+	}
+	metadata.Set(strings.TrimPrefix(rel, "/"), map[string]string{"mirror": strconv.FormatInt(time.Now().Unix(), 10)})
+}
+
+// mirrored 文件是否已复制到镜像，文件在复制后被修改则视为未复制
+func mirrored(key string, info os.FileInfo) bool {
+	t, _ := strconv.ParseInt(metadata.Get(key, "mirror"), 10, 64)
+	return boot.Mirror != nil && t > 0 && info.ModTime().Unix() <= t
+}
+
+// mirrorRedirect 本机繁忙时将已复制的文件重定向到镜像
+func mirrorRedirect(r *ghttp.Request, file string, info os.FileInfo) {
+	busy := int64(g.Config().GetInt("mirror.busy"))
+	if busy <= 0 || atomic.LoadInt64(&downloading) < busy || !mirrored(fileinfos.FileKey(file), info) {
+		return
+	}
+	r.Response.Header().Set("Location", boot.Mirror.FileURL(fileinfos.FileKey(file)))
+	r.Response.WriteHeader(http.StatusTemporaryRedirect)
+	r.Exit()
+}
+
+// Peers 文件来源列表: 本机与镜像(含在线状态与连接健康状态)
+func Peers(r *ghttp.Request) {
+	peers := []g.Map{{"role": "primary", "url": "", "online": true}}
+	if boot.Mirror != nil {
+		peers = append(peers, g.Map{"role": "mirror", "url": boot.Mirror.String(), "online": boot.Mirror.Ping() == nil,
+			"health": boot.Mirror.Health()})
+	}
+	response.JSON(r, 0, "ok", peers)
+}
+
+// MirrorVerify 重新复制文件到镜像，镜像上已有的文件只重传与本机不一致的分块
+// /api/mirror/verify?f=/files/a.iso
+func MirrorVerify(r *ghttp.Request) {
+	if boot.Mirror == nil {
+		response.Error(r, http.StatusNotFound, 201, "未配置镜像")
+	}
+	file := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+r.GetString("f")), "/files"))
+	if info, err := os.Stat(file); file == "" || err != nil || info.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	if err := boot.Mirror.Enqueue(fileinfos.FileKey(file)); err != nil {
+		response.Error(r, http.StatusServiceUnavailable, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok")
+}
+
+// MirrorBatch 接收主机批量复制的小文件 PUT /api/mirror/batch，请求体为tar流
+// 与WebDAV复制一样写入默认共享目录，已存在的文件被覆盖
+func MirrorBatch(r *ghttp.Request) {
+	if boot.Storage.Blocked() {
+		response.Error(r, http.StatusInsufficientStorage, 201, "磁盘空间不足，已停止接收上传")
+	}
+	root := fileinfos.FilesRoot()
+	saved := make([]string, 0)
+	err := mirror.ReadBatch(r.Body, func(rel string, mtime time.Time, body io.Reader) error {
+		file := filepath.Join(root, filepath.FromSlash(rel))
+		tmp, err := fileinfos.CreateTemp(uploadTmpDir(), path.Base(rel))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}()
+		n, err := bufpool.Copy(tmp, body)
+		if err != nil {
+			return err
+		}
+		_ = tmp.Close()
+		if err := fileinfos.Commit(tmp.Name(), file); err != nil {
+			return err
+		}
+		_ = os.Chtimes(file, mtime, mtime)
+		davChanged(r, "PUT", file, "", n)
+		saved = append(saved, rel)
+		return nil
+	})
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error(), saved)
+	}
+	response.JSON(r, 0, "ok", saved)
+}
+
+// Sources 文件的下载地址，已复制到镜像的文件同时返回镜像地址
+// /api/sources?f=/files/a.txt
+func Sources(r *ghttp.Request) {
+	f := r.GetString("f")
+	file := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+f), "/files"))
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	key := fileinfos.FileKey(file)
+	sources := []g.Map{{"role": "primary", "url": boot.BasePath + "/files/" + key}}
+	if mirrored(key, info) {
+		sources = append(sources, g.Map{"role": "mirror", "url": boot.Mirror.FileURL(key)})
+	}
+	response.JSON(r, 0, "ok", sources)
+}
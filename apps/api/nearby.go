@@ -0,0 +1,27 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"time"
+)
+
+// Nearby 局域网中的其他b0pass实例(由组播发现)，wake=1时先发送查询，等待各实例应答后返回
+func Nearby(r *ghttp.Request) {
+	if boot.Nearby == nil {
+		response.Error(r, http.StatusServiceUnavailable, 201, "局域网发现未启用")
+	}
+	if r.GetQueryBool("wake") {
+		if err := boot.Nearby.Query(); err != nil {
+			response.Error(r, http.StatusBadGateway, 201, err.Error())
+		}
+		time.Sleep(time.Second)
+	}
+	response.JSON(r, 0, "ok", g.Map{
+		"self":  boot.Nearby.Self.Name,
+		"peers": boot.Nearby.Peers(),
+	})
+}
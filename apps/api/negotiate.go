@@ -0,0 +1,127 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/filename"
+	"b0pass/library/hashes"
+	"b0pass/library/metadata"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/gogf/gf/os/gfile"
+	"github.com/gogf/gf/util/gconv"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 上传协商结果
+const (
+	DecisionAccept        = "accept"
+	DecisionResume        = "resume-from-offset"
+	DecisionDedupe        = "dedupe-hit"
+	DecisionRename        = "rename-needed"
+	DecisionSkip          = "skip-existing"
+	DecisionConflict      = "conflict"
+	DecisionQuotaExceeded = "quota-exceeded"
+)
+
+// Negotiate 上传前协商，在传输任何数据之前决定如何处理
+// /api/upload/negotiate?name=&path=&size=&hash=&algo=&algos=&mtime=&conflict=
+// name按[filename]规则调整，调整后的名称为name，original与changes为原名称与调整原因
+// algos为客户端支持的校验算法列表，返回双方都支持的最优算法(algo)供后续上传使用；
+// hash按algo(默认sha256)计算，命中已有相同内容的文件时直接在服务端完成(秒传)
+func Negotiate(r *ghttp.Request) {
+	name := gfile.Basename(r.GetString("name"))
+	if name == "" || name == "." {
+		response.JSON(r, 201, "name is required")
+	}
+	size := gconv.Int64(r.Get("size"))
+	checkUploadSize(r, size)
+	hash := strings.ToLower(r.GetString("hash"))
+	algo := uploadAlgo(r)
+	accept := hashes.Negotiate(r.GetString("algos"), uploadAlgos())
+	if accept == "" {
+		response.JSON(r, 201, "no common hash algorithm", uploadAlgos())
+	}
+	savePath := savePathOf(r, r.GetString("path"), name)
+	id := fileinfos.UploadID(filepath.Clean(savePath), size, hash)
+	ret := g.Map{"decision": DecisionAccept, "id": id, "offset": 0, "name": filepath.Base(savePath), "algo": accept}
+	// 文件名按[filename]规则调整时告知客户端原因
+	if res := filename.Sanitize(name, boot.FilenameRules()); res.Changed {
+		ret["original"], ret["changes"] = name, res.Changes
+	}
+
+	// 磁盘空间
+	if msg := quotaError(r, size); boot.Storage.Blocked() || msg != "" {
+		ret["decision"] = DecisionQuotaExceeded
+		ret["reason"] = msg
+		response.JSON(r, 0, "ok", ret)
+	}
+	// 断点续传
+	if info, err := os.Stat(fileinfos.PartFile(uploadTmpDir(), id)); err == nil && info.Size() < size {
+		ret["decision"] = DecisionResume
+		ret["offset"] = info.Size()
+		response.JSON(r, 0, "ok", ret)
+	}
+	// 内容相同的文件已存在
+	if hash != "" {
+		if sameContent(savePath, size, algo, hash) {
+			ret["decision"] = DecisionDedupe
+			response.JSON(r, 0, "ok", ret)
+		}
+		if _, err := os.Stat(savePath); os.IsNotExist(err) {
+			for _, key := range metadata.Find(algo, hash) {
+				src := fileinfos.FilePath(key)
+				if !sameContent(src, size, algo, hash) || fileinfos.Clone(src, savePath) != nil {
+					continue
+				}
+				if err := syncUpload(durability(r), savePath); err != nil {
+					response.Error(r, http.StatusInternalServerError, 201, err.Error())
+				}
+				finishUpload(r, savePath, size, knownSums(key))
+				ret["decision"] = DecisionDedupe
+				response.JSON(r, 0, "ok", ret)
+			}
+		}
+	}
+	// 同名文件已存在时按conflict参数(默认upload.conflict)处理
+	final, err := resolveUpload(r, savePath)
+	switch {
+	case err == fileinfos.ErrSkipped:
+		ret["decision"] = DecisionSkip
+	case err == fileinfos.ErrExist:
+		ret["decision"] = DecisionConflict
+	case err != nil:
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	case final != savePath:
+		ret["decision"] = DecisionRename
+		ret["name"] = filepath.Base(final)
+	}
+	response.JSON(r, 0, "ok", ret)
+}
+
+// sameContent 文件是否存在且大小与记录的哈希(algo算法)均一致
+func sameContent(file string, size int64, algo, hash string) bool {
+	info, err := os.Stat(file)
+	if err != nil || info.Size() != size {
+		return false
+	}
+	m := metadata.Fields(fileinfos.FileKey(file))
+	return m[algo] == hash && m["size"] == strconv.FormatInt(size, 10)
+}
+
+// knownSums 已记录的各算法哈希
+func knownSums(key string) map[string]string {
+	m := metadata.Fields(key)
+	sums := make(map[string]string)
+	for _, algo := range hashes.Supported {
+		if m[algo] != "" {
+			sums[algo] = m[algo]
+		}
+	}
+	return sums
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/bufpool"
+	"b0pass/library/diskusage"
+	"b0pass/library/disposition"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/jsonstream"
+	"b0pass/library/proxy"
+	"b0pass/library/response"
+	"fmt"
+	"github.com/gogf/gf/net/ghttp"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 终端(curl)友好的纯文本接口:
+//   curl -T file http://host:port/up/[dir/]      上传(也支持 curl -F f=@file)，返回下载地址
+//   curl http://host:port/ls[/dir]               纯文本列表(?format=ndjson 时每行一个JSON)
+//   curl -O http://host:port/f/<name>            下载
+// 请求头Accept包含application/json时返回与/api接口相同的JSON结构
+
+// wantJSON 客户端是否要求JSON
+func wantJSON(r *ghttp.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// plainReply 输出纯文本或JSON结果并结束请求
+func plainReply(r *ghttp.Request, status int, text string, data interface{}) {
+	if wantJSON(r) {
+		if status >= http.StatusBadRequest {
+			response.Error(r, status, 201, strings.TrimSpace(text), data)
+		}
+		response.JSON(r, 0, "ok", data)
+	}
+	r.Response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	r.Response.WriteHeader(status)
+	r.Response.Write(text)
+	r.Exit()
+}
+
+// plainPath 将URL中前缀之后的部分映射为共享目录下的相对路径
+func plainPath(r *ghttp.Request, prefix string) string {
+	return path.Clean("/" + strings.TrimPrefix(boot.TrimBase(r.URL.Path), prefix))
+}
+
+// plainURL 文件的下载地址
+func plainURL(r *ghttp.Request, rel string) string {
+	u := url.URL{Scheme: proxy.Scheme(r.Request), Host: r.Host, Path: boot.BasePath + "/f" + rel}
+	return u.String()
+}
+
+// PlainUpload 上传 PUT /up/[dir/]name 或 POST /up/[dir/] (multipart)
+// 同名文件已存在时按conflict参数处理，默认(upload.conflict)自动重命名
+func PlainUpload(r *ghttp.Request) {
+	if boot.Storage.Blocked() {
+		plainReply(r, http.StatusInsufficientStorage, "error: 磁盘空间不足，已停止接收上传\n", nil)
+	}
+	if msg := quotaError(r, r.Request.ContentLength); msg != "" {
+		plainReply(r, http.StatusInsufficientStorage, "error: "+msg+"\n", nil)
+	}
+	rel := plainPath(r, "/up")
+	var saved []string
+	if mr, err := r.MultipartReader(); err == nil {
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				plainReply(r, http.StatusBadRequest, "error: "+err.Error()+"\n", nil)
+			}
+			if part.FileName() == "" {
+				continue
+			}
+			saved = append(saved, plainSave(r, path.Join(rel, filepath.Base(part.FileName())), part, 0))
+		}
+	} else {
+		// curl -T file url/ 会把文件名追加到URL，未追加时使用name参数，都没有时按时间命名
+		if strings.HasSuffix(r.URL.Path, "/") || rel == "/" {
+			name := filepath.Base(r.GetQueryString("name"))
+			if name == "." || name == "/" {
+				name = "upload-" + time.Now().Format("20060102-150405")
+			}
+			rel = path.Join(rel, name)
+		}
+		saved = append(saved, plainSave(r, rel, r.Body, r.Request.ContentLength))
+	}
+	if len(saved) == 0 {
+		plainReply(r, http.StatusBadRequest, "error: no file uploaded\n", nil)
+	}
+	var text strings.Builder
+	for _, rel := range saved {
+		text.WriteString(plainURL(r, rel) + "\n")
+	}
+	plainReply(r, http.StatusOK, text.String(), saved)
+}
+
+// plainSave 写入暂存文件后移动到目标位置，返回最终的相对路径；size已知时预先分配空间
+func plainSave(r *ghttp.Request, rel string, body io.Reader, size int64) string {
+	rel = path.Join(path.Dir(path.Clean("/"+rel)), cleanName(path.Base(rel)))
+	target := fileinfos.FilePath(rel)
+	if target == "" || fileinfos.Roots().IsRoot(target) {
+		plainReply(r, http.StatusBadRequest, "error: "+errNoRoot()+"\n", nil)
+	}
+	policy, err := uploadDurability(r)
+	if err != nil {
+		plainReply(r, http.StatusBadRequest, "error: "+err.Error()+"\n", nil)
+	}
+	tmp, err := fileinfos.CreateTemp(rootTmpDir(target), path.Base(rel))
+	if err != nil {
+		plainReply(r, http.StatusInternalServerError, "error: "+err.Error()+"\n", nil)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	if err := diskusage.Preallocate(tmp, size); err != nil {
+		plainReply(r, http.StatusInsufficientStorage, "error: 磁盘空间不足\n", nil)
+	}
+	multi, _ := hashes.NewMulti(hashes.Default)
+	n, err := bufpool.Copy(io.MultiWriter(tmp, multi), body)
+	if err == nil {
+		err = tmp.Truncate(n)
+	}
+	if err != nil {
+		plainReply(r, http.StatusInternalServerError, "error: "+err.Error()+"\n", nil)
+	}
+	_ = tmp.Close()
+	savePath, err := resolveUpload(r, target)
+	switch err {
+	case nil:
+	case fileinfos.ErrSkipped:
+		return "/" + fileinfos.FileKey(savePath)
+	case fileinfos.ErrExist:
+		plainReply(r, http.StatusConflict, "error: 同名文件已存在: "+path.Base(rel)+"\n", nil)
+	default:
+		plainReply(r, http.StatusBadRequest, "error: "+err.Error()+"\n", nil)
+	}
+	if err := commitUpload(policy, tmp.Name(), savePath); err != nil {
+		plainReply(r, http.StatusInternalServerError, "error: "+err.Error()+"\n", nil)
+	}
+	finishUpload(r, savePath, n, multi.Sums())
+	return "/" + fileinfos.FileKey(savePath)
+}
+
+// PlainList 纯文本目录列表 GET /ls[/dir]
+func PlainList(r *ghttp.Request) {
+	plainList(r, plainPath(r, "/ls"))
+}
+
+// PlainFile 下载 GET /f/<path>，目录时返回列表
+func PlainFile(r *ghttp.Request) {
+	rel := plainPath(r, "/f")
+	file := fileinfos.FilePath(rel)
+	info, err := os.Stat(file)
+	if file == "" && rel == "/" {
+		plainList(r, rel)
+	}
+	if err != nil {
+		plainReply(r, http.StatusNotFound, "error: not found\n", nil)
+	}
+	if info.IsDir() {
+		plainList(r, rel)
+	}
+	r.Response.Header().Set("Content-Disposition", disposition.Header(disposition.Attachment, info.Name(), r.UserAgent()))
+	serveFile(r, file, info)
+}
+
+// plainEntry JSON列表项
+type plainEntry struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Dir   bool   `json:"dir"`
+	MTime int64  `json:"mtime"`
+	Link  string `json:"link,omitempty"` // 列为链接(storage.links = "list")时链接的目标
+}
+
+// plainList 目录列表，按名称顺序分批读取目录并以分块编码边读边输出，上万个文件时也不在内存中构造整个列表
+// 按Accept输出纯文本、JSON或NDJSON(application/x-ndjson或?format=ndjson，每行一项)
+func plainList(r *ghttp.Request, rel string) {
+	rules := hiddenRules(r)
+	d, err := fileinfos.OpenDir(rel)
+	if err != nil {
+		plainReply(r, http.StatusNotFound, "error: not found\n", nil)
+	}
+	defer func() { _ = d.Close() }()
+	var enc *jsonstream.Encoder
+	switch {
+	case wantNDJSON(r):
+		r.Response.Header().Set("Content-Type", jsonstream.ContentType)
+	case wantJSON(r):
+		r.Response.Header().Set("Content-Type", "application/json")
+	default:
+		r.Response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w, done := chunkedStream(r, http.StatusOK)
+	switch {
+	case wantNDJSON(r):
+		enc = jsonstream.NewLines(w)
+	case wantJSON(r):
+		enc = jsonstream.NewArray(w, `{"err":0,"msg":"ok","data":[`, `]}`)
+	}
+	var e plainEntry
+	for {
+		infos, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			done(false)
+			r.Exit()
+		}
+		for _, info := range infos {
+			if rules.Hidden(path.Join(rel, info.Name())) {
+				continue
+			}
+			e = plainEntry{Name: info.Name(), Path: path.Join(rel, info.Name()), Size: info.Size(), Dir: info.IsDir(), MTime: info.ModTime().Unix()}
+			if e.Dir {
+				e.Size = 0
+			}
+			if l := fileinfos.LinkOf(info); l != "" {
+				e.Link = "/" + fileinfos.FileKey(l)
+			}
+			if enc != nil {
+				err = enc.Encode(&e)
+			} else {
+				name := e.Name
+				if e.Dir {
+					name += "/"
+				}
+				if e.Link != "" {
+					name += " -> " + e.Link
+				}
+				_, err = fmt.Fprintf(w, "%8s  %s  %s\n", humanSize(e.Size, e.Dir), info.ModTime().Format("2006-01-02 15:04"), name)
+			}
+			if err != nil {
+				done(false)
+				r.Exit()
+			}
+		}
+	}
+	done(enc == nil || enc.Close() == nil)
+	r.Exit()
+}
+
+// wantNDJSON 客户端是否要求NDJSON
+func wantNDJSON(r *ghttp.Request) bool {
+	return r.GetQueryString("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), jsonstream.ContentType)
+}
+
+// humanSize 便于阅读的文件大小
+func humanSize(n int64, dir bool) string {
+	if dir {
+		return "-"
+	}
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	f, units := float64(n), "KMGTP"
+	for i := 0; i < len(units); i++ {
+		f /= 1024
+		if f < 1024 || i == len(units)-1 {
+			return fmt.Sprintf("%.1f%c", f, units[i])
+		}
+	}
+	return ""
+}
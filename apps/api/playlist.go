@@ -0,0 +1,72 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/disposition"
+	"b0pass/library/fileinfos"
+	"b0pass/library/playlist"
+	"b0pass/library/response"
+	"bytes"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// Playlist 目录中音频文件的播放列表(标题、艺术家、时长取自ID3标签)，format=m3u时输出M3U供播放器打开
+// /api/playlist?path=/files/音乐&format=m3u
+func Playlist(r *ghttp.Request) {
+	name := path.Clean("/" + r.GetQueryString("path"))
+	dir := fileinfos.FilePath(strings.TrimPrefix(name, "/files"))
+	st, err := os.Stat(dir)
+	if dir == "" || err != nil || !st.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "目录不存在")
+	}
+	format := r.GetQueryString("format", "json")
+	if format != "json" && format != "m3u" {
+		response.Error(r, http.StatusBadRequest, 201, "format只能是json或m3u")
+	}
+	tracks, err := playlist.Read(dir, audioDuration)
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	for i := range tracks {
+		u := url.URL{Path: path.Join(name, tracks[i].Name)}
+		tracks[i].URL = u.EscapedPath()
+	}
+	if format == "json" {
+		if tracks == nil {
+			tracks = []playlist.Track{}
+		}
+		response.JSON(r, 0, "ok", g.Map{"path": name, "count": len(tracks), "tracks": tracks})
+	}
+	// 外部播放器打开时需要完整地址
+	base := baseURL(r)
+	for i := range tracks {
+		tracks[i].URL = base + tracks[i].URL
+	}
+	var buf bytes.Buffer
+	_ = playlist.WriteM3U(&buf, tracks)
+	title := path.Base(name)
+	if title == "/" {
+		title = "files"
+	}
+	r.Response.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+	r.Response.Header().Set("Content-Disposition", disposition.Header(disposition.Inline, title+".m3u8", r.UserAgent()))
+	r.Response.Write(buf.Bytes())
+}
+
+// audioDuration 未启用转码(没有ffprobe)时为0
+func audioDuration(file string) float64 {
+	if boot.Prober == nil {
+		return 0
+	}
+	info, err := boot.Prober.Probe(file)
+	if err != nil {
+		return 0
+	}
+	return info.Duration
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/prefs"
+	"b0pass/library/response"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"strings"
+)
+
+// Session 界面启动时调用，返回设备标识、设备名称(未设置时为空)及该设备保存的偏好；浏览器首次访问时设置设备标识Cookie
+// /api/session
+func Session(r *ghttp.Request) {
+	id := deviceIdentity(r)
+	var name string
+	if strings.HasPrefix(id, "device:") {
+		name = currentDevice(r).Name
+	} else if c, ok := boot.Clients.Get(id); ok {
+		name = c.Name
+	}
+	response.JSON(r, 0, "ok", g.Map{
+		"device": id,
+		"name":   name,
+		"prefs":  boot.Prefs.Get(id),
+		"locale": boot.Locale(r.Request),
+	})
+}
+
+// PrefsGet 当前设备的偏好
+// /api/prefs
+func PrefsGet(r *ghttp.Request) {
+	response.JSON(r, 0, "ok", boot.Prefs.Get(deviceIdentity(r)))
+}
+
+// PrefsSet 修改当前设备的偏好，只修改请求中出现的项，值为空时恢复默认
+// PUT /api/prefs {"locale":"en","theme":"dark","view":"grid","download":"zip"}
+func PrefsSet(r *ghttp.Request) {
+	var changes map[string]string
+	if err := json.Unmarshal(r.GetRaw(), &changes); err != nil || len(changes) == 0 {
+		response.Error(r, http.StatusBadRequest, 201, "请求体须为偏好项的JSON对象: "+strings.Join(prefs.Keys(), "、"))
+	}
+	p, err := boot.Prefs.Update(deviceIdentity(r), changes)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok", p)
+}
+
+// deviceIdentity 请求方的设备标识，浏览器还没有标识时生成并设置Cookie(一年)
+func deviceIdentity(r *ghttp.Request) string {
+	if id := boot.DeviceIdentity(r.Request); id != "" {
+		return id
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	id := hex.EncodeToString(b)
+	http.SetCookie(r.Response.Writer, &http.Cookie{Name: boot.DeviceCookie, Value: id, Path: "/", MaxAge: 365 * 24 * 3600,
+		HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	// 本次请求中的语言等也按新标识读取
+	r.AddCookie(&http.Cookie{Name: boot.DeviceCookie, Value: id})
+	return "browser:" + id
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/disposition"
+	"b0pass/library/fileinfos"
+	"b0pass/library/httpcache"
+	"b0pass/library/logger"
+	"b0pass/library/preview"
+	"b0pass/library/response"
+	"b0pass/library/thumbs"
+	"github.com/gogf/gf/encoding/gcharset"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// Preview 文件在线预览: 文本与日志返回开头若干行(自动识别编码转为UTF-8)，Markdown渲染为HTML，
+// PDF原样输出供浏览器内置阅读器显示，图片按EXIF方向旋转并缩放到preview.image以内
+// /api/preview?path=/files/logs/a.log&lines=500
+func Preview(r *ghttp.Request) {
+	name := path.Clean("/" + r.GetQueryString("path"))
+	file := fileinfos.FilePath(strings.TrimPrefix(name, "/files"))
+	st, err := os.Stat(file)
+	if file == "" || err != nil || st.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	if thumbs.Supported(file) {
+		previewImage(r, file, st)
+		return
+	}
+	head, err := readHead(file, 4096)
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	c := g.Config()
+	kind := preview.Detect(file, head)
+	switch kind {
+	case preview.KindPDF:
+		// 内联显示，并禁止PDF中的脚本访问本站
+		h := r.Response.Header()
+		h.Set("Content-Type", "application/pdf")
+		h.Set("Content-Disposition", disposition.Inline)
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Content-Security-Policy", "sandbox")
+		r.Response.ServeFile(file)
+		return
+	case "":
+		response.Error(r, http.StatusUnsupportedMediaType, 201, "不支持预览该类型的文件")
+	}
+	lines := r.GetQueryInt("lines", c.GetInt("preview.lines", 200))
+	if lines <= 0 || lines > c.GetInt("preview.maxlines", 5000) {
+		response.Error(r, http.StatusBadRequest, 201, "lines须在1到preview.maxlines之间")
+	}
+	charset := r.GetQueryString("charset")
+	if charset != "" && !gcharset.Supported(charset) {
+		response.Error(r, http.StatusBadRequest, 201, "不支持的编码: "+charset)
+	}
+	text, err := preview.ReadText(file, lines, c.GetInt64("preview.bytes", 256)*1024, charset)
+	if err != nil {
+		response.Error(r, http.StatusUnprocessableEntity, 201, "无法读取文本: "+err.Error())
+	}
+	if kind == preview.KindMarkdown {
+		response.JSON(r, 0, "ok", g.Map{
+			"type":      kind,
+			"charset":   text.Charset,
+			"html":      preview.Markdown(text.Content, path.Dir(name)),
+			"truncated": text.Truncated,
+		})
+	}
+	response.JSON(r, 0, "ok", g.Map{
+		"type":      kind,
+		"charset":   text.Charset,
+		"content":   text.Content,
+		"lines":     text.Lines,
+		"truncated": text.Truncated,
+	})
+}
+
+// previewImage 输出按EXIF方向旋转并缩放后的图片，与缩略图共用缓存
+func previewImage(r *ghttp.Request, file string, src os.FileInfo) {
+	size := g.Config().GetInt("preview.image", 1600)
+	if s := r.GetQueryInt("size"); s > 0 && s < size {
+		size = s
+	}
+	img, err := boot.Thumbs(file).Get(file, size, size)
+	switch err {
+	case nil:
+	case thumbs.ErrTooLarge:
+		response.Error(r, http.StatusRequestEntityTooLarge, 201, "图片像素数超过thumb.pixels")
+	default:
+		logger.Warn("preview", "image", "path", fileinfos.FileKey(file), "err", err)
+		response.Error(r, http.StatusUnprocessableEntity, 201, "无法解码图片: "+err.Error())
+	}
+	r.Response.Header().Set("Cache-Control", "private, max-age=86400")
+	httpcache.Set(r.Response.Header(), src)
+	if httpcache.NotModified(r.Request, src) {
+		r.Response.WriteHeader(http.StatusNotModified)
+		return
+	}
+	r.Response.ServeFile(img)
+}
+
+// readHead 读取文件开头最多n字节
+func readHead(file string, n int) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	head := make([]byte, n)
+	n, err = io.ReadFull(f, head)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return head[:n], err
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/formstream"
+	"b0pass/library/hashes"
+	"b0pass/library/notify"
+	"b0pass/library/progress"
+	"b0pass/library/response"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/gogf/gf/os/gfile"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventProgress 上传请求中各文件的进度(排队、接收中、已保存、失败)，只推送给上传的设备
+const EventProgress = "progress"
+
+// progressKeep 请求结束后仍可查询进度的时间
+const progressKeep = 10 * time.Minute
+
+var (
+	progressMu sync.Mutex
+	progresses = make(map[string]*progress.Tracker)
+)
+
+// progressEvent 推送的进度，id为上传时progress字段的值
+type progressEvent struct {
+	ID string `json:"id"`
+	progress.Item
+}
+
+// declaredFile 上传时files字段中声明的文件
+type declaredFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Hash string `json:"hash"` // 可选，按algo字段的算法
+}
+
+// uploadBatch 一个上传请求的逐文件进度。客户端在文件之前的progress字段指定id(与推送的事件对应)，
+// 在files字段中声明全部文件时，每个文件接收完立即保存，一个文件失败不影响其它文件
+type uploadBatch struct {
+	r        *ghttp.Request
+	client   string
+	tracker  *progress.Tracker
+	declared []declaredFile
+	current  int
+	began    time.Time // 当前文件开始接收的时间
+	bound    bool
+}
+
+func newUploadBatch(r *ghttp.Request) *uploadBatch {
+	return &uploadBatch{r: r, client: clientID(r), current: -1}
+}
+
+// start 第一个文件部分开始时按已解析的字段创建进度，分片上传(id字段)不跟踪
+func (b *uploadBatch) start(values url.Values, filename string) {
+	if values.Get("id") != "" {
+		return
+	}
+	if b.tracker == nil {
+		id := values.Get("progress")
+		if id == "" {
+			buf := make([]byte, 8)
+			_, _ = rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		interval := time.Duration(g.Config().GetInt("upload.progress", 500)) * time.Millisecond
+		client := b.client
+		b.tracker = progress.New(id, interval, func(it progress.Item) {
+			notify.SendTo(client, notify.Event{Type: EventProgress, Level: "info", Title: it.Name, Data: progressEvent{ID: id, Item: it}})
+		})
+		progressMu.Lock()
+		progresses[client+"/"+id] = b.tracker
+		progressMu.Unlock()
+		if files := values.Get("files"); files != "" {
+			_ = json.Unmarshal([]byte(files), &b.declared)
+			items := make([]progress.Item, len(b.declared))
+			for i, f := range b.declared {
+				items[i] = progress.Item{Name: f.Name, Size: f.Size}
+			}
+			b.tracker.Declare(items)
+		}
+	}
+	b.current, b.began = b.tracker.Start(gfile.Basename(filename)), time.Now()
+}
+
+// written 当前文件已接收的字节数
+func (b *uploadBatch) written(file *formstream.File, n int64) {
+	if b.tracker != nil {
+		b.tracker.Progress(b.current, n)
+	}
+}
+
+// saved 当前文件已接收完，声明了files时立即保存
+func (b *uploadBatch) saved(values url.Values, file *formstream.File) {
+	if !b.streaming() || file.Field != "upload-file" {
+		return
+	}
+	savePath, err := b.commit(values, file)
+	if err == fileinfos.ErrSkipped {
+		b.tracker.Skip(b.current, fileinfos.FileURL(savePath))
+		return
+	}
+	b.tracker.Finish(b.current, fileinfos.FileURL(savePath), err)
+}
+
+// size 当前文件在files字段中声明的大小，未声明时为0
+func (b *uploadBatch) size() int64 {
+	if b.tracker == nil || b.current < 0 || b.current >= len(b.declared) {
+		return 0
+	}
+	return b.declared[b.current].Size
+}
+
+// streaming 是否为逐个保存的多文件上传
+func (b *uploadBatch) streaming() bool {
+	return b.tracker != nil && len(b.declared) > 0
+}
+
+// commit 校验并保存一个文件，错误只影响该文件
+func (b *uploadBatch) commit(values url.Values, file *formstream.File) (string, error) {
+	r := b.r
+	if !b.bound {
+		// 后续文件仍在请求体中，读取参数时不能再解析请求体；声明文件时其它字段须位于文件之前
+		r.PostForm = values
+		r.MultipartForm = &multipart.Form{Value: values}
+		r.Body = http.NoBody
+		b.bound = true
+	}
+	savePath := fileinfos.FilePath(values.Get("path") + "/" + cleanName(gfile.Basename(file.Filename)))
+	if savePath == "" || fileinfos.Roots().IsRoot(savePath) {
+		return "", errors.New(errNoRoot())
+	}
+	if _, err := protectUploadKey(r, savePath); err != nil {
+		return "", err
+	}
+	algo, ok := allowedAlgo(values.Get("algo"))
+	if !ok {
+		return "", errors.New("unsupported hash algorithm: " + algo)
+	}
+	policy, err := uploadDurability(r)
+	if err != nil {
+		return "", err
+	}
+	sums := file.Sums
+	if sums[algo] == "" {
+		if sums, err = hashes.FileSums(file.Path, hashes.Default, algo); err != nil {
+			return "", err
+		}
+	}
+	if b.current < len(b.declared) {
+		if hash := strings.ToLower(b.declared[b.current].Hash); hash != "" && hash != sums[algo] {
+			return "", errors.New("hash mismatch, upload discarded")
+		}
+	}
+	if savePath, err = resolveUpload(r, savePath); err != nil {
+		return savePath, err
+	}
+	markUpload(savePath, b.began)
+	if err := commitUpload(policy, file.Path, savePath); err != nil {
+		return "", err
+	}
+	if err := verifyFile(savePath, sums); err != nil {
+		return "", err
+	}
+	finishUpload(r, savePath, file.Size, sums)
+	return savePath, nil
+}
+
+// skip 非逐个保存时，同名文件已存在而跳过
+func (b *uploadBatch) skip(savePath string) {
+	if b.tracker != nil && b.current >= 0 {
+		b.tracker.Skip(b.current, fileinfos.FileURL(savePath))
+	}
+}
+
+// finish 非逐个保存时，文件保存后记录完成
+func (b *uploadBatch) finish(savePath string) {
+	if b.tracker != nil && b.current >= 0 {
+		b.tracker.Finish(b.current, fileinfos.FileURL(savePath), nil)
+	}
+}
+
+// close 请求结束(含出错退出)，未完成的文件记录为失败，进度保留progressKeep后删除
+func (b *uploadBatch) close() {
+	if b.tracker == nil {
+		return
+	}
+	b.tracker.Close()
+	key := b.client + "/" + b.tracker.ID
+	time.AfterFunc(progressKeep, func() {
+		progressMu.Lock()
+		delete(progresses, key)
+		progressMu.Unlock()
+	})
+}
+
+// respond 逐个保存的多文件上传的结果，有文件失败时返回207及各文件的状态
+func (b *uploadBatch) respond() {
+	b.tracker.Close()
+	result := g.Map{"id": b.tracker.ID, "files": b.tracker.Items()}
+	if n := b.tracker.Failed(); n > 0 {
+		response.Error(b.r, http.StatusMultiStatus, 201, "部分文件上传失败", result)
+	}
+	response.JSON(b.r, 0, "ok", result)
+}
+
+// UploadProgress 当前设备的上传请求中各文件的进度，请求结束后保留10分钟
+// /api/upload/progress?id=
+func UploadProgress(r *ghttp.Request) {
+	progressMu.Lock()
+	t, ok := progresses[clientID(r)+"/"+r.GetString("id")]
+	progressMu.Unlock()
+	if !ok {
+		response.Error(r, http.StatusNotFound, 201, "没有该上传")
+	}
+	response.JSON(r, 0, "ok", g.Map{"id": t.ID, "closed": !t.Closed().IsZero(), "files": t.Items()})
+}
+
+// UploadSlots 上传名额: 上限(0为不限制)、进行中的上传数、排队的设备数及当前设备的排队位置(不在队列中时为0)
+// /api/upload/slots
+func UploadSlots(r *ghttp.Request) {
+	max, active, queued := boot.UploadSlots.Stats()
+	response.JSON(r, 0, "ok", g.Map{"max": max, "active": active, "queued": queued, "position": boot.UploadSlots.Position(ClientKey(r))})
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/notify"
+	"b0pass/library/protect"
+	"b0pass/library/response"
+	"encoding/json"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EventProtect 受保护目录加密或解密完成
+const EventProtect = "protect"
+
+// 受保护目录的后台任务
+const (
+	protectEncrypting = "encrypting"
+	protectDecrypting = "decrypting"
+)
+
+var (
+	protectMu   sync.Mutex
+	protectBusy = make(map[string]string)
+)
+
+type protectRequest struct {
+	Path       string `json:"path"`
+	Passphrase string `json:"passphrase"`
+}
+
+// protectParse 请求中的目录(如 /files/docs/secret)，返回目录的绝对路径与共享目录下的相对路径
+func protectParse(r *ghttp.Request) (protectRequest, string, string) {
+	var req protectRequest
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, `请求体须为JSON: {"path":"/files/docs/secret","passphrase":"..."}`)
+	}
+	dir := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+req.Path), "/files"))
+	if dir == "" || fileinfos.Roots().IsRoot(dir) {
+		response.Error(r, http.StatusBadRequest, 201, "请指定共享目录下的子目录")
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "目录不存在")
+	}
+	return req, dir, fileinfos.FileKey(dir)
+}
+
+// protectFolder 请求中的受保护目录，未保护时返回404
+func protectFolder(r *ghttp.Request) (protectRequest, string, protect.Folder) {
+	req, dir, rel := protectParse(r)
+	f, ok := boot.Protected.Get(rel)
+	if !ok {
+		response.Error(r, http.StatusNotFound, 201, "目录未加密保护")
+	}
+	return req, dir, f
+}
+
+// protectKey 文件所在受保护目录的密钥: 设备已解锁，或请求头X-Passphrase中带有口令(只用于本次请求)
+func protectKey(r *ghttp.Request, f protect.Folder) ([]byte, bool) {
+	if key, ok := boot.Keyring.Key(f.Path, clientID(r)); ok {
+		return key, true
+	}
+	if pass := r.Header.Get("X-Passphrase"); pass != "" {
+		if key, err := f.Key(pass); err == nil {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// ProtectList 受保护目录，unlocked为当前设备是否已解锁，busy为正在进行的加密或解密
+// /api/protect
+func ProtectList(r *ghttp.Request) {
+	client := clientID(r)
+	list := make([]g.Map, 0)
+	for _, f := range boot.Protected.List() {
+		protectMu.Lock()
+		busy := protectBusy[f.Path]
+		protectMu.Unlock()
+		list = append(list, g.Map{"path": f.Path, "url": boot.BasePath + path.Clean("/files/"+f.Path), "created": f.Created,
+			"unlocked": boot.Keyring.Unlocked(f.Path, client), "busy": busy})
+	}
+	response.JSON(r, 0, "ok", list)
+}
+
+// ProtectCreate 用口令保护目录，目录中已有的文件在后台加密，完成后推送给当前设备；当前设备自动解锁
+// POST /api/protect {"path":"/files/docs/secret","passphrase":"..."}
+func ProtectCreate(r *ghttp.Request) {
+	req, dir, rel := protectParse(r)
+	f, key, err := protect.NewFolder(rel, req.Passphrase)
+	if err == protect.ErrWeak {
+		response.Error(r, http.StatusBadRequest, 201, "口令太短，最少字符数: "+strconv.Itoa(protect.MinPassphrase))
+	} else if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	if err := boot.Protected.Add(f); err == protect.ErrExist || err == protect.ErrNested {
+		response.Error(r, http.StatusConflict, 201, "该目录或其上级、下级目录已加密保护")
+	} else if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	boot.Keyring.Unlock(f.Path, clientID(r), key)
+	logger.Info("protect", "created", "path", f.Path)
+	if protectStart(f.Path, protectEncrypting) {
+		go protectWalk(clientID(r), dir, f.Path, key, protectEncrypting)
+	}
+	response.JSON(r, 0, "ok", g.Map{"path": f.Path, "busy": protectEncrypting})
+}
+
+// ProtectUnlock 为当前设备解锁目录；顺带在后台加密直接放入目录(如在主电脑上复制)的未加密文件
+// POST /api/protect/unlock {"path":"/files/docs/secret","passphrase":"..."}
+func ProtectUnlock(r *ghttp.Request) {
+	req, dir, f := protectFolder(r)
+	key, err := f.Key(req.Passphrase)
+	if err != nil {
+		logger.Warn("protect", "wrong passphrase", "path", f.Path, "client", r.GetClientIp())
+		response.Error(r, http.StatusForbidden, 201, "口令错误")
+	}
+	boot.Keyring.Unlock(f.Path, clientID(r), key)
+	if protectStart(f.Path, protectEncrypting) {
+		go protectWalk(clientID(r), dir, f.Path, key, protectEncrypting)
+	}
+	response.JSON(r, 0, "ok", nil)
+}
+
+// ProtectLock 为当前设备锁定目录
+// POST /api/protect/lock {"path":"/files/docs/secret"}
+func ProtectLock(r *ghttp.Request) {
+	_, _, f := protectFolder(r)
+	boot.Keyring.Lock(f.Path, clientID(r))
+	response.JSON(r, 0, "ok", nil)
+}
+
+// ProtectRemove 取消保护，在后台解密目录中的文件，全部设备随之锁定
+// /api/protect/remove {"path":"/files/docs/secret","passphrase":"..."}
+func ProtectRemove(r *ghttp.Request) {
+	req, dir, f := protectFolder(r)
+	key, err := f.Key(req.Passphrase)
+	if err != nil {
+		response.Error(r, http.StatusForbidden, 201, "口令错误")
+	}
+	if !protectStart(f.Path, protectDecrypting) {
+		response.Error(r, http.StatusConflict, 201, "该目录正在加密，请稍后再试")
+	}
+	go protectWalk(clientID(r), dir, f.Path, key, protectDecrypting)
+	response.JSON(r, 0, "ok", g.Map{"path": f.Path, "busy": protectDecrypting})
+}
+
+// protectStart 标记目录开始后台任务，已有任务进行中时返回false
+func protectStart(rel, op string) bool {
+	protectMu.Lock()
+	defer protectMu.Unlock()
+	if protectBusy[rel] != "" {
+		return false
+	}
+	protectBusy[rel] = op
+	return true
+}
+
+// protectWalk 在后台加密或解密目录中的全部文件，完成后推送给发起的设备；
+// 解密完成后取消保护并锁定，再解密一次期间写入的文件
+func protectWalk(client, dir, rel string, key []byte, op string) {
+	defer func() {
+		protectMu.Lock()
+		delete(protectBusy, rel)
+		protectMu.Unlock()
+	}()
+	convert := protect.EncryptFile
+	if op == protectDecrypting {
+		convert = protect.DecryptFile
+	}
+	n, err := protect.Walk(dir, func(file string) error { return convert(file, key) })
+	if err == nil && op == protectDecrypting {
+		if err = boot.Protected.Remove(rel); err == nil {
+			boot.Keyring.Lock(rel, "")
+			_, err = protect.Walk(dir, func(file string) error { return protect.DecryptFile(file, key) })
+		}
+	}
+	level, title := "info", fmt.Sprintf("已加密%s中的%d个文件", rel, n)
+	if op == protectDecrypting {
+		title = fmt.Sprintf("已取消%s的加密保护(%d个文件)", rel, n)
+	}
+	if err != nil {
+		level, title = "error", "受保护目录"+rel+"处理失败: "+err.Error()
+		logger.Error("protect", op, "path", rel, "err", err)
+	} else {
+		logger.Info("protect", op, "path", rel, "files", n)
+	}
+	notify.SendTo(client, notify.Event{Type: EventProtect, Level: level, Title: title, Data: map[string]string{"path": rel, "op": op}})
+}
+
+// errLocked 受保护目录未解锁
+type errLocked string
+
+func (e errLocked) Error() string { return "目录已加密保护，请先解锁: " + string(e) }
+
+// protectUploadKey 上传到受保护目录时的密钥，目录未保护时返回nil，设备未解锁时返回errLocked
+func protectUploadKey(r *ghttp.Request, savePath string) ([]byte, error) {
+	f, ok := boot.Protected.Find(fileinfos.FileKey(savePath))
+	if !ok {
+		return nil, nil
+	}
+	key, ok := protectKey(r, f)
+	if !ok {
+		return nil, errLocked(f.Path)
+	}
+	return key, nil
+}
+
+// protectUpload 上传到受保护目录的文件立即加密，设备未解锁时删除文件并返回423
+func protectUpload(r *ghttp.Request, savePath string) {
+	key, err := protectUploadKey(r, savePath)
+	if err != nil {
+		_ = os.Remove(savePath)
+		response.Error(r, http.StatusLocked, 201, err.Error())
+	}
+	if key == nil {
+		return
+	}
+	if err := protect.EncryptFile(savePath, key); err != nil {
+		_ = os.Remove(savePath)
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+}
+
+// isLocked 错误是否为受保护目录未解锁
+func isLocked(err error) bool {
+	_, ok := err.(errLocked)
+	return ok
+}
+
+// protocolWritable WebDAV、FTP、S3、gRPC能否写入文件: 这些协议没有解锁受保护目录的途径，写入的文件无法加密，
+// 因此不能写入受保护目录
+func protocolWritable(file string) bool {
+	_, ok := boot.Protected.Find(fileinfos.FileKey(file))
+	return !ok
+}
+
+// serveProtected 解密传输受保护目录中的加密文件(支持Range)，文件不在受保护目录或未加密时返回false；
+// 设备未解锁时返回423
+func serveProtected(r *ghttp.Request, file string, info os.FileInfo) bool {
+	f, ok := boot.Protected.Find(fileinfos.FileKey(file))
+	if !ok || !protect.IsEncrypted(file) {
+		return false
+	}
+	key, ok := protectKey(r, f)
+	if !ok {
+		response.Error(r, http.StatusLocked, 201, errLocked(f.Path).Error())
+	}
+	fd, content, err := protect.OpenFile(file, key)
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	defer func() { _ = fd.Close() }()
+	if serveEncrypted(r, path.Base(file), info.ModTime(), content, content.Size()) {
+		auditLog(r, audit.OpDownload, file, content.Size())
+		recordTransfer(r, audit.OpDownload, file, content.Size(), requestStart(r))
+		runScript(ClientKey(r), EventDownload, file, content.Size(), "")
+	}
+	return true
+}
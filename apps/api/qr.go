@@ -0,0 +1,94 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/ipaddress"
+	"b0pass/library/proxy"
+	"b0pass/library/qrcode"
+	"b0pass/library/response"
+	"errors"
+	"github.com/gogf/gf/net/ghttp"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// QR 服务端生成二维码，网页、终端(curl)与打印均可使用
+// /api/qr?data=&size=200&level=M&format=png，data为空时编码本服务的地址:
+// scheme(http或https，缺省与本次请求一致)、host(网卡地址，可带端口，缺省为首个内网地址)与path
+// format为png、svg、txt(Unicode半块字符，用于终端)或ansi(ANSI背景色)
+func QR(r *ghttp.Request) {
+	level, err := qrcode.ParseLevel(r.GetQueryString("level"))
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	data := r.GetQueryString("data")
+	if data == "" {
+		if data, err = qrAddress(r); err != nil {
+			response.Error(r, http.StatusBadRequest, 201, err.Error())
+		}
+	}
+	code, err := qrcode.Encode(data, level)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	size := r.GetQueryInt("size", 200)
+	if size < 21 || size > 2000 {
+		response.Error(r, http.StatusBadRequest, 201, "size只能是21-2000")
+	}
+	h := r.Response.Header()
+	h.Set("Cache-Control", "no-cache")
+	switch r.GetQueryString("format") {
+	case "", "png":
+		scale := size / (code.Size + 2*qrcode.Quiet)
+		if scale < 1 {
+			scale = 1
+		}
+		h.Set("Content-Type", "image/png")
+		_ = code.PNG(r.Response.Writer, scale)
+	case "svg":
+		h.Set("Content-Type", "image/svg+xml")
+		r.Response.Write(code.SVG(size))
+	case "txt":
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		r.Response.Write(code.Terminal())
+	case "ansi":
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		r.Response.Write(code.ANSI())
+	default:
+		response.Error(r, http.StatusBadRequest, 201, "format只能是png、svg、txt或ansi")
+	}
+}
+
+// qrAddress 由scheme、host与path参数组成的本服务地址
+func qrAddress(r *ghttp.Request) (string, error) {
+	scheme := r.GetQueryString("scheme")
+	switch scheme {
+	case "":
+		scheme = proxy.Scheme(r.Request)
+	case "http", "https":
+	default:
+		return "", errors.New("scheme只能是http或https")
+	}
+	host := r.GetQueryString("host")
+	if host == "" {
+		host = "127.0.0.1"
+		if ips, _ := ipaddress.GetIP(); len(ips) > 0 {
+			host = ips[0]
+		}
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(boot.ServPort))
+	}
+	p := r.GetQueryString("path", boot.BasePath+"/")
+	if !strings.HasPrefix(p, "/") {
+		return "", errors.New("path须以/开头")
+	}
+	u, err := url.Parse(scheme + "://" + host + p)
+	if err != nil || u.User != nil || u.Host != host {
+		return "", errors.New("host无效")
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/quota"
+	"b0pass/library/response"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+)
+
+// quotaError 上传者写入size字节前检查配额与磁盘空间，超出时返回原因，size未知(<=0)时不检查
+func quotaError(r *ghttp.Request, size int64) string {
+	if size <= 0 {
+		return ""
+	}
+	return quotaMessage(boot.Quota(ClientKey(r), uploadTmpDir()).Check(size))
+}
+
+// quotaMessage 超出限制的原因
+func quotaMessage(err error) string {
+	switch err {
+	case quota.ErrDevice:
+		return "已超出本设备的上传配额"
+	case quota.ErrTotal:
+		return "已超出上传总配额"
+	case quota.ErrDisk:
+		return "磁盘空间不足"
+	}
+	return ""
+}
+
+// checkQuota 同quotaError，超出时返回507
+func checkQuota(r *ghttp.Request, size int64) {
+	if msg := quotaError(r, size); msg != "" {
+		response.Error(r, http.StatusInsufficientStorage, 507, msg)
+	}
+}
+
+// Quota 当前设备的上传配额与剩余空间，供界面在上传前提示
+// /api/quota?size=1048576 指定size时ok表示能否上传该大小
+func Quota(r *ghttp.Request) {
+	report := boot.Quota(ClientKey(r), uploadTmpDir())
+	if size := r.GetQueryInt64("size"); size > 0 {
+		msg := quotaMessage(report.Check(size))
+		response.JSON(r, 0, "ok", struct {
+			quota.Report
+			OK     bool   `json:"ok"`
+			Reason string `json:"reason,omitempty"`
+		}{report, msg == "", msg})
+	}
+	response.JSON(r, 0, "ok", report)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/receipt"
+	"b0pass/library/response"
+	"encoding/json"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// issueReceipt 上传完成后签发回执并记录到元数据，请求携带email时发送到该邮箱
+func issueReceipt(r *ghttp.Request, savePath string, size int64, sums map[string]string) {
+	if boot.Receipts == nil {
+		return
+	}
+	rc, err := boot.Receipts.Sign(receipt.Receipt{
+		File:   "/files/" + fileinfos.FileKey(savePath),
+		Size:   size,
+		Hashes: sums,
+		Time:   time.Now(),
+		Client: ClientKey(r),
+	})
+	if err != nil {
+		logger.Error("receipt", "sign", "path", savePath, "err", err)
+		return
+	}
+	b, _ := json.Marshal(rc)
+	metadata.Set(fileinfos.FileKey(savePath), map[string]string{"receipt": string(b)})
+	if to := r.GetString("email"); to != "" {
+		go func() {
+			if err := boot.ReceiptMail().Send(to, rc); err != nil {
+				logger.Error("receipt", "mail", "to", to, "err", err)
+			}
+		}()
+	}
+}
+
+// storedReceipt 文件最近一次上传的回执
+func storedReceipt(key string) (receipt.Receipt, bool) {
+	var rc receipt.Receipt
+	s := metadata.Get(key, "receipt")
+	if s == "" || json.Unmarshal([]byte(s), &rc) != nil {
+		return rc, false
+	}
+	return rc, true
+}
+
+// uploadResult 上传接口的返回数据: 大小与最终的文件名(按同名处理方式可能已重命名)，请求携带receipt=1时同时返回回执
+func uploadResult(r *ghttp.Request, savePath string, size int64) interface{} {
+	ret := g.Map{"size": size, "name": filepath.Base(savePath), "url": fileinfos.FileURL(savePath)}
+	if r.GetBool("receipt") {
+		ret["receipt"] = requestedReceipt(r, savePath)
+	}
+	return ret
+}
+
+// requestedReceipt 请求携带receipt=1时返回文件的回执，否则为nil
+func requestedReceipt(r *ghttp.Request, savePath string) interface{} {
+	if !r.GetBool("receipt") {
+		return nil
+	}
+	if rc, ok := storedReceipt(fileinfos.FileKey(savePath)); ok {
+		return rc
+	}
+	return nil
+}
+
+// Receipt 查询文件最近一次上传的回执 /api/receipt?f=/files/a.txt
+func Receipt(r *ghttp.Request) {
+	file := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+r.GetString("f")), "/files"))
+	rc, ok := storedReceipt(fileinfos.FileKey(file))
+	if file == "" || !ok {
+		response.Error(r, http.StatusNotFound, 201, "没有该文件的上传回执")
+	}
+	response.JSON(r, 0, "ok", rc)
+}
+
+// ReceiptVerify 验证回执签名，并检查服务端当前的文件是否仍与回执一致
+func ReceiptVerify(r *ghttp.Request) {
+	if boot.Receipts == nil {
+		response.Error(r, http.StatusServiceUnavailable, 201, "回执签名密钥不可用")
+	}
+	var rc receipt.Receipt
+	if err := json.Unmarshal(r.GetRaw(), &rc); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	if err := boot.Receipts.Verify(rc); err != nil {
+		response.Error(r, http.StatusUnprocessableEntity, 202, err.Error())
+	}
+	// 文件仍在且大小与记录的哈希一致时为true
+	file := fileinfos.FilePath(strings.TrimPrefix(rc.File, "/files"))
+	hash := rc.Hashes[hashes.Default]
+	current := file != "" && hash != "" && sameContent(file, rc.Size, hashes.Default, hash)
+	response.JSON(r, 0, "ok", g.Map{"valid": true, "current": current, "server": rc.Server})
+}
+
+// ReceiptKey 回执签名公钥(PEM)，用于离线验证
+func ReceiptKey(r *ghttp.Request) {
+	if boot.Receipts == nil {
+		response.Error(r, http.StatusServiceUnavailable, 201, "回执签名密钥不可用")
+	}
+	r.Response.Header().Set("Content-Type", "application/x-pem-file")
+	r.Response.Write(boot.Receipts.PublicKey())
+	r.Exit()
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/device"
+	"b0pass/library/disposition"
+	"b0pass/library/logger"
+	"b0pass/library/notify"
+	"b0pass/library/relay"
+	"b0pass/library/response"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/gogf/gf/os/gfile"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// EventRelay 其他设备开始向本设备直传
+const EventRelay = "relay"
+
+// relayView 直传信息及收发地址
+func relayView(rl relay.Relay) g.Map {
+	return g.Map{"id": rl.ID, "name": rl.Name, "size": rl.Size, "spool": rl.Spool, "created": rl.Created, "url": boot.BasePath + "/api/relay/" + rl.ID}
+}
+
+// RelayCreate 创建设备直传: 发送方随后PUT /api/relay/<id>上传，接收方GET /api/relay/<id>同时下载，数据经服务端转发不写入磁盘
+// POST /api/relay {"to":"设备key","name":"a.zip","size":123,"spool":false}
+// to为空时任何拿到链接的设备都可以接收；spool为true时先写入暂存文件，接收方可稍后下载
+func RelayCreate(r *ghttp.Request) {
+	var req struct {
+		To    string `json:"to"`
+		Name  string `json:"name"`
+		Size  *int64 `json:"size"`
+		Spool bool   `json:"spool"`
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil || gfile.Basename(req.Name) == "" || gfile.Basename(req.Name) == "." {
+		response.Error(r, http.StatusBadRequest, 201, `请求体须为JSON: {"to":"设备key","name":"a.zip","size":123}`)
+	}
+	rl := relay.Relay{Name: gfile.Basename(req.Name), Size: -1, From: clientID(r), Spool: req.Spool}
+	if req.Size != nil && *req.Size >= 0 {
+		rl.Size = *req.Size
+	}
+	if rl.Spool {
+		if !g.Config().GetBool("relay.spool", true) {
+			response.Error(r, http.StatusForbidden, 201, "未允许暂存直传")
+		}
+		if boot.Storage.Blocked() {
+			response.Error(r, http.StatusInsufficientStorage, 507, "磁盘空间不足，已停止接收上传")
+		}
+	}
+	var target device.Client
+	if req.To != "" {
+		var ok bool
+		if target, ok = boot.Clients.Find(req.To); !ok {
+			response.Error(r, http.StatusNotFound, 201, "设备不存在")
+		}
+		if !rl.Spool && !notify.Online(target.ID) {
+			response.Error(r, http.StatusNotFound, 201, "设备不在线，可使用spool模式待对方上线后下载")
+		}
+		rl.To = target.ID
+	}
+	rl = boot.Relays.Create(rl)
+	view := relayView(rl)
+	if rl.To != "" {
+		from := boot.ClientName(r.Request)
+		data := g.Map{"from": from, "from_key": device.Key(clientID(r))}
+		for k, v := range view {
+			data[k] = v
+		}
+		notify.SendTo(rl.To, notify.Event{Type: EventRelay, Level: "info", Title: from + " 正在发送: " + rl.Name, Data: data})
+		view["to"] = target.Label()
+	}
+	response.JSON(r, 0, "ok", view)
+}
+
+// RelayList 发给当前设备、还没有开始接收的直传
+// /api/relay
+func RelayList(r *ghttp.Request) {
+	list := boot.Relays.Pending(boot.DeviceIdentity(r.Request))
+	ret := make([]g.Map, 0, len(list))
+	for _, rl := range list {
+		ret = append(ret, relayView(rl))
+	}
+	response.JSON(r, 0, "ok", ret)
+}
+
+// RelayStream 直传的收发: PUT/POST 发送方上传(请求体为原始数据)，GET 接收方下载
+// 直传模式下先到的一方等待另一方，最多等待relay.ttl分钟
+func RelayStream(r *ghttp.Request) {
+	id := r.GetRouterString("id")
+	rl, ok := boot.Relays.Get(id)
+	if !ok {
+		response.Error(r, http.StatusNotFound, 201, relay.ErrNoEntry.Error())
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), boot.Relays.TTL)
+	defer cancel()
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		if rl.From != clientID(r) {
+			response.Error(r, http.StatusForbidden, 403, "只有创建直传的设备可以发送")
+		}
+		n, err := boot.Relays.Send(ctx, id, r.Body)
+		switch {
+		case err == relay.ErrBusy:
+			response.Error(r, http.StatusConflict, 409, err.Error())
+		case err == context.DeadlineExceeded:
+			response.Error(r, http.StatusGatewayTimeout, 201, "接收方未连接，直传已超时")
+		case err != nil:
+			response.Error(r, http.StatusBadGateway, 201, err.Error(), g.Map{"bytes": n})
+		}
+		response.JSON(r, 0, "ok", g.Map{"bytes": n})
+	case http.MethodGet, http.MethodHead:
+		if rl.To != "" && rl.To != boot.DeviceIdentity(r.Request) {
+			response.Error(r, http.StatusForbidden, 403, "该直传发给其他设备")
+		}
+		relayHeaders(r.Response.Header(), rl, r.UserAgent())
+		if r.Method == http.MethodHead {
+			return
+		}
+		relayReceive(ctx, r, rl)
+	default:
+		response.Error(r, http.StatusMethodNotAllowed, 201, "method not allowed")
+	}
+}
+
+// relayHeaders 接收方的响应头
+func relayHeaders(h http.Header, rl relay.Relay, ua string) {
+	h.Set("Content-Type", "application/octet-stream")
+	h.Set("Content-Disposition", disposition.Header(disposition.Attachment, rl.Name, ua))
+	if rl.Size >= 0 {
+		h.Set("Content-Length", strconv.FormatInt(rl.Size, 10))
+	}
+}
+
+// relayReceive 接管连接后等待发送方，发送方开始传输时才写出响应头；
+// 框架会缓冲全部响应内容且有写超时，直传只能绕过
+func relayReceive(ctx context.Context, r *ghttp.Request, rl relay.Relay) {
+	conn, rw, err := hijack(r)
+	if err == errNoHijack {
+		relayReceiveStream(ctx, r, rl)
+		return
+	}
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Time{})
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// 接收方不会再发送数据，读到EOF即已断开，不再等待发送方
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, conn)
+		cancel()
+	}()
+	started := false
+	n, err := boot.Relays.Receive(ctx, rl.ID, func() io.Writer {
+		started = true
+		h := r.Response.Header()
+		h.Set("Connection", "close")
+		h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		_, _ = rw.WriteString("HTTP/1.1 200 OK\r\n")
+		_ = h.Write(rw)
+		_, _ = rw.WriteString("\r\n")
+		return rw
+	})
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil && !started {
+		status, msg := http.StatusGatewayTimeout, "发送方未连接，直传已超时"
+		switch err {
+		case relay.ErrBusy:
+			status, msg = http.StatusConflict, err.Error()
+		case relay.ErrNoEntry:
+			status, msg = http.StatusNotFound, err.Error()
+		}
+		_, _ = fmt.Fprintf(rw, "HTTP/1.1 %d %s\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s",
+			status, http.StatusText(status), len(msg), msg)
+		_ = rw.Flush()
+	}
+	if err != nil {
+		logger.Warn("relay", "receive", "id", rl.ID, "name", rl.Name, "bytes", n, "err", err)
+		return
+	}
+	logger.Info("relay", "done", "id", rl.ID, "name", rl.Name, "bytes", n, "from", rl.From, "to", boot.ClientName(r.Request))
+}
+
+// relayReceiveStream 不能接管连接(HTTP/2、HTTP/3)时的relayReceive，直接写出，客户端断开时请求的context结束
+func relayReceiveStream(ctx context.Context, r *ghttp.Request, rl relay.Relay) {
+	raw := rawWriter(r)
+	var out *bufio.Writer
+	n, err := boot.Relays.Receive(ctx, rl.ID, func() io.Writer {
+		raw.WriteHeader(http.StatusOK)
+		out = bufio.NewWriterSize(flushWriter{w: raw, done: r.Context().Done()}, 32<<10)
+		return out
+	})
+	if err == nil && out != nil {
+		err = out.Flush()
+	}
+	if err != nil && out == nil {
+		status, msg := http.StatusGatewayTimeout, "发送方未连接，直传已超时"
+		switch err {
+		case relay.ErrBusy:
+			status, msg = http.StatusConflict, err.Error()
+		case relay.ErrNoEntry:
+			status, msg = http.StatusNotFound, err.Error()
+		}
+		h := raw.Header()
+		h.Del("Content-Disposition")
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		h.Set("Content-Length", strconv.Itoa(len(msg)))
+		raw.WriteHeader(status)
+		_, _ = io.WriteString(raw, msg)
+	}
+	if err != nil {
+		logger.Warn("relay", "receive", "id", rl.ID, "name", rl.Name, "bytes", n, "err", err)
+		return
+	}
+	logger.Info("relay", "done", "id", rl.ID, "name", rl.Name, "bytes", n, "from", rl.From, "to", boot.ClientName(r.Request))
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/authn"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/s3"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+func init() {
+	if boot.S3Port > 0 {
+		go serveS3(boot.S3Port)
+	}
+}
+
+// serveS3 启动S3兼容接口
+func serveS3(port int) {
+	h := &s3.Handler{
+		Roots:   fileinfos.Roots(),
+		TempDir: uploadTmpDir(),
+		ETag: func(file string) string {
+			return metadata.Get(fileinfos.FileKey(file), hashes.MD5)
+		},
+		OnChange: s3Changed,
+		Allow:    protocolAllowed,
+		Remove:   protocolRemove,
+		Writable: protocolWritable,
+	}
+	if !s3Credentials(h) {
+		logger.Error("s3", "disabled", "err", "auth providers are enabled without a basic account(auth.user) to sign requests")
+		return
+	}
+	logger.Info("s3", "listening", "port", port)
+	var closed int32
+	awake := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&closed) == 1 {
+			http.Error(w, "S3 requires a basic account(auth.user)", http.StatusForbidden)
+			return
+		}
+		defer boot.BeginTransfer()()
+		h.ServeHTTP(w, r)
+	})
+	boot.OnSettings(func() {
+		if s3Credentials(h) {
+			atomic.StoreInt32(&closed, 0)
+		} else {
+			atomic.StoreInt32(&closed, 1)
+		}
+	})
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: awake}
+	boot.OnShutdown(func() { go func() { _ = srv.Shutdown(context.Background()) }() })
+	if err := srv.ListenAndServe(); err != nil && !boot.Stopping() {
+		logger.Error("s3", "serve", "err", err)
+	}
+}
+
+// s3Credentials 签名校验需要明文密钥，只能使用认证方式中basic的账号密码作为AccessKey/SecretKey，
+// 写权限按basic的角色；未启用认证时允许匿名访问，启用认证但没有basic账号时返回false
+func s3Credentials(h *s3.Handler) bool {
+	role := authn.RoleUser
+	if len(boot.Auth()) > 0 {
+		b := basicAuth()
+		if b == nil {
+			return false
+		}
+		h.AccessKey, h.SecretKey, role = b.User, b.Password, b.Role
+	} else {
+		h.AccessKey, h.SecretKey = "", ""
+	}
+	h.ReadOnly = !roleCanWrite(role)
+	return true
+}
+
+// s3Changed S3写操作后同步元数据并记录审计日志
+func s3Changed(r *http.Request, method, file string, size int64, etag string) {
+	key := fileinfos.FileKey(file)
+	entry := audit.Entry{Size: size, Agent: r.UserAgent()}
+	entry.Client, _, _ = net.SplitHostPort(r.RemoteAddr)
+	switch method {
+	case http.MethodPut, "COPY":
+		metadata.Delete(key)
+		if etag != "" {
+			metadata.Set(key, map[string]string{hashes.MD5: etag, "size": strconv.FormatInt(size, 10)})
+		}
+		entry.Op = audit.OpUpload
+		scanUpload(file)
+		replicate(file)
+	case http.MethodDelete:
+		metadata.Delete(key)
+		entry.Op = audit.OpDelete
+	default:
+		return
+	}
+	entry.Path = fileinfos.FileURL(file)
+	audit.Record(entry)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/notify"
+	"b0pass/library/scan"
+	"github.com/gogf/gf/frame/g"
+)
+
+// scanHook 上传完成后的扫描钩子，未启用时返回nil
+func scanHook() scan.Hook {
+	if !g.Config().GetBool("scan.enable") {
+		return nil
+	}
+	return scan.NewCommand(g.Config().GetString("scan.command"))
+}
+
+// scanUpload 异步扫描已上传的文件，未通过的文件移入隔离目录
+func scanUpload(file string) {
+	hook := scanHook()
+	if hook == nil {
+		return
+	}
+	key := fileinfos.FileKey(file)
+	metadata.Set(key, map[string]string{"scan": scan.StatusPending})
+	go func() {
+		ret := hook.Scan(file)
+		fields := map[string]string{"scan": ret.Status, "scan_detail": ret.Detail}
+		if ret.Status == scan.StatusInfected {
+			dir := fileinfos.GetRootPath() + "/" + g.Config().GetString("scan.quarantine", "tmp/quarantine")
+			dst, err := scan.Quarantine(file, dir)
+			if err != nil {
+				logger.Error("scan", "quarantine", "path", file, "err", err)
+			}
+			fields["quarantine"] = dst
+			notify.Send(notify.Event{
+				Type:  "scan",
+				Level: "warn",
+				Title: "文件未通过病毒扫描，已隔离: " + key,
+				Data:  ret,
+			})
+		}
+		metadata.Set(key, fields)
+	}()
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileindex"
+	"b0pass/library/response"
+	"b0pass/library/search"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"time"
+)
+
+// searchMaxLimit 单次搜索返回的结果上限
+const searchMaxLimit = 1000
+
+// Search 按文件名搜索共享目录 GET /api/search?q=report ext:pdf
+// 启用文件索引(index.enabled)时在其中查找，content=1时关键字也匹配文本文件的内容；
+// 否则启用search.index且索引已建立时在文件名索引中查找，都未启用时遍历目录；结果超过limit时truncated为true
+func Search(r *ghttp.Request) {
+	q := search.Parse(r.GetString("q"))
+	if q.Empty() {
+		response.Error(r, http.StatusBadRequest, 201, "缺少搜索关键字")
+	}
+	limit := r.GetInt("limit", g.Config().GetInt("search.limit", 200))
+	if limit <= 0 || limit > searchMaxLimit {
+		limit = searchMaxLimit
+	}
+	results := make([]search.Entry, 0)
+	truncated := false
+	collect := func(e search.Entry) bool {
+		if len(results) == limit {
+			truncated = true
+			return false
+		}
+		e.Path = "/files" + e.Path
+		results = append(results, e)
+		return true
+	}
+	start := time.Now()
+	var indexed interface{}
+	if x := boot.FileIndex; x != nil && !x.Synced().IsZero() {
+		indexed = x.Synced()
+		if err := x.Search(q, r.GetBool("content"), func(f fileindex.File) bool { return collect(f.Entry) }); err != nil {
+			response.Error(r, http.StatusInternalServerError, 201, err.Error())
+		}
+	} else if r.GetBool("content") {
+		response.Error(r, http.StatusBadRequest, 201, "文件索引未启用(index.enabled)，无法搜索文件内容")
+	} else if x := boot.SearchIndex; x != nil && !x.Built().IsZero() {
+		indexed = x.Built()
+		x.Search(q, collect)
+	} else if err := search.Walk(boot.SearchRoots(), q, collect); err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok", g.Map{
+		"results":   results,
+		"truncated": truncated,
+		"indexed":   indexed,
+		"took":      int64(time.Since(start) / time.Millisecond),
+	})
+}
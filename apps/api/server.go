@@ -6,6 +6,7 @@ import (
 	"b0pass/library/ipaddress"
 	"b0pass/library/openurl"
 	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
 	"github.com/gogf/gf/net/ghttp"
 	"strconv"
 )
@@ -27,6 +28,17 @@ func GetIp(r *ghttp.Request) {
 	response.JSON(r, 0, "ok", ips)
 }
 
+// Status 服务状态(磁盘使用率、提示横幅、文件句柄与休眠抑制)
+func Status(r *ghttp.Request) {
+	usage, level := boot.Storage.State()
+	response.JSON(r, 0, "ok", g.Map{
+		"disk":   usage,
+		"banner": level,
+		"files":  boot.FileLimit(),
+		"awake":  boot.Awake.Active(),
+	})
+}
+
 // GetPathSub 上传目录记忆功能
 func GetSubPath(r *ghttp.Request){
 	saveData(r,"path","data_path")
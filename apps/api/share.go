@@ -0,0 +1,276 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/disposition"
+	"b0pass/library/fileinfos"
+	"b0pass/library/proxy"
+	"b0pass/library/qrcode"
+	"b0pass/library/response"
+	"b0pass/library/shares"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShareCreate 创建分享链接 POST {"path":"/files/a.zip","ttl":24}
+// ttl单位为小时，0为默认有效期share.ttl，-1为不过期；接收方打开返回的/s/<id>下载，访问与下载完成情况可在/api/share/stats查看
+func ShareCreate(r *ghttp.Request) {
+	var req struct {
+		Path string `json:"path"`
+		TTL  int    `json:"ttl"`
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	link, err := boot.Shares.Create(shareTarget(r, req.Path), boot.ShareTTL(req.TTL))
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok", g.Map{"share": link, "url": boot.BasePath + "/s/" + link.ID})
+}
+
+// ShareShort 为单个文件创建短链接(如 /s/ab3xk7m2qp)并给出二维码，在电脑屏幕上显示后手机扫码即可下载该文件
+// POST {"path":"/files/a.zip","ttl":0}，ttl同/api/share；同一文件已有剩余有效期超过一半的短链接时返回该链接
+func ShareShort(r *ghttp.Request) {
+	var req struct {
+		Path string `json:"path"`
+		TTL  int    `json:"ttl"`
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	link, err := boot.Shares.Short(shareTarget(r, req.Path), boot.ShareTTL(req.TTL))
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok", g.Map{
+		"share": link,
+		"url":   boot.BasePath + "/s/" + link.ID,
+		"full":  phoneURL(r, "/s/"+link.ID),
+		"qr":    boot.BasePath + "/api/share/qr?id=" + link.ID,
+	})
+}
+
+// ShareQR 分享链接完整地址的二维码PNG /api/share/qr?id=ab3xk7m2qp&scale=8
+func ShareQR(r *ghttp.Request) {
+	link, ok := boot.Shares.Get(r.GetString("id"))
+	if !ok || !link.Valid(time.Now()) {
+		response.Error(r, http.StatusNotFound, 201, shares.ErrNoEntry.Error())
+	}
+	code, err := qrcode.Encode(phoneURL(r, "/s/"+link.ID), qrcode.M)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	scale := r.GetInt("scale", 8)
+	if scale < 1 || scale > 32 {
+		response.Error(r, http.StatusBadRequest, 201, "scale只能是1-32")
+	}
+	r.Response.Header().Set("Content-Type", "image/png")
+	r.Response.Header().Set("Cache-Control", "no-store")
+	_ = code.PNG(r.Response.Writer, scale)
+}
+
+// shareTarget 可分享的文件在共享目录下的相对路径，p如 /files/a.zip
+func shareTarget(r *ghttp.Request, p string) string {
+	rel := strings.TrimPrefix(path.Clean("/"+p), "/files")
+	file := fileinfos.FilePath(rel)
+	info, err := os.Stat(file)
+	if file == "" || err != nil || info.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	if _, ok := boot.Protected.Find(fileinfos.FileKey(file)); ok {
+		response.Error(r, http.StatusLocked, 201, "受保护目录中的文件不能创建分享链接")
+	}
+	return rel
+}
+
+// phoneURL 其他设备访问path的完整地址，在本机通过localhost访问时换成内网地址
+func phoneURL(r *ghttp.Request, p string) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return baseURL(r) + p
+	}
+	u := boot.AccessURL(boot.ServPort, boot.BasePath+p)
+	if proxy.Scheme(r.Request) == "https" {
+		u = "https://" + strings.TrimPrefix(u, "http://")
+	}
+	return u
+}
+
+// ShareList 分享链接列表(含访问统计)
+func ShareList(r *ghttp.Request) {
+	response.JSON(r, 0, "ok", boot.Shares.List())
+}
+
+// ShareStats 分享链接的访问统计: 访问次数、设备、客户端、来源及下载是否完成
+func ShareStats(r *ghttp.Request) {
+	link, ok := boot.Shares.Get(r.GetString("id"))
+	if !ok {
+		response.Error(r, http.StatusNotFound, 201, shares.ErrNoEntry.Error())
+	}
+	st := link.Stats
+	response.JSON(r, 0, "ok", g.Map{
+		"id":        link.ID,
+		"path":      "/files" + link.Path,
+		"expired":   !link.Valid(time.Now()),
+		"delivered": st.Delivered(),
+		"unique":    len(st.Devices),
+		"stats":     st,
+	})
+}
+
+// ShareDelete 删除分享链接
+func ShareDelete(r *ghttp.Request) {
+	if err := boot.Shares.Delete(r.GetString("id")); err == shares.ErrNoEntry {
+		response.Error(r, http.StatusNotFound, 201, err.Error())
+	} else if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	response.JSON(r, 0, "ok")
+}
+
+// ShareFile 通过分享链接下载 GET /s/<id>，支持Range续传
+// 自行写出响应以统计实际传输的字节数，判断接收方是否下载完整
+func ShareFile(r *ghttp.Request) {
+	id := r.GetRouterString("id")
+	client, now := r.GetClientIp(), time.Now()
+	if boot.ShareGuard.Blocked(client, now) {
+		r.Response.Header().Set("Retry-After", "60")
+		r.Response.WriteStatus(http.StatusTooManyRequests, "访问不存在的链接过多，请稍后重试")
+		r.Exit()
+	}
+	link, ok := boot.Shares.Get(id)
+	if !ok || !link.Valid(now) {
+		boot.ShareGuard.Miss(client, now)
+		r.Response.WriteStatus(http.StatusNotFound, "链接不存在或已过期")
+		r.Exit()
+	}
+	file := fileinfos.FilePath(link.Path)
+	f, err := os.Open(file)
+	if file == "" || err != nil {
+		r.Response.WriteStatus(http.StatusNotFound, "文件不存在")
+		r.Exit()
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		r.Response.WriteStatus(http.StatusNotFound, "文件不存在")
+		r.Exit()
+	}
+	access := shares.Access{
+		Device:    ClientKey(r),
+		Addr:      r.GetClientIp(),
+		UserAgent: r.UserAgent(),
+		Referrer:  r.Referer(),
+	}
+	w, closeConn, err := newCountWriter(r)
+	if err != nil {
+		// 无法接管连接时交由框架传输，只记录访问
+		_ = boot.Shares.Record(id, access)
+		r.Response.Header().Set("Content-Disposition", disposition.Header(disposition.Attachment, info.Name(), r.UserAgent()))
+		r.Response.ServeFile(file)
+		return
+	}
+	defer closeConn()
+	w.header.Set("Content-Disposition", disposition.Header(disposition.Attachment, info.Name(), r.UserAgent()))
+	http.ServeContent(w, r.Request, info.Name(), info.ModTime(), f)
+	flushed := w.w.Flush() == nil
+	if (w.status == http.StatusOK || w.status == http.StatusPartialContent) && r.Method != http.MethodHead {
+		access.Download = true
+		access.Bytes = w.n
+		access.Aborted = !flushed || !w.finished()
+		access.Complete = !access.Aborted && w.toEOF(info.Size())
+	}
+	_ = boot.Shares.Record(id, access)
+	if access.Complete {
+		auditLog(r, audit.OpDownload, file, w.n)
+		recordTransfer(r, audit.OpDownload, file, w.n, requestStart(r))
+	}
+}
+
+// countWriter 接管连接后的ResponseWriter，记录写出的正文字节数
+type countWriter struct {
+	w      *bufio.Writer
+	header http.Header
+	raw    http.ResponseWriter // 不能接管连接(HTTP/2、HTTP/3)时直接写出，由协议自身分帧
+	status int
+	n      int64
+	err    error
+}
+
+// newCountWriter 接管连接，不能接管时改为直接写出；返回的函数在传输结束后关闭连接
+func newCountWriter(r *ghttp.Request) (*countWriter, func(), error) {
+	conn, rw, err := hijack(r)
+	if err == errNoHijack {
+		raw := rawWriter(r)
+		return &countWriter{w: bufio.NewWriterSize(raw, 32<<10), header: raw.Header(), raw: raw}, func() {}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return &countWriter{w: rw.Writer, header: r.Response.Header()}, func() { _ = conn.Close() }, nil
+}
+
+func (c *countWriter) Header() http.Header { return c.header }
+
+// WriteHeader 写出状态行与响应头，传输结束后关闭连接
+func (c *countWriter) WriteHeader(status int) {
+	if c.status != 0 {
+		return
+	}
+	c.status = status
+	if c.raw != nil {
+		c.raw.WriteHeader(status)
+		return
+	}
+	c.header.Set("Connection", "close")
+	c.header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	_, _ = fmt.Fprintf(c.w, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+	_ = c.header.Write(c.w)
+	_, _ = c.w.WriteString("\r\n")
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// finished 响应正文是否按Content-Length全部写出
+func (c *countWriter) finished() bool {
+	n, err := strconv.ParseInt(c.header.Get("Content-Length"), 10, 64)
+	return c.err == nil && err == nil && c.n == n
+}
+
+// toEOF 响应是否包含文件末尾(完整下载或分段续传的最后一段)
+func (c *countWriter) toEOF(size int64) bool {
+	if c.status == http.StatusOK {
+		return true
+	}
+	var start, end, total int64
+	if _, err := fmt.Sscanf(c.header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		return false
+	}
+	return end == size-1
+}
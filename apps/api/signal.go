@@ -0,0 +1,65 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/device"
+	"b0pass/library/notify"
+	"b0pass/library/response"
+	"encoding/json"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+)
+
+// EventSignal WebRTC信令，经/api/events转给目标设备
+const EventSignal = "signal"
+
+// maxSignal 单条信令(SDP或ICE候选)的最大长度
+const maxSignal = 64 << 10
+
+// signalKinds 允许的信令类型: SDP提议与应答、ICE候选、结束(拒绝或取消)
+var signalKinds = map[string]bool{"offer": true, "answer": true, "candidate": true, "bye": true}
+
+// SignalConfig WebRTC配置: ICE服务器(局域网内直连时可为空)
+// /api/signal
+func SignalConfig(r *ghttp.Request) {
+	servers := make([]g.Map, 0)
+	for _, u := range g.Config().GetStrings("webrtc.stun") {
+		servers = append(servers, g.Map{"urls": u})
+	}
+	response.JSON(r, 0, "ok", g.Map{"ice_servers": servers, "key": device.Key(deviceIdentity(r))})
+}
+
+// Signal 向另一台在线设备转发WebRTC信令，两个浏览器据此建立数据通道点对点传输文件，服务端不经手文件内容
+// POST /api/signal {"to":"设备key","session":"会话ID","kind":"offer","data":{...}}
+func Signal(r *ghttp.Request) {
+	raw := r.GetRaw()
+	if len(raw) > maxSignal {
+		response.Error(r, http.StatusRequestEntityTooLarge, 413, "信令过长")
+	}
+	var req struct {
+		To      string          `json:"to"`
+		Session string          `json:"session"`
+		Kind    string          `json:"kind"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil || req.To == "" || req.Session == "" || len(req.Session) > 64 || !signalKinds[req.Kind] {
+		response.Error(r, http.StatusBadRequest, 201, `请求体须为JSON: {"to":"设备key","session":"会话ID","kind":"offer|answer|candidate|bye","data":{}}`)
+	}
+	target, ok := boot.Clients.Find(req.To)
+	if !ok || !notify.Online(target.ID) {
+		response.Error(r, http.StatusNotFound, 201, "设备不在线")
+	}
+	from := boot.ClientName(r.Request)
+	e := notify.Event{Type: EventSignal, Level: "info", Title: from, Data: g.Map{
+		"from":     from,
+		"from_key": device.Key(deviceIdentity(r)),
+		"session":  req.Session,
+		"kind":     req.Kind,
+		"data":     req.Data,
+	}}
+	if notify.SendTo(target.ID, e) == 0 {
+		response.Error(r, http.StatusNotFound, 201, "设备不在线")
+	}
+	response.JSON(r, 0, "ok")
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/batch"
+	"b0pass/library/response"
+	"encoding/json"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"sort"
+)
+
+func init() {
+	boot.RegisterState("settings",
+		func() interface{} { return settingsValues() },
+		restoreSettings)
+	boot.RegisterState("batches",
+		func() interface{} { return batches.Save() },
+		func(b json.RawMessage) (int, error) {
+			var list []batch.Saved
+			if err := json.Unmarshal(b, &list); err != nil {
+				return 0, err
+			}
+			return batches.Restore(list), nil
+		})
+}
+
+// restoreSettings 恢复快照中与当前生效值不同的设置
+func restoreSettings(b json.RawMessage) (int, error) {
+	var saved map[string]interface{}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return 0, err
+	}
+	current := settingsValues()
+	changed := make(map[string]interface{})
+	for _, s := range adminSettings {
+		v, ok := saved[s.Key]
+		if !ok {
+			continue
+		}
+		v, err := checkSetting(s, v)
+		if err != nil {
+			return 0, err
+		}
+		if fmt.Sprint(v) != fmt.Sprint(current[s.Key]) {
+			changed[s.Key] = v
+		}
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	return len(changed), boot.SaveSettings(changed)
+}
+
+// AdminSnapshot 保存运行时状态快照(设置、访客授权、目录上传会话与镜像复制队列)，下次启动时自动恢复(仅主电脑)
+func AdminSnapshot(r *ghttp.Request) {
+	if !fromHost(r) {
+		response.Error(r, http.StatusForbidden, 403, "请在主电脑上操作")
+	}
+	snap, err := boot.SaveSnapshot()
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	var states []string
+	for name := range snap.State {
+		states = append(states, name)
+	}
+	sort.Strings(states)
+	response.JSON(r, 0, "ok", g.Map{"file": boot.SnapshotFile(), "time": snap.Time, "states": states})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/metadata"
+	"b0pass/library/mimetype"
+	"b0pass/library/response"
+	"b0pass/library/thumbs"
+	"fmt"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// fileStat 文件的详细信息，不适用或无法读取的字段省略
+type fileStat struct {
+	Path     string  `json:"path"`
+	Name     string  `json:"name"`
+	Dir      bool    `json:"dir"`
+	Size     int64   `json:"size"`
+	MTime    int64   `json:"mtime"`
+	Mode     string  `json:"mode"` // 如 -rw-r--r--
+	Perm     string  `json:"perm"` // 八进制权限，如 0644
+	MIME     string  `json:"mime"`
+	Hash     string  `json:"hash,omitempty"` // 已记录或已索引的校验值，不会为此读取整个文件
+	Algo     string  `json:"algo,omitempty"`
+	Width    int     `json:"width,omitempty"` // 图片(按EXIF方向旋转后)或视频的尺寸
+	Height   int     `json:"height,omitempty"`
+	Taken    string  `json:"taken,omitempty"` // 照片拍摄时间
+	Duration float64 `json:"duration,omitempty"`
+	Video    string  `json:"video,omitempty"` // 视频编码
+	Audio    string  `json:"audio,omitempty"` // 音频编码
+}
+
+// Stat 文件的大小、修改时间、权限、MIME类型(按内容识别)、校验值与图片/视频尺寸，无需下载文件即可显示详情
+// /api/stat?path=/files/a.jpg
+func Stat(r *ghttp.Request) {
+	name := path.Clean("/" + r.GetQueryString("path"))
+	file := fileinfos.FilePath(strings.TrimPrefix(name, "/files"))
+	info, err := os.Stat(file)
+	if file == "" || err != nil {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	st := fileStat{
+		Path:  name,
+		Name:  info.Name(),
+		Dir:   info.IsDir(),
+		MTime: info.ModTime().Unix(),
+		Mode:  info.Mode().String(),
+		Perm:  fmt.Sprintf("%04o", info.Mode().Perm()),
+	}
+	if st.Dir {
+		st.MIME = "inode/directory"
+		response.JSON(r, 0, "ok", st)
+	}
+	st.Size = info.Size()
+	st.MIME = mimetype.File(file)
+	st.Hash = knownHash(file, info)
+	if st.Hash != "" {
+		st.Algo = hashes.Default
+	}
+	switch {
+	case thumbs.Supported(info.Name()):
+		img := boot.Gallery.Get(file, info)
+		st.Width, st.Height, st.Taken = img.Width, img.Height, img.Taken
+	case strings.HasPrefix(st.MIME, "video/") || strings.HasPrefix(st.MIME, "audio/"):
+		if boot.Prober == nil {
+			break
+		}
+		if v, err := boot.Prober.Probe(file); err == nil {
+			st.Width, st.Height, st.Duration, st.Video, st.Audio = v.Width, v.Height, v.Duration, v.Video, v.Audio
+		}
+	}
+	response.JSON(r, 0, "ok", st)
+}
+
+// knownHash 文件索引或上传时记录的校验值，大小或修改时间不一致时视为过期
+func knownHash(file string, info os.FileInfo) string {
+	if boot.FileIndex != nil {
+		if f, ok := boot.FileIndex.Get("/" + fileinfos.FileKey(file)); ok && f.Size == info.Size() && f.MTime == info.ModTime().Unix() && f.Hash != "" {
+			return f.Hash
+		}
+	}
+	m := metadata.Fields(fileinfos.FileKey(file))
+	if m["size"] == strconv.FormatInt(info.Size(), 10) {
+		return m[hashes.Default]
+	}
+	return ""
+}
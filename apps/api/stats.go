@@ -0,0 +1,40 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/diskusage"
+	"b0pass/library/fileinfos"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+)
+
+// rootStats 一个共享根目录所在磁盘的空间
+type rootStats struct {
+	Name string `json:"name"`
+	diskusage.Usage
+	Available int64  `json:"available"` // 可用于上传的空间(扣除拒绝上传阈值与安全余量)
+	Level     string `json:"level"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Stats 各共享根目录的磁盘空间与上传排队情况
+func Stats(r *ghttp.Request) {
+	list := make([]rootStats, 0, len(fileinfos.Roots()))
+	for _, root := range fileinfos.Roots() {
+		s := rootStats{Name: root.Name}
+		if usage, err := diskusage.Get(root.Path); err != nil {
+			s.Error = err.Error()
+		} else {
+			s.Usage, s.Available = usage, boot.DiskAvailable(usage)
+			s.Level = diskusage.LevelOf(usage.Percent, boot.Storage.Warn, boot.Storage.Block)
+		}
+		list = append(list, s)
+	}
+	max, active, queued := boot.UploadSlots.Stats()
+	response.JSON(r, 0, "ok", g.Map{
+		"roots":   list,
+		"margin":  boot.StorageMargin(),
+		"uploads": g.Map{"max": max, "active": active, "queued": queued},
+	})
+}
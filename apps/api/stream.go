@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/gogf/gf/net/ghttp"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"time"
+)
+
+// errNoHijack HTTP/2、HTTP/3的请求没有独占的连接，不能接管
+var errNoHijack = errors.New("connection cannot be hijacked (HTTP/2 or HTTP/3)")
+
+// hijack 接管连接；不能接管时返回errNoHijack(框架的Hijack在这种情况下会panic)
+func hijack(r *ghttp.Request) (net.Conn, *bufio.ReadWriter, error) {
+	if _, ok := r.Response.Writer.RawWriter().(http.Hijacker); !ok {
+		return nil, nil, errNoHijack
+	}
+	return r.Response.Writer.Hijack()
+}
+
+// rawWriter 不能接管连接时绕过框架缓冲直接写出的ResponseWriter，并取消写超时(HTTP/2按请求计算写超时)
+func rawWriter(r *ghttp.Request) http.ResponseWriter {
+	w := r.Response.Writer.RawWriter()
+	if d, ok := w.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		_ = d.SetWriteDeadline(time.Time{})
+	}
+	return w
+}
+
+// flushWriter 每次写入后立即发送
+type flushWriter struct {
+	w    http.ResponseWriter
+	done <-chan struct{}
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	return n, err
+}
+
+// Read 请求结束或客户端断开时返回EOF，与接管的连接读到EOF一致
+func (f flushWriter) Read(p []byte) (int, error) {
+	<-f.done
+	return 0, io.EOF
+}
+
+// Close 请求流随处理结束而关闭
+func (f flushWriter) Close() error {
+	return nil
+}
+
+// chunkedStream 接管连接并以分块编码(chunked)输出响应，内容边生成边发送，不经过框架的缓冲
+// done(true)写出结束块并关闭连接；done(false)直接断开，客户端据此知道响应不完整。
+// 无法接管连接时(如HTTP/2)直接写出，由协议自身分帧
+func chunkedStream(r *ghttp.Request, status int) (w io.Writer, done func(ok bool)) {
+	conn, rw, err := hijack(r)
+	if err != nil {
+		raw := rawWriter(r)
+		raw.Header().Del("Content-Length")
+		raw.WriteHeader(status)
+		out := bufio.NewWriterSize(flushWriter{w: raw, done: r.Context().Done()}, 32<<10)
+		return out, func(ok bool) {
+			if ok {
+				_ = out.Flush()
+			}
+		}
+	}
+	_ = conn.SetDeadline(time.Time{})
+	h := r.Response.Header()
+	h.Del("Content-Length")
+	h.Set("Transfer-Encoding", "chunked")
+	h.Set("Connection", "close")
+	h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	bw := rw.Writer
+	_, _ = fmt.Fprintf(bw, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+	_ = h.Write(bw)
+	_, _ = bw.WriteString("\r\n")
+	cw := httputil.NewChunkedWriter(bw)
+	out := bufio.NewWriterSize(cw, 32<<10)
+	return out, func(ok bool) {
+		if ok && out.Flush() == nil && cw.Close() == nil {
+			_, _ = bw.WriteString("\r\n")
+			_ = bw.Flush()
+		}
+		_ = conn.Close()
+	}
+}
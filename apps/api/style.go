@@ -0,0 +1,39 @@
+package api
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/metadata"
+	"b0pass/library/response"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// styleColor 颜色: #rgb、#rrggbb或颜色名
+var styleColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[a-zA-Z]{1,20})$`)
+
+// Style 设置共享根目录或文件夹的图标、颜色与说明，列表接口一并返回，参数为空时清除
+// /api/style?f=/files/photos&icon=📷&color=#f80&desc=家庭相册
+func Style(r *ghttp.Request) {
+	file := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+r.GetString("f")), "/files"))
+	if info, err := os.Stat(file); file == "" || err != nil || !info.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "目录不存在")
+	}
+	icon, color, desc := strings.TrimSpace(r.GetString("icon")), r.GetString("color"), strings.TrimSpace(r.GetString("desc"))
+	if utf8.RuneCountInString(icon) > 32 {
+		response.Error(r, http.StatusBadRequest, 201, "icon too long")
+	}
+	if color != "" && !styleColor.MatchString(color) {
+		response.Error(r, http.StatusBadRequest, 201, "color must be #rgb, #rrggbb or a color name")
+	}
+	if utf8.RuneCountInString(desc) > 200 {
+		response.Error(r, http.StatusBadRequest, 201, "desc too long")
+	}
+	fields := map[string]string{"icon": icon, "color": color, "desc": desc}
+	metadata.Set(fileinfos.FileKey(file), fields)
+	response.JSON(r, 0, "ok", fields)
+}
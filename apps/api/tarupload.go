@@ -0,0 +1,121 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/archive"
+	"b0pass/library/audit"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/metadata"
+	"b0pass/library/protect"
+	"b0pass/library/quota"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// UploadTar 以一个tar流(可gzip压缩)上传整个目录树，服务端边接收边解包
+// 大量小文件(如node_modules)不再逐个请求，且按同步策略(默认on-complete)全部写完后一次同步到磁盘
+// 参数只从查询字符串读取，请求体即tar流
+// tar cf - node_modules | curl -T - "http://ip:8899/api/upload/tar?path=project"
+func UploadTar(r *ghttp.Request) {
+	if boot.Storage.Blocked() {
+		response.Error(r, http.StatusInsufficientStorage, 507, "磁盘空间不足，已停止接收上传")
+	}
+	checkQuota(r, r.Request.ContentLength)
+	dir := fileinfos.FilePath(r.GetQueryString("path"))
+	if dir == "" {
+		response.Error(r, http.StatusBadRequest, 201, errNoRoot())
+	}
+	if _, err := protectUploadKey(r, dir); err != nil {
+		response.Error(r, http.StatusLocked, 201, err.Error())
+	}
+	policy := durability(r)
+	opt := archive.UnpackOptions{
+		Algos:   []string{hashes.Default},
+		MaxSize: boot.UploadLimit(),
+		Sync:    batchSync(policy),
+		Clean:   cleanPath,
+	}
+	conflict, err := uploadConflict(r)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	// overwrite=1同conflict=overwrite
+	if r.GetQueryBool("overwrite") {
+		conflict = fileinfos.ConflictOverwrite
+	}
+	skipped := make([]string, 0)
+	opt.Conflict = func(target string) (string, error) {
+		file, err := fileinfos.ResolveConflict(target, conflict)
+		if err == fileinfos.ErrSkipped {
+			skipped = append(skipped, fileinfos.FileURL(target))
+			return "", nil
+		}
+		return file, err
+	}
+	// 写入受保护目录的文件在移动到目标位置前加密
+	opt.Before = func(tmp, target string) error {
+		key, err := protectUploadKey(r, target)
+		if err != nil || key == nil {
+			return err
+		}
+		return protect.EncryptFile(tmp, key)
+	}
+	start := time.Now()
+	files, err := archive.Unpack(r.Body, dir, opt)
+	finishBatch(r, files, policy)
+	paths := make([]string, 0, len(files))
+	var bytes int64
+	for _, f := range files {
+		paths = append(paths, fileinfos.FileURL(f.Path))
+		bytes += f.Size
+	}
+	switch {
+	case err == archive.ErrTooLarge:
+		response.Error(r, http.StatusRequestEntityTooLarge, 413, "文件超过上传大小限制", paths)
+	case isLocked(err):
+		response.Error(r, http.StatusLocked, 201, err.Error(), paths)
+	case err == fileinfos.ErrExist:
+		response.Error(r, http.StatusConflict, 409, "同名文件已存在", paths)
+	case err != nil:
+		response.Error(r, http.StatusBadRequest, 201, err.Error(), paths)
+	}
+	response.JSON(r, 0, "ok", g.Map{
+		"files":   paths,
+		"skipped": skipped,
+		"bytes":   bytes,
+		"took":    int64(time.Since(start) / time.Millisecond),
+	})
+}
+
+// finishBatch 批量上传完成后的处理，同finishUpload，元数据一次写入，不签发回执
+func finishBatch(r *ghttp.Request, files []archive.Unpacked, policy string) {
+	items := make(map[string]map[string]string, len(files))
+	for _, f := range files {
+		fields := map[string]string{"size": strconv.FormatInt(f.Size, 10), quota.FieldOwner: ClientKey(r)}
+		for algo, sum := range f.Sums {
+			fields[algo] = sum
+		}
+		items[fileinfos.FileKey(f.Path)] = fields
+	}
+	metadata.SetMany(items)
+	start := requestStart(r)
+	for _, f := range files {
+		recordChunks(f.Path, f.Size)
+		auditUpload(r, f.Path, f.Size, policy)
+		recordTransfer(r, audit.OpUpload, f.Path, f.Size, start)
+		fileEvent(r, EventUpload, f.Path, f.Size, f.Sums[hashes.Default])
+		scanUpload(f.Path)
+		replicate(f.Path)
+	}
+	paths := make([]string, len(files))
+	var size int64
+	for i, f := range files {
+		paths[i], size = f.Path, size+f.Size
+	}
+	uploadNotice(r, paths, size)
+}
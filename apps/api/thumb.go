@@ -0,0 +1,52 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/httpcache"
+	"b0pass/library/logger"
+	"b0pass/library/response"
+	"b0pass/library/thumbs"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// Thumb 图片缩略图，缩放到w×h以内(保持比例)，生成后缓存，源文件变化后重新生成
+// /api/thumb?path=/files/photos/a.jpg&w=256&h=256
+func Thumb(r *ghttp.Request) {
+	file := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+r.GetString("path")), "/files"))
+	src, err := os.Stat(file)
+	if file == "" || err != nil || src.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	if !thumbs.Supported(file) {
+		response.Error(r, http.StatusUnsupportedMediaType, 201, "不支持该图片格式")
+	}
+	c := g.Config()
+	size, max := c.GetInt("thumb.size", 256), c.GetInt("thumb.max", 1024)
+	w, h := r.GetInt("w", size), r.GetInt("h", size)
+	if w <= 0 || h <= 0 || w > max || h > max {
+		response.Error(r, http.StatusBadRequest, 201, "w、h须在1到thumb.max之间")
+	}
+	thumb, err := boot.Thumbs(file).Get(file, w, h)
+	switch err {
+	case nil:
+	case thumbs.ErrTooLarge:
+		response.Error(r, http.StatusRequestEntityTooLarge, 201, "图片像素数超过thumb.pixels")
+	default:
+		logger.Warn("thumb", "generate", "path", fileinfos.FileKey(file), "err", err)
+		response.Error(r, http.StatusUnprocessableEntity, 201, "无法生成缩略图: "+err.Error())
+	}
+	// 缩略图的修改时间为最近使用时间，ETag改用源文件的，源文件不变时浏览器缓存一直有效
+	r.Response.Header().Set("Cache-Control", "private, max-age=86400")
+	httpcache.Set(r.Response.Header(), src)
+	if httpcache.NotModified(r.Request, src) {
+		r.Response.WriteHeader(http.StatusNotModified)
+		return
+	}
+	r.Response.ServeFile(thumb)
+}
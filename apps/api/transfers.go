@@ -0,0 +1,75 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/history"
+	"b0pass/library/logger"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// uploadStarts 分多次请求完成的上传(分片、设备上传、tus)的开始时间，key为目标路径
+var uploadStarts sync.Map
+
+// requestStart 请求到达的时间
+func requestStart(r *ghttp.Request) time.Time {
+	return time.Unix(0, r.EnterTime*int64(time.Microsecond))
+}
+
+// markUpload 记录上传的开始时间，完成时按此计算耗时与平均速度
+func markUpload(savePath string, start time.Time) {
+	uploadStarts.Store(savePath, start)
+}
+
+// uploadStart 上传的开始时间，未记录时为当前请求到达的时间
+func uploadStart(r *ghttp.Request, savePath string) time.Time {
+	if v, ok := uploadStarts.Load(savePath); ok {
+		uploadStarts.Delete(savePath)
+		return v.(time.Time)
+	}
+	return requestStart(r)
+}
+
+// recordTransfer 记录一次完成的传输
+func recordTransfer(r *ghttp.Request, op, file string, size int64, start time.Time) {
+	t := history.NewTransfer(op, fileinfos.FileURL(file), size, start, time.Now())
+	t.Device, t.Name, t.Agent = clientID(r), boot.ClientName(r.Request), r.UserAgent()
+	if err := boot.TransferLog.Add(t); err != nil {
+		logger.Error("history", "record", "path", t.Path, "err", err)
+	}
+}
+
+// History 已完成的传输记录，按时间从新到旧排序
+// /api/history?device=pixel&op=upload&from=2024-05-01&to=2024-05-07&limit=100
+// device为设备标识(device:<id>、browser:<id>)、设备名称或客户端地址
+func History(r *ghttp.Request) {
+	from, err := history.ParseTime(r.GetString("from"), false)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	to, err := history.ParseTime(r.GetString("to"), true)
+	if err != nil {
+		response.Error(r, http.StatusBadRequest, 201, err.Error())
+	}
+	list, err := boot.TransferLog.Query(history.Filter{
+		Device: r.GetString("device"),
+		Op:     r.GetString("op"),
+		Path:   r.GetString("q"),
+		From:   from,
+		To:     to,
+		Limit:  r.GetInt("limit", 100),
+	})
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	var size int64
+	for _, t := range list {
+		size += t.Size
+	}
+	response.JSON(r, 0, "ok", g.Map{"count": len(list), "size": size, "items": list})
+}
@@ -0,0 +1,327 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/history"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/notify"
+	"b0pass/library/quota"
+	"b0pass/library/response"
+	"b0pass/library/transit"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventTransit 经公网中转收发完成或失败
+const EventTransit = "transit"
+
+// 公网中转任务的状态
+const (
+	transitWaiting      = "waiting"      // 等待对方连接中转服务器
+	transitTransferring = "transferring" // 传输中
+	transitDone         = "done"
+	transitFailed       = "failed"
+)
+
+// transitJob 经公网中转服务器收发文件的任务，等待对方与传输都在后台进行，客户端按id查询进度
+type transitJob struct {
+	ID      string    `json:"id"`
+	Op      string    `json:"op"` // send、receive
+	Code    string    `json:"code"`
+	Relay   string    `json:"relay"`
+	State   string    `json:"state"`
+	Files   []string  `json:"files"`
+	Bytes   int64     `json:"bytes"`
+	Error   string    `json:"error,omitempty"`
+	Started time.Time `json:"started"`
+	client  string
+}
+
+var (
+	transitMu   sync.Mutex
+	transitJobs = make(map[string]*transitJob)
+)
+
+// transitRelay 配置的中转服务器地址，未配置时返回404
+func transitRelay(r *ghttp.Request) string {
+	relay := g.Config().GetString("transit.relay")
+	if relay == "" {
+		response.Error(r, http.StatusNotFound, 201, "未配置公网中转服务器(transit.relay)")
+	}
+	return relay
+}
+
+// newTransitJob 登记任务，并清理一天前结束的任务
+func newTransitJob(r *ghttp.Request, op, relay, code string) *transitJob {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	job := &transitJob{ID: hex.EncodeToString(b), Op: op, Code: code, Relay: relay, State: transitWaiting,
+		Files: []string{}, Started: time.Now(), client: clientID(r)}
+	transitMu.Lock()
+	defer transitMu.Unlock()
+	for id, j := range transitJobs {
+		if (j.State == transitDone || j.State == transitFailed) && time.Since(j.Started) > 24*time.Hour {
+			delete(transitJobs, id)
+		}
+	}
+	transitJobs[job.ID] = job
+	return job
+}
+
+// update 在锁内修改任务
+func (job *transitJob) update(fn func(j *transitJob)) {
+	transitMu.Lock()
+	fn(job)
+	transitMu.Unlock()
+}
+
+// finish 记录结果并推送给发起任务的设备
+func (job *transitJob) finish(err error) {
+	job.update(func(j *transitJob) {
+		j.State = transitDone
+		if err != nil {
+			j.State, j.Error = transitFailed, err.Error()
+		}
+	})
+	status := transitStatus(job)
+	level, title := "info", fmt.Sprintf("公网中转完成: %d个文件", len(status.Files))
+	if err != nil {
+		level, title = "error", "公网中转失败: "+err.Error()
+		logger.Error("transit", job.Op, "id", job.ID, "err", err)
+	}
+	notify.SendTo(job.client, notify.Event{Type: EventTransit, Level: level, Title: title, Data: status})
+}
+
+// transitStatus 任务的副本，避免与后台执行并发读写
+func transitStatus(job *transitJob) transitJob {
+	transitMu.Lock()
+	defer transitMu.Unlock()
+	j := *job
+	j.Files = append([]string{}, job.Files...)
+	return j
+}
+
+// transitContext 等待对方的时间
+func transitContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Duration(g.Config().GetInt("transit.wait", 10))*time.Minute)
+}
+
+// TransitSend 经公网中转服务器发送共享文件，返回传输码，对方用 b0pass receive --relay <地址> --code <传输码> 或
+// 自己的b0pass(POST /api/transit/receive)接收；数据用传输码派生的密钥加密，中转服务器无法解密
+// POST /api/transit/send {"files":["/files/a.zip"]}
+func TransitSend(r *ghttp.Request) {
+	relay := transitRelay(r)
+	var req struct {
+		Files []string `json:"files"`
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil || len(req.Files) == 0 {
+		response.Error(r, http.StatusBadRequest, 201, `请求体须为JSON: {"files":["/files/a.zip"]}`)
+	}
+	files := make([]string, 0, len(req.Files))
+	for _, f := range req.Files {
+		file := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+f), "/files"))
+		if info, err := os.Stat(file); file == "" || err != nil || !info.Mode().IsRegular() {
+			response.Error(r, http.StatusNotFound, 201, "文件不存在: "+f)
+		}
+		files = append(files, file)
+	}
+	job := newTransitJob(r, "send", relay, transit.NewCode())
+	go runTransitSend(job, files, boot.ClientName(r.Request), r.UserAgent())
+	response.JSON(r, 0, "ok", transitStatus(job))
+}
+
+// runTransitSend 连接中转服务器，配对后依次发送
+func runTransitSend(job *transitJob, files []string, name, agent string) {
+	ctx, cancel := transitContext()
+	defer cancel()
+	conn, err := transit.Dial(ctx, job.Relay, job.Code)
+	if err != nil {
+		job.finish(err)
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	job.update(func(j *transitJob) { j.State = transitTransferring })
+	s, _ := transit.Sender(conn, job.Code)
+	for _, file := range files {
+		start := time.Now()
+		n, err := transitSendFile(s, file)
+		if err != nil {
+			job.finish(fmt.Errorf("%s: %v", path.Base(file), err))
+			return
+		}
+		job.update(func(j *transitJob) {
+			j.Files = append(j.Files, fileinfos.FileURL(file))
+			j.Bytes += n
+		})
+		t := history.NewTransfer(audit.OpDownload, fileinfos.FileURL(file), n, start, time.Now())
+		t.Device, t.Name, t.Agent = job.client, name, agent
+		_ = boot.TransferLog.Add(t)
+	}
+	job.finish(s.Close())
+}
+
+func transitSendFile(s *transit.Session, file string) (int64, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+	info, err := fh.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return s.Send(transit.Header{Name: path.Base(file), Size: info.Size()}, fh)
+}
+
+// TransitReceive 用对方给出的传输码经公网中转服务器接收文件，保存到共享目录下的path，同名时自动重命名
+// POST /api/transit/receive {"code":"K7QF-2MZP-XW4D-9RTA-C3JH","path":"inbox"}
+func TransitReceive(r *ghttp.Request) {
+	relay := transitRelay(r)
+	var req struct {
+		Code string `json:"code"`
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(r.GetRaw(), &req); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, `请求体须为JSON: {"code":"传输码","path":"保存目录"}`)
+	}
+	if _, err := transit.Channel(req.Code); err != nil {
+		response.Error(r, http.StatusBadRequest, 201, "传输码无效")
+	}
+	if boot.Storage.Blocked() {
+		response.Error(r, http.StatusInsufficientStorage, 507, "磁盘空间不足，已停止接收上传")
+	}
+	// 确认保存目录合法，文件名在收到后确定
+	savePathOf(r, req.Path, "upload")
+	job := newTransitJob(r, "receive", relay, strings.ToUpper(strings.TrimSpace(req.Code)))
+	go runTransitReceive(job, req.Path, ClientKey(r), boot.ClientName(r.Request), r.UserAgent())
+	response.JSON(r, 0, "ok", transitStatus(job))
+}
+
+// runTransitReceive 连接中转服务器，配对后逐个接收，完整收到的文件与上传完成一样记录元数据、审计日志、传输记录与Webhook
+func runTransitReceive(job *transitJob, dir, owner, name, agent string) {
+	ctx, cancel := transitContext()
+	defer cancel()
+	conn, err := transit.Dial(ctx, job.Relay, job.Code)
+	if err != nil {
+		job.finish(err)
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	job.update(func(j *transitJob) { j.State = transitTransferring })
+	s, _ := transit.Receiver(conn, job.Code)
+	for {
+		start := time.Now()
+		file, n, sums, err := transitReceiveFile(s, dir, owner)
+		if err == io.EOF {
+			job.finish(nil)
+			return
+		}
+		if err != nil {
+			job.finish(err)
+			return
+		}
+		job.update(func(j *transitJob) {
+			j.Files = append(j.Files, fileinfos.FileURL(file))
+			j.Bytes += n
+		})
+		fields := map[string]string{"size": strconv.FormatInt(n, 10), quota.FieldOwner: owner}
+		for algo, sum := range sums {
+			fields[algo] = sum
+		}
+		metadata.Set(fileinfos.FileKey(file), fields)
+		recordChunks(file, n)
+		audit.Record(audit.Entry{Op: audit.OpUpload, Path: fileinfos.FileURL(file), Size: n, Client: owner, Agent: agent})
+		t := history.NewTransfer(audit.OpUpload, fileinfos.FileURL(file), n, start, time.Now())
+		t.Device, t.Name, t.Agent = job.client, name, agent
+		_ = boot.TransferLog.Add(t)
+		clientEvent(owner, EventUpload, file, n, sums[hashes.Default])
+		scanUpload(file)
+		replicate(file)
+	}
+}
+
+// transitReceiveFile 写入暂存文件，完整接收后再移动到目标位置
+func transitReceiveFile(s *transit.Session, dir, owner string) (string, int64, map[string]string, error) {
+	var tmp *os.File
+	var target string
+	multi, _ := hashes.NewMulti(hashes.Default)
+	defer func() {
+		if tmp != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	_, n, err := s.Receive(func(h transit.Header) (io.Writer, error) {
+		name := path.Base(strings.Replace(h.Name, "\\", "/", -1))
+		if name == "." || name == "/" || name == ".." {
+			name = "upload"
+		}
+		target = fileinfos.FilePath(dir + "/" + name)
+		if target == "" || fileinfos.Roots().IsRoot(target) {
+			return nil, errors.New("invalid path")
+		}
+		if msg := quotaMessage(boot.Quota(owner, uploadTmpDir()).Check(h.Size)); h.Size > 0 && msg != "" {
+			return nil, errors.New(msg)
+		}
+		var err error
+		if tmp, err = fileinfos.CreateTemp(rootTmpDir(target), name); err != nil {
+			return nil, err
+		}
+		return io.MultiWriter(tmp, multi), nil
+	})
+	if err != nil {
+		return "", n, nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", n, nil, err
+	}
+	savePath := fileinfos.UniqueName(target)
+	if err := fileinfos.Commit(tmp.Name(), savePath); err != nil {
+		return "", n, nil, err
+	}
+	return savePath, n, multi.Sums(), nil
+}
+
+// TransitStatus 当前设备发起的公网中转任务 /api/transit?id=，不指定id时按开始时间从新到旧返回全部
+func TransitStatus(r *ghttp.Request) {
+	id, client := r.GetQueryString("id"), clientID(r)
+	transitMu.Lock()
+	var jobs []*transitJob
+	for _, j := range transitJobs {
+		if j.client == client && (id == "" || j.ID == id) {
+			jobs = append(jobs, j)
+		}
+	}
+	transitMu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Started.After(jobs[j].Started) })
+	if id != "" {
+		if len(jobs) == 0 {
+			response.Error(r, http.StatusNotFound, 201, "任务不存在")
+		}
+		response.JSON(r, 0, "ok", transitStatus(jobs[0]))
+	}
+	ret := make([]transitJob, 0, len(jobs))
+	for _, j := range jobs {
+		ret = append(ret, transitStatus(j))
+	}
+	response.JSON(r, 0, "ok", ret)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/fileinfos"
+	"b0pass/library/response"
+	"b0pass/library/trash"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// findTrash 在各共享根目录的回收站中查找该项
+func findTrash(id string) (trash.Bin, trash.Item, bool) {
+	for _, b := range boot.TrashBins() {
+		if item, err := b.Get(id); err == nil {
+			return b, item, true
+		}
+	}
+	return trash.Bin{}, trash.Item{}, false
+}
+
+// protocolAllowed WebDAV、FTP、S3、gRPC能否访问文件: 按符号链接与挂载点策略，暂存目录、回收站等内部目录视为不存在
+func protocolAllowed(file string) bool {
+	return fileinfos.Allowed(file) && !boot.Internal(file)
+}
+
+// protocolRemove WebDAV、FTP、S3的删除与/api/delete一致，trash.enabled时移入回收站
+func protocolRemove(file string) error {
+	if !g.Config().GetBool("trash.enabled", true) {
+		return os.RemoveAll(file)
+	}
+	_, err := boot.TrashBin(file).Move(file, "/"+fileinfos.FileKey(file), "delete")
+	return err
+}
+
+// TrashList 回收站中的项，按删除时间从新到旧排序，expires为按trash.days彻底删除的时间
+func TrashList(r *ghttp.Request) {
+	days := g.Config().GetInt("trash.days", 30)
+	var items []trash.Item
+	for _, b := range boot.TrashBins() {
+		list, err := b.List()
+		if err != nil {
+			response.Error(r, http.StatusInternalServerError, 201, err.Error())
+		}
+		items = append(items, list...)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Deleted.After(items[j].Deleted) })
+	var size int64
+	ret := make([]g.Map, 0, len(items))
+	for _, item := range items {
+		size += item.Size
+		m := g.Map{"id": item.ID, "path": item.Path, "name": path.Base(item.Path), "size": item.Size, "dir": item.Dir,
+			"deleted": item.Deleted, "reason": item.Reason}
+		if days > 0 {
+			m["expires"] = item.Deleted.AddDate(0, 0, days)
+		}
+		ret = append(ret, m)
+	}
+	response.JSON(r, 0, "ok", g.Map{"count": len(ret), "size": size, "items": ret})
+}
+
+// TrashRestore 将回收站中的项还原到原路径，to为新的位置(如 /files/docs/a(1).txt)；目标已存在时返回409
+func TrashRestore(r *ghttp.Request) {
+	b, item, ok := findTrash(r.GetString("id"))
+	if !ok {
+		response.Error(r, http.StatusNotFound, 201, "回收站中没有该项")
+	}
+	rel := item.Path
+	if to := r.GetString("to"); to != "" {
+		rel = strings.TrimPrefix(path.Clean("/"+to), "/files")
+	}
+	dest := fileinfos.FilePath(rel)
+	if dest == "" || fileinfos.Roots().IsRoot(dest) {
+		response.Error(r, http.StatusBadRequest, 201, "还原位置不在共享目录下")
+	}
+	if err := b.Restore(item, dest); err == trash.ErrExist {
+		response.Error(r, http.StatusConflict, 201, "目标位置已有同名文件，请用to参数指定新的位置")
+	} else if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	auditLog(r, audit.OpRestore, dest, item.Size)
+	response.JSON(r, 0, "ok", fileinfos.FileURL(dest))
+}
+
+// TrashPurge 彻底删除回收站中的项，不指定id时清空全部回收站
+func TrashPurge(r *ghttp.Request) {
+	var purged []trash.Item
+	if id := r.GetString("id"); id != "" {
+		b, item, ok := findTrash(id)
+		if !ok {
+			response.Error(r, http.StatusNotFound, 201, "回收站中没有该项")
+		}
+		if err := b.Purge(item); err != nil {
+			response.Error(r, http.StatusInternalServerError, 201, err.Error())
+		}
+		purged = append(purged, item)
+	} else {
+		for _, b := range boot.TrashBins() {
+			list, err := b.Empty()
+			purged = append(purged, list...)
+			if err != nil {
+				response.Error(r, http.StatusInternalServerError, 201, err.Error())
+			}
+		}
+	}
+	var size int64
+	for _, item := range purged {
+		size += item.Size
+		audit.Record(audit.Entry{Op: audit.OpPurge, Path: item.Path, Size: item.Size, Client: r.GetClientIp(), Agent: r.UserAgent()})
+	}
+	response.JSON(r, 0, "ok", g.Map{"count": len(purged), "size": size})
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/diskusage"
+	"b0pass/library/fileinfos"
+	"b0pass/library/fsync"
+	"b0pass/library/hashes"
+	"b0pass/library/tus"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/gogf/gf/os/gfile"
+	"github.com/gogf/gf/util/gconv"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Tus tus 1.0断点续传协议，uppy(Tus插件)、tus-js-client等上传组件可直接使用
+// /api/tus/*
+// Upload-Metadata: filename(或name)文件名，path子目录，可选algo/hash校验整个文件，mtime修改时间，conflict同名文件处理方式
+func Tus(r *ghttp.Request) {
+	if boot.Storage.Blocked() && (r.Method == http.MethodPost || r.Method == http.MethodPatch) {
+		r.Response.Header().Set("Tus-Resumable", tus.Version)
+		r.Response.WriteStatus(http.StatusInsufficientStorage, "磁盘空间不足，已停止接收上传")
+		return
+	}
+	if r.Method == http.MethodPost {
+		if msg := quotaError(r, gconv.Int64(r.Header.Get("Upload-Length"))); msg != "" {
+			r.Response.Header().Set("Tus-Resumable", tus.Version)
+			r.Response.WriteStatus(http.StatusInsufficientStorage, msg)
+			return
+		}
+	}
+	// 同步策略由每个请求的durability参数或X-Durability头指定
+	policy, err := uploadDurability(r)
+	if err != nil {
+		r.Response.Header().Set("Tus-Resumable", tus.Version)
+		r.Response.WriteStatus(http.StatusBadRequest, err.Error())
+		return
+	}
+	h := &tus.Handler{
+		Prefix:  boot.BasePath + "/api/tus",
+		TempDir: filepath.Join(uploadTmpDir(), "tus"),
+		OnComplete: func(info *tus.Info, part string) error {
+			return tusComplete(r, info, part, policy)
+		},
+		SyncChunks: policy == fsync.PerChunk,
+		Preallocate: func(f *os.File, size int64) error {
+			if err := diskusage.Preallocate(f, size); err != nil {
+				return &tus.Error{Status: http.StatusInsufficientStorage, Msg: "磁盘空间不足"}
+			}
+			return nil
+		},
+	}
+	// 剩余配额(含可用磁盘空间)与上传大小限制中较小的作为单个上传的大小上限
+	if remaining := boot.Quota(ClientKey(r), uploadTmpDir()).Remaining; remaining > 0 {
+		h.MaxSize = remaining
+	}
+	if max := boot.UploadLimit(); max > 0 && (h.MaxSize == 0 || max < h.MaxSize) {
+		h.MaxSize = max
+	}
+	h.ServeHTTP(r.Response.Writer, r.Request)
+}
+
+// tusComplete tus上传完成: 校验哈希并移动到目标位置
+func tusComplete(r *ghttp.Request, info *tus.Info, part, policy string) error {
+	name := gfile.Basename(info.Meta["filename"])
+	if name == "" || name == "." {
+		name = gfile.Basename(info.Meta["name"])
+	}
+	if name == "" || name == "." {
+		return &tus.Error{Status: http.StatusBadRequest, Msg: "filename is required in Upload-Metadata"}
+	}
+	algo, ok := allowedAlgo(info.Meta["algo"])
+	if !ok {
+		return &tus.Error{Status: http.StatusBadRequest, Msg: "unsupported hash algorithm: " + algo}
+	}
+	sums, err := hashes.FileSums(part, hashes.Default, algo)
+	if err != nil {
+		return err
+	}
+	if hash := strings.ToLower(info.Meta["hash"]); hash != "" && hash != sums[algo] {
+		return &tus.Error{Status: tus.StatusChecksumMismatch, Msg: "hash mismatch, upload discarded"}
+	}
+	pathSub := info.Meta["path"]
+	fileinfos.Set("data_path", pathSub)
+	name = cleanName(name)
+	savePath := fileinfos.FilePath(pathSub + "/" + name)
+	if savePath == "" || fileinfos.Roots().IsRoot(savePath) {
+		return &tus.Error{Status: http.StatusBadRequest, Msg: errNoRoot()}
+	}
+	conflict, err := uploadConflict(r)
+	if c := info.Meta["conflict"]; c != "" {
+		conflict, err = fileinfos.ParseConflict(c, "")
+	}
+	if err != nil {
+		return &tus.Error{Status: http.StatusBadRequest, Msg: err.Error()}
+	}
+	switch savePath, err = fileinfos.ResolveConflict(savePath, conflict); err {
+	case nil:
+	case fileinfos.ErrSkipped:
+		// 保留已有文件，上传记录照常删除
+		return nil
+	case fileinfos.ErrExist:
+		return &tus.Error{Status: http.StatusConflict, Msg: "file already exists: " + name}
+	default:
+		return err
+	}
+	if err := commitUpload(policy, part, savePath); err != nil {
+		return err
+	}
+	if err := verifyFile(savePath, sums); err != nil {
+		return &tus.Error{Status: tus.StatusChecksumMismatch, Msg: err.Error()}
+	}
+	if mtime := gconv.Int64(info.Meta["mtime"]); mtime > 0 {
+		t := time.Unix(mtime, 0)
+		_ = os.Chtimes(savePath, t, t)
+	}
+	markUpload(savePath, info.Created)
+	finishUpload(r, savePath, info.Size, sums)
+	return nil
+}
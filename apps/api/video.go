@@ -0,0 +1,120 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/bufpool"
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/response"
+	"b0pass/library/transcode"
+	"bytes"
+	"context"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/gogf/gf/util/gconv"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Stream 视频在线播放，浏览器能直接播放的按Range输出原文件，
+// 其余(如HEVC、mkv)启用转码时由ffmpeg实时转封装或转码为分片MP4，start为起始秒数(转码时拖动进度用)
+// /api/stream?path=/files/手机/a.mov&start=120
+func Stream(r *ghttp.Request) {
+	file := videoFile(r)
+	info := probeVideo(file)
+	if info.Mode == transcode.Direct || r.GetQueryString("mode") == transcode.Direct {
+		// mov与mp4封装相同，按mp4声明浏览器才会尝试播放
+		if strings.EqualFold(filepath.Ext(file), ".mov") {
+			r.Response.Header().Set("Content-Type", "video/mp4")
+		}
+		r.Response.ServeFile(file)
+		return
+	}
+	ffmpeg, vargs := boot.FFmpeg()
+	release, ok := boot.Transcodes.Acquire("", 2*time.Second)
+	if !ok {
+		response.Error(r, http.StatusServiceUnavailable, 201, "同时转码的视频数已达到stream.max")
+	}
+	defer release()
+	start := gconv.Float64(r.GetQueryString("start"))
+	if start < 0 || info.Duration > 0 && start >= info.Duration {
+		response.Error(r, http.StatusRequestedRangeNotSatisfiable, 201, "start超出视频时长")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, transcode.Args(info.Mode, file, start, info, vargs)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		response.Error(r, http.StatusInternalServerError, 201, err.Error())
+	}
+	h := r.Response.Header()
+	h.Set("Content-Type", "video/mp4")
+	h.Set("Accept-Ranges", "none")
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Stream-Mode", info.Mode)
+	w, done := chunkedStream(r, http.StatusOK)
+	_, err = bufpool.Copy(w, out)
+	if err != nil {
+		// 客户端断开(关闭页面或拖动进度)时结束ffmpeg
+		cancel()
+	}
+	werr := cmd.Wait()
+	if err == nil && werr != nil {
+		logger.Warn("stream", info.Mode, "path", fileinfos.FileKey(file), "err", werr, "stderr", strings.TrimSpace(stderr.String()))
+	}
+	done(err == nil && werr == nil)
+}
+
+// StreamInfo 视频编码、时长与播放方式，播放器据此决定是否按start参数拖动进度
+// /api/stream/info?path=/files/手机/a.mov
+func StreamInfo(r *ghttp.Request) {
+	file := videoFile(r)
+	info := probeVideo(file)
+	response.JSON(r, 0, "ok", g.Map{
+		"video":    info.Video,
+		"audio":    info.Audio,
+		"width":    info.Width,
+		"height":   info.Height,
+		"duration": info.Duration,
+		"mode":     info.Mode,
+		"url":      boot.BasePath + "/api/stream?path=" + url.QueryEscape(r.GetQueryString("path")),
+	})
+}
+
+// videoFile 请求的视频文件，不存在时返回404
+func videoFile(r *ghttp.Request) string {
+	file := fileinfos.FilePath(strings.TrimPrefix(path.Clean("/"+r.GetQueryString("path")), "/files"))
+	st, err := os.Stat(file)
+	if file == "" || err != nil || st.IsDir() {
+		response.Error(r, http.StatusNotFound, 201, "文件不存在")
+	}
+	return file
+}
+
+// probeVideo 探测播放方式，未启用转码或探测失败时直接播放
+func probeVideo(file string) transcode.Info {
+	if boot.Prober == nil {
+		return transcode.Info{Mode: transcode.Direct}
+	}
+	info, err := boot.Prober.Probe(file)
+	if err != nil {
+		logger.Debug("stream", "probe", "path", fileinfos.FileKey(file), "err", err)
+		return transcode.Info{Mode: transcode.Direct}
+	}
+	if info.Video == "" {
+		// 纯音频文件浏览器一般能直接播放
+		info.Mode = transcode.Direct
+	}
+	return info
+}
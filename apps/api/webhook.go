@@ -0,0 +1,45 @@
+package api
+
+import (
+	"b0pass/boot"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hookscript"
+	"b0pass/library/webhook"
+	"github.com/gogf/gf/net/ghttp"
+)
+
+// 文件事件
+const (
+	EventUpload   = "upload"
+	EventDelete   = "delete"
+	EventDownload = "download" // 只触发钩子脚本
+)
+
+// fileEvent 向Webhook投递文件事件并执行钩子脚本，hash为sha256(未知时为空)
+func fileEvent(r *ghttp.Request, event, file string, size int64, hash string) {
+	clientEvent(ClientKey(r), event, file, size, hash)
+}
+
+// clientEvent 同fileEvent，用于请求结束后的后台任务
+func clientEvent(client, event, file string, size int64, hash string) {
+	boot.Webhooks.Send(webhook.Event{
+		Event:  event,
+		Path:   fileinfos.FileURL(file),
+		Size:   size,
+		Hash:   hash,
+		Client: client,
+	})
+	runScript(client, event, file, size, hash)
+}
+
+// runScript 执行钩子目录下的 on-<event> 脚本(存在时)
+func runScript(client, event, file string, size int64, hash string) {
+	boot.Scripts.Run(hookscript.Event{
+		Event:  event,
+		File:   file,
+		Path:   fileinfos.FileURL(file),
+		Size:   size,
+		Hash:   hash,
+		Client: client,
+	})
+}
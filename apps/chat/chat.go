@@ -2,8 +2,9 @@ package chat
 
 import (
 	"fmt"
-	"log"
 
+	"b0pass/boot"
+	"b0pass/library/logger"
 	"github.com/gogf/gf/container/garray"
 	"github.com/gogf/gf/container/gmap"
 	"github.com/gogf/gf/container/gset"
@@ -12,7 +13,6 @@ import (
 	"github.com/gogf/gf/frame/gmvc"
 	"github.com/gogf/gf/net/ghttp"
 	"github.com/gogf/gf/os/gcache"
-	"github.com/gogf/gf/os/glog"
 	"github.com/gogf/gf/util/gconv"
 	"github.com/gogf/gf/util/gvalid"
 )
@@ -48,7 +48,7 @@ var (
 
 // Index 聊天室首页，只显示模板内容
 func (c *Controller) Index() {
-	log.Println(c.Session.Id())
+	logger.Debug("chat", "session", "id", c.Session.Id())
 	if !c.Session.Contains("chat_name") {
 		_ = c.Session.Set("chat_name", c.Session.Id())
 	}
@@ -71,7 +71,7 @@ func (c *Controller) SetName() {
 		_ = c.Session.Set("chat_name", name)
 		_ = c.Session.Remove("chat_name_temp")
 		_ = c.Session.Remove("chat_name_error")
-		c.Response.RedirectTo("/chat")
+		c.Response.RedirectTo(boot.BasePath + "/chat")
 	}
 }
 
@@ -83,7 +83,7 @@ func (c *Controller) WebSocket() {
 	if ws, err := c.Request.WebSocket(); err == nil {
 		c.ws = ws
 	} else {
-		glog.Error(err)
+		logger.Error("chat", "websocket", "err", err)
 		return
 	}
 
@@ -123,7 +123,7 @@ func (c *Controller) WebSocket() {
 		msg.From = name
 
 		// 日志记录
-		glog.Cat("chat").Println(msg)
+		logger.Debug("chat", "message", "type", msg.Type, "from", msg.From)
 
 		// WS操作类型
 		switch msg.Type {
@@ -141,7 +141,7 @@ func (c *Controller) WebSocket() {
 					Msg{"send",
 						ghtml.SpecialChars("【群发】"+gconv.String(msg.Data)),
 						ghtml.SpecialChars(msg.From)}); err != nil {
-					glog.Error(err)
+					logger.Error("chat", "broadcast", "err", err)
 				}
 			}
 		}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"b0pass/boot"
+	"b0pass/library/actions"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Actions 列出服务端提供的文件操作，与Web界面的菜单一致
+func Actions(args []string) int {
+	fs := flag.NewFlagSet("actions", flag.ContinueOnError)
+	to := fs.String("to", "127.0.0.1:"+strconv.Itoa(boot.ServPort), "b0pass address (host:port or URL)")
+	rest, err := parseArgs(fs, args)
+	if err != nil || len(rest) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: b0pass actions </files/path> [--to host:port]")
+		return 2
+	}
+	base := *to
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	f := rest[0]
+	if !strings.HasPrefix(f, "/files") {
+		f = "/files/" + strings.TrimPrefix(f, "/")
+	}
+	resp, err := http.Get(strings.TrimSuffix(base, "/") + "/api/actions?f=" + url.QueryEscape(f))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Actions] ERR:", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	var ret struct {
+		Err  int    `json:"err"`
+		Msg  string `json:"msg"`
+		Data struct {
+			Path    string           `json:"path"`
+			Actions []actions.Action `json:"actions"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		fmt.Fprintln(os.Stderr, "[Actions] ERR:", resp.Status)
+		return 1
+	}
+	if ret.Err != 0 {
+		fmt.Fprintln(os.Stderr, "[Actions] ERR:", ret.Msg)
+		return 1
+	}
+	fmt.Println(ret.Data.Path)
+	for _, a := range ret.Data.Actions {
+		line := fmt.Sprintf("  %-8s %-6s %-4s %s", a.ID, a.Title, a.Method, a.URL)
+		if len(a.Caps) > 0 {
+			line += "  [" + strings.Join(a.Caps, ",") + "]"
+		}
+		if !a.Enabled {
+			line += "  (" + a.Reason + ")"
+		}
+		fmt.Println(line)
+	}
+	return 0
+}
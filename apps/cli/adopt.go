@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"b0pass/boot"
+	"b0pass/library/adopt"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adoptJob 收录任务的进度
+type adoptJob struct {
+	ID       string         `json:"id"`
+	Total    int            `json:"total"`
+	Done     int            `json:"done"`
+	Failed   int            `json:"failed"`
+	Finished bool           `json:"finished"`
+	Results  []adopt.Result `json:"results"`
+}
+
+// Adopt 将本机已有的文件或目录收录到运行中的b0pass的共享目录，无需经过上传
+// 由服务端执行(服务端维护元数据)，因此须在主电脑上运行
+func Adopt(args []string) int {
+	fs := flag.NewFlagSet("adopt", flag.ContinueOnError)
+	to := fs.String("to", "127.0.0.1:"+strconv.Itoa(boot.ServPort), "b0pass address on this computer")
+	dir := fs.String("path", "", "destination sub directory")
+	mode := fs.String("mode", adopt.ModeMove, "move, link or copy")
+	overwrite := fs.Bool("overwrite", false, "overwrite existing files")
+	rest, err := parseArgs(fs, args)
+	if err != nil || len(rest) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: b0pass adopt <files or dirs...> [--path sub] [--mode move|link|copy] [--overwrite]")
+		return 2
+	}
+	for i, p := range rest {
+		if rest[i], err = filepath.Abs(p); err != nil {
+			fmt.Fprintln(os.Stderr, "[Adopt] ERR:", err)
+			return 2
+		}
+	}
+	base := *to
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	base = strings.TrimSuffix(base, "/") + "/api/adopt"
+	body, _ := json.Marshal(map[string]interface{}{"src": rest, "path": *dir, "mode": *mode, "overwrite": *overwrite})
+	var job adoptJob
+	if err := adoptCall(http.MethodPost, base, body, &job); err != nil {
+		fmt.Fprintln(os.Stderr, "[Adopt] ERR:", err)
+		return 1
+	}
+	printed := 0
+	for {
+		for ; printed < len(job.Results); printed++ {
+			r := job.Results[printed]
+			if r.Err != "" {
+				fmt.Printf("[Adopt] %d/%d FAIL %s: %s\n", printed+1, job.Total, r.Src, r.Err)
+			} else {
+				fmt.Printf("[Adopt] %d/%d %s %s -> %s\n", printed+1, job.Total, r.Method, r.Src, r.Dst)
+			}
+		}
+		if job.Finished {
+			break
+		}
+		time.Sleep(time.Second)
+		if err := adoptCall(http.MethodGet, base+"?id="+url.QueryEscape(job.ID), nil, &job); err != nil {
+			fmt.Fprintln(os.Stderr, "[Adopt] ERR:", err)
+			return 1
+		}
+	}
+	if job.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// adoptCall 调用收录接口
+func adoptCall(method, u string, body []byte, job *adoptJob) error {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var ret struct {
+		Err  int      `json:"err"`
+		Msg  string   `json:"msg"`
+		Data adoptJob `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	if ret.Err != 0 {
+		return fmt.Errorf("%s", ret.Msg)
+	}
+	*job = ret.Data
+	return nil
+}
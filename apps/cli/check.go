@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"b0pass/boot"
+	"b0pass/library/archive"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/humanize"
+	"b0pass/library/retention"
+	"b0pass/library/roots"
+	"b0pass/library/scan"
+	"b0pass/library/webhook"
+	"crypto/tls"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 检查结果
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+)
+
+// checkReport 自检报告
+type checkReport struct {
+	failed bool
+}
+
+// add 输出一项检查结果
+func (c *checkReport) add(status, name, format string, args ...interface{}) {
+	if status == checkFail {
+		c.failed = true
+	}
+	fmt.Printf("[%s] %-10s %s\n", status, name, fmt.Sprintf(format, args...))
+}
+
+// Check 启动前自检: 配置、共享目录权限、端口、TLS证书与外部工具，有FAIL项时退出码为1
+func Check(args []string) int {
+	c := &checkReport{}
+	checkConfig(c)
+	checkDirs(c)
+	checkLimits(c)
+	checkPorts(c)
+	checkTLS(c)
+	checkTools(c)
+	if c.failed {
+		fmt.Println("check failed")
+		return 1
+	}
+	fmt.Println("check passed")
+	return 0
+}
+
+// checkConfig 配置来源与取值
+func checkConfig(c *checkReport) {
+	cfg := g.Config()
+	if cfg.FilePath() == "" && len(boot.ConfigSources) <= 1 {
+		c.add(checkFail, "config", "config/config.toml not found under %s", boot.PathRoot)
+	} else {
+		c.add(checkPass, "config", "sources: %s", strings.Join(boot.ConfigSources, " < "))
+	}
+	if p := cfg.GetInt("setting.port"); p <= 0 || p > 65535 {
+		c.add(checkFail, "config", "setting.port %d out of range", p)
+	}
+	oneOf := func(key, def string, allowed ...string) {
+		v := cfg.GetString(key, def)
+		for _, a := range allowed {
+			if v == a {
+				return
+			}
+		}
+		c.add(checkFail, "config", "%s = %q, expect one of %s", key, v, strings.Join(allowed, ", "))
+	}
+	oneOf("setting.qrcode", boot.QRUnicode, boot.QRUnicode, boot.QRANSI, boot.QROff)
+	oneOf("download.changed", "abort", "off", "abort", "snapshot")
+	oneOf("archive.format", archive.FormatZip, archive.FormatZip, archive.FormatTar, archive.FormatTarGz, archive.FormatTarZst)
+	oneOf("log.level", "info", "debug", "info", "warn", "error")
+	oneOf("log.format", "console", "console", "json")
+	oneOf("setting.locale", humanize.ZH, humanize.ZH, humanize.EN)
+	for _, algo := range cfg.GetStrings("upload.hashes") {
+		if _, err := hashes.New(algo); err != nil {
+			c.add(checkFail, "config", "upload.hashes: %v", err)
+		}
+	}
+	if warn, block := cfg.GetInt("storage.warn", 90), cfg.GetInt("storage.block", 97); warn > block {
+		c.add(checkWarn, "config", "storage.warn(%d) is above storage.block(%d), no warning before uploads are refused", warn, block)
+	}
+	if _, err := webhook.Parse(cfg.GetArray("webhooks")); err != nil {
+		c.add(checkFail, "config", "webhooks: %v", err)
+	}
+	if _, err := retention.Parse(cfg.GetArray("retention")); err != nil {
+		c.add(checkFail, "config", "%v", err)
+	}
+	if cfg.GetString("auth.user") != "" && cfg.GetString("auth.password") == "" {
+		c.add(checkWarn, "config", "auth.user is set but auth.password is empty")
+	}
+	if _, errs := boot.BuildAuth(); len(errs) > 0 {
+		for _, err := range errs {
+			c.add(checkFail, "config", "auth: %v", err)
+		}
+	}
+	if raw := cfg.GetString("mirror.url"); raw != "" {
+		if _, err := url.Parse(raw); err != nil {
+			c.add(checkFail, "config", "mirror.url: %v", err)
+		}
+	}
+}
+
+// checkDirs 各共享根目录、暂存目录与数据目录是否可写，暂存文件能否rename到共享目录
+func checkDirs(c *checkReport) {
+	if _, err := roots.Parse(g.Config().GetArray("roots"), boot.PathRoot); err != nil {
+		c.add(checkFail, "roots", "%v, using %s", err, fileinfos.FilesRoot())
+	}
+	for _, root := range fileinfos.Roots() {
+		checkRoot(c, root)
+	}
+	data := boot.PathRoot + "/tmp/data"
+	err := os.MkdirAll(data, 0755)
+	if err == nil {
+		err = ioutil.WriteFile(data+"/.check", nil, 0644)
+		_ = os.Remove(data + "/.check")
+	}
+	if err != nil {
+		c.add(checkFail, "data", "%s is not writable: %v", data, err)
+		return
+	}
+	c.add(checkPass, "data", "%s is writable", data)
+}
+
+// checkRoot 根目录是否存在且可写(只读模式下仅提示)
+func checkRoot(c *checkReport, root roots.Root) {
+	status := checkFail
+	if g.Config().GetBool("auth.readonly") {
+		status = checkWarn
+	}
+	if root.Path == boot.PathRoot+"/files" {
+		_ = os.MkdirAll(root.Path, 0755)
+	}
+	if info, err := os.Stat(root.Path); err != nil || !info.IsDir() {
+		c.add(checkFail, "files", "%s: %s is not a directory", root.Name, root.Path)
+		return
+	}
+	tmpDir := fileinfos.TempDir(root.Path, g.Config().GetString("upload.tmpdir"))
+	f, err := fileinfos.CreateTemp(tmpDir, "check")
+	if err != nil {
+		c.add(status, "files", "%s: upload temp dir %s is not writable: %v", root.Name, tmpDir, err)
+		return
+	}
+	_ = f.Close()
+	dst := filepath.Join(root.Path, ".b0pass-check")
+	if err := os.Rename(f.Name(), dst); err != nil {
+		_ = os.Remove(f.Name())
+		c.add(checkWarn, "files", "%s: cannot rename %s into %s(different filesystem?), uploads will be copied: %v", root.Name, tmpDir, root.Path, err)
+		return
+	}
+	_ = os.Remove(dst)
+	c.add(checkPass, "files", "%s: %s is writable", root.Name, root.Path)
+}
+
+// checkLimits 文件句柄上限
+func checkLimits(c *checkReport) {
+	limit, _ := boot.FileLimit()["limit"].(uint64)
+	switch {
+	case limit == 0:
+		c.add(checkSkip, "ulimit", "file limit unknown on this platform")
+	case limit < 1024:
+		c.add(checkWarn, "ulimit", "file limit(ulimit -n) is %d, zipping large folders will be slow", limit)
+	default:
+		c.add(checkPass, "ulimit", "file limit(ulimit -n) is %d", limit)
+	}
+}
+
+// checkPorts 各服务端口是否可监听
+func checkPorts(c *checkReport) {
+	cfg := g.Config()
+	ports := []struct {
+		name string
+		port int
+	}{
+		{"http", boot.ServPort},
+		{"ftp", orInt(boot.FTPPort, cfg.GetInt("ftp.port"))},
+		{"s3", orInt(boot.S3Port, cfg.GetInt("s3.port"))},
+		{"grpc", orInt(boot.GRPCPort, cfg.GetInt("grpc.port"))},
+	}
+	for _, p := range ports {
+		if p.port <= 0 {
+			c.add(checkSkip, "port", "%s disabled", p.name)
+			continue
+		}
+		ln, err := net.Listen("tcp", ":"+strconv.Itoa(p.port))
+		if err != nil {
+			c.add(checkFail, "port", "%s port %d: %v", p.name, p.port, err)
+			continue
+		}
+		_ = ln.Close()
+		c.add(checkPass, "port", "%s port %d is available", p.name, p.port)
+	}
+}
+
+// checkTLS 证书与私钥能否加载
+func checkTLS(c *checkReport) {
+	cert, key := g.Config().GetString("ftp.cert"), g.Config().GetString("ftp.key")
+	if cert == "" && key == "" {
+		c.add(checkSkip, "tls", "no certificate configured")
+		return
+	}
+	if cert == "" || key == "" {
+		c.add(checkFail, "tls", "ftp.cert and ftp.key must both be set")
+		return
+	}
+	if _, err := tls.LoadX509KeyPair(cert, key); err != nil {
+		c.add(checkFail, "tls", "ftp: %v", err)
+		return
+	}
+	c.add(checkPass, "tls", "ftp certificate %s loaded", cert)
+}
+
+// checkTools 外部工具: ffmpeg(视频缩略图与转码)、zstd(tar.zst打包)、ssh(SSH隧道)、病毒扫描命令(clamd)
+func checkTools(c *checkReport) {
+	if p, err := exec.LookPath("ffmpeg"); err != nil {
+		c.add(checkWarn, "ffmpeg", "not found in PATH, video thumbnails and transcoding are unavailable")
+	} else {
+		c.add(checkPass, "ffmpeg", "%s", p)
+	}
+	if p, err := exec.LookPath(archive.ZstdCommand); err != nil {
+		status := checkWarn
+		if g.Config().GetString("archive.format") == archive.FormatTarZst {
+			status = checkFail
+		}
+		c.add(status, "zstd", "not found in PATH, tar.zst archives are unavailable")
+	} else {
+		c.add(checkPass, "zstd", "%s", p)
+	}
+	if g.Config().GetString("tunnel.mode") == "ssh" {
+		if p, err := exec.LookPath("ssh"); err != nil {
+			c.add(checkFail, "ssh", "not found in PATH, tunnel.mode = ssh is unavailable")
+		} else {
+			c.add(checkPass, "ssh", "%s", p)
+		}
+	}
+	if !g.Config().GetBool("scan.enable") {
+		c.add(checkSkip, "scan", "virus scan disabled")
+		return
+	}
+	cmdline := g.Config().GetString("scan.command")
+	if strings.TrimSpace(cmdline) == "" {
+		c.add(checkFail, "scan", "scan.command is empty")
+		return
+	}
+	cmd := scan.NewCommand(cmdline)
+	if _, err := exec.LookPath(cmd.Args[0]); err != nil {
+		c.add(checkFail, "scan", "%s not found in PATH", cmd.Args[0])
+		return
+	}
+	// 扫描一个空文件，可发现clamd未运行等问题
+	f, err := ioutil.TempFile("", "b0pass-check")
+	if err != nil {
+		c.add(checkWarn, "scan", "%v", err)
+		return
+	}
+	_ = f.Close()
+	defer func() { _ = os.Remove(f.Name()) }()
+	if res := cmd.Scan(f.Name()); res.Status != scan.StatusClean {
+		c.add(checkFail, "scan", "test scan returned %s: %s", res.Status, res.Detail)
+		return
+	}
+	c.add(checkPass, "scan", "%s is working", cmd.Args[0])
+}
+
+// orInt a大于0时返回a，否则返回b
+func orInt(a, b int) int {
+	if a > 0 {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"b0pass/boot"
+	"b0pass/library/ipaddress"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// 命令行子命令，用法: b0pass [-p port] <command> [args...]
+
+// commands 已注册的子命令
+var commands = map[string]struct {
+	run   func(args []string) int
+	usage string
+}{
+	"send":          {Send, "send <files...> [--to host:port | --relay host:port]  发送文件，对方下载完成后退出"},
+	"actions":       {Actions, "actions </files/path> [--to host:port]  列出文件或目录的可用操作"},
+	"adopt":         {Adopt, "adopt <files or dirs...> [--path sub] [--mode move|link|copy]  将本机已有的文件收录到共享目录(需服务运行)"},
+	"check":         {Check, "check                             检查配置、目录权限、端口、证书与外部工具"},
+	"loadtest":      {Loadtest, "loadtest [--to host:port] [-c 8] [-n 100] [--size 1M] [--mode upload|download|mixed]  并发上传下载压测，输出延迟分位数与吞吐量"},
+	"history":       {History, "history export [--format csv|json] [--from date] [--to date] [-o file]  导出传输记录"},
+	"receive":       {Receive, "receive [--dir path] [--once] [--relay host:port --code CODE]  接收文件，--once时收到一次上传后退出"},
+	"relay-server":  {RelayServer, "relay-server [--listen :9009] [--wait 10]  公网中转服务器，为不同网络中的两端配对并转发加密数据"},
+	"tunnel-server": {TunnelServer, "tunnel-server [--listen :9010] [--ports 20000-20099] [--token T] [--host example.com]  公网隧道服务器，为tunnel.mode=ws的b0pass分配公网端口并转发访问"},
+}
+
+// Run 执行子命令，返回进程退出码
+func Run(name string, args []string) int {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", name)
+		Usage()
+		return 2
+	}
+	return cmd.run(args)
+}
+
+// Usage 输出子命令列表
+func Usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "Usage: b0pass [-p port] <command> [args...]")
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  "+commands[name].usage)
+	}
+}
+
+// parseArgs 解析参数，允许选项与位置参数混排
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return rest, nil
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// printURL 输出各局域网地址及第一个地址的二维码
+func printURL(port int, path string) {
+	ips, _ := ipaddress.GetIP()
+	if len(ips) == 0 {
+		ips = []string{"127.0.0.1"}
+	}
+	for _, ip := range ips {
+		fmt.Printf("[ServerUrl] http://%s:%d%s\n", ip, port, path)
+	}
+	boot.PrintQR(boot.AccessURL(port, path))
+}
+
+// listen 监听服务端口，端口被占用(如b0pass正在运行)时使用随机端口
+func listen() (net.Listener, error) {
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(boot.ServPort))
+	if err != nil {
+		ln, err = net.Listen("tcp", ":0")
+	}
+	return ln, err
+}
+
+// newPrefix 随机路径前缀，临时服务只响应该前缀下的请求
+func newPrefix() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "/" + hex.EncodeToString(b) + "/"
+}
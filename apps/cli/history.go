@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"b0pass/boot"
+	"b0pass/library/audit"
+	"b0pass/library/history"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// History 传输记录，目前只有export: 从本机审计日志和设备注册表导出报表，无需服务运行
+func History(args []string) int {
+	if len(args) == 0 || args[0] != "export" {
+		fmt.Fprintln(os.Stderr, "Usage: b0pass history export [--format csv|json] [--from date] [--to date] [-o file]")
+		return 2
+	}
+	fs := flag.NewFlagSet("history export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "csv or json")
+	fromArg := fs.String("from", "", "start time: 2006-01-02, 2006-01 or RFC3339")
+	toArg := fs.String("to", "", "end time(inclusive)")
+	out := fs.String("o", "", "output file(default stdout)")
+	if _, err := parseArgs(fs, args[1:]); err != nil {
+		return 2
+	}
+	from, err := history.ParseTime(*fromArg, false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[History] ERR:", err)
+		return 2
+	}
+	to, err := history.ParseTime(*toArg, true)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[History] ERR:", err)
+		return 2
+	}
+	rows := history.Collect(audit.Query("", 0), boot.Devices.List(), from, to)
+	if err := writeHistory(*out, *format, rows); err != nil {
+		fmt.Fprintln(os.Stderr, "[History] ERR:", err)
+		return 1
+	}
+	return 0
+}
+
+// writeHistory 输出到文件或标准输出
+func writeHistory(file, format string, rows []history.Row) error {
+	if file == "" {
+		return history.Write(os.Stdout, format, rows)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := history.Write(f, format, rows); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"b0pass/boot"
+	"b0pass/library/humanize"
+	"b0pass/library/loadtest"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Loadtest 对运行中的b0pass发起并发的合成上传/下载，输出各类操作的延迟分位数与吞吐量，
+// 用于评估硬件能承受的并发传输及发现性能回退；测试文件写入--dir，结束后删除(--keep保留)
+func Loadtest(args []string) int {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	to := fs.String("to", "127.0.0.1:"+strconv.Itoa(boot.ServPort), "target b0pass (host:port or URL, may include user:pass@)")
+	workers := fs.Int("c", 8, "concurrent transfers")
+	count := fs.Int("n", 100, "total transfers, 0 for unlimited (use --duration)")
+	duration := fs.Duration("duration", 0, "stop after this long, e.g. 30s")
+	size := fs.String("size", "1M", "file size per transfer, e.g. 64K, 10M")
+	mode := fs.String("mode", "mixed", "upload, download or mixed")
+	dir := fs.String("dir", "/b0pass-loadtest", "remote directory for test files")
+	keep := fs.Bool("keep", false, "keep uploaded test files")
+	asJSON := fs.Bool("json", false, "print reports as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return 2
+	}
+	n, err := humanize.ParseSize(*size)
+	if err != nil || *mode != "upload" && *mode != "download" && *mode != "mixed" || *count <= 0 && *duration <= 0 {
+		fmt.Fprintln(os.Stderr, "usage: b0pass loadtest [--to host:port] [-c 8] [-n 100] [--duration 30s] [--size 1M] [--mode upload|download|mixed] [--keep] [--json]")
+		return 2
+	}
+	base := *to
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	target, err := url.Parse(strings.TrimSuffix(base, "/"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Loadtest] ERR:", err)
+		return 2
+	}
+	lt := &loadTester{
+		base:   target,
+		dir:    path.Clean("/" + *dir),
+		size:   n,
+		client: &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, MaxIdleConnsPerHost: *workers}},
+	}
+	if *mode != "upload" {
+		// 下载使用预先上传的文件，不计入统计
+		if lt.seed, err = lt.upload(-1, 0); err != nil {
+			fmt.Fprintln(os.Stderr, "[Loadtest] ERR: seed file:", err)
+			return 1
+		}
+	}
+	if !*keep {
+		defer lt.cleanup()
+	}
+	if !*asJSON {
+		fmt.Printf("[Loadtest] %s %s x%d, %d workers -> %s\n", *mode, humanize.Size(n, humanize.EN), *count, *workers, target.Host)
+	}
+	reports := loadtest.Run(loadtest.Options{Workers: *workers, Count: *count, Duration: *duration}, func(worker, seq int) loadtest.Result {
+		if *mode == "download" || *mode == "mixed" && seq%2 == 1 {
+			size, err := lt.download()
+			return loadtest.Result{Kind: "download", Bytes: size, Err: err}
+		}
+		_, err := lt.upload(worker, seq)
+		return loadtest.Result{Kind: "upload", Bytes: n, Err: err}
+	})
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(reports)
+	} else {
+		for _, r := range reports {
+			printReport(r)
+		}
+	}
+	for _, r := range reports {
+		if r.Errors > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// printReport 输出一类操作的统计
+func printReport(r loadtest.Report) {
+	ms := func(d time.Duration) string { return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64) + "ms" }
+	fmt.Printf("[Loadtest] %-8s ops=%d errors=%d %s in %s  %.1f ops/s  %s/s\n",
+		r.Kind, r.Ops, r.Errors, humanize.Size(r.Bytes, humanize.EN), r.Elapsed.Round(time.Millisecond),
+		r.OpsPerSec, humanize.Size(int64(r.BytesPerSec), humanize.EN))
+	fmt.Printf("[Loadtest] %-8s latency mean=%s p50=%s p90=%s p99=%s max=%s\n",
+		r.Kind, ms(r.Mean), ms(r.P50), ms(r.P90), ms(r.P99), ms(r.Max))
+	if r.FirstErr != "" {
+		fmt.Printf("[Loadtest] %-8s first error: %s\n", r.Kind, r.FirstErr)
+	}
+}
+
+// loadTester 压测使用的地址与测试文件
+type loadTester struct {
+	base   *url.URL
+	dir    string
+	size   int64
+	seed   string // 下载使用的文件(相对共享目录)
+	client *http.Client
+}
+
+// url 目标服务上的地址
+func (lt *loadTester) url(p string, query url.Values) string {
+	u := *lt.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + p
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// upload 通过 PUT /up 上传合成数据，返回服务端保存的相对路径
+func (lt *loadTester) upload(worker, seq int) (string, error) {
+	name := fmt.Sprintf("w%d-%d.bin", worker, seq)
+	if worker < 0 {
+		name = "seed.bin"
+	}
+	req, err := http.NewRequest(http.MethodPut, lt.url(path.Join("/up", lt.dir, name), nil), loadtest.Data(lt.size, int64(seq)))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = lt.size
+	req.Header.Set("Accept", "application/json")
+	resp, err := lt.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var ret struct {
+		Err  int      `json:"err"`
+		Msg  string   `json:"msg"`
+		Data []string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	if ret.Err != 0 || len(ret.Data) == 0 {
+		return "", fmt.Errorf("%s %s", resp.Status, ret.Msg)
+	}
+	return ret.Data[0], nil
+}
+
+// download 通过 GET /f 下载测试文件，返回读取的字节数
+func (lt *loadTester) download() (int64, error) {
+	resp, err := lt.client.Get(lt.url(path.Join("/f", lt.seed), nil))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s", resp.Status)
+	}
+	n, err := io.Copy(ioutil.Discard, resp.Body)
+	if err == nil && n != lt.size {
+		err = fmt.Errorf("short download: %d of %d bytes", n, lt.size)
+	}
+	return n, err
+}
+
+// cleanup 删除测试目录
+func (lt *loadTester) cleanup() {
+	resp, err := lt.client.Get(lt.url("/api/delete", url.Values{"f": {"/files" + lt.dir}}))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Loadtest] cleanup:", err)
+		return
+	}
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"b0pass/library/bufpool"
+	"b0pass/library/fileinfos"
+	"b0pass/ui"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Receive 接收文件(投递模式)
+// 在本机启动只能上传的临时服务，输出地址和二维码，文件保存到--dir；--once时第一次上传成功后退出；
+// 指定--relay与--code时经公网中转服务器接收发送方的文件
+func Receive(args []string) int {
+	fs := flag.NewFlagSet("receive", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "directory to save received files")
+	once := fs.Bool("once", false, "exit after the first successful upload")
+	relay := fs.String("relay", "", "receive through a transit relay server (host:port)")
+	code := fs.String("code", "", "transfer code printed by the sender")
+	if _, err := parseArgs(fs, args); err != nil {
+		return 2
+	}
+	if (*relay == "") != (*code == "") {
+		fmt.Fprintln(os.Stderr, "usage: b0pass receive --relay host:port --code CODE [--dir path]")
+		return 2
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, "[Receive] ERR:", err)
+		return 1
+	}
+	if *relay != "" {
+		return transitReceive(*relay, *code, *dir)
+	}
+	root, _ := filepath.Abs(*dir)
+	tmpDir := fileinfos.TempDir(root, g.Config().GetString("upload.tmpdir"))
+	prefix := newPrefix()
+	received := make(chan struct{}, 1)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// curl -T file <url> 会把文件名追加到路径
+		if !strings.HasPrefix(r.URL.Path+"/", prefix) {
+			http.NotFound(w, r)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		switch r.Method {
+		case http.MethodGet:
+			if len(r.URL.Path) > len(prefix) {
+				http.NotFound(w, r)
+				return
+			}
+			page, err := ui.FS.ReadFile("public/page/inbox.html")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write(page)
+			return
+		case http.MethodPost, http.MethodPut:
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		names, err := receiveFiles(r, root, tmpDir, name)
+		if err == nil && len(names) == 0 {
+			err = fmt.Errorf("no file uploaded")
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "[Receive] ERR:", err)
+			receiveReply(w, r, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		for _, name := range names {
+			fmt.Printf("[Receive] %s (%s)\n", filepath.Join(root, name), r.RemoteAddr)
+		}
+		receiveReply(w, r, http.StatusOK, strings.Join(names, "\n"), names)
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+	})
+
+	ln, err := listen()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Receive] ERR:", err)
+		return 1
+	}
+	srv := &http.Server{Handler: handler}
+	go func() { _ = srv.Serve(ln) }()
+	printURL(ln.Addr().(*net.TCPAddr).Port, prefix)
+	fmt.Printf("[Receive] 等待上传，文件保存到 %s\n", root)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	for done := false; !done; {
+		select {
+		case <-received:
+			done = *once
+		case <-interrupt:
+			done = true
+		}
+	}
+	_ = srv.Shutdown(context.Background())
+	// 暂存目录为空时一并删除
+	_ = os.Remove(tmpDir)
+	fmt.Println("[Receive] 退出")
+	return 0
+}
+
+// receiveFiles 保存请求中的文件(multipart或PUT原始数据)，同名时自动重命名，返回保存的文件名
+func receiveFiles(r *http.Request, root, tmpDir, name string) ([]string, error) {
+	var names []string
+	if mr, err := r.MultipartReader(); err == nil {
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return names, nil
+			}
+			if err != nil {
+				return names, err
+			}
+			if part.FileName() == "" {
+				continue
+			}
+			name, err := receiveFile(root, tmpDir, part.FileName(), part)
+			if err != nil {
+				return names, err
+			}
+			names = append(names, name)
+		}
+	}
+	if q := r.URL.Query().Get("name"); q != "" {
+		name = q
+	}
+	if name == "" {
+		name = "upload"
+	}
+	name, err := receiveFile(root, tmpDir, name, r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return []string{name}, nil
+}
+
+func receiveFile(root, tmpDir, name string, body io.Reader) (string, error) {
+	name = safeName(name)
+	tmp, err := fileinfos.CreateTemp(tmpDir, name)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := bufpool.Copy(tmp, body); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	dst := fileinfos.UniqueName(filepath.Join(root, name))
+	if err := fileinfos.Commit(tmp.Name(), dst); err != nil {
+		return "", err
+	}
+	return filepath.Base(dst), nil
+}
+
+// safeName 去掉发送方给出的路径，只保留文件名
+func safeName(name string) string {
+	name = filepath.Base(strings.Replace(name, "\\", "/", -1))
+	if name == "." || name == "/" || name == ".." {
+		name = "upload"
+	}
+	return name
+}
+
+// receiveReply 与/in/<id>相同的响应格式: Accept包含application/json时返回JSON
+func receiveReply(w http.ResponseWriter, r *http.Request, status int, msg string, data interface{}) {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		code := 0
+		if status != http.StatusOK {
+			code = 201
+		}
+		if code == 0 {
+			msg = "ok"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"err": code, "msg": msg, "data": data})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, msg+"\n")
+}
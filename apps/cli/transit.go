@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/transit"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// RelayServer 公网中转服务器: 部署在双方都能访问的主机上，只配对和转发加密后的数据
+func RelayServer(args []string) int {
+	fs := flag.NewFlagSet("relay-server", flag.ContinueOnError)
+	addr := fs.String("listen", ":9009", "listen address")
+	wait := fs.Int("wait", 10, "minutes to wait for the other side")
+	if _, err := parseArgs(fs, args); err != nil {
+		return 2
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Relay] ERR:", err)
+		return 1
+	}
+	s := transit.NewServer(time.Duration(*wait) * time.Minute)
+	s.Log = func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) }
+	fmt.Printf("[Relay] listening on %s\n", ln.Addr())
+	go func() {
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		<-interrupt
+		_ = ln.Close()
+	}()
+	_ = s.Serve(ln)
+	fmt.Println("[Relay] 退出")
+	return 0
+}
+
+// transitContext 等待对方的时间，Ctrl+C时取消
+func transitContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(g.Config().GetInt("transit.wait", 10))*time.Minute)
+	go func() {
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// transitSend 经中转服务器发送文件，输出传输码后等待对方接收
+func transitSend(relay string, files []string) int {
+	code := transit.NewCode()
+	fmt.Printf("[Send] 传输码: %s\n", code)
+	fmt.Printf("[Send] 对方运行: b0pass receive --relay %s --code %s\n", relay, code)
+	ctx, cancel := transitContext()
+	defer cancel()
+	conn, err := transit.Dial(ctx, relay, code)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Send] ERR:", err)
+		return 1
+	}
+	defer func() { _ = conn.Close() }()
+	s, _ := transit.Sender(conn, code)
+	for _, f := range files {
+		if err := transitSendFile(s, f); err != nil {
+			fmt.Fprintf(os.Stderr, "[Send] ERR: %s: %v\n", f, err)
+			return 1
+		}
+	}
+	if err := s.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "[Send] ERR:", err)
+		return 1
+	}
+	return 0
+}
+
+func transitSendFile(s *transit.Session, file string) error {
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	info, err := fh.Stat()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	n, err := s.Send(transit.Header{Name: filepath.Base(file), Size: info.Size()}, fh)
+	if err == nil {
+		fmt.Printf("[Send] %s %d bytes in %s\n", file, n, time.Since(start).Round(time.Millisecond))
+	}
+	return err
+}
+
+// transitReceive 经中转服务器接收文件，保存到dir，同名时自动重命名
+func transitReceive(relay, code, dir string) int {
+	if _, err := transit.Channel(code); err != nil {
+		fmt.Fprintln(os.Stderr, "[Receive] ERR:", err)
+		return 2
+	}
+	root, _ := filepath.Abs(dir)
+	tmpDir := fileinfos.TempDir(root, g.Config().GetString("upload.tmpdir"))
+	defer func() { _ = os.Remove(tmpDir) }()
+	ctx, cancel := transitContext()
+	defer cancel()
+	conn, err := transit.Dial(ctx, relay, code)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Receive] ERR:", err)
+		return 1
+	}
+	defer func() { _ = conn.Close() }()
+	s, _ := transit.Receiver(conn, code)
+	for {
+		name, err := transitReceiveFile(s, root, tmpDir)
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "[Receive] ERR:", err)
+			return 1
+		}
+		fmt.Printf("[Receive] %s (%s)\n", filepath.Join(root, name), relay)
+	}
+}
+
+// transitReceiveFile 先写入暂存文件，完整接收后再移入目标目录
+func transitReceiveFile(s *transit.Session, root, tmpDir string) (string, error) {
+	var tmp *os.File
+	defer func() {
+		if tmp != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	h, _, err := s.Receive(func(h transit.Header) (io.Writer, error) {
+		var err error
+		tmp, err = fileinfos.CreateTemp(tmpDir, safeName(h.Name))
+		return tmp, err
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	dst := fileinfos.UniqueName(filepath.Join(root, safeName(h.Name)))
+	if err := fileinfos.Commit(tmp.Name(), dst); err != nil {
+		return "", err
+	}
+	return filepath.Base(dst), nil
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"b0pass/library/tunnel"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// TunnelServer 公网隧道服务器: 部署在有公网地址的主机上，为每个连接的b0pass分配一个公网端口并转发访问
+func TunnelServer(args []string) int {
+	fs := flag.NewFlagSet("tunnel-server", flag.ContinueOnError)
+	addr := fs.String("listen", ":9010", "listen address for tunnel clients")
+	ports := fs.String("ports", "20000-20099", "public port range assigned to clients")
+	token := fs.String("token", "", "token required from tunnel clients(default=none)")
+	host := fs.String("host", "", "public host name in tunnel URLs(default=host the client connected to)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return 2
+	}
+	min, max, err := portRange(*ports)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Tunnel] ERR:", err)
+		return 2
+	}
+	if *token == "" {
+		fmt.Fprintln(os.Stderr, "[Tunnel] 警告: 未设置--token，任何人都可以注册隧道")
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Tunnel] ERR:", err)
+		return 1
+	}
+	s := tunnel.NewServer(*token, min, max)
+	s.Host = *host
+	s.Log = func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) }
+	fmt.Printf("[Tunnel] listening on %s, public ports %d-%d\n", ln.Addr(), min, max)
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		<-interrupt
+		_ = srv.Close()
+	}()
+	_ = srv.Serve(ln)
+	fmt.Println("[Tunnel] 退出")
+	return 0
+}
+
+// portRange 解析端口范围，如 20000-20099 或单个端口
+func portRange(s string) (int, int, error) {
+	parts := strings.SplitN(s, "-", 2)
+	min, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	max := min
+	if err == nil && len(parts) == 2 {
+		max, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+	}
+	if err != nil || min <= 0 || max < min || max > 65535 {
+		return 0, 0, fmt.Errorf("invalid port range: %s", s)
+	}
+	return min, max, nil
+}
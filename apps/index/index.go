@@ -28,18 +28,21 @@ func (c *Controller) FileLists() {
 	}
 	c.View.Assign("ips",ips)
 	// path
-	pathRoot := fileinfos.GetRootPath() + "/files/"
+	pathRoot := fileinfos.FilesRoot() + "/"
 	c.View.Assign("path_root", pathRoot)
 	// file lists
 	fprPath:=c.Request.GetString("path")
-	var fpPath string
-	if fprPath!="" {
-		fpPath="/files"+fprPath+"/*"
-	}else{
-		fpPath="/files/*"
+	// 隐藏规则，all=1显示全部，exclude通配符有误时忽略
+	rules, _ := boot.Hidden(c.Request.Request)
+	flists := fileinfos.ListPath(fprPath,fprPath,rules)
+	fileinfos.Localize(flists, boot.Locale(c.Request.Request), time.Now())
+	// 主电脑上的文件路径(多根目录时各根目录不在同一位置)
+	for _, f := range flists {
+		f["local"] = fileinfos.FilePath(f["path"])
+		if f["link"] != "" {
+			f["local"] = fileinfos.FilePath(f["link"])
+		}
 	}
-	fp := fileinfos.GetRootPath() + fpPath
-	flists := fileinfos.ListDirData(fp,fprPath)
 	c.View.Assign("flists",flists)
 	// views
 	_ = c.View.Display("file-lists.html")
@@ -1,11 +1,15 @@
 package sync
 
 import (
+	"b0pass/boot"
+	"b0pass/library/logger"
+	"b0pass/library/notify"
 	"github.com/gogf/gf/container/gmap"
 	"github.com/gogf/gf/container/gset"
+	"github.com/gogf/gf/encoding/gjson"
+	"github.com/gogf/gf/frame/g"
 	"github.com/gogf/gf/frame/gmvc"
 	"github.com/gogf/gf/net/ghttp"
-	"github.com/gogf/gf/os/glog"
 )
 
 
@@ -19,6 +23,10 @@ var (
 	names = gset.NewStrSet()
 )
 
+func init() {
+	notify.Register("sync", notify.ChannelFunc(notifyUsers))
+}
+
 // Index 触发页面
 // /sync/
 func (c *Controller) Index() {
@@ -36,7 +44,7 @@ func (c *Controller) WebSocket() {
 	if ws, err := c.Request.WebSocket(); err == nil {
 		c.ws = ws
 	} else {
-		glog.Error(err)
+		logger.Error("sync", "websocket", "err", err)
 		return
 	}
 
@@ -45,27 +53,26 @@ func (c *Controller) WebSocket() {
 	if clientId=="" {
 		_ = c.Session.Set("clientId", c.Session.Id())
 	}
+	// 在线列表显示设备名称(见boot.ClientName)
+	name := boot.ClientName(c.Request.Request)
 	users.Set(c.ws, clientId)
-	names.Add(clientId)
+	names.Add(name)
 
 	for {
 		// 阻塞读取WS数据
 		msgType, msg, err  := c.ws.ReadMessage()
 		if err != nil {
 			users.Remove(c.ws)
-			names.Remove(clientId)
+			names.Remove(name)
 			break
 		}
 
 		// 群发同步所有端
-		glog.Cat("sync").Println("[sync] ",clientId,msg)
+		logger.Debug("sync", "message", "client", clientId, "size", len(msg))
 		_ = c.writeUsers()
 		if msg != nil {
-			msgs:= "{" +
-					"\"clientId\":\""+clientId+"\"," +
-					"\"msg\":\""+string(msg)+"\""+
-				"}"
-			_ = c.writeGroup(msgType,msgs)
+			msgs, _ := gjson.Encode(g.Map{"clientId": clientId, "name": name, "msg": string(msg)})
+			_ = c.writeGroup(msgType,string(msgs))
 		}
 	}
 }
@@ -100,4 +107,21 @@ func (c *Controller) writeUsers() error {
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// notifyUsers 向所有端推送通知消息
+func notifyUsers(e notify.Event) error {
+	msgs, err := gjson.Encode(g.Map{
+		"clientId": "notify",
+		"msg":      e,
+	})
+	if err != nil {
+		return err
+	}
+	users.RLockFunc(func(m map[interface{}]interface{}) {
+		for user := range m {
+			_ = user.(*ghttp.WebSocket).WriteMessage(ghttp.WS_MSG_TEXT, msgs)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,130 @@
+package boot
+
+import (
+	"b0pass/library/acme"
+	"b0pass/library/logger"
+	"crypto/tls"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Certs 自动申请的HTTPS证书，未配置acme.domain时为nil
+var Certs *acme.Manager
+
+// HTTPSPort 启用自动证书时的HTTPS端口
+var HTTPSPort int
+
+// ACMEChallengePath http-01域名验证的路径前缀，不加路径前缀、不需要登录、不跳转到HTTPS
+const ACMEChallengePath = "/.well-known/acme-challenge/"
+
+// initACME 配置了域名时创建证书管理并读取缓存的证书
+func initACME() {
+	c := g.Config()
+	var domains []string
+	for _, d := range strings.Split(c.GetString("acme.domain"), ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	if len(domains) == 0 {
+		return
+	}
+	challenge := c.GetString("acme.challenge", acme.ChallengeHTTP)
+	if challenge != acme.ChallengeHTTP && challenge != acme.ChallengeALPN {
+		logger.Error("acme", "config", "challenge", challenge, "err", "须为http-01或tls-alpn-01")
+		return
+	}
+	m := acme.NewManager(domains, c.GetString("acme.email"), c.GetString("acme.directory"), PathRoot+"/tmp/acme",
+		challenge, time.Duration(c.GetInt("acme.renew", 30))*24*time.Hour)
+	if err := m.Load(); err != nil {
+		logger.Error("acme", "load certificate", "err", err)
+	}
+	HTTPSPort = c.GetInt("acme.port", 443)
+	Certs = m
+}
+
+// serveHTTPS 在HTTPSPort上启用HTTPS(可协商HTTP/2)，证书在服务启动后申请并在到期前续期；
+// setting.port与acme.http照常提供HTTP，其中访问证书域名的请求跳转到HTTPS
+func serveHTTPS(s *ghttp.Server) {
+	if Certs == nil {
+		return
+	}
+	// 客户端未发送SNI时使用临时证书完成握手
+	fallback, err := acme.SelfSigned(Certs.Domains[0])
+	if err != nil {
+		logger.Error("acme", "self-signed", "err", err)
+		return
+	}
+	s.SetTLSConfig(tls.Config{
+		Certificates:   []tls.Certificate{fallback},
+		GetCertificate: Certs.GetCertificate,
+		NextProtos:     nextProtos(acme.ALPNProto),
+		ClientAuth:     clientAuth(),
+	})
+	s.SetHTTPSPort(HTTPSPort)
+	startHTTP3(s, &tls.Config{
+		Certificates:   []tls.Certificate{fallback},
+		GetCertificate: Certs.GetCertificate,
+		ClientAuth:     clientAuth(),
+	})
+	if p := g.Config().GetInt("acme.http", 80); p > 0 && p != ServPort {
+		s.SetPort(ServPort, p)
+	}
+
+	stop := make(chan struct{})
+	OnShutdown(func() { close(stop) })
+	go func() {
+		// 域名验证需要服务已在监听
+		for s.Status() != ghttp.SERVER_STATUS_RUNNING {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if !Certs.NeedRenew() {
+			logger.Info("acme", "certificate", "domain", Certs.Domains[0], "expires", Certs.Expires().Format(time.RFC3339))
+		}
+		Certs.Run(stop, func(err error) {
+			logger.Error("acme", "renew", "domain", Certs.Domains[0], "err", err)
+		})
+	}()
+}
+
+// HTTPSURL 启用自动证书时的HTTPS访问地址，否则为空
+func HTTPSURL() string {
+	if Certs == nil {
+		return ""
+	}
+	host := Certs.Domains[0]
+	if HTTPSPort != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(HTTPSPort))
+	}
+	return "https://" + host + BasePath + "/"
+}
+
+// RedirectHTTPS 经HTTP访问证书域名时跳转到的HTTPS地址，无需跳转时为空
+func RedirectHTTPS(r *http.Request, scheme string) string {
+	if Certs == nil || scheme == "https" || strings.HasPrefix(r.URL.Path, ACMEChallengePath) {
+		return ""
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, d := range Certs.Domains {
+		if d == host {
+			if HTTPSPort != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(HTTPSPort))
+			}
+			uri := r.RequestURI
+			if uri == "" {
+				uri = r.URL.RequestURI()
+			}
+			return "https://" + host + uri
+		}
+	}
+	return ""
+}
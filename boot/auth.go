@@ -0,0 +1,124 @@
+package boot
+
+import (
+	"b0pass/library/authn"
+	"b0pass/library/logger"
+	"crypto/rand"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// AuthProviders 支持的认证方式
+var AuthProviders = []string{"basic", "pin", "token", "mtls", "ldap", "oidc"}
+
+var (
+	auth atomic.Value
+	// pinSecret PIN会话Cookie的签名密钥，重启后需重新输入PIN
+	pinSecret = make([]byte, 32)
+)
+
+// initAuth 按[auth]配置组合认证方式，运行中修改设置后重新加载
+func initAuth() {
+	_, _ = rand.Read(pinSecret)
+	loadAuth()
+	OnSettings(loadAuth)
+}
+
+// loadAuth 重新组合认证方式，配置有误的方式跳过
+func loadAuth() {
+	chain, errs := BuildAuth()
+	for _, err := range errs {
+		logger.Error("auth", "provider", "err", err)
+	}
+	auth.Store(chain)
+}
+
+// Auth 当前的认证方式，为空时不启用认证
+func Auth() authn.Chain {
+	chain, _ := auth.Load().(authn.Chain)
+	return chain
+}
+
+// BuildAuth 按auth.providers的顺序创建认证方式，未配置时设置了auth.user则为basic；
+// 启用认证时已配对移动设备的访问令牌始终有效
+func BuildAuth() (authn.Chain, []error) {
+	c := g.Config()
+	names := c.GetStrings("auth.providers")
+	if len(names) == 0 && c.GetString("auth.user") != "" {
+		names = []string{"basic"}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	chain := authn.Chain{&authn.Bearer{ID: "device", Role: authn.RoleUser, Verify: func(token string) (string, bool) {
+		if Devices == nil {
+			return "", false
+		}
+		d, ok := Devices.Verify(token)
+		return d.ID, ok
+	}}}
+	var errs []error
+	role := func(name, def string) string {
+		return c.GetString("auth."+name+".role", def)
+	}
+	for _, name := range names {
+		switch name {
+		case "basic":
+			chain = append(chain, &authn.Basic{User: c.GetString("auth.user"), Password: c.GetString("auth.password"), Role: role(name, authn.RoleUser)})
+		case "pin":
+			pin := c.GetString("auth.pin.pin")
+			if pin == "" {
+				errs = append(errs, fmt.Errorf("auth.pin.pin is empty"))
+				continue
+			}
+			chain = append(chain, &authn.PIN{PIN: pin, Role: role(name, authn.RoleGuest), Secret: pinSecret,
+				MaxAge: time.Duration(c.GetInt("auth.pin.hours", 12)) * time.Hour})
+		case "token":
+			tokens := make(map[string]string)
+			for i, s := range c.GetStrings("auth.token.tokens") {
+				// "名称:令牌" 或仅令牌
+				label, token := fmt.Sprintf("token%d", i+1), s
+				if p := strings.Index(s, ":"); p > 0 {
+					label, token = s[:p], s[p+1:]
+				}
+				if token != "" {
+					tokens[token] = label
+				}
+			}
+			if len(tokens) == 0 {
+				errs = append(errs, fmt.Errorf("auth.token.tokens is empty"))
+				continue
+			}
+			chain = append(chain, &authn.Token{Tokens: tokens, Role: role(name, authn.RoleUser)})
+		case "mtls":
+			pool, err := authn.LoadCA(c.GetString("auth.mtls.ca"))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("auth.mtls.ca: %v", err))
+				continue
+			}
+			chain = append(chain, &authn.MTLS{CAs: pool, CNs: c.GetStrings("auth.mtls.cn"), Role: role(name, authn.RoleAdmin)})
+		case "ldap":
+			l := &authn.LDAP{URL: c.GetString("auth.ldap.url"), BindDN: c.GetString("auth.ldap.binddn"), Role: role(name, authn.RoleUser),
+				Timeout: time.Duration(c.GetInt("auth.ldap.timeout", 10)) * time.Second}
+			if l.URL == "" || !strings.Contains(l.BindDN, "%s") {
+				errs = append(errs, fmt.Errorf("auth.ldap.url and auth.ldap.binddn(with %%s) are required"))
+				continue
+			}
+			chain = append(chain, l)
+		case "oidc":
+			o := &authn.OIDC{Issuer: c.GetString("auth.oidc.issuer"), Audience: c.GetString("auth.oidc.audience"),
+				UserClaim: c.GetString("auth.oidc.claim"), Role: role(name, authn.RoleUser)}
+			if o.Issuer == "" {
+				errs = append(errs, fmt.Errorf("auth.oidc.issuer is empty"))
+				continue
+			}
+			chain = append(chain, o)
+		default:
+			errs = append(errs, fmt.Errorf("unknown auth provider %q, expect one of %s", name, strings.Join(AuthProviders, ", ")))
+		}
+	}
+	return chain, errs
+}
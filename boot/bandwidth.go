@@ -0,0 +1,14 @@
+package boot
+
+import (
+	"b0pass/library/limiter"
+	"github.com/gogf/gf/frame/g"
+)
+
+// Bandwidth 上传带宽限制，同时上传的设备平分总带宽
+var Bandwidth = limiter.NewBandwidth(uploadRate())
+
+// uploadRate 上传总带宽(字节/秒)，0为不限制
+func uploadRate() int64 {
+	return int64(g.Config().GetFloat64("upload.bandwidth") * (1 << 20))
+}
@@ -2,7 +2,8 @@ package boot
 
 import "fmt"
 
-func init() {
+// printBanner 输出启动横幅
+func printBanner() {
 	fmt.Println(`************************************************************
 ------------------------------------------------------------`)
 	fmt.Println("")
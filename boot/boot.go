@@ -1,18 +1,27 @@
 package boot
 
 import (
+	"b0pass/library/audit"
 	"b0pass/library/fileinfos"
+	"b0pass/library/metadata"
+	"b0pass/library/notify"
 	"flag"
+	"fmt"
 	"github.com/gogf/gf/frame/g"
 	"github.com/gogf/gf/net/ghttp"
 	"github.com/gogf/gf/os/gfile"
-	"github.com/gogf/gf/os/glog"
+	"os"
 	"time"
 )
 
 var (
 	PathRoot string
 	ServPort int
+	FTPPort  int
+	S3Port   int
+	GRPCPort int
+	Command  string
+	QRMode   string
 )
 
 func ExecArgs(){
@@ -20,6 +29,23 @@ func ExecArgs(){
 	if ServPort<=0{
 		ServPort=g.Config().GetInt("setting.port")
 	}
+	if QRMode == "" {
+		QRMode = g.Config().GetString("setting.qrcode", QRUnicode)
+	}
+	// 子命令(send等)自行处理服务，不启动附加服务
+	Command = flag.Arg(0)
+	if Command != "" {
+		return
+	}
+	if FTPPort <= 0 {
+		FTPPort = g.Config().GetInt("ftp.port")
+	}
+	if S3Port <= 0 {
+		S3Port = g.Config().GetInt("s3.port")
+	}
+	if GRPCPort <= 0 {
+		GRPCPort = g.Config().GetInt("grpc.port")
+	}
 }
 
 
@@ -27,59 +53,240 @@ func ExecArgs(){
 func init() {
 
 	// 分析CLI参数
-	flag.IntVar(&ServPort,"p",8899,"-p for Server Port(default=8899)")
+	flag.IntVar(&ServPort,"p",0,"-p for Server Port(default=8899)")
+	flag.IntVar(&FTPPort, "ftp-port", 0, "--ftp-port for FTP Server Port(default=0, disabled)")
+	flag.IntVar(&S3Port, "s3-port", 0, "--s3-port for S3-compatible API Port(default=0, disabled)")
+	flag.IntVar(&GRPCPort, "grpc-port", 0, "--grpc-port for gRPC API Port(default=0, disabled)")
+	flag.StringVar(&QRMode, "qr", "", "--qr for terminal QR code: unicode, ansi or off(default=unicode)")
+	flag.BoolVar(&UPnP, "upnp", false, "--upnp to ask the router to forward the port via UPnP or NAT-PMP(default=upnp.enable)")
+	flag.BoolVar(&Dev, "dev", false, "--dev to serve the web UI from ui/ in the source tree and reload pages on change(default=ui.dev)")
+	flag.StringVar(&BasePath, "base-path", "", "--base-path for serving under a reverse proxy subpath, e.g. /b0pass(default=setting.basepath)")
+	flag.StringVar(&MaxUploadSize, "max-upload-size", "", "--max-upload-size for the largest accepted upload, e.g. 20G(default=upload.maxsize)")
+	flag.StringVar(&ConfigFile, "config", "", "--config for extra config file(yaml, toml or json)")
+	flag.BoolVar(&PrintConfig, "print-config", false, "--print-config to print effective settings and exit")
+	flag.Var(&configSets, "set", "--set section.key=value to override a config item(repeatable)")
+	flag.Parse()
+	if err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, "[config] ERR:", err)
+		os.Exit(2)
+	}
+	if PrintConfig {
+		fmt.Print(EffectiveConfig())
+		os.Exit(0)
+	}
 	ExecArgs()
+	// 子命令的输出(如导出报表)可能被重定向，只在服务模式输出横幅
+	if Command == "" {
+		printBanner()
+	}
 
 	// 资源根目录
 	PathRoot = fileinfos.GetRootPath()
 
+	// 日志
+	initLogger()
+
+	// 反向代理的路径前缀与受信任的代理
+	initProxy()
+
+	// HTTPS证书自动申请
+	initACME()
+
+	// 共享根目录
+	initRoots()
+
 	// 恢复文件到缓存
 	fileinfos.Init("data_path","data_text")
+	metadata.Init(PathRoot + "/tmp/data/metadata.json")
+
+	// 审计日志
+	audit.Init(
+		PathRoot+"/"+g.Config().GetString("audit.path", "tmp/audit"),
+		g.Config().GetInt64("audit.maxsize", 10)<<20,
+		g.Config().GetInt("audit.backups", 5),
+	)
+
+	// 通知渠道
+	notify.Enable(g.Config().GetStrings("notify.channels")...)
+
+	// 文件句柄上限
+	initFileLimit()
+
+	// 传输缓冲区池
+	initBuffers()
+
+	// 上传名额
+	initUploadSlots()
+
+	// 传输期间阻止休眠
+	initInhibit()
+
+	// 移动设备注册表
+	initDevices()
+
+	// 设备偏好
+	initPrefs()
+
+	// 界面语言
+	initLocale()
+
+	// 传输记录
+	initHistory()
+
+	// 设备直传
+	initRelays()
+
+	// 端到端加密文件
+	initEncrypted()
+
+	// 受保护目录
+	initProtected()
+
+	// 访问认证
+	initAuth()
+
+	// 收集链接
+	initInboxes()
+
+	// 分享链接
+	initShares()
+
+	// 镜像复制
+	initMirror()
+
+	// 缩略图解码器
+	initThumbs()
+
+	// 文件类型
+	initMime()
+
+	// 视频转码
+	initStream()
 
-	go func() {
-
-		// APP核心引擎
-		v := g.View()
-		c := g.Config()
-		s := g.Server()
-
-		// 加载动作缓冲
-		time.Sleep(3000 * time.Millisecond)
-
-		// 模板引擎配置
-		_ = v.AddPath("template")
-		v.SetDelimiters("${", "}")
-
-		// glog配置
-		logpath := c.GetString("setting.logpath")
-		_ = glog.SetPath(logpath)
-		glog.SetStdoutPrint(true)
-
-		// Web Server配置
-		s.SetIndexFolder(true)
-		s.SetServerRoot("public")
-		s.SetLogPath(logpath)
-		s.SetReadTimeout(3 * 60 * time.Second)
-		s.SetWriteTimeout(3 * 60 * time.Second)
-		s.SetIdleTimeout(3 * 60 * time.Second)
-		s.SetMaxHeaderBytes(32*1024)
-		s.SetNameToUriType(ghttp.URI_TYPE_ALLLOWER)
-		s.SetErrorLogEnabled(true)
-		s.SetAccessLogEnabled(true)
-		s.SetPort(ServPort)
-		s.SetDumpRouteMap(false)
-
-		// 文件根目录
-		filePath := PathRoot + "/files"
-		if !gfile.Exists(filePath) {
-			if err := gfile.Mkdir(filePath); err != nil {
-				panic(err)
-			}
+	// 相册元数据
+	initGallery()
+
+	// 上传回执签名密钥
+	initReceipts()
+
+	// 文件事件Webhook
+	initWebhooks()
+
+	// 文件事件钩子脚本
+	initScripts()
+
+	if Command == "" {
+		go Serve()
+	}
+
+}
+
+// Serve 启动Web服务，阻塞直到服务结束
+func Serve() {
+	// APP核心引擎
+	v := g.View()
+	s := g.Server()
+
+	// 加载动作缓冲
+	time.Sleep(3000 * time.Millisecond)
+
+	// 网页界面(嵌入的资源，开发模式下为源码目录)
+	initUI()
+
+	// 模板引擎配置
+	_ = v.AddPath(UIPath("template"))
+	v.SetDelimiters("${", "}")
+	// 页面中的链接加上路径前缀
+	v.Assign("base", BasePath)
+
+	// Web Server配置
+	s.SetIndexFolder(true)
+	// 有路径前缀时静态文件只在前缀下提供
+	if BasePath != "" {
+		s.AddStaticPath(BasePath, UIPath("public"))
+	} else {
+		s.SetServerRoot(UIPath("public"))
+	}
+	s.SetReadTimeout(3 * 60 * time.Second)
+	s.SetWriteTimeout(3 * 60 * time.Second)
+	// 框架默认在内存中保留1GB表单数据，其余接口的multipart上传超过该值才写入临时文件
+	s.SetFormParsingMemory(8 << 20)
+	s.SetIdleTimeout(3 * 60 * time.Second)
+	s.SetMaxHeaderBytes(32*1024)
+	s.SetNameToUriType(ghttp.URI_TYPE_ALLLOWER)
+	s.SetErrorLogEnabled(true)
+	s.SetAccessLogEnabled(true)
+	s.SetLogHandler(logRequest)
+	s.SetPort(ServPort)
+	s.SetDumpRouteMap(false)
+
+	// 配置了域名时启用HTTPS并自动申请证书，否则使用配置的证书
+	serveHTTPS(s)
+	serveStaticHTTPS(s)
+
+	// 文件根目录(配置的根目录不自动创建，避免在未挂载的U盘挂载点下写入)
+	filePath := PathRoot + "/files"
+	if fileinfos.FilesRoot() == filePath && !gfile.Exists(filePath) {
+		if err := gfile.Mkdir(filePath); err != nil {
+			panic(err)
 		}
-		s.AddStaticPath("/files", filePath)
+	}
+
+	// 磁盘使用监控
+	initStorage()
+	go watchStorage()
+
+	// 文件名搜索索引
+	watchSearch()
+
+	// 文件索引
+	watchIndex()
+
+	// 目录大小
+	watchDirSizes()
+
+	// 恢复重启前保存的运行时状态
+	restoreSnapshot()
+
+	// 临时授权到期撤销
+	go watchGrants()
+
+	// 过期收集链接归档
+	go watchInboxes()
+
+	// 回收站按保留策略清理
+	go watchTrash()
+
+	// 按保留策略自动清理共享目录
+	go watchRetention()
+
+	// 过期传输记录清理
+	go watchHistory()
+
+	// 无人接收的设备直传
+	go watchRelays()
+
+	// 过期的加密文件
+	go watchEncrypted()
+
+	// 自动锁定受保护目录
+	go watchProtected()
+
+	// 网络地址监测
+	watchNetwork()
+
+	// 局域网发现
+	watchDiscovery()
+
+	// 公网隧道
+	watchTunnel()
+
+	// 路由器端口映射
+	watchPortMap()
 
-		// Run Server
-		g.Server().Run()
-	}()
+	// 退出信号
+	go watchSignals()
 
+	// Run Server
+	g.Server().Run()
 }
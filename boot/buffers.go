@@ -0,0 +1,17 @@
+package boot
+
+import (
+	"b0pass/library/bufpool"
+	"github.com/gogf/gf/frame/g"
+)
+
+// initBuffers 按transfer.buffer设置传输缓冲区池，运行中修改设置后重新加载
+func initBuffers() {
+	loadBuffers()
+	OnSettings(loadBuffers)
+}
+
+// loadBuffers 读取缓冲区大小(KB)
+func loadBuffers() {
+	bufpool.SetSize(g.Config().GetInt("transfer.buffer", bufpool.DefaultSize>>10) << 10)
+}
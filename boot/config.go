@@ -0,0 +1,167 @@
+package boot
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/layered"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gf-third/yaml"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/os/gcfg"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 分层配置: config/config.toml(内置默认值) < b0pass.yaml(/etc/b0pass、~/.config/b0pass、~、程序目录、当前目录) < --config < B0PASS_*环境变量 < 命令行参数
+
+var (
+	ConfigFile  string
+	PrintConfig bool
+	// ConfigSources 生效的配置来源，按优先级从低到高
+	ConfigSources []string
+
+	configSets setFlags
+	configMu   sync.Mutex
+)
+
+// flagKeys 命令行参数对应的配置项
+var flagKeys = map[string]string{
+	"p":         "setting.port",
+	"ftp-port":  "ftp.port",
+	"s3-port":   "s3.port",
+	"grpc-port": "grpc.port",
+	"qr":        "setting.qrcode",
+	"upnp":      "upnp.enable",
+	"dev":       "ui.dev",
+}
+
+// setFlags 可重复的 --set section.key=value 参数
+type setFlags []string
+
+func (s *setFlags) String() string { return strings.Join(*s, ",") }
+
+func (s *setFlags) Set(v string) error {
+	if i := strings.IndexByte(v, '='); i <= 0 || !strings.Contains(v[:i], ".") {
+		return fmt.Errorf("expect section.key=value: %s", v)
+	}
+	*s = append(*s, v)
+	return nil
+}
+
+// configDirs 配置文件查找目录: /etc/b0pass、用户目录、程序目录、当前目录
+func configDirs() []string {
+	dirs := layered.Dirs()
+	cwd := dirs[len(dirs)-1]
+	if root := fileinfos.GetRootPath(); root != cwd {
+		dirs = append(dirs[:len(dirs)-1], root, cwd)
+	}
+	return dirs
+}
+
+// loadConfig 合并各层配置，有覆盖时替换默认配置内容；运行中修改设置后重新调用
+// --config指定的文件无法读取时返回错误，其他配置文件有误时跳过
+func loadConfig() error {
+	merged := make(map[string]interface{})
+	// 默认配置直接读取文件，不受之前替换的内容影响
+	if file := g.Config().FilePath(); file != "" {
+		if m, err := layered.Load(file); err == nil {
+			merged = m
+		} else {
+			fmt.Fprintln(os.Stderr, "[config] ERR:", err)
+		}
+	}
+	ConfigSources = []string{"config/config.toml"}
+
+	files := layered.Find(configDirs())
+	if ConfigFile != "" {
+		files = append(files, ConfigFile)
+	}
+	for _, file := range files {
+		m, err := layered.Load(file)
+		if err != nil {
+			if file == ConfigFile {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "[config] ERR:", err)
+			continue
+		}
+		layered.Merge(merged, m)
+		ConfigSources = append(ConfigSources, file)
+	}
+
+	if env := layered.Env("B0PASS", os.Environ()); len(env) > 0 {
+		layered.Merge(merged, env)
+		ConfigSources = append(ConfigSources, "env")
+	}
+
+	flags := make(map[string]interface{})
+	flag.Visit(func(f *flag.Flag) {
+		if key, ok := flagKeys[f.Name]; ok {
+			layered.Set(flags, key, layered.Value(f.Value.String()))
+		}
+	})
+	for _, kv := range configSets {
+		i := strings.IndexByte(kv, '=')
+		layered.Set(flags, kv[:i], layered.Value(kv[i+1:]))
+	}
+	if len(flags) > 0 {
+		layered.Merge(merged, flags)
+		ConfigSources = append(ConfigSources, "flags")
+	}
+
+	name := g.Config().GetFileName()
+	if len(ConfigSources) == 1 && gcfg.GetContent(name) == "" {
+		return nil
+	}
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	gcfg.SetContent(string(b), name)
+	g.Config().Clear()
+	return nil
+}
+
+// SettingsFile 运行中修改的设置写入的配置文件: --config指定的文件，否则为程序目录下的b0pass.yaml
+func SettingsFile() string {
+	if ConfigFile != "" {
+		return ConfigFile
+	}
+	return filepath.Join(fileinfos.GetRootPath(), "b0pass.yaml")
+}
+
+// SaveSettings 将设置(section.key为键)写入配置文件，重新加载配置并应用到已启动的组件
+func SaveSettings(values map[string]interface{}) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	file := SettingsFile()
+	m := make(map[string]interface{})
+	if _, err := os.Stat(file); err == nil {
+		if m, err = layered.Load(file); err != nil {
+			return err
+		}
+	}
+	for key, v := range values {
+		layered.Set(m, key, v)
+	}
+	if err := layered.Save(file, m); err != nil {
+		return err
+	}
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	applySettings()
+	return nil
+}
+
+// EffectiveConfig 生效配置(YAML)，可直接保存为b0pass.yaml
+func EffectiveConfig() string {
+	b, err := yaml.Marshal(g.Config().ToMap())
+	if err != nil {
+		return "# " + err.Error() + "\n"
+	}
+	return "# sources: " + strings.Join(ConfigSources, " < ") + "\n" + string(b)
+}
@@ -0,0 +1,48 @@
+package boot
+
+import (
+	"b0pass/library/authn"
+	"b0pass/library/device"
+	"github.com/gogf/gf/frame/g"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Devices 已配对的移动设备
+var Devices *device.Registry
+
+// Clients 访问过的设备及其名称
+var Clients *device.Clients
+
+// initDevices 加载设备注册表
+func initDevices() {
+	Devices = device.Open(PathRoot + "/tmp/data/devices.json")
+	if h := g.Config().GetInt("device.accessttl", 24); h > 0 {
+		Devices.AccessTTL = time.Duration(h) * time.Hour
+	}
+	Clients = device.OpenClients(PathRoot + "/tmp/data/clients.json")
+}
+
+// ClientName 请求方设备的显示名称: 配对设备的名称，浏览器设置的名称或按User-Agent识别的名称(如 Chrome (Android))，
+// 无法识别设备时为客户端地址
+func ClientName(r *http.Request) string {
+	if token := authn.BearerToken(r); token != "" {
+		if d, ok := Devices.Verify(token); ok && d.Name != "" {
+			return d.Name
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if id := DeviceIdentity(r); strings.HasPrefix(id, "browser:") {
+		c, ok := Clients.Get(id)
+		if !ok {
+			c = device.Client{Agent: r.UserAgent(), Addr: host}
+		}
+		return c.Label()
+	}
+	return host
+}
@@ -0,0 +1,57 @@
+package boot
+
+import (
+	"b0pass/library/dirsize"
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/notify"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/os/gfsnotify"
+	"time"
+)
+
+// DirSizes 目录大小缓存，dirsize.enabled为false时为nil
+var DirSizes *dirsize.Cache
+
+// watchDirSizes 文件列表中的目录先返回估算值，后台计算完成后以dirsize通知推送最终值；
+// 文件变化时标记所在目录过期，并定期保存计算结果供重启后作为估算值
+func watchDirSizes() {
+	c := g.Config()
+	if !c.GetBool("dirsize.enabled", true) {
+		return
+	}
+	DirSizes = dirsize.Open(PathRoot+"/tmp/data/dirsize.json", dirsize.Options{
+		Queue:    c.GetInt("dirsize.queue", 1024),
+		TTL:      time.Duration(c.GetInt("dirsize.ttl", 60)) * time.Minute,
+		Estimate: estimateDirSize,
+		OnDone: func(dir string, s dirsize.Size) {
+			notify.Send(notify.Event{Type: "dirsize", Level: "info", Title: fileinfos.FileURL(dir),
+				Data: g.Map{"path": fileinfos.FileURL(dir), "size": s.Bytes, "files": s.Files, "complete": s.Complete}})
+		},
+	})
+	fileinfos.DirSize = func(dir string) (int64, int, bool) {
+		s := DirSizes.Get(dir)
+		return s.Bytes, s.Files, s.Complete
+	}
+	OnShutdown(func() { _ = DirSizes.Save() })
+	for _, r := range fileinfos.Roots() {
+		if _, err := gfsnotify.Add(r.Path, func(e *gfsnotify.Event) { DirSizes.Invalidate(e.Path) }, true); err != nil {
+			logger.Error("dirsize", "watch", "dir", r.Path, "err", err)
+		}
+	}
+	go func() {
+		for range time.Tick(time.Minute) {
+			if err := DirSizes.Save(); err != nil {
+				logger.Error("dirsize", "save", "err", err)
+			}
+		}
+	}()
+}
+
+// estimateDirSize 尚未计算过的目录按文件索引中的合计估算
+func estimateDirSize(dir string) (int64, int, bool) {
+	if FileIndex == nil {
+		return 0, 0, false
+	}
+	return FileIndex.DirSize(dir)
+}
@@ -0,0 +1,36 @@
+package boot
+
+import (
+	"b0pass/library/discovery"
+	"b0pass/library/logger"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gogf/gf/frame/g"
+	"os"
+	"time"
+)
+
+// Nearby 局域网中的其他实例，未启用或监听失败时为nil
+var Nearby *discovery.Service
+
+// watchDiscovery 按配置在局域网中通告本实例并发现其他实例
+func watchDiscovery() {
+	c := g.Config()
+	if !c.GetBool("discovery.enable", true) {
+		return
+	}
+	name := c.GetString("discovery.name")
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	s := discovery.New(discovery.Message{ID: hex.EncodeToString(id), Name: name, Port: ServPort, Base: BasePath},
+		time.Duration(c.GetInt("discovery.interval", 30))*time.Second)
+	if err := s.Start(c.GetString("discovery.group")); err != nil {
+		logger.Error("discovery", "listen", "err", err)
+		return
+	}
+	Nearby = s
+	OnShutdown(func() { _ = s.Close() })
+}
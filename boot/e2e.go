@@ -0,0 +1,36 @@
+package boot
+
+import (
+	"b0pass/library/e2e"
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"github.com/gogf/gf/frame/g"
+	"path/filepath"
+	"time"
+)
+
+// Encrypted 端到端加密文件的密文存储，目录无法创建时为nil
+var Encrypted *e2e.Store
+
+// initEncrypted 密文位于上传暂存目录下的e2e目录，不出现在共享目录的列表中
+func initEncrypted() {
+	dir := fileinfos.TempDir(fileinfos.FilesRoot(), g.Config().GetString("upload.tmpdir"))
+	s, err := e2e.Open(filepath.Join(dir, "e2e"))
+	if err != nil {
+		logger.Error("e2e", "open", "dir", dir, "err", err)
+		return
+	}
+	Encrypted = s
+}
+
+// watchEncrypted 每小时删除超过e2e.days天的加密文件
+func watchEncrypted() {
+	for Encrypted != nil {
+		if n, err := Encrypted.Expire(time.Now()); err != nil {
+			logger.Error("e2e", "expire", "err", err)
+		} else if n > 0 {
+			logger.Info("e2e", "expired", "count", n)
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,72 @@
+package boot
+
+import (
+	"b0pass/library/fileindex"
+	"b0pass/library/fileinfos"
+	"b0pass/library/hashes"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/os/gfsnotify"
+	"os"
+	"strconv"
+	"time"
+)
+
+// FileIndex 文件索引(校验值、文本内容与收到时间)，index.enabled为false时为nil
+var FileIndex *fileindex.Index
+
+// watchIndex 启用文件索引时与磁盘同步，之后按文件变化通知更新并定期保存
+func watchIndex() {
+	c := g.Config()
+	if !c.GetBool("index.enabled") {
+		return
+	}
+	opt := fileindex.Options{TextMax: int64(c.GetInt("index.text", 64)) * 1024}
+	if c.GetBool("index.hash", true) {
+		opt.Hash = indexHash
+	}
+	x, err := fileindex.Open(PathRoot+"/tmp/data/index.db", SearchRoots(), opt)
+	if err != nil {
+		logger.Error("index", "open", "err", err)
+		return
+	}
+	FileIndex = x
+	OnShutdown(func() { _ = FileIndex.Close() })
+	go func() {
+		start := time.Now()
+		if err := FileIndex.Sync(); err != nil {
+			logger.Error("index", "sync", "err", err)
+		} else {
+			logger.Info("index", "synced", "entries", FileIndex.Len(), "took", time.Since(start).Round(time.Millisecond))
+		}
+		for _, r := range fileinfos.Roots() {
+			if _, err := gfsnotify.Add(r.Path, func(e *gfsnotify.Event) { FileIndex.Notify(e.Path) }, true); err != nil {
+				logger.Error("index", "watch", "dir", r.Path, "err", err)
+			}
+		}
+		resync := time.Duration(c.GetInt("index.interval", 60)) * time.Minute
+		synced := time.Now()
+		for range time.Tick(time.Second) {
+			if _, err := FileIndex.Flush(2 * time.Second); err != nil {
+				logger.Error("index", "update", "err", err)
+			}
+			// 变化通知可能丢失(如目录数超过系统监视上限)，定期完整同步
+			if time.Since(synced) >= resync {
+				if err := FileIndex.Sync(); err != nil {
+					logger.Error("index", "sync", "err", err)
+				}
+				synced = time.Now()
+			}
+		}
+	}()
+}
+
+// indexHash 文件的sha256，上传时已记录且大小未变化时不再读取文件
+func indexHash(file string, info os.FileInfo) (string, error) {
+	m := metadata.Fields(fileinfos.FileKey(file))
+	if sum := m[hashes.Default]; sum != "" && m["size"] == strconv.FormatInt(info.Size(), 10) {
+		return sum, nil
+	}
+	return hashes.File(file, hashes.Default)
+}
@@ -0,0 +1,36 @@
+package boot
+
+import (
+	"b0pass/library/archive"
+	"b0pass/library/logger"
+	"github.com/gogf/gf/frame/g"
+)
+
+// Archives 打包下载的文件句柄池
+var Archives *archive.Pool
+
+// initFileLimit 按需提高文件句柄软上限(ulimit -n)，并据此创建打包句柄池
+func initFileLimit() {
+	c := g.Config()
+	if c.GetBool("archive.raiselimit", true) {
+		if _, err := archive.RaiseFileLimit(); err != nil {
+			logger.Error("archive", "raise file limit", "err", err)
+		}
+	}
+	if cur, _, err := archive.FileLimit(); err == nil && cur > 0 && cur < 1024 {
+		logger.Warn("archive", "file limit(ulimit -n) is low, zipping large folders will be slow", "limit", cur)
+	}
+	Archives = archive.NewPool(c.GetInt("archive.fdlimit"))
+}
+
+// FileLimit 文件句柄状态，用于状态接口与自检
+func FileLimit() g.Map {
+	cur, max, _ := archive.FileLimit()
+	size, inUse := Archives.Stats()
+	return g.Map{
+		"limit": cur,
+		"max":   max,
+		"pool":  size,
+		"inuse": inUse,
+	}
+}
@@ -0,0 +1,22 @@
+package boot
+
+import (
+	"b0pass/library/gallery"
+	"errors"
+)
+
+// Gallery 相册元数据缓存
+var Gallery *gallery.Cache
+
+// initGallery 打开相册元数据缓存，启用转码时HEIC等格式的尺寸由ffprobe读取
+func initGallery() {
+	Gallery = gallery.Open(PathRoot + "/tmp/data/gallery.json")
+	Gallery.Dimensions = func(file string) (int, int, error) {
+		if Prober == nil {
+			return 0, 0, errors.New("ffprobe not available")
+		}
+		info, err := Prober.Probe(file)
+		return info.Width, info.Height, err
+	}
+	OnShutdown(func() { _ = Gallery.Save() })
+}
@@ -0,0 +1,20 @@
+package boot
+
+import (
+	"b0pass/library/audit"
+	"b0pass/library/grants"
+	"time"
+)
+
+// Grants 访客设备的临时写权限
+var Grants = grants.New()
+
+// watchGrants 定时撤销到期的授权并记录审计日志
+func watchGrants() {
+	for {
+		time.Sleep(30 * time.Second)
+		for _, g := range Grants.Expire(time.Now()) {
+			audit.Record(audit.Entry{Op: audit.OpRevoke, Path: "/", Client: g.Device, Agent: "scheduler"})
+		}
+	}
+}
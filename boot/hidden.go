@@ -0,0 +1,66 @@
+package boot
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/hidden"
+	"b0pass/library/logger"
+	"github.com/gogf/gf/frame/g"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// HiddenRules 列表与打包下载中隐藏的文件([hidden])，暂存目录、回收站与缩略图缓存等内部目录总是隐藏
+func HiddenRules() hidden.Rules {
+	c := g.Config()
+	r := hidden.Rules{
+		Dotfiles: c.GetBool("hidden.dotfiles", true),
+		System:   c.GetBool("hidden.system", true),
+		Always:   []string{".b0pass-check", ".*.part"},
+	}
+	for _, p := range c.GetStrings("hidden.patterns") {
+		if err := hidden.Check([]string{p}); err != nil {
+			logger.Warn("hidden", "invalid pattern", "pattern", p, "err", err)
+			continue
+		}
+		r.Patterns = append(r.Patterns, p)
+	}
+	for _, d := range []string{c.GetString("upload.tmpdir", ".b0pass-tmp"), c.GetString("trash.dir", ".trash"), c.GetString("thumb.dir", ".thumbs")} {
+		if d != "" && !filepath.IsAbs(d) {
+			r.Always = append(r.Always, filepath.Base(d))
+		}
+	}
+	return r
+}
+
+// Internal file是否为所在共享根目录的暂存目录、回收站或缩略图缓存，或位于其中
+func Internal(file string) bool {
+	c := g.Config()
+	root := fileinfos.RootOf(file)
+	for _, d := range []string{c.GetString("upload.tmpdir", ".b0pass-tmp"), c.GetString("trash.dir", ".trash"), c.GetString("thumb.dir", ".thumbs")} {
+		if d == "" {
+			continue
+		}
+		dir := fileinfos.TempDir(root, d)
+		if file == dir || strings.HasPrefix(file, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hidden 本次请求的隐藏规则: all=1显示全部(内部目录除外)，exclude追加逗号分隔的通配符
+func Hidden(r *http.Request) (hidden.Rules, error) {
+	rules := HiddenRules()
+	q := r.URL.Query()
+	if all := q.Get("all"); all == "1" || all == "true" {
+		rules = rules.All()
+	}
+	if exclude := hidden.Split(q.Get("exclude")); len(exclude) > 0 {
+		if err := hidden.Check(exclude); err != nil {
+			return rules, err
+		}
+		rules.Patterns = append(rules.Patterns, exclude...)
+	}
+	return rules, nil
+}
@@ -0,0 +1,28 @@
+package boot
+
+import (
+	"b0pass/library/history"
+	"b0pass/library/logger"
+	"github.com/gogf/gf/frame/g"
+	"time"
+)
+
+// TransferLog 已完成的传输记录
+var TransferLog *history.Store
+
+// initHistory 打开传输记录
+func initHistory() {
+	TransferLog = history.Open(PathRoot + "/tmp/data/transfers")
+}
+
+// watchHistory 每天删除超过history.days天的传输记录(按月份整体删除)，为0时永久保留
+func watchHistory() {
+	for {
+		if days := g.Config().GetInt("history.days", 365); days > 0 {
+			if err := TransferLog.Expire(time.Now().AddDate(0, 0, -days)); err != nil {
+				logger.Error("history", "expire", "err", err)
+			}
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
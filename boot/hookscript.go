@@ -0,0 +1,38 @@
+package boot
+
+import (
+	"b0pass/library/hookscript"
+	"b0pass/library/logger"
+	"github.com/gogf/gf/frame/g"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Scripts 文件事件的钩子脚本
+var Scripts = hookscript.New("", 0)
+
+// initScripts 按[hooks]配置启用钩子脚本，运行中修改设置后重新加载
+func initScripts() {
+	loadScripts()
+	Scripts.OnDone = func(script string, e hookscript.Event, out []byte, err error) {
+		text := strings.TrimSpace(string(out))
+		if err != nil {
+			logger.Error("hooks", "script failed", "script", filepath.Base(script), "path", e.Path, "err", err, "output", text)
+			return
+		}
+		logger.Info("hooks", "script done", "script", filepath.Base(script), "path", e.Path, "output", text)
+	}
+	Scripts.Start(g.Config().GetInt("hooks.workers", 2))
+	OnSettings(loadScripts)
+}
+
+// loadScripts 读取钩子目录(相对程序目录)与超时设置
+func loadScripts() {
+	c := g.Config()
+	dir := c.GetString("hooks.dir", "hooks")
+	if dir != "" && !filepath.IsAbs(dir) {
+		dir = filepath.Join(PathRoot, dir)
+	}
+	Scripts.Set(dir, time.Duration(c.GetInt("hooks.timeout", 600))*time.Second)
+}
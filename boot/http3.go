@@ -0,0 +1,53 @@
+//go:build http3
+// +build http3
+
+package boot
+
+import (
+	"b0pass/library/logger"
+	"crypto/tls"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/quic-go/quic-go/http3"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// serveHTTP3 在HTTPSPort的UDP端口提供HTTP/3(实验性)，与HTTPS使用相同的证书与处理函数；
+// 框架的处理函数在服务启动时才设置，因此先监听端口，服务运行后再开始处理
+func serveHTTP3(s *ghttp.Server, conf *tls.Config) error {
+	conn, err := net.ListenPacket("udp", ":"+strconv.Itoa(HTTPSPort))
+	if err != nil {
+		return err
+	}
+	srv := &http3.Server{TLSConfig: http3.ConfigureTLSConfig(conf)}
+	OnShutdown(func() { _ = srv.Close() })
+	AltSvc = `h3=":` + strconv.Itoa(HTTPSPort) + `"; ma=86400`
+	go func() {
+		for s.Status() != ghttp.SERVER_STATUS_RUNNING {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if srv.Handler = serverHandler(s); srv.Handler == nil {
+			logger.Error("https", "http3", "err", "server handler not found")
+			return
+		}
+		if err := srv.Serve(conn); err != nil && err != http.ErrServerClosed && !Stopping() {
+			logger.Error("https", "http3", "port", HTTPSPort, "err", err)
+		}
+	}()
+	logger.Info("https", "http3", "port", HTTPSPort)
+	return nil
+}
+
+// serverHandler 框架未提供获取处理函数的方法，通过反射读取配置中的Handler
+func serverHandler(s *ghttp.Server) http.Handler {
+	v := reflect.ValueOf(s).Elem().FieldByName("config").FieldByName("Handler")
+	if !v.IsValid() {
+		return nil
+	}
+	h, _ := reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem().Interface().(http.Handler)
+	return h
+}
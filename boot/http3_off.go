@@ -0,0 +1,17 @@
+//go:build !http3
+// +build !http3
+
+package boot
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/gogf/gf/net/ghttp"
+)
+
+// ErrNoHTTP3 HTTP/3依赖quic-go，需使用 -tags http3 编译
+var ErrNoHTTP3 = errors.New("http3: not built in, rebuild with -tags http3")
+
+func serveHTTP3(s *ghttp.Server, conf *tls.Config) error {
+	return ErrNoHTTP3
+}
@@ -0,0 +1,67 @@
+package boot
+
+import (
+	"b0pass/library/authn"
+	"b0pass/library/logger"
+	"crypto/tls"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+)
+
+// serveStaticHTTPS 未启用自动证书而配置了https.cert与https.key时，在https.port上以该证书启用HTTPS
+func serveStaticHTTPS(s *ghttp.Server) {
+	c := g.Config()
+	cert, key := c.GetString("https.cert"), c.GetString("https.key")
+	if Certs != nil || cert == "" || key == "" {
+		return
+	}
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		logger.Error("https", "load certificate", "err", err)
+		return
+	}
+	HTTPSPort = c.GetInt("https.port", 443)
+	s.SetTLSConfig(tls.Config{
+		Certificates: []tls.Certificate{pair},
+		NextProtos:   nextProtos(),
+		ClientAuth:   clientAuth(),
+	})
+	s.SetHTTPSPort(HTTPSPort)
+	logger.Info("https", "listening", "port", HTTPSPort)
+	startHTTP3(s, &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   clientAuth(),
+	})
+}
+
+// AltSvc 启用HTTP/3后HTTPS响应携带的Alt-Svc头，告知客户端同一端口(UDP)可用HTTP/3
+var AltSvc string
+
+// startHTTP3 https.http3开启时在HTTPS端口的UDP上同时提供HTTP/3
+func startHTTP3(s *ghttp.Server, conf *tls.Config) {
+	if !g.Config().GetBool("https.http3") {
+		return
+	}
+	if err := serveHTTP3(s, conf); err != nil {
+		logger.Error("https", "http3", "port", HTTPSPort, "err", err)
+	}
+}
+
+// nextProtos HTTPS可协商的应用层协议，https.http2(默认开启)时优先HTTP/2，所有HTTPS监听共用
+func nextProtos(extra ...string) []string {
+	protos := []string{"http/1.1"}
+	if g.Config().GetBool("https.http2", true) {
+		protos = append([]string{"h2"}, protos...)
+	}
+	return append(protos, extra...)
+}
+
+// clientAuth 启用了mtls认证时请求客户端证书
+func clientAuth() tls.ClientAuthType {
+	for _, p := range Auth() {
+		if _, ok := p.(*authn.MTLS); ok {
+			return tls.RequestClientCert
+		}
+	}
+	return tls.NoClientCert
+}
@@ -0,0 +1,145 @@
+package boot
+
+import (
+	"b0pass/library/archive"
+	"b0pass/library/fileinfos"
+	"b0pass/library/fsync"
+	"b0pass/library/hashes"
+	"b0pass/library/inbox"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/notify"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Inboxes 收集链接会话
+var Inboxes *inbox.Store
+
+var (
+	archiveMu     sync.Mutex
+	archiveFailed = make(map[string]bool)
+)
+
+// initInboxes 加载收集链接会话
+func initInboxes() {
+	Inboxes = inbox.OpenStore(PathRoot + "/tmp/data/inbox.json")
+}
+
+// watchInboxes 定时归档已过期的收集会话，关闭的会话由关闭接口立即归档
+func watchInboxes() {
+	for {
+		for _, sess := range Inboxes.ToArchive(time.Now()) {
+			_, _ = ArchiveInbox(sess.ID)
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+// ArchiveInbox 将会话收到的文件打包为带日期的归档(inbox.format)并生成sha256清单，
+// inbox.trash为true时原文件移入回收站，完成后通知；同一会话失败后不再自动重试
+func ArchiveInbox(id string) (inbox.Session, error) {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	sess, ok := Inboxes.Get(id)
+	if !ok {
+		return sess, inbox.ErrNoEntry
+	}
+	if sess.Archived != "" || archiveFailed[id] {
+		return sess, nil
+	}
+	archived, packed, err := archiveInbox(sess)
+	if err != nil {
+		archiveFailed[id] = true
+		logger.Error("inbox", "archive", "id", id, "path", sess.Path, "err", err)
+		notify.Send(notify.Event{Type: "inbox", Level: "error", Title: "收集链接归档失败: " + sess.Path, Data: g.Map{"id": id, "err": err.Error()}})
+		return sess, err
+	}
+	sess, err = Inboxes.Update(id, func(s *inbox.Session) { s.Archived = archived })
+	if archived == inbox.NothingArchived {
+		return sess, err
+	}
+	var size int64
+	for _, p := range packed {
+		size += p.Size
+	}
+	logger.Info("inbox", "archived", "id", id, "archive", archived, "files", len(packed))
+	notify.Send(notify.Event{Type: "inbox", Level: "info", Title: "收集链接已归档: " + archived,
+		Data: g.Map{"id": id, "path": sess.Path, "archive": archived, "manifest": archived + ".sha256", "files": len(packed), "size": size}})
+	return sess, err
+}
+
+// archiveInbox 打包、写清单并移走原文件，返回归档的相对路径
+func archiveInbox(sess inbox.Session) (string, []archive.Packed, error) {
+	dir := fileinfos.FilePath(sess.Path)
+	if dir == "" {
+		return "", nil, fmt.Errorf("inbox path %s not found", sess.Path)
+	}
+	var names []string
+	for _, f := range sess.Files {
+		if rel := strings.TrimPrefix(f, strings.TrimSuffix(sess.Path, "/")+"/"); rel != f {
+			names = append(names, rel)
+		}
+	}
+	format := g.Config().GetString("inbox.format", archive.FormatZip)
+	if archive.ContentType(format) == "" {
+		return "", nil, fmt.Errorf("unsupported inbox.format %q", format)
+	}
+	target := fileinfos.UniqueName(filepath.Join(dir, fmt.Sprintf("inbox-%s-%s.%s", time.Now().Format("2006-01-02"), sess.ID[:8], format)))
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(target)+".*.part")
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	packed, err := archive.WriteFiles(context.Background(), tmp, dir, names, archive.Options{Format: format}, hashes.Default)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if len(packed) == 0 {
+		return inbox.NothingArchived, nil, nil
+	}
+	_ = tmp.Chmod(0644)
+	if err := tmp.Close(); err != nil {
+		return "", nil, err
+	}
+	// 清单为sha256sum格式，可用 sha256sum -c 校验解包后的文件
+	var manifest bytes.Buffer
+	for _, p := range packed {
+		fmt.Fprintf(&manifest, "%s  %s\n", p.Sums[hashes.Default], p.Name)
+	}
+	if err := ioutil.WriteFile(target+".sha256", manifest.Bytes(), 0644); err != nil {
+		return "", nil, err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", nil, err
+	}
+	if err := fsync.File(target); err != nil {
+		return "", nil, err
+	}
+	if g.Config().GetBool("inbox.trash", true) {
+		for _, p := range packed {
+			file := filepath.Join(dir, filepath.FromSlash(p.Name))
+			if _, err := TrashBin(file).Move(file, path.Join(sess.Path, p.Name), "inbox-archive"); err != nil {
+				logger.Warn("inbox", "trash", "file", file, "err", err)
+				continue
+			}
+			metadata.Delete(fileinfos.FileKey(file))
+		}
+	}
+	return "/" + fileinfos.FileKey(target), packed, nil
+}
@@ -0,0 +1,29 @@
+package boot
+
+import (
+	"b0pass/library/inhibit"
+	"b0pass/library/logger"
+	"github.com/gogf/gf/frame/g"
+	"sync"
+	"time"
+)
+
+// Awake 传输期间阻止系统休眠，未启用时为nil
+var Awake *inhibit.Inhibitor
+
+// initInhibit 按配置启用休眠抑制
+func initInhibit() {
+	c := g.Config()
+	if !c.GetBool("power.inhibit", true) {
+		return
+	}
+	var once sync.Once
+	Awake = inhibit.New(
+		"b0pass is transferring files",
+		time.Duration(c.GetInt("power.grace", 30))*time.Second,
+		func(err error) {
+			// 平台不支持时每次传输都会失败，只记录一次
+			once.Do(func() { logger.Warn("power", "inhibit sleep", "err", err) })
+		},
+	)
+}
@@ -0,0 +1,26 @@
+package boot
+
+import (
+	"b0pass/library/humanize"
+	"b0pass/library/response"
+	"github.com/gogf/gf/frame/g"
+	"net/http"
+)
+
+// initLocale 接口返回信息按请求的语言翻译
+func initLocale() {
+	response.Locale = Locale
+}
+
+// Locale 返回给界面的信息与大小、日期等文字的语言: ?locale=参数优先，其次设备偏好中的语言、Accept-Language，
+// 都不支持时为setting.locale
+func Locale(r *http.Request) string {
+	loc := r.URL.Query().Get("locale")
+	if loc == "" && Prefs != nil {
+		if id := DeviceIdentity(r); id != "" {
+			loc = Prefs.Get(id).Locale
+		}
+	}
+	return humanize.Negotiate(loc, r.Header.Get("Accept-Language"),
+		g.Config().GetString("setting.locale", humanize.ZH))
+}
@@ -0,0 +1,65 @@
+package boot
+
+import (
+	"b0pass/library/logger"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/gogf/gf/os/glog"
+	"github.com/gogf/gf/os/gtime"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// initLogger 按[log]配置初始化日志，并接管gf与标准库的日志输出
+func initLogger() {
+	c := g.Config()
+	level, err := logger.ParseLevel(c.GetString("log.level", "info"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[log] ERR:", err)
+	}
+	dir := c.GetString("setting.logpath", "tmp/log")
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(PathRoot, dir)
+	}
+	logger.Init(logger.Options{
+		File:    filepath.Join(dir, "b0pass.log"),
+		Level:   level,
+		JSON:    c.GetString("log.format") == "json",
+		MaxSize: c.GetInt64("log.maxsize", 10) << 20,
+		Backups: c.GetInt("log.backups", 5),
+		// 子命令的标准输出可能被重定向(如导出报表)，只写文件
+		Stdout: Command == "" && c.GetBool("log.stdout", true),
+	})
+	// gf日志的时间与级别由logger输出
+	glog.SetHeaderPrint(false)
+	glog.SetDebug(level == logger.LevelDebug)
+	glog.SetWriter(logger.Default().Writer(logger.LevelInfo, "gf"))
+	log.SetFlags(0)
+	log.SetOutput(logger.Default().Writer(logger.LevelInfo, "log"))
+}
+
+// logRequest 请求日志(替代gf的access/error日志)，记录状态、耗时、客户端IP与User-Agent
+func logRequest(r *ghttp.Request, err ...error) {
+	leave := r.LeaveTime
+	if leave < r.EnterTime {
+		leave = gtime.Microsecond()
+	}
+	kv := []interface{}{
+		"method", r.Method,
+		"path", r.URL.String(),
+		"status", r.Response.Status,
+		"ms", float64(leave-r.EnterTime) / 1000,
+		"ip", r.GetClientIp(),
+		"ua", r.UserAgent(),
+	}
+	if len(err) > 0 && err[0] != nil {
+		logger.Error("access", "request failed", append(kv, "err", err[0])...)
+		return
+	}
+	if !g.Config().GetBool("log.access", true) {
+		return
+	}
+	logger.Info("access", "request", kv...)
+}
@@ -0,0 +1,28 @@
+package boot
+
+import (
+	"b0pass/library/logger"
+	"b0pass/library/mimetype"
+	"github.com/gogf/gf/frame/g"
+	"strings"
+)
+
+// initMime 按mime.types补充扩展名对应的类型，每项格式为 "扩展名,扩展名: 类型"
+func initMime() {
+	for _, s := range g.Config().GetStrings("mime.types") {
+		p := strings.Index(s, ":")
+		typ := ""
+		if p >= 0 {
+			typ = strings.TrimSpace(s[p+1:])
+		}
+		if typ == "" {
+			logger.Error("mime", "types", "err", "expect \"ext,ext: type\"", "value", s)
+			continue
+		}
+		for _, ext := range strings.Split(s[:p], ",") {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				mimetype.Register(ext, typ)
+			}
+		}
+	}
+}
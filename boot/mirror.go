@@ -0,0 +1,31 @@
+package boot
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/mirror"
+	"b0pass/library/peerpool"
+	"github.com/gogf/gf/frame/g"
+	"time"
+)
+
+// Mirror 镜像复制，未配置时为nil
+var Mirror *mirror.Mirror
+
+// initMirror 按配置启用镜像复制
+func initMirror() {
+	m, err := mirror.New(g.Config().GetString("mirror.url"), fileinfos.FilesRoot())
+	if err != nil {
+		logger.Error("mirror", "init", "err", err)
+		return
+	}
+	if m != nil {
+		c := g.Config()
+		m.Resolve = fileinfos.FilePath
+		m.Small = int64(c.GetInt("mirror.small", 256)) << 10
+		m.BatchFiles = c.GetInt("mirror.batch", 64)
+		m.Peer = peerpool.New(c.GetInt("mirror.workers", 2)+1, time.Duration(c.GetInt("mirror.idle", 90))*time.Second)
+		m.Client = m.Peer.Client
+	}
+	Mirror = m
+}
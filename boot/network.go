@@ -0,0 +1,43 @@
+package boot
+
+import (
+	"b0pass/library/ipaddress"
+	"b0pass/library/logger"
+	"b0pass/library/notify"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// watchNetwork 监测内网地址变化(切换Wi-Fi、DHCP分配新地址)，通知已连接的页面刷新地址与二维码
+// 各服务监听在所有网卡上，地址变化后无需重新绑定
+func watchNetwork() {
+	interval := g.Config().GetInt("setting.netwatch", 5)
+	if interval <= 0 {
+		return
+	}
+	ipaddress.Watch(time.Duration(interval)*time.Second, func(old, now []string) {
+		added, removed := ipaddress.Diff(old, now)
+		var urls []string
+		for _, ip := range now {
+			urls = append(urls, ip+":"+strconv.Itoa(ServPort))
+		}
+		logger.Info("network", "addresses changed", "added", added, "removed", removed)
+		fmt.Printf("[IPlistArr] %v\n", now)
+		if len(added) > 0 {
+			PrintQR(AccessURL(ServPort, BasePath+"/"))
+		}
+		notify.Send(notify.Event{
+			Type:  "network",
+			Level: "info",
+			Title: "网络地址已变化: " + strings.Join(urls, " "),
+			Data: g.Map{
+				"ips":     urls,
+				"added":   added,
+				"removed": removed,
+			},
+		})
+	})
+}
@@ -0,0 +1,55 @@
+package boot
+
+import (
+	"b0pass/library/logger"
+	"b0pass/library/portmap"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"net"
+	"strconv"
+)
+
+// UPnP 启动时请求路由器映射端口(--upnp)
+var UPnP bool
+
+// watchPortMap 通过UPnP或NAT-PMP请求路由器把外网端口映射到setting.port，输出外网访问地址，退出时删除映射
+func watchPortMap() {
+	c := g.Config()
+	if !c.GetBool("upnp.enable") {
+		return
+	}
+	if len(Auth()) == 0 {
+		logger.Warn("portmap", "no authentication", "hint", "映射端口后外网可直接访问共享目录，建议配置[auth]")
+	}
+	pm := portmap.New(c.GetString("upnp.description", "b0pass"))
+	stop := make(chan struct{})
+	OnShutdown(func() {
+		close(stop)
+		if err := pm.Unmap(); err != nil {
+			logger.Warn("portmap", "unmap", "err", err)
+		}
+	})
+	go func() {
+		m, err := pm.Map(ServPort, c.GetInt("upnp.external"))
+		if err != nil {
+			logger.Error("portmap", "map", "port", ServPort, "err", err)
+			return
+		}
+		reportMapping(m)
+		pm.Run(stop, reportMapping, func(err error) {
+			logger.Warn("portmap", "renew", "err", err)
+		})
+	}()
+}
+
+// reportMapping 输出映射后的外网访问地址及二维码
+func reportMapping(m *portmap.Mapping) {
+	logger.Info("portmap", "mapped", "method", m.Method, "internal", m.Internal, "external", m.External, "ip", m.ExternalIP)
+	if m.ExternalIP == "" {
+		fmt.Printf("[UPnP] 外网端口 %d -> 本机端口 %d\n", m.External, m.Internal)
+		return
+	}
+	u := "http://" + net.JoinHostPort(m.ExternalIP, strconv.Itoa(m.External)) + BasePath + "/"
+	fmt.Printf("[UPnP-Url] %s\n", u)
+	PrintQR(u)
+}
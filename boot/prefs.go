@@ -0,0 +1,38 @@
+package boot
+
+import (
+	"b0pass/library/authn"
+	"b0pass/library/prefs"
+	"encoding/hex"
+	"net/http"
+)
+
+// Prefs 各设备的偏好设置
+var Prefs *prefs.Store
+
+// DeviceCookie 浏览器的设备标识Cookie，首次请求/api/session时设置
+const DeviceCookie = "b0pass_device"
+
+// initPrefs 加载设备偏好
+func initPrefs() {
+	Prefs = prefs.Open(PathRoot + "/tmp/data/prefs.json")
+}
+
+// DeviceIdentity 请求方的设备标识: 已配对设备为 device:<id>，浏览器为 browser:<Cookie中的随机标识>，都没有时为空
+func DeviceIdentity(r *http.Request) string {
+	if token := authn.BearerToken(r); token != "" {
+		if d, ok := Devices.Verify(token); ok {
+			return "device:" + d.ID
+		}
+	}
+	if c, err := r.Cookie(DeviceCookie); err == nil && ValidBrowserID(c.Value) {
+		return "browser:" + c.Value
+	}
+	return ""
+}
+
+// ValidBrowserID 浏览器标识为32位十六进制
+func ValidBrowserID(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == 16
+}
@@ -0,0 +1,30 @@
+package boot
+
+import (
+	"b0pass/library/logger"
+	"b0pass/library/protect"
+	"github.com/gogf/gf/frame/g"
+	"time"
+)
+
+// Protected 受保护(加密保存)的目录
+var Protected *protect.Store
+
+// Keyring 已解锁目录的密钥，只保存在内存中
+var Keyring *protect.Keyring
+
+// initProtected 加载受保护目录，设备超过protect.idle分钟未访问时自动锁定
+func initProtected() {
+	Protected = protect.Open(PathRoot + "/tmp/data/protected.json")
+	Keyring = protect.NewKeyring(time.Duration(g.Config().GetInt("protect.idle", 30)) * time.Minute)
+}
+
+// watchProtected 每分钟锁定长时间未访问的目录
+func watchProtected() {
+	for {
+		time.Sleep(time.Minute)
+		for _, rel := range Keyring.Expire(time.Now()) {
+			logger.Info("protect", "locked", "path", rel)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package boot
+
+import (
+	"b0pass/library/proxy"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"os"
+	"path"
+	"strings"
+)
+
+// BasePath 部署在反向代理的子路径下时的路径前缀(如 /b0pass)，为空时在根路径
+var BasePath string
+
+// Proxies 受信任的反向代理，来自这些地址的请求才采用X-Forwarded-For/X-Forwarded-Proto/X-Forwarded-Host
+var Proxies proxy.Trusted
+
+// initProxy 读取路径前缀(--base-path优先于setting.basepath)与受信任的代理
+func initProxy() {
+	if BasePath == "" {
+		BasePath = g.Config().GetString("setting.basepath")
+	}
+	BasePath = cleanBase(BasePath)
+	t, err := proxy.Parse(g.Config().GetStrings("proxy.trusted"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[config] ERR: proxy.trusted:", err)
+		os.Exit(2)
+	}
+	Proxies = t
+	// 部署在反向代理后时，不配置受信任的代理则经本机代理转发的请求都来自回环地址，会被当作主电脑
+	if BasePath != "" && len(Proxies) == 0 {
+		fmt.Fprintln(os.Stderr, "[config] ERR: setting.basepath(--base-path)须同时配置proxy.trusted(反向代理的地址)")
+		os.Exit(2)
+	}
+}
+
+// cleanBase 规范为以/开头、不以/结尾的路径，根路径为空
+func cleanBase(p string) string {
+	p = path.Clean("/" + strings.TrimSpace(p))
+	if p == "/" {
+		return ""
+	}
+	return p
+}
+
+// TrimBase 去掉请求路径的前缀，不在前缀下时原样返回
+func TrimBase(p string) string {
+	if BasePath == "" {
+		return p
+	}
+	if p == BasePath {
+		return "/"
+	}
+	if strings.HasPrefix(p, BasePath+"/") {
+		return p[len(BasePath):]
+	}
+	return p
+}
@@ -0,0 +1,39 @@
+package boot
+
+import (
+	"b0pass/library/ipaddress"
+	"b0pass/library/qrcode"
+	"fmt"
+	"strconv"
+)
+
+// 终端二维码输出方式
+const (
+	QRUnicode = "unicode" // Unicode半块字符，适用于深色背景
+	QRANSI    = "ansi"    // ANSI背景色，深色与浅色背景均可
+	QROff     = "off"
+)
+
+// PrintQR 在终端输出地址的二维码，便于SSH/无界面环境下扫码
+func PrintQR(url string) {
+	code, err := qrcode.Encode(url, qrcode.M)
+	if err != nil {
+		return
+	}
+	switch QRMode {
+	case QROff:
+	case QRANSI:
+		fmt.Print(code.ANSI())
+	default:
+		fmt.Print(code.Terminal())
+	}
+}
+
+// AccessURL 首个内网地址的访问地址，无内网地址时使用本机地址
+func AccessURL(port int, path string) string {
+	host := "127.0.0.1"
+	if ips, _ := ipaddress.GetIP(); len(ips) > 0 {
+		host = ips[0]
+	}
+	return "http://" + host + ":" + strconv.Itoa(port) + path
+}
@@ -0,0 +1,62 @@
+package boot
+
+import (
+	"b0pass/library/diskusage"
+	"b0pass/library/humanize"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/quota"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/util/gconv"
+)
+
+// QuotaLimits 配置的上传配额: quota.total全局、quota.device每个设备，[quota.devices]中单独指定某个设备
+func QuotaLimits() quota.Limits {
+	c := g.Config()
+	l := quota.Limits{
+		Total:   quotaSize("quota.total", c.GetString("quota.total", "0")),
+		Device:  quotaSize("quota.device", c.GetString("quota.device", "0")),
+		Devices: make(map[string]int64),
+	}
+	for owner, v := range c.GetMap("quota.devices") {
+		l.Devices[owner] = quotaSize("quota.devices."+owner, gconv.String(v))
+	}
+	return l
+}
+
+func quotaSize(key, s string) int64 {
+	n, err := humanize.ParseSize(s)
+	if err != nil {
+		logger.Warn("quota", "invalid size", "key", key, "value", s, "err", err)
+		return 0
+	}
+	return n
+}
+
+// StorageMargin 上传时在磁盘上额外保留的安全余量(storage.margin)
+func StorageMargin() int64 {
+	return quotaSize("storage.margin", g.Config().GetString("storage.margin", "64M"))
+}
+
+// DiskAvailable 磁盘上可用于上传的空间: 剩余空间扣除storage.block阈值之上的部分与安全余量，与拒绝上传的阈值一致
+func DiskAvailable(usage diskusage.Usage) int64 {
+	free := int64(usage.Free)
+	if block := Storage.Block; block > 0 && block < 100 {
+		free -= int64(float64(usage.Total) * (100 - block) / 100)
+	}
+	free -= StorageMargin()
+	if free < 0 {
+		free = 0
+	}
+	return free
+}
+
+// Quota owner(设备标识或IP)上传到dir所在磁盘前的可用空间
+func Quota(owner, dir string) quota.Report {
+	u := quota.Tally(metadata.Each, owner)
+	disk := quota.Space{Remaining: -1}
+	if usage, err := diskusage.Get(dir); err == nil {
+		disk = quota.Space{Limit: int64(usage.Total), Used: int64(usage.Used), Remaining: DiskAvailable(usage)}
+	}
+	return quota.Compute(QuotaLimits(), u, owner, disk)
+}
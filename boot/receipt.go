@@ -0,0 +1,36 @@
+package boot
+
+import (
+	"b0pass/library/logger"
+	"b0pass/library/receipt"
+	"github.com/gogf/gf/frame/g"
+	"os"
+)
+
+// Receipts 上传回执签名密钥，加载失败时为nil(不签发回执)
+var Receipts *receipt.Signer
+
+// initReceipts 加载回执签名密钥，首次运行时生成
+func initReceipts() {
+	name := g.Config().GetString("receipt.server")
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	s, err := receipt.LoadSigner(PathRoot+"/tmp/data/receipt.pem", name)
+	if err != nil {
+		logger.Error("receipt", "load key", "err", err)
+		return
+	}
+	Receipts = s
+}
+
+// ReceiptMail 回执邮件发送配置
+func ReceiptMail() receipt.Mail {
+	c := g.Config()
+	return receipt.Mail{
+		Addr:     c.GetString("receipt.smtp"),
+		User:     c.GetString("receipt.user"),
+		Password: c.GetString("receipt.password"),
+		From:     c.GetString("receipt.from"),
+	}
+}
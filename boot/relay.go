@@ -0,0 +1,29 @@
+package boot
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/relay"
+	"github.com/gogf/gf/frame/g"
+	"path/filepath"
+	"time"
+)
+
+// Relays 设备直传
+var Relays *relay.Hub
+
+// initRelays 直传的暂存文件位于上传暂存目录下的relay目录
+func initRelays() {
+	dir := fileinfos.TempDir(fileinfos.FilesRoot(), g.Config().GetString("upload.tmpdir"))
+	Relays = relay.New(filepath.Join(dir, "relay"), time.Duration(g.Config().GetInt("relay.ttl", 10))*time.Minute)
+}
+
+// watchRelays 每分钟取消超过relay.ttl分钟仍无人接收的直传
+func watchRelays() {
+	for {
+		time.Sleep(time.Minute)
+		for _, r := range Relays.Expire(time.Now()) {
+			logger.Info("relay", "expired", "id", r.ID, "name", r.Name, "from", r.From)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package boot
+
+import (
+	"b0pass/library/audit"
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/metadata"
+	"b0pass/library/notify"
+	"b0pass/library/retention"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"os"
+	"time"
+)
+
+// watchRetention 后台清理: 按[[retention]]规则定期将超过保留时间或超出大小上限的文件移入回收站或删除，
+// 每次重新读取配置，运行中修改规则后下一轮生效
+func watchRetention() {
+	for {
+		rules, err := retention.Parse(g.Config().GetArray("retention"))
+		if err != nil {
+			logger.Error("retention", "parse", "err", err)
+		}
+		for _, rule := range rules {
+			cleanup(rule)
+		}
+		interval := g.Config().GetInt("janitor.interval", 10)
+		if interval <= 0 {
+			interval = 10
+		}
+		time.Sleep(time.Duration(interval) * time.Minute)
+	}
+}
+
+// cleanup 执行一条规则，清理后删除变空的子目录并通知
+func cleanup(rule retention.Rule) {
+	dir := fileinfos.FilePath(rule.Dir)
+	if dir == "" {
+		logger.Warn("retention", "dir is not under a shared root", "dir", rule.Dir)
+		return
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return
+	}
+	files, err := rule.Select(dir, time.Now())
+	if err != nil {
+		logger.Error("retention", "scan", "dir", dir, "err", err)
+		return
+	}
+	var count int
+	var size int64
+	for _, f := range files {
+		key := fileinfos.FileKey(f.Path)
+		if rule.Action == retention.ActionDelete {
+			err = os.Remove(f.Path)
+		} else {
+			_, err = TrashBin(f.Path).Move(f.Path, "/"+key, "retention")
+		}
+		if err != nil {
+			logger.Warn("retention", rule.Action, "file", f.Path, "err", err)
+			continue
+		}
+		metadata.Delete(key)
+		retention.Prune(dir, f.Path)
+		audit.Record(audit.Entry{Op: audit.OpDelete, Path: "/files/" + key, Size: f.Size, Agent: "retention"})
+		count++
+		size += f.Size
+	}
+	if count > 0 {
+		logger.Info("retention", rule.Action, "dir", rule.Dir, "files", count, "size", size)
+		notify.Send(notify.Event{Type: "retention", Level: "info", Title: fmt.Sprintf("自动清理 %s: %d个文件", rule.Dir, count),
+			Data: g.Map{"path": "/files/" + fileinfos.FileKey(dir), "count": count, "size": size, "action": rule.Action}})
+	}
+}
@@ -0,0 +1,30 @@
+package boot
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/roots"
+	"github.com/gogf/gf/frame/g"
+	"os"
+)
+
+// initRoots 加载共享根目录配置，未配置时使用程序目录下的files；符号链接与挂载点策略见storage.links、storage.mounts
+func initRoots() {
+	links, err := roots.ParseLinks(g.Config().GetString("storage.links"))
+	if err != nil {
+		logger.Error("roots", "parse storage.links", "err", err)
+		links = roots.LinksFollow
+	}
+	fileinfos.SetPolicy(roots.Policy{Links: links, Mounts: g.Config().GetBool("storage.mounts", true)})
+	s, err := roots.Parse(g.Config().GetArray("roots"), PathRoot)
+	if err != nil {
+		logger.Error("roots", "parse", "err", err)
+		return
+	}
+	for _, r := range s {
+		if info, err := os.Stat(r.Path); err != nil || !info.IsDir() {
+			logger.Warn("roots", "not a directory", "name", r.Name, "path", r.Path)
+		}
+	}
+	fileinfos.SetRoots(s)
+}
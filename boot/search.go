@@ -0,0 +1,47 @@
+package boot
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/search"
+	"github.com/gogf/gf/frame/g"
+	"time"
+)
+
+// SearchIndex 文件名索引，search.index为false时不建立，搜索直接遍历目录
+var SearchIndex *search.Index
+
+// SearchRoots 搜索的根目录，结果路径与/files下的路径一致
+func SearchRoots() []search.Root {
+	s := fileinfos.Roots()
+	roots := make([]search.Root, 0, len(s))
+	for _, r := range s {
+		prefix := ""
+		if s.Multi() {
+			prefix = "/" + r.Name
+		}
+		roots = append(roots, search.Root{Prefix: prefix, Dir: r.Path})
+	}
+	return roots
+}
+
+// watchSearch 启用索引时定期重建
+func watchSearch() {
+	c := g.Config()
+	if !c.GetBool("search.index") {
+		return
+	}
+	SearchIndex = &search.Index{}
+	interval := time.Duration(c.GetInt("search.interval", 10)) * time.Minute
+	go func() {
+		for {
+			start := time.Now()
+			if err := SearchIndex.Build(SearchRoots()); err != nil {
+				logger.Error("search", "build index", "err", err)
+			} else {
+				logger.Info("search", "index built", "entries", SearchIndex.Len(), "took", time.Since(start).Round(time.Millisecond))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,38 @@
+package boot
+
+import (
+	"b0pass/library/audit"
+	"github.com/gogf/gf/frame/g"
+	"sync"
+	"time"
+)
+
+var (
+	settingsMu    sync.Mutex
+	settingsHooks []func()
+)
+
+// OnSettings 注册运行中修改设置后的回调，用于更新启动时读取并缓存的设置
+func OnSettings(fn func()) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	settingsHooks = append(settingsHooks, fn)
+}
+
+// applySettings 将修改后的设置应用到已启动的组件
+func applySettings() {
+	c := g.Config()
+	setStorageLimits()
+	if h := c.GetInt("device.accessttl", 24); h > 0 {
+		Devices.AccessTTL = time.Duration(h) * time.Hour
+	}
+	Bandwidth.SetRate(uploadRate())
+	audit.SetRetention(c.GetInt64("audit.maxsize", 10)<<20, c.GetInt("audit.backups", 5))
+	initLogger()
+	settingsMu.Lock()
+	hooks := append([]func(){}, settingsHooks...)
+	settingsMu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
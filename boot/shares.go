@@ -0,0 +1,30 @@
+package boot
+
+import (
+	"b0pass/library/shares"
+	"github.com/gogf/gf/frame/g"
+	"time"
+)
+
+// Shares 分享链接及其访问统计
+var Shares *shares.Store
+
+// ShareGuard 限制访问不存在的分享链接的频率
+var ShareGuard *shares.Guard
+
+// initShares 加载分享链接
+func initShares() {
+	Shares = shares.OpenStore(PathRoot + "/tmp/data/shares.json")
+	ShareGuard = &shares.Guard{Max: g.Config().GetInt("share.misses", 20), Window: time.Minute}
+}
+
+// ShareTTL 分享链接的有效期，hours为0时使用share.ttl，为负数或share.ttl为0时不过期
+func ShareTTL(hours int) time.Duration {
+	if hours == 0 {
+		hours = g.Config().GetInt("share.ttl", 168)
+	}
+	if hours < 0 {
+		return 0
+	}
+	return time.Duration(hours) * time.Hour
+}
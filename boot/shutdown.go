@@ -0,0 +1,109 @@
+package boot
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	stopping  int32
+	transfers int64
+
+	shutdownMu    sync.Mutex
+	shutdownHooks []func()
+)
+
+// BeginTransfer 开始一个传输(阻止休眠并计入退出时等待的传输)，返回结束函数(可重复调用)
+func BeginTransfer() func() {
+	atomic.AddInt64(&transfers, 1)
+	release := Awake.Acquire()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			release()
+			atomic.AddInt64(&transfers, -1)
+		})
+	}
+}
+
+// Transfers 进行中的传输数
+func Transfers() int64 {
+	return atomic.LoadInt64(&transfers)
+}
+
+// Stopping 是否正在退出，退出期间拒绝新请求
+func Stopping() bool {
+	return atomic.LoadInt32(&stopping) == 1
+}
+
+// OnShutdown 注册开始退出时的回调(协议服务停止监听、关闭界面等)，按注册的相反顺序调用
+func OnShutdown(fn func()) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	shutdownHooks = append(shutdownHooks, fn)
+}
+
+// watchSignals 接管SIGINT/SIGTERM，第一次收到时平滑退出，再次收到时立即退出
+// gf在服务启动时注册信号处理并直接关闭所有连接，因此等服务启动后重置为自己的处理
+func watchSignals() {
+	for g.Server().Status() != ghttp.SERVER_STATUS_RUNNING {
+		time.Sleep(100 * time.Millisecond)
+	}
+	// gf在单独的协程中注册信号处理，稍等确保其已注册后再重置
+	time.Sleep(time.Second)
+	signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	for sig := range ch {
+		if Stopping() {
+			logger.Warn("shutdown", "forced", "signal", sig)
+			os.Exit(1)
+		}
+		go Shutdown(sig.String())
+	}
+}
+
+// Shutdown 平滑退出: 拒绝新请求并调用退出回调，等待进行中的传输完成(最长setting.drain秒)，
+// 清理未完成上传的临时文件后结束进程
+func Shutdown(reason string) {
+	if !atomic.CompareAndSwapInt32(&stopping, 0, 1) {
+		return
+	}
+	shutdownMu.Lock()
+	hooks := append([]func(){}, shutdownHooks...)
+	shutdownMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+
+	drain := time.Duration(g.Config().GetInt("setting.drain", 30)) * time.Second
+	logger.Info("shutdown", "draining", "reason", reason, "transfers", Transfers(), "timeout", drain)
+	deadline := time.Now().Add(drain)
+	for Transfers() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if n := Transfers(); n > 0 {
+		logger.Warn("shutdown", "drain timeout", "aborted", n)
+	}
+
+	// 中断的上传不会再完成，断点续传的暂存文件保留
+	tmpDir := g.Config().GetString("upload.tmpdir")
+	for _, root := range fileinfos.Roots() {
+		n, err := fileinfos.RemoveTemps(fileinfos.TempDir(root.Path, tmpDir))
+		if err != nil {
+			logger.Error("shutdown", "remove temp files", "err", err)
+		} else if n > 0 {
+			logger.Info("shutdown", "removed partial uploads", "root", root.Name, "count", n)
+		}
+	}
+	logger.Info("shutdown", "done")
+	os.Exit(0)
+}
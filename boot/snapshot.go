@@ -0,0 +1,136 @@
+package boot
+
+import (
+	"b0pass/library/fsync"
+	"b0pass/library/grants"
+	"b0pass/library/logger"
+	"encoding/json"
+	"github.com/gogf/gf/frame/g"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// 运行时状态快照: 计划重启前保存设置、会话与传输队列，下次启动时恢复
+
+// stateProvider 一类运行时状态的保存与恢复
+type stateProvider struct {
+	name    string
+	save    func() interface{}
+	restore func(b json.RawMessage) (int, error)
+}
+
+var (
+	stateMu        sync.Mutex
+	stateProviders []stateProvider
+)
+
+// Snapshot 快照文件内容
+type Snapshot struct {
+	Time  time.Time                  `json:"time"`
+	State map[string]json.RawMessage `json:"state"`
+}
+
+// RegisterState 注册需要快照的运行时状态，restore返回恢复的条目数
+func RegisterState(name string, save func() interface{}, restore func(b json.RawMessage) (int, error)) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	stateProviders = append(stateProviders, stateProvider{name, save, restore})
+}
+
+// SnapshotFile 快照文件
+func SnapshotFile() string {
+	return PathRoot + "/tmp/data/snapshot.json"
+}
+
+// SaveSnapshot 保存所有运行时状态，原子替换文件，不会留下写了一半的快照
+// 保存期间持有配置锁，避免与运行中修改设置交错
+func SaveSnapshot() (*Snapshot, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	snap := &Snapshot{Time: time.Now(), State: make(map[string]json.RawMessage)}
+	for _, p := range stateProviders {
+		b, err := json.Marshal(p.save())
+		if err != nil {
+			return nil, err
+		}
+		snap.State[p.name] = b
+	}
+	b, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := fsync.WriteFile(SnapshotFile(), b, 0600); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// restoreSnapshot 启动时恢复快照(不超过setting.snapshot小时)，恢复后改名为.restored，不会重复恢复
+func restoreSnapshot() {
+	file := SnapshotFile()
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	defer func() { _ = os.Rename(file, file+".restored") }()
+	var snap Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		logger.Error("snapshot", "load", "err", err)
+		return
+	}
+	maxAge := time.Duration(g.Config().GetInt("setting.snapshot", 24)) * time.Hour
+	if maxAge <= 0 || time.Since(snap.Time) > maxAge {
+		logger.Warn("snapshot", "skipped stale snapshot", "time", snap.Time)
+		return
+	}
+	stateMu.Lock()
+	providers := append([]stateProvider{}, stateProviders...)
+	stateMu.Unlock()
+	for _, p := range providers {
+		raw, ok := snap.State[p.name]
+		if !ok {
+			continue
+		}
+		n, err := p.restore(raw)
+		if err != nil {
+			logger.Error("snapshot", "restore", "state", p.name, "err", err)
+			continue
+		}
+		logger.Info("snapshot", "restored", "state", p.name, "count", n)
+	}
+}
+
+func init() {
+	RegisterState("grants",
+		func() interface{} { return Grants.List() },
+		func(b json.RawMessage) (int, error) {
+			var list []grants.Grant
+			if err := json.Unmarshal(b, &list); err != nil {
+				return 0, err
+			}
+			return Grants.Restore(list, time.Now()), nil
+		})
+	RegisterState("mirror",
+		func() interface{} {
+			if Mirror == nil {
+				return []string{}
+			}
+			return Mirror.Pending()
+		},
+		func(b json.RawMessage) (int, error) {
+			var list []string
+			if err := json.Unmarshal(b, &list); err != nil || Mirror == nil {
+				return 0, err
+			}
+			for i, rel := range list {
+				if err := Mirror.Enqueue(rel); err != nil {
+					return i, err
+				}
+			}
+			return len(list), nil
+		})
+}
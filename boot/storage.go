@@ -0,0 +1,81 @@
+package boot
+
+import (
+	"b0pass/library/diskusage"
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/notify"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"time"
+)
+
+// Storage 默认(第一个)共享根目录所在磁盘的使用监控，上传暂存文件也写在该磁盘
+var Storage = &diskusage.Monitor{}
+
+// storages 各共享根目录所在磁盘的使用监控，第一个即Storage
+var storages []*diskusage.Monitor
+
+// initStorage 为每个共享根目录建立磁盘使用监控
+func initStorage() {
+	for i, r := range fileinfos.Roots() {
+		m := Storage
+		if i > 0 {
+			m = &diskusage.Monitor{}
+		}
+		m.Path = r.Path
+		storages = append(storages, m)
+	}
+	setStorageLimits()
+}
+
+// setStorageLimits 按配置设置各监控的告警与拒绝上传阈值
+func setStorageLimits() {
+	c := g.Config()
+	for _, m := range storages {
+		m.Warn = c.GetFloat64("storage.warn", 90)
+		m.Block = c.GetFloat64("storage.block", 97)
+	}
+}
+
+// StorageOf 文件所在共享根目录的磁盘使用监控，不在任何根目录下时为Storage
+func StorageOf(file string) *diskusage.Monitor {
+	if r, ok := fileinfos.Roots().Of(file); ok {
+		for _, m := range storages {
+			if m.Path == r.Path {
+				return m
+			}
+		}
+	}
+	return Storage
+}
+
+// watchStorage 定时检测各根目录的磁盘使用率，状态变化时发送通知
+func watchStorage() {
+	interval := time.Duration(g.Config().GetInt("storage.interval", 60)) * time.Second
+	s := fileinfos.Roots()
+	for {
+		for i, m := range storages {
+			changed, err := m.Check()
+			if err != nil {
+				logger.Error("storage", "check disk usage", "root", s[i].Name, "err", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			usage, level := m.State()
+			title := fmt.Sprintf("磁盘已使用 %.1f%%", usage.Percent)
+			if s.Multi() {
+				title = s[i].Name + ": " + title
+			}
+			notify.Send(notify.Event{
+				Type:  "storage",
+				Level: level,
+				Title: title,
+				Data:  usage,
+			})
+		}
+		time.Sleep(interval)
+	}
+}
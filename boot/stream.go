@@ -0,0 +1,49 @@
+package boot
+
+import (
+	"b0pass/library/limiter"
+	"b0pass/library/logger"
+	"b0pass/library/transcode"
+	"github.com/gogf/gf/frame/g"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// Prober 视频编码探测，ffprobe不存在时为nil
+	Prober *transcode.Prober
+	// Transcodes 同时进行的转码、转封装数限制
+	Transcodes *limiter.Limiter
+
+	ffmpegPath string
+	vargs      []string
+)
+
+// initStream 查找ffprobe与ffmpeg，stream.transcode为false或命令不存在时只能直接播放
+func initStream() {
+	cfg := g.Config()
+	max := cfg.GetInt("stream.max", 2)
+	Transcodes = limiter.New(max, max, 0)
+	if !cfg.GetBool("stream.transcode", true) {
+		return
+	}
+	probe, err := exec.LookPath(cfg.GetString("stream.ffprobe", "ffprobe"))
+	if err != nil {
+		logger.Debug("stream", "transcode", "skip", "ffprobe", "err", err)
+		return
+	}
+	if ffmpegPath, err = exec.LookPath(cfg.GetString("stream.ffmpeg", "ffmpeg")); err != nil {
+		logger.Debug("stream", "transcode", "skip", "ffmpeg", "err", err)
+		return
+	}
+	Prober = &transcode.Prober{Cmd: probe}
+	vargs = strings.Fields(cfg.GetString("stream.vargs"))
+}
+
+// FFmpeg 转码使用的ffmpeg路径及视频编码参数，未启用转码时路径为空
+func FFmpeg() (string, []string) {
+	if Prober == nil {
+		return "", nil
+	}
+	return ffmpegPath, vargs
+}
@@ -0,0 +1,60 @@
+package boot
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/thumbs"
+	"github.com/gogf/gf/frame/g"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+var (
+	thumbMu     sync.Mutex
+	thumbCaches = make(map[string]*thumbs.Cache)
+)
+
+// initThumbs 按thumb.decoders注册外部命令解码器，命令不存在时跳过
+// 每项格式为 "扩展名,扩展名: 命令 参数 {file}"，命令向标准输出写出PNG或JPEG
+func initThumbs() {
+	for _, s := range g.Config().GetStrings("thumb.decoders") {
+		p := strings.Index(s, ":")
+		if p < 0 {
+			logger.Error("thumb", "decoder", "err", "expect \"ext,ext: command args {file}\"", "value", s)
+			continue
+		}
+		args := strings.Fields(s[p+1:])
+		if len(args) == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(args[0]); err != nil {
+			logger.Debug("thumb", "decoder", "skip", args[0], "err", err)
+			continue
+		}
+		for _, ext := range strings.Split(s[:p], ",") {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				thumbs.Register(ext, thumbs.Command(args[0], args[1:]...))
+			}
+		}
+	}
+}
+
+// Thumbs 文件所在共享根目录的缩略图缓存，缓存目录为thumb.dir(默认根目录下的.thumbs)
+func Thumbs(file string) *thumbs.Cache {
+	dir := fileinfos.TempDir(fileinfos.RootOf(file), g.Config().GetString("thumb.dir", ".thumbs"))
+	thumbMu.Lock()
+	defer thumbMu.Unlock()
+	c, ok := thumbCaches[dir]
+	if !ok {
+		cfg := g.Config()
+		c = &thumbs.Cache{
+			Dir:       dir,
+			MaxBytes:  int64(cfg.GetInt("thumb.cache", 512)) << 20,
+			MaxPixels: int64(cfg.GetInt("thumb.pixels", 100)) * 1000000,
+			Quality:   cfg.GetInt("thumb.quality", 80),
+		}
+		thumbCaches[dir] = c
+	}
+	return c
+}
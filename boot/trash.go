@@ -0,0 +1,50 @@
+package boot
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/trash"
+	"github.com/gogf/gf/frame/g"
+	"time"
+)
+
+// TrashBin 文件所在共享根目录的回收站，目录为trash.dir(默认根目录下的.trash)
+func TrashBin(file string) trash.Bin {
+	return trash.Bin{Dir: fileinfos.TempDir(fileinfos.RootOf(file), g.Config().GetString("trash.dir", ".trash"))}
+}
+
+// TrashBins 各共享根目录的回收站，trash.dir为绝对路径时只有一个
+func TrashBins() []trash.Bin {
+	var bins []trash.Bin
+	seen := make(map[string]bool)
+	for _, r := range fileinfos.Roots() {
+		b := TrashBin(r.Path)
+		if !seen[b.Dir] {
+			seen[b.Dir] = true
+			bins = append(bins, b)
+		}
+	}
+	return bins
+}
+
+// watchTrash 按保留策略定期清理回收站: 删除超过trash.days天的项，总大小超过trash.maxsize(MB)时从最早删除的开始清理
+func watchTrash() {
+	for {
+		c := g.Config()
+		var before time.Time
+		if days := c.GetInt("trash.days", 30); days > 0 {
+			before = time.Now().AddDate(0, 0, -days)
+		}
+		maxSize := c.GetInt64("trash.maxsize", 0) << 20
+		for _, b := range TrashBins() {
+			purged, err := b.Expire(before, maxSize)
+			if err != nil {
+				logger.Error("trash", "expire", "dir", b.Dir, "err", err)
+			}
+			for _, item := range purged {
+				logger.Info("trash", "purge", "path", item.Path, "id", item.ID, "size", item.Size)
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,104 @@
+package boot
+
+import (
+	"b0pass/library/logger"
+	"b0pass/library/proxy"
+	"b0pass/library/tunnel"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Tunnel 经反向隧道转发到本机的连接，未启用隧道时为nil
+var Tunnel *tunnel.Local
+
+var (
+	tunnelMu  sync.RWMutex
+	tunnelURL string
+)
+
+// TunnelURL 经隧道的公网访问地址，未启用或尚未连接时为空
+func TunnelURL() string {
+	tunnelMu.RLock()
+	defer tunnelMu.RUnlock()
+	return tunnelURL
+}
+
+// setTunnelURL 隧道连接后输出公网地址及二维码
+func setTunnelURL(u string) {
+	u = strings.TrimSuffix(u, "/") + BasePath + "/"
+	tunnelMu.Lock()
+	changed := u != tunnelURL
+	tunnelURL = u
+	tunnelMu.Unlock()
+	logger.Info("tunnel", "connected", "url", u)
+	if changed {
+		fmt.Printf("[Tunnel-Url] %s\n", u)
+		PrintQR(u)
+	}
+}
+
+// watchTunnel 按配置连接公网隧道服务器(ws)或建立SSH远程端口转发(ssh)
+func watchTunnel() {
+	c := g.Config()
+	mode := c.GetString("tunnel.mode")
+	if mode == "" {
+		return
+	}
+	local := tunnel.NewLocal("127.0.0.1:" + strconv.Itoa(ServPort))
+	Tunnel = local
+	stop := make(chan struct{})
+	onError := func(err error) {
+		logger.Warn("tunnel", "disconnected", "mode", mode, "err", err)
+	}
+	switch mode {
+	case "ws":
+		if c.GetString("tunnel.server") == "" {
+			logger.Error("tunnel", "config", "err", "tunnel.server须为隧道服务器地址，如 wss://tunnel.example.com/tunnel")
+			return
+		}
+		cl := tunnel.NewClient(c.GetString("tunnel.server"), c.GetString("tunnel.token"), c.GetInt("tunnel.port"), local)
+		go cl.Run(stop, func(u string) {
+			if v := c.GetString("tunnel.url"); v != "" {
+				u = v
+			}
+			setTunnelURL(u)
+		}, onError)
+	case "ssh":
+		s := &tunnel.SSH{Target: c.GetString("tunnel.server"), Remote: c.GetInt("tunnel.port"), Key: c.GetString("tunnel.key"), Local: local}
+		u := c.GetString("tunnel.url")
+		if u == "" {
+			u = s.URL()
+		}
+		if err := s.Run(stop, func() { setTunnelURL(u) }, onError); err != nil {
+			logger.Error("tunnel", "ssh", "err", err)
+			return
+		}
+	default:
+		logger.Error("tunnel", "config", "mode", mode, "err", "须为ws或ssh")
+		return
+	}
+	OnShutdown(func() { close(stop) })
+}
+
+// TunnelOrigin 经隧道的请求在本机看来来自回环地址，改为公网客户端地址并删除X-Forwarded-*，
+// 避免被当作本机访问；返回是否经过隧道
+func TunnelOrigin(r *http.Request) bool {
+	if Tunnel == nil {
+		return false
+	}
+	origin, ok := Tunnel.Origin(r.RemoteAddr)
+	if !ok {
+		return false
+	}
+	for _, h := range []string{proxy.HeaderFor, proxy.HeaderProto, proxy.HeaderHost, "Forwarded"} {
+		r.Header.Del(h)
+	}
+	r.RemoteAddr = net.JoinHostPort(origin, "0")
+	r.Header.Set(proxy.HeaderReal, origin)
+	return true
+}
@@ -0,0 +1,159 @@
+package boot
+
+import (
+	"b0pass/library/logger"
+	"b0pass/ui"
+	"bytes"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/os/gfile"
+	"github.com/gogf/gf/os/gfsnotify"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Dev 开发模式(--dev): 页面与模板直接取自源码目录ui/，修改后自动刷新浏览器
+var Dev bool
+
+// uiRoot 网页界面所在目录，含public与template: 嵌入资源解压的目录，开发模式下为源码目录
+var uiRoot string
+
+// DevID 本次启动的标识，开发模式下浏览器重连后发现变化(程序已重新编译运行)时刷新页面
+var DevID = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+var (
+	devMu   sync.Mutex
+	devSubs = make(map[chan struct{}]struct{})
+)
+
+// initUI 准备网页界面: 默认把嵌入的资源解压到tmp/ui下按内容摘要命名的目录(已存在时直接使用)，
+// 不直接从内存提供是因为框架的资源管理器在并发读取同一文件时共用读取位置；开发模式下使用源码目录并监听修改
+func initUI() {
+	Dev = g.Config().GetBool("ui.dev")
+	if Dev {
+		dir, err := gfile.Search("ui")
+		if err != nil || !gfile.IsDir(filepath.Join(dir, "public")) || !gfile.IsDir(filepath.Join(dir, "template")) {
+			fmt.Fprintln(os.Stderr, "[UI] ERR: --dev须在源码目录下运行(需要ui/public与ui/template)")
+			os.Exit(2)
+		}
+		uiRoot = dir
+		watchUI()
+		logger.Info("ui", "dev mode", "dir", dir)
+		return
+	}
+	dir, err := extractUI(filepath.Join(PathRoot, "tmp", "ui"))
+	if err != nil {
+		// 程序目录不可写时解压到系统临时目录
+		logger.Warn("ui", "extract", "err", err)
+		dir, err = extractUI(filepath.Join(os.TempDir(), "b0pass-ui"))
+	}
+	if err != nil {
+		panic(err)
+	}
+	uiRoot = dir
+}
+
+// extractUI 解压嵌入的界面到parent下以版本命名的目录，并删除其他版本
+func extractUI(parent string) (string, error) {
+	dir := filepath.Join(parent, ui.Version())
+	if gfile.IsDir(dir) {
+		return dir, nil
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempDir(parent, ".extract-")
+	if err != nil {
+		return "", err
+	}
+	if err := ui.Extract(tmp); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		_ = os.RemoveAll(tmp)
+		// 同时启动的另一进程已解压
+		if gfile.IsDir(dir) {
+			return dir, nil
+		}
+		return "", err
+	}
+	if entries, err := ioutil.ReadDir(parent); err == nil {
+		for _, e := range entries {
+			if e.Name() != ui.Version() {
+				_ = os.RemoveAll(filepath.Join(parent, e.Name()))
+			}
+		}
+	}
+	return dir, nil
+}
+
+// UIPath 网页界面中文件的路径，如 UIPath("public/page/get.html")
+func UIPath(name string) string {
+	return filepath.Join(uiRoot, filepath.FromSlash(name))
+}
+
+// watchUI 开发模式下监听源码目录，文件变化后(合并100毫秒内的多次变化)通知已打开的页面刷新
+func watchUI() {
+	var (
+		mu    sync.Mutex
+		timer *time.Timer
+	)
+	_, err := gfsnotify.Add(uiRoot, func(e *gfsnotify.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(100*time.Millisecond, func() {
+			logger.Debug("ui", "changed", "file", e.Path)
+			devMu.Lock()
+			defer devMu.Unlock()
+			for ch := range devSubs {
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		})
+	}, true)
+	if err != nil {
+		logger.Warn("ui", "watch", "dir", uiRoot, "err", err)
+	}
+}
+
+// DevChanges 订阅开发模式下的界面文件变化，返回的函数取消订阅
+func DevChanges() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	devMu.Lock()
+	devSubs[ch] = struct{}{}
+	devMu.Unlock()
+	return ch, func() {
+		devMu.Lock()
+		delete(devSubs, ch)
+		devMu.Unlock()
+	}
+}
+
+// DevInject 开发模式下在页面末尾加入自动刷新的脚本
+func DevInject(page []byte) []byte {
+	script := []byte(`<script>(function () {
+	// 开发模式(--dev): 界面文件变化或程序重启后刷新页面
+	var id;
+	new EventSource("` + BasePath + `/api/dev/reload").onmessage = function (e) {
+		if (e.data === "reload" || (id && id !== e.data)) {
+			location.reload();
+		}
+		id = e.data;
+	};
+})();</script>
+`)
+	if i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>")); i >= 0 {
+		return append(page[:i:i], append(script, page[i:]...)...)
+	}
+	return append(page, script...)
+}
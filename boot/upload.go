@@ -0,0 +1,52 @@
+package boot
+
+import (
+	"b0pass/library/filename"
+	"b0pass/library/humanize"
+	"b0pass/library/limiter"
+	"b0pass/library/logger"
+	"github.com/gogf/gf/frame/g"
+	"strings"
+	"time"
+)
+
+// MaxUploadSize 命令行指定的单次上传大小上限，优先于upload.maxsize
+var MaxUploadSize string
+
+// UploadLimit 单次上传的最大字节数，0为不限制
+func UploadLimit() int64 {
+	s := MaxUploadSize
+	if s == "" {
+		s = g.Config().GetString("upload.maxsize", "0")
+	}
+	n, err := humanize.ParseSize(s)
+	if err != nil {
+		logger.Warn("upload", "invalid max upload size", "value", s, "err", err)
+		return 0
+	}
+	return n
+}
+
+// UploadSlots 同时进行的上传数(upload.slots)，超出的设备排队
+var UploadSlots *limiter.Slots
+
+// initUploadSlots 排队的设备在等待与重试间隔的两倍时间内未重试时移出队列
+func initUploadSlots() {
+	c := g.Config()
+	ttl := 2 * time.Duration(c.GetInt("upload.wait", 5)+c.GetInt("upload.retry", 3)) * time.Second
+	UploadSlots = limiter.NewSlots(c.GetInt("upload.slots"), c.GetInt("upload.queue", 64), ttl)
+}
+
+// FilenameRules 上传文件名的调整规则([filename])
+func FilenameRules() filename.Options {
+	c := g.Config()
+	o := filename.Options{
+		Form:     strings.ToLower(c.GetString("filename.normalize", filename.NFC)),
+		Portable: c.GetBool("filename.portable", true),
+		MaxBytes: c.GetInt("filename.maxbytes", 255),
+	}
+	if o.Form != filename.NFC && o.Form != filename.NFD {
+		o.Form = ""
+	}
+	return o
+}
@@ -0,0 +1,34 @@
+package boot
+
+import (
+	"b0pass/library/logger"
+	"b0pass/library/webhook"
+	"github.com/gogf/gf/frame/g"
+	"time"
+)
+
+// Webhooks 文件事件的Webhook投递
+var Webhooks = webhook.New(nil)
+
+// initWebhooks 按[[webhooks]]配置启用投递，运行中修改设置后重新加载
+func initWebhooks() {
+	loadWebhooks()
+	Webhooks.OnError = func(h webhook.Hook, e webhook.Event, err error) {
+		logger.Error("webhook", "deliver", "url", h.URL, "event", e.Event, "path", e.Path, "err", err)
+	}
+	Webhooks.Start(2)
+	OnSettings(loadWebhooks)
+}
+
+// loadWebhooks 读取接收地址与重试设置
+func loadWebhooks() {
+	c := g.Config()
+	hooks, err := webhook.Parse(c.GetArray("webhooks"))
+	if err != nil {
+		logger.Error("webhook", "parse", "err", err)
+		return
+	}
+	Webhooks.Retries = c.GetInt("webhook.retries", 3)
+	Webhooks.Client.Timeout = time.Duration(c.GetInt("webhook.timeout", 10)) * time.Second
+	Webhooks.SetHooks(hooks)
+}
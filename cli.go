@@ -1,13 +1,16 @@
 package main
 
 import (
+	"b0pass/apps/cli"
 	"b0pass/boot"
 	_ "b0pass/boot"
 	"b0pass/library/ipaddress"
 	"b0pass/library/openurl"
 	_ "b0pass/router"
+	"flag"
 	"fmt"
 	"github.com/gogf/gf/frame/g"
+	"os"
 	"strconv"
 	"time"
 )
@@ -15,14 +18,21 @@ import (
 func main() {
 	//Cli Args
 	boot.ExecArgs()
+	if boot.Command != "" {
+		os.Exit(cli.Run(boot.Command, flag.Args()[1:]))
+	}
 	ipArr,_:=ipaddress.GetIP()
-	fmt.Printf("[ServerUrl] http://127.0.0.1:%d\n",boot.ServPort)
+	fmt.Printf("[ServerUrl] http://127.0.0.1:%d%s/\n",boot.ServPort,boot.BasePath)
+	if u := boot.HTTPSURL(); u != "" {
+		fmt.Printf("[HTTPS-Url] %s\n", u)
+	}
 	fmt.Printf("[IPlistArr] %v\n",ipArr)
 	fmt.Printf("[Work-Path] %s\n",boot.PathRoot)
+	boot.PrintQR(boot.AccessURL(boot.ServPort, boot.BasePath+"/"))
 	//Open Urls
 	go func() {
 		time.Sleep(4000 * time.Millisecond)
-		_ = openurl.Open("http://127.0.0.1:" + strconv.Itoa(boot.ServPort))
+		_ = openurl.Open("http://127.0.0.1:" + strconv.Itoa(boot.ServPort) + boot.BasePath + "/")
 	}()
 	g.Wait()
 }
@@ -3,6 +3,6 @@
 # 进入工作目录
 cd ../../
 
-# 打包静态资源
-echo y | gf pack public,template,config boot/resource.go -n=boot
-# echo y | gf pack public,template,config resource.go -n=main
\ No newline at end of file
+# 打包配置文件(网页界面ui/public、ui/template已由ui包嵌入，无需打包)
+echo y | gf pack config boot/resource.go -n=boot
+# echo y | gf pack config resource.go -n=main
@@ -1,10 +1,17 @@
 module b0pass
 
-go 1.12
+go 1.16
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/dgraph-io/badger v1.6.0
+	github.com/gf-third/yaml v1.0.1
 	github.com/gogf/gf v1.9.10
+	github.com/gorilla/websocket v1.4.1
+	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/quic-go/quic-go v0.59.1
 	github.com/xujiajun/nutsdb v0.4.0
 	github.com/zserge/lorca v0.1.8
+	golang.org/x/net v0.43.0
+	golang.org/x/sys v0.35.0
 )
@@ -0,0 +1,233 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// fakeCA 最小的ACME服务: 校验JWS签名，验证时直接读取Manager中的令牌，签发由测试CA签名的证书
+type fakeCA struct {
+	t        *testing.T
+	srv      *httptest.Server
+	m        *Manager
+	account  *ecdsa.PublicKey
+	badNonce bool
+	valid    bool
+	cert     []byte
+	caKey    *ecdsa.PrivateKey
+	ca       *x509.Certificate
+}
+
+func newFakeCA(t *testing.T, m *Manager) *fakeCA {
+	f := &fakeCA{t: t, m: m, badNonce: true}
+	f.caKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	f.ca = &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test ca"}, IsCA: true,
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour), BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeCA) url(p string) string { return f.srv.URL + p }
+
+// payload 校验JWS并返回载荷
+func (f *fakeCA) payload(r *http.Request) []byte {
+	var jws struct{ Protected, Payload, Signature string }
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		f.t.Fatal(err)
+	}
+	ph, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	var protected struct {
+		Alg, Nonce, URL, Kid string
+		Jwk                  map[string]string
+	}
+	_ = json.Unmarshal(ph, &protected)
+	if protected.Alg != "ES256" || protected.Nonce == "" || protected.URL != f.url(r.URL.Path) {
+		f.t.Fatalf("bad protected header %s", ph)
+	}
+	if protected.Jwk != nil {
+		x, _ := base64.RawURLEncoding.DecodeString(protected.Jwk["x"])
+		y, _ := base64.RawURLEncoding.DecodeString(protected.Jwk["y"])
+		f.account = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	} else if protected.Kid != f.url("/acct/1") {
+		f.t.Fatalf("bad kid %q", protected.Kid)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(jws.Signature)
+	sum := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	if len(sig) != 64 || !ecdsa.Verify(f.account, sum[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		f.t.Fatal("bad signature")
+	}
+	b, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+	return b
+}
+
+func (f *fakeCA) order() map[string]interface{} {
+	o := map[string]interface{}{"status": "pending", "authorizations": []string{f.url("/authz/1")}, "finalize": f.url("/finalize")}
+	if f.cert != nil {
+		o["status"], o["certificate"] = "valid", f.url("/cert")
+	}
+	return o
+}
+
+func (f *fakeCA) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", "n"+time.Now().Format("150405.000000"))
+	if r.URL.Path == "/dir" {
+		_ = json.NewEncoder(w).Encode(map[string]string{"newNonce": f.url("/nonce"), "newAccount": f.url("/account"), "newOrder": f.url("/order")})
+		return
+	}
+	if r.URL.Path == "/nonce" {
+		return
+	}
+	body := f.payload(r)
+	var out interface{}
+	switch r.URL.Path {
+	case "/account":
+		if f.badNonce {
+			f.badNonce = false
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"type":"urn:ietf:params:acme:error:badNonce"}`))
+			return
+		}
+		w.Header().Set("Location", f.url("/acct/1"))
+		out = map[string]string{"status": "valid"}
+	case "/order", "/order/1":
+		w.Header().Set("Location", f.url("/order/1"))
+		out = f.order()
+	case "/authz/1":
+		status := "pending"
+		if f.valid {
+			status = "valid"
+		}
+		out = map[string]interface{}{"status": status, "identifier": Identifier{Type: "dns", Value: "b0pass.example"},
+			"challenges": []Challenge{{Type: ChallengeHTTP, URL: f.url("/chall/1"), Token: "tok1"}, {Type: ChallengeALPN, URL: f.url("/chall/2"), Token: "tok2"}}}
+	case "/chall/1":
+		// http-01: 读取Manager提供的令牌内容
+		v, ok := f.m.Token("tok1")
+		f.valid = ok && v == "tok1."+Thumbprint(f.account)
+		out = map[string]string{}
+	case "/finalize":
+		var req struct{ CSR string }
+		_ = json.Unmarshal(body, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil || csr.CheckSignature() != nil || len(csr.DNSNames) != 1 || csr.DNSNames[0] != "b0pass.example" {
+			f.t.Fatalf("bad csr %v", err)
+		}
+		tpl := &x509.Certificate{SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "b0pass.example"}, DNSNames: csr.DNSNames,
+			NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(90 * 24 * time.Hour)}
+		f.cert, _ = x509.CreateCertificate(rand.Reader, tpl, f.ca, csr.PublicKey, f.caKey)
+		out = f.order()
+	case "/cert":
+		_, _ = w.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.cert}))
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.URL.Path == "/account" || r.URL.Path == "/order" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func TestRenew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := NewManager([]string{"b0pass.example"}, "me@example.com", "", dir, "", 0)
+	f := newFakeCA(t, m)
+	defer f.srv.Close()
+	m.Directory = f.url("/dir")
+	key, _ := loadKey(dir + "/account.pem")
+	m.client = NewClient(m.Directory, key)
+	m.client.Interval = 10 * time.Millisecond
+
+	if !m.NeedRenew() {
+		t.Fatal("should need a certificate")
+	}
+	if c, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "b0pass.example"}); c != nil || err != nil {
+		t.Fatal("expected no certificate before obtaining", err)
+	}
+	if err := m.Renew(); err != nil {
+		t.Fatal(err)
+	}
+	if m.NeedRenew() || !f.valid {
+		t.Fatal(m.Expires(), f.valid)
+	}
+	if _, ok := m.Token("tok1"); ok {
+		t.Fatal("token should be removed after validation")
+	}
+	c, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "b0pass.example"})
+	if err != nil || c.Leaf.Subject.CommonName != "b0pass.example" {
+		t.Fatal(c, err)
+	}
+
+	// 重启后从缓存读取，账号密钥不变
+	m2 := NewManager([]string{"b0pass.example"}, "", "", dir, "", 0)
+	if err := m2.Load(); err != nil || m2.NeedRenew() {
+		t.Fatal(err, m2.Expires())
+	}
+	key2, err := loadKey(dir + "/account.pem")
+	if err != nil || key2.D.Cmp(key.D) != 0 {
+		t.Fatal("account key changed", err)
+	}
+	// 缓存的证书不包含配置的全部域名时重新申请
+	m3 := NewManager([]string{"b0pass.example", "www.b0pass.example"}, "", "", dir, "", 0)
+	if err := m3.Load(); err != nil || !m3.NeedRenew() {
+		t.Fatal(err, m3.Expires())
+	}
+}
+
+func TestALPN(t *testing.T) {
+	m := NewManager([]string{"b0pass.example"}, "", "", "", ChallengeALPN, 0)
+	if err := m.Present("B0pass.example", "tok", "tok.thumb"); err != nil {
+		t.Fatal(err)
+	}
+	hello := &tls.ClientHelloInfo{ServerName: "b0pass.example", SupportedProtos: []string{ALPNProto}}
+	c, err := m.GetCertificate(hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(c.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256([]byte("tok.thumb"))
+	found := false
+	for _, e := range leaf.Extensions {
+		if e.Id.Equal(idPeAcmeIdentifier) {
+			var got []byte
+			_, _ = asn1.Unmarshal(e.Value, &got)
+			found = e.Critical && string(got) == string(want[:])
+		}
+	}
+	if !found || leaf.DNSNames[0] != "B0pass.example" {
+		t.Fatal("missing acmeIdentifier extension")
+	}
+	// 普通握手不返回验证证书
+	if c, _ := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "b0pass.example"}); c != nil {
+		t.Fatal("challenge certificate served for normal handshake")
+	}
+	m.CleanUp("b0pass.example", "tok")
+	if _, err := m.GetCertificate(hello); err == nil {
+		t.Fatal("challenge should be removed")
+	}
+}
@@ -0,0 +1,373 @@
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ACME(RFC 8555)客户端: 注册账号、下单、完成域名验证、提交CSR并下载证书
+
+// LetsEncrypt Let's Encrypt的正式服务地址
+const LetsEncrypt = "https://acme-v02.api.letsencrypt.org/directory"
+
+// 验证方式
+const (
+	ChallengeHTTP = "http-01"
+	ChallengeALPN = "tls-alpn-01"
+)
+
+// maxBody 响应的最大字节数
+const maxBody = 1 << 20
+
+// Problem ACME服务返回的错误(RFC 7807)
+type Problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("acme: %s: %s", p.Type, p.Detail)
+}
+
+// Identifier 证书包含的域名
+type Identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Order 证书订单
+type Order struct {
+	Status         string       `json:"status"`
+	Identifiers    []Identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate,omitempty"`
+	Error          *Problem     `json:"error,omitempty"`
+}
+
+// Authorization 单个域名的授权
+type Authorization struct {
+	Status     string      `json:"status"`
+	Identifier Identifier  `json:"identifier"`
+	Challenges []Challenge `json:"challenges"`
+}
+
+// Challenge 域名验证
+type Challenge struct {
+	Type   string   `json:"type"`
+	URL    string   `json:"url"`
+	Token  string   `json:"token"`
+	Status string   `json:"status"`
+	Error  *Problem `json:"error,omitempty"`
+}
+
+// Solver 完成域名验证: Present在验证前提供keyAuth(http-01通过HTTP路径，tls-alpn-01通过握手证书)，CleanUp在验证后移除
+type Solver interface {
+	Type() string
+	Present(domain, token, keyAuth string) error
+	CleanUp(domain, token string)
+}
+
+// Client ACME客户端
+type Client struct {
+	Directory string
+	Key       *ecdsa.PrivateKey // 账号密钥
+	HTTP      *http.Client
+	Interval  time.Duration // 轮询订单与授权状态的间隔
+	Timeout   time.Duration // 等待验证与签发的最长时间
+
+	dir struct {
+		NewNonce   string `json:"newNonce"`
+		NewAccount string `json:"newAccount"`
+		NewOrder   string `json:"newOrder"`
+	}
+	kid    string
+	mu     sync.Mutex
+	nonces []string
+}
+
+// NewClient 创建使用账号密钥key的客户端
+func NewClient(directory string, key *ecdsa.PrivateKey) *Client {
+	if directory == "" {
+		directory = LetsEncrypt
+	}
+	return &Client{
+		Directory: directory,
+		Key:       key,
+		HTTP:      &http.Client{Timeout: 30 * time.Second},
+		Interval:  2 * time.Second,
+		Timeout:   2 * time.Minute,
+	}
+}
+
+// KeyAuth 验证令牌对应的keyAuthorization
+func (c *Client) KeyAuth(token string) string {
+	return token + "." + Thumbprint(&c.Key.PublicKey)
+}
+
+// discover 读取服务目录
+func (c *Client) discover() error {
+	if c.dir.NewOrder != "" {
+		return nil
+	}
+	res, err := c.HTTP.Get(c.Directory)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("acme: directory %s: %s", c.Directory, res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(&c.dir); err != nil {
+		return err
+	}
+	if c.dir.NewNonce == "" || c.dir.NewAccount == "" || c.dir.NewOrder == "" {
+		return errors.New("acme: incomplete directory " + c.Directory)
+	}
+	return nil
+}
+
+// nonce 取一个防重放随机数，优先使用之前响应中返回的
+func (c *Client) nonce() (string, error) {
+	c.mu.Lock()
+	if n := len(c.nonces); n > 0 {
+		v := c.nonces[n-1]
+		c.nonces = c.nonces[:n-1]
+		c.mu.Unlock()
+		return v, nil
+	}
+	c.mu.Unlock()
+	res, err := c.HTTP.Head(c.dir.NewNonce)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	v := res.Header.Get("Replay-Nonce")
+	if v == "" {
+		return "", errors.New("acme: no nonce from " + c.dir.NewNonce)
+	}
+	return v, nil
+}
+
+// post 发送签名请求，payload为nil时为POST-as-GET；随机数失效(badNonce)时重试一次
+func (c *Client) post(url string, payload interface{}, out interface{}) (*http.Response, []byte, error) {
+	var body []byte
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		body = b
+	}
+	for retry := 0; ; retry++ {
+		nonce, err := c.nonce()
+		if err != nil {
+			return nil, nil, err
+		}
+		jws, err := sign(c.Key, url, nonce, c.kid, body)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := c.HTTP.Post(url, "application/jose+json", bytes.NewReader(jws))
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxBody))
+		res.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if v := res.Header.Get("Replay-Nonce"); v != "" {
+			c.mu.Lock()
+			c.nonces = append(c.nonces, v)
+			c.mu.Unlock()
+		}
+		if res.StatusCode >= 400 {
+			p := &Problem{Status: res.StatusCode}
+			if json.Unmarshal(data, p) != nil || p.Type == "" {
+				p.Type, p.Detail = "http", res.Status
+			}
+			if p.Type == "urn:ietf:params:acme:error:badNonce" && retry == 0 {
+				continue
+			}
+			return res, data, p
+		}
+		if out != nil {
+			if err := json.Unmarshal(data, out); err != nil {
+				return res, data, err
+			}
+		}
+		return res, data, nil
+	}
+}
+
+// Register 注册账号(账号已存在时返回已有账号)，同意服务条款
+func (c *Client) Register(email string) error {
+	if err := c.discover(); err != nil {
+		return err
+	}
+	req := map[string]interface{}{"termsOfServiceAgreed": true}
+	if email != "" {
+		req["contact"] = []string{"mailto:" + email}
+	}
+	c.kid = ""
+	res, _, err := c.post(c.dir.NewAccount, req, nil)
+	if err != nil {
+		return err
+	}
+	c.kid = res.Header.Get("Location")
+	if c.kid == "" {
+		return errors.New("acme: no account location")
+	}
+	return nil
+}
+
+// Obtain 为domains申请证书，key为证书私钥，返回DER格式的证书链(第一个为域名证书)
+func (c *Client) Obtain(domains []string, key crypto.Signer, solver Solver) ([][]byte, error) {
+	if c.kid == "" {
+		return nil, errors.New("acme: account not registered")
+	}
+	if len(domains) == 0 {
+		return nil, errors.New("acme: no domain")
+	}
+	ids := make([]Identifier, len(domains))
+	for i, d := range domains {
+		ids[i] = Identifier{Type: "dns", Value: d}
+	}
+	var order Order
+	res, _, err := c.post(c.dir.NewOrder, map[string]interface{}{"identifiers": ids}, &order)
+	if err != nil {
+		return nil, err
+	}
+	orderURL := res.Header.Get("Location")
+	for _, u := range order.Authorizations {
+		if err := c.authorize(u, solver); err != nil {
+			return nil, err
+		}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := c.post(order.Finalize, map[string]string{"csr": b64(csr)}, &order); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(c.Timeout)
+	for order.Status != "valid" {
+		if order.Status == "invalid" {
+			return nil, orderError(order)
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("acme: timeout waiting for certificate")
+		}
+		time.Sleep(c.Interval)
+		if _, _, err := c.post(orderURL, nil, &order); err != nil {
+			return nil, err
+		}
+	}
+	_, data, err := c.post(order.Certificate, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var chain [][]byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			chain = append(chain, block.Bytes)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("acme: no certificate in response")
+	}
+	return chain, nil
+}
+
+// authorize 完成单个域名的验证
+func (c *Client) authorize(url string, solver Solver) error {
+	var authz Authorization
+	if _, _, err := c.post(url, nil, &authz); err != nil {
+		return err
+	}
+	if authz.Status == "valid" {
+		return nil
+	}
+	var ch *Challenge
+	for i := range authz.Challenges {
+		if authz.Challenges[i].Type == solver.Type() {
+			ch = &authz.Challenges[i]
+			break
+		}
+	}
+	if ch == nil {
+		return fmt.Errorf("acme: %s not offered for %s", solver.Type(), authz.Identifier.Value)
+	}
+	domain := authz.Identifier.Value
+	if err := solver.Present(domain, ch.Token, c.KeyAuth(ch.Token)); err != nil {
+		return err
+	}
+	defer solver.CleanUp(domain, ch.Token)
+	if _, _, err := c.post(ch.URL, struct{}{}, nil); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(c.Timeout)
+	for {
+		time.Sleep(c.Interval)
+		if _, _, err := c.post(url, nil, &authz); err != nil {
+			return err
+		}
+		switch authz.Status {
+		case "valid":
+			return nil
+		case "pending", "processing":
+		default:
+			for _, v := range authz.Challenges {
+				if v.Error != nil {
+					return fmt.Errorf("acme: %s: %v", domain, v.Error)
+				}
+			}
+			return fmt.Errorf("acme: authorization for %s is %s", domain, authz.Status)
+		}
+		if time.Now().After(deadline) {
+			return errors.New("acme: timeout validating " + domain)
+		}
+	}
+}
+
+// orderError 订单失败的原因
+func orderError(o Order) error {
+	if o.Error != nil {
+		return o.Error
+	}
+	return errors.New("acme: order for " + strings.Join(domainsOf(o), ",") + " is invalid")
+}
+
+func domainsOf(o Order) []string {
+	list := make([]string, len(o.Identifiers))
+	for i, id := range o.Identifiers {
+		list[i] = id.Value
+	}
+	return list
+}
@@ -0,0 +1,73 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// b64 JWS使用的无填充base64url编码
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// pad 大整数补齐为固定长度的大端字节
+func pad(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	if len(b) >= size {
+		return b
+	}
+	out := make([]byte, size)
+	copy(out[size-len(b):], b)
+	return out
+}
+
+// jwk 账号公钥(P-256)的JWK，键按字典序排列，可直接用于计算指纹
+func jwk(pub *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"crv": "P-256",
+		"kty": "EC",
+		"x":   b64(pad(pub.X, 32)),
+		"y":   b64(pad(pub.Y, 32)),
+	}
+}
+
+// Thumbprint 账号公钥的JWK指纹(RFC 7638)
+func Thumbprint(pub *ecdsa.PublicKey) string {
+	b, _ := json.Marshal(jwk(pub))
+	sum := sha256.Sum256(b)
+	return b64(sum[:])
+}
+
+// sign 生成ES256签名的JWS(扁平JSON格式)，kid为空时携带jwk(注册账号)，payload为nil时为POST-as-GET
+func sign(key *ecdsa.PrivateKey, url, nonce, kid string, payload []byte) ([]byte, error) {
+	protected := map[string]interface{}{"alg": "ES256", "nonce": nonce, "url": url}
+	if kid != "" {
+		protected["kid"] = kid
+	} else {
+		protected["jwk"] = jwk(&key.PublicKey)
+	}
+	ph, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	p, pl := b64(ph), ""
+	if payload != nil {
+		pl = b64(payload)
+	}
+	h := crypto.SHA256.New()
+	h.Write([]byte(p + "." + pl))
+	r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]string{
+		"protected": p,
+		"payload":   pl,
+		"signature": b64(append(pad(r, 32), pad(s, 32)...)),
+	})
+}
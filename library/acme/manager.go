@@ -0,0 +1,303 @@
+package acme
+
+import (
+	"b0pass/library/fsync"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ALPNProto tls-alpn-01验证使用的ALPN协议，HTTPS服务的NextProtos须包含
+const ALPNProto = "acme-tls/1"
+
+// idPeAcmeIdentifier tls-alpn-01验证证书中keyAuth摘要的扩展(RFC 8737)
+var idPeAcmeIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+// Manager 为域名自动申请与续期证书，账号密钥与证书缓存在Dir，同时作为验证的Solver
+type Manager struct {
+	Domains     []string
+	Email       string
+	Directory   string
+	Dir         string
+	Challenge   string        // ChallengeHTTP或ChallengeALPN
+	RenewBefore time.Duration // 到期前多久续期
+
+	mu     sync.RWMutex
+	cert   *tls.Certificate
+	tokens map[string]string           // http-01: 令牌 -> keyAuth
+	alpn   map[string]*tls.Certificate // tls-alpn-01: 域名 -> 验证证书
+	client *Client                     // 首次续期时创建
+}
+
+// NewManager 创建证书管理，challenge为空时使用http-01
+func NewManager(domains []string, email, directory, dir, challenge string, renewBefore time.Duration) *Manager {
+	if challenge == "" {
+		challenge = ChallengeHTTP
+	}
+	if renewBefore <= 0 {
+		renewBefore = 30 * 24 * time.Hour
+	}
+	return &Manager{
+		Domains:     domains,
+		Email:       email,
+		Directory:   directory,
+		Dir:         dir,
+		Challenge:   challenge,
+		RenewBefore: renewBefore,
+		tokens:      map[string]string{},
+		alpn:        map[string]*tls.Certificate{},
+	}
+}
+
+// certFile 证书缓存文件，依次保存私钥与证书链
+func (m *Manager) certFile() string {
+	return filepath.Join(m.Dir, strings.Replace(m.Domains[0], "*", "_", -1)+".pem")
+}
+
+// Load 读取缓存的证书，证书不包含全部域名时视为没有证书
+func (m *Manager) Load() error {
+	b, err := ioutil.ReadFile(m.certFile())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cert, err := tls.X509KeyPair(b, b)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	for _, d := range m.Domains {
+		if leaf.VerifyHostname(d) != nil {
+			return nil
+		}
+	}
+	cert.Leaf = leaf
+	m.mu.Lock()
+	m.cert = &cert
+	m.mu.Unlock()
+	return nil
+}
+
+// Expires 当前证书的到期时间，没有证书时为零值
+func (m *Manager) Expires() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cert == nil {
+		return time.Time{}
+	}
+	return m.cert.Leaf.NotAfter
+}
+
+// NeedRenew 没有证书或即将到期
+func (m *Manager) NeedRenew() bool {
+	exp := m.Expires()
+	return exp.IsZero() || time.Until(exp) < m.RenewBefore
+}
+
+// Renew 申请新证书并保存
+func (m *Manager) Renew() error {
+	if len(m.Domains) == 0 {
+		return errors.New("acme: no domain")
+	}
+	c := m.client
+	if c == nil {
+		key, err := loadKey(filepath.Join(m.Dir, "account.pem"))
+		if err != nil {
+			return err
+		}
+		c = NewClient(m.Directory, key)
+		m.client = c
+	}
+	if err := c.Register(m.Email); err != nil {
+		return err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	chain, err := c.Obtain(m.Domains, key, m)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	for _, b := range chain {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: b})...)
+	}
+	if err := writeFile(m.certFile(), data); err != nil {
+		return err
+	}
+	return m.Load()
+}
+
+// Run 到期前自动续期，每12小时检查一次，失败时1小时后重试，stop关闭时返回
+func (m *Manager) Run(stop <-chan struct{}, onError func(error)) {
+	for {
+		wait := 12 * time.Hour
+		if m.NeedRenew() {
+			if err := m.Renew(); err != nil {
+				if onError != nil {
+					onError(err)
+				}
+				wait = time.Hour
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// GetCertificate 用于tls.Config: tls-alpn-01验证时返回验证证书，否则返回当前证书，
+// 尚未取得证书时返回nil(使用tls.Config.Certificates中的临时证书)
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == ALPNProto {
+		if c := m.alpn[strings.ToLower(hello.ServerName)]; c != nil {
+			return c, nil
+		}
+		return nil, errors.New("acme: no challenge for " + hello.ServerName)
+	}
+	return m.cert, nil
+}
+
+// Token http-01验证: /.well-known/acme-challenge/<token> 的响应内容
+func (m *Manager) Token(token string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.tokens[token]
+	return v, ok
+}
+
+// Type 实现Solver
+func (m *Manager) Type() string {
+	return m.Challenge
+}
+
+// Present 实现Solver，保存验证内容等待ACME服务访问
+func (m *Manager) Present(domain, token, keyAuth string) error {
+	if m.Challenge == ChallengeALPN {
+		cert, err := alpnCert(domain, keyAuth)
+		if err != nil {
+			return err
+		}
+		m.mu.Lock()
+		m.alpn[strings.ToLower(domain)] = cert
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Lock()
+	m.tokens[token] = keyAuth
+	m.mu.Unlock()
+	return nil
+}
+
+// CleanUp 实现Solver
+func (m *Manager) CleanUp(domain, token string) {
+	m.mu.Lock()
+	delete(m.tokens, token)
+	delete(m.alpn, strings.ToLower(domain))
+	m.mu.Unlock()
+}
+
+// alpnCert tls-alpn-01验证用的自签名证书，扩展中包含keyAuth的SHA-256
+func alpnCert(domain, keyAuth string) (*tls.Certificate, error) {
+	sum := sha256.Sum256([]byte(keyAuth))
+	ext, err := asn1.Marshal(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return selfSigned(domain, []pkix.Extension{{Id: idPeAcmeIdentifier, Critical: true, Value: ext}})
+}
+
+// SelfSigned 域名的临时自签名证书，用于取得正式证书之前(如客户端未发送SNI时)的握手
+func SelfSigned(domain string) (tls.Certificate, error) {
+	c, err := selfSigned(domain, nil)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return *c, nil
+}
+
+func selfSigned(domain string, exts []pkix.Extension) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:    serial,
+		Subject:         pkix.Name{CommonName: domain},
+		DNSNames:        []string{domain},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(7 * 24 * time.Hour),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtraExtensions: exts,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// loadKey 读取账号密钥，不存在时生成并保存
+func loadKey(file string) (*ecdsa.PrivateKey, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return key, writeFile(file, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("acme: no PEM data in " + file)
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+// writeFile 私钥文件只允许所有者读写，原子替换文件
+func writeFile(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return fsync.WriteFile(file, data, 0600)
+}
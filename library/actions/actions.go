@@ -0,0 +1,144 @@
+package actions
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// 文件操作菜单: 服务端按文件类型与请求方权限给出可用的操作，Web界面、命令行等客户端据此渲染一致的菜单
+
+// 能力标记
+const (
+	CapHost  = "host"  // 仅主电脑可用
+	CapWrite = "write" // 修改共享目录，只读模式下不可用
+	CapHeavy = "heavy" // CPU或IO密集，受预览限流
+	CapAsync = "async" // 后台执行，接口立即返回
+)
+
+// Target 操作对象
+type Target struct {
+	Path string // 访问路径，如 /files/a.jpg
+	Name string
+	Dir  bool
+	Size int64
+}
+
+// Ext 小写扩展名(含点)
+func (t Target) Ext() string {
+	return strings.ToLower(path.Ext(t.Name))
+}
+
+// Context 请求方的权限
+type Context struct {
+	Host     bool // 请求来自主电脑
+	Writable bool // 允许写操作
+}
+
+// Def 操作定义
+type Def struct {
+	ID     string
+	Title  string
+	Method string
+	Caps   []string
+	// Applies 是否适用于该对象(不适用时不出现在菜单中)，为空时适用于所有文件
+	Applies func(t Target) bool
+	// URL 执行操作的地址
+	URL func(t Target) string
+	// Check 不可用的原因，为空时可用(可选)
+	Check func(t Target, c Context) string
+}
+
+// Action 对象的一项操作
+type Action struct {
+	ID      string   `json:"id"`
+	Title   string   `json:"title"`
+	Method  string   `json:"method"`
+	URL     string   `json:"url"`
+	Caps    []string `json:"caps"`
+	Enabled bool     `json:"enabled"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+// Registry 操作注册表，按注册顺序排列
+type Registry struct {
+	mu   sync.RWMutex
+	defs []Def
+}
+
+// Register 注册操作，ID相同时替换原有定义(保持原位置)
+func (r *Registry) Register(d Def) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d.Caps == nil {
+		d.Caps = []string{}
+	}
+	for i := range r.defs {
+		if r.defs[i].ID == d.ID {
+			r.defs[i] = d
+			return
+		}
+	}
+	r.defs = append(r.defs, d)
+}
+
+// Defs 全部操作定义
+func (r *Registry) Defs() []Def {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Def{}, r.defs...)
+}
+
+// For 对象的可用操作
+func (r *Registry) For(t Target, c Context) []Action {
+	list := make([]Action, 0)
+	for _, d := range r.Defs() {
+		if d.Applies != nil && !d.Applies(t) {
+			continue
+		}
+		if d.Applies == nil && t.Dir {
+			continue
+		}
+		a := Action{ID: d.ID, Title: d.Title, Method: d.Method, Caps: d.Caps, Enabled: true}
+		if d.URL != nil {
+			a.URL = d.URL(t)
+		}
+		if d.Check != nil {
+			a.Reason = d.Check(t, c)
+		}
+		if a.Reason == "" && hasCap(d.Caps, CapWrite) && !c.Writable {
+			a.Reason = "只读模式"
+		}
+		if a.Reason == "" && hasCap(d.Caps, CapHost) && !c.Host {
+			a.Reason = "请在主电脑上操作"
+		}
+		a.Enabled = a.Reason == ""
+		list = append(list, a)
+	}
+	return list
+}
+
+func hasCap(caps []string, c string) bool {
+	for _, v := range caps {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Exts 按扩展名判断适用的对象(不含目录)
+func Exts(exts ...string) func(t Target) bool {
+	return func(t Target) bool {
+		if t.Dir {
+			return false
+		}
+		ext := t.Ext()
+		for _, e := range exts {
+			if e == ext {
+				return true
+			}
+		}
+		return false
+	}
+}
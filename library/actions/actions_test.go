@@ -0,0 +1,43 @@
+package actions
+
+import "testing"
+
+func TestRegistry(t *testing.T) {
+	r := &Registry{}
+	r.Register(Def{ID: "download", Method: "GET", URL: func(t Target) string { return t.Path }})
+	r.Register(Def{ID: "zip", Method: "GET", Applies: func(t Target) bool { return t.Dir }})
+	r.Register(Def{ID: "preview", Method: "GET", Applies: Exts(".jpg", ".png"), Caps: []string{CapHeavy}})
+	r.Register(Def{ID: "delete", Method: "POST", Caps: []string{CapWrite}, Applies: func(Target) bool { return true }})
+	r.Register(Def{ID: "print", Method: "POST", Caps: []string{CapHost}})
+
+	ids := func(list []Action) (s string) {
+		for _, a := range list {
+			s += a.ID + " "
+		}
+		return
+	}
+	file := Target{Path: "/files/A.JPG", Name: "A.JPG"}
+	list := r.For(file, Context{Writable: true})
+	if got := ids(list); got != "download preview delete print " {
+		t.Fatalf("file actions %q", got)
+	}
+	if list[0].URL != "/files/A.JPG" || !list[0].Enabled {
+		t.Error(list[0])
+	}
+	if list[3].Enabled || list[3].Reason == "" {
+		t.Error("host-only action enabled for guest")
+	}
+	if got := ids(r.For(Target{Path: "/files/d", Name: "d", Dir: true}, Context{})); got != "zip delete " {
+		t.Errorf("dir actions %q", got)
+	}
+	if list := r.For(file, Context{Host: true}); list[2].Enabled || !list[3].Enabled {
+		t.Error("read-only context", list)
+	}
+
+	// 同ID替换原有定义
+	r.Register(Def{ID: "print", Method: "POST", Check: func(Target, Context) string { return "未配置打印机" }})
+	list = r.For(file, Context{Host: true})
+	if len(r.Defs()) != 5 || list[3].Reason != "未配置打印机" {
+		t.Error("replace", list[3])
+	}
+}
@@ -0,0 +1,191 @@
+package adopt
+
+import (
+	"b0pass/library/bufpool"
+	"b0pass/library/hashes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 收录主电脑磁盘上已有的文件到共享目录(移动、链接或复制)，同时计算哈希，大文件无需再经过一次上传
+
+// 收录方式
+const (
+	ModeMove = "move" // 移动，跨磁盘时复制并校验后删除原文件
+	ModeLink = "link" // 硬链接，跨磁盘时为符号链接
+	ModeCopy = "copy" // 复制并校验
+)
+
+// 实际执行的操作
+const (
+	MethodRename   = "rename"
+	MethodCopy     = "copy"
+	MethodHardlink = "hardlink"
+	MethodSymlink  = "symlink"
+)
+
+var (
+	// ErrExists 目标文件已存在
+	ErrExists = errors.New("adopt: destination exists")
+	// ErrMode 不支持的收录方式
+	ErrMode = errors.New("adopt: unknown mode")
+	// ErrNotRegular 不是普通文件
+	ErrNotRegular = errors.New("adopt: not a regular file")
+)
+
+// Options 收录选项
+type Options struct {
+	Mode      string
+	Algos     []string // 计算的哈希算法，缺省为hashes.Default
+	Overwrite bool     // 覆盖已存在的目标文件
+}
+
+// Result 单个文件的收录结果
+type Result struct {
+	Src    string            `json:"src"`
+	Dst    string            `json:"dst"`
+	Size   int64             `json:"size"`
+	Method string            `json:"method,omitempty"`
+	Sums   map[string]string `json:"hashes,omitempty"`
+	Err    string            `json:"error,omitempty"`
+}
+
+// Walk 列出src下的普通文件(src为文件时只有它本身)，返回各文件相对src所在目录的路径
+// 跳过以.开头的文件、目录与符号链接
+func Walk(src string) ([]string, error) {
+	src = filepath.Clean(src)
+	base := filepath.Dir(src)
+	var files []string
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != src && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			rel, err := filepath.Rel(base, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return files, err
+}
+
+// File 收录单个文件到dst
+func File(src, dst string, o Options) Result {
+	ret := Result{Src: src, Dst: dst}
+	sums, method, err := adopt(src, dst, o, &ret.Size)
+	ret.Sums, ret.Method = sums, method
+	if err != nil {
+		ret.Err = err.Error()
+	}
+	return ret
+}
+
+func adopt(src, dst string, o Options, size *int64) (map[string]string, string, error) {
+	if o.Mode != "" && o.Mode != ModeMove && o.Mode != ModeLink && o.Mode != ModeCopy {
+		return nil, "", ErrMode
+	}
+	info, err := os.Lstat(src)
+	if err != nil {
+		return nil, "", err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, "", ErrNotRegular
+	}
+	*size = info.Size()
+	algos := append([]string{hashes.Default}, o.Algos...)
+	if _, err := os.Lstat(dst); err == nil && !o.Overwrite {
+		return nil, "", ErrExists
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return nil, "", err
+	}
+	switch o.Mode {
+	case ModeCopy:
+		sums, err := copyVerify(src, dst, info, algos)
+		return sums, MethodCopy, err
+	case ModeMove, "":
+		sums, err := hashes.FileSums(src, algos...)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := os.Rename(src, dst); err == nil {
+			return sums, MethodRename, nil
+		}
+		// 跨磁盘无法rename
+		copied, err := copyVerify(src, dst, info, algos)
+		if err != nil {
+			return nil, "", err
+		}
+		if copied[hashes.Default] != sums[hashes.Default] {
+			// 复制期间原文件被修改，保留原文件
+			return copied, MethodCopy, nil
+		}
+		return copied, MethodCopy, os.Remove(src)
+	case ModeLink:
+		sums, err := hashes.FileSums(src, algos...)
+		if err != nil {
+			return nil, "", err
+		}
+		if o.Overwrite {
+			_ = os.Remove(dst)
+		}
+		if err := os.Link(src, dst); err == nil {
+			return sums, MethodHardlink, nil
+		}
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			return nil, "", err
+		}
+		return sums, MethodSymlink, os.Symlink(abs, dst)
+	}
+	return nil, "", ErrMode
+}
+
+// copyVerify 复制到临时文件并计算哈希，回读校验一致后改名为dst，保留修改时间
+func copyVerify(src, dst string, info os.FileInfo, algos []string) (map[string]string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = in.Close() }()
+	tmp := dst + ".adopting"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	m, err := hashes.NewMulti(algos...)
+	if err != nil {
+		_ = out.Close()
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	_, err = bufpool.Copy(io.MultiWriter(out, m), in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	sums := m.Sums()
+	if err == nil {
+		err = hashes.Verify(tmp, hashes.Default, sums[hashes.Default])
+	}
+	if err == nil {
+		_ = os.Chtimes(tmp, info.ModTime(), info.ModTime())
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	return sums, nil
+}
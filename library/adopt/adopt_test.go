@@ -0,0 +1,111 @@
+package adopt
+
+import (
+	"b0pass/library/filename"
+	"b0pass/library/hashes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAdopt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adopt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	src := filepath.Join(dir, "src", "album")
+	for _, name := range []string{"a.jpg", "sub/b.jpg", ".hidden", ".cache/c.jpg"} {
+		p := filepath.Join(src, name)
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte("data-"+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := Walk(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(files, ",") != "album/a.jpg,album/sub/b.jpg" {
+		t.Fatalf("walk %v", files)
+	}
+	want, _ := hashes.File(filepath.Join(src, "a.jpg"), hashes.Default)
+	share := filepath.Join(dir, "share")
+
+	// 复制: 原文件保留
+	r := File(filepath.Join(src, "a.jpg"), filepath.Join(share, "copy", "a.jpg"), Options{Mode: ModeCopy, Algos: []string{hashes.MD5}})
+	if r.Err != "" || r.Method != MethodCopy || r.Sums[hashes.Default] != want || r.Sums[hashes.MD5] == "" || r.Size != 10 {
+		t.Fatal(r)
+	}
+	if _, err := os.Stat(filepath.Join(src, "a.jpg")); err != nil {
+		t.Error("copy removed source")
+	}
+	// 目标已存在
+	if r := File(filepath.Join(src, "a.jpg"), filepath.Join(share, "copy", "a.jpg"), Options{Mode: ModeCopy}); r.Err != ErrExists.Error() {
+		t.Error(r)
+	}
+	// 链接
+	r = File(filepath.Join(src, "a.jpg"), filepath.Join(share, "link", "a.jpg"), Options{Mode: ModeLink})
+	if r.Err != "" || (r.Method != MethodHardlink && r.Method != MethodSymlink) {
+		t.Fatal(r)
+	}
+	// 移动
+	r = File(filepath.Join(src, "a.jpg"), filepath.Join(share, "move", "a.jpg"), Options{Mode: ModeMove})
+	if r.Err != "" || r.Method != MethodRename || r.Sums[hashes.Default] != want {
+		t.Fatal(r)
+	}
+	if _, err := os.Stat(filepath.Join(src, "a.jpg")); !os.IsNotExist(err) {
+		t.Error("move kept source")
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(share, "move", "a.jpg")); string(b) != "data-a.jpg" {
+		t.Errorf("moved content %q", b)
+	}
+	if r := File(filepath.Join(src, "sub"), filepath.Join(share, "x"), Options{}); r.Err != ErrNotRegular.Error() {
+		t.Error(r)
+	}
+	if r := File(filepath.Join(src, "sub", "b.jpg"), filepath.Join(share, "y"), Options{Mode: "zip"}); r.Err != ErrMode.Error() {
+		t.Error(r)
+	}
+}
+
+func TestAdoptTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adopt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	src := filepath.Join(dir, "src", "adoptdir")
+	for _, name := range []string{"y.txt", "sub/x.txt", "sub/deep/a:b.txt"} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte("data-"+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := Walk(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与/api/adopt相同: 相对路径逐段按文件名规则调整，保留目录结构
+	share, base := filepath.Join(dir, "share"), filepath.Dir(src)
+	for _, rel := range files {
+		dst := filepath.Join(share, filepath.FromSlash(filename.CleanPath(rel, filename.Options{Portable: true})))
+		if r := File(filepath.Join(base, filepath.FromSlash(rel)), dst, Options{Mode: ModeCopy}); r.Err != "" {
+			t.Fatal(r)
+		}
+	}
+	for name, want := range map[string]string{
+		"adoptdir/y.txt":            "data-y.txt",
+		"adoptdir/sub/x.txt":        "data-sub/x.txt",
+		"adoptdir/sub/deep/a_b.txt": "data-sub/deep/a:b.txt",
+	} {
+		if b, err := ioutil.ReadFile(filepath.Join(share, filepath.FromSlash(name))); err != nil || string(b) != want {
+			t.Errorf("%s: %q %v", name, b, err)
+		}
+	}
+	if list, _ := ioutil.ReadDir(share); len(list) != 1 || list[0].Name() != "adoptdir" {
+		t.Errorf("flattened tree %v", list)
+	}
+}
@@ -0,0 +1,264 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestZip(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "archive")
+	defer func() { _ = os.RemoveAll(dir) }()
+	for i := 0; i < 600; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("d%d", i%3))
+		_ = os.MkdirAll(sub, 0755)
+		_ = ioutil.WriteFile(filepath.Join(sub, fmt.Sprintf("f%03d.txt", i)), []byte(fmt.Sprint(i)), 0644)
+	}
+	_ = os.MkdirAll(filepath.Join(dir, "empty"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(dir, ".hidden"), []byte("x"), 0644)
+
+	var buf bytes.Buffer
+	stats, err := Zip(context.Background(), &buf, dir, Options{Pool: NewPool(2), Batch: 16})
+	if err != nil || stats.Files != 600 {
+		t.Fatal(stats, err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	if len(zr.File) != 601 || !names["d1/f001.txt"] || !names["empty/"] || names[".hidden"] {
+		t.Errorf("bad entries: %d", len(zr.File))
+	}
+
+	// 自定义隐藏规则: 显示点文件，跳过整个d2目录
+	buf.Reset()
+	hidden := func(rel string) bool { return rel == "d2" }
+	if stats, err = Zip(context.Background(), &buf, dir, Options{Hidden: hidden}); err != nil || stats.Files != 401 {
+		t.Fatal(stats, err)
+	}
+	zr, _ = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, "d2/") {
+			t.Error("hidden dir packed:", f.Name)
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+	p := NewPool(3)
+	release, err := p.Acquire(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.Acquire(ctx, 2); err == nil {
+		t.Error("acquired beyond pool size")
+	}
+	if _, inUse := p.Stats(); inUse != 2 {
+		t.Errorf("in use %d after cancelled acquire", inUse)
+	}
+	release()
+	release()
+	if r, err := p.Acquire(context.Background(), 3); err != nil {
+		t.Error(err)
+	} else {
+		r()
+	}
+}
+
+func TestWriteTar(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "archive")
+	defer func() { _ = os.RemoveAll(dir) }()
+	_ = os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	_ = os.MkdirAll(filepath.Join(dir, "empty"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(dir, "sub", "a.txt"), bytes.Repeat([]byte("a"), 1000), 0644)
+
+	var buf bytes.Buffer
+	stats, err := Write(context.Background(), &buf, dir, Options{Format: FormatTarGz, Level: 9})
+	if err != nil || stats.Files != 1 {
+		t.Fatal(stats, err)
+	}
+	gr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	names := make(map[string]int64)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names[h.Name] = h.Size
+	}
+	if len(names) != 2 || names["sub/a.txt"] != 1000 {
+		t.Errorf("bad entries: %v", names)
+	}
+	if _, err := Write(context.Background(), &buf, dir, Options{Format: FormatTarGz, Level: 10}); err == nil {
+		t.Error("invalid level accepted")
+	}
+}
+
+func TestZipStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "archive")
+	defer func() { _ = os.RemoveAll(dir) }()
+	_ = ioutil.WriteFile(filepath.Join(dir, "a.txt"), bytes.Repeat([]byte("a"), 1000), 0644)
+
+	var buf bytes.Buffer
+	if _, err := Write(context.Background(), &buf, dir, Options{Level: 0}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := zr.File[0]; f.Method != zip.Store || f.CompressedSize64 != 1000 {
+		t.Errorf("not stored: method %d size %d", f.Method, f.CompressedSize64)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "unpack")
+	defer func() { _ = os.RemoveAll(dir) }()
+	type entry struct {
+		hdr  *tar.Header
+		body string
+	}
+	build := func(gz bool, entries ...entry) *bytes.Buffer {
+		var buf bytes.Buffer
+		var w io.Writer = &buf
+		var zw *gzip.Writer
+		if gz {
+			zw = gzip.NewWriter(&buf)
+			w = zw
+		}
+		tw := tar.NewWriter(w)
+		for _, e := range entries {
+			if err := tw.WriteHeader(e.hdr); err != nil {
+				t.Fatal(err)
+			}
+			_, _ = tw.Write([]byte(e.body))
+		}
+		_ = tw.Close()
+		if zw != nil {
+			_ = zw.Close()
+		}
+		return &buf
+	}
+	mtime := time.Unix(1500000000, 0)
+	file := func(name, body string) entry {
+		return entry{&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(body)), ModTime: mtime}, body}
+	}
+	buf := build(true,
+		entry{hdr: &tar.Header{Name: "pkg/", Typeflag: tar.TypeDir, Mode: 0755}},
+		entry{hdr: &tar.Header{Name: "pkg/empty/", Typeflag: tar.TypeDir, Mode: 0755}},
+		file("pkg/index.js", "module.exports = 1"),
+		file("../../escape.txt", "x"),
+		entry{hdr: &tar.Header{Name: "pkg/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+	)
+	files, err := Unpack(buf, dir, UnpackOptions{Algos: []string{"sha256"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Size != 18 || files[0].Sums["sha256"] == "" {
+		t.Fatalf("%+v", files)
+	}
+	// 跳出目标目录的路径限制在目标目录内
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "escape.txt")); string(b) != "x" {
+		t.Error("escape", string(b))
+	}
+	if info, err := os.Stat(filepath.Join(dir, "pkg", "index.js")); err != nil || !info.ModTime().Equal(mtime) {
+		t.Error("mtime", info, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "pkg", "link")); !os.IsNotExist(err) {
+		t.Error("symlink", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "pkg", "empty")); err != nil || !info.IsDir() {
+		t.Error("dir", err)
+	}
+	// 不覆盖时改名，逐个同步
+	rename := func(p string) (string, error) { return p + ".1", nil }
+	files, err = Unpack(build(false, file("pkg/index.js", "v2")), dir, UnpackOptions{Conflict: rename, Sync: SyncFile})
+	if err != nil || len(files) != 1 || files[0].Path != filepath.Join(dir, "pkg", "index.js.1") {
+		t.Fatalf("%+v %v", files, err)
+	}
+	// 跳过已有的文件
+	skip := func(p string) (string, error) { return "", nil }
+	files, err = Unpack(build(false, file("pkg/index.js", "v3"), file("pkg/new.js", "n")), dir, UnpackOptions{Conflict: skip})
+	if err != nil || len(files) != 1 || files[0].Path != filepath.Join(dir, "pkg", "new.js") {
+		t.Fatalf("skip %+v %v", files, err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "pkg", "index.js")); string(b) != "module.exports = 1" {
+		t.Error("skipped content", string(b))
+	}
+	// 调整包内的路径
+	clean := func(rel string) string { return strings.Replace(rel, ":", "_", -1) }
+	files, err = Unpack(build(false, file("pkg/a:b.txt", "c")), dir, UnpackOptions{Clean: clean})
+	if err != nil || len(files) != 1 || files[0].Path != filepath.Join(dir, "pkg", "a_b.txt") {
+		t.Fatalf("clean %+v %v", files, err)
+	}
+	// 移动到目标位置前改写临时文件，返回错误时丢弃该文件并停止
+	errLocked := fmt.Errorf("locked")
+	before := func(tmp, target string) error {
+		if filepath.Base(filepath.Dir(target)) == "locked" {
+			return errLocked
+		}
+		return ioutil.WriteFile(tmp, []byte("sealed"), 0644)
+	}
+	files, err = Unpack(build(false, file("pkg/s.txt", "plain"), file("locked/p.txt", "plain"), file("pkg/t.txt", "plain")), dir, UnpackOptions{Before: before})
+	if err != errLocked || len(files) != 1 || files[0].Path != filepath.Join(dir, "pkg", "s.txt") {
+		t.Fatalf("before %+v %v", files, err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "pkg", "s.txt")); string(b) != "sealed" {
+		t.Error("before content", string(b))
+	}
+	if list, _ := ioutil.ReadDir(filepath.Join(dir, "locked")); len(list) != 0 {
+		t.Error("before left files", list)
+	}
+	if _, err := Unpack(build(false, file("a", "12345"), file("b", "123456789")), dir, UnpackOptions{MaxSize: 8}); err != ErrTooLarge {
+		t.Error("max size", err)
+	}
+	left, _ := filepath.Glob(filepath.Join(dir, "pkg", ".*.part"))
+	if len(left) != 0 {
+		t.Error("temp files", left)
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "archive")
+	defer func() { _ = os.RemoveAll(dir) }()
+	_ = os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("abc"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("hello"), 0644)
+
+	var buf bytes.Buffer
+	packed, err := WriteFiles(context.Background(), &buf, dir, []string{"a.txt", "/sub/b.txt", "missing.txt", "sub"}, Options{}, "sha256")
+	if err != nil || len(packed) != 2 {
+		t.Fatal(packed, err)
+	}
+	if packed[0].Name != "a.txt" || packed[0].Sums["sha256"] != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" || packed[1].Name != "sub/b.txt" || packed[1].Size != 5 {
+		t.Errorf("%+v", packed)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil || len(zr.File) != 2 || zr.File[1].Name != "sub/b.txt" {
+		t.Fatal(err)
+	}
+}
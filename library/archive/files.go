@@ -0,0 +1,78 @@
+package archive
+
+import (
+	"b0pass/library/bufpool"
+	"b0pass/library/hashes"
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Packed 打包写入的文件
+type Packed struct {
+	Name string // 包内路径
+	Size int64
+	Sums map[string]string
+}
+
+// WriteFiles 将root下的names(斜杠分隔的相对路径，可含子目录)按opt.Format打包写入w，
+// 写入时同时计算algos校验值，用于生成清单；不存在或不是普通文件的跳过
+func WriteFiles(ctx context.Context, w io.Writer, root string, names []string, opt Options, algos ...string) ([]Packed, error) {
+	if opt.Format == "" {
+		opt.Format = FormatZip
+	}
+	if err := CheckLevel(opt.Format, opt.Level); err != nil {
+		return nil, err
+	}
+	aw, err := newWriter(ctx, w, opt.Format, opt.Level)
+	if err != nil {
+		return nil, err
+	}
+	var packed []Packed
+	for _, name := range names {
+		name = path.Clean("/" + name)[1:]
+		p, err := packFile(aw, filepath.Join(root, filepath.FromSlash(name)), name, algos)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			aw.Abort()
+			return packed, err
+		}
+		if p.Name != "" {
+			packed = append(packed, p)
+		}
+	}
+	return packed, aw.Close()
+}
+
+// packFile 写入一个文件并计算校验值
+func packFile(aw entryWriter, file, name string, algos []string) (Packed, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Packed{}, err
+	}
+	defer func() { _ = f.Close() }()
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return Packed{}, err
+	}
+	multi, err := hashes.NewMulti(algos...)
+	if err != nil {
+		return Packed{}, err
+	}
+	dst, err := aw.File(name, fi)
+	if err != nil {
+		return Packed{}, err
+	}
+	n, err := bufpool.Copy(io.MultiWriter(dst, multi), f)
+	if err != nil {
+		return Packed{}, err
+	}
+	return Packed{Name: name, Size: n, Sums: multi.Sums()}, nil
+}
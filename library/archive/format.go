@@ -0,0 +1,174 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// 打包格式
+const (
+	FormatZip    = "zip"
+	FormatTar    = "tar"
+	FormatTarGz  = "tar.gz"
+	FormatTarZst = "tar.zst" // 通过zstd命令压缩
+)
+
+// ZstdCommand zstd压缩命令
+var ZstdCommand = "zstd"
+
+// formatInfo 格式的内容类型与压缩级别范围
+var formatInfo = map[string]struct {
+	contentType string
+	min, max    int
+}{
+	FormatZip:    {"application/zip", 0, 9},
+	FormatTar:    {"application/x-tar", 0, 0},
+	FormatTarGz:  {"application/gzip", 0, 9},
+	FormatTarZst: {"application/zstd", 0, 19},
+}
+
+// ContentType 格式对应的Content-Type，格式不支持时返回空
+func ContentType(format string) string {
+	return formatInfo[format].contentType
+}
+
+// CheckLevel 检查格式与压缩级别
+// zip: 0仅存储，1-9为deflate级别；tar.gz: 1-9；tar.zst: 1-19；tar不压缩；
+// tar.gz与tar.zst为0时使用默认级别
+func CheckLevel(format string, level int) error {
+	info, ok := formatInfo[format]
+	if !ok {
+		return fmt.Errorf("archive: unsupported format %q", format)
+	}
+	if level < info.min || level > info.max {
+		return fmt.Errorf("archive: %s level %d out of range [%d, %d]", format, level, info.min, info.max)
+	}
+	return nil
+}
+
+// entryWriter 打包格式的写入器
+type entryWriter interface {
+	Dir(name string, fi os.FileInfo) error
+	File(name string, fi os.FileInfo) (io.Writer, error)
+	Close() error
+	// Abort 出错时释放资源，不写入结尾
+	Abort()
+}
+
+// newWriter 按格式创建写入器
+func newWriter(ctx context.Context, w io.Writer, format string, level int) (entryWriter, error) {
+	switch format {
+	case FormatTar:
+		return &tarWriter{Writer: tar.NewWriter(w)}, nil
+	case FormatTarGz:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		return &tarWriter{Writer: tar.NewWriter(gw), codec: gw}, nil
+	case FormatTarZst:
+		zw, err := newZstd(ctx, w, level)
+		if err != nil {
+			return nil, err
+		}
+		return &tarWriter{Writer: tar.NewWriter(zw), codec: zw}, nil
+	default:
+		return newZipWriter(w, level), nil
+	}
+}
+
+// tarWriter tar格式，codec为外层压缩
+type tarWriter struct {
+	*tar.Writer
+	codec io.WriteCloser
+}
+
+func (t *tarWriter) Dir(name string, fi os.FileInfo) error {
+	h, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	h.Name = name + "/"
+	return t.WriteHeader(h)
+}
+
+func (t *tarWriter) File(name string, fi os.FileInfo) (io.Writer, error) {
+	h, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return nil, err
+	}
+	h.Name = name
+	if err := t.WriteHeader(h); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *tarWriter) Close() error {
+	err := t.Writer.Close()
+	if t.codec != nil {
+		if cerr := t.codec.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (t *tarWriter) Abort() {
+	if z, ok := t.codec.(*zstdWriter); ok {
+		z.abort()
+	}
+}
+
+// zstdWriter 通过zstd命令压缩，写入其标准输入，输出写入w
+type zstdWriter struct {
+	io.WriteCloser
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+}
+
+// newZstd 启动zstd命令，level为0时使用命令的默认级别
+func newZstd(ctx context.Context, w io.Writer, level int) (*zstdWriter, error) {
+	args := []string{"-q", "-c"}
+	if level > 0 {
+		args = append(args, "-"+strconv.Itoa(level))
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(ctx, ZstdCommand, args...)
+	cmd.Stdout = w
+	stdin, err := cmd.StdinPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("archive: %s: %v", ZstdCommand, err)
+	}
+	return &zstdWriter{WriteCloser: stdin, cmd: cmd, cancel: cancel}, nil
+}
+
+// Close 结束输入并等待压缩完成
+func (z *zstdWriter) Close() error {
+	defer z.cancel()
+	err := z.WriteCloser.Close()
+	if werr := z.cmd.Wait(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// abort 终止压缩命令
+func (z *zstdWriter) abort() {
+	z.cancel()
+	_ = z.WriteCloser.Close()
+	_ = z.cmd.Wait()
+}
@@ -0,0 +1,98 @@
+package archive
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Pool 文件句柄池，限制所有打包任务同时占用的文件句柄数
+// 每个打包任务同时最多占用两个句柄(当前目录与当前文件)，开始前一次性申请
+type Pool struct {
+	mu     sync.Mutex
+	tokens chan struct{}
+}
+
+// NewPool 创建句柄池，size<=0时按系统文件句柄上限计算
+func NewPool(size int) *Pool {
+	if size <= 0 {
+		size = DefaultPoolSize()
+	}
+	return &Pool{tokens: make(chan struct{}, size)}
+}
+
+// DefaultPoolSize 按系统文件句柄上限的1/4计算句柄池大小，其余留给网络连接等
+func DefaultPoolSize() int {
+	cur, _, err := FileLimit()
+	if err != nil || cur == 0 {
+		return 256
+	}
+	n := int(cur / 4)
+	if n < 8 {
+		n = 8
+	}
+	if n > 4096 {
+		n = 4096
+	}
+	return n
+}
+
+// Acquire 申请n个句柄，ctx取消时放弃等待
+// 申请过程加锁，避免多个任务各持有部分句柄而互相等待
+func (p *Pool) Acquire(ctx context.Context, n int) (func(), error) {
+	if n > cap(p.tokens) {
+		n = cap(p.tokens)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := 0; i < n; i++ {
+		select {
+		case p.tokens <- struct{}{}:
+		case <-ctx.Done():
+			p.release(i)
+			return nil, ctx.Err()
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(func() { p.release(n) }) }, nil
+}
+
+func (p *Pool) release(n int) {
+	for i := 0; i < n; i++ {
+		<-p.tokens
+	}
+}
+
+// Stats 返回句柄池大小与已占用数
+func (p *Pool) Stats() (size, inUse int) {
+	return cap(p.tokens), len(p.tokens)
+}
+
+// tooManyFiles 是否为进程或系统文件句柄耗尽
+func tooManyFiles(err error) bool {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	return err == syscall.EMFILE || err == syscall.ENFILE
+}
+
+// retryOpen 句柄耗尽时等待其他请求释放后重试，而不是直接失败
+func retryOpen(ctx context.Context, open func() error) error {
+	delay := 50 * time.Millisecond
+	for i := 0; ; i++ {
+		err := open()
+		if err == nil || !tooManyFiles(err) || i >= 20 {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if delay < time.Second {
+			delay *= 2
+		}
+	}
+}
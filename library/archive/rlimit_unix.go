@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package archive
+
+import "syscall"
+
+// FileLimit 获取进程文件句柄数的软上限与硬上限(ulimit -n)
+func FileLimit() (cur, max uint64, err error) {
+	var rl syscall.Rlimit
+	if err = syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, 0, err
+	}
+	return uint64(rl.Cur), uint64(rl.Max), nil
+}
+
+// RaiseFileLimit 将软上限提高到硬上限，返回调整后的软上限
+func RaiseFileLimit() (uint64, error) {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, err
+	}
+	if rl.Cur < rl.Max {
+		old := rl.Cur
+		rl.Cur = rl.Max
+		if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+			return uint64(old), err
+		}
+	}
+	return uint64(rl.Cur), nil
+}
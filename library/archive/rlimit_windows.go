@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package archive
+
+// FileLimit Windows没有ulimit，句柄数只受系统资源限制，返回0表示未知
+func FileLimit() (cur, max uint64, err error) {
+	return 0, 0, nil
+}
+
+// RaiseFileLimit Windows无需调整
+func RaiseFileLimit() (uint64, error) {
+	return 0, nil
+}
@@ -0,0 +1,172 @@
+package archive
+
+import (
+	"archive/tar"
+	"b0pass/library/bufpool"
+	"b0pass/library/fsync"
+	"b0pass/library/hashes"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// 同步方式
+const (
+	SyncNone  = "none"  // 不主动同步，由系统写回
+	SyncGroup = "group" // 全部写完后一次同步
+	SyncFile  = "file"  // 每个文件写完后同步
+)
+
+// ErrTooLarge 文件超过大小上限
+var ErrTooLarge = errors.New("archive: file exceeds size limit")
+
+// UnpackOptions 解包选项
+type UnpackOptions struct {
+	Algos   []string // 写入时同时计算的校验算法
+	MaxSize int64    // 单个文件的大小上限，为0时不限制
+	Sync    string   // 同步方式，为空时为SyncGroup
+	// Conflict 目标已存在时的新路径，返回空字符串时跳过该文件，返回错误时停止解包；为nil时覆盖
+	Conflict func(target string) (string, error)
+	// Clean 调整包内的相对路径(以/开头)，如规范化文件名；为nil时不调整
+	Clean func(rel string) string
+	// Before 文件已写入临时文件、移动到目标位置前调用(可选)，如加密临时文件；返回错误时丢弃该文件并停止解包
+	Before func(tmp, target string) error
+}
+
+// Unpacked 解包写入的文件
+type Unpacked struct {
+	Path string // 目标绝对路径
+	Size int64
+	Sums map[string]string
+}
+
+// Unpack 将tar(可gzip压缩)流解包到dir，只写入普通文件与目录，跳过链接与设备文件；
+// 每个文件先写入同目录下的隐藏临时文件再rename，返回已写入的文件(出错时为出错前写入的)
+func Unpack(r io.Reader, dir string, opt UnpackOptions) ([]Unpacked, error) {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = gz.Close() }()
+		src = gz
+	}
+	var (
+		tr    = tar.NewReader(src)
+		files []Unpacked
+		group fsync.Group
+	)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, err
+		}
+		rel := path.Clean("/" + hdr.Name)
+		if opt.Clean != nil {
+			rel = path.Clean("/" + opt.Clean(rel))
+		}
+		if rel == "/" {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return files, err
+			}
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+		default:
+			continue
+		}
+		if opt.MaxSize > 0 && hdr.Size > opt.MaxSize {
+			return files, ErrTooLarge
+		}
+		f, err := unpackFile(tr, hdr, target, opt)
+		if err != nil {
+			return files, err
+		}
+		if f.Path == "" {
+			continue
+		}
+		if opt.Sync == "" || opt.Sync == SyncGroup {
+			group.Add(f.Path)
+		}
+		files = append(files, f)
+	}
+	return files, group.Sync()
+}
+
+// unpackFile 写入一个文件
+func unpackFile(r io.Reader, hdr *tar.Header, target string, opt UnpackOptions) (Unpacked, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return Unpacked{}, err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(target), "."+filepath.Base(target)+".*.part")
+	if err != nil {
+		return Unpacked{}, err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	mode := os.FileMode(0644)
+	if hdr.Mode&0111 != 0 {
+		mode = 0755
+	}
+	_ = tmp.Chmod(mode)
+	var w io.Writer = tmp
+	multi, err := hashes.NewMulti(opt.Algos...)
+	if err != nil {
+		return Unpacked{}, err
+	}
+	if len(opt.Algos) > 0 {
+		w = io.MultiWriter(tmp, multi)
+	}
+	n, err := bufpool.Copy(w, r)
+	if err != nil {
+		return Unpacked{}, err
+	}
+	if opt.Sync == SyncFile {
+		if err := tmp.Sync(); err != nil {
+			return Unpacked{}, err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return Unpacked{}, err
+	}
+	_ = os.Chtimes(tmp.Name(), hdr.ModTime, hdr.ModTime)
+	if _, err := os.Stat(target); err == nil && opt.Conflict != nil {
+		if target, err = opt.Conflict(target); err != nil || target == "" {
+			return Unpacked{}, err
+		}
+	}
+	if opt.Before != nil {
+		if err := opt.Before(tmp.Name(), target); err != nil {
+			return Unpacked{}, err
+		}
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return Unpacked{}, err
+	}
+	if opt.Sync == SyncFile {
+		if err := fsync.Dir(filepath.Dir(target)); err != nil {
+			return Unpacked{}, err
+		}
+	}
+	u := Unpacked{Path: target, Size: n}
+	if len(opt.Algos) > 0 {
+		u.Sums = multi.Sums()
+	}
+	return u, nil
+}
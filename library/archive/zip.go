@@ -0,0 +1,195 @@
+package archive
+
+import (
+	"archive/zip"
+	"b0pass/library/bufpool"
+	"b0pass/library/fileguard"
+	"compress/flate"
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Options 打包选项
+type Options struct {
+	Pool   *Pool  // 句柄池，为nil时不限制
+	Batch  int    // 每批读取的目录项数，默认256
+	Format string // 打包格式，默认zip
+	Level  int    // 压缩级别，见CheckLevel；zip为0时仅存储
+	// Hidden 跳过的文件与目录，rel为以/分隔的相对路径；为nil时跳过以.开头的
+	Hidden func(rel string) bool
+}
+
+// Stats 打包结果
+type Stats struct {
+	Files int   // 文件数
+	Size  int64 // 原始文件总大小
+}
+
+// Zip 将目录dir以zip格式流式写入w，跳过隐藏文件(见Options.Hidden)
+func Zip(ctx context.Context, w io.Writer, dir string, opt Options) (Stats, error) {
+	opt.Format = FormatZip
+	return Write(ctx, w, dir, opt)
+}
+
+// Write 将目录dir按opt.Format流式打包写入w，跳过隐藏文件(见Options.Hidden)
+// 目录按批读取并逐个打开文件，同一时刻只占用当前目录与当前文件两个句柄，
+// 子目录排队处理而不是递归打开，目录再深、文件再多也不会耗尽句柄
+func Write(ctx context.Context, w io.Writer, dir string, opt Options) (Stats, error) {
+	var stats Stats
+	if opt.Batch <= 0 {
+		opt.Batch = 256
+	}
+	if opt.Format == "" {
+		opt.Format = FormatZip
+	}
+	if opt.Hidden == nil {
+		opt.Hidden = func(rel string) bool { return strings.HasPrefix(path.Base(rel), ".") }
+	}
+	if err := CheckLevel(opt.Format, opt.Level); err != nil {
+		return stats, err
+	}
+	if opt.Pool != nil {
+		release, err := opt.Pool.Acquire(ctx, 2)
+		if err != nil {
+			return stats, err
+		}
+		defer release()
+	}
+	aw, err := newWriter(ctx, w, opt.Format, opt.Level)
+	if err != nil {
+		return stats, err
+	}
+	queue := []string{""}
+	for len(queue) > 0 {
+		rel := queue[0]
+		queue = queue[1:]
+		subs, err := writeDir(ctx, aw, dir, rel, opt, &stats)
+		if err != nil {
+			aw.Abort()
+			return stats, err
+		}
+		queue = append(queue, subs...)
+	}
+	return stats, aw.Close()
+}
+
+// writeDir 打包rel目录下的文件，返回子目录
+func writeDir(ctx context.Context, aw entryWriter, root, rel string, opt Options, stats *Stats) ([]string, error) {
+	var d *os.File
+	err := retryOpen(ctx, func() (err error) {
+		d, err = os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = d.Close() }()
+
+	var subs []string
+	empty := true
+	for {
+		list, err := d.Readdir(opt.Batch)
+		sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+		for _, fi := range list {
+			name := path.Join(rel, fi.Name())
+			if opt.Hidden(name) {
+				continue
+			}
+			empty = false
+			switch {
+			case fi.IsDir():
+				subs = append(subs, name)
+			case fi.Mode().IsRegular():
+				if err := writeFile(ctx, aw, root, name, fi); err != nil {
+					return nil, err
+				}
+				stats.Files++
+				stats.Size += fi.Size()
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	// 空目录写入目录项，解压后保留目录结构
+	if empty && rel != "" {
+		fi, err := d.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if err := aw.Dir(rel, fi); err != nil {
+			return nil, err
+		}
+	}
+	return subs, nil
+}
+
+// writeFile 写入单个文件，读取期间文件被修改时返回fileguard.ErrChanged
+func writeFile(ctx context.Context, aw entryWriter, root, name string, fi os.FileInfo) error {
+	var f *fileguard.Reader
+	err := retryOpen(ctx, func() (err error) {
+		f, err = fileguard.Open(filepath.Join(root, filepath.FromSlash(name)), 4<<20)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	dst, err := aw.File(name, fi)
+	if err != nil {
+		return err
+	}
+	_, err = bufpool.Copy(dst, f)
+	return err
+}
+
+// zipWriter zip格式，level为0时仅存储
+type zipWriter struct {
+	*zip.Writer
+	method uint16
+}
+
+func newZipWriter(w io.Writer, level int) *zipWriter {
+	zw := &zipWriter{Writer: zip.NewWriter(w), method: zip.Deflate}
+	if level == 0 {
+		zw.method = zip.Store
+	} else {
+		zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+	return zw
+}
+
+func (z *zipWriter) Dir(name string, fi os.FileInfo) error {
+	h, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return err
+	}
+	h.Name = name + "/"
+	_, err = z.CreateHeader(h)
+	return err
+}
+
+func (z *zipWriter) File(name string, fi os.FileInfo) (io.Writer, error) {
+	h, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return nil, err
+	}
+	h.Name = name
+	h.Method = z.method
+	return z.CreateHeader(h)
+}
+
+func (z *zipWriter) Abort() {}
@@ -0,0 +1,250 @@
+package argon2
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"sync"
+)
+
+// Argon2id密码哈希(RFC 9106)，接口与golang.org/x/crypto/argon2相同，
+// 项目的依赖中没有x/crypto，这里只保留纯Go的实现
+
+const (
+	version     = 0x13
+	modeID      = 2
+	blockWords  = 128 // 每块1024字节
+	syncPoints  = 4
+	blake2bSize = 64
+)
+
+type block [blockWords]uint64
+
+// IDKey 由密码与盐派生keyLen字节的密钥，time为迭代次数，memory为内存大小(KiB)，threads为并行度
+func IDKey(password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	return deriveKey(password, salt, nil, nil, time, memory, threads, keyLen)
+}
+
+func deriveKey(password, salt, secret, data []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	if time < 1 {
+		panic("argon2: number of rounds too small")
+	}
+	if threads < 1 {
+		panic("argon2: parallelism degree too low")
+	}
+	h0 := initHash(password, salt, secret, data, time, memory, uint32(threads), keyLen)
+	lanes := uint32(threads)
+	memory = memory / (syncPoints * lanes) * (syncPoints * lanes)
+	if memory < 2*syncPoints*lanes {
+		memory = 2 * syncPoints * lanes
+	}
+	b := initBlocks(&h0, memory, lanes)
+	processBlocks(b, time, memory, lanes)
+	return extractKey(b, memory, lanes, keyLen)
+}
+
+// initHash H0
+func initHash(password, salt, secret, data []byte, time, memory, threads, keyLen uint32) [blake2bSize + 8]byte {
+	var msg []byte
+	le32 := func(v uint32) {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], v)
+		msg = append(msg, b[:]...)
+	}
+	le32(threads)
+	le32(keyLen)
+	le32(memory)
+	le32(time)
+	le32(version)
+	le32(modeID)
+	for _, v := range [][]byte{password, salt, secret, data} {
+		le32(uint32(len(v)))
+		msg = append(msg, v...)
+	}
+	var h0 [blake2bSize + 8]byte
+	blake2b(h0[:blake2bSize], msg)
+	return h0
+}
+
+// hashLong 可变长度哈希H'
+func hashLong(out, in []byte) {
+	var prefix [4]byte
+	binary.LittleEndian.PutUint32(prefix[:], uint32(len(out)))
+	msg := append(prefix[:], in...)
+	if len(out) <= blake2bSize {
+		blake2b(out, msg)
+		return
+	}
+	var v [blake2bSize]byte
+	blake2b(v[:], msg)
+	copy(out, v[:32])
+	out = out[32:]
+	for len(out) > blake2bSize {
+		blake2b(v[:], v[:])
+		copy(out, v[:32])
+		out = out[32:]
+	}
+	blake2b(out, v[:])
+}
+
+func initBlocks(h0 *[blake2bSize + 8]byte, memory, lanes uint32) []block {
+	var buf [1024]byte
+	b := make([]block, memory)
+	for lane := uint32(0); lane < lanes; lane++ {
+		j := lane * (memory / lanes)
+		binary.LittleEndian.PutUint32(h0[blake2bSize+4:], lane)
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(h0[blake2bSize:], i)
+			hashLong(buf[:], h0[:])
+			for k := range b[j+i] {
+				b[j+i][k] = binary.LittleEndian.Uint64(buf[k*8:])
+			}
+		}
+	}
+	return b
+}
+
+func processBlocks(b []block, time, memory, lanes uint32) {
+	laneLen := memory / lanes
+	segLen := laneLen / syncPoints
+
+	segment := func(n, slice, lane uint32, wg *sync.WaitGroup) {
+		defer wg.Done()
+		var addresses, in, zero block
+		// 第一遍的前半部分与数据无关(Argon2i方式)，之后与数据相关(Argon2d方式)
+		independent := n == 0 && slice < syncPoints/2
+		if independent {
+			in[0], in[1], in[2], in[3], in[4], in[5] = uint64(n), uint64(lane), uint64(slice), uint64(memory), uint64(time), modeID
+		}
+		index := uint32(0)
+		if n == 0 && slice == 0 {
+			index = 2 // 前两块已由initBlocks生成
+			if independent {
+				in[6]++
+				processBlock(&addresses, &in, &zero, false)
+				processBlock(&addresses, &addresses, &zero, false)
+			}
+		}
+		offset := lane*laneLen + slice*segLen + index
+		for index < segLen {
+			prev := offset - 1
+			if index == 0 && slice == 0 {
+				prev += laneLen
+			}
+			var random uint64
+			if independent {
+				if index%blockWords == 0 {
+					in[6]++
+					processBlock(&addresses, &in, &zero, false)
+					processBlock(&addresses, &addresses, &zero, false)
+				}
+				random = addresses[index%blockWords]
+			} else {
+				random = b[prev][0]
+			}
+			ref := indexAlpha(random, laneLen, segLen, lanes, n, slice, lane, index)
+			processBlock(&b[offset], &b[prev], &b[ref], n > 0)
+			index, offset = index+1, offset+1
+		}
+	}
+
+	for n := uint32(0); n < time; n++ {
+		for slice := uint32(0); slice < syncPoints; slice++ {
+			var wg sync.WaitGroup
+			for lane := uint32(0); lane < lanes; lane++ {
+				wg.Add(1)
+				go segment(n, slice, lane, &wg)
+			}
+			wg.Wait()
+		}
+	}
+}
+
+func extractKey(b []block, memory, lanes, keyLen uint32) []byte {
+	laneLen := memory / lanes
+	for lane := uint32(0); lane < lanes-1; lane++ {
+		for i, v := range b[lane*laneLen+laneLen-1] {
+			b[memory-1][i] ^= v
+		}
+	}
+	var buf [1024]byte
+	for i, v := range b[memory-1] {
+		binary.LittleEndian.PutUint64(buf[i*8:], v)
+	}
+	key := make([]byte, keyLen)
+	hashLong(key, buf[:])
+	return key
+}
+
+// indexAlpha 参考块的位置
+func indexAlpha(random uint64, laneLen, segLen, lanes, n, slice, lane, index uint32) uint32 {
+	refLane := uint32(random>>32) % lanes
+	if n == 0 && slice == 0 {
+		refLane = lane
+	}
+	m, s := 3*segLen, ((slice+1)%syncPoints)*segLen
+	if lane == refLane {
+		m += index
+	}
+	if n == 0 {
+		m, s = slice*segLen, 0
+		if slice == 0 || lane == refLane {
+			m += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		m--
+	}
+	p := random & 0xFFFFFFFF
+	p = (p * p) >> 32
+	p = (p * uint64(m)) >> 32
+	return refLane*laneLen + uint32((uint64(s)+uint64(m)-(p+1))%uint64(laneLen))
+}
+
+// processBlock 压缩函数G，xor为true时与原内容异或(第二遍起)
+func processBlock(out, in1, in2 *block, xor bool) {
+	var t block
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+	r := t
+	// 8行，每行16个字
+	for i := 0; i < blockWords; i += 16 {
+		blamka(&t[i], &t[i+1], &t[i+2], &t[i+3], &t[i+4], &t[i+5], &t[i+6], &t[i+7],
+			&t[i+8], &t[i+9], &t[i+10], &t[i+11], &t[i+12], &t[i+13], &t[i+14], &t[i+15])
+	}
+	// 8列，每列为各行中相同位置的两个字
+	for i := 0; i < 16; i += 2 {
+		blamka(&t[i], &t[i+1], &t[16+i], &t[16+i+1], &t[32+i], &t[32+i+1], &t[48+i], &t[48+i+1],
+			&t[64+i], &t[64+i+1], &t[80+i], &t[80+i+1], &t[96+i], &t[96+i+1], &t[112+i], &t[112+i+1])
+	}
+	for i := range t {
+		if xor {
+			out[i] ^= r[i] ^ t[i]
+		} else {
+			out[i] = r[i] ^ t[i]
+		}
+	}
+}
+
+// blamka BLAKE2b轮函数的变体，加法换为 a + b + 2*低32位(a)*低32位(b)
+func blamka(v0, v1, v2, v3, v4, v5, v6, v7, v8, v9, v10, v11, v12, v13, v14, v15 *uint64) {
+	gb(v0, v4, v8, v12)
+	gb(v1, v5, v9, v13)
+	gb(v2, v6, v10, v14)
+	gb(v3, v7, v11, v15)
+	gb(v0, v5, v10, v15)
+	gb(v1, v6, v11, v12)
+	gb(v2, v7, v8, v13)
+	gb(v3, v4, v9, v14)
+}
+
+func gb(a, b, c, d *uint64) {
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d = bits.RotateLeft64(*d^*a, -32)
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b = bits.RotateLeft64(*b^*c, -24)
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d = bits.RotateLeft64(*d^*a, -16)
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b = bits.RotateLeft64(*b^*c, -63)
+}
@@ -0,0 +1,38 @@
+package argon2
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestBlake2b(t *testing.T) {
+	// RFC 7693 附录A
+	out := make([]byte, 64)
+	blake2b(out, []byte("abc"))
+	want := "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d1" +
+		"7d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"
+	if hex.EncodeToString(out) != want {
+		t.Error(hex.EncodeToString(out))
+	}
+	out = make([]byte, 32)
+	blake2b(out, nil)
+	if hex.EncodeToString(out) != "0e5751c026e543b2e8ab2eb06099daa1d1e5df47778f7787faab45cdf12fe3a8" {
+		t.Error("empty", hex.EncodeToString(out))
+	}
+}
+
+func TestIDKey(t *testing.T) {
+	// RFC 9106 5.3 Argon2id测试向量
+	key := deriveKey(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16), bytes.Repeat([]byte{3}, 8), bytes.Repeat([]byte{4}, 12), 3, 32, 4, 32)
+	if hex.EncodeToString(key) != "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659" {
+		t.Error(hex.EncodeToString(key))
+	}
+	a := IDKey([]byte("password"), []byte("somesalt"), 1, 64, 1, 32)
+	if bytes.Equal(a, IDKey([]byte("password"), []byte("othersalt"), 1, 64, 1, 32)) || len(a) != 32 {
+		t.Error("salt")
+	}
+	if long := IDKey([]byte("password"), []byte("somesalt"), 1, 64, 1, 100); len(long) != 100 {
+		t.Error(len(long))
+	}
+}
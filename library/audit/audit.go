@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 操作类型
+const (
+	OpUpload   = "upload"
+	OpDownload = "download"
+	OpDelete   = "delete"
+	OpRename   = "rename"
+	OpAdopt    = "adopt"   // 收录主电脑上已有的文件
+	OpRestore  = "restore" // 从回收站还原
+	OpPurge    = "purge"   // 从回收站彻底删除
+	// 临时授权，Client为访客设备，Size为授权分钟数
+	OpGrant  = "grant"
+	OpRevoke = "revoke"
+)
+
+// Entry 审计记录
+type Entry struct {
+	Time   string `json:"time"`
+	Op     string `json:"op"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Client string `json:"client"`
+	Agent  string `json:"agent"`
+	// 上传采用的同步策略(none/on-complete/per-chunk)，其他操作为空
+	Durability string `json:"durability,omitempty"`
+}
+
+// Logger 审计日志，按JSON行写入并按大小轮转
+type Logger struct {
+	mu      sync.Mutex
+	file    string
+	maxSize int64
+	backups int
+}
+
+var std *Logger
+
+// Init 初始化默认审计日志
+func Init(dir string, maxSize int64, backups int) {
+	std = New(filepath.Join(dir, "audit.log"), maxSize, backups)
+}
+
+// SetRetention 修改默认审计日志的轮转大小与保留份数
+func SetRetention(maxSize int64, backups int) {
+	if std == nil {
+		return
+	}
+	std.mu.Lock()
+	std.maxSize, std.backups = maxSize, backups
+	std.mu.Unlock()
+}
+
+// Record 写入默认审计日志
+func Record(e Entry) {
+	if std == nil {
+		return
+	}
+	_ = std.Write(e)
+}
+
+// Query 查询默认审计日志
+func Query(op string, limit int) []Entry {
+	if std == nil {
+		return nil
+	}
+	return std.Read(op, limit)
+}
+
+// New 创建审计日志
+func New(file string, maxSize int64, backups int) *Logger {
+	return &Logger{file: file, maxSize: maxSize, backups: backups}
+}
+
+// Write 追加一条记录
+func (l *Logger) Write(e Entry) error {
+	if e.Time == "" {
+		e.Time = time.Now().Format(time.RFC3339)
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.file), 0755); err != nil {
+		return err
+	}
+	l.rotate()
+	f, err := os.OpenFile(l.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// Read 按时间倒序读取记录，op为空时不过滤
+func (l *Logger) Read(op string, limit int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var ret []Entry
+	for i := 0; i <= l.backups; i++ {
+		entries := readFile(l.backupName(i))
+		for j := len(entries) - 1; j >= 0; j-- {
+			if op != "" && entries[j].Op != op {
+				continue
+			}
+			ret = append(ret, entries[j])
+			if limit > 0 && len(ret) >= limit {
+				return ret
+			}
+		}
+	}
+	return ret
+}
+
+// rotate 超出大小时轮转: audit.log -> audit.log.1 -> audit.log.2 ...
+func (l *Logger) rotate() {
+	if l.maxSize <= 0 {
+		return
+	}
+	info, err := os.Stat(l.file)
+	if err != nil || info.Size() < l.maxSize {
+		return
+	}
+	if l.backups <= 0 {
+		_ = os.Remove(l.file)
+		return
+	}
+	_ = os.Remove(l.backupName(l.backups))
+	for i := l.backups - 1; i >= 0; i-- {
+		_ = os.Rename(l.backupName(i), l.backupName(i+1))
+	}
+}
+
+// backupName 第n个备份文件名，0为当前文件
+func (l *Logger) backupName(n int) string {
+	if n == 0 {
+		return l.file
+	}
+	return fmt.Sprintf("%s.%d", l.file, n)
+}
+
+// readFile 读取单个日志文件
+func readFile(file string) []Entry {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
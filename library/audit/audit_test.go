@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "audit")
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	l := New(filepath.Join(dir, "audit.log"), 200, 2)
+	for i := 0; i < 10; i++ {
+		op := OpUpload
+		if i%2 == 1 {
+			op = OpDelete
+		}
+		if err := l.Write(Entry{Op: op, Path: "/files/a.txt", Client: "127.0.0.1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit.log.1")); err != nil {
+		t.Error("log not rotated")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit.log.3")); err == nil {
+		t.Error("too many backups kept")
+	}
+
+	entries := l.Read(OpDelete, 2)
+	if len(entries) != 2 {
+		t.Fatalf("want 2 entries, got %d", len(entries))
+	}
+	for _, e := range entries {
+		if e.Op != OpDelete || e.Time == "" {
+			t.Errorf("bad entry %+v", e)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package authn
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 可组合的认证方式: 按顺序尝试各认证方式，任一通过即可，
+// 每种方式带有角色(如mTLS证书为管理员、PIN为访客)，新增认证方式无需修改各接口
+
+// 角色
+const (
+	RoleAdmin = "admin" // 只读模式下仍可写入
+	RoleUser  = "user"  // 按只读设置
+	RoleGuest = "guest" // 只能浏览与下载
+)
+
+var (
+	// ErrNoCredentials 请求未携带该认证方式的凭证
+	ErrNoCredentials = errors.New("authn: no credentials")
+	// ErrInvalid 凭证无效
+	ErrInvalid = errors.New("authn: invalid credentials")
+)
+
+// Identity 认证通过的身份
+type Identity struct {
+	Provider string `json:"provider"` // 认证方式
+	User     string `json:"user"`     // 用户名、设备、证书CN等
+	Role     string `json:"role"`
+}
+
+// Provider 认证方式
+type Provider interface {
+	// Name 认证方式名称，如 basic、pin
+	Name() string
+	// Authenticate 认证请求，未携带凭证时返回ErrNoCredentials，w可用于设置会话Cookie
+	Authenticate(w http.ResponseWriter, r *http.Request) (Identity, error)
+	// Challenge 认证失败时设置响应头(如WWW-Authenticate)，返回true表示已写出完整响应(如PIN输入页)
+	Challenge(w http.ResponseWriter, r *http.Request) bool
+}
+
+// Chain 按顺序尝试的认证方式
+type Chain []Provider
+
+// Authenticate 依次尝试，第一个通过的方式生效；
+// 都未通过时，有凭证无效的返回ErrInvalid，否则返回ErrNoCredentials
+func (c Chain) Authenticate(w http.ResponseWriter, r *http.Request) (Identity, error) {
+	failed := ErrNoCredentials
+	for _, p := range c {
+		id, err := p.Authenticate(w, r)
+		if err == nil {
+			if id.Provider == "" {
+				id.Provider = p.Name()
+			}
+			return id, nil
+		}
+		if err != ErrNoCredentials {
+			failed = ErrInvalid
+		}
+	}
+	return Identity{}, failed
+}
+
+// Challenge 设置各认证方式的质询，有方式已写出完整响应时返回true
+func (c Chain) Challenge(w http.ResponseWriter, r *http.Request) bool {
+	for _, p := range c {
+		if p.Challenge(w, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Names 各认证方式的名称
+func (c Chain) Names() []string {
+	names := make([]string, len(c))
+	for i, p := range c {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Role 规范化角色名，未知角色按user
+func Role(role string) string {
+	switch role {
+	case RoleAdmin, RoleGuest:
+		return role
+	}
+	return RoleUser
+}
@@ -0,0 +1,256 @@
+package authn
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainBasicToken(t *testing.T) {
+	devices := &Bearer{ID: "device", Role: RoleUser, Verify: func(token string) (string, bool) { return "phone", token == "dev-1" }}
+	chain := Chain{
+		&Basic{User: "admin", Password: "secret", Role: RoleAdmin},
+		&Token{Tokens: map[string]string{"tk-1": "ci"}, Role: RoleGuest},
+		devices,
+	}
+	cases := []struct {
+		setup func(r *http.Request)
+		id    Identity
+		err   error
+	}{
+		{func(r *http.Request) {}, Identity{}, ErrNoCredentials},
+		{func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, Identity{"basic", "admin", RoleAdmin}, nil},
+		{func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }, Identity{}, ErrInvalid},
+		{func(r *http.Request) { r.Header.Set("Authorization", "Bearer tk-1") }, Identity{"token", "ci", RoleGuest}, nil},
+		{func(r *http.Request) { r.URL.RawQuery = "token=tk-1" }, Identity{"token", "ci", RoleGuest}, nil},
+		{func(r *http.Request) { r.Header.Set("Authorization", "Bearer dev-1") }, Identity{"device", "phone", RoleUser}, nil},
+		{func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, Identity{}, ErrNoCredentials},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		c.setup(r)
+		id, err := chain.Authenticate(httptest.NewRecorder(), r)
+		if id != c.id || err != c.err {
+			t.Errorf("case %d: %v %v, want %v %v", i, id, err, c.id, c.err)
+		}
+	}
+	w := httptest.NewRecorder()
+	if chain.Challenge(w, httptest.NewRequest(http.MethodGet, "/", nil)) || !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic") {
+		t.Error(w.Header())
+	}
+	if names := strings.Join(chain.Names(), ","); names != "basic,token,device" {
+		t.Error(names)
+	}
+}
+
+func TestPIN(t *testing.T) {
+	p := &PIN{PIN: "1234", Role: RoleGuest, Secret: []byte("k")}
+	r := httptest.NewRequest(http.MethodGet, "/?pin=1234", nil)
+	w := httptest.NewRecorder()
+	if id, err := p.Authenticate(w, r); err != nil || id.Role != RoleGuest {
+		t.Fatal(id, err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != PinCookie {
+		t.Fatal(cookies)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	if _, err := p.Authenticate(httptest.NewRecorder(), r); err != nil {
+		t.Error("cookie", err)
+	}
+	// 修改PIN后原会话失效
+	p2 := &PIN{PIN: "9999", Secret: []byte("k")}
+	if _, err := p2.Authenticate(httptest.NewRecorder(), r); err != ErrNoCredentials {
+		t.Error("old cookie", err)
+	}
+	// 连续输错后即使输入正确也被锁定
+	for i := 0; i < pinMaxFailures; i++ {
+		r = httptest.NewRequest(http.MethodGet, "/?pin=0000", nil)
+		if _, err := p.Authenticate(httptest.NewRecorder(), r); err != ErrInvalid {
+			t.Fatal(err)
+		}
+	}
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-B0Pass-Pin", "1234")
+	if _, err := p.Authenticate(httptest.NewRecorder(), r); err != ErrInvalid {
+		t.Error("lockout", err)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/files/", nil)
+	r.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	if !p.Challenge(w, r) || w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `action="/files/"`) {
+		t.Error(w.Code, w.Body.String())
+	}
+}
+
+func TestMTLS(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "ca"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}
+	caDER, _ := x509.CreateCertificate(rand.Reader, caTpl, caTpl, &caKey.PublicKey, caKey)
+	ca, _ := x509.ParseCertificate(caDER)
+	issue := func(cn string, signer *ecdsa.PrivateKey, parent *x509.Certificate) *x509.Certificate {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		tpl := &x509.Certificate{
+			SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: cn},
+			NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		if parent == nil {
+			parent, signer = tpl, key
+		}
+		der, _ := x509.CreateCertificate(rand.Reader, tpl, parent, &key.PublicKey, signer)
+		c, _ := x509.ParseCertificate(der)
+		return c
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	m := &MTLS{CAs: pool, CNs: []string{"laptop"}, Role: RoleAdmin}
+	auth := func(c *x509.Certificate) (Identity, error) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if c != nil {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{c}}
+		}
+		return m.Authenticate(httptest.NewRecorder(), r)
+	}
+	if id, err := auth(issue("laptop", caKey, ca)); err != nil || id.User != "laptop" || id.Role != RoleAdmin {
+		t.Error(id, err)
+	}
+	if _, err := auth(issue("other", caKey, ca)); err != ErrInvalid {
+		t.Error("cn", err)
+	}
+	if _, err := auth(issue("laptop", nil, nil)); err != ErrInvalid {
+		t.Error("self signed", err)
+	}
+	if _, err := auth(nil); err != ErrNoCredentials {
+		t.Error("no cert", err)
+	}
+}
+
+func TestLDAP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	binds := 0
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			msg, err := readBER(bufio.NewReader(conn))
+			if err == nil {
+				binds++
+				_, body, _, _ := nextTLV(msg)
+				_, _, rest, _ := nextTLV(body)
+				_, req, _, _ := nextTLV(rest)
+				_, _, rest, _ = nextTLV(req)
+				_, dn, rest, _ := nextTLV(rest)
+				_, pass, _, _ := nextTLV(rest)
+				code := byte(49) // invalidCredentials
+				if string(dn) == `uid=al\,ice,ou=people` && string(pass) == "pw" {
+					code = 0
+				}
+				resp := ber(0x30, concat(ber(0x02, []byte{1}), ber(0x61, concat(ber(0x0a, []byte{code}), ber(0x04, nil), ber(0x04, []byte("diag"))))))
+				_, _ = conn.Write(resp)
+			}
+			_ = conn.Close()
+		}
+	}()
+	l := &LDAP{URL: "ldap://" + ln.Addr().String(), BindDN: "uid=%s,ou=people", Role: RoleUser}
+	auth := func(user, pass string) error {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.SetBasicAuth(user, pass)
+		_, err := l.Authenticate(httptest.NewRecorder(), r)
+		return err
+	}
+	if err := auth("al,ice", "pw"); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth("al,ice", "pw"); err != nil || binds != 1 {
+		t.Error("cached", err, binds)
+	}
+	if err := auth("al,ice", "bad"); err != ErrInvalid {
+		t.Error(err)
+	}
+	if err := auth("al,ice", ""); err != ErrInvalid || binds != 2 {
+		t.Error("empty password", err, binds)
+	}
+	if err := l.Bind("uid=x", "y"); err == nil || !strings.Contains(err.Error(), "diag") {
+		t.Error(err)
+	}
+}
+
+func TestOIDC(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	b64 := base64.RawURLEncoding.EncodeToString
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{"jwks_uri": srv.URL + "/keys"})
+		case "/keys":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kty": "RSA", "kid": "k1", "use": "sig", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	sign := func(claims map[string]interface{}) string {
+		h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+		c, _ := json.Marshal(claims)
+		input := b64(h) + "." + b64(c)
+		digest := sha256.Sum256([]byte(input))
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		return input + "." + b64(sig)
+	}
+	o := &OIDC{Issuer: srv.URL, Audience: "b0pass", Role: RoleUser}
+	auth := func(token string) (Identity, error) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		return o.Authenticate(httptest.NewRecorder(), r)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	good := sign(map[string]interface{}{"iss": srv.URL, "aud": []string{"b0pass"}, "exp": exp, "email": "a@b.c"})
+	if id, err := auth(good); err != nil || id.User != "a@b.c" {
+		t.Fatal(id, err)
+	}
+	bad := []string{
+		sign(map[string]interface{}{"iss": "https://evil", "aud": "b0pass", "exp": exp}),
+		sign(map[string]interface{}{"iss": srv.URL, "aud": "other", "exp": exp}),
+		sign(map[string]interface{}{"iss": srv.URL, "aud": "b0pass", "exp": time.Now().Add(-time.Hour).Unix()}),
+		good[:len(good)-4] + "AAAA",
+	}
+	for i, token := range bad {
+		if _, err := auth(token); err != ErrInvalid {
+			t.Errorf("bad %d: %v", i, err)
+		}
+	}
+	if _, err := auth("not-a-jwt"); err != ErrNoCredentials {
+		t.Error(err)
+	}
+}
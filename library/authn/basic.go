@@ -0,0 +1,111 @@
+package authn
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Basic HTTP Basic认证(单个账号)
+type Basic struct {
+	User     string
+	Password string
+	Role     string
+	Realm    string
+}
+
+// Name 认证方式名称
+func (b *Basic) Name() string { return "basic" }
+
+// Authenticate 校验Authorization: Basic
+func (b *Basic) Authenticate(w http.ResponseWriter, r *http.Request) (Identity, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+	if !equal(user, b.User) || !equal(pass, b.Password) {
+		return Identity{}, ErrInvalid
+	}
+	return Identity{User: user, Role: Role(b.Role)}, nil
+}
+
+// Challenge 浏览器据此弹出登录框
+func (b *Basic) Challenge(w http.ResponseWriter, r *http.Request) bool {
+	realm := b.Realm
+	if realm == "" {
+		realm = "B0Pass"
+	}
+	w.Header().Add("WWW-Authenticate", `Basic realm="`+realm+`"`)
+	return false
+}
+
+// Token 静态访问令牌，通过 Authorization: Bearer 或 ?token= 传入
+type Token struct {
+	// Tokens 令牌 -> 名称(用于日志与审计)
+	Tokens map[string]string
+	Role   string
+}
+
+// Name 认证方式名称
+func (t *Token) Name() string { return "token" }
+
+// Authenticate 校验令牌
+func (t *Token) Authenticate(w http.ResponseWriter, r *http.Request) (Identity, error) {
+	token := BearerToken(r)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		return Identity{}, ErrNoCredentials
+	}
+	for tk, name := range t.Tokens {
+		if equal(token, tk) {
+			return Identity{User: name, Role: Role(t.Role)}, nil
+		}
+	}
+	// 可能是其它方式(设备、OIDC)的Bearer令牌
+	return Identity{}, ErrNoCredentials
+}
+
+// Challenge 令牌无需质询
+func (t *Token) Challenge(w http.ResponseWriter, r *http.Request) bool { return false }
+
+// Bearer 由函数校验的Bearer令牌，如已配对移动设备的访问令牌
+type Bearer struct {
+	ID     string
+	Verify func(token string) (user string, ok bool)
+	Role   string
+}
+
+// Name 认证方式名称
+func (b *Bearer) Name() string { return b.ID }
+
+// Authenticate 校验Authorization: Bearer
+func (b *Bearer) Authenticate(w http.ResponseWriter, r *http.Request) (Identity, error) {
+	token := BearerToken(r)
+	if token == "" {
+		return Identity{}, ErrNoCredentials
+	}
+	user, ok := b.Verify(token)
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+	return Identity{User: user, Role: Role(b.Role)}, nil
+}
+
+// Challenge Bearer令牌无需质询
+func (b *Bearer) Challenge(w http.ResponseWriter, r *http.Request) bool { return false }
+
+// BearerToken Authorization: Bearer 中的令牌
+func BearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// equal 常量时间比较
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,253 @@
+package authn
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LDAP 以HTTP Basic账号向LDAP服务器做简单绑定(simple bind)认证，
+// 绑定成功的账号缓存一段时间，避免每个请求都连接LDAP
+type LDAP struct {
+	URL     string // ldap://host:389 或 ldaps://host:636
+	BindDN  string // 绑定DN模板，%s为用户名，如 uid=%s,ou=people,dc=example,dc=com
+	Role    string
+	Timeout time.Duration
+	Cache   time.Duration // 绑定成功的缓存时间，0为5分钟
+
+	mu    sync.Mutex
+	cache map[[32]byte]time.Time
+}
+
+// Name 认证方式名称
+func (l *LDAP) Name() string { return "ldap" }
+
+// Authenticate 校验Authorization: Basic
+func (l *LDAP) Authenticate(w http.ResponseWriter, r *http.Request) (Identity, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+	// 空密码在LDAP中是匿名绑定，总会成功
+	if user == "" || pass == "" {
+		return Identity{}, ErrInvalid
+	}
+	key := sha256.Sum256([]byte(user + "\x00" + pass))
+	if l.cached(key) {
+		return Identity{User: user, Role: Role(l.Role)}, nil
+	}
+	if err := l.Bind(fmt.Sprintf(l.BindDN, escapeDN(user)), pass); err != nil {
+		return Identity{}, ErrInvalid
+	}
+	l.store(key)
+	return Identity{User: user, Role: Role(l.Role)}, nil
+}
+
+// Challenge 与Basic相同
+func (l *LDAP) Challenge(w http.ResponseWriter, r *http.Request) bool {
+	if w.Header().Get("WWW-Authenticate") == "" {
+		w.Header().Add("WWW-Authenticate", `Basic realm="B0Pass"`)
+	}
+	return false
+}
+
+// Bind 连接LDAP服务器并做简单绑定
+func (l *LDAP) Bind(dn, password string) error {
+	u, err := url.Parse(l.URL)
+	if err != nil {
+		return err
+	}
+	timeout := l.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	host := u.Host
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if u.Port() == "" {
+			host += ":389"
+		}
+		conn, err = dialer.Dial("tcp", host)
+	case "ldaps":
+		if u.Port() == "" {
+			host += ":636"
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return errors.New("authn: unsupported ldap url " + l.URL)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	// LDAPMessage { messageID 1, BindRequest { version 3, name, simple password } }
+	req := ber(0x30, append(ber(0x02, []byte{1}), ber(0x60, concat(
+		ber(0x02, []byte{3}),
+		ber(0x04, []byte(dn)),
+		ber(0x80, []byte(password)),
+	))...))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	msg, err := readBER(bufio.NewReader(conn))
+	if err != nil {
+		return err
+	}
+	// LDAPMessage { messageID, BindResponse { resultCode, matchedDN, diagnosticMessage } }
+	_, body, _, err := nextTLV(msg)
+	if err != nil {
+		return err
+	}
+	_, _, rest, err := nextTLV(body)
+	if err != nil {
+		return err
+	}
+	tag, resp, _, err := nextTLV(rest)
+	if err != nil || tag != 0x61 {
+		return errors.New("authn: unexpected ldap response")
+	}
+	tag, code, rest, err := nextTLV(resp)
+	if err != nil || tag != 0x0a || len(code) != 1 {
+		return errors.New("authn: unexpected ldap response")
+	}
+	if code[0] != 0 {
+		diag := ""
+		if _, _, rest, err = nextTLV(rest); err == nil {
+			if _, d, _, err := nextTLV(rest); err == nil {
+				diag = string(d)
+			}
+		}
+		return fmt.Errorf("authn: ldap bind failed (result %d) %s", code[0], diag)
+	}
+	return nil
+}
+
+// cached 绑定结果是否仍在缓存中
+func (l *LDAP) cached(key [32]byte) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	exp, ok := l.cache[key]
+	if ok && time.Now().After(exp) {
+		delete(l.cache, key)
+		return false
+	}
+	return ok
+}
+
+// store 缓存绑定成功的账号
+func (l *LDAP) store(key [32]byte) {
+	ttl := l.Cache
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cache == nil {
+		l.cache = make(map[[32]byte]time.Time)
+	}
+	l.cache[key] = time.Now().Add(ttl)
+}
+
+// escapeDN 转义DN属性值中的特殊字符(RFC 4514)
+func escapeDN(s string) string {
+	var b strings.Builder
+	for i, c := range s {
+		if strings.ContainsRune(`,+"\<>;=`, c) || (i == 0 && (c == ' ' || c == '#')) || (i == len(s)-1 && c == ' ') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// ber 编码一个BER元素
+func ber(tag byte, content []byte) []byte {
+	n := len(content)
+	var head []byte
+	switch {
+	case n < 0x80:
+		head = []byte{tag, byte(n)}
+	case n < 0x100:
+		head = []byte{tag, 0x81, byte(n)}
+	case n < 0x10000:
+		head = []byte{tag, 0x82, byte(n >> 8), byte(n)}
+	default:
+		head = []byte{tag, 0x84, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	return append(head, content...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// readBER 从连接读取一个完整的BER元素
+func readBER(r *bufio.Reader) ([]byte, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	n := int(head[1])
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 {
+			return nil, errors.New("authn: bad ber length")
+		}
+		lb := make([]byte, size)
+		if _, err := io.ReadFull(r, lb); err != nil {
+			return nil, err
+		}
+		head = append(head, lb...)
+		n = 0
+		for _, c := range lb {
+			n = n<<8 | int(c)
+		}
+	}
+	if n > 1<<20 {
+		return nil, errors.New("authn: ldap response too large")
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return append(head, body...), nil
+}
+
+// nextTLV 解析第一个元素
+func nextTLV(b []byte) (tag byte, content, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errors.New("authn: short ber")
+	}
+	tag, n, off := b[0], int(b[1]), 2
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 || len(b) < 2+size {
+			return 0, nil, nil, errors.New("authn: bad ber length")
+		}
+		n = 0
+		for _, c := range b[2 : 2+size] {
+			n = n<<8 | int(c)
+		}
+		off += size
+	}
+	if n < 0 || len(b) < off+n {
+		return 0, nil, nil, errors.New("authn: short ber")
+	}
+	return tag, b[off : off+n], b[off+n:], nil
+}
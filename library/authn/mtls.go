@@ -0,0 +1,77 @@
+package authn
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+)
+
+// MTLS 客户端证书认证，HTTPS连接上的客户端证书须由CA签发
+// 服务端只请求证书(不强制)，因此可与其它认证方式组合
+type MTLS struct {
+	CAs  *x509.CertPool
+	CNs  []string // 允许的证书CN，为空时CA签发的证书均可
+	Role string
+}
+
+// LoadCA 读取PEM格式的CA证书
+func LoadCA(file string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("authn: no certificate in " + file)
+	}
+	return pool, nil
+}
+
+// Name 认证方式名称
+func (m *MTLS) Name() string { return "mtls" }
+
+// Authenticate 校验连接上的客户端证书
+func (m *MTLS) Authenticate(w http.ResponseWriter, r *http.Request) (Identity, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return Identity{}, ErrNoCredentials
+	}
+	if m.CAs == nil {
+		return Identity{}, ErrInvalid
+	}
+	certs := r.TLS.PeerCertificates
+	opts := x509.VerifyOptions{
+		Roots:         m.CAs,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, c := range certs[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	if _, err := certs[0].Verify(opts); err != nil {
+		return Identity{}, ErrInvalid
+	}
+	cn := certs[0].Subject.CommonName
+	if len(m.CNs) > 0 && !contains(m.CNs, cn) {
+		return Identity{}, ErrInvalid
+	}
+	return Identity{User: cn, Role: Role(m.Role)}, nil
+}
+
+// Challenge 证书在TLS握手时提供，无需质询
+func (m *MTLS) Challenge(w http.ResponseWriter, r *http.Request) bool { return false }
+
+// Configure 使HTTPS服务在握手时请求客户端证书(由Authenticate校验)
+func (m *MTLS) Configure(c *tls.Config) {
+	c.ClientAuth = tls.RequestClientCert
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+package authn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDC 校验OpenID Connect身份提供方签发的令牌(JWT，RS256/ES256)，通过 Authorization: Bearer 传入
+// 公钥从发现文档(.well-known/openid-configuration)中的jwks_uri获取并缓存
+type OIDC struct {
+	Issuer    string
+	Audience  string // 令牌的aud须包含该值，为空时不检查
+	Role      string
+	UserClaim string // 作为用户名的声明，默认preferred_username，没有时依次为email、sub
+	Client    *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// oidcClaims 校验需要的声明
+type oidcClaims struct {
+	Iss string          `json:"iss"`
+	Aud json.RawMessage `json:"aud"`
+	Exp int64           `json:"exp"`
+	Nbf int64           `json:"nbf"`
+}
+
+const (
+	oidcSkew    = time.Minute    // 允许的时钟偏差
+	oidcRefresh = time.Hour      // 公钥缓存时间
+	oidcRetry   = time.Minute    // 未知kid时重新获取公钥的最小间隔
+	oidcMaxBody = int64(1 << 20) // 发现文档与公钥的大小上限
+)
+
+// Name 认证方式名称
+func (o *OIDC) Name() string { return "oidc" }
+
+// Authenticate 校验Bearer令牌的签名、签发方、受众与有效期
+func (o *OIDC) Authenticate(w http.ResponseWriter, r *http.Request) (Identity, error) {
+	token := BearerToken(r)
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		// 不是JWT，可能是设备令牌等
+		return Identity{}, ErrNoCredentials
+	}
+	claims, err := o.Verify(token)
+	if err != nil {
+		return Identity{}, ErrInvalid
+	}
+	user := ""
+	for _, name := range []string{o.UserClaim, "preferred_username", "email", "sub"} {
+		if s, ok := claims[name].(string); name != "" && ok && s != "" {
+			user = s
+			break
+		}
+	}
+	return Identity{User: user, Role: Role(o.Role)}, nil
+}
+
+// Challenge Bearer令牌无需质询
+func (o *OIDC) Challenge(w http.ResponseWriter, r *http.Request) bool { return false }
+
+// Verify 校验令牌并返回全部声明
+func (o *OIDC) Verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("authn: malformed jwt")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	key, err := o.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			return nil, errors.New("authn: bad jwt signature")
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(sig) != 64 ||
+			!ecdsa.Verify(k, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return nil, errors.New("authn: bad jwt signature")
+		}
+	default:
+		return nil, errors.New("authn: unsupported jwt key")
+	}
+	var c oidcClaims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if strings.TrimSuffix(c.Iss, "/") != strings.TrimSuffix(o.Issuer, "/") {
+		return nil, errors.New("authn: jwt issuer mismatch")
+	}
+	if c.Exp == 0 || now.After(time.Unix(c.Exp, 0).Add(oidcSkew)) {
+		return nil, errors.New("authn: jwt expired")
+	}
+	if c.Nbf != 0 && now.Add(oidcSkew).Before(time.Unix(c.Nbf, 0)) {
+		return nil, errors.New("authn: jwt not yet valid")
+	}
+	if o.Audience != "" && !audience(c.Aud, o.Audience) {
+		return nil, errors.New("authn: jwt audience mismatch")
+	}
+	var all map[string]interface{}
+	err = decodeSegment(parts[1], &all)
+	return all, err
+}
+
+// key 按kid查找公钥，未知kid时重新获取(限制频率)
+func (o *OIDC) key(kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	k, ok := o.keys[kid]
+	stale := time.Since(o.fetched) > oidcRefresh
+	if ok && !stale {
+		return k, nil
+	}
+	if !ok && !stale && time.Since(o.fetched) < oidcRetry {
+		return nil, fmt.Errorf("authn: unknown jwt key %q", kid)
+	}
+	keys, err := o.fetchKeys()
+	o.fetched = time.Now()
+	if err != nil {
+		if ok {
+			return k, nil
+		}
+		return nil, err
+	}
+	o.keys = keys
+	if k, ok = keys[kid]; !ok {
+		return nil, fmt.Errorf("authn: unknown jwt key %q", kid)
+	}
+	return k, nil
+}
+
+// fetchKeys 读取发现文档与JWKS
+func (o *OIDC) fetchKeys() (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := o.getJSON(strings.TrimSuffix(o.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.JWKSURI == "" {
+		return nil, errors.New("authn: no jwks_uri in discovery document")
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := o.getJSON(discovery.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch {
+		case k.Kty == "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err1 != nil || err2 != nil || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys, nil
+}
+
+// getJSON 读取JSON文档
+func (o *OIDC) getJSON(u string, v interface{}) error {
+	client := o.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("authn: %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, oidcMaxBody)).Decode(v)
+}
+
+// decodeSegment 解码JWT的一段
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// audience aud(字符串或数组)是否包含want
+func audience(raw json.RawMessage, want string) bool {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return one == want
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		return contains(list, want)
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package authn
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PinCookie PIN认证通过后保存会话的Cookie
+const PinCookie = "b0pass_pin"
+
+// PIN 数字PIN认证，适合临时给访客使用: 输入一次后以Cookie保持，修改PIN后原会话失效
+// 同一地址连续输错后锁定一段时间，防止穷举
+type PIN struct {
+	PIN    string
+	Role   string
+	Secret []byte        // Cookie签名密钥
+	MaxAge time.Duration // 会话有效期，0为浏览器关闭前
+
+	mu       sync.Mutex
+	failures map[string]*pinFailure
+}
+
+// pinFailure 某地址的输错次数
+type pinFailure struct {
+	count int
+	until time.Time
+}
+
+const (
+	pinMaxFailures = 5
+	pinLockout     = time.Minute
+)
+
+// Name 认证方式名称
+func (p *PIN) Name() string { return "pin" }
+
+// Authenticate 校验会话Cookie，或 X-B0Pass-Pin 请求头 / pin 参数中的PIN(通过后设置Cookie)
+func (p *PIN) Authenticate(w http.ResponseWriter, r *http.Request) (Identity, error) {
+	if p.PIN == "" {
+		return Identity{}, ErrNoCredentials
+	}
+	if c, err := r.Cookie(PinCookie); err == nil && equal(c.Value, p.session()) {
+		return Identity{Role: Role(p.Role)}, nil
+	}
+	pin := r.Header.Get("X-B0Pass-Pin")
+	if pin == "" {
+		pin = r.URL.Query().Get("pin")
+	}
+	if pin == "" {
+		return Identity{}, ErrNoCredentials
+	}
+	host := clientHost(r)
+	if p.locked(host) || !equal(pin, p.PIN) {
+		p.fail(host)
+		return Identity{}, ErrInvalid
+	}
+	p.reset(host)
+	cookie := &http.Cookie{Name: PinCookie, Value: p.session(), Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode}
+	if p.MaxAge > 0 {
+		cookie.MaxAge = int(p.MaxAge / time.Second)
+	}
+	http.SetCookie(w, cookie)
+	return Identity{Role: Role(p.Role)}, nil
+}
+
+// Challenge 浏览器打开页面时显示PIN输入页
+func (p *PIN) Challenge(w http.ResponseWriter, r *http.Request) bool {
+	if p.PIN == "" || r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusUnauthorized)
+	_, _ = w.Write([]byte(strings.Replace(pinPage, "{path}", html.EscapeString(r.URL.Path), 1)))
+	return true
+}
+
+// session 会话Cookie的值，由PIN派生
+func (p *PIN) session() string {
+	m := hmac.New(sha256.New, p.Secret)
+	_, _ = m.Write([]byte("pin:" + p.PIN))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// locked 地址是否因多次输错被锁定
+func (p *PIN) locked(host string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f := p.failures[host]
+	return f != nil && time.Now().Before(f.until)
+}
+
+// fail 记录一次输错，达到上限时锁定
+func (p *PIN) fail(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures == nil {
+		p.failures = make(map[string]*pinFailure)
+	}
+	f := p.failures[host]
+	if f == nil {
+		f = &pinFailure{}
+		p.failures[host] = f
+	}
+	if f.count++; f.count >= pinMaxFailures {
+		f.count, f.until = 0, time.Now().Add(pinLockout)
+	}
+}
+
+// reset 输入正确后清除记录
+func (p *PIN) reset(host string) {
+	p.mu.Lock()
+	delete(p.failures, host)
+	p.mu.Unlock()
+}
+
+// clientHost 连接地址(不信任X-Real-IP)
+func clientHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// pinPage PIN输入页
+const pinPage = `<!DOCTYPE html>
+<html lang="zh-cn"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width,initial-scale=1">
+<title>B0Pass</title></head>
+<body style="font-family:sans-serif;text-align:center;padding:40px">
+<form method="get" action="{path}"><p>请输入PIN</p>
+<input name="pin" type="password" inputmode="numeric" autofocus style="font-size:20px;width:8em;text-align:center">
+<button type="submit" style="font-size:18px">进入</button></form>
+</body></html>`
@@ -0,0 +1,241 @@
+package batch
+
+import (
+	"b0pass/library/fileinfos"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 文件状态
+const (
+	StatusPending = "pending"
+	StatusPartial = "partial"
+	StatusDone    = "done"
+)
+
+// Entry 会话中的文件，Path为相对会话目录的路径
+type Entry struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Mtime int64  `json:"mtime"`
+}
+
+// FileState 文件的传输状态，ID与Offset用于分片上传续传
+type FileState struct {
+	Entry
+	ID     string `json:"id"`
+	Offset int64  `json:"offset"`
+	Status string `json:"status"`
+}
+
+// Session 批量传输会话(整个目录树的上传)
+type Session struct {
+	ID      string    `json:"id"`
+	Dir     string    `json:"path"`
+	Files   []Entry   `json:"-"`
+	Created time.Time `json:"created"`
+	updated time.Time
+}
+
+// Progress 会话进度
+type Progress struct {
+	Files     int          `json:"files"`
+	Done      int          `json:"done"`
+	Size      int64        `json:"size"`
+	Completed int64        `json:"completed"`
+	States    []*FileState `json:"states"`
+}
+
+// Store 会话存储，空闲超过TTL的会话自动清理
+type Store struct {
+	TTL time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewStore 创建会话存储
+func NewStore(ttl time.Duration) *Store {
+	return &Store{TTL: ttl, sessions: make(map[string]*Session)}
+}
+
+// CleanPath 规范化相对路径，拒绝越出目录与隐藏文件
+func CleanPath(p string) (string, error) {
+	p = strings.Trim(path.Clean("/"+strings.Replace(p, "\\", "/", -1)), "/")
+	if p == "" {
+		return "", errors.New("empty path")
+	}
+	for _, part := range strings.Split(p, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", errors.New("hidden path not allowed: " + p)
+		}
+	}
+	return p, nil
+}
+
+// Create 创建会话，dir为相对共享根目录的目标目录
+func (s *Store) Create(dir string, files []Entry) (*Session, error) {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	seen := make(map[string]bool)
+	list := make([]Entry, 0, len(files))
+	for _, f := range files {
+		p, err := CleanPath(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if f.Size < 0 {
+			return nil, errors.New("invalid size: " + p)
+		}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		f.Path = p
+		list = append(list, f)
+	}
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sess := &Session{ID: hex.EncodeToString(id), Dir: dir, Files: list, Created: now, updated: now}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now)
+	s.sessions[sess.ID] = sess
+	return sess, nil
+}
+
+// Get 获取会话并刷新空闲时间
+func (s *Store) Get(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.expire(now)
+	sess, ok := s.sessions[id]
+	if ok {
+		sess.updated = now
+	}
+	return sess, ok
+}
+
+// Remove 结束会话
+func (s *Store) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// Saved 会话的保存形式(含文件列表)，用于快照与恢复
+type Saved struct {
+	ID      string    `json:"id"`
+	Dir     string    `json:"path"`
+	Files   []Entry   `json:"files"`
+	Created time.Time `json:"created"`
+}
+
+// Save 当前所有会话
+func (s *Store) Save() []Saved {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+	list := make([]Saved, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		list = append(list, Saved{ID: sess.ID, Dir: sess.Dir, Files: sess.Files, Created: sess.Created})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+// Restore 恢复保存的会话，空闲时间从恢复时开始计算，已有的同ID会话不覆盖
+func (s *Store) Restore(list []Saved) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for _, v := range list {
+		if v.ID == "" || s.sessions[v.ID] != nil {
+			continue
+		}
+		s.sessions[v.ID] = &Session{ID: v.ID, Dir: v.Dir, Files: v.Files, Created: v.Created, updated: now}
+		n++
+	}
+	return n
+}
+
+func (s *Store) expire(now time.Time) {
+	if s.TTL <= 0 {
+		return
+	}
+	for id, sess := range s.sessions {
+		if now.Sub(sess.updated) > s.TTL {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// Target 文件的完整路径，dir为会话目标目录(Dir)对应的文件路径
+func (sess *Session) Target(dir string, e Entry) string {
+	return filepath.Join(dir, filepath.FromSlash(e.Path))
+}
+
+// Progress 根据目标文件与暂存文件计算各文件状态
+// 目标文件大小与修改时间均一致时视为已完成，存在暂存分片时从分片大小续传
+func (sess *Session) Progress(dir, tmpDir string) *Progress {
+	p := &Progress{Files: len(sess.Files), States: make([]*FileState, 0, len(sess.Files))}
+	for _, e := range sess.Files {
+		target := sess.Target(dir, e)
+		st := &FileState{Entry: e, ID: fileinfos.UploadID(filepath.Clean(target), e.Size, ""), Status: StatusPending}
+		if info, err := os.Stat(target); err == nil && !info.IsDir() && info.Size() == e.Size &&
+			(e.Mtime <= 0 || info.ModTime().Unix() == e.Mtime) {
+			st.Status, st.Offset = StatusDone, e.Size
+		} else if info, err := os.Stat(fileinfos.PartFile(tmpDir, st.ID)); err == nil && info.Size() < e.Size {
+			st.Status, st.Offset = StatusPartial, info.Size()
+		}
+		p.Size += e.Size
+		p.Completed += st.Offset
+		if st.Status == StatusDone {
+			p.Done++
+		}
+		p.States = append(p.States, st)
+	}
+	return p
+}
+
+// Tree 递归列出目录下的文件(跳过hide为真的文件与目录，为nil时跳过点文件)，用于整个目录的下载
+func Tree(dir string, hide func(rel string) bool) ([]Entry, error) {
+	if hide == nil {
+		hide = func(rel string) bool { return strings.HasPrefix(path.Base(rel), ".") }
+	}
+	var list []Entry
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		if hide(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !info.Mode().IsRegular() {
+			return nil
+		}
+		list = append(list, Entry{Path: rel, Size: info.Size(), Mtime: info.ModTime().Unix()})
+		return nil
+	})
+	return list, err
+}
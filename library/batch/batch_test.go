@@ -0,0 +1,113 @@
+package batch
+
+import (
+	"b0pass/library/fileinfos"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanPath(t *testing.T) {
+	for in, want := range map[string]string{"a/b.txt": "a/b.txt", "/a//b": "a/b", "../../x": "x", `a\b`: "a/b"} {
+		if got, err := CleanPath(in); err != nil || got != want {
+			t.Errorf("CleanPath(%q) = %q, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"", "..", "a/.git/config"} {
+		if _, err := CleanPath(in); err == nil {
+			t.Errorf("CleanPath(%q) accepted", in)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	root, _ := ioutil.TempDir("", "batch")
+	defer func() { _ = os.RemoveAll(root) }()
+	tmp := filepath.Join(root, ".tmp")
+	s := NewStore(time.Hour)
+	sess, err := s.Create("/up", []Entry{
+		{Path: "done.txt", Size: 3, Mtime: 1600000000},
+		{Path: "sub/partial.bin", Size: 10},
+		{Path: "sub/new.bin", Size: 5},
+		{Path: "sub/new.bin", Size: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sess.Files) != 3 {
+		t.Fatalf("duplicates not removed: %d", len(sess.Files))
+	}
+	done := sess.Target(root, sess.Files[0])
+	_ = os.MkdirAll(filepath.Dir(done), 0755)
+	_ = ioutil.WriteFile(done, []byte("abc"), 0644)
+	mt := time.Unix(1600000000, 0)
+	_ = os.Chtimes(done, mt, mt)
+	p := sess.Progress(root, tmp)
+	part, err := fileinfos.OpenPart(tmp, p.States[1].ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write([]byte("1234"))
+	_ = part.Close()
+
+	p = sess.Progress(root, tmp)
+	if p.Done != 1 || p.Size != 18 || p.Completed != 7 {
+		t.Errorf("bad progress %+v", p)
+	}
+	if p.States[0].Status != StatusDone || p.States[1].Status != StatusPartial || p.States[1].Offset != 4 ||
+		p.States[2].Status != StatusPending {
+		t.Errorf("bad states %+v %+v %+v", p.States[0], p.States[1], p.States[2])
+	}
+	if _, ok := s.Get(sess.ID); !ok {
+		t.Error("session not found")
+	}
+	s.Remove(sess.ID)
+	if _, ok := s.Get(sess.ID); ok {
+		t.Error("session not removed")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	s := NewStore(time.Millisecond)
+	sess, _ := s.Create("", nil)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := s.Get(sess.ID); ok {
+		t.Error("idle session not expired")
+	}
+}
+
+func TestSaveRestore(t *testing.T) {
+	s := NewStore(time.Hour)
+	sess, _ := s.Create("photos", []Entry{{Path: "a.jpg", Size: 1}})
+	saved := s.Save()
+	r := NewStore(time.Hour)
+	if n := r.Restore(saved); n != 1 {
+		t.Fatalf("restored %d", n)
+	}
+	got, ok := r.Get(sess.ID)
+	if !ok || got.Dir != "photos" || len(got.Files) != 1 || got.Files[0].Path != "a.jpg" {
+		t.Errorf("bad session %+v", got)
+	}
+	if n := r.Restore(saved); n != 0 {
+		t.Error("existing session overwritten")
+	}
+}
+
+func TestTree(t *testing.T) {
+	root, _ := ioutil.TempDir("", "batch")
+	defer func() { _ = os.RemoveAll(root) }()
+	_ = os.MkdirAll(filepath.Join(root, "a", ".hidden"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "a", "x.txt"), []byte("x"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(root, "a", ".hidden", "y"), []byte("y"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(root, "z"), []byte("zz"), 0644)
+	list, err := Tree(root, nil)
+	if err != nil || len(list) != 2 || list[0].Path != "a/x.txt" || list[1].Size != 2 {
+		t.Errorf("bad tree %+v %v", list, err)
+	}
+	list, _ = Tree(root, func(rel string) bool { return rel == "z" })
+	if len(list) != 2 || list[1].Path != "a/x.txt" {
+		t.Errorf("custom hide %+v", list)
+	}
+}
@@ -0,0 +1,206 @@
+package bootstrap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// 客户端引导: 按请求方的系统与架构提供对应的b0pass程序及一行安装命令，
+// 程序优先取本地目录中的文件(或正在运行的程序本身)，没有时由发布页代理下载
+
+// Name 程序名
+const Name = "b0pass"
+
+// Platform 系统与架构，取值同GOOS/GOARCH
+type Platform struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+}
+
+var (
+	osAlias = map[string]string{
+		"linux": "linux", "darwin": "darwin", "macos": "darwin", "mac": "darwin",
+		"windows": "windows", "win": "windows", "freebsd": "freebsd", "android": "android",
+	}
+	archAlias = map[string]string{
+		"amd64": "amd64", "x86_64": "amd64", "x64": "amd64",
+		"arm64": "arm64", "aarch64": "arm64", "armv8l": "arm64",
+		"arm": "arm", "armv7l": "arm", "armv7": "arm", "armv6l": "arm",
+		"386": "386", "i386": "386", "i686": "386", "x86": "386",
+	}
+	// mingw/msys/cygwin下uname -s输出如 MINGW64_NT-10.0
+	windowsUname = regexp.MustCompile(`^(mingw|msys|cygwin)`)
+)
+
+// Parse 规范化系统与架构名，可识别uname -s/-m的输出，无法识别时为空
+func Parse(goos, arch string) Platform {
+	goos, arch = strings.ToLower(strings.TrimSpace(goos)), strings.ToLower(strings.TrimSpace(arch))
+	p := Platform{OS: osAlias[goos], Arch: archAlias[arch]}
+	if p.OS == "" && windowsUname.MatchString(goos) {
+		p.OS = "windows"
+	}
+	return p
+}
+
+// Detect 从浏览器的User-Agent推断系统与架构，无法判断架构时按amd64
+func Detect(userAgent string) Platform {
+	ua := strings.ToLower(userAgent)
+	var p Platform
+	switch {
+	case strings.Contains(ua, "android"):
+		p.OS = "android"
+	case strings.Contains(ua, "windows"):
+		p.OS = "windows"
+	case strings.Contains(ua, "mac os x"), strings.Contains(ua, "macintosh"):
+		p.OS = "darwin"
+	case strings.Contains(ua, "freebsd"):
+		p.OS = "freebsd"
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		p.OS = "linux"
+	default:
+		return p
+	}
+	switch {
+	case strings.Contains(ua, "aarch64"), strings.Contains(ua, "arm64"):
+		p.Arch = "arm64"
+	case strings.Contains(ua, "armv7"), strings.Contains(ua, "armv6"):
+		p.Arch = "arm"
+	case strings.Contains(ua, "i686"), strings.Contains(ua, "i386"):
+		p.Arch = "386"
+	case p.OS == "android":
+		p.Arch = "arm64"
+	default:
+		p.Arch = "amd64"
+	}
+	return p
+}
+
+// Current 正在运行的程序的平台
+func Current() Platform {
+	return Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+}
+
+// Valid 系统与架构均已识别
+func (p Platform) Valid() bool {
+	return p.OS != "" && p.Arch != ""
+}
+
+// File 发布文件名，如 b0pass_linux_amd64、b0pass_windows_amd64.exe
+func (p Platform) File() string {
+	return Name + "_" + p.OS + "_" + p.Arch + p.Ext()
+}
+
+// Ext 可执行文件扩展名
+func (p Platform) Ext() string {
+	if p.OS == "windows" {
+		return ".exe"
+	}
+	return ""
+}
+
+// Source 程序来源
+type Source struct {
+	// Dir 存放各平台程序的目录，文件名同Platform.File
+	Dir string
+	// Release 发布页下载地址模板，{file} {os} {arch} 会被替换，为空时不代理
+	Release string
+	// Self 正在运行的程序，请求的平台与本机相同时使用
+	Self string
+}
+
+// Local 本地可直接提供的程序文件，没有时为空
+func (s Source) Local(p Platform) string {
+	if !p.Valid() {
+		return ""
+	}
+	if s.Dir != "" {
+		file := filepath.Join(s.Dir, p.File())
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+			return file
+		}
+	}
+	if s.Self != "" && p == Current() {
+		return s.Self
+	}
+	return ""
+}
+
+// Remote 发布页上的下载地址，未配置时为空
+func (s Source) Remote(p Platform) string {
+	if s.Release == "" || !p.Valid() {
+		return ""
+	}
+	return strings.NewReplacer("{file}", p.File(), "{os}", p.OS, "{arch}", p.Arch).Replace(s.Release)
+}
+
+// Available 可以提供的平台: 本地目录中的程序及本机平台，配置了发布页时为所有常用平台
+func (s Source) Available() []Platform {
+	set := map[Platform]bool{}
+	if s.Self != "" {
+		set[Current()] = true
+	}
+	if s.Release != "" {
+		for _, goos := range []string{"linux", "darwin", "windows"} {
+			for _, arch := range []string{"amd64", "arm64"} {
+				set[Platform{goos, arch}] = true
+			}
+		}
+		set[Platform{"linux", "arm"}] = true
+	}
+	if files, err := ioutil.ReadDir(s.Dir); err == nil {
+		for _, f := range files {
+			name := strings.TrimSuffix(f.Name(), ".exe")
+			parts := strings.Split(name, "_")
+			if f.IsDir() || len(parts) != 3 || parts[0] != Name {
+				continue
+			}
+			if p := Parse(parts[1], parts[2]); p.Valid() && p.File() == f.Name() {
+				set[p] = true
+			}
+		}
+	}
+	list := make([]Platform, 0, len(set))
+	for p := range set {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].OS != list[j].OS {
+			return list[i].OS < list[j].OS
+		}
+		return list[i].Arch < list[j].Arch
+	})
+	return list
+}
+
+// Install 平台对应的一行安装命令，base为服务地址(如 http://192.168.1.5:8899)
+func Install(base string, p Platform) string {
+	base = strings.TrimSuffix(base, "/")
+	if p.OS == "windows" {
+		return `powershell -c "iwr -UseBasicParsing '` + base + `/get/` + Name + `?os=windows&arch=` + p.Arch +
+			`' -OutFile ` + Name + `.exe"`
+	}
+	return "curl -fsSL " + base + "/get/install.sh | sh"
+}
+
+// Script 类Unix系统的安装脚本: 按uname下载对应程序到当前目录并赋予执行权限
+func Script(base string) string {
+	base = strings.TrimSuffix(base, "/")
+	return `#!/bin/sh
+# ` + Name + ` 安装脚本，由 ` + base + ` 提供
+set -e
+url="` + base + `/get/` + Name + `?os=$(uname -s)&arch=$(uname -m)"
+if command -v curl >/dev/null 2>&1; then
+    curl -fL -o ` + Name + ` "$url"
+else
+    wget -O ` + Name + ` "$url"
+fi
+chmod +x ` + Name + `
+echo "` + Name + ` installed: $(pwd)/` + Name + `"
+echo "run: ./` + Name + `"
+`
+}
@@ -0,0 +1,79 @@
+package bootstrap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDetect(t *testing.T) {
+	if p := Parse("Linux", "x86_64"); p != (Platform{"linux", "amd64"}) {
+		t.Error(p)
+	}
+	if p := Parse("Darwin", "arm64"); p != (Platform{"darwin", "arm64"}) {
+		t.Error(p)
+	}
+	if p := Parse("MINGW64_NT-10.0", "x86_64"); p.File() != "b0pass_windows_amd64.exe" {
+		t.Error(p)
+	}
+	if p := Parse("plan9", "mips"); p.Valid() {
+		t.Error(p)
+	}
+	agents := map[string]Platform{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36": {"windows", "amd64"},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) Safari/605.1": {"darwin", "amd64"},
+		"Mozilla/5.0 (X11; Linux aarch64) Firefox/115.0":               {"linux", "arm64"},
+		"Mozilla/5.0 (X11; Linux armv7l) Chrome/100":                   {"linux", "arm"},
+		"curl/7.68.0": {},
+	}
+	for ua, want := range agents {
+		if got := Detect(ua); got != want {
+			t.Errorf("Detect(%q) = %v, want %v", ua, got, want)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bootstrap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	for _, name := range []string{"b0pass_linux_arm64", "b0pass_windows_amd64.exe", "b0pass_linux_amd64.exe", "readme.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("bin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := Source{Dir: dir}
+	if got := s.Local(Platform{"linux", "arm64"}); got != filepath.Join(dir, "b0pass_linux_arm64") {
+		t.Error(got)
+	}
+	if got := s.Local(Platform{"linux", "amd64"}); got != "" {
+		t.Error(got)
+	}
+	if got := s.Available(); len(got) != 2 || got[0] != (Platform{"linux", "arm64"}) || got[1] != (Platform{"windows", "amd64"}) {
+		t.Error(got)
+	}
+	s.Self = "/usr/bin/b0pass"
+	if got := s.Local(Current()); got != s.Self && !strings.HasPrefix(got, dir) {
+		t.Error(got)
+	}
+	s.Release = "https://example.com/download/{file}"
+	if got := s.Remote(Platform{"darwin", "arm64"}); got != "https://example.com/download/b0pass_darwin_arm64" {
+		t.Error(got)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	if got := Install("http://10.0.0.2:8899/", Platform{"linux", "amd64"}); got != "curl -fsSL http://10.0.0.2:8899/get/install.sh | sh" {
+		t.Error(got)
+	}
+	if got := Install("http://10.0.0.2:8899", Platform{"windows", "arm64"}); !strings.Contains(got, "/get/b0pass?os=windows&arch=arm64") {
+		t.Error(got)
+	}
+	if got := Script("http://10.0.0.2:8899"); !strings.Contains(got, `url="http://10.0.0.2:8899/get/b0pass?os=$(uname -s)&arch=$(uname -m)"`) {
+		t.Error(got)
+	}
+}
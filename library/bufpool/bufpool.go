@@ -0,0 +1,99 @@
+package bufpool
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// 传输读写缓冲区池: 上传、下载与哈希计算共用固定大小的缓冲区，避免每个请求各自分配
+
+// DefaultSize 默认缓冲区大小
+const DefaultSize = 256 << 10
+
+// Pool 固定大小的缓冲区池
+type Pool struct {
+	size int
+	pool sync.Pool
+}
+
+// New 创建缓冲区池，size<=0时为DefaultSize
+func New(size int) *Pool {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	p := &Pool{size: size}
+	p.pool.New = func() interface{} {
+		b := make([]byte, p.size)
+		return &b
+	}
+	return p
+}
+
+// Size 缓冲区大小
+func (p *Pool) Size() int {
+	return p.size
+}
+
+// Get 取出缓冲区，用完后须Put归还
+func (p *Pool) Get() *[]byte {
+	return p.pool.Get().(*[]byte)
+}
+
+// Put 归还缓冲区，大小不符(如修改配置前取出)的直接丢弃
+func (p *Pool) Put(b *[]byte) {
+	if b != nil && len(*b) == p.size {
+		p.pool.Put(b)
+	}
+}
+
+// Copy 同io.Copy，使用池中的缓冲区
+// 文件之间的复制仍交给io.Copy(可使用copy_file_range等内核复制)，其余情况屏蔽ReadFrom/WriteTo，
+// 否则*os.File等类型会在内部另行分配缓冲区
+func (p *Pool) Copy(dst io.Writer, src io.Reader) (int64, error) {
+	if _, ok := src.(*os.File); ok {
+		if _, ok := dst.(*os.File); ok {
+			return io.Copy(dst, src)
+		}
+	}
+	b := p.Get()
+	defer p.Put(b)
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *b)
+}
+
+var std atomic.Value
+
+func init() {
+	std.Store(New(DefaultSize))
+}
+
+// Default 默认缓冲区池
+func Default() *Pool {
+	return std.Load().(*Pool)
+}
+
+// SetSize 修改默认缓冲区池的缓冲区大小，已取出的缓冲区归还时丢弃
+func SetSize(size int) {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	if Default().Size() != size {
+		std.Store(New(size))
+	}
+}
+
+// Get 从默认缓冲区池取出缓冲区
+func Get() *[]byte {
+	return Default().Get()
+}
+
+// Put 归还到默认缓冲区池
+func Put(b *[]byte) {
+	Default().Put(b)
+}
+
+// Copy 使用默认缓冲区池复制
+func Copy(dst io.Writer, src io.Reader) (int64, error) {
+	return Default().Copy(dst, src)
+}
@@ -0,0 +1,52 @@
+package bufpool
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	p := New(16)
+	var dst bytes.Buffer
+	src := strings.Repeat("0123456789", 100)
+	n, err := p.Copy(&dst, strings.NewReader(src))
+	if err != nil || n != int64(len(src)) || dst.String() != src {
+		t.Fatal(n, err)
+	}
+	b := p.Get()
+	if len(*b) != 16 {
+		t.Error(len(*b))
+	}
+	p.Put(b)
+}
+
+func TestSetSize(t *testing.T) {
+	defer SetSize(DefaultSize)
+	old := Get()
+	SetSize(1 << 10)
+	if Default().Size() != 1<<10 {
+		t.Fatal(Default().Size())
+	}
+	// 旧大小的缓冲区不会进入新池
+	Put(old)
+	if b := Get(); len(*b) != 1<<10 {
+		t.Error(len(*b))
+	}
+}
+
+func TestReuse(t *testing.T) {
+	p := New(1 << 20)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 100; i++ {
+		var dst bytes.Buffer
+		_, _ = p.Copy(&dst, strings.NewReader("hello"))
+	}
+	runtime.ReadMemStats(&after)
+	// 每次分配1MB缓冲区时总分配量会超过100MB
+	if n := after.TotalAlloc - before.TotalAlloc; n > 20<<20 {
+		t.Errorf("allocated %d bytes for 100 copies", n)
+	}
+}
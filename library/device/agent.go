@@ -0,0 +1,53 @@
+package device
+
+import "strings"
+
+// 按User-Agent识别浏览器与系统，作为未命名设备的默认名称
+
+// agentRule 按顺序匹配，前面的规则优先(如Edge的UA中也含Chrome)
+type agentRule struct {
+	token string
+	name  string
+}
+
+var browsers = []agentRule{
+	{"EdgA/", "Edge"}, {"EdgiOS/", "Edge"}, {"Edg/", "Edge"},
+	{"OPR/", "Opera"}, {"OPT/", "Opera"},
+	{"MicroMessenger/", "微信"}, {"QQBrowser/", "QQ浏览器"}, {"UCBrowser/", "UC浏览器"},
+	{"SamsungBrowser/", "Samsung Internet"}, {"HuaweiBrowser/", "华为浏览器"}, {"MiuiBrowser/", "小米浏览器"},
+	{"Firefox/", "Firefox"}, {"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"}, {"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"}, {"Wget/", "Wget"}, {"b0pass/", "b0pass"}, {"okhttp/", "okhttp"}, {"Go-http-client/", "Go"},
+}
+
+var systems = []agentRule{
+	{"iPhone", "iPhone"}, {"iPad", "iPad"}, {"Android", "Android"}, {"HarmonyOS", "HarmonyOS"},
+	{"Windows", "Windows"}, {"CrOS", "ChromeOS"}, {"Macintosh", "macOS"}, {"Mac OS X", "macOS"}, {"Linux", "Linux"},
+}
+
+func match(ua string, rules []agentRule) string {
+	for _, r := range rules {
+		if strings.Contains(ua, r.token) {
+			return r.name
+		}
+	}
+	return ""
+}
+
+// Agent 识别User-Agent中的浏览器(或客户端程序)与系统，无法识别的为空
+func Agent(ua string) (browser, system string) {
+	return match(ua, browsers), match(ua, systems)
+}
+
+// Describe 设备的默认名称，如 Chrome (Android)，无法识别时为空
+func Describe(ua string) string {
+	browser, system := Agent(ua)
+	switch {
+	case browser != "" && system != "":
+		return browser + " (" + system + ")"
+	case browser != "":
+		return browser
+	}
+	return system
+}
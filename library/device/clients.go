@@ -0,0 +1,185 @@
+package device
+
+import (
+	"b0pass/library/fsync"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 客户端: 按设备标识(配对设备为 device:<id>，浏览器为 browser:<Cookie>)记录访问过的设备，
+// 设备可以给自己起名(如"小明的iPhone")，未命名时按User-Agent显示默认名称
+
+// MaxName 设备名称的最大长度(字符)
+const MaxName = 40
+
+// ErrName 设备名称不合法(名称会用于收集链接的文件名)
+var ErrName = errors.New("name must be 1-40 characters without slashes or control characters")
+
+// Client 访问过的设备
+type Client struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"` // 设备自己设置的名称
+	Agent     string    `json:"agent"`
+	Addr      string    `json:"addr"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Label 显示名称: 设置的名称，其次为按User-Agent识别的名称，都没有时为地址
+func (c Client) Label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if d := Describe(c.Agent); d != "" {
+		return d
+	}
+	return c.Addr
+}
+
+// Key 设备的公开标识，用于在设备列表中指定发送对象，不暴露Cookie中的标识
+func Key(id string) string {
+	return hash("client:" + id)[:12]
+}
+
+// CleanName 校验并整理设备名称
+func CleanName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if strings.Trim(name, ".") == "" || utf8.RuneCountInString(name) > MaxName || strings.ContainsAny(name, `/\`) {
+		return "", ErrName
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", ErrName
+		}
+	}
+	return name, nil
+}
+
+// Clients 访问过的设备，持久化到文件
+// 最近访问时间等变化频繁，最多每分钟写入一次；新设备与改名立即写入
+type Clients struct {
+	mu      sync.Mutex
+	file    string
+	clients map[string]*Client
+	saved   time.Time
+}
+
+// OpenClients 打开客户端文件，文件不存在时创建空存储
+func OpenClients(file string) *Clients {
+	c := &Clients{file: file, clients: make(map[string]*Client)}
+	var list []Client
+	if b, err := ioutil.ReadFile(file); err == nil {
+		_ = json.Unmarshal(b, &list)
+	}
+	for i := range list {
+		c.clients[list[i].ID] = &list[i]
+	}
+	return c
+}
+
+// Seen 记录设备的一次访问
+func (c *Clients) Seen(id, addr, agent string) Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	cl, ok := c.clients[id]
+	if !ok {
+		cl = &Client{ID: id, FirstSeen: now}
+		c.clients[id] = cl
+	}
+	cl.Addr, cl.LastSeen = addr, now
+	if agent != "" {
+		cl.Agent = agent
+	}
+	if !ok || now.Sub(c.saved) > time.Minute {
+		_ = c.save()
+	}
+	return *cl
+}
+
+// Get 设备信息
+func (c *Clients) Get(id string) (Client, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cl, ok := c.clients[id]
+	if !ok {
+		return Client{}, false
+	}
+	return *cl, true
+}
+
+// Find 按公开标识(见Key)查找设备
+func (c *Clients) Find(key string) (Client, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, cl := range c.clients {
+		if Key(id) == key {
+			return *cl, true
+		}
+	}
+	return Client{}, false
+}
+
+// SetName 设置设备名称，name为空时恢复默认名称
+func (c *Clients) SetName(id, name string) (Client, error) {
+	if name != "" {
+		var err error
+		if name, err = CleanName(name); err != nil {
+			return Client{}, err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cl, ok := c.clients[id]
+	if !ok {
+		cl = &Client{ID: id, FirstSeen: time.Now(), LastSeen: time.Now()}
+		c.clients[id] = cl
+	}
+	cl.Name = name
+	return *cl, c.save()
+}
+
+// List 在since之后访问过的设备，按最近访问时间从新到旧排序
+func (c *Clients) List(since time.Time) []Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := make([]Client, 0, len(c.clients))
+	for _, cl := range c.clients {
+		if cl.LastSeen.After(since) {
+			list = append(list, *cl)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastSeen.After(list[j].LastSeen) })
+	return list
+}
+
+// Delete 删除设备(如取消配对后)
+func (c *Clients) Delete(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.clients[id]; !ok {
+		return nil
+	}
+	delete(c.clients, id)
+	return c.save()
+}
+
+// save 持久化到文件，原子替换文件
+func (c *Clients) save() error {
+	c.saved = time.Now()
+	if c.file == "" {
+		return nil
+	}
+	list := make([]Client, 0, len(c.clients))
+	for _, cl := range c.clients {
+		list = append(list, *cl)
+	}
+	return fsync.WriteJSON(c.file, list, 0644)
+}
@@ -0,0 +1,77 @@
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribe(t *testing.T) {
+	cases := map[string]string{
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Mobile Safari/537.36":                       "Chrome (Android)",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1": "Safari (iPhone)",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36 Edg/124.0":                   "Edge (Windows)",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14.4; rv:125.0) Gecko/20100101 Firefox/125.0":                                                     "Firefox (macOS)",
+		"curl/7.88.1": "curl",
+		"":            "",
+	}
+	for ua, want := range cases {
+		if got := Describe(ua); got != want {
+			t.Errorf("%q: got %q, want %q", ua, got, want)
+		}
+	}
+}
+
+func TestClients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clients")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "clients.json")
+
+	c := OpenClients(file)
+	ua := "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) Version/17.4 Mobile/15E148 Safari/604.1"
+	if cl := c.Seen("browser:a", "192.168.1.5", ua); cl.Label() != "Safari (iPhone)" || cl.FirstSeen.IsZero() {
+		t.Errorf("%+v %q", cl, cl.Label())
+	}
+	if cl := c.Seen("browser:b", "192.168.1.6", ""); cl.Label() != "192.168.1.6" {
+		t.Errorf("addr label %q", cl.Label())
+	}
+	for _, bad := range []string{"  ", "..", "a/b", "x\ny", strings.Repeat("长", MaxName+1)} {
+		if _, err := c.SetName("browser:a", bad); err != ErrName {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	if cl, err := c.SetName("browser:a", " 小明的iPhone "); err != nil || cl.Label() != "小明的iPhone" {
+		t.Fatalf("%+v %v", cl, err)
+	}
+	if list := c.List(time.Now().Add(-time.Minute)); len(list) != 2 || list[0].ID != "browser:b" {
+		t.Errorf("list %+v", list)
+	}
+
+	if cl, ok := c.Find(Key("browser:b")); !ok || cl.ID != "browser:b" || len(Key("browser:b")) != 12 {
+		t.Errorf("find %+v", cl)
+	}
+	if _, ok := c.Find(Key("browser:x")); ok {
+		t.Error("found unknown key")
+	}
+
+	// 重新打开后保留名称
+	c2 := OpenClients(file)
+	if cl, ok := c2.Get("browser:a"); !ok || cl.Name != "小明的iPhone" || cl.Agent != ua {
+		t.Errorf("reopen %+v", cl)
+	}
+	if cl, _ := c2.SetName("browser:a", ""); cl.Label() != "Safari (iPhone)" {
+		t.Errorf("reset %q", cl.Label())
+	}
+	if err := c2.Delete("browser:b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c2.Get("browser:b"); ok {
+		t.Error("deleted client still present")
+	}
+}
@@ -0,0 +1,257 @@
+package device
+
+import (
+	"b0pass/library/fsync"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCode    = errors.New("invalid or expired pairing code")
+	ErrToken   = errors.New("invalid or expired token")
+	ErrNoEntry = errors.New("device not found")
+)
+
+// Push 推送注册信息，Provider为fcm/apns/webpush等
+type Push struct {
+	Provider string `json:"provider"`
+	Token    string `json:"token"`
+}
+
+// Device 已配对的设备，只保存刷新令牌的哈希
+type Device struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Platform string    `json:"platform"`
+	Push     *Push     `json:"push,omitempty"`
+	Paired   time.Time `json:"paired"`
+	LastSeen time.Time `json:"last_seen"`
+	refresh  string
+}
+
+// Token 配对或刷新得到的令牌
+type Token struct {
+	DeviceID     string `json:"device_id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type access struct {
+	id      string
+	expires time.Time
+}
+
+// Registry 设备注册表，设备与刷新令牌持久化到文件，访问令牌与配对码仅在内存中
+// 服务重启后访问令牌失效，设备使用刷新令牌重新获取即可
+type Registry struct {
+	// AccessTTL 访问令牌有效期
+	AccessTTL time.Duration
+	// CodeTTL 配对码有效期
+	CodeTTL time.Duration
+
+	mu      sync.Mutex
+	file    string
+	devices map[string]*Device
+	codes   map[string]time.Time
+	access  map[string]access
+}
+
+// record 持久化格式
+type record struct {
+	Device
+	Refresh string `json:"refresh"`
+}
+
+// Open 打开注册表文件，文件不存在时创建空注册表
+func Open(file string) *Registry {
+	r := &Registry{
+		AccessTTL: time.Hour,
+		CodeTTL:   5 * time.Minute,
+		file:      file,
+		devices:   make(map[string]*Device),
+		codes:     make(map[string]time.Time),
+		access:    make(map[string]access),
+	}
+	var list []record
+	if b, err := ioutil.ReadFile(file); err == nil {
+		_ = json.Unmarshal(b, &list)
+	}
+	for _, rec := range list {
+		d := rec.Device
+		d.refresh = rec.Refresh
+		r.devices[d.ID] = &d
+	}
+	return r
+}
+
+func random(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewCode 生成一次性配对码(8位，便于手动输入)
+func (r *Registry) NewCode() (string, time.Time) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	code := base32.StdEncoding.EncodeToString(b)
+	expires := time.Now().Add(r.CodeTTL)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire(time.Now())
+	r.codes[code] = expires
+	return code, expires
+}
+
+// Pair 使用配对码注册设备
+func (r *Registry) Pair(code, name, platform string) (*Token, error) {
+	code = strings.ToUpper(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.expire(now)
+	if _, ok := r.codes[code]; !ok {
+		return nil, ErrCode
+	}
+	delete(r.codes, code)
+	d := &Device{ID: random(8), Name: name, Platform: platform, Paired: now, LastSeen: now}
+	r.devices[d.ID] = d
+	return r.issue(d)
+}
+
+// Refresh 使用刷新令牌换取新的令牌，旧的刷新令牌随即失效
+func (r *Registry) Refresh(refresh string) (*Token, error) {
+	h := hash(refresh)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.devices {
+		if refresh != "" && d.refresh == h {
+			d.LastSeen = time.Now()
+			return r.issue(d)
+		}
+	}
+	return nil, ErrToken
+}
+
+// issue 签发令牌并持久化
+func (r *Registry) issue(d *Device) (*Token, error) {
+	t := &Token{DeviceID: d.ID, AccessToken: random(24), RefreshToken: random(32), ExpiresIn: int64(r.AccessTTL / time.Second)}
+	d.refresh = hash(t.RefreshToken)
+	r.access[hash(t.AccessToken)] = access{id: d.ID, expires: time.Now().Add(r.AccessTTL)}
+	return t, r.save()
+}
+
+// Verify 校验访问令牌，返回对应设备
+func (r *Registry) Verify(token string) (Device, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.access[hash(token)]
+	if !ok || time.Now().After(a.expires) {
+		return Device{}, false
+	}
+	d, ok := r.devices[a.id]
+	if !ok {
+		return Device{}, false
+	}
+	d.LastSeen = time.Now()
+	return *d, true
+}
+
+// SetPush 设置设备的推送注册信息，provider为空时清除
+func (r *Registry) SetPush(id, provider, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.devices[id]
+	if !ok {
+		return ErrNoEntry
+	}
+	d.Push = nil
+	if provider != "" {
+		d.Push = &Push{Provider: provider, Token: token}
+	}
+	return r.save()
+}
+
+// Rename 修改设备名称
+func (r *Registry) Rename(id, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.devices[id]
+	if !ok {
+		return ErrNoEntry
+	}
+	d.Name = name
+	return r.save()
+}
+
+// Revoke 移除设备，其令牌立即失效
+func (r *Registry) Revoke(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.devices[id]; !ok {
+		return ErrNoEntry
+	}
+	delete(r.devices, id)
+	for k, a := range r.access {
+		if a.id == id {
+			delete(r.access, k)
+		}
+	}
+	return r.save()
+}
+
+// List 已配对的设备，按配对时间排序
+func (r *Registry) List() []Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Device, 0, len(r.devices))
+	for _, d := range r.devices {
+		list = append(list, *d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Paired.Before(list[j].Paired) })
+	return list
+}
+
+func (r *Registry) expire(now time.Time) {
+	for code, exp := range r.codes {
+		if now.After(exp) {
+			delete(r.codes, code)
+		}
+	}
+	for k, a := range r.access {
+		if now.After(a.expires) {
+			delete(r.access, k)
+		}
+	}
+}
+
+// save 持久化到文件，原子替换文件
+func (r *Registry) save() error {
+	if r.file == "" {
+		return nil
+	}
+	list := make([]record, 0, len(r.devices))
+	for _, d := range r.devices {
+		list = append(list, record{Device: *d, Refresh: d.refresh})
+	}
+	return fsync.WriteJSON(r.file, list, 0600)
+}
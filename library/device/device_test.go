@@ -0,0 +1,85 @@
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPair(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "device")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "devices.json")
+	r := Open(file)
+	code, _ := r.NewCode()
+	if len(code) != 8 {
+		t.Errorf("bad code %q", code)
+	}
+	if _, err := r.Pair("WRONG123", "phone", "android"); err != ErrCode {
+		t.Errorf("expected ErrCode, got %v", err)
+	}
+	tok, err := r.Pair(code[:4]+"-"+code[4:], "phone", "android")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Pair(code, "again", "ios"); err != ErrCode {
+		t.Error("pairing code reused")
+	}
+	if d, ok := r.Verify(tok.AccessToken); !ok || d.Name != "phone" || d.ID != tok.DeviceID {
+		t.Errorf("verify failed %+v", d)
+	}
+	if err := r.SetPush(tok.DeviceID, "fcm", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Rename(tok.DeviceID, "pixel"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Rename("missing", "x"); err != ErrNoEntry {
+		t.Error("rename missing device:", err)
+	}
+
+	// 重启后访问令牌失效，刷新令牌仍有效
+	r = Open(file)
+	if _, ok := r.Verify(tok.AccessToken); ok {
+		t.Error("access token survived restart")
+	}
+	tok2, err := r.Refresh(tok.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Refresh(tok.RefreshToken); err != ErrToken {
+		t.Error("old refresh token still valid")
+	}
+	list := r.List()
+	if len(list) != 1 || list[0].Push == nil || list[0].Push.Token != "abc" || list[0].Name != "pixel" {
+		t.Errorf("bad list %+v", list)
+	}
+	if err := r.Revoke(tok2.DeviceID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Verify(tok2.AccessToken); ok {
+		t.Error("revoked device still verified")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	r := Open("")
+	r.CodeTTL, r.AccessTTL = time.Millisecond, time.Millisecond
+	code, _ := r.NewCode()
+	time.Sleep(5 * time.Millisecond)
+	if _, err := r.Pair(code, "x", "y"); err != ErrCode {
+		t.Error("expired code accepted")
+	}
+	r.CodeTTL = time.Minute
+	code, _ = r.NewCode()
+	tok, err := r.Pair(code, "x", "y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := r.Verify(tok.AccessToken); ok {
+		t.Error("expired access token accepted")
+	}
+}
@@ -0,0 +1,193 @@
+package dirsize
+
+import (
+	"b0pass/library/fsync"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 目录大小: 列表中的目录立即返回估算值(上次计算的结果或文件索引中的合计)，
+// 同时在后台逐个遍历计算，完成后回调；目录下文件变化时已有结果标记为过期，仍作为估算值返回
+
+// Size 目录大小
+type Size struct {
+	Bytes    int64 `json:"size"`
+	Files    int   `json:"files"`
+	Complete bool  `json:"complete"` // 为false时是估算值，计算完成后通过回调推送
+}
+
+// entry 计算结果
+type entry struct {
+	Bytes   int64     `json:"size"`
+	Files   int       `json:"files"`
+	Updated time.Time `json:"updated"`
+	stale   bool
+}
+
+// Options 缓存选项
+type Options struct {
+	Queue    int                                 // 计算队列长度
+	TTL      time.Duration                       // 结果的有效期，为0时只在文件变化后重新计算
+	Estimate func(dir string) (int64, int, bool) // 没有计算结果时的估算，为nil或返回false时估算为0
+	OnDone   func(dir string, s Size)            // 计算完成的回调
+}
+
+// Cache 目录大小缓存与后台计算队列
+type Cache struct {
+	opt  Options
+	file string
+
+	mu        sync.Mutex
+	sizes     map[string]*entry // key为目录绝对路径
+	computing map[string]bool   // 排队或计算中的目录
+	restart   map[string]bool   // 计算过程中有文件变化、完成后需重新计算的目录
+	queue     chan string
+	dirty     bool
+}
+
+// Open 打开持久化文件并启动后台计算，重启前保存的结果均视为过期(期间可能有变化)
+func Open(file string, opt Options) *Cache {
+	c := &Cache{
+		opt:       opt,
+		file:      file,
+		sizes:     make(map[string]*entry),
+		computing: make(map[string]bool),
+		restart:   make(map[string]bool),
+		queue:     make(chan string, opt.Queue),
+	}
+	if b, err := ioutil.ReadFile(file); err == nil {
+		_ = json.Unmarshal(b, &c.sizes)
+	}
+	for _, e := range c.sizes {
+		e.stale = true
+	}
+	go c.run()
+	return c
+}
+
+// Get 目录大小，结果不是最新时加入计算队列并返回估算值
+func (c *Cache) Get(dir string) Size {
+	dir = filepath.Clean(dir)
+	c.mu.Lock()
+	e := c.sizes[dir]
+	if e != nil && !e.stale && (c.opt.TTL <= 0 || time.Since(e.Updated) < c.opt.TTL) {
+		c.mu.Unlock()
+		return Size{Bytes: e.Bytes, Files: e.Files, Complete: true}
+	}
+	c.enqueue(dir)
+	c.mu.Unlock()
+	if e != nil {
+		return Size{Bytes: e.Bytes, Files: e.Files}
+	}
+	if c.opt.Estimate != nil {
+		if size, files, ok := c.opt.Estimate(dir); ok {
+			return Size{Bytes: size, Files: files}
+		}
+	}
+	return Size{}
+}
+
+// enqueue 加入计算队列，队列已满时放弃(下次读取时再加入)，调用方持有锁
+func (c *Cache) enqueue(dir string) {
+	if c.computing[dir] {
+		return
+	}
+	select {
+	case c.queue <- dir:
+		c.computing[dir] = true
+	default:
+	}
+}
+
+// Invalidate 文件或目录有变化，其所在的各级目录的结果标记为过期
+func (c *Cache) Invalidate(file string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for dir := filepath.Clean(file); ; dir = filepath.Dir(dir) {
+		if e := c.sizes[dir]; e != nil {
+			e.stale = true
+		}
+		if c.computing[dir] {
+			c.restart[dir] = true
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return
+		}
+	}
+}
+
+// run 依次计算队列中的目录
+func (c *Cache) run() {
+	for dir := range c.queue {
+		size, files, err := Walk(dir)
+		c.mu.Lock()
+		delete(c.computing, dir)
+		if err != nil {
+			// 目录已不存在
+			delete(c.sizes, dir)
+			delete(c.restart, dir)
+			c.dirty = true
+			c.mu.Unlock()
+			continue
+		}
+		stale := c.restart[dir]
+		delete(c.restart, dir)
+		c.sizes[dir] = &entry{Bytes: size, Files: files, Updated: time.Now(), stale: stale}
+		c.dirty = true
+		if stale {
+			c.enqueue(dir)
+		}
+		c.mu.Unlock()
+		if !stale && c.opt.OnDone != nil {
+			c.opt.OnDone(dir, Size{Bytes: size, Files: files, Complete: true})
+		}
+	}
+}
+
+// Walk 遍历目录计算文件总大小与数量，跳过隐藏文件与目录(如缩略图缓存、回收站)
+func Walk(dir string) (int64, int, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return 0, 0, err
+	}
+	var (
+		size  int64
+		files int
+	)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 无权限等无法读取的子目录不计入
+			return nil
+		}
+		if p != dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files, err
+}
+
+// Save 有变化时持久化到文件，原子替换文件
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty || c.file == "" {
+		return nil
+	}
+	if err := fsync.WriteJSON(c.file, c.sizes, 0644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,77 @@
+package dirsize
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirsize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	root := filepath.Join(dir, "files")
+	write := func(rel string, n int) {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, make([]byte, n), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a/1.bin", 100)
+	write("a/sub/2.bin", 50)
+	write("a/.thumbs/x.jpg", 1000)
+
+	done := make(chan Size, 4)
+	db := filepath.Join(dir, "dirsize.json")
+	c := Open(db, Options{
+		Queue:    8,
+		Estimate: func(string) (int64, int, bool) { return 42, 1, true },
+		OnDone:   func(_ string, s Size) { done <- s },
+	})
+	a := filepath.Join(root, "a")
+	if s := c.Get(a); s.Complete || s.Bytes != 42 {
+		t.Errorf("estimate %+v", s)
+	}
+	wait := func() Size {
+		select {
+		case s := <-done:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatal("not computed")
+		}
+		return Size{}
+	}
+	if s := wait(); s.Bytes != 150 || s.Files != 2 || !s.Complete {
+		t.Errorf("done %+v", s)
+	}
+	if s := c.Get(a); !s.Complete || s.Bytes != 150 {
+		t.Errorf("cached %+v", s)
+	}
+
+	// 文件变化后返回旧值并重新计算
+	write("a/sub/3.bin", 10)
+	c.Invalidate(filepath.Join(a, "sub", "3.bin"))
+	if s := c.Get(a); s.Complete || s.Bytes != 150 {
+		t.Errorf("stale %+v", s)
+	}
+	if s := wait(); s.Bytes != 160 || s.Files != 3 {
+		t.Errorf("recomputed %+v", s)
+	}
+
+	// 重新打开后保存的结果作为估算值
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	c2 := Open(db, Options{Queue: 8})
+	if s := c2.Get(a); s.Complete || s.Bytes != 160 {
+		t.Errorf("reopen %+v", s)
+	}
+	if s := c2.Get(filepath.Join(root, "missing")); s.Complete || s.Bytes != 0 {
+		t.Errorf("missing %+v", s)
+	}
+}
@@ -0,0 +1,223 @@
+package discovery
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 局域网发现: 各实例定期向组播地址通告自身的名称与端口，收到查询时立即通告，
+// 打开附近设备页时发送查询即可刷新列表，不必等待下一次定时通告；退出时通告离开。
+// 同一台电脑上的多个实例共用组播端口(SO_REUSEADDR)，都能收到
+
+// DefaultGroup 默认的组播地址
+const DefaultGroup = "239.255.43.21:8897"
+
+// 消息类型
+const (
+	TypeAnnounce = "announce"
+	TypeQuery    = "query"
+	TypeBye      = "bye"
+)
+
+// maxMessage 消息的最大字节数
+const maxMessage = 1024
+
+// Message 组播消息
+type Message struct {
+	Type    string `json:"t"`
+	ID      string `json:"id"` // 实例标识，每次启动时随机生成
+	Name    string `json:"name"`
+	Port    int    `json:"port"`
+	Base    string `json:"base,omitempty"` // 路径前缀，如 /b0pass
+	Version string `json:"v,omitempty"`
+}
+
+// Peer 发现的实例
+type Peer struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Addr    string    `json:"addr"` // 发送通告的地址
+	Port    int       `json:"port"`
+	Version string    `json:"version,omitempty"`
+	URL     string    `json:"url"`
+	Seen    time.Time `json:"seen"`
+}
+
+// Service 发现服务
+type Service struct {
+	Self     Message
+	Interval time.Duration // 定期通告的间隔，超过3倍间隔未收到通告的实例视为离开
+
+	conn    *net.UDPConn
+	group   *net.UDPAddr
+	mu      sync.Mutex
+	peers   map[string]*Peer
+	replied time.Time // 最近一次应答查询的时间，避免多个实例同时查询时频繁通告
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// New 创建未监听的发现服务，Start开始监听与通告
+func New(self Message, interval time.Duration) *Service {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Service{Self: self, Interval: interval, peers: map[string]*Peer{}, stop: make(chan struct{})}
+}
+
+// Start 在组播地址group(空为DefaultGroup)上监听并开始定期通告
+func (s *Service) Start(group string) error {
+	if group == "" {
+		group = DefaultGroup
+	}
+	addr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return err
+	}
+	if !addr.IP.IsMulticast() {
+		return errors.New("discovery: " + group + " is not a multicast address")
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, addr)
+	if err != nil {
+		return err
+	}
+	s.conn, s.group = conn, addr
+	go s.receive()
+	go s.announce()
+	return nil
+}
+
+// Query 发送查询，其他实例收到后立即通告
+func (s *Service) Query() error {
+	return s.send(TypeQuery)
+}
+
+// Close 通告离开并停止监听
+func (s *Service) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.stop)
+		if s.conn != nil {
+			_ = s.send(TypeBye)
+			err = s.conn.Close()
+		}
+	})
+	return err
+}
+
+// Peers 在线的其他实例，按名称与地址排序
+func (s *Service) Peers() []Peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expire := time.Now().Add(-3 * s.Interval)
+	list := make([]Peer, 0, len(s.peers))
+	for id, p := range s.peers {
+		if p.Seen.Before(expire) {
+			delete(s.peers, id)
+			continue
+		}
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].URL < list[j].URL
+	})
+	return list
+}
+
+// Handle 处理收到的消息，from为发送方地址，返回是否需要立即通告
+func (s *Service) Handle(m Message, from net.IP) bool {
+	if m.ID == "" || m.ID == s.Self.ID {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch m.Type {
+	case TypeBye:
+		delete(s.peers, m.ID)
+		return false
+	case TypeAnnounce, TypeQuery:
+		// 查询方同样是在线的实例
+		if m.Port > 0 && m.Port < 65536 && from != nil {
+			s.peers[m.ID] = &Peer{
+				ID:      m.ID,
+				Name:    m.Name,
+				Addr:    from.String(),
+				Port:    m.Port,
+				Version: m.Version,
+				URL:     "http://" + net.JoinHostPort(from.String(), strconv.Itoa(m.Port)) + strings.TrimSuffix(path.Clean("/"+m.Base), "/") + "/",
+				Seen:    time.Now(),
+			}
+		}
+		if m.Type == TypeQuery && time.Since(s.replied) >= time.Second {
+			s.replied = time.Now()
+			return true
+		}
+	}
+	return false
+}
+
+// receive 接收组播消息
+func (s *Service) receive() {
+	buf := make([]byte, maxMessage)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.stop:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		var m Message
+		if json.Unmarshal(buf[:n], &m) != nil {
+			continue
+		}
+		if s.Handle(m, from.IP) {
+			_ = s.send(TypeAnnounce)
+		}
+	}
+}
+
+// announce 启动时查询一次，之后定期通告
+func (s *Service) announce() {
+	_ = s.send(TypeQuery)
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			_ = s.send(TypeAnnounce)
+		}
+	}
+}
+
+// send 发送本实例的消息
+func (s *Service) send(typ string) error {
+	if s.conn == nil {
+		return errors.New("discovery: not started")
+	}
+	m := s.Self
+	m.Type = typ
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.WriteToUDP(data, s.group)
+	return err
+}
@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHandle(t *testing.T) {
+	s := New(Message{ID: "self", Name: "me", Port: 8899}, time.Minute)
+	ip := net.ParseIP("192.168.1.20")
+
+	// 自己的消息与缺少ID的消息忽略
+	s.Handle(Message{Type: TypeAnnounce, ID: "self", Port: 8899}, ip)
+	s.Handle(Message{Type: TypeAnnounce, Port: 8899}, ip)
+	if len(s.Peers()) != 0 {
+		t.Fatal(s.Peers())
+	}
+
+	if s.Handle(Message{Type: TypeAnnounce, ID: "b", Name: "desk", Port: 9000, Version: "1.0"}, ip) {
+		t.Fatal("announce should not be answered")
+	}
+	s.Handle(Message{Type: TypeAnnounce, ID: "a", Name: "desk", Port: 8899}, net.ParseIP("192.168.1.10"))
+	peers := s.Peers()
+	if len(peers) != 2 || peers[0].URL != "http://192.168.1.10:8899/" || peers[1].URL != "http://192.168.1.20:9000/" || peers[1].Version != "1.0" {
+		t.Fatal(peers)
+	}
+
+	// 查询时应答，1秒内的重复查询不再应答；查询方也记为在线
+	if !s.Handle(Message{Type: TypeQuery, ID: "c", Name: "laptop", Port: 8899}, net.ParseIP("192.168.1.30")) {
+		t.Fatal("query should be answered")
+	}
+	if s.Handle(Message{Type: TypeQuery, ID: "c", Name: "laptop", Port: 8899}, net.ParseIP("192.168.1.30")) {
+		t.Fatal("repeated query should be throttled")
+	}
+	if len(s.Peers()) != 3 {
+		t.Fatal(s.Peers())
+	}
+
+	// 部署在子路径下的实例
+	s.Handle(Message{Type: TypeAnnounce, ID: "e", Name: "nas", Port: 80, Base: "/b0pass"}, net.ParseIP("192.168.1.40"))
+	if peers := s.Peers(); len(peers) != 4 || peers[3].URL != "http://192.168.1.40:80/b0pass/" {
+		t.Fatal(peers)
+	}
+	s.Handle(Message{Type: TypeBye, ID: "e"}, ip)
+
+	// 无效端口不记录，离开时移除
+	s.Handle(Message{Type: TypeAnnounce, ID: "d", Port: 70000}, ip)
+	s.Handle(Message{Type: TypeBye, ID: "b"}, ip)
+	if peers := s.Peers(); len(peers) != 2 || peers[1].ID != "c" {
+		t.Fatal(peers)
+	}
+
+	// 超过3倍通告间隔未收到通告时视为离开
+	s.mu.Lock()
+	s.peers["a"].Seen = time.Now().Add(-4 * time.Minute)
+	s.mu.Unlock()
+	if peers := s.Peers(); len(peers) != 1 || peers[0].ID != "c" {
+		t.Fatal(peers)
+	}
+}
+
+func TestStart(t *testing.T) {
+	s := New(Message{ID: "x"}, time.Minute)
+	if err := s.Start("127.0.0.1:8897"); err == nil {
+		t.Fatal("expected error for non-multicast address")
+	}
+	if err := s.Query(); err == nil {
+		t.Fatal("expected error before start")
+	}
+	_ = s.Close()
+}
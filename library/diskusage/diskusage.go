@@ -0,0 +1,93 @@
+package diskusage
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNoSpace 预分配时磁盘空间不足
+var ErrNoSpace = errors.New("no space left on device")
+
+// 磁盘使用状态
+const (
+	LevelOK    = "ok"
+	LevelWarn  = "warn"
+	LevelBlock = "block"
+)
+
+// Usage 磁盘空间信息
+type Usage struct {
+	Total   uint64  `json:"total"`
+	Free    uint64  `json:"free"`
+	Used    uint64  `json:"used"`
+	Percent float64 `json:"percent"`
+}
+
+// Monitor 磁盘使用阈值监控
+type Monitor struct {
+	Path  string  // 监控目录
+	Warn  float64 // 告警阈值(%)
+	Block float64 // 拒绝上传阈值(%)
+
+	mu    sync.RWMutex
+	usage Usage
+	level string
+}
+
+// Get 获取path所在磁盘的空间信息
+func Get(path string) (Usage, error) {
+	total, free, err := statfs(path)
+	if err != nil {
+		return Usage{}, err
+	}
+	u := Usage{Total: total, Free: free}
+	if total > 0 {
+		u.Used = total - free
+		u.Percent = float64(u.Used) * 100 / float64(total)
+	}
+	return u, nil
+}
+
+// LevelOf 根据使用率计算状态
+func LevelOf(percent, warn, block float64) string {
+	switch {
+	case block > 0 && percent >= block:
+		return LevelBlock
+	case warn > 0 && percent >= warn:
+		return LevelWarn
+	}
+	return LevelOK
+}
+
+// Check 刷新磁盘状态，返回状态是否发生变化
+func (m *Monitor) Check() (bool, error) {
+	u, err := Get(m.Path)
+	if err != nil {
+		return false, err
+	}
+	level := LevelOf(u.Percent, m.Warn, m.Block)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := m.level != "" && m.level != level
+	if m.level == "" && level != LevelOK {
+		changed = true
+	}
+	m.usage, m.level = u, level
+	return changed, nil
+}
+
+// State 返回最近一次检测的空间信息与状态
+func (m *Monitor) State() (Usage, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.level == "" {
+		return m.usage, LevelOK
+	}
+	return m.usage, m.level
+}
+
+// Blocked 是否已达到拒绝上传阈值
+func (m *Monitor) Blocked() bool {
+	_, level := m.State()
+	return level == LevelBlock
+}
@@ -0,0 +1,82 @@
+package diskusage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	u, err := Get(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Total == 0 || u.Free > u.Total {
+		t.Errorf("bad usage %+v", u)
+	}
+	t.Log(u)
+}
+
+func TestLevelOf(t *testing.T) {
+	cases := map[float64]string{50: LevelOK, 90: LevelWarn, 96.9: LevelWarn, 97: LevelBlock}
+	for percent, want := range cases {
+		if got := LevelOf(percent, 90, 97); got != want {
+			t.Errorf("LevelOf(%v) = %s, want %s", percent, got, want)
+		}
+	}
+	if got := LevelOf(99, 0, 0); got != LevelOK {
+		t.Errorf("disabled thresholds = %s", got)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	m := &Monitor{Path: os.TempDir(), Warn: 0.0001, Block: 0}
+	changed, err := m.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || m.Blocked() {
+		t.Error("first check should report warn level")
+	}
+	if changed, _ = m.Check(); changed {
+		t.Error("level unchanged but reported change")
+	}
+}
+
+func TestPreallocate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskusage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	f, err := os.Create(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.WriteString("abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Preallocate(f, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	// 文件大小与写入位置不变
+	if _, err := f.WriteString("de"); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := f.Stat(); info.Size() != 5 {
+		t.Error("size", info.Size())
+	}
+	if err := Preallocate(f, 0); err != nil {
+		t.Error(err)
+	}
+	// 超出磁盘容量
+	u, err := Get(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Preallocate(f, int64(u.Total)*4); err != nil && err != ErrNoSpace {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package diskusage
+
+import "syscall"
+
+// statfs 获取磁盘总空间与可用空间
+func statfs(path string) (total, free uint64, err error) {
+	var st syscall.Statfs_t
+	if err = syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Blocks) * uint64(st.Bsize), uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+package diskusage
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// statfs 获取磁盘总空间与可用空间
+func statfs(path string) (total, free uint64, err error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var avail, all, allFree uint64
+	r, _, e := procGetDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&avail)),
+		uintptr(unsafe.Pointer(&all)),
+		uintptr(unsafe.Pointer(&allFree)),
+	)
+	if r == 0 {
+		return 0, 0, e
+	}
+	return all, avail, nil
+}
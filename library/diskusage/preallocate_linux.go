@@ -0,0 +1,33 @@
+//go:build linux
+// +build linux
+
+package diskusage
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocKeepSize FALLOC_FL_KEEP_SIZE: 只分配磁盘块，不改变文件大小
+const fallocKeepSize = 1
+
+// Preallocate 为f预先分配size字节的磁盘空间，减少碎片并在写入前发现空间不足(ErrNoSpace)；
+// 不改变文件大小，追加写入与续传的偏移不受影响，写入完成后用Truncate释放多分配的部分；
+// 文件系统不支持时忽略
+func Preallocate(f *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	for {
+		switch err := syscall.Fallocate(int(f.Fd()), fallocKeepSize, 0, size); err {
+		case nil:
+			return nil
+		case syscall.EINTR:
+			continue
+		case syscall.ENOSPC, syscall.EDQUOT:
+			return ErrNoSpace
+		default:
+			return nil
+		}
+	}
+}
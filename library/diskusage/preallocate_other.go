@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package diskusage
+
+import "os"
+
+// Preallocate 其它系统不预先分配(扩展文件大小会破坏续传的偏移)，只依赖上传前的空间检查
+func Preallocate(f *os.File, size int64) error {
+	return nil
+}
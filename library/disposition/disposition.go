@@ -0,0 +1,121 @@
+package disposition
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Content-Disposition: 按RFC 6266同时给出ASCII的filename与RFC 5987编码的filename*，
+// 支持filename*的浏览器使用UTF-8原名，其余使用ASCII替代名；少数不支持filename*的旧浏览器按User-Agent特殊处理，
+// 避免中文、日文文件名在下载时乱码
+
+// 处理方式
+const (
+	Inline     = "inline"     // 在浏览器中打开
+	Attachment = "attachment" // 作为附件下载
+)
+
+var (
+	// oldIE IE 6~8: 不支持filename*，会对filename中的%xx解码
+	oldIE = regexp.MustCompile(`MSIE [5-8]\.`)
+	// oldSafari Safari 5及以前: 不支持filename*，filename中的UTF-8原样使用
+	oldSafari = regexp.MustCompile(`Version/[1-5]\.[\d.]* (Mobile/\w+ )?Safari/`)
+	// oldAndroid Android 4.0以前的系统浏览器: 只能保存ASCII文件名
+	oldAndroid = regexp.MustCompile(`Android [1-3]\.`)
+)
+
+// Header kind为Inline或Attachment，name为文件名，ua为请求的User-Agent(可为空)
+func Header(kind, name, ua string) string {
+	name = clean(name)
+	switch {
+	case isASCII(name):
+		return kind + "; filename=" + quote(name)
+	case oldIE.MatchString(ua):
+		return kind + "; filename=" + quote(encode(name))
+	case oldSafari.MatchString(ua) && !strings.Contains(ua, "Chrome/"):
+		return kind + "; filename=" + quote(name)
+	case oldAndroid.MatchString(ua) && !strings.Contains(ua, "Chrome/"):
+		return kind + "; filename=" + quote(Fallback(name))
+	}
+	return kind + "; filename=" + quote(Fallback(name)) + "; filename*=UTF-8''" + encode(name)
+}
+
+// Fallback 文件名的ASCII替代名: 连续的非ASCII字符替换为一个_，保留扩展名，全部被替换时为download加扩展名
+func Fallback(name string) string {
+	var b strings.Builder
+	under := false
+	for _, c := range name {
+		if c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '%' {
+			if !under {
+				b.WriteByte('_')
+			}
+			under = true
+			continue
+		}
+		b.WriteRune(c)
+		under = false
+	}
+	s := b.String()
+	ext := path.Ext(s)
+	if stem := strings.Trim(strings.TrimSuffix(s, ext), "_ ."); stem == "" {
+		if strings.Trim(ext, "_.") == "" {
+			ext = ""
+		}
+		return "download" + ext
+	}
+	return s
+}
+
+// clean 去掉路径与控制字符，无效的UTF-8替换为U+FFFD
+func clean(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		c, size := utf8.DecodeRuneInString(name[i:])
+		i += size
+		if c < 0x20 || c == 0x7f {
+			continue
+		}
+		b.WriteRune(c)
+	}
+	if b.Len() == 0 {
+		return "download"
+	}
+	return b.String()
+}
+
+// isASCII 可直接作为quoted-string的可打印ASCII
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] >= 0x7f || s[i] == '%' {
+			return false
+		}
+	}
+	return true
+}
+
+// quote RFC 2616的quoted-string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// encode RFC 5987的ext-value编码: attr-char之外的字节编码为%XX
+func encode(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package disposition
+
+import "testing"
+
+func TestHeader(t *testing.T) {
+	const (
+		chrome    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+		firefox   = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+		safari    = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15"
+		ios       = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1"
+		wechat    = "Mozilla/5.0 (Linux; Android 13; V2154A) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/107.0.5304.141 Mobile Safari/537.36 XWEB/5235 MicroMessenger/8.0.40"
+		ie11      = "Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; rv:11.0) like Gecko"
+		ie8       = "Mozilla/4.0 (compatible; MSIE 8.0; Windows NT 6.1; Trident/4.0)"
+		safari5   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_6_8) AppleWebKit/534.57.2 (KHTML, like Gecko) Version/5.1.7 Safari/534.57.2"
+		android23 = "Mozilla/5.0 (Linux; U; Android 2.3.6; zh-cn; GT-S5830 Build/GINGERBREAD) AppleWebKit/533.1 (KHTML, like Gecko) Version/4.0 Mobile Safari/533.1"
+		curl      = "curl/8.4.0"
+	)
+	const std = `attachment; filename="download.pdf"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.pdf`
+	cases := []struct {
+		ua, name, want string
+	}{
+		{chrome, "报告.pdf", std},
+		{firefox, "报告.pdf", std},
+		{safari, "报告.pdf", std},
+		{ios, "报告.pdf", std},
+		{wechat, "报告.pdf", std},
+		{ie11, "报告.pdf", std},
+		{curl, "报告.pdf", std},
+		{"", "报告.pdf", std},
+		{ie8, "报告.pdf", `attachment; filename="%E6%8A%A5%E5%91%8A.pdf"`},
+		{safari5, "报告.pdf", `attachment; filename="报告.pdf"`},
+		{android23, "报告.pdf", `attachment; filename="download.pdf"`},
+		{chrome, "写真 2024.jpg", `attachment; filename="_ 2024.jpg"; filename*=UTF-8''%E5%86%99%E7%9C%9F%202024.jpg`},
+		{chrome, "a.txt", `attachment; filename="a.txt"`},
+		{chrome, `a "b".txt`, `attachment; filename="a \"b\".txt"`},
+		{chrome, "100%.txt", `attachment; filename="100_.txt"; filename*=UTF-8''100%25.txt`},
+		{chrome, "../../etc/passwd", `attachment; filename="passwd"`},
+		{chrome, "a\r\nb.txt", `attachment; filename="ab.txt"`},
+		{chrome, "x'(1);.txt", `attachment; filename="x'(1);.txt"`},
+		{chrome, "", `attachment; filename="download"`},
+	}
+	for _, c := range cases {
+		if got := Header(Attachment, c.name, c.ua); got != c.want {
+			t.Errorf("%q %q:\n got %s\nwant %s", c.ua, c.name, got, c.want)
+		}
+	}
+	if got := Header(Inline, "ü.mp4", chrome); got != `inline; filename="download.mp4"; filename*=UTF-8''%C3%BC.mp4` {
+		t.Error(got)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	cases := map[string]string{
+		"报告.pdf":         "download.pdf",
+		"季度报告-Q1.xlsx":   "_-Q1.xlsx",
+		"日本語":            "download",
+		"アルバム.写真":        "download",
+		"résumé.doc":     "r_sum_.doc",
+		"report (1).pdf": "report (1).pdf",
+	}
+	for in, want := range cases {
+		if got := Fallback(in); got != want {
+			t.Errorf("%s: %s", in, got)
+		}
+	}
+}
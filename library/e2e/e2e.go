@@ -0,0 +1,277 @@
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// 端到端加密文件: 浏览器用WebCrypto生成密钥并加密，密钥只放在链接的#片段中，服务端只保存与转发密文
+//
+// 密文格式: "B0E1" + 4字节明文分块大小 + 各分块的AES-256-GCM密文(每块多16字节认证标签)
+// 第i块的nonce为8字节序号与4字节标记(最后一块为1)，分块被重排、删除或截断时解密失败；
+// 分块大小固定，可以只解密需要的分块(支持Range)
+// 文件名等信息单独加密为meta，nonce为8字节0xFF与标记2
+
+const (
+	Magic      = "B0E1"
+	headerSize = 8
+	tagSize    = 16
+	KeySize    = 32
+	MinChunk   = 4 << 10
+	MaxChunk   = 16 << 20
+	// DefaultChunk 网页端使用的分块大小
+	DefaultChunk = 1 << 20
+)
+
+var (
+	ErrFormat = errors.New("not an encrypted blob")
+	ErrKey    = errors.New("wrong key or corrupted data")
+)
+
+// Meta 加密保存的文件信息
+type Meta struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// NewKey 生成随机密钥
+func NewKey() []byte {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// EncodeKey 密钥的链接形式(base64url，无填充)
+func EncodeKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodeKey 解析链接中的密钥
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, ErrKey
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func nonce(n uint64, flag byte) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b, n)
+	b[11] = flag
+	return b
+}
+
+// SealMeta 加密文件信息
+func SealMeta(key []byte, m Meta) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	b, _ := json.Marshal(m)
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nil, nonce(^uint64(0), 2), b, nil)), nil
+}
+
+// OpenMeta 解密文件信息
+func OpenMeta(key []byte, sealed string) (Meta, error) {
+	var m Meta
+	aead, err := newAEAD(key)
+	if err != nil {
+		return m, err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return m, ErrFormat
+	}
+	if b, err = aead.Open(nil, nonce(^uint64(0), 2), b, nil); err != nil {
+		return m, ErrKey
+	}
+	return m, json.Unmarshal(b, &m)
+}
+
+// Layout 由文件头与密文大小得到分块大小与明文大小，格式不对时返回ErrFormat
+func Layout(header []byte, size int64) (chunk int, plain int64, err error) {
+	if len(header) < headerSize || string(header[:4]) != Magic {
+		return 0, 0, ErrFormat
+	}
+	chunk = int(binary.BigEndian.Uint32(header[4:8]))
+	if chunk < MinChunk || chunk > MaxChunk {
+		return 0, 0, ErrFormat
+	}
+	body := size - headerSize
+	frame := int64(chunk + tagSize)
+	frames := (body + frame - 1) / frame
+	// 至少有一块(空文件为只有认证标签的最后一块)，最后一块不短于认证标签
+	if body < tagSize || body-(frames-1)*frame < tagSize {
+		return 0, 0, ErrFormat
+	}
+	return chunk, body - frames*tagSize, nil
+}
+
+// Writer 加密写入，Close时写出最后一块
+type Writer struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	n     uint64
+	chunk int
+}
+
+// NewWriter 按分块大小加密写入w
+func NewWriter(w io.Writer, key []byte, chunk int) (*Writer, error) {
+	if chunk < MinChunk || chunk > MaxChunk {
+		return nil, ErrFormat
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	copy(header, Magic)
+	binary.BigEndian.PutUint32(header[4:], uint32(chunk))
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, chunk: chunk, buf: make([]byte, 0, chunk)}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	total := len(p)
+	for len(p) > 0 {
+		// 缓冲区满且还有数据时才写出，保证最后一块在Close时写出
+		if len(w.buf) == w.chunk {
+			if err := w.seal(false); err != nil {
+				return total - len(p), err
+			}
+		}
+		n := copy(w.buf[len(w.buf):w.chunk], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+	}
+	return total, nil
+}
+
+func (w *Writer) seal(last bool) error {
+	var flag byte
+	if last {
+		flag = 1
+	}
+	out := w.aead.Seal(nil, nonce(w.n, flag), w.buf, nil)
+	w.n++
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(out)
+	return err
+}
+
+// Close 写出最后一块，不关闭底层的w
+func (w *Writer) Close() error {
+	return w.seal(true)
+}
+
+// Reader 解密读取，支持Seek，只解密读到的分块
+type Reader struct {
+	ra    io.ReaderAt
+	aead  cipher.AEAD
+	chunk int
+	size  int64 // 密文大小
+	plain int64
+	off   int64
+	// 最近解密的分块
+	index int64
+	data  []byte
+}
+
+// NewReader 解密size字节的密文，格式不对时返回ErrFormat，密钥错误在读取时返回ErrKey
+func NewReader(ra io.ReaderAt, size int64, key []byte) (*Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := ra.ReadAt(header, 0); err != nil {
+		return nil, ErrFormat
+	}
+	chunk, plain, err := Layout(header, size)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{ra: ra, aead: aead, chunk: chunk, size: size, plain: plain, index: -1}, nil
+}
+
+// Size 明文大小
+func (r *Reader) Size() int64 {
+	return r.plain
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.off >= r.plain {
+		return 0, io.EOF
+	}
+	index := r.off / int64(r.chunk)
+	if index != r.index {
+		if err := r.load(index); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.data[r.off-index*int64(r.chunk):])
+	r.off += int64(n)
+	return n, nil
+}
+
+// load 读取并解密第index块
+func (r *Reader) load(index int64) error {
+	frame := int64(r.chunk + tagSize)
+	start := headerSize + index*frame
+	end := start + frame
+	var flag byte
+	if end >= r.size {
+		end, flag = r.size, 1
+	}
+	b := make([]byte, end-start)
+	if n, err := r.ra.ReadAt(b, start); n < len(b) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	data, err := r.aead.Open(b[:0], nonce(uint64(index), flag), b, nil)
+	if err != nil {
+		return ErrKey
+	}
+	r.index, r.data = index, data
+	return nil
+}
+
+// Seek 实现io.Seeker，偏移为明文位置
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.plain
+	}
+	if offset < 0 {
+		return 0, errors.New("e2e: negative position")
+	}
+	r.off = offset
+	return offset, nil
+}
@@ -0,0 +1,156 @@
+package e2e
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+func encrypt(t *testing.T, key, data []byte, chunk int) []byte {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, key, chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 分多次写入，跨越分块边界
+	for p := data; len(p) > 0; {
+		n := 1000
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestReader(t *testing.T) {
+	key := NewKey()
+	for _, size := range []int{0, 1, MinChunk, 3*MinChunk + 77} {
+		data := make([]byte, size)
+		rand.Read(data)
+		blob := encrypt(t, key, data, MinChunk)
+		chunk, plain, err := Layout(blob, int64(len(blob)))
+		if err != nil || chunk != MinChunk || plain != int64(size) {
+			t.Fatalf("layout %d: %d %d %v", size, chunk, plain, err)
+		}
+		r, err := NewReader(bytes.NewReader(blob), int64(len(blob)), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if size > MinChunk {
+			// 只解密Range需要的分块
+			_, _ = r.Seek(int64(MinChunk-10), io.SeekStart)
+			part := make([]byte, 20)
+			if _, err := io.ReadFull(r, part); err != nil || !bytes.Equal(part, data[MinChunk-10:MinChunk+10]) {
+				t.Error("seek", err)
+			}
+		}
+	}
+
+	data := bytes.Repeat([]byte("x"), 2*MinChunk+5)
+	blob := encrypt(t, key, data, MinChunk)
+	read := func(b []byte, key []byte) error {
+		r, err := NewReader(bytes.NewReader(b), int64(len(b)), key)
+		if err == nil {
+			_, err = ioutil.ReadAll(r)
+		}
+		return err
+	}
+	if err := read(blob, NewKey()); err != ErrKey {
+		t.Error("wrong key", err)
+	}
+	// 去掉最后一块: 倒数第二块没有结束标记
+	if err := read(blob[:headerSize+2*(MinChunk+tagSize)], key); err != ErrKey {
+		t.Error("truncated", err)
+	}
+	if _, _, err := Layout([]byte("B0E1\x00\x00\x10\x00"), headerSize+5); err != ErrFormat {
+		t.Error("short", err)
+	}
+
+	sealed, err := SealMeta(key, Meta{Name: "报告.pdf", Type: "application/pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := OpenMeta(key, sealed); err != nil || m.Name != "报告.pdf" {
+		t.Error(m, err)
+	}
+	if _, err := OpenMeta(NewKey(), sealed); err != ErrKey {
+		t.Error("meta wrong key", err)
+	}
+	if k, err := DecodeKey(EncodeKey(key)); err != nil || !bytes.Equal(k, key) {
+		t.Error("key encoding", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "e2e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := NewKey()
+	blob := encrypt(t, key, []byte("hello"), DefaultChunk)
+	meta, _ := SealMeta(key, Meta{Name: "a.txt"})
+
+	if _, err := s.Put(bytes.NewReader([]byte("plain text")), Blob{Meta: meta}, 0); err != ErrFormat {
+		t.Error("plain upload", err)
+	}
+	if _, err := s.Put(bytes.NewReader(blob), Blob{Meta: meta}, 10); err != ErrTooLarge {
+		t.Error("max", err)
+	}
+	b, err := s.Put(bytes.NewReader(blob), Blob{Meta: meta, Owner: "device:a"}, 0)
+	if err != nil || b.Plain != 5 || b.Size != int64(len(blob)) || b.Chunk != DefaultChunk {
+		t.Fatal(b, err)
+	}
+	f, got, err := s.OpenBlob(b.ID)
+	if err != nil || got.Meta != meta {
+		t.Fatal(err)
+	}
+	r, _ := NewReader(f, got.Size, key)
+	if data, _ := ioutil.ReadAll(r); string(data) != "hello" {
+		t.Error(string(data))
+	}
+	_ = f.Close()
+	if _, err := s.Get("../" + b.ID[3:]); err != ErrNoEntry {
+		t.Error("invalid id", err)
+	}
+	_ = s.Downloaded(b.ID)
+	if list, _ := s.List("device:a"); len(list) != 1 || list[0].Downloads != 1 {
+		t.Error(list)
+	}
+	if list, _ := s.List("device:b"); len(list) != 0 {
+		t.Error(list)
+	}
+
+	old, _ := s.Put(bytes.NewReader(blob), Blob{Meta: meta, Expires: time.Now().Add(-time.Minute)}, 0)
+	if _, err := s.Get(old.ID); err != ErrNoEntry {
+		t.Error("expired", err)
+	}
+	if n, err := s.Expire(time.Now()); n != 1 || err != nil {
+		t.Error(n, err)
+	}
+	if err := s.Delete(b.ID); err != nil {
+		t.Error(err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Error(len(files))
+	}
+}
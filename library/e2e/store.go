@@ -0,0 +1,229 @@
+package e2e
+
+import (
+	"b0pass/library/fsync"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoEntry  = errors.New("encrypted file not found or expired")
+	ErrTooLarge = errors.New("encrypted file too large")
+)
+
+// MaxMeta 加密文件信息的长度上限
+const MaxMeta = 4096
+
+// Blob 服务端保存的密文及其信息，服务端不知道文件名与内容
+type Blob struct {
+	ID      string    `json:"id"`
+	Meta    string    `json:"meta"`  // 加密的文件信息
+	Size    int64     `json:"size"`  // 密文大小
+	Plain   int64     `json:"plain"` // 明文大小
+	Chunk   int       `json:"chunk"`
+	Owner   string    `json:"owner"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires,omitempty"` // 零值为不过期
+	// Downloads 密文或明文被完整下载的次数
+	Downloads int `json:"downloads"`
+}
+
+// Expired 是否已过期
+func (b Blob) Expired(now time.Time) bool {
+	return !b.Expires.IsZero() && now.After(b.Expires)
+}
+
+// Store 密文存储，每个文件为<id>.blob与<id>.json
+type Store struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// Open 打开存储目录
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+// validID 只接受Put生成的ID，避免路径穿越
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+// Put 保存上传的密文，max为密文大小上限(0为不限制)；密文格式不对时返回ErrFormat
+func (s *Store) Put(body io.Reader, b Blob, max int64) (Blob, error) {
+	if b.Meta == "" || len(b.Meta) > MaxMeta {
+		return b, ErrFormat
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	b.ID = hex.EncodeToString(id)
+	tmp, err := ioutil.TempFile(s.dir, ".upload-")
+	if err != nil {
+		return b, err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	src := body
+	if max > 0 {
+		src = io.LimitReader(body, max+1)
+	}
+	n, err := io.Copy(tmp, src)
+	if err != nil {
+		return b, err
+	}
+	if max > 0 && n > max {
+		return b, ErrTooLarge
+	}
+	header := make([]byte, headerSize)
+	if _, err := tmp.ReadAt(header, 0); err != nil {
+		return b, ErrFormat
+	}
+	if b.Chunk, b.Plain, err = Layout(header, n); err != nil {
+		return b, err
+	}
+	if err := tmp.Close(); err != nil {
+		return b, err
+	}
+	b.Size, b.Created = n, time.Now()
+	if err := os.Rename(tmp.Name(), s.path(b.ID, ".blob")); err != nil {
+		return b, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return b, s.save(b)
+}
+
+func (s *Store) save(b Blob) error {
+	return fsync.WriteJSON(s.path(b.ID, ".json"), b, 0600)
+}
+
+func (s *Store) load(id string) (Blob, error) {
+	var b Blob
+	if !validID(id) {
+		return b, ErrNoEntry
+	}
+	data, err := ioutil.ReadFile(s.path(id, ".json"))
+	if err != nil {
+		return b, ErrNoEntry
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return b, err
+	}
+	return b, nil
+}
+
+// Get 文件信息，已过期时返回ErrNoEntry
+func (s *Store) Get(id string) (Blob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.load(id)
+	if err == nil && b.Expired(time.Now()) {
+		return b, ErrNoEntry
+	}
+	return b, err
+}
+
+// OpenBlob 打开密文
+func (s *Store) OpenBlob(id string) (*os.File, Blob, error) {
+	b, err := s.Get(id)
+	if err != nil {
+		return nil, b, err
+	}
+	f, err := os.Open(s.path(id, ".blob"))
+	if err != nil {
+		return nil, b, ErrNoEntry
+	}
+	return f, b, nil
+}
+
+// Downloaded 记录一次完整下载
+func (s *Store) Downloaded(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.load(id)
+	if err != nil {
+		return err
+	}
+	b.Downloads++
+	return s.save(b)
+}
+
+// List 某设备上传的文件(owner为空时为全部)，按上传时间从新到旧排序，不含已过期的
+func (s *Store) List(owner string) ([]Blob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	list := []Blob{}
+	for _, name := range names {
+		b, err := s.load(strings.TrimSuffix(filepath.Base(name), ".json"))
+		if err != nil || b.Expired(now) || owner != "" && b.Owner != owner {
+			continue
+		}
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list, nil
+}
+
+// Delete 删除密文及其信息
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.load(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(id, ".blob")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(s.path(id, ".json"))
+}
+
+// Expire 删除已过期的文件，返回删除的个数
+func (s *Store) Expire(now time.Time) (int, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, name := range names {
+		id := strings.TrimSuffix(filepath.Base(name), ".json")
+		s.mu.Lock()
+		b, err := s.load(id)
+		s.mu.Unlock()
+		if err != nil || !b.Expired(now) {
+			continue
+		}
+		if err := s.Delete(id); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
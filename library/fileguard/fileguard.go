@@ -0,0 +1,181 @@
+package fileguard
+
+import (
+	"b0pass/library/bufpool"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 文件在传输过程中被修改时的处理策略
+const (
+	PolicyOff      = "off"      // 不检查
+	PolicyAbort    = "abort"    // 中断传输并报错
+	PolicySnapshot = "snapshot" // 先复制快照再传输快照
+)
+
+// ErrChanged 传输过程中文件被修改
+var ErrChanged = errors.New("file changed during transfer")
+
+// Stamp 文件状态，大小或修改时间变化即视为文件被修改
+type Stamp struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// StampOf 获取文件状态
+func StampOf(file string) (Stamp, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return Stamp{}, err
+	}
+	return Stamp{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Changed 文件当前状态是否与s不同(文件被删除也视为变化)
+func (s Stamp) Changed(file string) bool {
+	now, err := StampOf(file)
+	return err != nil || now.Size != s.Size || !now.ModTime.Equal(s.ModTime)
+}
+
+// Watcher 传输期间定期检查文件是否被修改
+type Watcher struct {
+	file     string
+	stamp    Stamp
+	onChange func()
+	stop     chan struct{}
+	once     sync.Once
+	mu       sync.Mutex
+	changed  bool
+}
+
+// Watch 开始监视文件，发现变化时调用一次onChange(可为nil)
+func Watch(file string, stamp Stamp, interval time.Duration, onChange func()) *Watcher {
+	w := &Watcher{file: file, stamp: stamp, onChange: onChange, stop: make(chan struct{})}
+	go w.loop(interval)
+	return w
+}
+
+func (w *Watcher) loop(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+			if w.check() {
+				return
+			}
+		}
+	}
+}
+
+// check 检查一次，返回是否已变化
+func (w *Watcher) check() bool {
+	w.mu.Lock()
+	if w.changed {
+		w.mu.Unlock()
+		return true
+	}
+	if !w.stamp.Changed(w.file) {
+		w.mu.Unlock()
+		return false
+	}
+	w.changed = true
+	w.mu.Unlock()
+	if w.onChange != nil {
+		w.onChange()
+	}
+	return true
+}
+
+// Stop 停止监视并做最后一次检查，返回传输期间文件是否被修改
+func (w *Watcher) Stop() bool {
+	w.once.Do(func() { close(w.stop) })
+	return w.check()
+}
+
+// Reader 读取时检查文件状态的只读文件，适合打包等逐个读取的场景
+// 每读取every字节检查一次，发现变化返回ErrChanged
+type Reader struct {
+	*os.File
+	stamp Stamp
+	every int64
+	read  int64
+}
+
+// Open 打开文件并记录当前状态
+func Open(file string, every int64) (*Reader, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &Reader{File: f, stamp: Stamp{Size: info.Size(), ModTime: info.ModTime()}, every: every}, nil
+}
+
+// Stamp 打开时的文件状态
+func (r *Reader) Stamp() Stamp {
+	return r.stamp
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.File.Read(p)
+	r.read += int64(n)
+	if r.every > 0 && r.read >= r.every || err == io.EOF {
+		r.read = 0
+		if r.stamp.Changed(r.File.Name()) {
+			return n, ErrChanged
+		}
+	}
+	return n, err
+}
+
+// WriteTo 屏蔽*os.File的WriteTo，io.Copy会优先使用它直接读取文件(sendfile等)而跳过检查
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, struct{ io.Reader }{r})
+}
+
+// Snapshot 将文件复制到dir下的独立目录(保留文件名)，返回快照路径与清理函数
+// 复制期间文件被修改时返回ErrChanged
+func Snapshot(file, dir string) (string, func(), error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", nil, err
+	}
+	tmp, err := ioutil.TempDir(dir, "snap")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(tmp) }
+	src, err := Open(file, 0)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	defer func() { _ = src.Close() }()
+	dst := filepath.Join(tmp, filepath.Base(file))
+	f, err := os.Create(dst)
+	if err == nil {
+		_, err = bufpool.Copy(f, src)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err == nil {
+		err = os.Chtimes(dst, src.stamp.ModTime, src.stamp.ModTime)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return dst, cleanup, nil
+}
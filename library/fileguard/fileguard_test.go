@@ -0,0 +1,90 @@
+package fileguard
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "fileguard")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "a.txt")
+	_ = ioutil.WriteFile(file, []byte("abc"), 0644)
+
+	stamp, err := StampOf(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := Watch(file, stamp, 10*time.Millisecond, nil)
+	if w.Stop() {
+		t.Error("unchanged file reported as changed")
+	}
+
+	notified := make(chan bool, 1)
+	w = Watch(file, stamp, 10*time.Millisecond, func() { notified <- true })
+	_ = ioutil.WriteFile(file, []byte("abcd"), 0644)
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		t.Error("change not detected")
+	}
+	if !w.Stop() {
+		t.Error("Stop should report change")
+	}
+}
+
+func TestReader(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "fileguard")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "a.txt")
+	_ = ioutil.WriteFile(file, []byte("hello world"), 0644)
+
+	r, err := Open(file, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	buf := make([]byte, 4)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	_ = ioutil.WriteFile(file, []byte("HELLO WORLD!"), 0644)
+	if _, err := ioutil.ReadAll(r); err != ErrChanged {
+		t.Errorf("want ErrChanged, got %v", err)
+	}
+
+	// io.Copy同样需要经过检查
+	r2, err := Open(file, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r2.Close() }()
+	_ = ioutil.WriteFile(file, []byte("changed again"), 0644)
+	if _, err := io.Copy(ioutil.Discard, r2); err != ErrChanged {
+		t.Errorf("io.Copy: want ErrChanged, got %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "fileguard")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "a.txt")
+	_ = ioutil.WriteFile(file, []byte("abc"), 0644)
+
+	snap, cleanup, err := Snapshot(file, filepath.Join(dir, "snap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ioutil.WriteFile(file, []byte("changed"), 0644)
+	if b, _ := ioutil.ReadFile(snap); string(b) != "abc" || filepath.Base(snap) != "a.txt" {
+		t.Errorf("bad snapshot %s %q", snap, b)
+	}
+	cleanup()
+	if _, err := os.Stat(snap); !os.IsNotExist(err) {
+		t.Error("snapshot not cleaned up")
+	}
+}
@@ -0,0 +1,539 @@
+package fileindex
+
+import (
+	"b0pass/library/search"
+	"database/sql"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 文件索引: 把共享目录下文件的大小、修改时间、校验值与文本内容摘录记录在SQLite数据库中，
+// 文本内容建立FTS4全文索引，由文件变化通知保持最新，用于全文搜索、重复文件检测与按收到时间查询。
+// SQLite驱动依赖cgo，CGO_ENABLED=0编译的程序打开索引时返回错误
+
+// schema 数据库结构，texts的docid为files的id，删除文件时由触发器同步删除
+const schema = `
+CREATE TABLE IF NOT EXISTS files (
+	id    INTEGER PRIMARY KEY,
+	path  TEXT NOT NULL UNIQUE,
+	name  TEXT NOT NULL,
+	size  INTEGER NOT NULL,
+	mtime INTEGER NOT NULL,
+	dir   INTEGER NOT NULL,
+	hash  TEXT NOT NULL,
+	text  TEXT NOT NULL,
+	added INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS files_hash ON files (hash, size);
+CREATE INDEX IF NOT EXISTS files_added ON files (added);
+CREATE VIRTUAL TABLE IF NOT EXISTS texts USING fts4 (name, body, tokenize=unicode61);
+CREATE TRIGGER IF NOT EXISTS files_delete AFTER DELETE ON files BEGIN
+	DELETE FROM texts WHERE docid = old.id;
+END;
+`
+
+// columns 读取索引项的字段，与scan的顺序一致
+const columns = `id, path, name, size, mtime, dir, hash, text, added`
+
+// File 索引项
+type File struct {
+	search.Entry
+	Hash  string    `json:"hash,omitempty"` // 校验值，目录与未计算时为空
+	Text  string    `json:"text,omitempty"` // 文本文件开头部分的内容(小写，空白合并为一个空格)
+	Added time.Time `json:"added"`          // 加入索引的时间，首次建立索引时为修改时间
+}
+
+// Options 索引选项
+type Options struct {
+	TextMax int64                                               // 摘录文本的最大字节数，为0时不摘录
+	Hash    func(file string, info os.FileInfo) (string, error) // 计算校验值，为nil时不计算
+}
+
+// root 索引的根目录，dir为解析符号链接后的绝对路径，与文件变化通知中的路径一致
+type root struct {
+	search.Root
+	dir string
+}
+
+// Index 文件索引
+type Index struct {
+	opt   Options
+	db    *sql.DB
+	roots []root
+
+	mu     sync.RWMutex
+	synced time.Time
+
+	pmu     sync.Mutex
+	pending map[string]time.Time // 等待更新的绝对路径及最近一次变化时间
+}
+
+// Open 打开索引数据库，不存在时创建，需调用Sync与磁盘同步
+func Open(file string, roots []search.Root, opt Options) (*Index, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", file+"?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	x := &Index{opt: opt, db: db, pending: make(map[string]time.Time)}
+	for _, r := range roots {
+		dir, err := filepath.Abs(r.Dir)
+		if err != nil {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = real
+		}
+		x.roots = append(x.roots, root{Root: search.Root{Prefix: r.Prefix, Dir: dir}, dir: dir})
+	}
+	return x, nil
+}
+
+// Close 关闭索引数据库
+func (x *Index) Close() error {
+	return x.db.Close()
+}
+
+// Sync 遍历各根目录，加入新文件、更新有变化的文件并移除已不存在的文件
+func (x *Index) Sync() error {
+	initial := x.Len() == 0
+	seen := make(map[string]bool)
+	for _, r := range x.roots {
+		var perr error
+		err := search.Each(r.Root, func(e search.Entry) bool {
+			seen[e.Path] = true
+			perr = x.put(e, filepath.Join(r.dir, filepath.FromSlash(strings.TrimPrefix(e.Path, r.Prefix))), initial)
+			return perr == nil
+		})
+		if err == nil {
+			err = perr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// 索引路径都以/开头，"0"是"/"之后的字符
+	if err := x.prune("", "0", seen); err != nil {
+		return err
+	}
+	x.mu.Lock()
+	x.synced = time.Now()
+	x.mu.Unlock()
+	return nil
+}
+
+// Notify 记录文件变化，由Flush在变化停止后更新，避免写入过程中反复计算校验值
+func (x *Index) Notify(file string) {
+	x.pmu.Lock()
+	x.pending[file] = time.Now()
+	x.pmu.Unlock()
+}
+
+// Flush 更新已超过quiet没有变化的文件，返回更新的数量与遇到的第一个错误
+func (x *Index) Flush(quiet time.Duration) (int, error) {
+	now := time.Now()
+	var files []string
+	x.pmu.Lock()
+	for f, t := range x.pending {
+		if now.Sub(t) >= quiet {
+			files = append(files, f)
+			delete(x.pending, f)
+		}
+	}
+	x.pmu.Unlock()
+	sort.Strings(files)
+	var first error
+	for _, f := range files {
+		if err := x.Update(f); err != nil && first == nil {
+			first = err
+		}
+	}
+	return len(files), first
+}
+
+// Update 按磁盘上的状态更新一个文件或目录(含其下全部文件)，已不存在时从索引中移除
+func (x *Index) Update(file string) error {
+	r, p, ok := x.locate(file)
+	if !ok {
+		return nil
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		_, err := x.db.Exec(`DELETE FROM files WHERE path = ? OR (path > ? AND path < ?)`, p, p+"/", p+"0")
+		return err
+	}
+	if err := x.put(entry(p, info), file, false); err != nil {
+		return err
+	}
+	// 新建目录的通知可能晚于其中文件的通知
+	for dir, dp := filepath.Dir(file), path.Dir(p); dir != r.dir && dp != r.Prefix && dp != "/"; dir, dp = filepath.Dir(dir), path.Dir(dp) {
+		if _, ok := x.Get(dp); ok {
+			break
+		}
+		if di, err := os.Stat(dir); err == nil {
+			if err := x.put(entry(dp, di), dir, false); err != nil {
+				return err
+			}
+		}
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var perr error
+	err = search.Each(search.Root{Prefix: p, Dir: file}, func(e search.Entry) bool {
+		seen[e.Path] = true
+		perr = x.put(e, filepath.Join(r.dir, filepath.FromSlash(strings.TrimPrefix(e.Path, r.Prefix))), false)
+		return perr == nil
+	})
+	if err == nil {
+		err = perr
+	}
+	if err != nil {
+		return err
+	}
+	return x.prune(p+"/", p+"0", seen)
+}
+
+// prune 移除路径在lo与hi之间且不在seen中的索引项
+func (x *Index) prune(lo, hi string, seen map[string]bool) error {
+	rows, err := x.db.Query(`SELECT path FROM files WHERE path > ? AND path < ?`, lo, hi)
+	if err != nil {
+		return err
+	}
+	var gone []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if !seen[p] {
+			gone = append(gone, p)
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, p := range gone {
+		if _, err := x.db.Exec(`DELETE FROM files WHERE path = ?`, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// locate 绝对路径所在的根目录及索引路径，隐藏文件与目录不索引
+func (x *Index) locate(file string) (root, string, bool) {
+	for _, r := range x.roots {
+		rel, err := filepath.Rel(r.dir, file)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, name := range strings.Split(rel, "/") {
+			if strings.HasPrefix(name, ".") {
+				return r, "", false
+			}
+		}
+		return r, r.Prefix + "/" + rel, true
+	}
+	return root{}, "", false
+}
+
+// entry 由文件信息生成搜索结果项
+func entry(p string, info os.FileInfo) search.Entry {
+	e := search.Entry{Path: p, Name: info.Name(), MTime: info.ModTime().Unix(), Dir: info.IsDir()}
+	if !e.Dir {
+		e.Size = info.Size()
+	}
+	return e
+}
+
+// put 写入索引项，文件未变化时保留已有的校验值与文本
+func (x *Index) put(e search.Entry, file string, initial bool) error {
+	old, ok := x.Get(e.Path)
+	if ok && old.Entry == e {
+		return nil
+	}
+	f := File{Entry: e, Added: time.Now()}
+	if ok {
+		f.Added = old.Added
+	} else if initial {
+		f.Added = time.Unix(e.MTime, 0)
+	}
+	if !e.Dir {
+		if x.opt.Hash != nil {
+			if info, err := os.Stat(file); err == nil {
+				f.Hash, _ = x.opt.Hash(file, info)
+			}
+		}
+		if x.opt.TextMax > 0 {
+			f.Text = extract(file, x.opt.TextMax)
+		}
+	}
+	return x.write(f)
+}
+
+// write 在一个事务中写入索引项及其全文索引
+func (x *Index) write(f File) error {
+	tx, err := x.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	var id int64
+	err = tx.QueryRow(`INSERT INTO files (path, name, size, mtime, dir, hash, text, added) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET name = excluded.name, size = excluded.size, mtime = excluded.mtime,
+		dir = excluded.dir, hash = excluded.hash, text = excluded.text, added = excluded.added
+		RETURNING id`, f.Path, f.Name, f.Size, f.MTime, f.Dir, f.Hash, f.Text, f.Added.UnixNano()).Scan(&id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM texts WHERE docid = ?`, id); err != nil {
+		return err
+	}
+	if f.Text != "" {
+		if _, err := tx.Exec(`INSERT INTO texts (docid, name, body) VALUES (?, ?, ?)`, id, spaced(strings.ToLower(f.Name)), spaced(f.Text)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// scanner sql.Row与sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan 按columns的顺序读取一个索引项
+func scan(s scanner) (int64, File, error) {
+	var (
+		id    int64
+		f     File
+		added int64
+	)
+	err := s.Scan(&id, &f.Path, &f.Name, &f.Size, &f.MTime, &f.Dir, &f.Hash, &f.Text, &added)
+	f.Added = time.Unix(0, added)
+	return id, f, err
+}
+
+// query 按顺序对查询到的索引项调用fn，fn返回false时停止
+func (x *Index) query(fn func(id int64, f File) bool, where string, args ...interface{}) error {
+	rows, err := x.db.Query(`SELECT `+columns+` FROM files `+where, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		id, f, err := scan(rows)
+		if err != nil {
+			return err
+		}
+		if !fn(id, f) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+// extract 读取文本文件开头max字节，非文本文件返回空
+func extract(file string, max int64) string {
+	fd, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = fd.Close() }()
+	b, err := ioutil.ReadAll(io.LimitReader(fd, max))
+	if err != nil || len(b) == 0 {
+		return ""
+	}
+	// 截断位置可能在多字节字符中间
+	for i := 0; i < utf8.UTFMax && len(b) > 0 && !utf8.Valid(b); i++ {
+		b = b[:len(b)-1]
+	}
+	if !utf8.Valid(b) || !strings.HasPrefix(http.DetectContentType(b), "text/") {
+		return ""
+	}
+	return strings.Join(strings.Fields(strings.ToLower(string(b))), " ")
+}
+
+// cjk 中日韩文字，全文索引按字切分
+func cjk(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// spaced 在中日韩文字两侧加空格，使全文索引按字而不是按连续的一段文字切分
+func spaced(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if cjk(r) {
+			b.WriteByte(' ')
+			b.WriteRune(r)
+			b.WriteByte(' ')
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// match 由关键字生成全文搜索条件，每个关键字为一个短语且最后一个词按前缀匹配，关键字之间为AND；
+// 有关键字不含字母与数字时返回空
+func match(terms []string) string {
+	var list []string
+	for _, t := range terms {
+		words := strings.FieldsFunc(spaced(strings.ToLower(t)), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if len(words) == 0 {
+			return ""
+		}
+		list = append(list, `"`+strings.Join(words, " ")+`*"`)
+	}
+	return strings.Join(list, " ")
+}
+
+// Search 查找名称符合条件的文件，text为true时关键字也可出现在文本内容中(按词匹配，中日韩文字按字匹配)；
+// 按路径顺序对匹配的项调用fn，fn返回false时停止
+func (x *Index) Search(q search.Query, text bool, fn func(File) bool) error {
+	hits := make(map[int64]bool)
+	if m := match(q.Terms); text && m != "" {
+		rows, err := x.db.Query(`SELECT docid FROM texts WHERE texts MATCH ?`, m)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			hits[id] = true
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	exts := search.Query{Exts: q.Exts}
+	return x.query(func(id int64, f File) bool {
+		if !q.Match(f.Name, f.Dir) && !(hits[id] && exts.Match(f.Name, f.Dir)) {
+			return true
+		}
+		return fn(f)
+	}, `ORDER BY path`)
+}
+
+// Duplicates 内容相同(校验值与大小一致)且不小于minSize的文件分组，按可节省的空间从大到小排序
+func (x *Index) Duplicates(minSize int64) ([][]File, error) {
+	groups := make(map[string][]File)
+	var keys []string
+	err := x.query(func(_ int64, f File) bool {
+		key := f.Hash + "/" + strconv.FormatInt(f.Size, 10)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], f)
+		return true
+	}, `WHERE dir = 0 AND hash <> '' AND size >= ? AND (hash, size) IN (
+		SELECT hash, size FROM files WHERE dir = 0 AND hash <> '' AND size >= ? GROUP BY hash, size HAVING count(*) > 1
+	) ORDER BY path`, minSize, minSize)
+	if err != nil {
+		return nil, err
+	}
+	dups := make([][]File, 0, len(keys))
+	for _, k := range keys {
+		dups = append(dups, groups[k])
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		wi, wj := dups[i][0].Size*int64(len(dups[i])-1), dups[j][0].Size*int64(len(dups[j])-1)
+		if wi != wj {
+			return wi > wj
+		}
+		return dups[i][0].Path < dups[j][0].Path
+	})
+	return dups, nil
+}
+
+// Added from~to(为零值时不限)之间加入索引的文件(不含目录)，按加入时间排序
+func (x *Index) Added(from, to time.Time) ([]File, error) {
+	where, args := `WHERE dir = 0`, []interface{}{}
+	if !from.IsZero() {
+		where += ` AND added >= ?`
+		args = append(args, from.UnixNano())
+	}
+	if !to.IsZero() {
+		where += ` AND added <= ?`
+		args = append(args, to.UnixNano())
+	}
+	var list []File
+	err := x.query(func(_ int64, f File) bool {
+		list = append(list, f)
+		return true
+	}, where+` ORDER BY added, path`, args...)
+	return list, err
+}
+
+// Get 读取一个索引项，path为索引路径
+func (x *Index) Get(path string) (File, bool) {
+	_, f, err := scan(x.db.QueryRow(`SELECT `+columns+` FROM files WHERE path = ?`, path))
+	if err != nil {
+		return File{}, false
+	}
+	return f, true
+}
+
+// DirSize 索引中目录(绝对路径)下文件的总大小与数量，目录不在索引的根目录下或尚未建立索引时ok为false
+func (x *Index) DirSize(dir string) (size int64, files int, ok bool) {
+	_, p, ok := x.locate(dir)
+	if !ok {
+		for _, r := range x.roots {
+			if filepath.Clean(dir) == r.dir {
+				p, ok = r.Prefix, true
+			}
+		}
+	}
+	if !ok || x.Len() == 0 {
+		return 0, 0, false
+	}
+	err := x.db.QueryRow(`SELECT coalesce(sum(size), 0), count(*) FROM files WHERE dir = 0 AND path > ? AND path < ?`, p+"/", p+"0").Scan(&size, &files)
+	if err != nil {
+		return 0, 0, false
+	}
+	return size, files, true
+}
+
+// Len 索引中的项数
+func (x *Index) Len() int {
+	var n int
+	_ = x.db.QueryRow(`SELECT count(*) FROM files`).Scan(&n)
+	return n
+}
+
+// Synced 最近一次完成Sync的时间，尚未完成时为零值
+func (x *Index) Synced() time.Time {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.synced
+}
@@ -0,0 +1,161 @@
+package fileindex
+
+import (
+	"b0pass/library/search"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sha(file string, info os.FileInfo) (string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	write := func(rel, content string) string {
+		p := filepath.Join(dir, "files", filepath.FromSlash(rel))
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	write("notes/plan.md", "Quarterly  BUDGET\nreview 预算")
+	write("a/copy1.bin", strings.Repeat("x", 100))
+	write("b/copy2.bin", strings.Repeat("x", 100))
+	write(".b0pass-tmp/up.part", "budget")
+	old := time.Now().Add(-48 * time.Hour)
+	_ = os.Chtimes(filepath.Join(dir, "files", "notes", "plan.md"), old, old)
+
+	db := filepath.Join(dir, "index.db")
+	roots := []search.Root{{Dir: filepath.Join(dir, "files")}}
+	x, err := Open(db, roots, Options{TextMax: 1024, Hash: sha})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if n := x.Len(); n != 6 {
+		t.Fatal("len", n)
+	}
+	names := func(q string, text bool) string {
+		var list []string
+		if err := x.Search(search.Parse(q), text, func(f File) bool {
+			list = append(list, f.Path)
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(list, ",")
+	}
+	if got := names("budget", false); got != "" {
+		t.Error("name only", got)
+	}
+	if got := names("budget 预算", true); got != "/notes/plan.md" {
+		t.Error("text", got)
+	}
+	if got := names("budget ext:txt", true); got != "" {
+		t.Error("ext", got)
+	}
+	if got := names("quarterly budget", true); got != "/notes/plan.md" {
+		t.Error("whitespace", got)
+	}
+	// 英文按词的开头匹配，中文按字匹配
+	if got := names("quart 算", true); got != "/notes/plan.md" {
+		t.Error("prefix", got)
+	}
+	if got := names("udget", true); got != "" {
+		t.Error("infix", got)
+	}
+	if got := names("plan budget", true); got != "/notes/plan.md" {
+		t.Error("name and text", got)
+	}
+	dups, err := x.Duplicates(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dups) != 1 || len(dups[0]) != 2 || dups[0][0].Path != "/a/copy1.bin" {
+		t.Errorf("dups %+v", dups)
+	}
+	// 首次建立索引时加入时间为修改时间
+	if f, _ := x.Get("/notes/plan.md"); f.Added.Unix() != old.Unix() {
+		t.Error("added", f.Added)
+	}
+
+	// 变化通知: 新文件、修改与删除在Flush后生效
+	start := time.Now()
+	x.Notify(write("c/new.txt", "hello world"))
+	p := write("b/copy2.bin", "changed")
+	x.Notify(p)
+	if n, _ := x.Flush(time.Hour); n != 0 {
+		t.Error("not quiet", n)
+	}
+	if n, err := x.Flush(0); n != 2 || err != nil {
+		t.Error("flush", n, err)
+	}
+	if dups, _ := x.Duplicates(1); len(dups) != 0 {
+		t.Error("dups after change")
+	}
+	if added, _ := x.Added(start, time.Time{}); len(added) != 1 || added[0].Path != "/c/new.txt" {
+		t.Errorf("added %+v", added)
+	}
+	_ = os.RemoveAll(filepath.Join(dir, "files", "a"))
+	x.Notify(filepath.Join(dir, "files", "a"))
+	x.Notify(filepath.Join(dir, "files", ".b0pass-tmp", "up.part"))
+	if _, err := x.Flush(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := x.Get("/a/copy1.bin"); ok {
+		t.Error("removed")
+	}
+
+	if size, files, ok := x.DirSize(filepath.Join(dir, "files", "b")); !ok || size != 7 || files != 1 {
+		t.Error("dir size", size, files, ok)
+	}
+	plan := int64(len("Quarterly  BUDGET\nreview 预算"))
+	if size, files, ok := x.DirSize(filepath.Join(dir, "files")); !ok || size != plan+7+11 || files != 3 {
+		t.Error("root size", size, files, ok)
+	}
+	if _, _, ok := x.DirSize(dir); ok {
+		t.Error("outside root")
+	}
+
+	// 重新打开后保留索引，未变化的文件不重新计算
+	n := x.Len()
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+	y, err := Open(db, roots, Options{Hash: func(string, os.FileInfo) (string, error) {
+		t.Error("rehash")
+		return "", nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = y.Close() }()
+	if err := y.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := y.Get("/c/new.txt"); y.Len() != n || !ok || f.Text != "hello world" {
+		t.Error("reopen", y.Len(), n)
+	}
+	if f, _ := y.Get("/notes/plan.md"); f.Text == "" || f.Hash == "" {
+		t.Errorf("reopen %+v", f)
+	}
+}
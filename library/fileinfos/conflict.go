@@ -0,0 +1,57 @@
+package fileinfos
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 上传的文件名已存在时的处理方式
+const (
+	ConflictOverwrite = "overwrite" // 覆盖已有文件
+	ConflictSkip      = "skip"      // 保留已有文件，丢弃上传的内容
+	ConflictRename    = "rename"    // 自动重命名，如 photo (1).jpg
+	ConflictFail      = "fail"      // 报错，由客户端决定
+)
+
+// Conflicts 全部处理方式
+var Conflicts = []string{ConflictOverwrite, ConflictSkip, ConflictRename, ConflictFail}
+
+var (
+	// ErrSkipped 同名文件已存在，按skip跳过
+	ErrSkipped = errors.New("file exists, skipped")
+	// ErrExist 同名文件已存在，按fail报错
+	ErrExist = errors.New("file already exists")
+)
+
+// ParseConflict 解析处理方式(不区分大小写)，为空时返回def
+func ParseConflict(s, def string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		s = def
+	}
+	for _, c := range Conflicts {
+		if s == c {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("invalid conflict %q, expect one of %s", s, strings.Join(Conflicts, ", "))
+}
+
+// ResolveConflict 按处理方式决定写入file的最终路径: 不存在或覆盖时为file，重命名时为新的路径，
+// 跳过时返回ErrSkipped、报错时返回ErrExist(路径均为已有的file)
+func ResolveConflict(file, policy string) (string, error) {
+	if _, err := os.Lstat(file); os.IsNotExist(err) {
+		return file, nil
+	}
+	switch policy {
+	case ConflictSkip:
+		return file, ErrSkipped
+	case ConflictRename:
+		return UniqueName(file), nil
+	case ConflictFail:
+		return file, ErrExist
+	}
+	return file, nil
+}
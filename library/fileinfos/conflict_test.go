@@ -0,0 +1,45 @@
+package fileinfos
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveConflict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conflict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "photo.jpg")
+	for _, c := range Conflicts {
+		if got, err := ResolveConflict(file, c); got != file || err != nil {
+			t.Errorf("%s new file: %s %v", c, got, err)
+		}
+	}
+	_ = ioutil.WriteFile(file, []byte("x"), 0644)
+	if got, err := ResolveConflict(file, ConflictOverwrite); got != file || err != nil {
+		t.Error("overwrite", got, err)
+	}
+	if got, err := ResolveConflict(file, ConflictRename); got != filepath.Join(dir, "photo (1).jpg") || err != nil {
+		t.Error("rename", got, err)
+	}
+	if _, err := ResolveConflict(file, ConflictSkip); err != ErrSkipped {
+		t.Error("skip", err)
+	}
+	if _, err := ResolveConflict(file, ConflictFail); err != ErrExist {
+		t.Error("fail", err)
+	}
+
+	if c, err := ParseConflict(" Skip ", ConflictRename); c != ConflictSkip || err != nil {
+		t.Error(c, err)
+	}
+	if c, _ := ParseConflict("", ConflictRename); c != ConflictRename {
+		t.Error("default", c)
+	}
+	if _, err := ParseConflict("replace", ConflictRename); err == nil {
+		t.Error("invalid")
+	}
+}
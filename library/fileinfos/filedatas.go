@@ -1,14 +1,12 @@
 package fileinfos
 
 import (
-	"fmt"
 	"github.com/gogf/gf/os/gcache"
 	"github.com/gogf/gf/os/gfile"
 )
 
 // DataInit 从文件恢复为缓存
 func Init(keys... string){
-	fmt.Println(keys)
 	for _,key:=range keys{
 		data:=gfile.GetContents(cacheFile(key))
 		gcache.Set(key,data,0)
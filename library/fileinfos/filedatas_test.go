@@ -6,7 +6,7 @@ import (
 )
 
 func TestDataInit(t *testing.T) {
-	DataSet("data_path","appsss")
-	DataInit("data_path","data_text")
-	log.Println(DataGet("data_path"))
+	Set("data_path","appsss")
+	Init("data_path","data_text")
+	log.Println(Get("data_path"))
 }
\ No newline at end of file
@@ -1,6 +1,8 @@
 package fileinfos
 
 import (
+	"b0pass/library/hidden"
+	"b0pass/library/metadata"
 	"fmt"
 	"log"
 	"os"
@@ -44,6 +46,12 @@ func GetRootPath() string {
 	return fp
 }
 
+// FileKey 文件在共享目录下的相对路径，用作元数据key
+func FileKey(file string) string {
+	rel, _ := Roots().Rel(file)
+	return rel
+}
+
 // 根据文件名判断是否是图片
 func IfImage(f string) bool {
 	var imgs = []string{".png", ".jpg", ".jpeg", ".gif", ".bmp", ".ico"}
@@ -90,45 +98,64 @@ func GetSize(fileBytes uint64) string {
 }
 
 // List Dir Data
-func ListDirData(fp,fpSub string) []map[string]string {
+// rules为隐藏规则，匹配共享目录下的相对路径
+func ListDirData(fp,fpSub string, rules hidden.Rules) []map[string]string {
 	files, _ := filepath.Glob(fp)
 	var ret []map[string]string
 	var indexs=0
 	for _, file := range files {
-		fileInfo, _ := os.Stat(file)
-		//filename
-		mfile := filepath.Base(file)
-		if string(mfile[0]) == "." {
+		if rules.Hidden(FileKey(file)) {
 			continue
 		}
-		//filetype
-		mtype := "file"
-		if IfImage(mfile) {
-			mtype = "img"
-		}
-		//fileext
-		mext := strings.ToUpper(path.Ext(mfile))
-		if fileInfo.IsDir() {
-			mext = "dir"
-			mtype = "dir"
+		// 按符号链接与挂载点策略过滤
+		fileInfo, err := os.Lstat(file)
+		if err != nil {
+			continue
 		}
-		if mext==""{
-			mext = "file"
-			mtype= "file"
+		fileInfo, link, ok := Roots().Entry(filepath.Dir(file), fileInfo, policy)
+		if !ok {
+			continue
 		}
-		//index
 		indexs++
-		//map
-		m := make(map[string]string)
-		m["name"] = mfile
-		m["ext"] = mext
-		m["size"] = strconv.Itoa(int(fileInfo.Size()))
-		m["sizes"] = GetSize(uint64(fileInfo.Size()))
-		m["date"] = fileInfo.ModTime().Format("01-02")
-		m["path"] = fpSub+"/"+ mfile
-		m["type"] = mtype
-		m["indexs"]=strconv.Itoa(indexs)
+		m := dirEntry(file, fileInfo, link, fpSub)
+		m["indexs"] = strconv.Itoa(indexs)
 		ret = append(ret, m)
 	}
 	return ret
 }
+
+// dirEntry 列表中的一项(不含indexs)，file为目录项的路径，info按符号链接策略处理过，link为列为链接时的目标
+func dirEntry(file string, info os.FileInfo, link, fpSub string) map[string]string {
+	mfile := filepath.Base(file)
+	mtype := "file"
+	if IfImage(mfile) {
+		mtype = "img"
+	}
+	mext := strings.ToUpper(path.Ext(mfile))
+	if info.IsDir() {
+		mext = "dir"
+		mtype = "dir"
+	}
+	if mext == "" {
+		mext = "file"
+		mtype = "file"
+	}
+	m := make(map[string]string)
+	m["name"] = mfile
+	m["ext"] = mext
+	m["size"] = strconv.Itoa(int(info.Size()))
+	m["sizes"] = GetSize(uint64(info.Size()))
+	m["date"] = info.ModTime().Format("01-02")
+	m["mtime"] = strconv.FormatInt(info.ModTime().Unix(), 10)
+	m["path"] = fpSub + "/" + mfile
+	m["type"] = mtype
+	m["scan"] = metadata.Get(FileKey(file), "scan")
+	if link != "" {
+		m["link"] = "/" + FileKey(link)
+	}
+	if mtype == "dir" {
+		setStyle(m, file)
+		setDirSize(m, file)
+	}
+	return m
+}
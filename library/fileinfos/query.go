@@ -0,0 +1,141 @@
+package fileinfos
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sorts 列表支持的排序字段
+var Sorts = []string{"name", "size", "mtime"}
+
+// Query 列表的过滤、排序与分页条件
+type Query struct {
+	Filter string // 名称包含的文字(不区分大小写)，含*?[时按通配符匹配，如 *.jpg
+	Sort   string // name、size或mtime，为空时保持原顺序；排序时目录在前
+	Desc   bool
+	Page   int // 页码，从1开始
+	Limit  int // 每页数量，0为不分页
+}
+
+// ValidSort 是否为支持的排序字段
+func ValidSort(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, v := range Sorts {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply 对列表过滤、排序后取出一页，返回该页与过滤后的总数，indexs按在全部结果中的位置重新编号
+func (q Query) Apply(list []map[string]string) ([]map[string]string, int) {
+	if q.Filter != "" {
+		match := q.matcher()
+		kept := make([]map[string]string, 0, len(list))
+		for _, m := range list {
+			if match(m["name"]) {
+				kept = append(kept, m)
+			}
+		}
+		list = kept
+	}
+	if q.Sort != "" {
+		sort.SliceStable(list, func(i, j int) bool {
+			a, b := list[i], list[j]
+			if da, db := a["type"] == "dir", b["type"] == "dir"; da != db {
+				return da
+			}
+			c := compare(a, b, q.Sort)
+			if c == 0 && q.Sort != "name" {
+				c = compare(a, b, "name")
+			}
+			if q.Desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	total := len(list)
+	offset := 0
+	if q.Limit > 0 {
+		page := q.Page
+		if page < 1 {
+			page = 1
+		}
+		offset = (page - 1) * q.Limit
+		if offset > total {
+			offset = total
+		}
+		end := offset + q.Limit
+		if end > total {
+			end = total
+		}
+		list = list[offset:end]
+	}
+	for i, m := range list {
+		m["indexs"] = strconv.Itoa(offset + i + 1)
+	}
+	return list, total
+}
+
+// Each 不排序时按原顺序逐项过滤与分页，不必先构造整个列表: each逐项生成列表项(如EachPath)，
+// 当前页的项按在全部结果中的位置重新编号indexs后调用fn，fn返回false时停止；返回过滤后的总数
+func (q Query) Each(each func(fn func(map[string]string) bool) error, fn func(map[string]string) bool) (int, error) {
+	match := q.matcher()
+	offset := 0
+	if q.Limit > 0 && q.Page > 1 {
+		offset = (q.Page - 1) * q.Limit
+	}
+	total := 0
+	err := each(func(m map[string]string) bool {
+		if !match(m["name"]) {
+			return true
+		}
+		total++
+		if total <= offset || q.Limit > 0 && total > offset+q.Limit {
+			return true
+		}
+		m["indexs"] = strconv.Itoa(total)
+		return fn(m)
+	})
+	return total, err
+}
+
+// matcher 名称过滤函数
+func (q Query) matcher() func(name string) bool {
+	filter := strings.ToLower(q.Filter)
+	if strings.ContainsAny(filter, "*?[") {
+		return func(name string) bool {
+			ok, _ := path.Match(filter, strings.ToLower(name))
+			return ok
+		}
+	}
+	return func(name string) bool {
+		return strings.Contains(strings.ToLower(name), filter)
+	}
+}
+
+// compare 按字段比较两个列表项，name不区分大小写
+func compare(a, b map[string]string, field string) int {
+	if field == "name" {
+		x, y := strings.ToLower(a["name"]), strings.ToLower(b["name"])
+		if x == y {
+			return strings.Compare(a["name"], b["name"])
+		}
+		return strings.Compare(x, y)
+	}
+	x, _ := strconv.ParseInt(a[field], 10, 64)
+	y, _ := strconv.ParseInt(b[field], 10, 64)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
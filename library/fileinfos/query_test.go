@@ -0,0 +1,92 @@
+package fileinfos
+
+import (
+	"strings"
+	"testing"
+)
+
+func queryList() []map[string]string {
+	return []map[string]string{
+		{"name": "b.jpg", "type": "img", "size": "300", "mtime": "10"},
+		{"name": "A.txt", "type": "file", "size": "100", "mtime": "30"},
+		{"name": "docs", "type": "dir", "size": "0", "mtime": "20"},
+		{"name": "c.JPG", "type": "img", "size": "200", "mtime": "40"},
+	}
+}
+
+func names(list []map[string]string) string {
+	var s []string
+	for _, m := range list {
+		s = append(s, m["name"])
+	}
+	return strings.Join(s, ",")
+}
+
+func TestQueryApply(t *testing.T) {
+	cases := []struct {
+		q     Query
+		want  string
+		total int
+	}{
+		{Query{}, "b.jpg,A.txt,docs,c.JPG", 4},
+		{Query{Sort: "name"}, "docs,A.txt,b.jpg,c.JPG", 4},
+		{Query{Sort: "size", Desc: true}, "docs,b.jpg,c.JPG,A.txt", 4},
+		{Query{Sort: "mtime"}, "docs,b.jpg,A.txt,c.JPG", 4},
+		{Query{Filter: "*.jpg", Sort: "name"}, "b.jpg,c.JPG", 2},
+		{Query{Filter: "T"}, "A.txt", 1},
+		{Query{Sort: "name", Page: 2, Limit: 3}, "c.JPG", 4},
+		{Query{Sort: "name", Page: 9, Limit: 3}, "", 4},
+	}
+	for i, c := range cases {
+		list, total := c.q.Apply(queryList())
+		if got := names(list); got != c.want || total != c.total {
+			t.Errorf("case %d: %s (%d), want %s (%d)", i, got, total, c.want, c.total)
+		}
+	}
+	list, _ := Query{Sort: "name", Page: 2, Limit: 2}.Apply(queryList())
+	if list[0]["indexs"] != "3" {
+		t.Error(list[0]["indexs"])
+	}
+	if !ValidSort("mtime") || ValidSort("owner") {
+		t.Error("ValidSort")
+	}
+}
+
+func TestQueryEach(t *testing.T) {
+	each := func(fn func(map[string]string) bool) error {
+		for _, m := range queryList() {
+			if !fn(m) {
+				break
+			}
+		}
+		return nil
+	}
+	cases := []struct {
+		q     Query
+		want  string
+		total int
+	}{
+		{Query{}, "b.jpg,A.txt,docs,c.JPG", 4},
+		{Query{Filter: "*.jpg"}, "b.jpg,c.JPG", 2},
+		{Query{Page: 2, Limit: 3}, "c.JPG", 4},
+		{Query{Page: 9, Limit: 3}, "", 4},
+	}
+	for i, c := range cases {
+		var list []map[string]string
+		total, err := c.q.Each(each, func(m map[string]string) bool {
+			list = append(list, m)
+			return true
+		})
+		if got := names(list); err != nil || got != c.want || total != c.total {
+			t.Errorf("case %d: %s (%d), want %s (%d)", i, got, total, c.want, c.total)
+		}
+	}
+	var indexs []string
+	_, _ = Query{Filter: "*.jpg", Page: 2, Limit: 1}.Each(each, func(m map[string]string) bool {
+		indexs = append(indexs, m["indexs"])
+		return true
+	})
+	if strings.Join(indexs, ",") != "2" {
+		t.Error(indexs)
+	}
+}
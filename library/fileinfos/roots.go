@@ -0,0 +1,286 @@
+package fileinfos
+
+import (
+	"b0pass/library/hidden"
+	"b0pass/library/humanize"
+	"b0pass/library/metadata"
+	"b0pass/library/roots"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// rootSet 共享根目录，未配置时为程序目录下的files
+var rootSet roots.Set
+
+// policy 符号链接与挂载点策略
+var policy = roots.Policy{Links: roots.LinksFollow, Mounts: true}
+
+// SetRoots 设置共享根目录
+func SetRoots(s roots.Set) {
+	rootSet = s
+}
+
+// SetPolicy 设置符号链接与挂载点策略
+func SetPolicy(p roots.Policy) {
+	policy = p
+}
+
+// Roots 共享根目录列表
+func Roots() roots.Set {
+	if len(rootSet) == 0 {
+		return roots.Set{{Name: "files", Path: GetRootPath() + "/files"}}
+	}
+	return rootSet
+}
+
+// FilesRoot 默认(第一个)共享根目录，用于暂存目录等只需一个位置的场合
+func FilesRoot() string {
+	return Roots()[0].Path
+}
+
+// FilePath 共享目录下的相对路径(/files之后的部分)转为文件路径
+// 多根目录时首段为根目录名，顶层虚拟目录或根目录不存在时返回空；
+// 按策略不能经过的符号链接、指向根目录之外的链接及未共享的挂载点同样返回空
+func FilePath(rel string) string {
+	file, _ := Roots().Resolve(rel)
+	if file != "" && Roots().Check(file, policy) != nil {
+		return ""
+	}
+	return file
+}
+
+// Allowed 文件路径是否可按符号链接与挂载点策略访问，用于WebDAV、FTP等直接使用文件路径的服务
+func Allowed(file string) bool {
+	return Roots().Check(file, policy) == nil
+}
+
+// LinkOf 列表项(ReadDir、Dir.Next)为符号链接且策略为列为链接时，链接目标的文件路径
+func LinkOf(info os.FileInfo) string {
+	if l, ok := info.(linkInfo); ok {
+		return l.link
+	}
+	return ""
+}
+
+// linkInfo 列为链接的目录项
+type linkInfo struct {
+	os.FileInfo
+	link string
+}
+
+// filterDir 按符号链接与挂载点策略过滤目录dir的目录项(Lstat的结果)
+func filterDir(dir string, infos []os.FileInfo) []os.FileInfo {
+	ret := infos[:0]
+	for _, info := range infos {
+		shown, link, ok := Roots().Entry(dir, info, policy)
+		if !ok {
+			continue
+		}
+		if link != "" {
+			shown = linkInfo{shown, link}
+		}
+		ret = append(ret, shown)
+	}
+	return ret
+}
+
+// FileURL 文件的下载路径(/files/...)，用于审计日志等，不在共享目录下时返回原路径
+func FileURL(file string) string {
+	if rel, ok := Roots().Rel(file); ok {
+		return path.Clean("/files/" + rel)
+	}
+	return file
+}
+
+// RootOf 文件所在的共享根目录，不在任何根目录下时返回默认根目录
+func RootOf(file string) string {
+	if r, ok := Roots().Of(file); ok {
+		return r.Path
+	}
+	return FilesRoot()
+}
+
+// StyleFields 目录的显示样式(图标、颜色、说明)，保存在元数据中
+var StyleFields = []string{"icon", "color", "desc"}
+
+// setStyle 将目录的显示样式写入列表项，元数据中没有时根目录取配置的值
+func setStyle(m map[string]string, file string) {
+	defaults := map[string]string{}
+	if r, ok := Roots().Of(file); ok && r.Path == filepath.Clean(file) {
+		defaults = map[string]string{"icon": r.Icon, "color": r.Color, "desc": r.Desc}
+	}
+	fields := metadata.Fields(FileKey(file))
+	for _, f := range StyleFields {
+		if m[f] = fields[f]; m[f] == "" {
+			m[f] = defaults[f]
+		}
+	}
+}
+
+// DirSize 目录下文件的总大小、数量及是否为最终值(否则为估算值)，为nil时目录大小为0
+var DirSize func(dir string) (int64, int, bool)
+
+// setDirSize 将目录大小写入列表项
+func setDirSize(m map[string]string, dir string) {
+	if DirSize == nil {
+		return
+	}
+	size, files, complete := DirSize(dir)
+	m["size"] = strconv.FormatInt(size, 10)
+	m["sizes"] = GetSize(uint64(size))
+	m["files"] = strconv.Itoa(files)
+	m["complete"] = strconv.FormatBool(complete)
+}
+
+// ReadDir 读取共享目录下的相对目录，多根目录的顶层返回各根目录(无法访问的根目录跳过)
+func ReadDir(rel string) ([]os.FileInfo, error) {
+	if s := Roots(); s.IsTop(rel) {
+		return s.List(), nil
+	}
+	dir := FilePath(rel)
+	if dir == "" {
+		return nil, os.ErrNotExist
+	}
+	infos, err := ioutil.ReadDir(dir)
+	return filterDir(dir, infos), err
+}
+
+// dirBatch 每次读取的目录项数
+const dirBatch = 256
+
+// Dir 按名称顺序分批读取的目录，上万个目录项时只在内存中保存名称，目录项的信息每次读取一批
+type Dir struct {
+	dir   string
+	names []string      // 排序后尚未读取的名称
+	infos []os.FileInfo // 多根目录的顶层
+}
+
+// OpenDir 打开共享目录下的相对目录，多根目录的顶层为各根目录
+func OpenDir(rel string) (*Dir, error) {
+	if Roots().IsTop(rel) {
+		infos, err := ReadDir(rel)
+		return &Dir{infos: infos}, err
+	}
+	dir := FilePath(rel)
+	if dir == "" {
+		return nil, os.ErrNotExist
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	if info, err := f.Stat(); err != nil || !info.IsDir() {
+		return nil, os.ErrNotExist
+	}
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return &Dir{dir: dir, names: names}, nil
+}
+
+// Next 按名称顺序读取下一批目录项，读完时返回io.EOF；读取期间已删除的项跳过
+func (d *Dir) Next() ([]os.FileInfo, error) {
+	if d.dir == "" {
+		infos := d.infos
+		if d.infos = nil; len(infos) == 0 {
+			return nil, io.EOF
+		}
+		return infos, nil
+	}
+	if len(d.names) == 0 {
+		return nil, io.EOF
+	}
+	n := dirBatch
+	if n > len(d.names) {
+		n = len(d.names)
+	}
+	infos := make([]os.FileInfo, 0, n)
+	for _, name := range d.names[:n] {
+		if info, err := os.Lstat(filepath.Join(d.dir, name)); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	d.names = d.names[n:]
+	return filterDir(d.dir, infos), nil
+}
+
+// Close 释放尚未读取的名称
+func (d *Dir) Close() error {
+	d.names, d.infos = nil, nil
+	return nil
+}
+
+// ListPath 列出共享目录下的相对路径rel，多根目录的顶层列出各根目录
+// prefix为返回的path字段前缀，rules为隐藏规则
+func ListPath(rel, prefix string, rules hidden.Rules) []map[string]string {
+	var ret []map[string]string
+	_ = EachPath(rel, prefix, rules, func(m map[string]string) bool {
+		ret = append(ret, m)
+		return true
+	})
+	return ret
+}
+
+// EachPath 按名称顺序逐项生成ListPath的列表项并调用fn，fn返回false时停止，不在内存中构造整个列表
+func EachPath(rel, prefix string, rules hidden.Rules, fn func(map[string]string) bool) error {
+	d, err := OpenDir(rel)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	top := Roots().IsTop(rel)
+	index := 0
+	for {
+		infos, err := d.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			var m map[string]string
+			if top {
+				m = map[string]string{
+					"name": info.Name(), "ext": "dir", "type": "dir", "size": "0", "sizes": GetSize(0),
+					"date": info.ModTime().Format("01-02"), "mtime": strconv.FormatInt(info.ModTime().Unix(), 10), "path": prefix + "/" + info.Name(),
+				}
+				setStyle(m, FilePath(info.Name()))
+				setDirSize(m, FilePath(info.Name()))
+			} else {
+				file := filepath.Join(d.dir, info.Name())
+				if rules.Hidden(FileKey(file)) {
+					continue
+				}
+				m = dirEntry(file, info, LinkOf(info), prefix)
+			}
+			index++
+			m["indexs"] = strconv.Itoa(index)
+			if !fn(m) {
+				return nil
+			}
+		}
+	}
+}
+
+// Localize 按语言改写列表中的sizes、date并加入相对修改时间ago(需要mtime字段)
+func Localize(list []map[string]string, loc string, now time.Time) {
+	for _, m := range list {
+		size, _ := strconv.ParseInt(m["size"], 10, 64)
+		m["sizes"] = humanize.Size(size, loc)
+		if sec, err := strconv.ParseInt(m["mtime"], 10, 64); err == nil {
+			t := time.Unix(sec, 0)
+			m["date"] = humanize.Date(t, now, loc)
+			m["ago"] = humanize.Ago(t, now, loc)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package fileinfos
+
+import (
+	"b0pass/library/hidden"
+	"b0pass/library/roots"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestOpenDir(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "fileinfos")
+	defer func() { _ = os.RemoveAll(dir) }()
+	defer SetRoots(nil)
+	SetRoots(roots.Set{{Name: "files", Path: dir}})
+	for i := 0; i < dirBatch+10; i++ {
+		_ = ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(i)), nil, 0644)
+	}
+	d, err := OpenDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+	seen, batches, last := map[string]bool{}, 0, ""
+	for {
+		infos, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		batches++
+		// 整个目录按名称排序，而不是每批各自排序
+		for _, info := range infos {
+			if info.Name() <= last {
+				t.Fatal("order", last, info.Name())
+			}
+			last = info.Name()
+			seen[info.Name()] = true
+		}
+	}
+	if len(seen) != dirBatch+10 || batches != 2 {
+		t.Error(len(seen), batches)
+	}
+	if _, err := OpenDir("/0"); err == nil {
+		t.Error("file opened as dir")
+	}
+	if _, err := OpenDir("/missing"); err == nil {
+		t.Error("missing dir")
+	}
+}
+
+func TestEachPath(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "fileinfos")
+	defer func() { _ = os.RemoveAll(dir) }()
+	defer SetRoots(nil)
+	SetRoots(roots.Set{{Name: "files", Path: dir}})
+	// 倒序创建，超过一批的目录项也按名称顺序返回
+	n := dirBatch*2 + 5
+	for i := n - 1; i >= 0; i-- {
+		_ = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("f%04d.txt", i)), nil, 0644)
+	}
+	_ = os.Mkdir(filepath.Join(dir, "hidden"), 0755)
+	i := 0
+	err := EachPath("/", "files", hidden.Rules{Patterns: []string{"hidden"}}, func(m map[string]string) bool {
+		if want := fmt.Sprintf("f%04d.txt", i); m["name"] != want || m["path"] != "files/"+want || m["indexs"] != strconv.Itoa(i+1) {
+			t.Fatalf("%d: %v", i, m)
+		}
+		i++
+		return true
+	})
+	if err != nil || i != n {
+		t.Error(i, err)
+	}
+	if list := ListPath("/", "files", hidden.Rules{}); len(list) != n+1 || list[n]["name"] != "hidden" {
+		t.Error(len(list))
+	}
+}
@@ -0,0 +1,203 @@
+package fileinfos
+
+import (
+	"b0pass/library/bufpool"
+	"b0pass/library/fsync"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TempDir 上传暂存目录
+// 相对路径以root为基准，默认与目标目录位于同一文件系统，最终rename为原子操作
+func TempDir(root, dir string) string {
+	if dir == "" {
+		dir = ".b0pass-tmp"
+	}
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(root, dir)
+}
+
+// CreateTemp 在暂存目录中创建临时文件
+func CreateTemp(dir, name string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(dir, filepath.Base(name)+".*.part")
+	if err != nil {
+		return nil, err
+	}
+	// TempFile默认权限为0600，调整为普通上传文件权限
+	_ = f.Chmod(0644)
+	return f, nil
+}
+
+// UploadID 根据目标路径、大小与哈希生成断点续传ID
+func UploadID(target string, size int64, hash string) string {
+	sum := sha256.Sum256([]byte(target + "|" + strconv.FormatInt(size, 10) + "|" + hash))
+	return hex.EncodeToString(sum[:12])
+}
+
+// PartFile 断点续传暂存文件路径
+func PartFile(dir, id string) string {
+	return filepath.Join(dir, id+".part")
+}
+
+// OpenPart 打开断点续传暂存文件用于追加写入
+// offset须与已写入的大小一致，避免分片重复或缺失
+func OpenPart(dir, id string, offset int64) (*os.File, error) {
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return nil, fmt.Errorf("invalid upload id: %s", id)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(PartFile(dir, id), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil && info.Size() != offset {
+		err = fmt.Errorf("offset mismatch: have %d, got %d", info.Size(), offset)
+	}
+	if err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// RemoveTemps 删除暂存目录中CreateTemp创建的临时文件，保留断点续传的暂存文件(PartFile)，返回删除个数
+func RemoveTemps(dir string) (int, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".part") {
+			continue
+		}
+		if _, err := hex.DecodeString(strings.TrimSuffix(name, ".part")); err == nil {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// UniqueName 目标已存在时生成不冲突的文件名，如 photo (1).jpg
+func UniqueName(file string) string {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return file
+	}
+	ext := filepath.Ext(file)
+	base := strings.TrimSuffix(file, ext)
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+	}
+}
+
+// Clone 以硬链接(失败时复制)的方式将src放到dst
+func Clone(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return copyFile(src, dst, false)
+}
+
+// Commit 将暂存文件移动到目标位置，目标位置不会出现写了一半的文件
+// 暂存目录被配置到其他文件系统时，rename失败则退化为复制
+func Commit(tmp, dst string) error {
+	return commit(tmp, dst, false)
+}
+
+// CommitSync 同Commit，rename前同步暂存文件、rename后同步目标目录，断电后目标位置要么没有该文件，要么是完整的文件
+func CommitSync(tmp, dst string) error {
+	return commit(tmp, dst, true)
+}
+
+func commit(tmp, dst string, sync bool) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if sync {
+		if err := syncFile(tmp); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		if err := copyFile(tmp, dst, sync); err != nil {
+			return err
+		}
+		_ = os.Remove(tmp)
+	}
+	if sync {
+		return fsync.Dir(filepath.Dir(dst))
+	}
+	return nil
+}
+
+// syncFile 同步文件内容
+func syncFile(file string) error {
+	f, err := os.OpenFile(file, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// copyFile 复制文件: 先写入目标目录中的隐藏临时文件，完成后rename到dst
+func copyFile(src, dst string, sync bool) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst)+".*.part")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(out.Name()) }()
+	if info, err := in.Stat(); err == nil {
+		_ = out.Chmod(info.Mode().Perm())
+	}
+	_, err = bufpool.Copy(out, in)
+	if err == nil && sync {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
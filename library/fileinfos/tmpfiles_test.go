@@ -0,0 +1,110 @@
+package fileinfos
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTempDir(t *testing.T) {
+	if got := TempDir("/data/files", ""); got != filepath.Join("/data/files", ".b0pass-tmp") {
+		t.Error(got)
+	}
+	if got := TempDir("/data/files", "/mnt/tmp"); got != "/mnt/tmp" {
+		t.Error(got)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	root, _ := ioutil.TempDir("", "files")
+	defer func() { _ = os.RemoveAll(root) }()
+
+	f, err := CreateTemp(TempDir(root, ""), "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("hello")
+	_ = f.Close()
+
+	dst := filepath.Join(root, "sub", "a.txt")
+	if err := Commit(f.Name(), dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(dst); string(data) != "hello" {
+		t.Errorf("bad content %q", data)
+	}
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Error("temp file left behind")
+	}
+
+	g, _ := CreateTemp(TempDir(root, ""), "b.txt")
+	_, _ = g.WriteString("world")
+	_ = g.Close()
+	if err := CommitSync(g.Name(), filepath.Join(root, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(root, "b.txt")); string(data) != "world" {
+		t.Errorf("sync commit %q", data)
+	}
+
+	// 跨文件系统时的复制经目标目录中的隐藏临时文件rename到目标位置
+	if err := copyFile(dst, filepath.Join(root, "c.txt"), true); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := ioutil.ReadDir(root)
+	var names []string
+	for _, info := range list {
+		names = append(names, info.Name())
+	}
+	if len(names) != 4 || names[2] != "c.txt" {
+		t.Errorf("files %v", names)
+	}
+	if info, err := os.Stat(filepath.Join(root, "c.txt")); err != nil || info.Mode().Perm() != 0644 {
+		t.Error("copy mode", err)
+	}
+}
+
+func TestOpenPart(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "parts")
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	id := UploadID("/files/a.bin", 6, "")
+	f, err := OpenPart(dir, id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("abc")
+	_ = f.Close()
+
+	if _, err := OpenPart(dir, id, 0); err == nil {
+		t.Error("offset mismatch accepted")
+	}
+	if _, err := OpenPart(dir, "../../etc/passwd", 0); err == nil {
+		t.Error("invalid id accepted")
+	}
+	f, err = OpenPart(dir, id, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("def")
+	_ = f.Close()
+	if data, _ := ioutil.ReadFile(PartFile(dir, id)); string(data) != "abcdef" {
+		t.Errorf("bad content %q", data)
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "unique")
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	file := filepath.Join(dir, "photo.jpg")
+	if UniqueName(file) != file {
+		t.Error("free name changed")
+	}
+	_ = ioutil.WriteFile(file, nil, 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "photo (1).jpg"), nil, 0644)
+	if got := UniqueName(file); got != filepath.Join(dir, "photo (2).jpg") {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,169 @@
+package filename
+
+import (
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// 跨平台文件名: 上传的文件名先规范化Unicode形式，再替换Windows与Android(FAT/exFAT存储卡)上不允许的字符、
+// 避开保留名并限制长度，使macOS上传的文件在Windows主机上可以正常打开，反之亦然
+
+// Replacement 替换不允许的字符
+const Replacement = "_"
+
+// MaxUTF16 NTFS与exFAT的文件名长度上限(UTF-16码元)
+const MaxUTF16 = 255
+
+// 调整的原因
+const (
+	ChangeNormalized = "normalized" // Unicode规范化
+	ChangeInvalid    = "invalid"    // 替换了不允许的字符
+	ChangeReserved   = "reserved"   // Windows保留名，如 CON、NUL.txt
+	ChangeTrailing   = "trailing"   // 去掉结尾的点与空格
+	ChangeTruncated  = "truncated"  // 超出长度上限，保留扩展名截断
+	ChangeEmpty      = "empty"      // 调整后为空或为 . ..
+)
+
+// Options 文件名规则
+type Options struct {
+	Form     string // Unicode规范化形式: NFC、NFD，为空时不规范化
+	Portable bool   // 替换Windows、Android上不允许的字符，避开保留名，去掉结尾的点与空格
+	MaxBytes int    // UTF-8字节数上限(ext4等为255)，0为不限制；Portable时同时限制UTF-16码元数
+}
+
+// Result 调整结果
+type Result struct {
+	Name     string   `json:"name"`
+	Original string   `json:"original"`
+	Changed  bool     `json:"changed"`
+	Changes  []string `json:"changes,omitempty"`
+}
+
+// invalidChars Windows与Android(FAT)文件名中不允许的字符，另有控制字符
+const invalidChars = `<>:"/\|?*`
+
+// reserved Windows保留的设备名，带扩展名时同样不可用
+var reserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Sanitize 按规则调整一个文件名(不含目录)
+func Sanitize(name string, o Options) Result {
+	res := Result{Original: name}
+	change := func(c string) {
+		for _, v := range res.Changes {
+			if v == c {
+				return
+			}
+		}
+		res.Changes = append(res.Changes, c)
+	}
+	if !utf8.ValidString(name) {
+		name = validUTF8(name)
+		change(ChangeInvalid)
+	}
+	if s := Normalize(name, o.Form); s != name {
+		name = s
+		change(ChangeNormalized)
+	}
+	// 路径分隔符与空字符在任何系统上都不能出现在文件名中
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == 0 || (o.Portable && (r < 0x20 || r == 0x7F || strings.ContainsRune(invalidChars, r))) {
+			change(ChangeInvalid)
+			return []rune(Replacement)[0]
+		}
+		return r
+	}, name)
+	if o.Portable {
+		if s := strings.TrimRight(name, ". "); s != name {
+			name = s
+			change(ChangeTrailing)
+		}
+		base := name
+		if i := strings.IndexByte(base, '.'); i >= 0 {
+			base = base[:i]
+		}
+		if reserved[strings.ToUpper(strings.TrimRight(base, " "))] {
+			name = Replacement + name
+			change(ChangeReserved)
+		}
+	}
+	if s := truncate(name, o); s != name {
+		name = s
+		change(ChangeTruncated)
+	}
+	if strings.TrimFunc(name, unicode.IsSpace) == "" || name == "." || name == ".." {
+		name = Replacement
+		change(ChangeEmpty)
+	}
+	res.Name, res.Changed = name, len(res.Changes) > 0
+	return res
+}
+
+// Clean 按规则调整后的文件名
+func Clean(name string, o Options) string {
+	return Sanitize(name, o).Name
+}
+
+// CleanPath 调整相对路径(以/分隔)中的每一段，用于解包等包含目录的名称
+func CleanPath(rel string, o Options) string {
+	parts := strings.Split(path.Clean("/"+rel), "/")
+	for i, p := range parts {
+		if p != "" {
+			parts[i] = Clean(p, o)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// fits 长度是否在上限内
+func fits(name string, o Options) bool {
+	if o.MaxBytes > 0 && len(name) > o.MaxBytes {
+		return false
+	}
+	return !o.Portable || len(utf16.Encode([]rune(name))) <= MaxUTF16
+}
+
+// truncate 超出长度上限时从主文件名末尾逐个字符截断，保留扩展名；扩展名本身过长时一起截断
+func truncate(name string, o Options) string {
+	if fits(name, o) {
+		return name
+	}
+	ext := path.Ext(name)
+	if len(ext) > 16 || ext == name {
+		ext = ""
+	}
+	base := []rune(strings.TrimSuffix(name, ext))
+	for len(base) > 0 {
+		r := base[len(base)-1]
+		base = base[:len(base)-1]
+		// 截掉组合字符(如分解形式的重音)时连同它所在的字母一起截掉
+		if combiningClass(r) != 0 {
+			continue
+		}
+		if s := string(base) + ext; fits(s, o) {
+			return s
+		}
+	}
+	return ext
+}
+
+// validUTF8 替换不合法的UTF-8字节
+func validUTF8(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && n == 1 {
+			b.WriteString(Replacement)
+		} else {
+			b.WriteString(s[i : i+n])
+		}
+		i += n
+	}
+	return b.String()
+}
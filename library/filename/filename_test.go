@@ -0,0 +1,110 @@
+package filename
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct{ nfc, nfd string }{
+		{"Caf\u00e9.txt", "Cafe\u0301.txt"},
+		{"\uac01", "\u1100\u1161\u11a8"},                               // 谚文
+		{"\u304c", "\u304b\u3099"},                                     // が
+		{"Ti\u1ebfng Vi\u1ec7t", "Tie\u0302\u0301ng Vie\u0323\u0302t"}, // 越南语，组合类排序
+		{"\u01fa", "A\u030a\u0301"},
+		{"\u0301abc", "\u0301abc"},
+	}
+	for _, c := range cases {
+		if got := ToNFD(c.nfc); got != c.nfd {
+			t.Errorf("NFD(%q) = %q, want %q", c.nfc, got, c.nfd)
+		}
+		if got := ToNFC(c.nfd); got != c.nfc {
+			t.Errorf("NFC(%q) = %q, want %q", c.nfd, got, c.nfc)
+		}
+	}
+	// 单一分解: 埃符号 U+212B 规范化为 Å
+	if got := ToNFC("\u212b"); got != "\u00c5" {
+		t.Errorf("NFC(U+212B) = %q", got)
+	}
+	// 组合排除: 天城文 क़ 保持分解形式
+	if got := ToNFC("\u0958"); got != "\u0915\u093c" {
+		t.Errorf("NFC(U+0958) = %q", got)
+	}
+	// 组合字符乱序时按组合类排序后组合
+	if got := ToNFC("a\u0302\u0323"); got != "\u1ead" {
+		t.Errorf("NFC = %q", got)
+	}
+	if got := ToNFD("a\u0302\u0323"); got != "a\u0323\u0302" {
+		t.Errorf("NFD = %q", got)
+	}
+	if got := Normalize("Cafe\u0301", ""); got != "Cafe\u0301" {
+		t.Error("empty form", got)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	o := Options{Form: NFC, Portable: true, MaxBytes: 255}
+	cases := []struct {
+		in, out string
+		changes []string
+	}{
+		{"report.pdf", "report.pdf", nil},
+		{"Cafe\u0301.txt", "Caf\u00e9.txt", []string{ChangeNormalized}},
+		{`a<b>:c"d|e?f*.txt`, "a_b__c_d_e_f_.txt", []string{ChangeInvalid}},
+		{"tab\there.txt", "tab_here.txt", []string{ChangeInvalid}},
+		{"CON.txt", "_CON.txt", []string{ChangeReserved}},
+		{"nul", "_nul", []string{ChangeReserved}},
+		{"console.txt", "console.txt", nil},
+		{"notes. . ", "notes", []string{ChangeTrailing}},
+		{"...", "_", []string{ChangeTrailing, ChangeEmpty}},
+		{"", "_", []string{ChangeEmpty}},
+		{"bad\xffname", "bad_name", []string{ChangeInvalid}},
+	}
+	for _, c := range cases {
+		res := Sanitize(c.in, o)
+		if res.Name != c.out || strings.Join(res.Changes, ",") != strings.Join(c.changes, ",") {
+			t.Errorf("Sanitize(%q) = %q %v, want %q %v", c.in, res.Name, res.Changes, c.out, c.changes)
+		}
+		if res.Changed != (len(c.changes) > 0) || res.Original != c.in {
+			t.Errorf("Sanitize(%q) = %+v", c.in, res)
+		}
+	}
+	// 非Portable时只替换路径分隔符
+	if got := Clean(`a:b/c.txt`, Options{}); got != "a:b_c.txt" {
+		t.Error(got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	o := Options{Portable: true, MaxBytes: 255}
+	long := strings.Repeat("\u4e2d", 100) + ".tar.gz"
+	res := Sanitize(long, o)
+	if len(res.Name) > 255 || !strings.HasSuffix(res.Name, ".gz") || res.Changes[0] != ChangeTruncated {
+		t.Errorf("%d %q %v", len(res.Name), res.Name, res.Changes)
+	}
+	// UTF-16长度上限: 300个emoji各占4字节与2个码元
+	emoji := Clean(strings.Repeat("\U0001F600", 300)+".png", Options{Portable: true})
+	if n := len(utf16.Encode([]rune(emoji))); n > MaxUTF16 || !strings.HasSuffix(emoji, ".png") {
+		t.Error(n, emoji)
+	}
+	// 不留下缺少组合字符的字母
+	got := Clean(strings.Repeat("e\u0301", 10)+".txt", Options{MaxBytes: 12})
+	if got != "e\u0301e\u0301.txt" {
+		t.Errorf("%q", got)
+	}
+	// 扩展名过长时整体截断
+	if got := Clean(strings.Repeat("x", 300), Options{MaxBytes: 10}); got != strings.Repeat("x", 10) {
+		t.Error(got)
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	o := Options{Form: NFC, Portable: true}
+	if got := CleanPath("docs/CON/a?.txt", o); got != "/docs/_CON/a_.txt" {
+		t.Error(got)
+	}
+	if got := CleanPath("../x/./y", o); got != "/x/y" {
+		t.Error(got)
+	}
+}
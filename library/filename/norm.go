@@ -0,0 +1,170 @@
+package filename
+
+import (
+	"sort"
+	"sync"
+)
+
+// Unicode规范化: macOS的文件名多为分解形式(NFD，如 e + ◌́)，Windows、Linux与Android多为组合形式(NFC，如 é)，
+// 同一个名称在两边字节不同，复制后会出现看起来同名的两个文件或找不到文件
+
+// 规范化形式
+const (
+	NFC = "nfc"
+	NFD = "nfd"
+)
+
+type decomp struct {
+	r, a, b rune
+	compose bool
+}
+
+type class struct {
+	lo, hi rune
+	ccc    uint8
+}
+
+// 谚文音节按算法分解与组合
+const (
+	hangulBase  = 0xAC00
+	hangulL     = 0x1100
+	hangulV     = 0x1161
+	hangulT     = 0x11A7
+	hangulLN    = 19
+	hangulVN    = 21
+	hangulTN    = 28
+	hangulNN    = hangulVN * hangulTN
+	hangulCount = hangulLN * hangulNN
+)
+
+var (
+	tablesOnce sync.Once
+	decompMap  map[rune][2]rune
+	composeMap map[[2]rune]rune
+)
+
+func initTables() {
+	decompMap = make(map[rune][2]rune, len(decomps))
+	composeMap = make(map[[2]rune]rune, len(decomps))
+	for _, d := range decomps {
+		decompMap[d.r] = [2]rune{d.a, d.b}
+		if d.compose {
+			composeMap[[2]rune{d.a, d.b}] = d.r
+		}
+	}
+}
+
+// combiningClass 规范组合类，0为起始字符
+func combiningClass(r rune) uint8 {
+	i := sort.Search(len(classes), func(i int) bool { return classes[i].hi >= r })
+	if i < len(classes) && classes[i].lo <= r {
+		return classes[i].ccc
+	}
+	return 0
+}
+
+// decompose 将r完全规范分解后追加到buf
+func decompose(buf []rune, r rune) []rune {
+	if s := r - hangulBase; s >= 0 && s < hangulCount {
+		buf = append(buf, hangulL+s/hangulNN, hangulV+(s%hangulNN)/hangulTN)
+		if t := s % hangulTN; t != 0 {
+			buf = append(buf, hangulT+t)
+		}
+		return buf
+	}
+	d, ok := decompMap[r]
+	if !ok {
+		return append(buf, r)
+	}
+	buf = decompose(buf, d[0])
+	if d[1] != 0 {
+		buf = decompose(buf, d[1])
+	}
+	return buf
+}
+
+// compose a与b的规范组合，不能组合时返回-1
+func compose(a, b rune) rune {
+	if l, v := a-hangulL, b-hangulV; l >= 0 && l < hangulLN && v >= 0 && v < hangulVN {
+		return hangulBase + (l*hangulVN+v)*hangulTN
+	}
+	if s, t := a-hangulBase, b-hangulT; s >= 0 && s < hangulCount && s%hangulTN == 0 && t > 0 && t < hangulTN {
+		return a + t
+	}
+	if c, ok := composeMap[[2]rune{a, b}]; ok {
+		return c
+	}
+	return -1
+}
+
+// nfd 规范分解并按组合类排序相邻的非起始字符
+func nfd(s string) []rune {
+	tablesOnce.Do(initTables)
+	buf := make([]rune, 0, len(s))
+	for _, r := range s {
+		buf = decompose(buf, r)
+	}
+	for i := 1; i < len(buf); i++ {
+		c := combiningClass(buf[i])
+		if c == 0 {
+			continue
+		}
+		for j := i; j > 0; j-- {
+			p := combiningClass(buf[j-1])
+			if p == 0 || p <= c {
+				break
+			}
+			buf[j], buf[j-1] = buf[j-1], buf[j]
+		}
+	}
+	return buf
+}
+
+// ToNFD 分解形式
+func ToNFD(s string) string {
+	return string(nfd(s))
+}
+
+// ToNFC 组合形式
+func ToNFC(s string) string {
+	buf := nfd(s)
+	if len(buf) == 0 {
+		return ""
+	}
+	starter := 0
+	last := combiningClass(buf[0])
+	if last != 0 {
+		// 以非起始字符开头时不与之后的字符组合
+		last = 255
+		starter = -1
+	}
+	n := 1
+	for i := 1; i < len(buf); i++ {
+		r := buf[i]
+		c := combiningClass(r)
+		if starter >= 0 && (last < c || last == 0) {
+			if composite := compose(buf[starter], r); composite >= 0 {
+				buf[starter] = composite
+				continue
+			}
+		}
+		if c == 0 {
+			starter = n
+		}
+		last = c
+		buf[n] = r
+		n++
+	}
+	return string(buf[:n])
+}
+
+// Normalize 按形式(NFC或NFD)规范化，其它值不处理
+func Normalize(s, form string) string {
+	switch form {
+	case NFC:
+		return ToNFC(s)
+	case NFD:
+		return ToNFD(s)
+	}
+	return s
+}
@@ -0,0 +1,207 @@
+package formstream
+
+import (
+	"b0pass/library/bufpool"
+	"b0pass/library/hashes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// 流式解析multipart上传: 文件部分边读边写入暂存文件(同时计算哈希)，
+// 内存占用只有缓冲区池中固定大小的缓冲区，与上传文件的大小无关
+
+var (
+	// ErrTooLarge 请求体超过大小限制
+	ErrTooLarge = errors.New("formstream: request body too large")
+	// ErrFieldsTooLarge 普通字段总大小超过限制
+	ErrFieldsTooLarge = errors.New("formstream: form fields too large")
+)
+
+// maxFieldBytes 普通字段的总大小上限
+const maxFieldBytes = 1 << 20
+
+// Options 解析选项
+type Options struct {
+	// MaxSize 请求体最大字节数，0为不限制
+	MaxSize int64
+	// Create 为文件部分创建暂存文件，values为该部分之前已解析的字段；
+	// 可预先分配空间，写入完成后截断到实际大小
+	Create func(values url.Values, field, filename string) (*os.File, error)
+	// Algos 文件部分计算的哈希算法(可选)，values同上
+	Algos func(values url.Values) []string
+	// Progress 文件部分已写入n字节(累计，可选)
+	Progress func(file *File, n int64)
+	// Saved 文件部分已完整写入暂存文件(可选)，可在解析后续部分前处理该文件
+	Saved func(values url.Values, file *File)
+}
+
+// File 已写入暂存文件的文件部分
+type File struct {
+	Field    string
+	Filename string
+	Path     string // 暂存文件
+	Size     int64
+	Sums     map[string]string
+}
+
+// Form 解析结果
+type Form struct {
+	Values url.Values
+	Files  []*File
+}
+
+// File 字段对应的第一个文件
+func (f *Form) File(field string) *File {
+	for _, file := range f.Files {
+		if file.Field == field {
+			return file
+		}
+	}
+	return nil
+}
+
+// Remove 删除所有暂存文件(已被移走的文件忽略)
+func (f *Form) Remove() {
+	for _, file := range f.Files {
+		_ = os.Remove(file.Path)
+	}
+}
+
+// Parse 解析multipart请求体，出错时已写入的暂存文件会被删除
+func Parse(r *http.Request, o Options) (form *Form, err error) {
+	if o.MaxSize > 0 {
+		if r.ContentLength > o.MaxSize {
+			return nil, ErrTooLarge
+		}
+		limited := &limitReader{r: r.Body, n: o.MaxSize}
+		r.Body = limited
+		defer func() {
+			if limited.exceeded && err != nil {
+				err = ErrTooLarge
+			}
+		}()
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	form = &Form{Values: make(url.Values)}
+	fieldBytes := int64(0)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			form.Remove()
+			return nil, err
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if part.FileName() == "" {
+			b, err := ioutil.ReadAll(io.LimitReader(part, maxFieldBytes-fieldBytes+1))
+			if err != nil {
+				form.Remove()
+				return nil, err
+			}
+			if fieldBytes += int64(len(b)); fieldBytes > maxFieldBytes {
+				form.Remove()
+				return nil, ErrFieldsTooLarge
+			}
+			form.Values.Add(name, string(b))
+			continue
+		}
+		file, err := saveFile(part, form.Values, o)
+		if file != nil {
+			form.Files = append(form.Files, file)
+		}
+		if err != nil {
+			form.Remove()
+			return nil, err
+		}
+		if o.Saved != nil {
+			o.Saved(form.Values, file)
+		}
+	}
+}
+
+// saveFile 将文件部分写入暂存文件
+func saveFile(part *multipart.Part, values url.Values, o Options) (*File, error) {
+	f, err := o.Create(values, part.FormName(), part.FileName())
+	if err != nil {
+		return nil, err
+	}
+	file := &File{Field: part.FormName(), Filename: part.FileName(), Path: f.Name()}
+	var algos []string
+	if o.Algos != nil {
+		algos = o.Algos(values)
+	}
+	multi, err := hashes.NewMulti(algos...)
+	if err != nil {
+		_ = f.Close()
+		return file, err
+	}
+	var w io.Writer = io.MultiWriter(f, multi)
+	if o.Progress != nil {
+		w = &progressWriter{w: w, fn: func(n int64) { o.Progress(file, n) }}
+	}
+	file.Size, err = bufpool.Copy(w, part)
+	if err == nil {
+		err = f.Truncate(file.Size)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	file.Sums = multi.Sums()
+	return file, err
+}
+
+// progressWriter 每次写入后报告累计字节数
+type progressWriter struct {
+	w  io.Writer
+	n  int64
+	fn func(n int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.n += int64(n)
+	p.fn(p.n)
+	return n, err
+}
+
+// limitReader 超过n字节时返回ErrTooLarge
+type limitReader struct {
+	r        io.ReadCloser
+	n        int64
+	exceeded bool
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// 恰好读完时允许返回EOF
+		var one [1]byte
+		if n, err := l.r.Read(one[:]); n == 0 {
+			return 0, err
+		}
+		l.exceeded = true
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+func (l *limitReader) Close() error {
+	return l.r.Close()
+}
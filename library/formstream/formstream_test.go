@@ -0,0 +1,132 @@
+package formstream
+
+import (
+	"b0pass/library/hashes"
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+// newRequest 构造multipart请求，字段按顺序写入
+func newRequest(t *testing.T, fields [][2]string, file string, data []byte) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, f := range fields {
+		_ = w.WriteField(f[0], f[1])
+	}
+	fw, err := w.CreateFormFile("upload-file", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fw.Write(data)
+	_ = w.WriteField("after", "1")
+	_ = w.Close()
+	r, _ := http.NewRequest(http.MethodPost, "/api/upload", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	return r
+}
+
+func TestParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "formstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	var seen url.Values
+	o := Options{
+		Create: func(values url.Values, field, filename string) (*os.File, error) {
+			seen = values
+			f, err := ioutil.TempFile(dir, filename+".*.part")
+			if err == nil {
+				// 预留的空间在写入后截断
+				err = f.Truncate(1 << 21)
+			}
+			return f, err
+		},
+		Algos: func(values url.Values) []string { return []string{hashes.SHA256, values.Get("algo")} },
+	}
+	form, err := Parse(newRequest(t, [][2]string{{"path", "sub"}, {"algo", "md5"}}, "a.bin", data), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := form.File("upload-file")
+	if f == nil || f.Filename != "a.bin" || f.Size != int64(len(data)) {
+		t.Fatal(f)
+	}
+	if seen.Get("path") != "sub" || form.Values.Get("after") != "1" {
+		t.Error("values", seen, form.Values)
+	}
+	want, _ := hashes.NewMulti(hashes.SHA256, hashes.MD5)
+	_, _ = want.Write(data)
+	if f.Sums[hashes.SHA256] != want.Sums()[hashes.SHA256] || f.Sums[hashes.MD5] != want.Sums()[hashes.MD5] {
+		t.Error("sums", f.Sums)
+	}
+	if b, _ := ioutil.ReadFile(f.Path); !bytes.Equal(b, data) {
+		t.Error("content")
+	}
+	form.Remove()
+	if _, err := os.Stat(f.Path); !os.IsNotExist(err) {
+		t.Error("not removed")
+	}
+
+	// 超过大小限制: 声明的长度或实际读取的长度
+	o.MaxSize = 1000
+	if _, err := Parse(newRequest(t, nil, "b.bin", data), o); err != ErrTooLarge {
+		t.Error("content-length", err)
+	}
+	r := newRequest(t, nil, "b.bin", data)
+	r.ContentLength = -1
+	if _, err := Parse(r, o); err != ErrTooLarge {
+		t.Error("streamed", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Error("temp files left", len(files))
+	}
+}
+
+func TestProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "formstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("path", "sub")
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		fw, _ := w.CreateFormFile("upload-file", name)
+		_, _ = fw.Write(bytes.Repeat([]byte(name), 50000))
+	}
+	_ = w.Close()
+	r, _ := http.NewRequest(http.MethodPost, "/api/upload", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+
+	written := make(map[string]int64)
+	var saved []string
+	form, err := Parse(r, Options{
+		Create: func(values url.Values, field, filename string) (*os.File, error) {
+			return ioutil.TempFile(dir, filename+".*.part")
+		},
+		Progress: func(file *File, n int64) { written[file.Filename] = n },
+		Saved: func(values url.Values, file *File) {
+			// 前一个文件在后续部分解析前处理
+			if written[file.Filename] != file.Size || values.Get("path") != "sub" {
+				t.Error("saved", file.Filename, written)
+			}
+			saved = append(saved, file.Filename)
+			_ = os.Remove(file.Path)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form.Remove()
+	if len(saved) != 2 || saved[0] != "a.jpg" || written["b.jpg"] != 250000 {
+		t.Error(saved, written)
+	}
+}
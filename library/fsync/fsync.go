@@ -0,0 +1,78 @@
+package fsync
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 合并fsync: 批量写入大量小文件时不逐个同步，全部写完后一次同步，
+// Linux上每个文件系统只需一次syncfs，其他系统逐个同步文件及其所在目录
+
+// Dir 同步目录，使其中新建、rename的文件在断电后仍存在；不支持同步目录的系统忽略
+func Dir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	if err := d.Sync(); err != nil && !ignoreDirError(err) {
+		return err
+	}
+	return nil
+}
+
+// Group 待同步的一组文件，可并发Add
+type Group struct {
+	mu    sync.Mutex
+	files []string
+}
+
+// Add 加入已写入并关闭的文件
+func (g *Group) Add(file string) {
+	g.mu.Lock()
+	g.files = append(g.files, file)
+	g.mu.Unlock()
+}
+
+// Len 待同步的文件数
+func (g *Group) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.files)
+}
+
+// Sync 同步全部文件及其所在目录，完成后清空
+func (g *Group) Sync() error {
+	g.mu.Lock()
+	files := g.files
+	g.files = nil
+	g.mu.Unlock()
+	if len(files) == 0 {
+		return nil
+	}
+	return syncFiles(files)
+}
+
+// syncEach 逐个同步文件，再同步各文件所在的目录(每个目录一次)
+func syncEach(files []string) error {
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		f, err := os.OpenFile(file, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		err = f.Sync()
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		dirs[filepath.Dir(file)] = true
+	}
+	for dir := range dirs {
+		if err := Dir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package fsync
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+	"syscall"
+)
+
+// syncFiles 每个文件系统调用一次syncfs，同时写回数据与目录项
+func syncFiles(files []string) error {
+	done := make(map[uint64]bool)
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return syncEach(files)
+		}
+		if done[uint64(st.Dev)] {
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		err = unix.Syncfs(int(f.Fd()))
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		done[uint64(st.Dev)] = true
+	}
+	return nil
+}
+
+// ignoreDirError 目录同步的错误是否可以忽略
+func ignoreDirError(err error) bool {
+	return false
+}
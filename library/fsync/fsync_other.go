@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package fsync
+
+import "runtime"
+
+// syncFiles 逐个同步文件及目录
+func syncFiles(files []string) error {
+	return syncEach(files)
+}
+
+// ignoreDirError Windows不支持同步目录
+func ignoreDirError(err error) bool {
+	return runtime.GOOS == "windows"
+}
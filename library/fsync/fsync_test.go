@@ -0,0 +1,82 @@
+package fsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	var g Group
+	for _, name := range []string{"a", "b", "sub/c"} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(file), 0755)
+		if err := ioutil.WriteFile(file, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		g.Add(file)
+	}
+	if g.Len() != 3 {
+		t.Fatal(g.Len())
+	}
+	if err := g.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if g.Len() != 0 {
+		t.Error("not cleared")
+	}
+	if err := syncEach([]string{filepath.Join(dir, "a"), filepath.Join(dir, "sub", "c")}); err != nil {
+		t.Error(err)
+	}
+	g.Add(filepath.Join(dir, "missing"))
+	if err := g.Sync(); err == nil {
+		t.Error("missing file")
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	if p, err := ParsePolicy(" Per-Chunk ", OnComplete); err != nil || p != PerChunk {
+		t.Error(p, err)
+	}
+	if p, err := ParsePolicy("", OnComplete); err != nil || p != OnComplete {
+		t.Error(p, err)
+	}
+	if _, err := ParsePolicy("always", OnComplete); err == nil {
+		t.Error("invalid policy")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "data", "state.json")
+	for _, v := range []string{"old", "new"} {
+		if err := WriteJSON(file, map[string]string{"v": v}, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, _ := ioutil.ReadFile(file)
+	info, err := os.Stat(file)
+	if string(b) != `{"v":"new"}` || err != nil || info.Mode().Perm() != 0600 {
+		t.Error(string(b), err)
+	}
+	// 不留下临时文件
+	if names, _ := ioutil.ReadDir(filepath.Dir(file)); len(names) != 1 {
+		t.Error(len(names))
+	}
+	if err := WriteJSON(file, func() {}, 0600); err == nil {
+		t.Error("unsupported value")
+	}
+	if b, _ := ioutil.ReadFile(file); string(b) != `{"v":"new"}` {
+		t.Error("replaced on error", string(b))
+	}
+}
@@ -0,0 +1,35 @@
+package fsync
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 上传的同步策略，在断电安全与写入速度之间取舍(如以SD卡为存储的设备)
+const (
+	None       = "none"        // 不主动同步，由系统写回，断电可能丢失最近收到的文件
+	OnComplete = "on-complete" // 文件完整接收后同步文件及其所在目录
+	PerChunk   = "per-chunk"   // 每个分片写入后同步，确认的续传位置在断电后仍有效
+)
+
+// Policies 全部同步策略
+var Policies = []string{None, OnComplete, PerChunk}
+
+// ParsePolicy 解析同步策略(不区分大小写)，为空时返回def
+func ParsePolicy(s, def string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		s = def
+	}
+	for _, p := range Policies {
+		if s == p {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("invalid durability %q, expect one of %s", s, strings.Join(Policies, ", "))
+}
+
+// File 同步文件内容及其所在目录
+func File(file string) error {
+	return syncEach([]string{file})
+}
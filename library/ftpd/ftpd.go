@@ -0,0 +1,775 @@
+package ftpd
+
+import (
+	"b0pass/library/bufpool"
+	"b0pass/library/fileinfos"
+	"b0pass/library/logger"
+	"b0pass/library/roots"
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server 内嵌FTP/FTPS服务，供只支持FTP的老设备、相机、扫描仪使用
+type Server struct {
+	// Root 共享根目录
+	Root string
+	// Roots 多个共享根目录(可选)，设置时以根目录名作为顶层虚拟目录，不再使用Root
+	Roots roots.Set
+	// TempDir 上传暂存目录
+	TempDir string
+	// User/Password 为空时允许任意用户(匿名)登录
+	User     string
+	Password string
+	// Login 校验登录并返回角色(可选)，设置后不再使用User/Password
+	Login func(client, user, password string) (role string, ok bool)
+	// CanWrite 该角色能否写入(可选)，未设置时按ReadOnly
+	CanWrite func(role string) bool
+	// ReadOnly 只读模式
+	ReadOnly bool
+	// TLSConfig 非空时支持 AUTH TLS(显式FTPS)
+	TLSConfig *tls.Config
+	// PassiveMin/PassiveMax 被动模式端口范围，为0时由系统分配
+	PassiveMin int
+	PassiveMax int
+	// PublicIP PASV返回的地址，为空时使用控制连接的本地地址
+	PublicIP string
+	// OnChange 写操作完成回调(可选)，dest仅RNTO时有值
+	OnChange func(client, cmd, file, dest string, size int64)
+	// Busy 数据传输开始时调用(可选)，返回的函数在传输结束时调用
+	Busy func() func()
+	// Allow 是否允许访问文件(可选)，如按符号链接策略限制，不允许的路径视为不存在，也不在列表中出现
+	Allow func(file string) bool
+	// Remove 删除文件或目录(可选)，如移入回收站，未设置时直接删除
+	Remove func(file string) error
+	// Writable 能否写入文件(可选)，如受保护目录，不允许时STOR/APPE/RNTO返回550
+	Writable func(file string) bool
+
+	mu       sync.Mutex
+	listener net.Listener
+	nextPort int
+}
+
+// roots 共享根目录集合
+func (s *Server) roots() roots.Set {
+	if len(s.Roots) > 0 {
+		return s.Roots
+	}
+	return roots.Single(s.Root)
+}
+
+// remove 删除文件或目录
+func (s *Server) remove(file string) error {
+	if s.Remove != nil {
+		return s.Remove(file)
+	}
+	return os.RemoveAll(file)
+}
+
+// ListenAndServe 监听并提供服务
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在指定监听上提供服务
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.newSession(conn).serve()
+	}
+}
+
+// Close 停止监听
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// listenPassive 在被动端口范围内监听
+func (s *Server) listenPassive(host string) (net.Listener, error) {
+	if s.PassiveMin <= 0 || s.PassiveMax < s.PassiveMin {
+		return net.Listen("tcp", net.JoinHostPort(host, "0"))
+	}
+	n := s.PassiveMax - s.PassiveMin + 1
+	for i := 0; i < n; i++ {
+		s.mu.Lock()
+		port := s.PassiveMin + (s.nextPort+i)%n
+		s.mu.Unlock()
+		l, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err == nil {
+			s.mu.Lock()
+			s.nextPort = (s.nextPort + i + 1) % n
+			s.mu.Unlock()
+			return l, nil
+		}
+	}
+	return nil, errors.New("no passive port available")
+}
+
+// session 单个控制连接
+type session struct {
+	srv    *Server
+	conn   net.Conn
+	reader *bufio.Reader
+	client string
+
+	user       string
+	role       string
+	authed     bool
+	cwd        string
+	rest       int64
+	renameFrom string
+	private    bool
+
+	pasv net.Listener
+	port string
+}
+
+// login 校验PASS
+func (c *session) login(password string) bool {
+	if c.srv.Login != nil {
+		role, ok := c.srv.Login(c.client, c.user, password)
+		c.role = role
+		return ok
+	}
+	return c.srv.User == "" || (c.user == c.srv.User && password == c.srv.Password)
+}
+
+// writable 当前用户能否写入
+func (c *session) writable() bool {
+	if c.srv.CanWrite != nil {
+		return c.srv.CanWrite(c.role)
+	}
+	return !c.srv.ReadOnly
+}
+
+// writableAt 能否写入文件file
+func (c *session) writableAt(file string) bool {
+	return c.srv.Writable == nil || c.srv.Writable(file)
+}
+
+func (s *Server) newSession(conn net.Conn) *session {
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	return &session{
+		srv:    s,
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		client: host,
+		cwd:    "/",
+	}
+}
+
+func (c *session) reply(code int, msg string) {
+	_, _ = fmt.Fprintf(c.conn, "%d %s\r\n", code, msg)
+}
+
+func (c *session) serve() {
+	defer func() {
+		c.closeData()
+		_ = c.conn.Close()
+	}()
+	c.reply(220, "B0Pass FTP ready")
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+		cmd = strings.ToUpper(cmd)
+		if cmd == "QUIT" {
+			c.reply(221, "Bye")
+			return
+		}
+		c.handle(cmd, arg)
+	}
+}
+
+// handle 分发命令
+func (c *session) handle(cmd, arg string) {
+	switch cmd {
+	case "USER", "PASS", "AUTH", "PBSZ", "PROT", "FEAT", "SYST", "NOOP", "OPTS":
+	default:
+		if !c.authed {
+			c.reply(530, "Please login with USER and PASS")
+			return
+		}
+	}
+	switch cmd {
+	case "USER":
+		c.user, c.authed = arg, false
+		c.reply(331, "Password required")
+	case "PASS":
+		if c.login(arg) {
+			c.authed = true
+			c.reply(230, "Login successful")
+		} else {
+			c.reply(530, "Login incorrect")
+		}
+	case "AUTH":
+		c.cmdAuth(arg)
+	case "PBSZ":
+		c.reply(200, "PBSZ=0")
+	case "PROT":
+		c.cmdProt(arg)
+	case "FEAT":
+		c.cmdFeat()
+	case "SYST":
+		c.reply(215, "UNIX Type: L8")
+	case "NOOP":
+		c.reply(200, "OK")
+	case "OPTS":
+		c.reply(200, "OK")
+	case "TYPE", "MODE", "STRU":
+		c.reply(200, "OK")
+	case "PWD", "XPWD":
+		c.reply(257, strconv.Quote(c.cwd)+" is current directory")
+	case "CWD", "XCWD":
+		c.cmdCwd(arg)
+	case "CDUP", "XCUP":
+		c.cmdCwd("..")
+	case "PASV":
+		c.cmdPasv(false)
+	case "EPSV":
+		c.cmdPasv(true)
+	case "PORT":
+		c.cmdPort(arg)
+	case "EPRT":
+		c.cmdEprt(arg)
+	case "LIST", "NLST", "MLSD":
+		c.cmdList(cmd, arg)
+	case "MLST":
+		c.cmdMlst(arg)
+	case "SIZE":
+		c.cmdSize(arg)
+	case "MDTM":
+		c.cmdMdtm(arg)
+	case "REST":
+		c.cmdRest(arg)
+	case "RETR":
+		c.cmdRetr(arg)
+	case "STOR", "APPE":
+		c.cmdStor(cmd, arg)
+	case "DELE", "RMD", "XRMD", "MKD", "XMKD", "RNFR", "RNTO":
+		c.cmdWrite(cmd, arg)
+	case "ABOR":
+		c.closeData()
+		c.reply(226, "Abort successful")
+	default:
+		c.reply(502, "Command not implemented")
+	}
+}
+
+// resolve 虚拟路径 -> (规范虚拟路径, 本地路径)，不会越出根目录；
+// 不允许访问、多根目录的顶层虚拟目录或根目录名不存在时本地路径为空
+func (c *session) resolve(p string) (string, string) {
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(c.cwd, p)
+	}
+	p = path.Clean("/" + p)
+	file, ok := c.srv.roots().Resolve(p)
+	if !ok || c.srv.Allow != nil && !c.srv.Allow(file) {
+		return p, ""
+	}
+	return p, file
+}
+
+// stat 虚拟路径的文件信息，多根目录的顶层为虚拟目录
+func (c *session) stat(vp, file string) (os.FileInfo, error) {
+	if c.srv.roots().IsTop(vp) {
+		return c.srv.roots().Top(), nil
+	}
+	return os.Stat(file)
+}
+
+// fixed 是否为不能写入、删除或重命名的路径: 顶层虚拟目录、各根目录本身或不允许访问的路径
+func (c *session) fixed(file string) bool {
+	return file == "" || c.srv.roots().IsRoot(file)
+}
+
+func (c *session) changed(cmd, file, dest string, size int64) {
+	if c.srv.OnChange != nil {
+		c.srv.OnChange(c.client, cmd, file, dest, size)
+	}
+}
+
+func (c *session) cmdAuth(arg string) {
+	if c.srv.TLSConfig == nil || !strings.EqualFold(arg, "TLS") && !strings.EqualFold(arg, "SSL") {
+		c.reply(504, "AUTH not supported")
+		return
+	}
+	c.reply(234, "AUTH TLS successful")
+	tc := tls.Server(c.conn, c.srv.TLSConfig)
+	if err := tc.Handshake(); err != nil {
+		_ = c.conn.Close()
+		return
+	}
+	c.conn = tc
+	c.reader = bufio.NewReader(tc)
+}
+
+func (c *session) cmdProt(arg string) {
+	switch strings.ToUpper(arg) {
+	case "C":
+		c.private = false
+		c.reply(200, "PROT C")
+	case "P":
+		if _, ok := c.conn.(*tls.Conn); !ok {
+			c.reply(503, "PROT P requires AUTH TLS")
+			return
+		}
+		c.private = true
+		c.reply(200, "PROT P")
+	default:
+		c.reply(504, "PROT not supported")
+	}
+}
+
+func (c *session) cmdFeat() {
+	feats := []string{"EPSV", "EPRT", "MDTM", "MLST type*;size*;modify*;", "PASV", "REST STREAM", "SIZE", "UTF8"}
+	if c.srv.TLSConfig != nil {
+		feats = append(feats, "AUTH TLS", "PBSZ", "PROT")
+	}
+	_, _ = fmt.Fprint(c.conn, "211-Features:\r\n")
+	for _, f := range feats {
+		_, _ = fmt.Fprintf(c.conn, " %s\r\n", f)
+	}
+	c.reply(211, "End")
+}
+
+func (c *session) cmdCwd(arg string) {
+	vp, file := c.resolve(arg)
+	if info, err := c.stat(vp, file); err != nil || !info.IsDir() {
+		c.reply(550, "No such directory")
+		return
+	}
+	c.cwd = vp
+	c.reply(250, "Directory changed to "+vp)
+}
+
+func (c *session) cmdPasv(extended bool) {
+	c.closeData()
+	host, _, _ := net.SplitHostPort(c.conn.LocalAddr().String())
+	l, err := c.srv.listenPassive(host)
+	if err != nil {
+		c.reply(425, err.Error())
+		return
+	}
+	c.pasv = l
+	port := l.Addr().(*net.TCPAddr).Port
+	if extended {
+		c.reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", port))
+		return
+	}
+	ip := net.ParseIP(c.srv.PublicIP)
+	if ip == nil {
+		ip = net.ParseIP(host)
+	}
+	if ip = ip.To4(); ip == nil {
+		c.closeData()
+		c.reply(425, "PASV requires IPv4, use EPSV")
+		return
+	}
+	c.reply(227, fmt.Sprintf("Entering Passive Mode (%d,%d,%d,%d,%d,%d)",
+		ip[0], ip[1], ip[2], ip[3], port>>8, port&0xff))
+}
+
+func (c *session) cmdPort(arg string) {
+	p := strings.Split(arg, ",")
+	if len(p) != 6 {
+		c.reply(501, "Syntax error")
+		return
+	}
+	hi, _ := strconv.Atoi(p[4])
+	lo, _ := strconv.Atoi(p[5])
+	c.closeData()
+	c.port = net.JoinHostPort(strings.Join(p[:4], "."), strconv.Itoa(hi<<8|lo))
+	c.reply(200, "PORT command successful")
+}
+
+func (c *session) cmdEprt(arg string) {
+	if len(arg) < 4 {
+		c.reply(501, "Syntax error")
+		return
+	}
+	p := strings.Split(arg[1:len(arg)-1], arg[:1])
+	if len(p) != 3 {
+		c.reply(501, "Syntax error")
+		return
+	}
+	c.closeData()
+	c.port = net.JoinHostPort(p[1], p[2])
+	c.reply(200, "EPRT command successful")
+}
+
+// openData 建立数据连接
+func (c *session) openData() (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	switch {
+	case c.pasv != nil:
+		if tl, ok := c.pasv.(*net.TCPListener); ok {
+			_ = tl.SetDeadline(time.Now().Add(30 * time.Second))
+		}
+		conn, err = c.pasv.Accept()
+		_ = c.pasv.Close()
+		c.pasv = nil
+	case c.port != "":
+		// 只允许连回客户端自身，防止FTP bounce攻击
+		if host, _, _ := net.SplitHostPort(c.port); !net.ParseIP(host).Equal(net.ParseIP(c.client)) {
+			c.port = ""
+			return nil, errors.New("PORT address must match client address")
+		}
+		conn, err = net.DialTimeout("tcp", c.port, 30*time.Second)
+		c.port = ""
+	default:
+		err = errors.New("use PASV or PORT first")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.private {
+		tc := tls.Server(conn, c.srv.TLSConfig)
+		if err := tc.Handshake(); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+	return conn, nil
+}
+
+func (c *session) closeData() {
+	if c.pasv != nil {
+		_ = c.pasv.Close()
+		c.pasv = nil
+	}
+	c.port = ""
+}
+
+// transfer 打开数据连接并执行fn
+func (c *session) transfer(fn func(conn net.Conn) error) {
+	c.reply(150, "Opening data connection")
+	conn, err := c.openData()
+	if err != nil {
+		c.reply(425, "Can't open data connection: "+err.Error())
+		return
+	}
+	if c.srv.Busy != nil {
+		defer c.srv.Busy()()
+	}
+	err = fn(conn)
+	_ = conn.Close()
+	if err != nil {
+		c.reply(426, "Transfer aborted: "+err.Error())
+		return
+	}
+	c.reply(226, "Transfer complete")
+}
+
+func (c *session) cmdList(cmd, arg string) {
+	// 忽略 ls 风格的参数(-a/-l)
+	if strings.HasPrefix(arg, "-") {
+		f := strings.Fields(arg)
+		arg = ""
+		if len(f) > 1 {
+			arg = f[len(f)-1]
+		}
+	}
+	vp, file := c.resolve(arg)
+	info, err := c.stat(vp, file)
+	if err != nil {
+		c.reply(550, "No such file or directory")
+		return
+	}
+	var infos []os.FileInfo
+	if file == "" {
+		infos = c.srv.roots().List()
+	} else if info.IsDir() {
+		list, err := ioutil.ReadDir(file)
+		if err != nil {
+			c.reply(550, err.Error())
+			return
+		}
+		for _, fi := range list {
+			if strings.HasPrefix(fi.Name(), ".") {
+				continue
+			}
+			child := filepath.Join(file, fi.Name())
+			if c.srv.Allow != nil && !c.srv.Allow(child) {
+				continue
+			}
+			// 允许访问的符号链接按目标显示
+			if fi.Mode()&os.ModeSymlink != 0 {
+				if fi, err = os.Stat(child); err != nil {
+					continue
+				}
+			}
+			infos = append(infos, fi)
+		}
+	} else {
+		infos = append(infos, info)
+	}
+	c.transfer(func(conn net.Conn) error {
+		w := bufio.NewWriter(conn)
+		for _, fi := range infos {
+			switch cmd {
+			case "NLST":
+				_, _ = fmt.Fprintf(w, "%s\r\n", fi.Name())
+			case "MLSD":
+				_, _ = fmt.Fprintf(w, "%s %s\r\n", factLine(fi), fi.Name())
+			default:
+				_, _ = fmt.Fprintf(w, "%s\r\n", listLine(fi))
+			}
+		}
+		return w.Flush()
+	})
+}
+
+func (c *session) cmdMlst(arg string) {
+	vp, file := c.resolve(arg)
+	info, err := c.stat(vp, file)
+	if err != nil {
+		c.reply(550, "No such file or directory")
+		return
+	}
+	_, _ = fmt.Fprintf(c.conn, "250-Listing %s\r\n %s %s\r\n", vp, factLine(info), vp)
+	c.reply(250, "End")
+}
+
+// listLine ls -l 格式
+func listLine(fi os.FileInfo) string {
+	mode := "-rw-r--r--"
+	if fi.IsDir() {
+		mode = "drwxr-xr-x"
+	}
+	t := fi.ModTime()
+	stamp := t.Format("Jan _2 15:04")
+	if time.Since(t) > 180*24*time.Hour || t.After(time.Now()) {
+		stamp = t.Format("Jan _2  2006")
+	}
+	return fmt.Sprintf("%s 1 ftp ftp %12d %s %s", mode, fi.Size(), stamp, fi.Name())
+}
+
+// factLine MLSD/MLST 事实行
+func factLine(fi os.FileInfo) string {
+	typ := "file"
+	if fi.IsDir() {
+		typ = "dir"
+	}
+	return fmt.Sprintf("type=%s;size=%d;modify=%s;", typ, fi.Size(), fi.ModTime().UTC().Format("20060102150405"))
+}
+
+func (c *session) cmdSize(arg string) {
+	_, file := c.resolve(arg)
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		c.reply(550, "No such file")
+		return
+	}
+	c.reply(213, strconv.FormatInt(info.Size(), 10))
+}
+
+func (c *session) cmdMdtm(arg string) {
+	_, file := c.resolve(arg)
+	info, err := os.Stat(file)
+	if err != nil {
+		c.reply(550, "No such file")
+		return
+	}
+	c.reply(213, info.ModTime().UTC().Format("20060102150405"))
+}
+
+func (c *session) cmdRest(arg string) {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		c.reply(501, "Invalid offset")
+		return
+	}
+	c.rest = n
+	c.reply(350, "Restarting at "+arg)
+}
+
+func (c *session) cmdRetr(arg string) {
+	offset := c.rest
+	c.rest = 0
+	_, file := c.resolve(arg)
+	f, err := os.Open(file)
+	if err != nil {
+		c.reply(550, "No such file")
+		return
+	}
+	defer func() { _ = f.Close() }()
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		c.reply(550, "Not a file")
+		return
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		c.reply(550, err.Error())
+		return
+	}
+	c.transfer(func(conn net.Conn) error {
+		_, err := bufpool.Copy(conn, f)
+		return err
+	})
+}
+
+// cmdStor 上传: 新文件经暂存目录写入后移动到目标位置，REST/APPE 时直接在原文件上续写
+func (c *session) cmdStor(cmd, arg string) {
+	offset := c.rest
+	c.rest = 0
+	if !c.writable() {
+		c.reply(550, "Permission denied")
+		return
+	}
+	_, file := c.resolve(arg)
+	if c.fixed(file) {
+		c.reply(553, "Invalid file name")
+		return
+	}
+	if !c.writableAt(file) {
+		c.reply(550, "Permission denied")
+		return
+	}
+	if cmd == "APPE" || offset > 0 {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			c.reply(550, err.Error())
+			return
+		}
+		defer func() { _ = f.Close() }()
+		if cmd == "APPE" {
+			_, err = f.Seek(0, io.SeekEnd)
+		} else {
+			_, err = f.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			c.reply(550, err.Error())
+			return
+		}
+		c.transfer(func(conn net.Conn) error {
+			_, err := bufpool.Copy(f, conn)
+			return err
+		})
+		if info, err := f.Stat(); err == nil {
+			c.changed("STOR", file, "", info.Size())
+		}
+		return
+	}
+	tmp, err := fileinfos.CreateTemp(c.srv.TempDir, filepath.Base(file))
+	if err != nil {
+		c.reply(550, err.Error())
+		return
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	var n int64
+	c.transfer(func(conn net.Conn) error {
+		n, err = bufpool.Copy(tmp, conn)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = fileinfos.Commit(tmp.Name(), file)
+		}
+		return err
+	})
+	if err == nil {
+		c.changed("STOR", file, "", n)
+	}
+}
+
+// cmdWrite 删除、建目录、重命名
+func (c *session) cmdWrite(cmd, arg string) {
+	if !c.writable() {
+		c.reply(550, "Permission denied")
+		return
+	}
+	vp, file := c.resolve(arg)
+	if vp == "/" || c.fixed(file) {
+		c.reply(550, "Permission denied")
+		return
+	}
+	var err error
+	switch cmd {
+	case "DELE":
+		var info os.FileInfo
+		if info, err = os.Stat(file); err == nil && info.IsDir() {
+			c.reply(550, "Is a directory")
+			return
+		}
+		if err == nil {
+			err = c.srv.remove(file)
+		}
+		if err == nil {
+			c.changed(cmd, file, "", 0)
+		}
+	case "RMD", "XRMD":
+		if err = c.srv.remove(file); err == nil {
+			c.changed("RMD", file, "", 0)
+		}
+	case "MKD", "XMKD":
+		if err = os.MkdirAll(file, 0755); err == nil {
+			c.reply(257, strconv.Quote(vp)+" created")
+			return
+		}
+	case "RNFR":
+		if _, err = os.Stat(file); err == nil {
+			c.renameFrom = file
+			c.reply(350, "Ready for RNTO")
+			return
+		}
+	case "RNTO":
+		from := c.renameFrom
+		c.renameFrom = ""
+		if from == "" {
+			c.reply(503, "RNFR required first")
+			return
+		}
+		if !c.writableAt(file) {
+			c.reply(550, "Permission denied")
+			return
+		}
+		if err = os.Rename(from, file); err == nil {
+			var size int64
+			if info, e := os.Stat(file); e == nil {
+				size = info.Size()
+			}
+			c.changed(cmd, from, file, size)
+		}
+	}
+	if err != nil {
+		logger.Warn("ftp", "command failed", "cmd", cmd, "err", err)
+		c.reply(550, "Operation failed")
+		return
+	}
+	c.reply(250, "OK")
+}
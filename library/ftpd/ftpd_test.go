@@ -0,0 +1,192 @@
+package ftpd
+
+import (
+	"b0pass/library/roots"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pasv 进入被动模式并建立数据连接
+func pasv(t *testing.T, c *textproto.Conn) net.Conn {
+	_ = c.PrintfLine("EPSV")
+	_, msg, err := c.ReadResponse(229)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var port int
+	_, _ = fmt.Sscanf(msg[strings.Index(msg, "|||"):], "|||%d|", &port)
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func cmd(t *testing.T, c *textproto.Conn, code int, format string, args ...interface{}) string {
+	_ = c.PrintfLine(format, args...)
+	_, msg, err := c.ReadResponse(code)
+	if err != nil {
+		t.Fatalf("%s: %v", format, err)
+	}
+	return msg
+}
+
+func TestServer(t *testing.T) {
+	root, _ := ioutil.TempDir("", "ftpd")
+	defer func() { _ = os.RemoveAll(root) }()
+	var changes []string
+	s := &Server{Root: root, TempDir: filepath.Join(root, ".tmp"), User: "u", Password: "p",
+		OnChange: func(client, cmd, file, dest string, size int64) {
+			changes = append(changes, cmd)
+		}}
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() { _ = s.Serve(l) }()
+	defer func() { _ = s.Close() }()
+
+	c, err := textproto.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	if _, _, err := c.ReadResponse(220); err != nil {
+		t.Fatal(err)
+	}
+	cmd(t, c, 530, "PWD")
+	cmd(t, c, 331, "USER u")
+	cmd(t, c, 530, "PASS x")
+	cmd(t, c, 331, "USER u")
+	cmd(t, c, 230, "PASS p")
+	cmd(t, c, 257, "MKD sub")
+	cmd(t, c, 250, "CWD sub")
+
+	// 上传
+	data := pasv(t, c)
+	_ = c.PrintfLine("STOR a.txt")
+	_, _, _ = c.ReadResponse(150)
+	_, _ = data.Write([]byte("hello ftp"))
+	_ = data.Close()
+	if _, _, err := c.ReadResponse(226); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(root, "sub", "a.txt")); string(b) != "hello ftp" {
+		t.Errorf("bad content %q", b)
+	}
+	if msg := cmd(t, c, 213, "SIZE /sub/a.txt"); msg != "9" {
+		t.Errorf("bad size %s", msg)
+	}
+
+	// 列表与断点下载
+	data = pasv(t, c)
+	_ = c.PrintfLine("NLST")
+	_, _, _ = c.ReadResponse(150)
+	list, _ := ioutil.ReadAll(data)
+	_, _, _ = c.ReadResponse(226)
+	if strings.TrimSpace(string(list)) != "a.txt" {
+		t.Errorf("bad list %q", list)
+	}
+	cmd(t, c, 350, "REST 6")
+	data = pasv(t, c)
+	_ = c.PrintfLine("RETR a.txt")
+	_, _, _ = c.ReadResponse(150)
+	b, _ := ioutil.ReadAll(data)
+	_, _, _ = c.ReadResponse(226)
+	if string(b) != "ftp" {
+		t.Errorf("bad retr %q", b)
+	}
+
+	// 不能越出根目录
+	cmd(t, c, 250, "CWD ../../..")
+	if msg := cmd(t, c, 257, "PWD"); !strings.HasPrefix(msg, `"/"`) {
+		t.Errorf("escaped root: %s", msg)
+	}
+
+	cmd(t, c, 350, "RNFR sub/a.txt")
+	cmd(t, c, 250, "RNTO b.txt")
+	cmd(t, c, 550, "DELE /sub")
+	cmd(t, c, 250, "DELE b.txt")
+	if strings.Join(changes, ",") != "STOR,RNTO,DELE" {
+		t.Errorf("bad changes %v", changes)
+	}
+
+	s.ReadOnly = true
+	cmd(t, c, 550, "MKD other")
+	cmd(t, c, 221, "QUIT")
+}
+
+func TestLogin(t *testing.T) {
+	root, _ := ioutil.TempDir("", "ftpd")
+	defer func() { _ = os.RemoveAll(root) }()
+	s := &Server{Root: root, TempDir: filepath.Join(root, ".tmp"),
+		Login: func(client, user, password string) (string, bool) {
+			return user, password == "pin"
+		},
+		CanWrite: func(role string) bool { return role != "guest" },
+	}
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() { _ = s.Serve(l) }()
+	defer func() { _ = s.Close() }()
+
+	c, err := textproto.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	_, _, _ = c.ReadResponse(220)
+	// 设置Login后不再允许匿名登录
+	cmd(t, c, 331, "USER guest")
+	cmd(t, c, 530, "PASS x")
+	cmd(t, c, 331, "USER guest")
+	cmd(t, c, 230, "PASS pin")
+	cmd(t, c, 550, "MKD sub")
+	cmd(t, c, 331, "USER user")
+	cmd(t, c, 230, "PASS pin")
+	cmd(t, c, 257, "MKD sub")
+}
+
+func TestRoots(t *testing.T) {
+	a, _ := ioutil.TempDir("", "ftpd-a")
+	b, _ := ioutil.TempDir("", "ftpd-b")
+	defer func() { _ = os.RemoveAll(a); _ = os.RemoveAll(b) }()
+	s := &Server{Roots: roots.Set{{Name: "A", Path: a}, {Name: "B", Path: b}}, TempDir: filepath.Join(a, ".tmp")}
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() { _ = s.Serve(l) }()
+	defer func() { _ = s.Close() }()
+
+	c, err := textproto.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	_, _, _ = c.ReadResponse(220)
+	cmd(t, c, 331, "USER anonymous")
+	cmd(t, c, 230, "PASS x")
+
+	// 顶层列出各根目录
+	data := pasv(t, c)
+	_ = c.PrintfLine("NLST")
+	_, _, _ = c.ReadResponse(150)
+	list, _ := ioutil.ReadAll(data)
+	_, _, _ = c.ReadResponse(226)
+	if strings.Join(strings.Fields(string(list)), ",") != "A,B" {
+		t.Errorf("bad top list %q", list)
+	}
+	cmd(t, c, 553, "STOR top.txt")
+	cmd(t, c, 550, "MKD C")
+	cmd(t, c, 550, "RMD A")
+	cmd(t, c, 250, "CWD /B")
+	cmd(t, c, 257, "MKD sub")
+	if info, err := os.Stat(filepath.Join(b, "sub")); err != nil || !info.IsDir() {
+		t.Errorf("MKD in second root: %v", err)
+	}
+	cmd(t, c, 350, "RNFR sub")
+	cmd(t, c, 250, "RNTO /A/sub")
+	if _, err := os.Stat(filepath.Join(a, "sub")); err != nil {
+		t.Error(err)
+	}
+}
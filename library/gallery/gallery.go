@@ -0,0 +1,146 @@
+package gallery
+
+import (
+	"b0pass/library/fsync"
+	"b0pass/library/thumbs"
+	"encoding/json"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 相册元数据: 目录中各图片的尺寸、拍摄时间与EXIF方向，首次请求时读取并缓存，文件变化后重新读取
+
+// Image 图片元数据
+type Image struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	MTime       int64  `json:"mtime"`
+	Width       int    `json:"width"`       // 按EXIF方向旋转后的显示宽度，无法读取时为0
+	Height      int    `json:"height"`      // 按EXIF方向旋转后的显示高度
+	Orientation int    `json:"orientation"` // EXIF方向(1~8)
+	Taken       string `json:"taken"`       // 拍摄时间(RFC3339)，没有EXIF时间时为空
+}
+
+// Cache 图片元数据缓存，key为文件绝对路径，大小或修改时间变化后重新读取
+type Cache struct {
+	// Dimensions 标准库不支持的格式(如HEIC)的尺寸，为nil或出错时为0
+	Dimensions func(file string) (int, int, error)
+
+	file  string
+	mu    sync.Mutex
+	items map[string]Image
+	dirty bool
+}
+
+// Open 打开持久化文件
+func Open(file string) *Cache {
+	c := &Cache{file: file, items: make(map[string]Image)}
+	if b, err := ioutil.ReadFile(file); err == nil {
+		_ = json.Unmarshal(b, &c.items)
+	}
+	return c
+}
+
+// Dir 目录中全部图片(thumbs.Supported)的元数据，按拍摄时间排序，没有拍摄时间的按修改时间
+func (c *Cache) Dir(dir string) ([]Image, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Image, 0, len(infos))
+	seen := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") || !thumbs.Supported(info.Name()) {
+			continue
+		}
+		file := filepath.Join(dir, info.Name())
+		seen[file] = true
+		list = append(list, c.Get(file, info))
+	}
+	// 移除目录中已不存在的图片
+	c.mu.Lock()
+	for file := range c.items {
+		if filepath.Dir(file) == filepath.Clean(dir) && !seen[file] {
+			delete(c.items, file)
+			c.dirty = true
+		}
+	}
+	c.mu.Unlock()
+	sort.SliceStable(list, func(i, j int) bool {
+		ti, tj := list[i].time(), list[j].time()
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// time 排序用的时间
+func (img Image) time() time.Time {
+	if t, err := time.Parse(time.RFC3339, img.Taken); err == nil {
+		return t
+	}
+	return time.Unix(img.MTime, 0)
+}
+
+// Get 一个图片的元数据，缓存中的大小与修改时间一致时直接返回
+func (c *Cache) Get(file string, info os.FileInfo) Image {
+	c.mu.Lock()
+	img, ok := c.items[file]
+	c.mu.Unlock()
+	if ok && img.Size == info.Size() && img.MTime == info.ModTime().Unix() {
+		return img
+	}
+	img = c.read(file, info)
+	c.mu.Lock()
+	c.items[file] = img
+	c.dirty = true
+	c.mu.Unlock()
+	return img
+}
+
+// read 读取尺寸与EXIF信息
+func (c *Cache) read(file string, info os.FileInfo) Image {
+	exif := thumbs.ReadExif(file)
+	img := Image{Name: info.Name(), Size: info.Size(), MTime: info.ModTime().Unix(), Orientation: exif.Orientation}
+	if !exif.Taken.IsZero() {
+		img.Taken = exif.Taken.Format(time.RFC3339)
+	}
+	if fd, err := os.Open(file); err == nil {
+		if cfg, _, err := image.DecodeConfig(fd); err == nil {
+			img.Width, img.Height = cfg.Width, cfg.Height
+		}
+		_ = fd.Close()
+	}
+	if img.Width == 0 && c.Dimensions != nil {
+		img.Width, img.Height, _ = c.Dimensions(file)
+	}
+	if img.Orientation >= 5 {
+		img.Width, img.Height = img.Height, img.Width
+	}
+	return img
+}
+
+// Save 有变化时持久化到文件，原子替换文件
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty || c.file == "" {
+		return nil
+	}
+	if err := fsync.WriteJSON(c.file, c.items, 0644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
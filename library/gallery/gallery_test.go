@@ -0,0 +1,67 @@
+package gallery
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gallery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	writePNG := func(name string, w, h int, mtime time.Time) {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = png.Encode(f, image.NewRGBA(image.Rect(0, 0, w, h)))
+		_ = f.Close()
+		_ = os.Chtimes(f.Name(), mtime, mtime)
+	}
+	now := time.Now()
+	writePNG("b.png", 30, 20, now.Add(-time.Hour))
+	writePNG("a.png", 10, 40, now)
+	_ = ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "broken.jpg"), []byte("x"), 0644)
+	_ = os.Chtimes(filepath.Join(dir, "broken.jpg"), now.Add(-time.Minute), now.Add(-time.Minute))
+
+	db := filepath.Join(dir, ".gallery.json")
+	c := Open(db)
+	c.Dimensions = func(string) (int, int, error) { return 7, 9, nil }
+	list, err := c.Dir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按修改时间排序，无法解码的图片使用Dimensions
+	if len(list) != 3 || list[0].Name != "b.png" || list[2].Name != "a.png" {
+		t.Fatalf("%+v", list)
+	}
+	if list[0].Width != 30 || list[0].Height != 20 || list[0].Orientation != 1 || list[0].Taken != "" {
+		t.Errorf("%+v", list[0])
+	}
+	if list[1].Name != "broken.jpg" || list[1].Width != 7 {
+		t.Errorf("%+v", list[1])
+	}
+
+	// 缓存未变化的文件，已删除的移除
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Remove(filepath.Join(dir, "broken.jpg"))
+	c2 := Open(db)
+	c2.items[filepath.Join(dir, "a.png")] = Image{Name: "cached", Size: list[2].Size, MTime: list[2].MTime}
+	list, _ = c2.Dir(dir)
+	if len(list) != 2 || list[1].Name != "cached" {
+		t.Errorf("%+v", list)
+	}
+	if _, ok := c2.items[filepath.Join(dir, "broken.jpg")]; ok || len(c2.items) != 2 {
+		t.Error("not pruned", c2.items)
+	}
+}
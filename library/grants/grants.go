@@ -0,0 +1,99 @@
+package grants
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 临时授权: 只读模式下允许指定访客设备在一段时间内上传、删除
+
+// Grant 授权记录
+type Grant struct {
+	Device  string    `json:"device"`
+	By      string    `json:"by"`
+	Granted time.Time `json:"granted"`
+	Expires time.Time `json:"expires"`
+}
+
+// Store 授权表，仅保存在内存中，服务重启后全部失效
+type Store struct {
+	mu     sync.Mutex
+	grants map[string]Grant
+}
+
+// New 创建授权表
+func New() *Store {
+	return &Store{grants: make(map[string]Grant)}
+}
+
+// Grant 授权设备d时长，已有授权时延长到新的到期时间
+func (s *Store) Grant(device, by string, d time.Duration) Grant {
+	now := time.Now()
+	g := Grant{Device: device, By: by, Granted: now, Expires: now.Add(d)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grants[device] = g
+	return g
+}
+
+// Revoke 撤销授权
+func (s *Store) Revoke(device string) (Grant, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.grants[device]
+	delete(s.grants, device)
+	return g, ok
+}
+
+// Allowed 设备当前是否有授权
+func (s *Store) Allowed(device string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.grants[device]
+	return ok && now.Before(g.Expires)
+}
+
+// Expire 移除已到期的授权并返回，由定时任务调用以记录自动撤销
+func (s *Store) Expire(now time.Time) []Grant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []Grant
+	for k, g := range s.grants {
+		if !now.Before(g.Expires) {
+			expired = append(expired, g)
+			delete(s.grants, k)
+		}
+	}
+	return expired
+}
+
+// List 当前的授权，按到期时间排序
+func (s *Store) List() []Grant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Grant, 0, len(s.grants))
+	for _, g := range s.grants {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Expires.Before(list[j].Expires) })
+	return list
+}
+
+// Restore 恢复保存的授权，已到期的忽略，返回恢复的个数
+func (s *Store) Restore(list []Grant, now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, g := range list {
+		if g.Device == "" || !now.Before(g.Expires) {
+			continue
+		}
+		if old, ok := s.grants[g.Device]; ok && old.Expires.After(g.Expires) {
+			continue
+		}
+		s.grants[g.Device] = g
+		n++
+	}
+	return n
+}
@@ -0,0 +1,45 @@
+package grants
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGrants(t *testing.T) {
+	s := New()
+	now := time.Now()
+	g := s.Grant("10.0.0.2", "127.0.0.1", 10*time.Minute)
+	if !s.Allowed("10.0.0.2", now) || s.Allowed("10.0.0.3", now) {
+		t.Fatal("allowed")
+	}
+	if s.Allowed("10.0.0.2", g.Expires) {
+		t.Error("allowed after expiry")
+	}
+	s.Grant("10.0.0.3", "127.0.0.1", time.Minute)
+	if list := s.List(); len(list) != 2 || list[0].Device != "10.0.0.3" {
+		t.Error(list)
+	}
+	expired := s.Expire(now.Add(5 * time.Minute))
+	if len(expired) != 1 || expired[0].Device != "10.0.0.3" || len(s.List()) != 1 {
+		t.Error(expired)
+	}
+	if _, ok := s.Revoke("10.0.0.2"); !ok || s.Allowed("10.0.0.2", now) {
+		t.Error("revoke")
+	}
+	if _, ok := s.Revoke("10.0.0.2"); ok {
+		t.Error("revoke twice")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	src := New()
+	src.Grant("10.0.0.2", "127.0.0.1", time.Hour)
+	src.Grant("10.0.0.3", "127.0.0.1", time.Minute)
+	s := New()
+	if n := s.Restore(src.List(), time.Now().Add(30*time.Minute)); n != 1 {
+		t.Errorf("restored %d", n)
+	}
+	if !s.Allowed("10.0.0.2", time.Now()) || s.Allowed("10.0.0.3", time.Now()) {
+		t.Error(s.List())
+	}
+}
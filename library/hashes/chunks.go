@@ -0,0 +1,96 @@
+package hashes
+
+import (
+	"b0pass/library/bufpool"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"os"
+	"strings"
+)
+
+// DefaultChunkSize 默认分块大小
+const DefaultChunkSize = 4 << 20
+
+// Chunker 按固定大小分块计算SHA-256，用于定位大文件中损坏的部分
+type Chunker struct {
+	size int64
+	n    int64
+	h    hash.Hash
+	sums []string
+}
+
+// NewChunker 创建分块哈希计算器，size<=0时为DefaultChunkSize
+func NewChunker(size int64) *Chunker {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	return &Chunker{size: size, h: sha256.New()}
+}
+
+// Write 实现io.Writer
+func (c *Chunker) Write(p []byte) (int, error) {
+	total := len(p)
+	for len(p) > 0 {
+		n := c.size - c.n
+		if n > int64(len(p)) {
+			n = int64(len(p))
+		}
+		_, _ = c.h.Write(p[:n])
+		c.n += n
+		p = p[n:]
+		if c.n == c.size {
+			c.sums = append(c.sums, hex.EncodeToString(c.h.Sum(nil)))
+			c.h.Reset()
+			c.n = 0
+		}
+	}
+	return total, nil
+}
+
+// Sums 各分块的十六进制哈希，最后不足一块的部分单独成块
+func (c *Chunker) Sums() []string {
+	sums := append([]string{}, c.sums...)
+	if c.n > 0 {
+		sums = append(sums, hex.EncodeToString(c.h.Sum(nil)))
+	}
+	return sums
+}
+
+// FileChunks 计算文件的分块哈希
+func FileChunks(file string, size int64) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	c := NewChunker(size)
+	if _, err := bufpool.Copy(c, f); err != nil {
+		return nil, err
+	}
+	return c.Sums(), nil
+}
+
+// DiffChunks 与want不一致(含缺少)的分块序号
+func DiffChunks(want, have []string) []int {
+	var bad []int
+	for i, sum := range want {
+		if i >= len(have) || have[i] != sum {
+			bad = append(bad, i)
+		}
+	}
+	return bad
+}
+
+// JoinChunks 分块哈希编码为逗号分隔的字符串，用于保存到元数据
+func JoinChunks(sums []string) string {
+	return strings.Join(sums, ",")
+}
+
+// SplitChunks 解析JoinChunks的结果
+func SplitChunks(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,11 @@
+package hashes
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// dropCache 丢弃文件的页缓存
+func dropCache(f *os.File) {
+	_ = unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED)
+}
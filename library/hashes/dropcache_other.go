@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package hashes
+
+import "os"
+
+// dropCache 非Linux平台无法可靠丢弃页缓存，仅依赖fsync
+func dropCache(f *os.File) {}
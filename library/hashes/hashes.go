@@ -0,0 +1,120 @@
+package hashes
+
+import (
+	"b0pass/library/bufpool"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// 哈希算法
+const (
+	MD5    = "md5"
+	SHA256 = "sha256"
+	BLAKE3 = "blake3"
+)
+
+// Default 默认哈希算法，秒传/去重以此为准
+const Default = SHA256
+
+// Supported 支持的算法，按服务端优先级排列
+var Supported = []string{BLAKE3, SHA256, MD5}
+
+// New 创建哈希算法实例
+func New(algo string) (hash.Hash, error) {
+	switch algo {
+	case "", SHA256:
+		return sha256.New(), nil
+	case BLAKE3:
+		return NewBLAKE3(), nil
+	case MD5:
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm: %s", algo)
+}
+
+// Negotiate 从客户端可接受的算法列表(逗号分隔)中选出allowed里优先级最高的一个
+// 客户端未声明时使用Default，无共同算法时返回空
+func Negotiate(accept string, allowed []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return Default
+	}
+	client := make(map[string]bool)
+	for _, a := range strings.Split(strings.ToLower(accept), ",") {
+		client[strings.TrimSpace(a)] = true
+	}
+	for _, a := range allowed {
+		if client[a] {
+			return a
+		}
+	}
+	return ""
+}
+
+// Multi 同时计算多种哈希
+type Multi struct {
+	io.Writer
+	hashes map[string]hash.Hash
+}
+
+// NewMulti 创建多哈希计算器，重复或空的算法名会被忽略
+func NewMulti(algos ...string) (*Multi, error) {
+	m := &Multi{hashes: make(map[string]hash.Hash)}
+	var writers []io.Writer
+	for _, algo := range algos {
+		if algo == "" || m.hashes[algo] != nil {
+			continue
+		}
+		h, err := New(algo)
+		if err != nil {
+			return nil, err
+		}
+		m.hashes[algo] = h
+		writers = append(writers, h)
+	}
+	m.Writer = io.MultiWriter(writers...)
+	return m, nil
+}
+
+// Sums 返回各算法的十六进制哈希
+func (m *Multi) Sums() map[string]string {
+	ret := make(map[string]string, len(m.hashes))
+	for algo, h := range m.hashes {
+		ret[algo] = hex.EncodeToString(h.Sum(nil))
+	}
+	return ret
+}
+
+// FileSums 一次读取文件计算多种哈希
+func FileSums(file string, algos ...string) (map[string]string, error) {
+	m, err := NewMulti(algos...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := bufpool.Copy(m, f); err != nil {
+		return nil, err
+	}
+	return m.Sums(), nil
+}
+
+// File 计算文件哈希，返回十六进制字符串
+func File(file, algo string) (string, error) {
+	sums, err := FileSums(file, algo)
+	if err != nil {
+		return "", err
+	}
+	if algo == "" {
+		algo = Default
+	}
+	return sums[algo], nil
+}
@@ -0,0 +1,123 @@
+package hashes
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	f, _ := ioutil.TempFile("", "hash")
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, _ = f.WriteString("abc")
+	_ = f.Close()
+
+	sum, err := File(f.Name(), Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("bad sha256 %s", sum)
+	}
+	if _, err := New("crc64"); err == nil {
+		t.Error("unknown algorithm accepted")
+	}
+
+	sums, err := FileSums(f.Name(), MD5, BLAKE3, SHA256, MD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 3 || sums[MD5] != "900150983cd24fb0d6963f7d28e17f72" || sums[SHA256] != sum {
+		t.Errorf("bad sums %v", sums)
+	}
+	if sums[BLAKE3] != "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85" {
+		t.Errorf("bad blake3 %s", sums[BLAKE3])
+	}
+}
+
+func TestBLAKE3(t *testing.T) {
+	// 官方测试向量: 输入为 i%251 的字节序列
+	vectors := map[int]string{
+		0:    "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262",
+		1024: "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7",
+		1025: "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444",
+		8193: "bab6c09cb8ce8cf459261398d2e7aef35700bf488116ceb94a36d0f5f1b7bc3b",
+	}
+	for n, want := range vectors {
+		in := make([]byte, n)
+		for i := range in {
+			in[i] = byte(i % 251)
+		}
+		h := NewBLAKE3()
+		// 分多次写入，覆盖分块边界
+		for p := in; len(p) > 0; {
+			k := 100
+			if k > len(p) {
+				k = len(p)
+			}
+			_, _ = h.Write(p[:k])
+			p = p[k:]
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			t.Errorf("blake3(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	if a := Negotiate("", Supported); a != Default {
+		t.Errorf("empty accept: %s", a)
+	}
+	if a := Negotiate("md5, SHA256", Supported); a != SHA256 {
+		t.Errorf("want sha256, got %s", a)
+	}
+	if a := Negotiate("md5,blake3", []string{MD5, SHA256}); a != MD5 {
+		t.Errorf("want md5, got %s", a)
+	}
+	if a := Negotiate("crc32", Supported); a != "" {
+		t.Errorf("want none, got %s", a)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	f, _ := ioutil.TempFile("", "verify")
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, _ = f.WriteString("abc")
+	_ = f.Close()
+
+	if err := Verify(f.Name(), SHA256, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"); err != nil {
+		t.Error(err)
+	}
+	if err := Verify(f.Name(), SHA256, "00"); err != ErrVerify {
+		t.Errorf("want ErrVerify, got %v", err)
+	}
+}
+
+func TestChunks(t *testing.T) {
+	f, _ := ioutil.TempFile("", "chunks")
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, _ = f.WriteString("abcabcab")
+	_ = f.Close()
+
+	sums, err := FileChunks(f.Name(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// abc abc ab
+	if len(sums) != 3 || sums[0] != sums[1] || sums[0] != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" || sums[2] == sums[0] {
+		t.Fatalf("bad chunks %v", sums)
+	}
+	c := NewChunker(3)
+	_, _ = c.Write([]byte("ab"))
+	_, _ = c.Write([]byte("cabXab"))
+	if bad := DiffChunks(sums, c.Sums()); len(bad) != 1 || bad[0] != 1 {
+		t.Errorf("bad diff %v", bad)
+	}
+	if bad := DiffChunks(sums, sums[:1]); len(bad) != 2 {
+		t.Errorf("missing chunks not reported %v", bad)
+	}
+	if got := SplitChunks(JoinChunks(sums)); len(got) != 3 || got[2] != sums[2] {
+		t.Error(got)
+	}
+}
@@ -0,0 +1,36 @@
+package hashes
+
+import (
+	"b0pass/library/bufpool"
+	"encoding/hex"
+	"errors"
+	"os"
+)
+
+// ErrVerify 回读校验失败
+var ErrVerify = errors.New("verify after write failed: content mismatch")
+
+// Verify 将文件刷入磁盘后从磁盘回读并校验哈希
+// 先fsync再丢弃页缓存，尽量读到磁盘上的真实数据，以发现静默的磁盘或线缆错误
+func Verify(file, algo, want string) error {
+	h, err := New(algo)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	dropCache(f)
+	if _, err := bufpool.Copy(h, f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != want {
+		return ErrVerify
+	}
+	return nil
+}
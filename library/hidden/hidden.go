@@ -0,0 +1,86 @@
+package hidden
+
+import (
+	"path"
+	"strings"
+)
+
+// 隐藏文件: 目录列表与打包下载中不显示点文件、各系统自动生成的文件(.DS_Store、Thumbs.db等)与自定义的通配符，
+// 避免界面被这些文件占满；只影响列表与打包，不阻止按路径直接访问
+
+// System 各系统自动生成的文件，名称不区分大小写
+var System = []string{
+	".DS_Store", "._*", ".AppleDouble", ".Spotlight-V100", ".Trashes", ".fseventsd", ".TemporaryItems", // macOS
+	"Thumbs.db", "ehthumbs.db", "desktop.ini", "$RECYCLE.BIN", "System Volume Information", // Windows
+	".directory", ".Trash-*", // Linux桌面
+}
+
+// Rules 隐藏规则
+type Rules struct {
+	Dotfiles bool     // 隐藏以.开头的文件与目录
+	System   bool     // 隐藏System中的文件
+	Patterns []string // 自定义通配符(path.Match语法)，匹配名称；含/时匹配相对路径，如 build/*
+	Always   []string // 总是隐藏的通配符，显示全部时同样隐藏(暂存目录、回收站等内部目录)
+}
+
+// Check 检查通配符的语法
+func Check(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Split 逗号分隔的通配符列表，忽略空项
+func Split(s string) []string {
+	var ret []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// All 显示全部(Always除外)的规则
+func (r Rules) All() Rules {
+	return Rules{Always: r.Always}
+}
+
+// Hidden rel(以/分隔的相对路径，或仅为名称)是否隐藏；隐藏的目录其下的文件一并隐藏，由调用方跳过整个目录
+func (r Rules) Hidden(rel string) bool {
+	rel = strings.Trim(rel, "/")
+	name := path.Base(rel)
+	if match(r.Always, name, rel, false) {
+		return true
+	}
+	if r.Dotfiles && strings.HasPrefix(name, ".") {
+		return true
+	}
+	if r.System && match(System, name, rel, true) {
+		return true
+	}
+	return match(r.Patterns, name, rel, false)
+}
+
+// match 名称或相对路径是否匹配任一通配符
+func match(patterns []string, name, rel string, fold bool) bool {
+	if fold {
+		name = strings.ToLower(name)
+	}
+	for _, p := range patterns {
+		s := name
+		if strings.Contains(p, "/") {
+			s, p = rel, strings.Trim(p, "/")
+		}
+		if fold {
+			p = strings.ToLower(p)
+		}
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
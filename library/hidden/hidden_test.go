@@ -0,0 +1,50 @@
+package hidden
+
+import "testing"
+
+func TestHidden(t *testing.T) {
+	r := Rules{Dotfiles: true, System: true, Patterns: []string{"*.tmp", "node_modules", "build/*"}, Always: []string{".b0pass-tmp"}}
+	cases := map[string]bool{
+		"report.pdf":                false,
+		".env":                      true,
+		"docs/.git":                 true,
+		".DS_Store":                 true,
+		"photos/Thumbs.db":          true,
+		"photos/THUMBS.DB":          true,
+		"DESKTOP.INI":               true,
+		"._IMG_0001.JPG":            true,
+		"$RECYCLE.BIN":              true,
+		"a.tmp":                     true,
+		"src/node_modules":          true,
+		"build/app.js":              true,
+		"src/build/app.js":          false,
+		"/build/":                   false,
+		"Thumbs.db.bak":             false,
+		"System Volume Information": true,
+	}
+	for rel, want := range cases {
+		if got := r.Hidden(rel); got != want {
+			t.Errorf("%q: got %v", rel, got)
+		}
+	}
+	// 只隐藏系统文件时保留其它点文件
+	sys := Rules{System: true}
+	if sys.Hidden(".env") || !sys.Hidden(".DS_Store") {
+		t.Error("system only")
+	}
+	// 显示全部时内部目录仍然隐藏
+	all := r.All()
+	if all.Hidden(".env") || all.Hidden("a.tmp") || !all.Hidden(".b0pass-tmp") {
+		t.Error("all")
+	}
+	if (Rules{}).Hidden(".env") {
+		t.Error("empty rules")
+	}
+
+	if got := Split(" *.log, ,~$*,"); len(got) != 2 || got[0] != "*.log" || got[1] != "~$*" {
+		t.Error(got)
+	}
+	if Check([]string{"*.tmp", "[a-z]*"}) != nil || Check([]string{"[a-"}) == nil {
+		t.Error("check")
+	}
+}
@@ -0,0 +1,114 @@
+package history
+
+import (
+	"b0pass/library/audit"
+	"b0pass/library/device"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 传输记录导出: 汇总审计日志中的传输、其他操作及已配对设备，按时间段输出CSV/JSON报表
+
+// 记录类别
+const (
+	KindTransfer = "transfer"
+	KindEvent    = "event"
+	KindDevice   = "device"
+)
+
+// Row 导出记录
+// 设备记录: Time为配对时间，Op为pair，Path为设备ID，Client为设备名，Agent为平台
+type Row struct {
+	Kind   string `json:"kind"`
+	Time   string `json:"time"`
+	Op     string `json:"op"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Client string `json:"client"`
+	Agent  string `json:"agent"`
+	// 上传采用的同步策略
+	Durability string `json:"durability,omitempty"`
+}
+
+// Header CSV表头
+var Header = []string{"kind", "time", "op", "path", "size", "client", "agent", "durability"}
+
+// ParseTime 解析时间，支持 2006-01-02、2006-01 与RFC3339；end为true时日期/月份取该段的结束时间
+func ParseTime(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expect 2006-01-02, 2006-01 or RFC3339", s)
+	}
+	if end {
+		t = t.AddDate(0, 1, 0).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// Collect 汇总from~to(为零值时不限)之间的记录，按时间排序
+// 设备在时间段内配对过或活跃过即列出
+func Collect(entries []audit.Entry, devices []device.Device, from, to time.Time) []Row {
+	in := func(t time.Time) bool {
+		return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+	}
+	var rows []Row
+	for _, e := range entries {
+		t, err := time.Parse(time.RFC3339, e.Time)
+		if err != nil || !in(t) {
+			continue
+		}
+		kind := KindEvent
+		if e.Op == audit.OpUpload || e.Op == audit.OpDownload {
+			kind = KindTransfer
+		}
+		rows = append(rows, Row{kind, e.Time, e.Op, e.Path, e.Size, e.Client, e.Agent, e.Durability})
+	}
+	for _, d := range devices {
+		if !to.IsZero() && d.Paired.After(to) || !from.IsZero() && d.LastSeen.Before(from) && d.Paired.Before(from) {
+			continue
+		}
+		rows = append(rows, Row{KindDevice, d.Paired.Format(time.RFC3339), "pair", d.ID, 0, d.Name, d.Platform, ""})
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Time < rows[j].Time })
+	return rows
+}
+
+// Write 按格式(csv/json)输出
+func Write(w io.Writer, format string, rows []Row) error {
+	switch strings.ToLower(format) {
+	case "", "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write(Header)
+		for _, r := range rows {
+			_ = cw.Write([]string{r.Kind, r.Time, r.Op, r.Path, strconv.FormatInt(r.Size, 10), r.Client, r.Agent, r.Durability})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		if rows == nil {
+			rows = []Row{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+	return fmt.Errorf("unsupported format %q, expect csv or json", format)
+}
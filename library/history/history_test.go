@@ -0,0 +1,58 @@
+package history
+
+import (
+	"b0pass/library/audit"
+	"b0pass/library/device"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollect(t *testing.T) {
+	from, _ := ParseTime("2024-05", false)
+	to, _ := ParseTime("2024-05", true)
+	if to.Format("2006-01-02 15:04:05") != "2024-05-31 23:59:59" {
+		t.Fatal(to)
+	}
+	entries := []audit.Entry{
+		{Time: "2024-04-30T23:00:00+00:00", Op: audit.OpUpload, Path: "/old.txt"},
+		{Time: time.Date(2024, 5, 3, 9, 0, 0, 0, time.Local).Format(time.RFC3339), Op: audit.OpUpload, Path: "/c.txt", Size: 5, Durability: "per-chunk"},
+		{Time: time.Date(2024, 5, 2, 9, 0, 0, 0, time.Local).Format(time.RFC3339), Op: audit.OpDownload, Path: "/a.txt", Size: 3, Client: "10.0.0.2"},
+		{Time: time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local).Format(time.RFC3339), Op: audit.OpDelete, Path: "/b.txt"},
+		{Time: "bad", Op: audit.OpUpload},
+	}
+	devices := []device.Device{
+		{ID: "d1", Name: "pixel", Platform: "android", Paired: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), LastSeen: time.Date(2024, 5, 3, 0, 0, 0, 0, time.Local)},
+		{ID: "d2", Name: "old", Paired: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local), LastSeen: time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local)},
+		{ID: "d3", Name: "new", Paired: time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local), LastSeen: time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)},
+	}
+	rows := Collect(entries, devices, from, to)
+	if len(rows) != 4 {
+		t.Fatalf("%+v", rows)
+	}
+	if rows[0].Kind != KindDevice || rows[0].Client != "pixel" || rows[1].Kind != KindEvent || rows[2].Kind != KindTransfer || rows[3].Durability != "per-chunk" {
+		t.Errorf("%+v", rows)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "csv", rows); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || lines[0] != strings.Join(Header, ",") || !strings.HasSuffix(lines[3], ",/a.txt,3,10.0.0.2,,") || !strings.HasSuffix(lines[4], ",/c.txt,5,,,per-chunk") {
+		t.Errorf("%q", lines)
+	}
+	buf.Reset()
+	if err := Write(&buf, "json", nil); err != nil {
+		t.Fatal(err)
+	}
+	var got []Row
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || got == nil {
+		t.Errorf("%q %v", buf.String(), err)
+	}
+	if Write(&buf, "xml", rows) == nil {
+		t.Error("expected error for xml")
+	}
+}
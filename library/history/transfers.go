@@ -0,0 +1,178 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 传输记录: 每次完成的上传与下载(文件、大小、耗时、平均速度、设备)按月份追加写入JSON行文件，
+// 按时间段查询时只读取涉及的月份，超过保留期的月份整体删除
+
+// Transfer 一次完成的传输
+type Transfer struct {
+	Time     time.Time `json:"time"` // 完成时间
+	Op       string    `json:"op"`   // upload、download
+	Path     string    `json:"path"` // 如 /files/a.zip
+	Size     int64     `json:"size"`
+	Duration float64   `json:"duration"` // 秒
+	Speed    int64     `json:"speed"`    // 平均速度(字节/秒)
+	Device   string    `json:"device"`   // 设备标识(device:<id>、browser:<id>)，无法识别设备时为IP
+	Name     string    `json:"name"`     // 设备名称，无法识别设备时为IP
+	Agent    string    `json:"agent"`
+}
+
+// NewTransfer 按开始与完成时间计算耗时与平均速度
+func NewTransfer(op, path string, size int64, start, end time.Time) Transfer {
+	t := Transfer{Time: end, Op: op, Path: path, Size: size}
+	if d := end.Sub(start).Seconds(); d > 0 {
+		t.Duration = math.Round(d*1000) / 1000
+		t.Speed = int64(float64(size) / d)
+	}
+	return t
+}
+
+// Filter 查询条件，为零值的条件不过滤
+type Filter struct {
+	Device string // 设备标识或设备名
+	Op     string
+	Path   string // 路径包含的文字
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+func (f Filter) match(t Transfer) bool {
+	return (f.Device == "" || t.Device == f.Device || t.Name == f.Device) &&
+		(f.Op == "" || t.Op == f.Op) &&
+		(f.Path == "" || strings.Contains(t.Path, f.Path)) &&
+		(f.From.IsZero() || !t.Time.Before(f.From)) &&
+		(f.To.IsZero() || !t.Time.After(f.To))
+}
+
+// Store 传输记录，每月一个文件 transfers-2006-01.jsonl
+type Store struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// Open 打开记录目录
+func Open(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+const monthLayout = "2006-01"
+
+func (s *Store) file(month string) string {
+	return filepath.Join(s.dir, "transfers-"+month+".jsonl")
+}
+
+// Add 追加一条记录
+func (s *Store) Add(t Transfer) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.file(t.Time.Format(monthLayout)), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// months 已有记录的月份，从新到旧
+func (s *Store) months() []string {
+	infos, _ := ioutil.ReadDir(s.dir)
+	var months []string
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasPrefix(name, "transfers-") || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		month := strings.TrimSuffix(strings.TrimPrefix(name, "transfers-"), ".jsonl")
+		if _, err := time.Parse(monthLayout, month); err == nil {
+			months = append(months, month)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(months)))
+	return months
+}
+
+// Query 按条件查询，按时间从新到旧排序
+func (s *Store) Query(f Filter) ([]Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := []Transfer{}
+	for _, month := range s.months() {
+		// 月份与时间段不相交时跳过整个文件(记录按本地时间的月份分文件)
+		start, _ := time.ParseInLocation(monthLayout, month, time.Local)
+		if (!f.From.IsZero() && !start.AddDate(0, 1, 0).After(f.From)) || (!f.To.IsZero() && start.After(f.To)) {
+			continue
+		}
+		list, err := readTransfers(s.file(month))
+		if err != nil {
+			return ret, err
+		}
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Time.After(list[j].Time) })
+		for _, t := range list {
+			if !f.match(t) {
+				continue
+			}
+			ret = append(ret, t)
+			if f.Limit > 0 && len(ret) >= f.Limit {
+				return ret, nil
+			}
+		}
+	}
+	return ret, nil
+}
+
+// Expire 删除早于before所在月份的记录文件
+func (s *Store) Expire(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keep := before.Format(monthLayout)
+	for _, month := range s.months() {
+		if month < keep {
+			if err := os.Remove(s.file(month)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readTransfers 读取一个月的记录，忽略损坏的行(如写入时断电)
+func readTransfers(file string) ([]Transfer, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var list []Transfer
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var t Transfer
+		if json.Unmarshal(scanner.Bytes(), &t) == nil {
+			list = append(list, t)
+		}
+	}
+	return list, scanner.Err()
+}
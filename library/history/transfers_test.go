@@ -0,0 +1,55 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	s := Open(dir)
+	may := time.Date(2024, 5, 20, 10, 0, 0, 0, time.Local)
+	list := []Transfer{
+		NewTransfer("upload", "/files/a.zip", 4<<20, may.Add(-2*time.Second), may),
+		{Time: may.AddDate(0, 0, 5), Op: "download", Path: "/files/b.mp4", Size: 10, Device: "device:p1", Name: "pixel"},
+		{Time: may.AddDate(0, 1, 0), Op: "upload", Path: "/files/c.txt", Size: 3, Device: "10.0.0.2", Name: "10.0.0.2"},
+	}
+	for _, tr := range list {
+		if err := s.Add(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if list[0].Duration != 2 || list[0].Speed != 2<<20 {
+		t.Errorf("speed %+v", list[0])
+	}
+
+	all, err := s.Query(Filter{})
+	if err != nil || len(all) != 3 || all[0].Path != "/files/c.txt" || all[2].Path != "/files/a.zip" {
+		t.Fatalf("%+v %v", all, err)
+	}
+	if got, _ := s.Query(Filter{Device: "pixel"}); len(got) != 1 || got[0].Device != "device:p1" {
+		t.Errorf("device %+v", got)
+	}
+	// 只读取时间段涉及的月份
+	got, _ := s.Query(Filter{From: may.AddDate(0, 0, 1), To: may.AddDate(0, 0, 10)})
+	if len(got) != 1 || got[0].Op != "download" {
+		t.Errorf("range %+v", got)
+	}
+	if got, _ := s.Query(Filter{Op: "upload", Limit: 1}); len(got) != 1 || got[0].Path != "/files/c.txt" {
+		t.Errorf("limit %+v", got)
+	}
+
+	if err := s.Expire(may.AddDate(0, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Query(Filter{}); len(got) != 1 || got[0].Path != "/files/c.txt" {
+		t.Errorf("expire %+v", got)
+	}
+}
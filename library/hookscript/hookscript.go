@@ -0,0 +1,169 @@
+package hookscript
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 钩子脚本: 文件事件时执行钩子目录下的 on-<事件> 可执行文件(如 on-upload、on-download、on-delete)，
+// 事件信息通过环境变量与标准输入(JSON)传入，用于转码、移动到NAS等自定义处理
+
+// Event 文件事件
+type Event struct {
+	Event  string    `json:"event"`
+	File   string    `json:"file"` // 主电脑上的文件路径
+	Path   string    `json:"path"` // 访问路径，如 /files/a.mp4
+	Size   int64     `json:"size"`
+	Hash   string    `json:"hash,omitempty"`
+	Client string    `json:"client,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Env 传给脚本的环境变量
+func (e Event) Env() []string {
+	return []string{
+		"B0PASS_EVENT=" + e.Event,
+		"B0PASS_FILE=" + e.File,
+		"B0PASS_PATH=" + e.Path,
+		"B0PASS_SIZE=" + strconv.FormatInt(e.Size, 10),
+		"B0PASS_HASH=" + e.Hash,
+		"B0PASS_CLIENT=" + e.Client,
+		"B0PASS_TIME=" + e.Time.Format(time.RFC3339),
+	}
+}
+
+// maxOutput 记录的脚本输出上限
+const maxOutput = 64 << 10
+
+// Runner 按事件查找并执行脚本，每次执行时查找，增删脚本无需重启
+type Runner struct {
+	// OnDone 脚本执行完成的回调(可选)，out为合并的标准输出与标准错误
+	OnDone func(script string, e Event, out []byte, err error)
+
+	mu      sync.RWMutex
+	dir     string
+	timeout time.Duration
+	queue   chan Event
+}
+
+// New 创建执行器，dir为空时不执行任何脚本
+func New(dir string, timeout time.Duration) *Runner {
+	return &Runner{dir: dir, timeout: timeout, queue: make(chan Event, 64)}
+}
+
+// Set 修改钩子目录与单个脚本的最长执行时间(0为不限制)
+func (r *Runner) Set(dir string, timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dir, r.timeout = dir, timeout
+}
+
+// Script 事件对应的脚本，不存在或不可执行时返回空
+func (r *Runner) Script(event string) string {
+	r.mu.RLock()
+	dir := r.dir
+	r.mu.RUnlock()
+	if dir == "" || event == "" {
+		return ""
+	}
+	name := filepath.Join(dir, "on-"+event)
+	if runtime.GOOS == "windows" {
+		for _, ext := range []string{".exe", ".bat", ".cmd"} {
+			if info, err := os.Stat(name + ext); err == nil && info.Mode().IsRegular() {
+				return name + ext
+			}
+		}
+		return ""
+	}
+	if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+		return name
+	}
+	return ""
+}
+
+// Start 启动执行协程，workers为同时执行的脚本数
+func (r *Runner) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for e := range r.queue {
+				script := r.Script(e.Event)
+				if script == "" {
+					continue
+				}
+				out, err := r.Exec(script, e)
+				if r.OnDone != nil {
+					r.OnDone(script, e, out, err)
+				}
+			}
+		}()
+	}
+}
+
+// Run 事件有对应的脚本时加入执行队列，队列满时丢弃并返回false
+func (r *Runner) Run(e Event) bool {
+	if r.Script(e.Event) == "" {
+		return false
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	select {
+	case r.queue <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// Exec 在钩子目录下执行脚本并等待结束，超时时结束脚本进程
+func (r *Runner) Exec(script string, e Event) ([]byte, error) {
+	r.mu.RLock()
+	dir, timeout := r.dir, r.timeout
+	r.mu.RUnlock()
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	stdin, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, script)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), e.Env()...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	out := &limitBuffer{max: maxOutput}
+	cmd.Stdout, cmd.Stderr = out, out
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = ctx.Err()
+	}
+	return out.Bytes(), err
+}
+
+// limitBuffer 只保留前max字节，之后的输出丢弃
+type limitBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitBuffer) Write(p []byte) (int, error) {
+	if n := b.max - b.Len(); n > 0 {
+		if len(p) > n {
+			b.Buffer.Write(p[:n])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,82 @@
+package hookscript
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts")
+	}
+	dir, err := ioutil.TempDir("", "hookscript")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	script := "#!/bin/sh\necho \"$B0PASS_EVENT $B0PASS_PATH $B0PASS_SIZE\"\ncat\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "on-upload"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 不可执行的文件不作为脚本
+	if err := ioutil.WriteFile(filepath.Join(dir, "on-delete"), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := New(dir, 5*time.Second)
+	if r.Script("delete") != "" || r.Script("download") != "" {
+		t.Error("non-executable or missing script")
+	}
+	if r.Run(Event{Event: "delete"}) {
+		t.Error("run without script")
+	}
+	e := Event{Event: "upload", Path: "/files/a.txt", Size: 3, Time: time.Now()}
+	out, err := r.Exec(r.Script("upload"), e)
+	if err != nil {
+		t.Fatal(err, string(out))
+	}
+	if !strings.HasPrefix(string(out), "upload /files/a.txt 3\n") || !strings.Contains(string(out), `"path":"/files/a.txt"`) {
+		t.Errorf("output %q", out)
+	}
+
+	done := make(chan error, 1)
+	r.OnDone = func(script string, e Event, out []byte, err error) { done <- err }
+	r.Start(1)
+	if !r.Run(e) {
+		t.Fatal("not queued")
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("script not run")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts")
+	}
+	dir, err := ioutil.TempDir("", "hookscript")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	if err := ioutil.WriteFile(filepath.Join(dir, "on-upload"), []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r := New(dir, 200*time.Millisecond)
+	start := time.Now()
+	if _, err := r.Exec(r.Script("upload"), Event{Event: "upload"}); err == nil {
+		t.Error("expected timeout")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("script not killed")
+	}
+}
@@ -0,0 +1,53 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// 下载与静态资源的条件请求: 强ETag由大小与修改时间生成，
+// 未使用上传时记录的哈希，主电脑上被直接修改的文件元数据不会更新
+
+// ETag 文件的强ETag
+func ETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// Set 设置ETag、Last-Modified与Cache-Control(每次使用前向服务端确认)
+func Set(h http.Header, info os.FileInfo) {
+	h.Set("ETag", ETag(info))
+	h.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	if h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", "no-cache")
+	}
+}
+
+// NotModified GET/HEAD的条件请求是否命中: 有If-None-Match时只比较ETag，否则比较If-Modified-Since
+func NotModified(r *http.Request, info os.FileInfo) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return matchETag(inm, ETag(info))
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	return err == nil && !info.ModTime().Truncate(time.Second).After(t)
+}
+
+// matchETag If-None-Match列表中是否有匹配的ETag(弱比较)
+func matchETag(list, etag string) bool {
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	_ = os.Chtimes(file, mtime, mtime)
+	info, _ := os.Stat(file)
+
+	w := httptest.NewRecorder()
+	Set(w.Header(), info)
+	etag := w.Header().Get("ETag")
+	if etag != ETag(info) || w.Header().Get("Last-Modified") != "Thu, 02 Jan 2020 03:04:05 GMT" {
+		t.Fatal(w.Header())
+	}
+	r := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+	if NotModified(r, info) {
+		t.Error("unconditional")
+	}
+	r.Header.Set("If-None-Match", `"x", W/`+etag)
+	if !NotModified(r, info) {
+		t.Error("if-none-match")
+	}
+	// If-None-Match不匹配时忽略If-Modified-Since
+	r.Header.Set("If-None-Match", `"other"`)
+	r.Header.Set("If-Modified-Since", "Thu, 02 Jan 2020 03:04:05 GMT")
+	if NotModified(r, info) {
+		t.Error("etag mismatch")
+	}
+	r.Header.Del("If-None-Match")
+	if !NotModified(r, info) {
+		t.Error("if-modified-since")
+	}
+	r.Header.Set("If-Modified-Since", "Thu, 02 Jan 2020 03:04:04 GMT")
+	if NotModified(r, info) {
+		t.Error("modified")
+	}
+	if err := ioutil.WriteFile(file, []byte("hello!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(file, mtime, mtime)
+	changed, _ := os.Stat(file)
+	if ETag(changed) == etag {
+		t.Error("size change keeps etag")
+	}
+}
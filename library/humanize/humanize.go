@@ -0,0 +1,192 @@
+package humanize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 面向界面的大小、数量、日期与时长格式化，中文与英文两种语言
+
+// 语言
+const (
+	ZH = "zh"
+	EN = "en"
+)
+
+// Negotiate 选择语言: locale参数优先，其次Accept-Language，都不支持时为def
+func Negotiate(locale, acceptLanguage, def string) string {
+	if loc := match(locale); loc != "" {
+		return loc
+	}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		if loc := match(strings.SplitN(part, ";", 2)[0]); loc != "" {
+			return loc
+		}
+	}
+	if loc := match(def); loc != "" {
+		return loc
+	}
+	return ZH
+}
+
+// match 语言标签(zh-CN、en_US等)对应的语言，不支持时返回空
+func match(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	switch {
+	case tag == ZH || strings.HasPrefix(tag, "zh-") || strings.HasPrefix(tag, "zh_"):
+		return ZH
+	case tag == EN || strings.HasPrefix(tag, "en-") || strings.HasPrefix(tag, "en_"):
+		return EN
+	}
+	return ""
+}
+
+var sizeUnits = []string{"B", "KB", "MB", "GB", "TB", "PB"}
+
+// Size 文件大小，1024进制，小于10时保留一位小数，如 1.5 MB、230 KB
+func Size(n int64, loc string) string {
+	if n < 1024 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	f := float64(n)
+	i := 0
+	for f >= 1024 && i < len(sizeUnits)-1 {
+		f /= 1024
+		i++
+	}
+	if f < 10 {
+		return strconv.FormatFloat(f, 'f', 1, 64) + " " + sizeUnits[i]
+	}
+	return strconv.FormatFloat(f, 'f', 0, 64) + " " + sizeUnits[i]
+}
+
+// Number 数量，中文以万、亿为单位(1.2万)，英文加千位分隔符(12,345)
+func Number(n int64, loc string) string {
+	if n < 0 {
+		return "-" + Number(-n, loc)
+	}
+	if loc == ZH {
+		switch {
+		case n >= 100000000:
+			return trimZero(float64(n)/100000000) + "亿"
+		case n >= 10000:
+			return trimZero(float64(n)/10000) + "万"
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	s := strconv.FormatInt(n, 10)
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// trimZero 保留一位小数，去掉末尾的.0
+func trimZero(f float64) string {
+	return strings.TrimSuffix(strconv.FormatFloat(f, 'f', 1, 64), ".0")
+}
+
+// Date 日期时间，当天只显示时间，当年省略年份
+func Date(t, now time.Time, loc string) string {
+	t = t.In(now.Location())
+	switch {
+	case t.Year() == now.Year() && t.YearDay() == now.YearDay():
+		return t.Format("15:04")
+	case t.Year() == now.Year():
+		if loc == ZH {
+			return fmt.Sprintf("%d月%d日 %s", t.Month(), t.Day(), t.Format("15:04"))
+		}
+		return t.Format("Jan 2 15:04")
+	}
+	if loc == ZH {
+		return fmt.Sprintf("%d年%d月%d日", t.Year(), t.Month(), t.Day())
+	}
+	return t.Format("Jan 2, 2006")
+}
+
+// Duration 时长，取最大的两个单位，如 1小时5分、3m 20s
+func Duration(d time.Duration, loc string) string {
+	if d < 0 {
+		d = -d
+	}
+	units := []struct {
+		d      time.Duration
+		zh, en string
+	}{
+		{24 * time.Hour, "天", "d"}, {time.Hour, "小时", "h"}, {time.Minute, "分", "m"}, {time.Second, "秒", "s"},
+	}
+	var parts []string
+	for _, u := range units {
+		if n := d / u.d; n > 0 || (len(parts) == 0 && u.d == time.Second) {
+			if loc == ZH {
+				parts = append(parts, strconv.FormatInt(int64(n), 10)+u.zh)
+			} else {
+				parts = append(parts, strconv.FormatInt(int64(n), 10)+u.en)
+			}
+			d -= n * u.d
+		} else if len(parts) > 0 {
+			break
+		}
+		if len(parts) == 2 {
+			break
+		}
+	}
+	if loc == ZH {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Ago 相对时间，如 刚刚、5分钟前、3 hours ago，超过7天时为日期
+func Ago(t, now time.Time, loc string) string {
+	d := now.Sub(t)
+	type unit struct {
+		d      time.Duration
+		zh, en string
+	}
+	switch {
+	case d < time.Minute:
+		if loc == ZH {
+			return "刚刚"
+		}
+		return "just now"
+	case d >= 7*24*time.Hour:
+		return Date(t, now, loc)
+	}
+	for _, u := range []unit{{24 * time.Hour, "天", "day"}, {time.Hour, "小时", "hour"}, {time.Minute, "分钟", "minute"}} {
+		if n := int64(d / u.d); n > 0 {
+			if loc == ZH {
+				return strconv.FormatInt(n, 10) + u.zh + "前"
+			}
+			if n > 1 {
+				return fmt.Sprintf("%d %ss ago", n, u.en)
+			}
+			return fmt.Sprintf("1 %s ago", u.en)
+		}
+	}
+	return ""
+}
+
+// ParseSize 解析大小，如 512K、20G、1.5GB、1024(字节)，单位为1024进制且不区分大小写
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+	mul := int64(1)
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGTP", s[n-1]); i >= 0 {
+			mul = 1 << (10 * uint(i+1))
+			s = strings.TrimSpace(s[:n-1])
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("humanize: invalid size %q", s)
+	}
+	return int64(f * float64(mul)), nil
+}
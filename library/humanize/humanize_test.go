@@ -0,0 +1,96 @@
+package humanize
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct{ param, accept, def, want string }{
+		{"en", "zh-CN", ZH, EN},
+		{"", "en-US,en;q=0.9,zh;q=0.8", ZH, EN},
+		{"", "fr-FR,zh-TW;q=0.5", EN, ZH},
+		{"fr", "", EN, EN},
+		{"", "", "", ZH},
+	}
+	for _, c := range cases {
+		if got := Negotiate(c.param, c.accept, c.def); got != c.want {
+			t.Errorf("Negotiate(%q,%q,%q) = %q, want %q", c.param, c.accept, c.def, got, c.want)
+		}
+	}
+}
+
+func TestSizeNumber(t *testing.T) {
+	sizes := map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 230 << 10: "230 KB", 5 << 30: "5.0 GB"}
+	for n, want := range sizes {
+		if got := Size(n, EN); got != want {
+			t.Errorf("Size(%d) = %q, want %q", n, got, want)
+		}
+	}
+	if got := Number(12345, EN); got != "12,345" {
+		t.Error(got)
+	}
+	if got := Number(12345, ZH); got != "1.2万" {
+		t.Error(got)
+	}
+	if got := Number(300000000, ZH); got != "3亿" {
+		t.Error(got)
+	}
+}
+
+func TestDate(t *testing.T) {
+	now := time.Date(2020, 3, 5, 18, 0, 0, 0, time.UTC)
+	if got := Date(now.Add(-time.Hour), now, ZH); got != "17:00" {
+		t.Error(got)
+	}
+	feb := time.Date(2020, 2, 1, 9, 30, 0, 0, time.UTC)
+	if got := Date(feb, now, ZH); got != "2月1日 09:30" {
+		t.Error(got)
+	}
+	if got := Date(feb, now, EN); got != "Feb 1 09:30" {
+		t.Error(got)
+	}
+	if got := Date(feb.AddDate(-1, 0, 0), now, EN); got != "Feb 1, 2019" {
+		t.Error(got)
+	}
+}
+
+func TestDurationAgo(t *testing.T) {
+	d := time.Hour + 5*time.Minute + 7*time.Second
+	if got := Duration(d, ZH); got != "1小时5分" {
+		t.Error(got)
+	}
+	if got := Duration(d, EN); got != "1h 5m" {
+		t.Error(got)
+	}
+	if got := Duration(2*time.Second, EN); got != "2s" {
+		t.Error(got)
+	}
+	now := time.Now()
+	if got := Ago(now.Add(-10*time.Second), now, ZH); got != "刚刚" {
+		t.Error(got)
+	}
+	if got := Ago(now.Add(-5*time.Minute), now, ZH); got != "5分钟前" {
+		t.Error(got)
+	}
+	if got := Ago(now.Add(-3*time.Hour), now, EN); got != "3 hours ago" {
+		t.Error(got)
+	}
+	if got := Ago(now.Add(-25*time.Hour), now, EN); got != "1 day ago" {
+		t.Error(got)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	sizes := map[string]int64{"1024": 1024, "512K": 512 << 10, "20G": 20 << 30, "1.5GB": 3 << 29, "2 MiB": 2 << 20, "0": 0}
+	for s, want := range sizes {
+		if got, err := ParseSize(s); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "G", "-1M", "abc"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) expected error", s)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+)
+
+// 界面文字与接口返回信息的翻译: 各语言的目录(locales/<语言>.json)嵌入程序，
+// 接口信息以中文原文为键，界面文字以ui.开头的名称为键；目录中没有的文字原样返回
+
+//go:embed locales/*.json
+var files embed.FS
+
+// catalogs 各语言的目录
+var catalogs = load()
+
+func load() map[string]map[string]string {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	m := make(map[string]map[string]string, len(entries))
+	for _, e := range entries {
+		b, err := files.ReadFile("locales/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		c := make(map[string]string)
+		if err := json.Unmarshal(b, &c); err != nil {
+			panic("i18n: " + e.Name() + ": " + err.Error())
+		}
+		m[strings.TrimSuffix(e.Name(), path.Ext(e.Name()))] = c
+	}
+	return m
+}
+
+// Langs 支持的语言
+func Langs() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Catalog 语言的全部翻译，不支持的语言返回nil；调用方不得修改
+func Catalog(lang string) map[string]string {
+	return catalogs[lang]
+}
+
+// T 翻译文字: 完全匹配优先，其次按第一个": "分为前缀与详情(如 "文件不存在: a.txt")只翻译前缀
+func T(lang, msg string) string {
+	c := catalogs[lang]
+	if c == nil || msg == "" {
+		return msg
+	}
+	if s, ok := c[msg]; ok {
+		return s
+	}
+	if i := strings.Index(msg, ": "); i > 0 {
+		if s, ok := c[msg[:i]]; ok {
+			return s + msg[i:]
+		}
+	}
+	return msg
+}
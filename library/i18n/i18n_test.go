@@ -0,0 +1,44 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	cases := []struct{ lang, msg, want string }{
+		{"en", "文件不存在", "file not found"},
+		{"en", "文件不存在: a.txt", "file not found: a.txt"},
+		{"en", "口令太短，最少字符数: 8", "passphrase is too short, minimum length: 8"},
+		{"en", "sort只能是以下之一: name, size, mtime", "sort must be one of: name, size, mtime"},
+		{"zh", "name must be 1-40 characters without slashes or control characters", "名称须为1-40个字符，不能包含斜杠或控制字符"},
+		{"en", "ok", "ok"},
+		{"zh", "文件不存在", "文件不存在"},
+		{"zh", "hash mismatch, upload discarded", "校验值不一致，上传已丢弃"},
+		{"fr", "文件不存在", "文件不存在"},
+	}
+	for _, c := range cases {
+		if got := T(c.lang, c.msg); got != c.want {
+			t.Errorf("T(%q, %q) = %q, want %q", c.lang, c.msg, got, c.want)
+		}
+	}
+}
+
+func TestCatalogs(t *testing.T) {
+	if langs := Langs(); strings.Join(langs, ",") != "en,zh" {
+		t.Fatal(langs)
+	}
+	// 界面文字在各语言中都有翻译
+	for _, lang := range Langs() {
+		for key := range Catalog(lang) {
+			if !strings.HasPrefix(key, "ui.") {
+				continue
+			}
+			for _, other := range Langs() {
+				if Catalog(other)[key] == "" {
+					t.Errorf("%s: %s missing in %s", lang, key, other)
+				}
+			}
+		}
+	}
+}
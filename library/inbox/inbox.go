@@ -0,0 +1,217 @@
+package inbox
+
+import (
+	"b0pass/library/fsync"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 收集链接(inbox): 发送者通过链接上传到指定目录，服务端按会话的命名模板重命名
+
+var (
+	ErrNoEntry = errors.New("inbox not found")
+	ErrClosed  = errors.New("inbox closed or expired")
+)
+
+// Session 收集会话
+type Session struct {
+	ID       string    `json:"id"`
+	Path     string    `json:"path"`
+	Template string    `json:"template"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Closed   bool      `json:"closed"`
+	// Files 已收到的文件(相对共享目录)
+	Files []string `json:"files"`
+	// Archive 关闭或过期后自动打包归档
+	Archive bool `json:"archive,omitempty"`
+	// Archived 归档文件(相对共享目录)，尚未归档时为空，没有可归档的文件时为NothingArchived
+	Archived string `json:"archived,omitempty"`
+}
+
+// NothingArchived 会话没有可归档的文件
+const NothingArchived = "-"
+
+// Open 会话是否仍可接收上传
+func (s Session) Open(now time.Time) bool {
+	return !s.Closed && (s.Expires.IsZero() || now.Before(s.Expires))
+}
+
+// Store 会话存储，持久化到文件
+type Store struct {
+	mu       sync.Mutex
+	file     string
+	sessions map[string]*Session
+}
+
+// OpenStore 打开会话文件，文件不存在时创建空存储
+func OpenStore(file string) *Store {
+	s := &Store{file: file, sessions: make(map[string]*Session)}
+	var list []*Session
+	if b, err := ioutil.ReadFile(file); err == nil {
+		_ = json.Unmarshal(b, &list)
+	}
+	for _, sess := range list {
+		s.sessions[sess.ID] = sess
+	}
+	return s
+}
+
+// Create 创建会话，dir为共享目录下的相对目录，ttl为0时不过期
+func (s *Store) Create(dir, template string, ttl time.Duration) (Session, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return Session{}, err
+	}
+	now := time.Now()
+	sess := &Session{ID: hex.EncodeToString(b), Path: path.Clean("/" + dir), Template: template, Created: now, Files: []string{}}
+	if ttl > 0 {
+		sess.Expires = now.Add(ttl)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sess.ID] = sess
+	return *sess, s.save()
+}
+
+// Get 获取会话
+func (s *Store) Get(id string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return Session{}, false
+	}
+	return *sess, true
+}
+
+// Add 记录收到的文件，会话已关闭或过期时返回ErrClosed
+func (s *Store) Add(id, file string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return ErrNoEntry
+	}
+	if !sess.Open(time.Now()) {
+		return ErrClosed
+	}
+	sess.Files = append(sess.Files, file)
+	return s.save()
+}
+
+// Close 关闭会话，不再接收上传
+func (s *Store) Close(id string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return Session{}, ErrNoEntry
+	}
+	sess.Closed = true
+	return *sess, s.save()
+}
+
+// Update 修改会话并保存
+func (s *Store) Update(id string, fn func(sess *Session)) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return Session{}, ErrNoEntry
+	}
+	fn(sess)
+	return *sess, s.save()
+}
+
+// ToArchive 已关闭或过期、需要归档且尚未归档的会话
+func (s *Store) ToArchive(now time.Time) []Session {
+	var list []Session
+	for _, sess := range s.List() {
+		if sess.Archive && sess.Archived == "" && !sess.Open(now) {
+			list = append(list, sess)
+		}
+	}
+	return list
+}
+
+// List 所有会话，按创建时间排序
+func (s *Store) List() []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		list = append(list, *sess)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+// save 持久化到文件，原子替换文件
+func (s *Store) save() error {
+	if s.file == "" {
+		return nil
+	}
+	list := make([]*Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		list = append(list, sess)
+	}
+	return fsync.WriteJSON(s.file, list, 0644)
+}
+
+// Vars 命名模板变量
+type Vars struct {
+	Time   time.Time
+	Device string
+	Orig   string
+	Seq    int
+}
+
+// Name 按模板生成文件名
+// 支持 {date} {time} {device} {orig} {name} {ext} {n}，未知占位符原样保留；
+// 模板为空时使用原文件名，模板不含{orig}和{ext}时补上原扩展名
+func Name(template string, v Vars) string {
+	orig := filepath.Base(strings.Replace(v.Orig, "\\", "/", -1))
+	if template == "" {
+		return safe(orig)
+	}
+	ext := path.Ext(orig)
+	name := strings.NewReplacer(
+		"{date}", v.Time.Format("2006-01-02"),
+		"{time}", v.Time.Format("150405"),
+		"{device}", v.Device,
+		"{orig}", orig,
+		"{name}", strings.TrimSuffix(orig, ext),
+		"{ext}", strings.TrimPrefix(ext, "."),
+		"{n}", strconv.Itoa(v.Seq),
+	).Replace(template)
+	if !strings.Contains(template, "{orig}") && !strings.Contains(template, "{ext}") {
+		name += ext
+	}
+	return safe(name)
+}
+
+// safe 替换路径分隔符与控制字符，避免模板变量(如设备名)逃逸出目标目录
+func safe(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		name = "_"
+	}
+	return name
+}
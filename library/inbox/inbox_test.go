@@ -0,0 +1,80 @@
+package inbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestName(t *testing.T) {
+	v := Vars{Time: time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local), Device: "pixel", Orig: "IMG 01.jpg", Seq: 3}
+	cases := map[string]string{
+		"":                       "IMG 01.jpg",
+		"{date}-{device}-{orig}": "2024-05-06-pixel-IMG 01.jpg",
+		"{date}_{time}_{n}":      "2024-05-06_070809_3.jpg",
+		"{name}.{ext}":           "IMG 01.jpg",
+		"{device}/{unknown}":     "pixel_{unknown}.jpg",
+		"../{orig}":              ".._IMG 01.jpg",
+	}
+	for tmpl, want := range cases {
+		if got := Name(tmpl, v); got != want {
+			t.Errorf("%q: got %q, want %q", tmpl, got, want)
+		}
+	}
+	v.Device = "../../etc"
+	if got := Name("{device}", v); got != ".._.._etc.jpg" {
+		t.Error(got)
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "inbox")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "inbox.json")
+
+	s := OpenStore(file)
+	sess, err := s.Create("photos/../in", "{date}-{orig}", time.Hour)
+	if err != nil || sess.Path != "/in" || !sess.Open(time.Now()) {
+		t.Fatal(sess, err)
+	}
+	if err := s.Add(sess.ID, "/in/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("nope", "/in/b.jpg"); err != ErrNoEntry {
+		t.Error(err)
+	}
+
+	s = OpenStore(file)
+	got, ok := s.Get(sess.ID)
+	if !ok || got.Template != "{date}-{orig}" || len(got.Files) != 1 {
+		t.Fatal(got, ok)
+	}
+	if _, err := s.Close(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(sess.ID, "/in/c.jpg"); err != ErrClosed {
+		t.Error(err)
+	}
+	if !got.Open(time.Now()) || got.Open(got.Expires.Add(time.Second)) {
+		t.Error("expiry")
+	}
+
+	if len(s.ToArchive(time.Now())) != 0 {
+		t.Error("archive not enabled")
+	}
+	if _, err := s.Update(sess.ID, func(sess *Session) { sess.Archive = true }); err != nil {
+		t.Fatal(err)
+	}
+	if list := s.ToArchive(time.Now()); len(list) != 1 || list[0].ID != sess.ID {
+		t.Fatal(list)
+	}
+	_, _ = s.Update(sess.ID, func(sess *Session) { sess.Archived = "/in/inbox.zip" })
+	if len(OpenStore(file).ToArchive(time.Now())) != 0 {
+		t.Error("archived")
+	}
+	if _, err := s.Update("nope", func(*Session) {}); err != ErrNoEntry {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,82 @@
+package inhibit
+
+import (
+	"sync"
+	"time"
+)
+
+// Inhibitor 传输期间阻止系统休眠
+// 按引用计数管理，第一个传输开始时启用，最后一个传输结束grace时间后释放，
+// 避免连续的小文件传输反复启用与释放
+type Inhibitor struct {
+	Why   string        // 显示给系统的原因
+	Grace time.Duration // 空闲后延迟释放的时间
+
+	mu     sync.Mutex
+	active int
+	stop   func()
+	timer  *time.Timer
+	start  func(why string) (func(), error)
+	onErr  func(error)
+}
+
+// New 创建休眠抑制器，onErr为启用失败时的回调(可为nil)
+func New(why string, grace time.Duration, onErr func(error)) *Inhibitor {
+	return &Inhibitor{Why: why, Grace: grace, start: start, onErr: onErr}
+}
+
+// Acquire 开始一个传输，返回结束函数(可重复调用)
+// nil Inhibitor(未启用)返回空函数
+func (in *Inhibitor) Acquire() func() {
+	if in == nil {
+		return func() {}
+	}
+	in.mu.Lock()
+	in.active++
+	if in.timer != nil {
+		in.timer.Stop()
+		in.timer = nil
+	}
+	if in.stop == nil {
+		stop, err := in.start(in.Why)
+		if err != nil {
+			if in.onErr != nil {
+				in.onErr(err)
+			}
+		} else {
+			in.stop = stop
+		}
+	}
+	in.mu.Unlock()
+
+	var once sync.Once
+	return func() { once.Do(in.release) }
+}
+
+func (in *Inhibitor) release() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.active--
+	if in.active > 0 || in.stop == nil {
+		return
+	}
+	in.timer = time.AfterFunc(in.Grace, func() {
+		in.mu.Lock()
+		defer in.mu.Unlock()
+		if in.active == 0 && in.stop != nil {
+			in.stop()
+			in.stop = nil
+		}
+		in.timer = nil
+	})
+}
+
+// Active 是否正在阻止休眠
+func (in *Inhibitor) Active() bool {
+	if in == nil {
+		return false
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.stop != nil
+}
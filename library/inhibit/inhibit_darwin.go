@@ -0,0 +1,12 @@
+package inhibit
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// start 通过caffeinate阻止空闲休眠，-w使其在本进程退出时自动结束
+func start(why string) (func(), error) {
+	return startProcess(exec.Command("caffeinate", "-i", "-w", strconv.Itoa(os.Getpid())))
+}
@@ -0,0 +1,15 @@
+package inhibit
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// start 通过systemd-inhibit持有休眠锁，结束子进程即释放
+// 本进程退出时子进程随之被终止，不会遗留休眠锁
+func start(why string) (func(), error) {
+	cmd := exec.Command("systemd-inhibit", "--what=sleep:idle", "--who=b0pass",
+		"--why="+why, "--mode=block", "sleep", "infinity")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+	return startProcess(cmd)
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package inhibit
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// start 其他平台暂不支持
+func start(why string) (func(), error) {
+	return nil, fmt.Errorf("sleep inhibition is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,48 @@
+package inhibit
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInhibitor(t *testing.T) {
+	var starts, stops int32
+	in := New("test", 20*time.Millisecond, nil)
+	in.start = func(why string) (func(), error) {
+		atomic.AddInt32(&starts, 1)
+		return func() { atomic.AddInt32(&stops, 1) }, nil
+	}
+
+	a, b := in.Acquire(), in.Acquire()
+	if atomic.LoadInt32(&starts) != 1 || !in.Active() {
+		t.Fatalf("starts=%d active=%v", starts, in.Active())
+	}
+	a()
+	a()
+	b()
+	// 宽限期内新的传输复用同一个休眠锁
+	c := in.Acquire()
+	time.Sleep(40 * time.Millisecond)
+	if atomic.LoadInt32(&starts) != 1 || atomic.LoadInt32(&stops) != 0 {
+		t.Errorf("released during transfer: starts=%d stops=%d", starts, stops)
+	}
+	c()
+	time.Sleep(40 * time.Millisecond)
+	if atomic.LoadInt32(&stops) != 1 || in.Active() {
+		t.Errorf("not released when idle: stops=%d", stops)
+	}
+}
+
+func TestStartError(t *testing.T) {
+	var got error
+	in := New("test", time.Millisecond, func(err error) { got = err })
+	in.start = func(why string) (func(), error) { return nil, errors.New("unsupported") }
+	in.Acquire()()
+	if got == nil || in.Active() {
+		t.Error("start error not reported")
+	}
+	var nilIn *Inhibitor
+	nilIn.Acquire()()
+}
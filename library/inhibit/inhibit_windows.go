@@ -0,0 +1,38 @@
+package inhibit
+
+import (
+	"runtime"
+	"syscall"
+)
+
+const (
+	esContinuous     = 0x80000000
+	esSystemRequired = 0x00000001
+)
+
+var procSetThreadExecutionState = syscall.NewLazyDLL("kernel32.dll").NewProc("SetThreadExecutionState")
+
+// start 调用SetThreadExecutionState阻止休眠
+// 该状态属于调用线程，因此在固定的系统线程上设置并等待结束
+func start(why string) (func(), error) {
+	if err := procSetThreadExecutionState.Find(); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	ready := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		if r, _, err := procSetThreadExecutionState.Call(esContinuous | esSystemRequired); r == 0 {
+			ready <- err
+			return
+		}
+		ready <- nil
+		<-done
+		_, _, _ = procSetThreadExecutionState.Call(esContinuous)
+	}()
+	if err := <-ready; err != nil {
+		return nil, err
+	}
+	return func() { close(done) }, nil
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package inhibit
+
+import "os/exec"
+
+// startProcess 启动持有休眠锁的子进程，返回结束函数
+func startProcess(cmd *exec.Cmd) (func(), error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}, nil
+}
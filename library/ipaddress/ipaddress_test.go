@@ -2,6 +2,7 @@ package ipaddress
 
 import (
 	"testing"
+	"time"
 )
 
 func TestGetIP(t *testing.T) {
@@ -19,3 +20,36 @@ func TestGetIP2(t *testing.T) {
 	}
 	t.Log(ip)
 }
+
+func TestWatch(t *testing.T) {
+	ips := make(chan []string, 3)
+	ips <- []string{"192.168.1.2"}
+	getIP = func() ([]string, error) {
+		select {
+		case ip := <-ips:
+			return ip, nil
+		default:
+			return []string{"10.0.0.5", "192.168.1.2"}, nil
+		}
+	}
+	defer func() { getIP = GetIP }()
+	ips <- []string{"192.168.1.2"}
+
+	changed := make(chan []string, 1)
+	stop := Watch(5*time.Millisecond, func(old, now []string) {
+		select {
+		case changed <- now:
+		default:
+		}
+	})
+	defer stop()
+	select {
+	case now := <-changed:
+		added, removed := Diff([]string{"192.168.1.2"}, now)
+		if len(added) != 1 || added[0] != "10.0.0.5" || len(removed) != 0 {
+			t.Errorf("bad diff %v %v", added, removed)
+		}
+	case <-time.After(time.Second):
+		t.Error("change not detected")
+	}
+}
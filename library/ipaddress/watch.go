@@ -0,0 +1,59 @@
+package ipaddress
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// getIP 获取地址，测试时替换
+var getIP = GetIP
+
+// Watch 定期检查内网IP，切换Wi-Fi、DHCP续租得到新地址等变化时调用onChange
+// 返回停止函数
+func Watch(interval time.Duration, onChange func(old, now []string)) func() {
+	stop := make(chan struct{})
+	go func() {
+		last, _ := getIP()
+		sort.Strings(last)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+			}
+			now, err := getIP()
+			if err != nil {
+				continue
+			}
+			sort.Strings(now)
+			if strings.Join(now, ",") != strings.Join(last, ",") {
+				onChange(last, now)
+				last = now
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// Diff 比较两组地址，返回新增与移除的地址
+func Diff(old, now []string) (added, removed []string) {
+	seen := make(map[string]bool)
+	for _, ip := range old {
+		seen[ip] = true
+	}
+	for _, ip := range now {
+		if !seen[ip] {
+			added = append(added, ip)
+		}
+		delete(seen, ip)
+	}
+	for _, ip := range old {
+		if seen[ip] {
+			removed = append(removed, ip)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,70 @@
+package jsonstream
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// 流式输出JSON数组或NDJSON: 逐项编码写出，复用同一个json.Encoder，不构造中间切片
+
+// ContentType NDJSON的MIME类型
+const ContentType = "application/x-ndjson"
+
+// Encoder 逐项写出的编码器
+type Encoder struct {
+	w     io.Writer
+	enc   *json.Encoder
+	array bool
+	tail  string
+	n     int
+	err   error
+}
+
+// NewArray 写出head后开始数组，各项以逗号分隔，Close时写出tail
+// head须以"["结尾，tail以"]"开头，如 `{"data":[` 与 `]}`
+func NewArray(w io.Writer, head, tail string) *Encoder {
+	e := newEncoder(w)
+	e.array, e.tail = true, tail
+	_, e.err = io.WriteString(w, head)
+	return e
+}
+
+// NewLines 每项一行(NDJSON)
+func NewLines(w io.Writer) *Encoder {
+	return newEncoder(w)
+}
+
+func newEncoder(w io.Writer) *Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &Encoder{w: w, enc: enc}
+}
+
+// Encode 写出一项，出错后不再写出并返回第一个错误
+func (e *Encoder) Encode(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.array && e.n > 0 {
+		if _, e.err = io.WriteString(e.w, ","); e.err != nil {
+			return e.err
+		}
+	}
+	if e.err = e.enc.Encode(v); e.err == nil {
+		e.n++
+	}
+	return e.err
+}
+
+// Close 结束输出(数组时写出tail)
+func (e *Encoder) Close() error {
+	if e.err == nil && e.array {
+		_, e.err = io.WriteString(e.w, e.tail)
+	}
+	return e.err
+}
+
+// Count 已写出的项数
+func (e *Encoder) Count() int {
+	return e.n
+}
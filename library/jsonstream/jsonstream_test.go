@@ -0,0 +1,73 @@
+package jsonstream
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type entry struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+func TestArray(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewArray(&buf, `{"err":0,"data":[`, `]}`)
+	for _, v := range []entry{{"a<b", 1}, {"c", 2}} {
+		if err := e.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Close(); err != nil || e.Count() != 2 {
+		t.Fatal(err, e.Count())
+	}
+	var got struct {
+		Data []entry `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || len(got.Data) != 2 || got.Data[0].Name != "a<b" {
+		t.Fatal(buf.String(), err)
+	}
+	if !strings.Contains(buf.String(), "a<b") {
+		t.Error("html escaped", buf.String())
+	}
+
+	buf.Reset()
+	e = NewArray(&buf, `[`, `]`)
+	if err := e.Close(); err != nil || buf.String() != "[]" {
+		t.Error(buf.String(), err)
+	}
+}
+
+func TestLines(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewLines(&buf)
+	_ = e.Encode(entry{"a", 1})
+	_ = e.Encode(entry{"b", 2})
+	_ = e.Close()
+	if buf.String() != "{\"name\":\"a\",\"size\":1}\n{\"name\":\"b\",\"size\":2}\n" {
+		t.Error(buf.String())
+	}
+}
+
+type failWriter struct{ n int }
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.n--; w.n < 0 {
+		return 0, errors.New("closed")
+	}
+	return len(p), nil
+}
+
+func TestError(t *testing.T) {
+	e := NewArray(&failWriter{n: 2}, "[", "]")
+	_ = e.Encode(entry{"a", 1})
+	if err := e.Encode(entry{"b", 2}); err == nil {
+		t.Fatal("want error")
+	}
+	if err := e.Close(); err == nil || e.Count() != 1 {
+		t.Error(err, e.Count())
+	}
+}
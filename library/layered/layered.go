@@ -0,0 +1,188 @@
+package layered
+
+import (
+	"b0pass/library/fsync"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/gf-third/yaml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 分层配置: 内置默认值 < 配置文件(/etc、用户目录、当前目录) < 环境变量 < 命令行参数
+
+// Names 配置文件名，按顺序在每个目录中查找第一个存在的
+var Names = []string{"b0pass.yaml", "b0pass.yml", "b0pass.toml", "b0pass.json"}
+
+// Dirs 配置文件查找目录，按优先级从低到高排列
+func Dirs() []string {
+	dirs := []string{"/etc/b0pass"}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config", "b0pass"), home)
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, cwd)
+	}
+	return dirs
+}
+
+// Find 在各目录中查找配置文件，用户目录下也接受以.开头的隐藏文件名
+func Find(dirs []string) []string {
+	var files []string
+	for _, dir := range dirs {
+		for _, name := range Names {
+			file := filepath.Join(dir, name)
+			if _, err := os.Stat(file); err != nil {
+				file = filepath.Join(dir, "."+name)
+				if _, err := os.Stat(file); err != nil {
+					continue
+				}
+			}
+			files = append(files, file)
+			break
+		}
+	}
+	return files
+}
+
+// Load 按扩展名读取yaml/toml/json配置文件
+func Load(file string) (map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".toml":
+		m := make(map[string]interface{})
+		_, err = toml.Decode(string(b), &m)
+		v = m
+	case ".json":
+		err = json.Unmarshal(b, &v)
+	default:
+		err = yaml.Unmarshal(b, &v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	m, ok := normalize(v).(map[string]interface{})
+	if !ok && v != nil {
+		return nil, fmt.Errorf("%s: not a mapping", file)
+	}
+	if m == nil {
+		m = make(map[string]interface{})
+	}
+	return m, nil
+}
+
+// Save 按扩展名将配置写入yaml/toml/json文件，原子替换文件，写入过程中不会留下不完整的文件
+func Save(file string, m map[string]interface{}) error {
+	var buf bytes.Buffer
+	var err error
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".toml":
+		err = toml.NewEncoder(&buf).Encode(m)
+	case ".json":
+		var b []byte
+		if b, err = json.MarshalIndent(m, "", "  "); err == nil {
+			buf.Write(b)
+		}
+	default:
+		var b []byte
+		if b, err = yaml.Marshal(m); err == nil {
+			buf.Write(b)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return fsync.WriteFile(file, buf.Bytes(), 0644)
+}
+
+// Env 读取 PREFIX_SECTION_KEY=value 形式的环境变量，如 B0PASS_SETTING_PORT=9000 对应 setting.port
+// 第一个下划线分隔分组与键名，键名中的其余下划线保留
+func Env(prefix string, environ []string) map[string]interface{} {
+	m := make(map[string]interface{})
+	prefix = strings.ToUpper(prefix) + "_"
+	for _, kv := range environ {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 || !strings.HasPrefix(kv[:i], prefix) {
+			continue
+		}
+		key := strings.ToLower(kv[len(prefix):i])
+		parts := strings.SplitN(key, "_", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		Set(m, parts[0]+"."+parts[1], Value(kv[i+1:]))
+	}
+	return m
+}
+
+// Value 将字符串解析为数字、布尔或数组(JSON写法)，否则原样作为字符串
+func Value(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		if _, ok := v.(map[string]interface{}); !ok {
+			return v
+		}
+	}
+	return s
+}
+
+// Set 按点分路径设置值，中间层不存在时创建
+func Set(m map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		sub, ok := m[p].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[p] = sub
+		}
+		m = sub
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+// Merge 将src深度合并到dst，同名键以src为准
+func Merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if sv, ok := v.(map[string]interface{}); ok {
+			if dv, ok := dst[k].(map[string]interface{}); ok {
+				Merge(dv, sv)
+				continue
+			}
+			cp := make(map[string]interface{})
+			Merge(cp, sv)
+			v = cp
+		}
+		dst[k] = v
+	}
+}
+
+// normalize 将yaml解析出的map[interface{}]interface{}转换为map[string]interface{}
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, sv := range t {
+			m[fmt.Sprint(k)] = normalize(sv)
+		}
+		return m
+	case map[string]interface{}:
+		for k, sv := range t {
+			t[k] = normalize(sv)
+		}
+		return t
+	case []interface{}:
+		for i, sv := range t {
+			t[i] = normalize(sv)
+		}
+		return t
+	}
+	return v
+}
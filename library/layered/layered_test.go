@@ -0,0 +1,129 @@
+package layered
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"b0pass.yaml": "setting:\n  port: 9000\nnotify:\n  channels: [log]\n",
+		"b0pass.toml": "[setting]\nport = 9000\n[notify]\nchannels = [\"log\"]\n",
+		"b0pass.json": `{"setting":{"port":9000},"notify":{"channels":["log"]}}`,
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := Load(file)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		setting, ok := m["setting"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("%s: %#v", name, m)
+		}
+		if port, _ := Value(toString(setting["port"])).(float64); port != 9000 {
+			t.Errorf("%s: port %#v", name, setting["port"])
+		}
+		channels, _ := m["notify"].(map[string]interface{})["channels"].([]interface{})
+		if len(channels) != 1 || channels[0] != "log" {
+			t.Errorf("%s: channels %#v", name, channels)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := map[string]interface{}{"auth": map[string]interface{}{"readonly": true}, "storage": map[string]interface{}{"warn": float64(80)}}
+	for _, name := range []string{"b0pass.yaml", "b0pass.toml", "b0pass.json"} {
+		file := filepath.Join(dir, name)
+		if err := Save(file, m); err != nil {
+			t.Fatal(name, err)
+		}
+		got, err := Load(file)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		auth, _ := got["auth"].(map[string]interface{})
+		storage, _ := got["storage"].(map[string]interface{})
+		if auth["readonly"] != true || toString(storage["warn"]) != "80" {
+			t.Errorf("%s: %#v", name, got)
+		}
+	}
+	if infos, _ := ioutil.ReadDir(dir); len(infos) != 3 {
+		t.Errorf("temp files left: %d", len(infos))
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	for _, d := range []string{a, b, c} {
+		_ = os.Mkdir(d, 0755)
+	}
+	_ = ioutil.WriteFile(filepath.Join(a, "b0pass.toml"), nil, 0644)
+	_ = ioutil.WriteFile(filepath.Join(a, "b0pass.yaml"), nil, 0644)
+	_ = ioutil.WriteFile(filepath.Join(c, ".b0pass.yml"), nil, 0644)
+	got := Find([]string{a, b, c})
+	want := []string{filepath.Join(a, "b0pass.yaml"), filepath.Join(c, ".b0pass.yml")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	base := map[string]interface{}{
+		"setting": map[string]interface{}{"port": 8899, "logpath": "tmp/log"},
+		"upload":  map[string]interface{}{"verify": false},
+	}
+	Merge(base, map[string]interface{}{"setting": map[string]interface{}{"port": 9000}})
+	Merge(base, Env("b0pass", []string{
+		"B0PASS_UPLOAD_VERIFY=true",
+		"B0PASS_AUTH_USER=alice",
+		"B0PASS_STORAGE_BLOCK_AT=95",
+		"B0PASS_BROKEN=1",
+		"HOME=/root",
+	}))
+	want := map[string]interface{}{
+		"setting": map[string]interface{}{"port": 9000, "logpath": "tmp/log"},
+		"upload":  map[string]interface{}{"verify": true},
+		"auth":    map[string]interface{}{"user": "alice"},
+		"storage": map[string]interface{}{"block_at": float64(95)},
+	}
+	if !reflect.DeepEqual(base, want) {
+		t.Errorf("got %#v", base)
+	}
+	if v := Value("[1,2]"); !reflect.DeepEqual(v, []interface{}{float64(1), float64(2)}) {
+		t.Errorf("%#v", v)
+	}
+	if v := Value(`{"a":1}`); v != `{"a":1}` {
+		t.Errorf("%#v", v)
+	}
+}
+
+func toString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,105 @@
+package limiter
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// activeWindow 最近该时间内有传输的客户端参与平分带宽
+	activeWindow = time.Second
+	// idleExpire 超过该时间没有传输的客户端记录被回收
+	idleExpire = time.Minute
+	// burst 允许超前于配额的时间，避免小块读写频繁休眠
+	burst = 100 * time.Millisecond
+	// chunk 单次读取的最大字节数，保证调度粒度
+	chunk = 32 << 10
+)
+
+// Bandwidth 带宽限制器，最近有传输的客户端平分总带宽
+// 空闲客户端不占份额，某个客户端停止传输后其余客户端在activeWindow内分得它的份额
+type Bandwidth struct {
+	mu    sync.Mutex
+	rate  int64
+	flows map[string]*flow
+}
+
+// flow 单个客户端的传输进度
+type flow struct {
+	next time.Time // 按份额计算的下一次可传输时间
+	last time.Time // 最近一次传输时间
+}
+
+// NewBandwidth 创建带宽限制器，rate为总带宽(字节/秒)，<=0不限制
+func NewBandwidth(rate int64) *Bandwidth {
+	return &Bandwidth{rate: rate, flows: make(map[string]*flow)}
+}
+
+// SetRate 修改总带宽(字节/秒)，<=0不限制
+func (b *Bandwidth) SetRate(rate int64) {
+	b.mu.Lock()
+	b.rate = rate
+	b.mu.Unlock()
+}
+
+// Wait key传输了n字节后按其份额等待
+func (b *Bandwidth) Wait(key string, n int) {
+	if d := b.reserve(key, n, time.Now()); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// reserve 登记key在now时传输了n字节，返回需要等待的时间
+func (b *Bandwidth) reserve(key string, n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 || n <= 0 {
+		return 0
+	}
+	f, ok := b.flows[key]
+	if !ok {
+		f = &flow{next: now}
+		b.flows[key] = f
+	}
+	f.last = now
+	active := 0
+	for k, o := range b.flows {
+		switch idle := now.Sub(o.last); {
+		case idle <= activeWindow:
+			active++
+		case idle > idleExpire:
+			delete(b.flows, k)
+		}
+	}
+	share := b.rate / int64(active)
+	if share <= 0 {
+		share = 1
+	}
+	if f.next.Before(now) {
+		f.next = now
+	}
+	f.next = f.next.Add(time.Duration(int64(n) * int64(time.Second) / share))
+	return f.next.Sub(now) - burst
+}
+
+// Reader 按key的份额限速读取
+func (b *Bandwidth) Reader(key string, r io.ReadCloser) io.ReadCloser {
+	return &bandwidthReader{ReadCloser: r, b: b, key: key}
+}
+
+// bandwidthReader 限速读取器
+type bandwidthReader struct {
+	io.ReadCloser
+	b   *Bandwidth
+	key string
+}
+
+func (r *bandwidthReader) Read(p []byte) (int, error) {
+	if len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.b.Wait(r.key, n)
+	return n, err
+}
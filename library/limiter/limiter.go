@@ -0,0 +1,116 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter 全局+单客户端两级并发限制器
+// 超出并发数的请求排队等待，等待超时或队列已满时申请失败
+type Limiter struct {
+	global  chan struct{}
+	client  int
+	queue   int
+	mu      sync.Mutex
+	waiting int
+	clients map[string]*clientSlot
+}
+
+// clientSlot 单个客户端的并发名额
+type clientSlot struct {
+	ch   chan struct{}
+	refs int
+}
+
+// New 创建限制器
+// global: 全局并发数; client: 单客户端并发数; queue: 最大排队数(<=0不限制)
+func New(global, client, queue int) *Limiter {
+	if global <= 0 {
+		global = 1
+	}
+	if client <= 0 || client > global {
+		client = global
+	}
+	return &Limiter{
+		global:  make(chan struct{}, global),
+		client:  client,
+		queue:   queue,
+		clients: make(map[string]*clientSlot),
+	}
+}
+
+// Acquire 在wait时间内为key申请一个执行名额
+// 成功时返回释放函数(可重复调用)，失败时返回false
+func (l *Limiter) Acquire(key string, wait time.Duration) (func(), bool) {
+	slot, ok := l.enter(key)
+	if !ok {
+		return nil, false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case slot.ch <- struct{}{}:
+	case <-timer.C:
+		l.leave(key, true)
+		return nil, false
+	}
+	select {
+	case l.global <- struct{}{}:
+	case <-timer.C:
+		<-slot.ch
+		l.leave(key, true)
+		return nil, false
+	}
+	l.mu.Lock()
+	l.waiting--
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.global
+			<-slot.ch
+			l.leave(key, false)
+		})
+	}, true
+}
+
+// Stats 返回当前执行数与排队数
+func (l *Limiter) Stats() (active, waiting int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.global), l.waiting
+}
+
+// enter 登记排队并取得客户端名额对象
+func (l *Limiter) enter(key string) (*clientSlot, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.queue > 0 && l.waiting >= l.queue {
+		return nil, false
+	}
+	l.waiting++
+	slot, ok := l.clients[key]
+	if !ok {
+		slot = &clientSlot{ch: make(chan struct{}, l.client)}
+		l.clients[key] = slot
+	}
+	slot.refs++
+	return slot, true
+}
+
+// leave 释放客户端名额对象的引用，空闲时回收
+func (l *Limiter) leave(key string, waiting bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if waiting {
+		l.waiting--
+	}
+	if slot, ok := l.clients[key]; ok {
+		slot.refs--
+		if slot.refs <= 0 {
+			delete(l.clients, key)
+		}
+	}
+}
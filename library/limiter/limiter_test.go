@@ -0,0 +1,137 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquireClient(t *testing.T) {
+	l := New(4, 1, 0)
+	release, ok := l.Acquire("a", time.Second)
+	if !ok {
+		t.Fatal("first acquire failed")
+	}
+	// 同一客户端超出并发数
+	if _, ok := l.Acquire("a", 50*time.Millisecond); ok {
+		t.Error("client limit not enforced")
+	}
+	// 其他客户端不受影响
+	other, ok := l.Acquire("b", 50*time.Millisecond)
+	if !ok {
+		t.Error("other client blocked")
+	}
+	other()
+	release()
+	release()
+	if active, waiting := l.Stats(); active != 0 || waiting != 0 {
+		t.Errorf("stats not released: %d %d", active, waiting)
+	}
+}
+
+func TestAcquireGlobal(t *testing.T) {
+	l := New(1, 1, 1)
+	release, _ := l.Acquire("a", time.Second)
+	done := make(chan bool)
+	go func() {
+		_, ok := l.Acquire("b", time.Second)
+		done <- ok
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// 队列已满
+	if _, ok := l.Acquire("c", time.Second); ok {
+		t.Error("queue limit not enforced")
+	}
+	release()
+	if ok := <-done; !ok {
+		t.Error("queued acquire failed")
+	}
+}
+
+func TestBandwidthFair(t *testing.T) {
+	b := NewBandwidth(1000)
+	now := time.Now()
+	// 单个客户端独占总带宽
+	if d := b.reserve("a", 1000, now); d != time.Second-burst {
+		t.Errorf("single client wait %v", d)
+	}
+	// 两个客户端同时传输时各得一半
+	if d := b.reserve("b", 500, now); d != time.Second-burst {
+		t.Errorf("shared client wait %v", d)
+	}
+	// a空闲后b独占
+	later := now.Add(2 * time.Second)
+	if d := b.reserve("b", 1000, later); d != time.Second-burst {
+		t.Errorf("after idle wait %v", d)
+	}
+	b.SetRate(0)
+	if d := b.reserve("a", 1000, later); d != 0 {
+		t.Errorf("unlimited wait %v", d)
+	}
+}
+
+func TestSlots(t *testing.T) {
+	s := NewSlots(2, 2, time.Minute)
+	now := time.Now()
+	a, _ := s.Acquire("a", now)
+	b, _ := s.Acquire("b", now)
+	if a == nil || b == nil {
+		t.Fatal("free slots")
+	}
+	if _, pos := s.Acquire("c", now); pos != 1 {
+		t.Error("c position", pos)
+	}
+	if _, pos := s.Acquire("d", now); pos != 2 {
+		t.Error("d position", pos)
+	}
+	// 队列已满
+	if release, pos := s.Acquire("e", now); release != nil || pos != 0 {
+		t.Error("queue full", pos)
+	}
+	// 重试时保持位置
+	if _, pos := s.Acquire("d", now); pos != 2 {
+		t.Error("d retry", pos)
+	}
+	a()
+	a()
+	// 空出的名额留给队首，后来的客户端不能插队
+	if release, _ := s.Acquire("d", now); release != nil {
+		t.Error("d jumped the queue")
+	}
+	c, _ := s.Acquire("c", now)
+	if c == nil || s.Position("d") != 1 {
+		t.Error("c at head", s.Position("d"))
+	}
+	// 超过ttl未重试的客户端移出队列
+	b()
+	if release, pos := s.Acquire("x", now.Add(2*time.Minute)); release == nil {
+		t.Error("expired queue", pos)
+	}
+	if max, active, queued := s.Stats(); max != 2 || active != 2 || queued != 0 {
+		t.Error("stats", max, active, queued)
+	}
+}
+
+func TestSlotsWait(t *testing.T) {
+	s := NewSlots(1, 0, time.Minute)
+	a, _ := s.Acquire("a", time.Now())
+	done := make(chan bool)
+	go func() {
+		release, _ := s.Wait(context.Background(), "b", time.Second)
+		done <- release != nil
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if s.Position("b") != 1 {
+		t.Error("b not queued")
+	}
+	a()
+	if !<-done {
+		t.Error("waiting request not woken")
+	}
+	if release, pos := s.Wait(context.Background(), "c", 20*time.Millisecond); release != nil || pos != 1 {
+		t.Error("timeout", pos)
+	}
+	if release, _ := NewSlots(0, 0, 0).Acquire("a", time.Now()); release == nil {
+		t.Error("unlimited")
+	}
+}
@@ -0,0 +1,138 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Slots 上传名额: 最多max个同时进行，超出的客户端按先后排队，重试时保持原来的位置；
+// 排在前面的客户端优先得到空出的名额，超过ttl未重试的客户端移出队列
+type Slots struct {
+	mu      sync.Mutex
+	max     int
+	limit   int
+	ttl     time.Duration
+	active  int
+	queue   []*waiter
+	changed chan struct{}
+}
+
+// waiter 排队中的客户端
+type waiter struct {
+	client string
+	seen   time.Time
+}
+
+// NewSlots max<=0不限制；queue为最多排队的客户端数，<=0不限制
+func NewSlots(max, queue int, ttl time.Duration) *Slots {
+	return &Slots{max: max, limit: queue, ttl: ttl, changed: make(chan struct{})}
+}
+
+// Acquire 立即为client申请名额，成功时返回释放函数(可重复调用)；
+// 失败时client进入或留在队列中，返回排队位置(从1开始)，队列已满时位置为0
+func (s *Slots) Acquire(client string, now time.Time) (func(), int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.max <= 0 {
+		return func() {}, 0
+	}
+	s.expire(now)
+	index := -1
+	for i, w := range s.queue {
+		if w.client == client {
+			index = i
+			w.seen = now
+			break
+		}
+	}
+	free := s.max - s.active
+	switch {
+	case index >= 0 && index < free:
+		// 排在前面的客户端得到名额
+		s.queue = append(s.queue[:index], s.queue[index+1:]...)
+	case index < 0 && free > len(s.queue):
+		// 空出的名额多于排队的客户端，不需要排队
+	case index >= 0:
+		return nil, index + 1
+	case s.limit > 0 && len(s.queue) >= s.limit:
+		return nil, 0
+	default:
+		s.queue = append(s.queue, &waiter{client: client, seen: now})
+		return nil, len(s.queue)
+	}
+	s.active++
+	var once sync.Once
+	return func() {
+		once.Do(s.release)
+	}, 0
+}
+
+// Wait 在wait时间内排队申请名额，名额空出时立即重试；失败时返回排队位置，队列已满时为0
+func (s *Slots) Wait(ctx context.Context, client string, wait time.Duration) (func(), int) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		s.mu.Unlock()
+		release, position := s.Acquire(client, time.Now())
+		if release != nil || position == 0 {
+			return release, position
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, position
+		case <-ctx.Done():
+			return nil, position
+		}
+	}
+}
+
+// Stats 名额上限、进行中的数量与排队的客户端数
+func (s *Slots) Stats() (max, active, queued int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+	return s.max, s.active, len(s.queue)
+}
+
+// Position client的排队位置，不在队列中时为0
+func (s *Slots) Position(client string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, w := range s.queue {
+		if w.client == client {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// release 释放名额并唤醒等待的请求
+func (s *Slots) release() {
+	s.mu.Lock()
+	s.active--
+	s.notify()
+	s.mu.Unlock()
+}
+
+// expire 移出超过ttl未重试的客户端
+func (s *Slots) expire(now time.Time) {
+	kept := s.queue[:0]
+	for _, w := range s.queue {
+		if now.Sub(w.seen) <= s.ttl {
+			kept = append(kept, w)
+		}
+	}
+	if len(kept) < len(s.queue) {
+		s.queue = kept
+		s.notify()
+	}
+}
+
+func (s *Slots) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
@@ -0,0 +1,173 @@
+package loadtest
+
+import (
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 传输压测: 多个并发worker重复执行上传/下载等操作，按操作类型统计延迟分位数与吞吐量
+
+// Result 一次操作的结果
+type Result struct {
+	Kind  string // 操作类型，如 upload、download
+	Bytes int64  // 传输的字节数
+	Err   error
+}
+
+// Op 执行一次操作，worker为并发序号，seq为全局操作序号
+type Op func(worker, seq int) Result
+
+// Options 压测选项
+type Options struct {
+	Workers  int           // 并发数
+	Count    int           // 操作总数，0为不限(须指定Duration)
+	Duration time.Duration // 最长运行时间，0为不限
+}
+
+// Report 某类操作的统计
+type Report struct {
+	Kind        string        `json:"kind"`
+	Ops         int           `json:"ops"`
+	Errors      int           `json:"errors"`
+	Bytes       int64         `json:"bytes"`
+	Elapsed     time.Duration `json:"elapsed"`
+	OpsPerSec   float64       `json:"ops_per_sec"`
+	BytesPerSec float64       `json:"bytes_per_sec"`
+	Mean        time.Duration `json:"mean"`
+	P50         time.Duration `json:"p50"`
+	P90         time.Duration `json:"p90"`
+	P99         time.Duration `json:"p99"`
+	Max         time.Duration `json:"max"`
+	FirstErr    string        `json:"first_err,omitempty"`
+}
+
+// sample 一次操作的耗时
+type sample struct {
+	Result
+	latency time.Duration
+}
+
+// Run 执行压测，返回按操作类型排序的统计；失败的操作计入错误数，不计入延迟与吞吐量
+func Run(o Options, op Op) []Report {
+	if o.Workers <= 0 {
+		o.Workers = 1
+	}
+	var deadline time.Time
+	if o.Duration > 0 {
+		deadline = time.Now().Add(o.Duration)
+	}
+	var (
+		mu      sync.Mutex
+		samples []sample
+		next    int64 = -1
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for w := 0; w < o.Workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for {
+				seq := int(atomic.AddInt64(&next, 1))
+				if o.Count > 0 && seq >= o.Count || !deadline.IsZero() && time.Now().After(deadline) {
+					return
+				}
+				begin := time.Now()
+				ret := op(worker, seq)
+				s := sample{Result: ret, latency: time.Since(begin)}
+				mu.Lock()
+				samples = append(samples, s)
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+	return summarize(samplesByKind(samples), time.Since(start))
+}
+
+// samplesByKind 按操作类型分组
+func samplesByKind(samples []sample) map[string][]sample {
+	m := make(map[string][]sample)
+	for _, s := range samples {
+		m[s.Kind] = append(m[s.Kind], s)
+	}
+	return m
+}
+
+// summarize 计算各类操作的统计，elapsed为整个压测的耗时
+func summarize(groups map[string][]sample, elapsed time.Duration) []Report {
+	reports := make([]Report, 0, len(groups))
+	for kind, samples := range groups {
+		r := Report{Kind: kind, Ops: len(samples), Elapsed: elapsed}
+		var latencies []time.Duration
+		var total time.Duration
+		for _, s := range samples {
+			if s.Err != nil {
+				r.Errors++
+				if r.FirstErr == "" {
+					r.FirstErr = s.Err.Error()
+				}
+				continue
+			}
+			r.Bytes += s.Bytes
+			total += s.latency
+			latencies = append(latencies, s.latency)
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		if n := len(latencies); n > 0 {
+			r.Mean = total / time.Duration(n)
+			r.P50 = Percentile(latencies, 50)
+			r.P90 = Percentile(latencies, 90)
+			r.P99 = Percentile(latencies, 99)
+			r.Max = latencies[n-1]
+		}
+		if secs := elapsed.Seconds(); secs > 0 {
+			r.OpsPerSec = float64(len(latencies)) / secs
+			r.BytesPerSec = float64(r.Bytes) / secs
+		}
+		reports = append(reports, r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Kind < reports[j].Kind })
+	return reports
+}
+
+// Percentile 已排序延迟的p分位数(最近秩法)
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// Data 大小为size的合成数据，按seed生成且不占用与大小相当的内存
+func Data(size, seed int64) io.Reader {
+	return io.LimitReader(&pattern{x: uint64(seed)*2654435761 + 1}, size)
+}
+
+// pattern 无限的伪随机字节流(xorshift)，避免被压缩或去重
+type pattern struct {
+	x uint64
+}
+
+func (p *pattern) Read(b []byte) (int, error) {
+	for i := range b {
+		if i%8 == 0 {
+			p.x ^= p.x << 13
+			p.x ^= p.x >> 7
+			p.x ^= p.x << 17
+		}
+		b[i] = byte(p.x >> (uint(i%8) * 8))
+	}
+	return len(b), nil
+}
@@ -0,0 +1,66 @@
+package loadtest
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	reports := Run(Options{Workers: 4, Count: 20}, func(worker, seq int) Result {
+		if seq%2 == 0 {
+			return Result{Kind: "upload", Bytes: 100}
+		}
+		if seq == 1 {
+			return Result{Kind: "download", Err: errors.New("boom")}
+		}
+		return Result{Kind: "download", Bytes: 10}
+	})
+	if len(reports) != 2 || reports[0].Kind != "download" || reports[1].Kind != "upload" {
+		t.Fatal(reports)
+	}
+	if d := reports[0]; d.Ops != 10 || d.Errors != 1 || d.Bytes != 90 || d.FirstErr != "boom" {
+		t.Error(d)
+	}
+	if u := reports[1]; u.Ops != 10 || u.Errors != 0 || u.Bytes != 1000 || u.BytesPerSec <= 0 {
+		t.Error(u)
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	start := time.Now()
+	reports := Run(Options{Workers: 2, Duration: 50 * time.Millisecond}, func(worker, seq int) Result {
+		time.Sleep(5 * time.Millisecond)
+		return Result{Kind: "x"}
+	})
+	if time.Since(start) > time.Second || len(reports) != 1 || reports[0].Ops < 2 {
+		t.Error(reports)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var d []time.Duration
+	for i := 1; i <= 100; i++ {
+		d = append(d, time.Duration(i))
+	}
+	if Percentile(d, 50) != 50 || Percentile(d, 99) != 99 || Percentile(d, 100) != 100 || Percentile(d, 0) != 1 {
+		t.Error(Percentile(d, 50), Percentile(d, 99))
+	}
+	if Percentile(nil, 50) != 0 {
+		t.Error("empty")
+	}
+}
+
+func TestData(t *testing.T) {
+	a, _ := ioutil.ReadAll(Data(1000, 1))
+	b, _ := ioutil.ReadAll(Data(1000, 1))
+	c, _ := ioutil.ReadAll(Data(1000, 2))
+	if len(a) != 1000 || string(a) != string(b) || string(a) == string(c) {
+		t.Error(len(a))
+	}
+	if n, _ := io.Copy(ioutil.Discard, Data(5<<20, 3)); n != 5<<20 {
+		t.Error(n)
+	}
+}
@@ -0,0 +1,203 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level 日志级别
+type Level int
+
+// 日志级别
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "unknown"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel 解析级别名称(debug/info/warn/error)
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("logger: unknown level %q", s)
+}
+
+// Options 日志配置
+type Options struct {
+	File    string // 日志文件，为空时不写文件
+	Level   Level  // 低于该级别的日志不输出
+	JSON    bool   // 按JSON行输出，否则为文本
+	MaxSize int64  // 单个文件大小(字节)，超过后轮转，<=0不轮转
+	Backups int    // 轮转保留个数
+	Stdout  bool   // 同时输出到标准输出
+}
+
+// Logger 分级日志，输出到标准输出与按大小轮转的文件
+type Logger struct {
+	opt  Options
+	mu   sync.Mutex
+	file *rotator
+	out  io.Writer
+}
+
+// New 创建日志
+func New(opt Options) *Logger {
+	l := &Logger{opt: opt}
+	if opt.File != "" {
+		l.file = &rotator{file: opt.File, maxSize: opt.MaxSize, backups: opt.Backups}
+	}
+	if opt.Stdout {
+		l.out = os.Stdout
+	}
+	return l
+}
+
+// Enabled 是否输出该级别的日志
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.opt.Level
+}
+
+// Log 输出一条日志，kv为成对的字段名与值
+func (l *Logger) Log(level Level, cat, msg string, kv ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	line := l.format(time.Now(), level, cat, msg, kv)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.out != nil {
+		_, _ = l.out.Write(line)
+	}
+	if l.file != nil {
+		if _, err := l.file.Write(line); err != nil && l.out == nil {
+			_, _ = os.Stderr.Write(line)
+		}
+	}
+}
+
+// format 按配置格式化为一行
+func (l *Logger) format(t time.Time, level Level, cat, msg string, kv []interface{}) []byte {
+	if l.opt.JSON {
+		m := map[string]interface{}{
+			"time": t.Format(time.RFC3339Nano), "level": level.String(), "cat": cat, "msg": msg,
+		}
+		for i := 0; i+1 < len(kv); i += 2 {
+			m[fmt.Sprint(kv[i])] = value(kv[i+1])
+		}
+		b, _ := json.Marshal(m)
+		return append(b, '\n')
+	}
+	var buf bytes.Buffer
+	buf.WriteString(t.Format("2006-01-02 15:04:05.000"))
+	buf.WriteString(" [" + strings.ToUpper(level.String()) + "]")
+	if cat != "" {
+		buf.WriteString(" [" + cat + "]")
+	}
+	buf.WriteString(" " + msg)
+	for i := 0; i+1 < len(kv); i += 2 {
+		v := fmt.Sprint(value(kv[i+1]))
+		if strings.ContainsAny(v, " \"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&buf, " %v=%s", kv[i], v)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// value 字段值，error转为字符串以便JSON输出
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+// Close 关闭日志文件
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Writer 将写入的每一行作为指定级别与分类的日志，用于接管第三方库的日志输出
+func (l *Logger) Writer(level Level, cat string) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		for _, line := range strings.Split(strings.TrimRight(string(p), "\r\n"), "\n") {
+			if line != "" {
+				l.Log(level, cat, line)
+			}
+		}
+		return len(p), nil
+	})
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+var (
+	stdMu sync.RWMutex
+	std   = New(Options{Level: LevelInfo, Stdout: true})
+)
+
+// Init 替换默认日志
+func Init(opt Options) {
+	stdMu.Lock()
+	old := std
+	std = New(opt)
+	stdMu.Unlock()
+	_ = old.Close()
+}
+
+// Default 默认日志
+func Default() *Logger {
+	stdMu.RLock()
+	defer stdMu.RUnlock()
+	return std
+}
+
+// Debug 输出调试日志
+func Debug(cat, msg string, kv ...interface{}) {
+	Default().Log(LevelDebug, cat, msg, kv...)
+}
+
+// Info 输出一般日志
+func Info(cat, msg string, kv ...interface{}) {
+	Default().Log(LevelInfo, cat, msg, kv...)
+}
+
+// Warn 输出警告日志
+func Warn(cat, msg string, kv ...interface{}) {
+	Default().Log(LevelWarn, cat, msg, kv...)
+}
+
+// Error 输出错误日志
+func Error(cat, msg string, kv ...interface{}) {
+	Default().Log(LevelError, cat, msg, kv...)
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLevelsJSON(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logger")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "b0pass.log")
+	l := New(Options{File: file, Level: LevelInfo, JSON: true})
+	l.Log(LevelDebug, "test", "hidden")
+	l.Log(LevelWarn, "ftp", "listen failed", "port", 21, "err", errors.New("in use"))
+	_ = l.Close()
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("want 1 line, got %q", b)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["level"] != "warn" || m["cat"] != "ftp" || m["err"] != "in use" || m["port"] != float64(21) {
+		t.Errorf("bad entry: %v", m)
+	}
+	if lv, err := ParseLevel("ERROR"); err != nil || lv != LevelError {
+		t.Error(lv, err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logger")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "b0pass.log")
+	l := New(Options{File: file, Level: LevelDebug, MaxSize: 200, Backups: 2})
+	for i := 0; i < 20; i++ {
+		l.Log(LevelInfo, "test", "a message long enough to rotate", "i", i)
+	}
+	_ = l.Close()
+	for _, name := range []string{file, file + ".1", file + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(file + ".3"); err == nil {
+		t.Error("too many backups kept")
+	}
+	f, _ := os.Open(file)
+	defer func() { _ = f.Close() }()
+	s := bufio.NewScanner(f)
+	s.Scan()
+	if !strings.Contains(s.Text(), "[INFO] [test]") {
+		t.Errorf("bad console line: %s", s.Text())
+	}
+}
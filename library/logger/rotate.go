@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// rotator 按大小轮转的日志文件: file写满后依次改名为file.1、file.2...，超出保留个数的删除
+type rotator struct {
+	file    string
+	maxSize int64
+	backups int
+	f       *os.File
+	size    int64
+}
+
+func (r *rotator) Write(p []byte) (int, error) {
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		r.rotate()
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// open 以追加方式打开日志文件
+func (r *rotator) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// rotate 关闭当前文件并依次改名
+func (r *rotator) rotate() {
+	_ = r.f.Close()
+	r.f = nil
+	if r.backups <= 0 {
+		_ = os.Remove(r.file)
+		return
+	}
+	_ = os.Remove(r.backupName(r.backups))
+	for i := r.backups - 1; i >= 1; i-- {
+		_ = os.Rename(r.backupName(i), r.backupName(i+1))
+	}
+	_ = os.Rename(r.file, r.backupName(1))
+}
+
+func (r *rotator) backupName(n int) string {
+	return fmt.Sprintf("%s.%d", r.file, n)
+}
+
+// Close 关闭文件，下次写入时重新打开
+func (r *rotator) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
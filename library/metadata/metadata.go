@@ -0,0 +1,188 @@
+package metadata
+
+import (
+	"b0pass/library/fsync"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// Store 文件元数据存储，key为共享目录下的相对路径
+// 数据以JSON格式整体持久化，原子替换文件
+type Store struct {
+	mu   sync.RWMutex
+	file string
+	data map[string]map[string]string
+}
+
+var std = &Store{data: make(map[string]map[string]string)}
+
+// Init 初始化默认存储
+func Init(file string) {
+	std = Open(file)
+}
+
+// Get 读取默认存储中的字段
+func Get(key, field string) string {
+	return std.Get(key, field)
+}
+
+// Fields 读取默认存储中的全部字段
+func Fields(key string) map[string]string {
+	return std.Fields(key)
+}
+
+// Set 写入默认存储
+func Set(key string, fields map[string]string) {
+	_ = std.Set(key, fields)
+}
+
+// SetMany 在默认存储中批量写入
+func SetMany(items map[string]map[string]string) {
+	_ = std.SetMany(items)
+}
+
+// Find 在默认存储中查找字段值匹配的key
+func Find(field, value string) []string {
+	return std.Find(field, value)
+}
+
+// Each 遍历默认存储
+func Each(fn func(key string, fields map[string]string)) {
+	std.Each(fn)
+}
+
+// Delete 删除默认存储中key及其子路径
+func Delete(key string) {
+	_ = std.Delete(key)
+}
+
+// Rename 在默认存储中移动key及其子路径
+func Rename(oldKey, newKey string) {
+	_ = std.Rename(oldKey, newKey)
+}
+
+// Open 打开存储文件，文件不存在时创建空存储
+func Open(file string) *Store {
+	s := &Store{file: file, data: make(map[string]map[string]string)}
+	if b, err := ioutil.ReadFile(file); err == nil {
+		_ = json.Unmarshal(b, &s.data)
+	}
+	return s
+}
+
+// Get 读取字段
+func (s *Store) Get(key, field string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data[key][field]
+}
+
+// Fields 读取key的全部字段(副本)
+func (s *Store) Fields(key string) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make(map[string]string, len(s.data[key]))
+	for k, v := range s.data[key] {
+		ret[k] = v
+	}
+	return ret
+}
+
+// Find 查找字段值匹配的key
+func (s *Store) Find(field, value string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []string
+	for k, m := range s.data {
+		if m[field] == value {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Each 遍历全部key，fn中不能修改fields，也不能调用存储的写入方法
+func (s *Store) Each(fn func(key string, fields map[string]string)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for k, m := range s.data {
+		fn(k, m)
+	}
+}
+
+// Set 合并写入字段，值为空字符串时删除该字段
+func (s *Store) Set(key string, fields map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.merge(key, fields)
+	return s.save()
+}
+
+// SetMany 批量合并写入多个key的字段，只持久化一次
+func (s *Store) SetMany(items map[string]map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, fields := range items {
+		s.merge(key, fields)
+	}
+	return s.save()
+}
+
+// merge 合并字段，调用方持有锁
+func (s *Store) merge(key string, fields map[string]string) {
+	m, ok := s.data[key]
+	if !ok {
+		m = make(map[string]string)
+		s.data[key] = m
+	}
+	for k, v := range fields {
+		if v == "" {
+			delete(m, k)
+		} else {
+			m[k] = v
+		}
+	}
+	if len(m) == 0 {
+		delete(s.data, key)
+	}
+}
+
+// Delete 删除key及其子路径的元数据
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.data {
+		if isUnder(k, key) {
+			delete(s.data, k)
+		}
+	}
+	return s.save()
+}
+
+// Rename 移动key及其子路径的元数据
+func (s *Store) Rename(oldKey, newKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.data {
+		if isUnder(k, oldKey) {
+			delete(s.data, k)
+			s.data[newKey+strings.TrimPrefix(k, oldKey)] = v
+		}
+	}
+	return s.save()
+}
+
+// save 持久化到文件
+func (s *Store) save() error {
+	if s.file == "" {
+		return nil
+	}
+	return fsync.WriteJSON(s.file, s.data, 0644)
+}
+
+// isUnder 判断k是否为key本身或其子路径
+func isUnder(k, key string) bool {
+	return k == key || strings.HasPrefix(k, strings.TrimSuffix(key, "/")+"/")
+}
@@ -0,0 +1,53 @@
+package metadata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "meta")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "meta.json")
+
+	s := Open(file)
+	_ = s.Set("photos/a.jpg", map[string]string{"scan": "clean", "md5": "x"})
+	_ = s.Set("photos/b.jpg", map[string]string{"scan": "pending"})
+	_ = s.Set("photos2/c.jpg", map[string]string{"scan": "clean"})
+	_ = s.Set("photos/a.jpg", map[string]string{"md5": ""})
+
+	s = Open(file)
+	if got := s.Get("photos/a.jpg", "scan"); got != "clean" {
+		t.Errorf("reload got %q", got)
+	}
+	if got := s.Get("photos/a.jpg", "md5"); got != "" {
+		t.Errorf("empty value not removed: %q", got)
+	}
+
+	if keys := s.Find("scan", "pending"); len(keys) != 1 || keys[0] != "photos/b.jpg" {
+		t.Errorf("find got %v", keys)
+	}
+
+	_ = s.SetMany(map[string]map[string]string{
+		"photos/b.jpg": {"scan": "clean"},
+		"photos/d.jpg": {"size": "3"},
+	})
+	if s = Open(file); s.Get("photos/b.jpg", "scan") != "clean" || s.Get("photos/d.jpg", "size") != "3" {
+		t.Error("set many", s.Fields("photos/b.jpg"), s.Fields("photos/d.jpg"))
+	}
+	_ = s.Set("photos/b.jpg", map[string]string{"scan": "pending"})
+
+	_ = s.Rename("photos", "albums")
+	if s.Get("albums/b.jpg", "scan") != "pending" || s.Get("photos/b.jpg", "scan") != "" {
+		t.Error("rename failed")
+	}
+	_ = s.Delete("albums")
+	if len(s.Fields("albums/a.jpg")) != 0 {
+		t.Error("delete failed")
+	}
+	if s.Get("photos2/c.jpg", "scan") != "clean" {
+		t.Error("sibling prefix removed")
+	}
+}
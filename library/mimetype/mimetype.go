@@ -0,0 +1,134 @@
+package mimetype
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 文件类型: 按开头512字节识别(http.DetectContentType)，识别结果只是通用类型或容器格式时
+// (如octet-stream、纯文本、zip、MP4容器)按扩展名细化；扩展名表可用Register补充，
+// 不随系统的mime.types变化，各平台上结果一致
+
+// SniffLen 识别类型读取的字节数
+const SniffLen = 512
+
+// Unknown 无法识别的类型
+const Unknown = "application/octet-stream"
+
+var (
+	mu sync.RWMutex
+	// types 扩展名(小写，不含点)对应的类型，优先于系统的mime.types
+	types = map[string]string{
+		// 视频、音频
+		"mp4": "video/mp4", "m4v": "video/mp4", "mov": "video/quicktime", "mkv": "video/x-matroska", "webm": "video/webm",
+		"avi": "video/x-msvideo", "ts": "video/mp2t", "ogv": "video/ogg", "3gp": "video/3gpp",
+		"mp3": "audio/mpeg", "m4a": "audio/mp4", "aac": "audio/aac", "flac": "audio/flac", "wav": "audio/wav",
+		"ogg": "audio/ogg", "oga": "audio/ogg", "opus": "audio/ogg", "wma": "audio/x-ms-wma",
+		// 图片
+		"jpg": "image/jpeg", "jpeg": "image/jpeg", "png": "image/png", "gif": "image/gif", "webp": "image/webp",
+		"avif": "image/avif", "heic": "image/heic", "heif": "image/heif", "bmp": "image/bmp", "ico": "image/x-icon",
+		"tif": "image/tiff", "tiff": "image/tiff", "svg": "image/svg+xml",
+		// 文本
+		"txt": "text/plain; charset=utf-8", "log": "text/plain; charset=utf-8", "md": "text/markdown; charset=utf-8",
+		"csv": "text/csv; charset=utf-8", "html": "text/html; charset=utf-8", "htm": "text/html; charset=utf-8",
+		"css": "text/css; charset=utf-8", "js": "text/javascript; charset=utf-8", "json": "application/json",
+		"xml": "text/xml; charset=utf-8", "vtt": "text/vtt; charset=utf-8", "srt": "application/x-subrip",
+		// 文档
+		"pdf": "application/pdf", "epub": "application/epub+zip",
+		"doc": "application/msword", "xls": "application/vnd.ms-excel", "ppt": "application/vnd.ms-powerpoint",
+		"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"odt":  "application/vnd.oasis.opendocument.text", "ods": "application/vnd.oasis.opendocument.spreadsheet",
+		// 压缩包与安装包
+		"zip": "application/zip", "7z": "application/x-7z-compressed", "rar": "application/vnd.rar",
+		"gz": "application/gzip", "tgz": "application/gzip", "tar": "application/x-tar", "zst": "application/zstd",
+		"xz": "application/x-xz", "bz2": "application/x-bzip2", "iso": "application/x-iso9660-image",
+		"apk": "application/vnd.android.package-archive", "jar": "application/java-archive",
+		"dmg": "application/x-apple-diskimage", "exe": "application/vnd.microsoft.portable-executable",
+		"msi": "application/x-msi", "deb": "application/vnd.debian.binary-package", "wasm": "application/wasm",
+	}
+	// refine 识别为这些通用类型或容器格式时，扩展名给出的类型以这些前缀开头则采用扩展名的；
+	// 内容像网页而扩展名已知时同样按扩展名，避免上传的.txt被浏览器当作网页执行
+	refine = map[string][]string{
+		Unknown:                     {""},
+		"text/html; charset=utf-8":  {""},
+		"text/xml; charset=utf-8":   {""},
+		"text/plain; charset=utf-8": {"text/", "application/json", "application/x-subrip", "image/svg+xml"},
+		"application/zip":           {"application/"},
+		"application/x-gzip":        {"application/gzip"},
+		"video/mp4":                 {"video/", "audio/"},
+		"video/webm":                {"video/", "audio/"},
+		"application/ogg":           {"video/", "audio/"},
+		"audio/wave":                {"audio/wav"},
+	}
+)
+
+// Register 注册扩展名(不区分大小写，可带点)的类型，覆盖已有的
+func Register(ext, typ string) {
+	mu.Lock()
+	defer mu.Unlock()
+	types[strings.ToLower(strings.TrimPrefix(ext, "."))] = typ
+}
+
+// ByExt 按扩展名的类型，未知时为空
+func ByExt(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	mu.RLock()
+	typ := types[strings.TrimPrefix(ext, ".")]
+	mu.RUnlock()
+	if typ == "" && ext != "" {
+		typ = mime.TypeByExtension(ext)
+	}
+	return typ
+}
+
+// Detect 按文件开头的内容head(至多SniffLen字节)识别类型，识别结果不够具体时按文件名name的扩展名
+func Detect(name string, head []byte) string {
+	sniffed := http.DetectContentType(head)
+	prefixes, ok := refine[sniffed]
+	if !ok {
+		return sniffed
+	}
+	byExt := ByExt(name)
+	for _, p := range prefixes {
+		if byExt != "" && strings.HasPrefix(byExt, p) {
+			// 文本类型沿用识别出的字符集
+			if strings.HasPrefix(byExt, "text/") && !strings.Contains(byExt, "charset=") {
+				byExt += "; charset=utf-8"
+			}
+			return byExt
+		}
+	}
+	return sniffed
+}
+
+// File 读取文件开头识别类型，无法读取时按扩展名
+func File(file string) string {
+	fd, err := os.Open(file)
+	if err != nil {
+		if typ := ByExt(file); typ != "" {
+			return typ
+		}
+		return Unknown
+	}
+	defer func() { _ = fd.Close() }()
+	buf := make([]byte, SniffLen)
+	n, _ := io.ReadFull(fd, buf)
+	return Detect(file, buf[:n])
+}
+
+// Inline 浏览器能直接显示或播放的类型(图片、音视频、文本、PDF)，其余应作为附件下载
+func Inline(typ string) bool {
+	base := strings.TrimSpace(strings.SplitN(typ, ";", 2)[0])
+	switch {
+	case strings.HasPrefix(base, "image/"), strings.HasPrefix(base, "video/"), strings.HasPrefix(base, "audio/"), strings.HasPrefix(base, "text/"):
+		return true
+	}
+	return base == "application/pdf" || base == "application/json"
+}
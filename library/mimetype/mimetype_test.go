@@ -0,0 +1,74 @@
+package mimetype
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	mp4 := []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+	zip := []byte("PK\x03\x04\x14\x00\x00\x00")
+	cases := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"a.png", png, "image/png"},
+		{"a.txt", png, "image/png"}, // 内容优先于扩展名
+		{"a.jpg", []byte("hello"), "text/plain; charset=utf-8"},
+		{"a.mp4", mp4, "video/mp4"},
+		{"a.m4a", mp4, "audio/mp4"},
+		{"a.docx", zip, "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"a.apk", zip, "application/vnd.android.package-archive"},
+		{"a.zip", zip, "application/zip"},
+		{"a.md", []byte("# title"), "text/markdown; charset=utf-8"},
+		{"a.JSON", []byte(`{"a":1}`), "application/json"},
+		{"a.txt", []byte("<html><script>"), "text/plain; charset=utf-8"},
+		{"a.html", []byte("<html>"), "text/html; charset=utf-8"},
+		{"a.svg", []byte(`<?xml version="1.0"?><svg>`), "image/svg+xml"},
+		{"a.bin", []byte{0, 1, 2, 3}, Unknown},
+		{"noext", []byte{0, 1, 2, 3}, Unknown},
+		{"a.mkv", []byte{0, 1, 2, 3}, "video/x-matroska"},
+	}
+	for _, c := range cases {
+		if got := Detect(c.name, c.head); got != c.want {
+			t.Errorf("%s: got %q want %q", c.name, got, c.want)
+		}
+	}
+	Register(".NFO", "text/x-nfo")
+	if got := Detect("readme.nfo", []byte("ascii art")); got != "text/x-nfo; charset=utf-8" {
+		t.Error("registered", got)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mimetype")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "clip.mov")
+	_ = ioutil.WriteFile(file, []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  "), 0644)
+	if got := File(file); got != "video/quicktime" {
+		t.Error(got)
+	}
+	if got := File(filepath.Join(dir, "missing.pdf")); got != "application/pdf" {
+		t.Error(got)
+	}
+}
+
+func TestInline(t *testing.T) {
+	inline := map[string]bool{
+		"video/mp4":                 true,
+		"application/pdf":           true,
+		"text/plain; charset=utf-8": true,
+		"image/svg+xml":             true,
+		Unknown:                     false,
+		"application/zip":           false,
+	}
+	for typ, want := range inline {
+		if Inline(typ) != want {
+			t.Error(typ)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package mirror
+
+import (
+	"archive/tar"
+	"b0pass/library/bufpool"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 小文件批量复制: 队列中连续的小文件合并为一个tar流，通过一个请求边读边传，
+// 避免数千个小文件逐个请求
+
+// BatchPath 镜像上接收批量复制的接口，请求体为tar流
+const BatchPath = "/api/mirror/batch"
+
+// gather 首个文件是小文件时，从队列中继续取出已在等待的小文件组成一批，取出的大文件放入rest
+func (m *Mirror) gather(first string) (batch, rest []string) {
+	size, ok := m.small(first)
+	if !ok {
+		return []string{first}, nil
+	}
+	batch = []string{first}
+	for len(batch) < m.BatchFiles && size < m.BatchBytes {
+		select {
+		case rel := <-m.queue:
+			if n, ok := m.small(rel); ok {
+				batch = append(batch, rel)
+				size += n
+			} else {
+				rest = append(rest, rel)
+			}
+		default:
+			return batch, rest
+		}
+	}
+	return batch, rest
+}
+
+// small 文件是否适合批量复制，返回文件大小
+func (m *Mirror) small(rel string) (int64, bool) {
+	if m.Small <= 0 || m.BatchFiles < 2 || atomic.LoadInt32(&m.noBatch) == 1 {
+		return 0, false
+	}
+	info, err := os.Stat(m.file(rel))
+	if err != nil || !info.Mode().IsRegular() || info.Size() > m.Small {
+		return 0, false
+	}
+	return info.Size(), true
+}
+
+// pushBatch 以一个tar流上传一批文件，失败时由调用方逐个重传
+func (m *Mirror) pushBatch(rels []string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(m.writeBatch(pw, rels))
+	}()
+	resp, err := m.do(http.MethodPut, BatchPath, pr, -1, "Content-Type", "application/x-tar")
+	_ = pr.Close()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		// 镜像版本较旧，不支持批量复制
+		atomic.StoreInt32(&m.noBatch, 1)
+		return fmt.Errorf("mirror: batch not supported: %s", resp.Status)
+	default:
+		return fmt.Errorf("mirror: batch: %s", resp.Status)
+	}
+	var ret struct {
+		Err  int      `json:"err"`
+		Msg  string   `json:"msg"`
+		Data []string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		return err
+	}
+	if ret.Err != 0 || len(ret.Data) != len(rels) {
+		return fmt.Errorf("mirror: batch: %s (%d/%d saved)", ret.Msg, len(ret.Data), len(rels))
+	}
+	return nil
+}
+
+// writeBatch 将文件依次写入tar流
+func (m *Mirror) writeBatch(w io.Writer, rels []string) error {
+	tw := tar.NewWriter(w)
+	for _, rel := range rels {
+		if err := writeEntry(tw, m.file(rel), rel); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeEntry(tw *tar.Writer, file, rel string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     strings.TrimPrefix(rel, "/"),
+		Size:     info.Size(),
+		Mode:     0644,
+		ModTime:  info.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = bufpool.Copy(tw, io.LimitReader(f, info.Size()))
+	return err
+}
+
+// ReadBatch 镜像端读取批量复制的tar流，对每个普通文件调用save(rel为清理后以/开头的相对路径)
+func ReadBatch(body io.Reader, save func(rel string, mtime time.Time, r io.Reader) error) error {
+	tr := tar.NewReader(body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		rel := path.Clean("/" + hdr.Name)
+		if rel == "/" {
+			continue
+		}
+		if err := save(rel, hdr.ModTime, tr); err != nil {
+			return err
+		}
+		// save未读完时丢弃剩余内容
+		_, _ = io.Copy(ioutil.Discard, tr)
+	}
+}
@@ -0,0 +1,169 @@
+package mirror
+
+import (
+	"b0pass/library/hashes"
+	"b0pass/library/webdav"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	src, _ := ioutil.TempDir("", "mirror-src")
+	dst, _ := ioutil.TempDir("", "mirror-dst")
+	defer func() { _ = os.RemoveAll(src) }()
+	defer func() { _ = os.RemoveAll(dst) }()
+	_ = os.MkdirAll(filepath.Join(src, "a", "b"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(src, "a", "b", "c.txt"), []byte("hello"), 0644)
+
+	var marked bool
+	dav := &webdav.Handler{Prefix: "/dav", Root: dst}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "u" || pass != "p" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		marked = marked || r.Header.Get(Header) != ""
+		dav.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	m, err := New("http://u:p@"+srv.Listener.Addr().String()+"/", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Backoff = 10 * time.Millisecond
+	done := make(chan error, 2)
+	m.OnDone = func(rel string, err error) { done <- err }
+	m.Start(1)
+	if err := m.Enqueue("a/b/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if p := m.Pending(); len(p) != 0 {
+		t.Errorf("pending after done: %v", p)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dst, "a", "b", "c.txt")); string(b) != "hello" || !marked {
+		t.Error("not replicated", string(b), marked)
+	}
+	_ = m.Enqueue("missing.txt")
+	if err := <-done; err == nil {
+		t.Error("missing file replicated")
+	}
+	if u := m.FileURL("/a/b/c d.txt"); u != "http://"+srv.Listener.Addr().String()+"/files/a/b/c%20d.txt" {
+		t.Error(u)
+	}
+}
+
+func TestMirrorRepair(t *testing.T) {
+	src, _ := ioutil.TempDir("", "mirror-src")
+	dst, _ := ioutil.TempDir("", "mirror-dst")
+	defer func() { _ = os.RemoveAll(src) }()
+	defer func() { _ = os.RemoveAll(dst) }()
+	_ = ioutil.WriteFile(filepath.Join(src, "a.bin"), []byte("aaaabbbbcc"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dst, "a.bin"), []byte("aaaaXbbbcc"), 0644)
+
+	var puts []string
+	dav := &webdav.Handler{Prefix: "/dav", Root: dst}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/chunks" {
+			size, _ := strconv.ParseInt(r.URL.Query().Get("chunk"), 10, 64)
+			sums, _ := hashes.FileChunks(filepath.Join(dst, "a.bin"), size)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"err": 0, "data": map[string]interface{}{"size": 10, "hashes": sums}})
+			return
+		}
+		if r.Method == http.MethodPut {
+			puts = append(puts, r.Header.Get("Content-Range"))
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	m, _ := New(srv.URL, src)
+	want, _ := hashes.FileChunks(filepath.Join(src, "a.bin"), 4)
+	m.Chunks = func(rel string) (int64, []string) { return 4, want }
+	if err := m.push("/a.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dst, "a.bin")); string(b) != "aaaabbbbcc" {
+		t.Errorf("not repaired: %q", b)
+	}
+	if len(puts) != 1 || puts[0] != "bytes 4-7/10" {
+		t.Errorf("unexpected uploads %v", puts)
+	}
+}
+
+func TestMirrorBatch(t *testing.T) {
+	src, _ := ioutil.TempDir("", "mirror-src")
+	dst, _ := ioutil.TempDir("", "mirror-dst")
+	defer func() { _ = os.RemoveAll(src) }()
+	defer func() { _ = os.RemoveAll(dst) }()
+	_ = os.MkdirAll(filepath.Join(src, "d"), 0755)
+	files := map[string]string{"d/1.txt": "one", "d/2.txt": "two", "3.txt": "three", "big.bin": "0123456789abcdef"}
+	for name, body := range files {
+		_ = ioutil.WriteFile(filepath.Join(src, name), []byte(body), 0644)
+	}
+
+	var batches, puts int32
+	dav := &webdav.Handler{Prefix: "/dav", Root: dst}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == BatchPath {
+			atomic.AddInt32(&batches, 1)
+			var saved []string
+			err := ReadBatch(r.Body, func(rel string, mtime time.Time, body io.Reader) error {
+				file := filepath.Join(dst, filepath.FromSlash(rel))
+				_ = os.MkdirAll(filepath.Dir(file), 0755)
+				b, _ := ioutil.ReadAll(body)
+				saved = append(saved, rel)
+				return ioutil.WriteFile(file, b, 0644)
+			})
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"err": 0, "data": saved})
+			return
+		}
+		if r.Method == http.MethodPut {
+			atomic.AddInt32(&puts, 1)
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	m, _ := New(srv.URL, src)
+	m.Small = 8
+	done := make(chan error, len(files))
+	m.OnDone = func(rel string, err error) { done <- err }
+	for name := range files {
+		_ = m.Enqueue(name)
+	}
+	m.Start(1)
+	for range files {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, body := range files {
+		if b, _ := ioutil.ReadFile(filepath.Join(dst, name)); string(b) != body {
+			t.Errorf("%s: %q", name, b)
+		}
+	}
+	// 三个小文件一批，大文件单独PUT
+	if b, p := atomic.LoadInt32(&batches), atomic.LoadInt32(&puts); b != 1 || p != 1 {
+		t.Errorf("batches=%d puts=%d", b, p)
+	}
+	if h := m.Health(); h.State != "up" || h.Requests == 0 {
+		t.Errorf("%+v", h)
+	}
+}
@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"b0pass/library/logger"
+	"sync"
+	"time"
+)
+
+// Event 通知事件
+type Event struct {
+	Type  string      `json:"type"`
+	Level string      `json:"level"`
+	Title string      `json:"title"`
+	Data  interface{} `json:"data"`
+	Time  string      `json:"time"`
+}
+
+// Channel 通知渠道
+type Channel interface {
+	Send(e Event) error
+}
+
+// ChannelFunc 函数形式的通知渠道
+type ChannelFunc func(e Event) error
+
+// Send 实现Channel接口
+func (f ChannelFunc) Send(e Event) error {
+	return f(e)
+}
+
+var (
+	mu       sync.RWMutex
+	channels = make(map[string]Channel)
+	enabled  map[string]bool
+	// subscribers 订阅者，不受启用渠道限制；值为订阅者的设备标识，为空时只接收广播
+	subscribers = make(map[chan Event]string)
+)
+
+func init() {
+	Register("log", ChannelFunc(func(e Event) error {
+		logger.Info("notify", e.Title, "level", e.Level, "type", e.Type)
+		return nil
+	}))
+}
+
+// Register 注册通知渠道
+func Register(name string, c Channel) {
+	mu.Lock()
+	defer mu.Unlock()
+	channels[name] = c
+}
+
+// Enable 设置启用的渠道，未设置时全部启用
+func Enable(names ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(names) == 0 {
+		enabled = nil
+		return
+	}
+	enabled = make(map[string]bool)
+	for _, name := range names {
+		enabled[name] = true
+	}
+}
+
+// Send 向所有启用的渠道异步发送通知
+func Send(e Event) {
+	if e.Time == "" {
+		e.Time = time.Now().Format(time.RFC3339)
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	for name, c := range channels {
+		if enabled != nil && !enabled[name] {
+			continue
+		}
+		go func(name string, c Channel) {
+			if err := c.Send(e); err != nil {
+				logger.Error("notify", "send", "channel", name, "err", err)
+			}
+		}(name, c)
+	}
+}
+
+// SendTo 只向以client身份订阅的订阅者(如该设备打开的页面)发送，不经过通知渠道，返回收到的订阅者数
+func SendTo(client string, e Event) int {
+	if e.Time == "" {
+		e.Time = time.Now().Format(time.RFC3339)
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	n := 0
+	for ch, c := range subscribers {
+		if c != client || client == "" {
+			continue
+		}
+		select {
+		case ch <- e:
+			n++
+		default:
+		}
+	}
+	return n
+}
+
+// Online 以设备身份订阅的设备是否在线
+func Online(client string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, c := range subscribers {
+		if c == client && client != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe 订阅所有通知事件，返回事件通道与取消函数
+// 订阅者处理不及时时丢弃事件，不阻塞发送方
+func Subscribe(buffer int) (<-chan Event, func()) {
+	return SubscribeAs("", buffer)
+}
+
+// SubscribeAs 以设备身份订阅，除所有通知事件外还接收发给该设备的事件(SendTo)
+func SubscribeAs(client string, buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	mu.Lock()
+	subscribers[ch] = client
+	mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			mu.Lock()
+			delete(subscribers, ch)
+			mu.Unlock()
+			close(ch)
+		})
+	}
+}
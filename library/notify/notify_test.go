@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	got := make(chan Event, 2)
+	Register("test", ChannelFunc(func(e Event) error {
+		got <- e
+		return nil
+	}))
+	Register("muted", ChannelFunc(func(e Event) error {
+		t.Error("disabled channel received event")
+		return nil
+	}))
+	Enable("test")
+
+	Send(Event{Type: "storage", Level: "warn", Title: "disk almost full"})
+	select {
+	case e := <-got:
+		if e.Type != "storage" || e.Time == "" {
+			t.Errorf("bad event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("event not delivered")
+	}
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestSubscribe(t *testing.T) {
+	events, cancel := Subscribe(1)
+	Send(Event{Type: "network", Title: "address changed"})
+	Send(Event{Type: "dropped"})
+	if e := <-events; e.Type != "network" {
+		t.Errorf("bad event %+v", e)
+	}
+	cancel()
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("channel not closed after cancel")
+	}
+	Send(Event{Type: "after"})
+}
+
+func TestSendTo(t *testing.T) {
+	phone, cancelPhone := SubscribeAs("browser:phone", 2)
+	defer cancelPhone()
+	laptop, cancelLaptop := SubscribeAs("browser:laptop", 2)
+	if !Online("browser:laptop") || Online("browser:tv") || Online("") {
+		t.Error("online")
+	}
+	if n := SendTo("browser:laptop", Event{Type: "handoff", Title: "a.pdf"}); n != 1 {
+		t.Errorf("delivered to %d", n)
+	}
+	if e := <-laptop; e.Type != "handoff" || e.Time == "" {
+		t.Errorf("bad event %+v", e)
+	}
+	select {
+	case e := <-phone:
+		t.Errorf("phone received %+v", e)
+	default:
+	}
+	cancelLaptop()
+	if SendTo("browser:laptop", Event{Type: "handoff"}) != 0 || Online("browser:laptop") {
+		t.Error("cancelled subscriber still online")
+	}
+}
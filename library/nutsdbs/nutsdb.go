@@ -1,7 +1,7 @@
 package nustdbs
 
 import (
-	"github.com/gogf/gf/os/glog"
+	"b0pass/library/logger"
 	"github.com/xujiajun/nutsdb"
 	"log"
 	"runtime"
@@ -35,7 +35,7 @@ func Init(){
 			bucket:"db0",
 			dbdir:Dirs[runtime.GOOS],
 		}
-		glog.Cat("nutsdb").Println("init:",Dirs[runtime.GOOS])
+		logger.Debug("nutsdb", "init", "dir", Dirs[runtime.GOOS])
 		DBs.OpenDB()
 	})
 
@@ -57,12 +57,12 @@ type DBClient struct {
 
 // OpenDB() 打开数据库
 func (d *DBClient) OpenDB(){
-	glog.Cat("nutsdb").Println("OpenDB")
+	logger.Debug("nutsdb", "open")
 	opt := nutsdb.DefaultOptions
 	opt.Dir = d.dbdir
 	db, err := nutsdb.Open(opt)
 	if err != nil {
-		glog.Cat("nutsdb").Println("OpenDB:ERR:",err)
+		logger.Error("nutsdb", "open", "err", err)
 	}
 	d.db=db
 }
@@ -74,7 +74,7 @@ func (d *DBClient) CloseDB(){
 
 // SetData(keys,value) 写入数据
 func (d *DBClient) SetData(keys,value string){
-	glog.Cat("nutsdb").Println("SetData:",keys,value)
+	logger.Debug("nutsdb", "set", "key", keys)
 	key := []byte(keys)
 	val := []byte(value)
 	if err := d.db.Update(
@@ -84,14 +84,13 @@ func (d *DBClient) SetData(keys,value string){
 			}
 			return nil
 		}); err != nil {
-		glog.Cat("nutsdb").Println("SetData:ERR:",err)
-		log.Println(err)
+		logger.Error("nutsdb", "set", "key", keys, "err", err)
 	}
 }
 
 // GetData() 读取数据
 func (d *DBClient) GetData(keys string) string {
-	glog.Cat("nutsdb").Println("GetData:",keys)
+	logger.Debug("nutsdb", "get", "key", keys)
 	key := []byte(keys)
 	data:=""
 	if err := d.db.View(
@@ -107,8 +106,7 @@ func (d *DBClient) GetData(keys string) string {
 			}
 			return nil
 		}); err != nil {
-		glog.Cat("nutsdb").Println("GetData:ERR:",err)
-		log.Println(err)
+		logger.Error("nutsdb", "get", "key", keys, "err", err)
 	}
 	return data
 }
@@ -129,7 +127,7 @@ func (d *DBClient) GetDatas(prefix string,limitNum int) []map[string]string{
 			datas=append(datas,data)
 			return nil
 		}); err != nil {
-		log.Println(err)
+		logger.Error("nutsdb", "delete", "err", err)
 	}
 	return datas
 }
@@ -0,0 +1,207 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Param 参数，In为query/path/form，Type为string/integer/boolean/file
+type Param struct {
+	Name     string
+	In       string
+	Type     string
+	Required bool
+	Desc     string
+}
+
+// Operation 接口定义
+type Operation struct {
+	// Method GET/POST等，多个方法以逗号分隔，ALL按GET与POST生成
+	Method string
+	// Path gf路由规则，如 /api/tus/*any
+	Path    string
+	Tag     string
+	Summary string
+	Params  []Param
+	// JSON 请求体为JSON时的说明
+	JSON string
+	// Raw 响应不是标准的{err,msg,data}结构(文件、页面等)
+	Raw string
+	// Errors 可能返回的HTTP错误状态码及说明
+	Errors map[int]string
+}
+
+// Info 文档基本信息
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+	// BasicAuth 服务启用了HTTP Basic认证
+	BasicAuth bool
+	// Bearer 支持Bearer令牌认证
+	Bearer bool
+}
+
+// obj JSON对象
+type obj = map[string]interface{}
+
+var routeParam = regexp.MustCompile(`/[:*]([A-Za-z0-9_]+)`)
+
+// PathOf 将gf路由规则转为OpenAPI路径，返回路径参数名
+func PathOf(route string) (string, []string) {
+	var names []string
+	p := routeParam.ReplaceAllStringFunc(route, func(s string) string {
+		names = append(names, s[2:])
+		return "/{" + s[2:] + "}"
+	})
+	return p, names
+}
+
+func schema(typ string) obj {
+	switch typ {
+	case "file":
+		return obj{"type": "string", "format": "binary"}
+	case "":
+		return obj{"type": "string"}
+	}
+	return obj{"type": typ}
+}
+
+// operation 生成单个方法的Operation Object
+func operation(op Operation, pathParams []string) obj {
+	o := obj{"summary": op.Summary}
+	if op.Tag != "" {
+		o["tags"] = []string{op.Tag}
+	}
+	var params []interface{}
+	for _, name := range pathParams {
+		params = append(params, obj{
+			"name": name, "in": "path", "required": true, "schema": schema("string"),
+		})
+	}
+	props := make(obj)
+	var required []string
+	for _, p := range op.Params {
+		if p.In == "form" {
+			s := schema(p.Type)
+			if p.Desc != "" {
+				s["description"] = p.Desc
+			}
+			props[p.Name] = s
+			if p.Required {
+				required = append(required, p.Name)
+			}
+			continue
+		}
+		in := p.In
+		if in == "" {
+			in = "query"
+		}
+		params = append(params, obj{
+			"name": p.Name, "in": in, "required": p.Required || in == "path",
+			"description": p.Desc, "schema": schema(p.Type),
+		})
+	}
+	if len(params) > 0 {
+		o["parameters"] = params
+	}
+	if len(props) > 0 {
+		s := obj{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		o["requestBody"] = obj{
+			"content": obj{"multipart/form-data": obj{"schema": s}},
+		}
+	} else if op.JSON != "" {
+		o["requestBody"] = obj{
+			"description": op.JSON,
+			"content":     obj{"application/json": obj{"schema": obj{"type": "object"}}},
+		}
+	}
+	responses := make(obj)
+	if op.Raw != "" {
+		responses["200"] = obj{"description": op.Raw}
+	} else {
+		responses["200"] = obj{
+			"description": "err为0时成功",
+			"content": obj{"application/json": obj{
+				"schema": obj{"$ref": "#/components/schemas/Result"},
+			}},
+		}
+	}
+	for status, desc := range op.Errors {
+		responses[strconv.Itoa(status)] = obj{"description": desc}
+	}
+	o["responses"] = responses
+	return o
+}
+
+// Build 生成OpenAPI 3.0文档
+func Build(info Info, ops []Operation) obj {
+	paths := make(map[string]obj)
+	tags := make(map[string]bool)
+	for _, op := range ops {
+		p, names := PathOf(op.Path)
+		if paths[p] == nil {
+			paths[p] = make(obj)
+		}
+		methods := strings.Split(strings.ToUpper(op.Method), ",")
+		if op.Method == "ALL" {
+			methods = []string{http.MethodGet, http.MethodPost}
+		}
+		for _, m := range methods {
+			paths[p][strings.ToLower(m)] = operation(op, names)
+		}
+		if op.Tag != "" {
+			tags[op.Tag] = true
+		}
+	}
+	var tagList []interface{}
+	names := make([]string, 0, len(tags))
+	for t := range tags {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	for _, t := range names {
+		tagList = append(tagList, obj{"name": t})
+	}
+	doc := obj{
+		"openapi": "3.0.3",
+		"info": obj{
+			"title": info.Title, "version": info.Version, "description": info.Description,
+		},
+		"paths": paths,
+		"tags":  tagList,
+		"components": obj{
+			"schemas": obj{
+				"Result": obj{
+					"type": "object",
+					"properties": obj{
+						"err":  obj{"type": "integer", "description": "0成功，非0为错误码"},
+						"msg":  obj{"type": "string"},
+						"data": obj{"description": "接口数据"},
+					},
+				},
+			},
+		},
+	}
+	schemes := make(obj)
+	var security []interface{}
+	if info.BasicAuth {
+		schemes["basic"] = obj{"type": "http", "scheme": "basic"}
+		security = append(security, obj{"basic": []string{}})
+	}
+	if info.Bearer {
+		schemes["bearer"] = obj{"type": "http", "scheme": "bearer"}
+		security = append(security, obj{"bearer": []string{}})
+	}
+	if len(schemes) > 0 {
+		doc["components"].(obj)["securitySchemes"] = schemes
+		doc["security"] = security
+	}
+	return doc
+}
@@ -0,0 +1,73 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPathOf(t *testing.T) {
+	p, names := PathOf("/api/tus/*any")
+	if p != "/api/tus/{any}" || len(names) != 1 || names[0] != "any" {
+		t.Errorf("got %s %v", p, names)
+	}
+	if p, names = PathOf("/api/:id/done"); p != "/api/{id}/done" || names[0] != "id" {
+		t.Errorf("got %s %v", p, names)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	doc := Build(Info{Title: "B0Pass", Version: "1", BasicAuth: true, Bearer: true}, []Operation{
+		{Method: "POST", Path: "/api/upload", Tag: "文件", Summary: "上传", Params: []Param{
+			{Name: "upload-file", In: "form", Type: "file", Required: true},
+			{Name: "path", In: "form"},
+		}, Errors: map[int]string{507: "磁盘空间不足"}},
+		{Method: "ALL", Path: "/api/tus/*any", Raw: "tus协议响应"},
+		{Method: "HEAD,PATCH", Path: "/api/tus2/:id"},
+		{Method: "GET", Path: "/api/delete", Params: []Param{{Name: "f", Required: true}}},
+	})
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Required []string `json:"required"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]interface{} `json:"responses"`
+		} `json:"paths"`
+		Security []interface{} `json:"security"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.OpenAPI != "3.0.3" || len(v.Security) != 2 {
+		t.Errorf("bad header %+v", v)
+	}
+	up := v.Paths["/api/upload"]["post"]
+	if req := up.RequestBody.Content["multipart/form-data"].Schema.Required; len(req) != 1 || req[0] != "upload-file" {
+		t.Errorf("bad upload body %+v", up.RequestBody)
+	}
+	if up.Responses["507"] == nil || up.Responses["200"] == nil {
+		t.Errorf("bad responses %+v", up.Responses)
+	}
+	tus := v.Paths["/api/tus/{any}"]
+	if len(tus) != 2 || tus["get"].Parameters[0].In != "path" {
+		t.Errorf("bad tus path %+v", tus)
+	}
+	if m := v.Paths["/api/tus2/{id}"]; len(m) != 2 || m["patch"].Responses == nil {
+		t.Errorf("bad method list %+v", m)
+	}
+	if p := v.Paths["/api/delete"]["get"].Parameters; len(p) != 1 || p[0].In != "query" {
+		t.Errorf("bad delete params %+v", p)
+	}
+}
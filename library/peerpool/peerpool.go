@@ -0,0 +1,143 @@
+package peerpool
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 到一个对端(镜像等另一个b0pass)的连接池: 保持长连接复用、启动时预热，并跟踪连接健康状态
+
+// 健康状态
+const (
+	StateUp       = "up"
+	StateDegraded = "degraded" // 最近有请求失败
+	StateDown     = "down"     // 连续失败达到DownAfter次
+)
+
+// DownAfter 连续失败多少次视为离线
+const DownAfter = 3
+
+// Health 对端健康状态
+type Health struct {
+	State       string    `json:"state"`
+	Requests    int64     `json:"requests"`
+	Failures    int64     `json:"failures"`
+	Consecutive int       `json:"consecutive"` // 连续失败次数
+	LastError   string    `json:"last_error,omitempty"`
+	LastOK      time.Time `json:"last_ok,omitempty"`
+	LatencyMS   float64   `json:"latency_ms"` // 响应头到达时间的滑动平均
+}
+
+// Peer 对端连接池
+type Peer struct {
+	Client *http.Client
+
+	transport *http.Transport
+	mu        sync.Mutex
+	health    Health
+}
+
+// New 创建连接池，conns为保持的空闲连接数，idle为空闲连接的保持时间
+func New(conns int, idle time.Duration) *Peer {
+	if conns <= 0 {
+		conns = 2
+	}
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          conns,
+		MaxIdleConnsPerHost:   conns,
+		IdleConnTimeout:       idle,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &Peer{Client: &http.Client{Transport: t}, transport: t, health: Health{State: StateUp}}
+}
+
+// Do 发送请求并记录健康状态，连接错误与5xx响应计为失败
+func (p *Peer) Do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := p.Client.Do(req)
+	switch {
+	case err != nil:
+		p.record(err.Error(), 0)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		p.record(resp.Status, 0)
+	default:
+		p.record("", time.Since(start))
+	}
+	return resp, err
+}
+
+// record 记录一次请求结果，errText为空表示成功
+func (p *Peer) record(errText string, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := &p.health
+	h.Requests++
+	if errText != "" {
+		h.Failures++
+		h.Consecutive++
+		h.LastError = errText
+		h.State = StateDegraded
+		if h.Consecutive >= DownAfter {
+			h.State = StateDown
+		}
+		return
+	}
+	ms := float64(latency) / float64(time.Millisecond)
+	if h.LastOK.IsZero() {
+		h.LatencyMS = ms
+	} else {
+		h.LatencyMS = 0.8*h.LatencyMS + 0.2*ms
+	}
+	h.Consecutive, h.State, h.LastOK = 0, StateUp, time.Now()
+}
+
+// Health 当前健康状态
+func (p *Peer) Health() Health {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.health
+}
+
+// Warm 并发发送n个GET请求建立n条连接，之后由连接池复用，返回成功的请求数
+func (p *Peer) Warm(rawurl string, n int) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok := 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+			if err != nil {
+				return
+			}
+			resp, err := p.Do(req)
+			if err != nil {
+				return
+			}
+			// 读完响应体，连接才会放回连接池
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+			mu.Lock()
+			ok++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return ok
+}
+
+// Close 关闭空闲连接
+func (p *Peer) Close() {
+	p.transport.CloseIdleConnections()
+}
@@ -0,0 +1,81 @@
+package peerpool
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPeer(t *testing.T) {
+	var conns int32
+	fail := int32(0)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	srv.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	p := New(4, time.Minute)
+	defer p.Close()
+	if n := p.Warm(srv.URL, 4); n != 4 || atomic.LoadInt32(&conns) != 4 {
+		t.Fatal(n, conns)
+	}
+	// 预热后的并发请求复用已有连接
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if resp, err := p.Do(req); err == nil {
+				_, _ = io.Copy(ioutil.Discard, resp.Body)
+				_ = resp.Body.Close()
+			}
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+	if c := atomic.LoadInt32(&conns); c != 4 {
+		t.Errorf("%d connections, want 4", c)
+	}
+	if h := p.Health(); h.State != StateUp || h.Requests != 8 || h.LatencyMS < 10 {
+		t.Errorf("%+v", h)
+	}
+
+	atomic.StoreInt32(&fail, 1)
+	for i := 0; i < DownAfter; i++ {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if resp, err := p.Do(req); err == nil {
+			_ = resp.Body.Close()
+		}
+		if want := StateDegraded; i < DownAfter-1 && p.Health().State != want {
+			t.Error(p.Health())
+		}
+	}
+	if h := p.Health(); h.State != StateDown || h.Consecutive != DownAfter || h.LastError == "" {
+		t.Errorf("%+v", h)
+	}
+	atomic.StoreInt32(&fail, 0)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if resp, err := p.Do(req); err == nil {
+		_ = resp.Body.Close()
+	}
+	if h := p.Health(); h.State != StateUp || h.Consecutive != 0 {
+		t.Errorf("%+v", h)
+	}
+}
@@ -0,0 +1,213 @@
+package playlist
+
+import (
+	"b0pass/library/preview"
+	"bytes"
+	"encoding/binary"
+	"github.com/gogf/gf/encoding/gcharset"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Tags 音频文件的标签
+type Tags struct {
+	Title    string
+	Artist   string
+	Album    string
+	Track    int
+	Duration float64 // 秒，未知时为0
+}
+
+// ReadTags 读取MP3的ID3v2标签(v2.2~v2.4)，没有时读取文件末尾的ID3v1标签，并按帧头估算时长
+func ReadTags(file string) (Tags, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Tags{}, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return Tags{}, err
+	}
+	var (
+		t     Tags
+		start int64
+	)
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(f, head); err == nil && string(head[:3]) == "ID3" {
+		size := int64(syncsafe(head[6:10]))
+		start = 10 + size
+		if head[5]&0x10 != 0 {
+			// 带结尾标记
+			start += 10
+		}
+		if size <= 1<<20 {
+			tag := make([]byte, size)
+			if _, err := io.ReadFull(f, tag); err == nil {
+				t = parseID3v2(head[3], head[5], tag)
+			}
+		}
+	}
+	tail := int64(0)
+	if info.Size() >= 128 {
+		v1 := make([]byte, 128)
+		if _, err := f.ReadAt(v1, info.Size()-128); err == nil && string(v1[:3]) == "TAG" {
+			tail = 128
+			if t.Title == "" && t.Artist == "" {
+				t = mergeV1(t, v1)
+			}
+		}
+	}
+	if t.Duration == 0 {
+		t.Duration = mp3Duration(f, start, info.Size()-tail)
+	}
+	return t, nil
+}
+
+// syncsafe 每字节只用低7位的整数
+func syncsafe(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<7 | int(c&0x7F)
+	}
+	return n
+}
+
+// parseID3v2 解析标签帧，major为版本号(2、3或4)
+func parseID3v2(major, flags byte, b []byte) Tags {
+	var t Tags
+	if flags&0x80 != 0 && major < 4 {
+		// 整个标签做了非同步处理
+		b = bytes.Replace(b, []byte{0xFF, 0x00}, []byte{0xFF}, -1)
+	}
+	if flags&0x40 != 0 && major >= 3 && len(b) >= 4 {
+		// 跳过扩展头
+		n := int(binary.BigEndian.Uint32(b))
+		if major == 4 {
+			n = syncsafe(b[:4])
+		} else {
+			n += 4
+		}
+		if n > len(b) {
+			return t
+		}
+		b = b[n:]
+	}
+	idLen, hdrLen := 4, 10
+	if major == 2 {
+		idLen, hdrLen = 3, 6
+	}
+	for len(b) >= hdrLen && b[0] != 0 {
+		id := string(b[:idLen])
+		var size int
+		switch major {
+		case 2:
+			size = int(b[3])<<16 | int(b[4])<<8 | int(b[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(b[4:]))
+		default:
+			size = syncsafe(b[4:8])
+		}
+		if size <= 0 || hdrLen+size > len(b) {
+			break
+		}
+		data := b[hdrLen : hdrLen+size]
+		b = b[hdrLen+size:]
+		switch id {
+		case "TIT2", "TT2":
+			t.Title = decodeText(data)
+		case "TPE1", "TP1":
+			t.Artist = decodeText(data)
+		case "TALB", "TAL":
+			t.Album = decodeText(data)
+		case "TRCK", "TRK":
+			t.Track = trackNumber(decodeText(data))
+		case "TLEN", "TLE":
+			if ms, err := strconv.Atoi(decodeText(data)); err == nil && ms > 0 {
+				t.Duration = float64(ms) / 1000
+			}
+		}
+	}
+	return t
+}
+
+// decodeText 文本帧: 第一个字节为编码(0:ISO-8859-1, 1:带BOM的UTF-16, 2:UTF-16BE, 3:UTF-8)
+func decodeText(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	var s string
+	switch b[0] {
+	case 1, 2:
+		s = decodeUTF16(b[1:], b[0] == 2)
+	case 3:
+		s = string(b[1:])
+	default:
+		s = decodeLegacy(b[1:])
+	}
+	// 多个值以NUL分隔，只取第一个
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// decodeUTF16 UTF-16文本，有BOM时按BOM确定字节序
+func decodeUTF16(b []byte, bigEndian bool) string {
+	if len(b) >= 2 {
+		switch {
+		case b[0] == 0xFF && b[1] == 0xFE:
+			b, bigEndian = b[2:], false
+		case b[0] == 0xFE && b[1] == 0xFF:
+			b, bigEndian = b[2:], true
+		}
+	}
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		if bigEndian {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		} else {
+			u = append(u, uint16(b[i+1])<<8|uint16(b[i]))
+		}
+	}
+	return string(utf16.Decode(u))
+}
+
+// decodeLegacy 标为ISO-8859-1的文本，实际常为GBK等本地编码，按内容识别
+func decodeLegacy(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	if s, err := gcharset.ToUTF8(preview.DetectCharset(b), string(b)); err == nil {
+		return s
+	}
+	return string(b)
+}
+
+// trackNumber 音轨号，如 "3/12"
+func trackNumber(s string) int {
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s = s[:i]
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
+
+// mergeV1 ID3v1标签: 标题、艺术家、专辑各30字节，v1.1的音轨号在第126字节
+func mergeV1(t Tags, b []byte) Tags {
+	field := func(f []byte) string {
+		if i := bytes.IndexByte(f, 0); i >= 0 {
+			f = f[:i]
+		}
+		return strings.TrimSpace(decodeLegacy(f))
+	}
+	t.Title, t.Artist, t.Album = field(b[3:33]), field(b[33:63]), field(b[63:93])
+	if b[125] == 0 && b[126] != 0 {
+		t.Track = int(b[126])
+	}
+	return t
+}
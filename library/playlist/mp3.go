@@ -0,0 +1,65 @@
+package playlist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// MPEG音频帧头中的码率(kbps)与采样率，按MPEG版本区分(Layer III)
+var (
+	bitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	bitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	sampleRate = map[byte][3]int{3: {44100, 48000, 32000}, 2: {22050, 24000, 16000}, 0: {11025, 12000, 8000}}
+)
+
+// mp3Duration 按audio起点后第一个Layer III帧估算时长(秒)：有Xing/Info或VBRI头时按总帧数计算，否则按固定码率计算
+// end为音频数据的结束位置(不含ID3v1标签)，不是MP3时返回0
+func mp3Duration(r io.ReaderAt, start, end int64) float64 {
+	buf := make([]byte, 64<<10)
+	n, _ := r.ReadAt(buf, start)
+	buf = buf[:n]
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xFF || buf[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+		version, layer := buf[i+1]>>3&3, buf[i+1]>>1&3
+		brIndex, srIndex := buf[i+2]>>4, buf[i+2]>>2&3
+		if version == 1 || layer != 1 || brIndex == 0 || brIndex == 15 || srIndex == 3 {
+			continue
+		}
+		rate := sampleRate[version][srIndex]
+		bitrate, samples, side := bitratesV1[brIndex], 1152, 32
+		mono := buf[i+3]>>6 == 3
+		if version != 3 {
+			bitrate, samples, side = bitratesV2[brIndex], 576, 17
+			if mono {
+				side = 9
+			}
+		} else if mono {
+			side = 17
+		}
+		frame := buf[i:]
+		if frames := xingFrames(frame, 4+side); frames > 0 {
+			return float64(frames) * float64(samples) / float64(rate)
+		}
+		return float64(end-start-int64(i)) * 8 / float64(bitrate*1000)
+	}
+	return 0
+}
+
+// xingFrames Xing/Info(位于边信息之后)或VBRI(位于帧头后32字节)头中的总帧数，没有时为0
+func xingFrames(frame []byte, off int) int {
+	if off+12 <= len(frame) {
+		if tag := frame[off : off+4]; bytes.Equal(tag, []byte("Xing")) || bytes.Equal(tag, []byte("Info")) {
+			if binary.BigEndian.Uint32(frame[off+4:])&1 != 0 {
+				return int(binary.BigEndian.Uint32(frame[off+8:]))
+			}
+			return 0
+		}
+	}
+	if 36+18 <= len(frame) && bytes.Equal(frame[36:40], []byte("VBRI")) {
+		return int(binary.BigEndian.Uint32(frame[36+14:]))
+	}
+	return 0
+}
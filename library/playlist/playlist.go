@@ -0,0 +1,97 @@
+package playlist
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 播放列表: 目录中的音频文件及其标题、艺术家与时长，可输出为JSON或M3U
+
+// Exts 浏览器一般能直接播放的音频扩展名
+var Exts = map[string]bool{".mp3": true, ".m4a": true, ".aac": true, ".flac": true, ".ogg": true, ".oga": true, ".opus": true, ".wav": true}
+
+// Track 播放列表中的一项
+type Track struct {
+	Name     string  `json:"name"`
+	Title    string  `json:"title"` // 没有标签时为去掉扩展名的文件名
+	Artist   string  `json:"artist"`
+	Album    string  `json:"album"`
+	Track    int     `json:"track"`
+	Duration float64 `json:"duration"` // 秒，未知时为0
+	Size     int64   `json:"size"`
+	URL      string  `json:"url"`
+}
+
+// Supported 是否为音频文件
+func Supported(name string) bool {
+	return Exts[strings.ToLower(filepath.Ext(name))]
+}
+
+// Read 读取目录中的音频文件，按专辑、音轨号与文件名排序；
+// duration用于读取MP3以外的格式(或MP3无法估算时)的时长，可为nil
+func Read(dir string, duration func(file string) float64) ([]Track, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var list []Track
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") || !Supported(info.Name()) {
+			continue
+		}
+		file := filepath.Join(dir, info.Name())
+		t := Track{Name: info.Name(), Size: info.Size()}
+		if strings.EqualFold(filepath.Ext(file), ".mp3") {
+			if tags, err := ReadTags(file); err == nil {
+				t.Title, t.Artist, t.Album, t.Track, t.Duration = tags.Title, tags.Artist, tags.Album, tags.Track, tags.Duration
+			}
+		}
+		if t.Duration == 0 && duration != nil {
+			t.Duration = duration(file)
+		}
+		if t.Title == "" {
+			t.Title = strings.TrimSuffix(info.Name(), filepath.Ext(info.Name()))
+		}
+		t.Duration = math.Round(t.Duration*1000) / 1000
+		list = append(list, t)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Album != b.Album {
+			return a.Album < b.Album
+		}
+		if a.Track != b.Track {
+			return a.Track < b.Track
+		}
+		return a.Name < b.Name
+	})
+	return list, nil
+}
+
+// WriteM3U 输出扩展M3U(UTF-8)，时长未知时为-1
+func WriteM3U(w io.Writer, tracks []Track) error {
+	if _, err := io.WriteString(w, "#EXTM3U\n"); err != nil {
+		return err
+	}
+	for _, t := range tracks {
+		secs := int(math.Round(t.Duration))
+		if t.Duration == 0 {
+			secs = -1
+		}
+		title := t.Title
+		if t.Artist != "" {
+			title = t.Artist + " - " + t.Title
+		}
+		// 标题中的换行会破坏格式
+		title = strings.NewReplacer("\r", " ", "\n", " ").Replace(title)
+		if _, err := fmt.Fprintf(w, "#EXTINF:%d,%s\n%s\n", secs, title, t.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package playlist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/gogf/gf/encoding/gcharset"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// id3Frame ID3v2.3文本帧
+func id3Frame(id string, text []byte) []byte {
+	b := []byte(id)
+	b = append(b, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(text)))
+	return append(b, text...)
+}
+
+// id3Tag ID3v2.3标签头与帧
+func id3Tag(frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	n := len(body)
+	return append([]byte{'I', 'D', '3', 3, 0, 0, byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}, body...)
+}
+
+// cbrFrames MPEG1 Layer III 128kbps 44.1kHz的空帧
+func cbrFrames(n int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x64})
+	return bytes.Repeat(frame, n)
+}
+
+func TestReadTags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "playlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	gbk, _ := gcharset.UTF8To("GBK", "周杰伦")
+	title := []byte{1, 0xFF, 0xFE, 'S', 0, 'o', 0, 'n', 0, 'g', 0}
+	data := append(id3Tag(
+		id3Frame("TIT2", title),
+		id3Frame("TPE1", append([]byte{0}, gbk...)),
+		id3Frame("TALB", []byte("\x03专辑")),
+		id3Frame("TRCK", []byte("\x003/12")),
+	), cbrFrames(100)...)
+	a := filepath.Join(dir, "a.mp3")
+	_ = ioutil.WriteFile(a, data, 0644)
+	tags, err := ReadTags(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags.Title != "Song" || tags.Artist != "周杰伦" || tags.Album != "专辑" || tags.Track != 3 {
+		t.Errorf("%+v", tags)
+	}
+	if math.Abs(tags.Duration-2.60625) > 0.001 {
+		t.Error("cbr duration", tags.Duration)
+	}
+
+	// Xing头中的帧数(单声道，边信息17字节)
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC4})
+	copy(frame[21:], []byte("Xing\x00\x00\x00\x01\x00\x00\x03\xE8"))
+	v1 := make([]byte, 128)
+	copy(v1, "TAG")
+	copy(v1[3:], "Old Title")
+	copy(v1[33:], "Someone")
+	v1[126] = 7
+	b := filepath.Join(dir, "b.mp3")
+	_ = ioutil.WriteFile(b, append(append(frame, cbrFrames(10)...), v1...), 0644)
+	tags, _ = ReadTags(b)
+	if tags.Title != "Old Title" || tags.Artist != "Someone" || tags.Track != 7 {
+		t.Errorf("v1 %+v", tags)
+	}
+	if math.Abs(tags.Duration-1000*1152/44100.0) > 0.001 {
+		t.Error("xing duration", tags.Duration)
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "playlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	_ = ioutil.WriteFile(filepath.Join(dir, "2.mp3"), append(id3Tag(id3Frame("TIT2", []byte("\x00Two")), id3Frame("TRCK", []byte("\x002"))), cbrFrames(10)...), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "1.mp3"), append(id3Tag(id3Frame("TIT2", []byte("\x00One")), id3Frame("TRCK", []byte("\x001"))), cbrFrames(10)...), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "live.flac"), []byte("fLaC"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "cover.jpg"), []byte("x"), 0644)
+
+	list, err := Read(dir, func(file string) float64 { return 61.5 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0].Title != "live" || list[1].Title != "One" || list[2].Title != "Two" {
+		t.Fatalf("%+v", list)
+	}
+	if list[0].Duration != 61.5 || list[1].Duration != 0.261 {
+		t.Errorf("%+v", list)
+	}
+	list[1].Artist, list[1].URL = "A\nB", "http://h/files/1.mp3"
+	var out bytes.Buffer
+	if err := WriteM3U(&out, list[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	if want := "#EXTM3U\n#EXTINF:0,A B - One\nhttp://h/files/1.mp3\n"; out.String() != want {
+		t.Errorf("%q", out.String())
+	}
+}
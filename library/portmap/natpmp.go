@@ -0,0 +1,126 @@
+package portmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NAT-PMP(RFC 6886): 向网关的UDP 5351端口发送请求
+
+// natPMPPort 网关接收NAT-PMP请求的端口
+const natPMPPort = 5351
+
+type natPMP struct {
+	Gateway string // 网关地址，如 192.168.1.1:5351
+	Timeout time.Duration
+}
+
+// call 发送请求并等待操作码为op+128的响应，超时按250毫秒起倍增重发
+func (p *natPMP) call(req []byte, size int) ([]byte, error) {
+	conn, err := net.Dial("udp", p.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	deadline := time.Now().Add(p.Timeout)
+	buf := make([]byte, 16)
+	for wait := 250 * time.Millisecond; time.Now().Before(deadline); wait *= 2 {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		next := time.Now().Add(wait)
+		if next.After(deadline) {
+			next = deadline
+		}
+		_ = conn.SetReadDeadline(next)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if n < size || buf[0] != 0 || buf[1] != req[1]+128 {
+				continue
+			}
+			if code := binary.BigEndian.Uint16(buf[2:4]); code != 0 {
+				return nil, fmt.Errorf("portmap: nat-pmp result %d", code)
+			}
+			return buf[:n], nil
+		}
+	}
+	return nil, errors.New("portmap: nat-pmp: no response from " + p.Gateway)
+}
+
+func (p *natPMP) externalIP() (string, error) {
+	res, err := p.call([]byte{0, 0}, 12)
+	if err != nil {
+		return "", err
+	}
+	return net.IP(res[8:12]).String(), nil
+}
+
+// mapTCP 添加或删除(lifetime为0)TCP映射，返回网关分配的外网端口
+func (p *natPMP) mapTCP(internal, external int, lifetime uint32) (int, error) {
+	req := make([]byte, 12)
+	req[1] = 2
+	binary.BigEndian.PutUint16(req[4:6], uint16(internal))
+	binary.BigEndian.PutUint16(req[6:8], uint16(external))
+	binary.BigEndian.PutUint32(req[8:12], lifetime)
+	res, err := p.call(req, 16)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(res[10:12])), nil
+}
+
+func (p *natPMP) add(internal, external int, lease time.Duration) (int, error) {
+	return p.mapTCP(internal, external, uint32(lease/time.Second))
+}
+
+func (p *natPMP) remove(internal, external int) error {
+	_, err := p.mapTCP(internal, 0, 0)
+	return err
+}
+
+// Gateway 默认网关的NAT-PMP地址: Linux读取路由表，其他系统假定为本机所在网段的.1
+func Gateway() (string, error) {
+	if b, err := ioutil.ReadFile("/proc/net/route"); err == nil {
+		if ip := routeGateway(string(b)); ip != nil {
+			return net.JoinHostPort(ip.String(), strconv.Itoa(natPMPPort)), nil
+		}
+	}
+	// 不会发送数据，只用于取得默认路由使用的本机地址
+	c, err := net.Dial("udp4", "192.0.2.1:9")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = c.Close() }()
+	ip := c.LocalAddr().(*net.UDPAddr).IP.To4()
+	if ip == nil || ip.IsLoopback() {
+		return "", ErrNoRouter
+	}
+	return net.JoinHostPort(net.IPv4(ip[0], ip[1], ip[2], 1).String(), strconv.Itoa(natPMPPort)), nil
+}
+
+// routeGateway /proc/net/route中默认路由(目标为0)的网关，地址为小端十六进制
+func routeGateway(table string) net.IP {
+	for _, line := range strings.Split(table, "\n")[1:] {
+		f := strings.Fields(line)
+		if len(f) < 3 || f[1] != "00000000" {
+			continue
+		}
+		v, err := strconv.ParseUint(f[2], 16, 32)
+		if err != nil || v == 0 {
+			continue
+		}
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, uint32(v))
+		return net.IP(b)
+	}
+	return nil
+}
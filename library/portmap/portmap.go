@@ -0,0 +1,146 @@
+package portmap
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// 路由器端口映射: 先尝试UPnP IGD(SSDP发现，SOAP添加映射)，不支持时尝试NAT-PMP；
+// 映射带租期，到期前续期，退出时删除
+
+// 映射方式
+const (
+	MethodUPnP   = "upnp"
+	MethodNATPMP = "nat-pmp"
+)
+
+// Lease 映射的租期，Run每隔一半租期续期
+const Lease = time.Hour
+
+var ErrNoRouter = errors.New("portmap: no UPnP or NAT-PMP router found")
+
+// Mapping 已添加的TCP端口映射
+type Mapping struct {
+	Method     string
+	Internal   int    // 本机端口
+	External   int    // 路由器的外网端口
+	ExternalIP string // 路由器的外网地址，路由器未返回时为空
+}
+
+// mapper 一种映射方式
+type mapper interface {
+	// add 添加或续期映射，返回实际的外网端口
+	add(internal, external int, lease time.Duration) (int, error)
+	// remove 删除映射
+	remove(internal, external int) error
+	// externalIP 路由器的外网地址
+	externalIP() (string, error)
+}
+
+// Client 端口映射客户端
+type Client struct {
+	Description string        // 在路由器上显示的映射说明
+	Timeout     time.Duration // 发现路由器的等待时间
+
+	m       mapper
+	method  string
+	mu      sync.Mutex
+	mapping *Mapping
+}
+
+// New 创建客户端
+func New(description string) *Client {
+	return &Client{Description: description, Timeout: 3 * time.Second}
+}
+
+// discover 依次尝试UPnP与NAT-PMP
+func (c *Client) discover() error {
+	if c.m != nil {
+		return nil
+	}
+	if igd, err := discoverIGD(c.Timeout, c.Description); err == nil {
+		c.m, c.method = igd, MethodUPnP
+		return nil
+	}
+	if gw, err := Gateway(); err == nil {
+		pmp := &natPMP{Gateway: gw, Timeout: c.Timeout}
+		if _, err := pmp.externalIP(); err == nil {
+			c.m, c.method = pmp, MethodNATPMP
+			return nil
+		}
+	}
+	return ErrNoRouter
+}
+
+// Map 把路由器的external端口映射到本机的internal端口，external为0时与internal相同
+func (c *Client) Map(internal, external int) (*Mapping, error) {
+	if err := c.discover(); err != nil {
+		return nil, err
+	}
+	if external <= 0 {
+		external = internal
+	}
+	port, err := c.m.add(internal, external, Lease)
+	if err != nil {
+		return nil, err
+	}
+	ip, _ := c.m.externalIP()
+	m := &Mapping{Method: c.method, Internal: internal, External: port, ExternalIP: ip}
+	c.mu.Lock()
+	c.mapping = m
+	c.mu.Unlock()
+	return m, nil
+}
+
+// Unmap 删除Map添加的映射
+func (c *Client) Unmap() error {
+	c.mu.Lock()
+	m := c.mapping
+	c.mapping = nil
+	c.mu.Unlock()
+	if m == nil {
+		return nil
+	}
+	return c.m.remove(m.Internal, m.External)
+}
+
+// Run 每隔半个租期续期直到stop关闭，续期失败时调用onError并在1分钟后重试，外网端口或地址变化时调用onChange
+func (c *Client) Run(stop <-chan struct{}, onChange func(*Mapping), onError func(error)) {
+	wait := Lease / 2
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+		wait = Lease / 2
+		c.mu.Lock()
+		m := c.mapping
+		c.mu.Unlock()
+		if m == nil {
+			continue
+		}
+		port, err := c.m.add(m.Internal, m.External, Lease)
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			wait = time.Minute
+			continue
+		}
+		ip, _ := c.m.externalIP()
+		if port == m.External && (ip == "" || ip == m.ExternalIP) {
+			continue
+		}
+		changed := &Mapping{Method: m.Method, Internal: m.Internal, External: port, ExternalIP: ip}
+		c.mu.Lock()
+		if c.mapping == m {
+			c.mapping = changed
+		}
+		c.mu.Unlock()
+		if onChange != nil {
+			onChange(changed)
+		}
+	}
+}
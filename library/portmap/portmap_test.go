@@ -0,0 +1,141 @@
+package portmap
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const description = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+ <device><deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+  <deviceList><device><deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+   <deviceList><device><deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+    <serviceList><service><serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType><controlURL>/ctl/IPConn</controlURL></service></serviceList>
+   </device></deviceList>
+  </device></deviceList>
+ </device>
+</root>`
+
+func soapFault(w http.ResponseWriter, code string) {
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = w.Write([]byte(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>` +
+		code + `</errorCode><errorDescription>test</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`))
+}
+
+func TestUPnP(t *testing.T) {
+	mapped := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/desc.xml" {
+			_, _ = w.Write([]byte(description))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		v := xmlValues(body)
+		action := r.Header.Get("SOAPAction")
+		switch {
+		case r.URL.Path != "/ctl/IPConn":
+			http.NotFound(w, r)
+		case strings.HasSuffix(action, `#AddPortMapping"`):
+			// 只支持永久映射，8080已被占用
+			if v["NewLeaseDuration"] != "0" {
+				soapFault(w, "725")
+				return
+			}
+			if v["NewExternalPort"] == "8080" {
+				soapFault(w, "718")
+				return
+			}
+			if v["NewInternalClient"] != "127.0.0.1" || v["NewPortMappingDescription"] != "b0pass" {
+				t.Errorf("bad request %v", v)
+			}
+			mapped[v["NewExternalPort"]] = v["NewInternalPort"]
+		case strings.HasSuffix(action, `#GetExternalIPAddress"`):
+			_, _ = w.Write([]byte(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1"><NewExternalIPAddress>203.0.113.7</NewExternalIPAddress></u:GetExternalIPAddressResponse></s:Body></s:Envelope>`))
+		case strings.HasSuffix(action, `#DeletePortMapping"`):
+			delete(mapped, v["NewExternalPort"])
+		default:
+			soapFault(w, "401")
+		}
+	}))
+	defer srv.Close()
+
+	d, err := newIGD(srv.URL+"/desc.xml", "b0pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.control != srv.URL+"/ctl/IPConn" || d.localIP != "127.0.0.1" {
+		t.Fatal(d.control, d.localIP)
+	}
+	c := &Client{m: d, method: MethodUPnP}
+	m, err := c.Map(8899, 8080)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.External != 8081 || m.ExternalIP != "203.0.113.7" || mapped["8081"] != "8899" {
+		t.Fatalf("%+v %v", m, mapped)
+	}
+	if err := c.Unmap(); err != nil || len(mapped) != 0 {
+		t.Fatal(err, mapped)
+	}
+}
+
+func TestNATPMP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	lifetimes := make(chan uint32, 4)
+	go func() {
+		buf := make([]byte, 64)
+		for first := true; ; first = false {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// 丢弃第一个请求，客户端应重发
+			if first {
+				continue
+			}
+			res := make([]byte, 16)
+			res[1] = buf[1] + 128
+			switch {
+			case buf[1] == 0 && n == 2:
+				copy(res[8:12], net.IPv4(203, 0, 113, 9).To4())
+				_, _ = conn.WriteTo(res[:12], addr)
+			case buf[1] == 2 && n == 12:
+				copy(res[8:10], buf[4:6])
+				binary.BigEndian.PutUint16(res[10:12], 40000)
+				copy(res[12:16], buf[8:12])
+				lifetimes <- binary.BigEndian.Uint32(buf[8:12])
+				_, _ = conn.WriteTo(res, addr)
+			}
+		}
+	}()
+	c := &Client{m: &natPMP{Gateway: conn.LocalAddr().String(), Timeout: 3 * time.Second}, method: MethodNATPMP}
+	m, err := c.Map(8899, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.External != 40000 || m.ExternalIP != "203.0.113.9" || <-lifetimes != uint32(Lease/time.Second) {
+		t.Fatalf("%+v", m)
+	}
+	if err := c.Unmap(); err != nil || <-lifetimes != 0 {
+		t.Fatal("unmap", err)
+	}
+}
+
+func TestRouteGateway(t *testing.T) {
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\n" +
+		"eth0\t0001A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\n" +
+		"eth0\t00000000\t0101A8C0\t0003\t0\t0\t0\t00000000\n"
+	if ip := routeGateway(table); ip.String() != "192.168.1.1" {
+		t.Fatal(ip)
+	}
+}
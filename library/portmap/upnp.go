@@ -0,0 +1,261 @@
+package portmap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UPnP IGD(Internet Gateway Device)的WANIPConnection/WANPPPConnection服务
+
+const ssdpAddr = "239.255.255.250:1900"
+
+// UPnP错误码
+const (
+	upnpConflict      = 718 // ConflictInMappingEntry: 外网端口已被其他设备映射
+	upnpOnlyPermanent = 725 // OnlyPermanentLeasesSupported: 只支持永久映射
+)
+
+// upnpError 路由器返回的SOAP错误
+type upnpError struct {
+	Code        int
+	Description string
+}
+
+func (e *upnpError) Error() string {
+	return fmt.Sprintf("portmap: upnp error %d: %s", e.Code, e.Description)
+}
+
+type igd struct {
+	control     string // 服务的控制地址
+	service     string // 服务类型
+	localIP     string // 本机在路由器所在网络中的地址
+	description string
+	client      *http.Client
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+type upnpDevice struct {
+	Services []upnpService `xml:"serviceList>service"`
+	Devices  []upnpDevice  `xml:"deviceList>device"`
+}
+
+// find 在设备树中查找WAN连接服务
+func (d upnpDevice) find() (upnpService, bool) {
+	for _, s := range d.Services {
+		if strings.HasPrefix(s.ServiceType, "urn:schemas-upnp-org:service:WANIPConnection:") ||
+			strings.HasPrefix(s.ServiceType, "urn:schemas-upnp-org:service:WANPPPConnection:") {
+			return s, true
+		}
+	}
+	for _, sub := range d.Devices {
+		if s, ok := sub.find(); ok {
+			return s, true
+		}
+	}
+	return upnpService{}, false
+}
+
+// discoverIGD 通过SSDP组播查找支持端口映射的路由器
+func discoverIGD(timeout time.Duration, description string) (*igd, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range []string{"urn:schemas-upnp-org:device:InternetGatewayDevice:1", "urn:schemas-upnp-org:device:InternetGatewayDevice:2"} {
+		req := "M-SEARCH * HTTP/1.1\r\nHOST: " + ssdpAddr + "\r\nST: " + st + "\r\nMAN: \"ssdp:discover\"\r\nMX: 2\r\n\r\n"
+		if _, err := conn.WriteTo([]byte(req), dst); err != nil {
+			return nil, err
+		}
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	seen := make(map[string]bool)
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, ErrNoRouter
+		}
+		res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		location := res.Header.Get("Location")
+		if location == "" || seen[location] {
+			continue
+		}
+		seen[location] = true
+		if d, err := newIGD(location, description); err == nil {
+			return d, nil
+		}
+	}
+}
+
+// newIGD 读取设备描述，查找WAN连接服务
+func newIGD(location, description string) (*igd, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	res, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	var root struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&root); err != nil {
+		return nil, err
+	}
+	s, ok := root.Device.find()
+	if !ok {
+		return nil, errors.New("portmap: no WAN connection service at " + location)
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if root.URLBase != "" {
+		if u, err := url.Parse(root.URLBase); err == nil {
+			base = u
+		}
+	}
+	control, err := base.Parse(s.ControlURL)
+	if err != nil {
+		return nil, err
+	}
+	// 本机连接路由器使用的地址即路由器所在网络中的地址
+	c, err := net.Dial("udp", control.Host)
+	if err != nil && !strings.Contains(control.Host, ":") {
+		c, err = net.Dial("udp", control.Host+":80")
+	}
+	if err != nil {
+		return nil, err
+	}
+	localIP := c.LocalAddr().(*net.UDPAddr).IP.String()
+	_ = c.Close()
+	return &igd{control: control.String(), service: s.ServiceType, localIP: localIP, description: description, client: client}, nil
+}
+
+// soap 调用服务的action，返回响应中的各项值
+func (d *igd) soap(action string, args [][2]string) (map[string]string, error) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	body.WriteString(`<u:` + action + ` xmlns:u="` + d.service + `">`)
+	for _, kv := range args {
+		body.WriteString("<" + kv[0] + ">" + html.EscapeString(kv[1]) + "</" + kv[0] + ">")
+	}
+	body.WriteString(`</u:` + action + `></s:Body></s:Envelope>`)
+	req, err := http.NewRequest(http.MethodPost, d.control, strings.NewReader(body.String()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+d.service+"#"+action+`"`)
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	values := xmlValues(data)
+	if res.StatusCode != http.StatusOK {
+		code, _ := strconv.Atoi(values["errorCode"])
+		if code == 0 {
+			return nil, fmt.Errorf("portmap: upnp %s: %s", action, res.Status)
+		}
+		return nil, &upnpError{Code: code, Description: values["errorDescription"]}
+	}
+	return values, nil
+}
+
+// xmlValues 响应中所有叶子元素的值(按本地名)
+func xmlValues(data []byte) map[string]string {
+	values := make(map[string]string)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var name string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return values
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name = t.Name.Local
+		case xml.CharData:
+			if name != "" {
+				values[name] = strings.TrimSpace(string(t))
+			}
+		case xml.EndElement:
+			name = ""
+		}
+	}
+}
+
+// add 添加映射；只支持永久映射时改为永久，外网端口冲突时依次尝试之后的端口
+func (d *igd) add(internal, external int, lease time.Duration) (int, error) {
+	seconds := int(lease / time.Second)
+	for try := 0; try < 10; try++ {
+		_, err := d.soap("AddPortMapping", [][2]string{
+			{"NewRemoteHost", ""},
+			{"NewExternalPort", strconv.Itoa(external)},
+			{"NewProtocol", "TCP"},
+			{"NewInternalPort", strconv.Itoa(internal)},
+			{"NewInternalClient", d.localIP},
+			{"NewEnabled", "1"},
+			{"NewPortMappingDescription", d.description},
+			{"NewLeaseDuration", strconv.Itoa(seconds)},
+		})
+		e, ok := err.(*upnpError)
+		switch {
+		case err == nil:
+			return external, nil
+		case ok && e.Code == upnpOnlyPermanent && seconds != 0:
+			seconds = 0
+		case ok && e.Code == upnpConflict && external < 65535:
+			external++
+		default:
+			return 0, err
+		}
+	}
+	return 0, errors.New("portmap: upnp: no free external port")
+}
+
+func (d *igd) remove(internal, external int) error {
+	_, err := d.soap("DeletePortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(external)},
+		{"NewProtocol", "TCP"},
+	})
+	return err
+}
+
+func (d *igd) externalIP() (string, error) {
+	v, err := d.soap("GetExternalIPAddress", nil)
+	if err != nil {
+		return "", err
+	}
+	return v["NewExternalIPAddress"], nil
+}
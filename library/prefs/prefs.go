@@ -0,0 +1,140 @@
+package prefs
+
+import (
+	"b0pass/library/archive"
+	"b0pass/library/fsync"
+	"b0pass/library/humanize"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 设备偏好: 语言、主题、默认视图与下载格式按设备保存在服务端，手机与电脑重新连接后各自保持自己的设置
+
+// 偏好项，值为空时使用界面的默认值
+const (
+	KeyLocale   = "locale"   // 语言: zh、en
+	KeyTheme    = "theme"    // 主题: light、dark、auto
+	KeyView     = "view"     // 默认视图: list、grid、gallery
+	KeyDownload = "download" // 目录的下载格式: zip、tar、tar.gz、tar.zst
+)
+
+// values 各偏好项允许的值
+var values = map[string][]string{
+	KeyLocale:   {humanize.ZH, humanize.EN},
+	KeyTheme:    {"light", "dark", "auto"},
+	KeyView:     {"list", "grid", "gallery"},
+	KeyDownload: {archive.FormatZip, archive.FormatTar, archive.FormatTarGz, archive.FormatTarZst},
+}
+
+// Prefs 一个设备的偏好
+type Prefs struct {
+	Locale   string    `json:"locale"`
+	Theme    string    `json:"theme"`
+	View     string    `json:"view"`
+	Download string    `json:"download"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Set 设置一项，value为空时恢复默认值
+func (p *Prefs) Set(key, value string) error {
+	value = strings.TrimSpace(value)
+	allowed, ok := values[key]
+	if !ok {
+		return fmt.Errorf("unknown preference %q", key)
+	}
+	if value != "" && !contains(allowed, value) {
+		return fmt.Errorf("%s must be one of %s", key, strings.Join(allowed, ", "))
+	}
+	switch key {
+	case KeyLocale:
+		p.Locale = value
+	case KeyTheme:
+		p.Theme = value
+	case KeyView:
+		p.View = value
+	case KeyDownload:
+		p.Download = value
+	}
+	return nil
+}
+
+// Keys 全部偏好项
+func Keys() []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Store 各设备的偏好，key为设备标识，持久化到文件
+type Store struct {
+	mu    sync.Mutex
+	file  string
+	prefs map[string]Prefs
+}
+
+// Open 打开偏好文件，文件不存在时创建空存储
+func Open(file string) *Store {
+	s := &Store{file: file, prefs: make(map[string]Prefs)}
+	if b, err := ioutil.ReadFile(file); err == nil {
+		_ = json.Unmarshal(b, &s.prefs)
+	}
+	return s
+}
+
+// Get 设备的偏好，没有保存过时为零值
+func (s *Store) Get(id string) Prefs {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prefs[id]
+}
+
+// Update 修改设备的多项偏好，任一项不合法时不做修改
+func (s *Store) Update(id string, changes map[string]string) (Prefs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.prefs[id]
+	for k, v := range changes {
+		if err := p.Set(k, v); err != nil {
+			return s.prefs[id], err
+		}
+	}
+	p.Updated = time.Now()
+	s.prefs[id] = p
+	return p, s.save()
+}
+
+// Delete 删除设备的偏好(如取消配对后)
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.prefs[id]; !ok {
+		return nil
+	}
+	delete(s.prefs, id)
+	return s.save()
+}
+
+// save 持久化到文件，原子替换文件
+func (s *Store) save() error {
+	if s.file == "" {
+		return nil
+	}
+	return fsync.WriteJSON(s.file, s.prefs, 0644)
+}
@@ -0,0 +1,54 @@
+package prefs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prefs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "prefs.json")
+
+	s := Open(file)
+	p, err := s.Update("device:a", map[string]string{KeyLocale: "en", KeyTheme: "dark", KeyDownload: "tar.gz"})
+	if err != nil || p.Locale != "en" || p.Theme != "dark" || p.Download != "tar.gz" || p.Updated.IsZero() {
+		t.Fatalf("%+v %v", p, err)
+	}
+	if _, err := s.Update("device:a", map[string]string{KeyView: "grid", KeyTheme: "pink"}); err == nil {
+		t.Error("invalid theme")
+	}
+	if _, err := s.Update("device:a", map[string]string{"color": "red"}); err == nil {
+		t.Error("unknown key")
+	}
+	if p := s.Get("device:a"); p.View != "" || p.Theme != "dark" {
+		t.Errorf("partial update %+v", p)
+	}
+	if _, err := s.Update("browser:b", map[string]string{KeyView: "gallery"}); err != nil {
+		t.Fatal(err)
+	}
+	// 空值恢复默认
+	if p, _ := s.Update("device:a", map[string]string{KeyTheme: ""}); p.Theme != "" || p.Locale != "en" {
+		t.Errorf("reset %+v", p)
+	}
+
+	// 重新打开后各设备保持自己的设置
+	s2 := Open(file)
+	if p := s2.Get("device:a"); p.Locale != "en" || p.View != "" {
+		t.Errorf("reopen a %+v", p)
+	}
+	if p := s2.Get("browser:b"); p.View != "gallery" || p.Locale != "" {
+		t.Errorf("reopen b %+v", p)
+	}
+	if err := s2.Delete("device:a"); err != nil || s2.Get("device:a").Locale != "" {
+		t.Error("delete", err)
+	}
+	if len(Keys()) != 4 {
+		t.Error(Keys())
+	}
+}
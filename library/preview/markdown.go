@@ -0,0 +1,341 @@
+package preview
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Markdown 将Markdown渲染为HTML，支持标题、段落、强调、行内代码、代码块、引用、列表(含任务列表)、
+// 表格、分隔线、链接与图片；原文中的HTML一律转义，链接只允许http(s)、mailto与相对地址。
+// base非空时相对地址以base为前缀(如Markdown文件所在目录的下载地址)
+func Markdown(src, base string) string {
+	lines := strings.Split(strings.Replace(strings.Replace(src, "\r\n", "\n", -1), "\t", "    ", -1), "\n")
+	var b strings.Builder
+	md := &markdown{base: base}
+	md.blocks(&b, lines)
+	return b.String()
+}
+
+var (
+	reHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	reRule    = regexp.MustCompile(`^ {0,3}(?:(?:- *){3,}|(?:\* *){3,}|(?:_ *){3,})$`)
+	reFence   = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*([\\w+-]*)")
+	reItem    = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])( +|$)`)
+	reTask    = regexp.MustCompile(`^\[([ xX])\] `)
+	reTableSp = regexp.MustCompile(`^ *\|? *:?-+:? *(\| *:?-+:? *)*\|? *$`)
+
+	reCode   = regexp.MustCompile("`+")
+	reImage  = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+&#34;[^)]*&#34;)?\)`)
+	reLink   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+&#34;[^)]*&#34;)?\)`)
+	reAuto   = regexp.MustCompile(`&lt;((?:https?|mailto):[^\s&]+)&gt;`)
+	reStrong = regexp.MustCompile(`\*\*([^*\s](?:.*?[^*\s])?)\*\*|__([^_\s](?:.*?[^_\s])?)__`)
+	reEm     = regexp.MustCompile(`\*([^*\s](?:[^*]*?[^*\s])?)\*|\b_([^_\s](?:[^_]*?[^_\s])?)_\b`)
+	reStrike = regexp.MustCompile(`~~([^~\s](?:.*?[^~\s])?)~~`)
+	reScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+)
+
+type markdown struct {
+	base string
+}
+
+// blocks 渲染块级元素
+func (md *markdown) blocks(b *strings.Builder, lines []string) {
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + md.inline(strings.Join(para, "\n")) + "</p>\n")
+			para = nil
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case reFence.MatchString(line):
+			flush()
+			m := reFence.FindStringSubmatch(line)
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), m[1]); i++ {
+				code = append(code, lines[i])
+			}
+			class := ""
+			if m[2] != "" {
+				class = ` class="language-` + html.EscapeString(m[2]) + `"`
+			}
+			b.WriteString("<pre><code" + class + ">" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+		case reHeading.MatchString(trimmed) && strings.HasPrefix(line, trimmed[:1]):
+			flush()
+			m := reHeading.FindStringSubmatch(trimmed)
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", len(m[1]), md.inline(m[2]), len(m[1]))
+		case reRule.MatchString(line):
+			if len(para) > 0 && strings.Trim(trimmed, "-") == "" {
+				// 段落下方的---为二级标题
+				b.WriteString("<h2>" + md.inline(strings.Join(para, " ")) + "</h2>\n")
+				para = nil
+				continue
+			}
+			flush()
+			b.WriteString("<hr>\n")
+		case len(para) > 0 && strings.Trim(trimmed, "=") == "":
+			b.WriteString("<h1>" + md.inline(strings.Join(para, " ")) + "</h1>\n")
+			para = nil
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quote = append(quote, strings.TrimPrefix(q, " "))
+			}
+			i--
+			b.WriteString("<blockquote>\n")
+			md.blocks(b, quote)
+			b.WriteString("</blockquote>\n")
+		case reItem.MatchString(line) && (len(para) == 0 || !isDigit(trimmed[0])):
+			flush()
+			i = md.list(b, lines, i) - 1
+		case strings.HasPrefix(line, "    ") && len(para) == 0:
+			var code []string
+			for ; i < len(lines) && (strings.HasPrefix(lines[i], "    ") || strings.TrimSpace(lines[i]) == ""); i++ {
+				code = append(code, strings.TrimPrefix(lines[i], "    "))
+			}
+			i--
+			for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+				code = code[:len(code)-1]
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+		case strings.Contains(line, "|") && i+1 < len(lines) && reTableSp.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-"):
+			flush()
+			i = md.table(b, lines, i) - 1
+		default:
+			para = append(para, strings.TrimRight(line, " ")+hardBreak(line))
+		}
+	}
+	flush()
+}
+
+// hardBreak 行尾两个空格为换行
+func hardBreak(line string) string {
+	if strings.HasSuffix(line, "  ") {
+		return "\x00br"
+	}
+	return ""
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// list 渲染从第i行开始的列表，返回列表之后的行号
+func (md *markdown) list(b *strings.Builder, lines []string, i int) int {
+	first := reItem.FindStringSubmatch(lines[i])
+	ordered := isDigit(first[2][0])
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+		if n := strings.TrimRight(first[2], ".)"); n != "1" {
+			tag = `ol start="` + strings.TrimLeft(n, "0") + `"`
+		}
+	}
+	b.WriteString("<" + tag + ">\n")
+	indent := len(first[1])
+	for i < len(lines) {
+		m := reItem.FindStringSubmatch(lines[i])
+		if m == nil || len(m[1]) != indent || isDigit(m[2][0]) != ordered {
+			break
+		}
+		content := strings.TrimRight(lines[i][len(m[0]):], " ")
+		width := len(m[0])
+		item := []string{content}
+		blank := false
+		for i++; i < len(lines); i++ {
+			l := lines[i]
+			if strings.TrimSpace(l) == "" {
+				blank = true
+				item = append(item, "")
+				continue
+			}
+			// 缩进的行属于当前项，否则未隔空行的非列表行为延续的段落
+			if lead := len(l) - len(strings.TrimLeft(l, " ")); lead >= width || lead > indent && reItem.MatchString(l) {
+				item = append(item, strings.TrimPrefix(l, strings.Repeat(" ", minInt(lead, width))))
+				blank = false
+				continue
+			}
+			if !blank && !reItem.MatchString(l) {
+				item = append(item, strings.TrimSpace(l))
+				continue
+			}
+			break
+		}
+		for len(item) > 0 && item[len(item)-1] == "" {
+			item = item[:len(item)-1]
+		}
+		b.WriteString("<li>")
+		if t := reTask.FindStringSubmatch(item[0]); t != nil {
+			checked := ""
+			if t[1] != " " {
+				checked = " checked"
+			}
+			b.WriteString(`<input type="checkbox" disabled` + checked + `> `)
+			item[0] = item[0][len(t[0]):]
+		}
+		// 不含空行的项(紧凑列表)文字不包<p>，其后的嵌套列表等照常渲染
+		if indexOf(item, "") < 0 {
+			n := 1
+			for n < len(item) && !hasBlock(item[n:n+1]) {
+				n++
+			}
+			b.WriteString(md.inline(strings.Join(item[:n], "\n")))
+			item = item[n:]
+		}
+		if len(item) > 0 {
+			var inner strings.Builder
+			md.blocks(&inner, item)
+			b.WriteString("\n" + inner.String())
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + strings.Fields(tag)[0] + ">\n")
+	return i
+}
+
+// hasBlock 是否含有块级元素(嵌套列表、代码块等)
+func hasBlock(lines []string) bool {
+	for _, l := range lines {
+		if reItem.MatchString(l) || reFence.MatchString(l) || strings.HasPrefix(strings.TrimSpace(l), ">") {
+			return true
+		}
+	}
+	return false
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// table 渲染从第i行开始的表格，返回表格之后的行号
+func (md *markdown) table(b *strings.Builder, lines []string, i int) int {
+	head := splitRow(lines[i])
+	var aligns []string
+	for _, c := range splitRow(lines[i+1]) {
+		c = strings.TrimSpace(c)
+		switch {
+		case strings.HasPrefix(c, ":") && strings.HasSuffix(c, ":"):
+			aligns = append(aligns, ` style="text-align:center"`)
+		case strings.HasSuffix(c, ":"):
+			aligns = append(aligns, ` style="text-align:right"`)
+		case strings.HasPrefix(c, ":"):
+			aligns = append(aligns, ` style="text-align:left"`)
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	row := func(cells []string, tag string) {
+		b.WriteString("<tr>")
+		for j := range head {
+			cell, align := "", ""
+			if j < len(cells) {
+				cell = cells[j]
+			}
+			if j < len(aligns) {
+				align = aligns[j]
+			}
+			b.WriteString("<" + tag + align + ">" + md.inline(strings.TrimSpace(cell)) + "</" + tag + ">")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("<table>\n<thead>\n")
+	row(head, "th")
+	b.WriteString("</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+		row(splitRow(lines[i]), "td")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+// splitRow 拆分表格行，\|为单元格内的竖线
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	cells := strings.Split(strings.Replace(line, `\|`, "\x00pipe", -1), "|")
+	for i, c := range cells {
+		cells[i] = strings.Replace(c, "\x00pipe", "|", -1)
+	}
+	return cells
+}
+
+// inline 渲染行内元素，行内代码的内容不再处理
+func (md *markdown) inline(s string) string {
+	var b strings.Builder
+	for {
+		loc := reCode.FindStringIndex(s)
+		if loc == nil {
+			break
+		}
+		ticks := s[loc[0]:loc[1]]
+		end := strings.Index(s[loc[1]:], ticks)
+		if end < 0 {
+			b.WriteString(md.span(s[:loc[1]]))
+			s = s[loc[1]:]
+			continue
+		}
+		b.WriteString(md.span(s[:loc[0]]))
+		b.WriteString("<code>" + html.EscapeString(strings.TrimSpace(s[loc[1]:loc[1]+end])) + "</code>")
+		s = s[loc[1]+end+len(ticks):]
+	}
+	b.WriteString(md.span(s))
+	return b.String()
+}
+
+// span 渲染不含行内代码的文字
+func (md *markdown) span(s string) string {
+	s = html.EscapeString(s)
+	s = reImage.ReplaceAllStringFunc(s, func(m string) string {
+		p := reImage.FindStringSubmatch(m)
+		return `<img src="` + md.url(p[2]) + `" alt="` + p[1] + `">`
+	})
+	s = reLink.ReplaceAllStringFunc(s, func(m string) string {
+		p := reLink.FindStringSubmatch(m)
+		return `<a href="` + md.url(p[2]) + `" rel="noopener noreferrer">` + p[1] + `</a>`
+	})
+	s = reAuto.ReplaceAllString(s, `<a href="$1" rel="noopener noreferrer">$1</a>`)
+	s = reStrong.ReplaceAllString(s, "<strong>$1$2</strong>")
+	s = reEm.ReplaceAllString(s, "<em>$1$2</em>")
+	s = reStrike.ReplaceAllString(s, "<del>$1</del>")
+	return strings.Replace(s, "\x00br\n", "<br>\n", -1)
+}
+
+// url 过滤链接地址，相对地址加上base
+func (md *markdown) url(u string) string {
+	raw := html.UnescapeString(u)
+	if reScheme.MatchString(raw) {
+		lower := strings.ToLower(raw)
+		if !strings.HasPrefix(lower, "http:") && !strings.HasPrefix(lower, "https:") && !strings.HasPrefix(lower, "mailto:") {
+			return "#"
+		}
+		return u
+	}
+	if md.base != "" && !strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "#") && !strings.HasPrefix(raw, "?") {
+		return html.EscapeString(strings.TrimSuffix(md.base, "/") + "/" + raw)
+	}
+	return u
+}
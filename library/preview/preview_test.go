@@ -0,0 +1,145 @@
+package preview
+
+import (
+	"github.com/gogf/gf/encoding/gcharset"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		name string
+		head string
+		kind string
+	}{
+		{"a.log", "hello\nworld\n", KindText},
+		{"README.md", "# title\n", KindMarkdown},
+		{"a.pdf", "whatever", KindPDF},
+		{"noext", "%PDF-1.7\n", KindPDF},
+		{"a.bin", "ab\x00cd", ""},
+		{"a.md", "\x01\x02\x03\x04", ""},
+		{"utf16.txt", "\xff\xfea\x00b\x00", KindText},
+	}
+	for _, c := range cases {
+		if kind := Detect(c.name, []byte(c.head)); kind != c.kind {
+			t.Errorf("%s: %q != %q", c.name, kind, c.kind)
+		}
+	}
+}
+
+func TestDetectCharset(t *testing.T) {
+	gbk, err := gcharset.UTF8To("GBK", "中文日志，编码测试")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"plain ascii":           "UTF-8",
+		"中文":                    "UTF-8",
+		"\xef\xbb\xbfbom":       "UTF-8",
+		"\xff\xfea\x00":         "UTF-16LE",
+		gbk:                     "GB18030",
+		"caf\xe9 \xe0 la r\xe8": "windows-1252",
+	}
+	for s, cs := range cases {
+		if got := DetectCharset([]byte(s)); got != cs {
+			t.Errorf("%q: %s != %s", s, got, cs)
+		}
+	}
+}
+
+func TestReadText(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preview")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	file := filepath.Join(dir, "a.log")
+	_ = ioutil.WriteFile(file, []byte("l1\nl2\nl3\nl4\n"), 0644)
+	text, err := ReadText(file, 2, 1024, "")
+	if err != nil || text.Content != "l1\nl2\n" || text.Lines != 2 || !text.Truncated {
+		t.Errorf("%+v %v", text, err)
+	}
+	text, _ = ReadText(file, 4, 1024, "")
+	if text.Lines != 4 || text.Truncated {
+		t.Errorf("%+v", text)
+	}
+	text, _ = ReadText(file, 10, 7, "")
+	if text.Content != "l1\nl2\n" || !text.Truncated {
+		t.Errorf("bytes %+v", text)
+	}
+
+	gbk, _ := gcharset.UTF8To("GBK", "第一行\n第二行\n")
+	_ = ioutil.WriteFile(file, []byte(gbk), 0644)
+	text, err = ReadText(file, 10, 1024, "")
+	if err != nil || text.Charset != "GB18030" || text.Content != "第一行\n第二行\n" {
+		t.Errorf("gbk %+v %v", text, err)
+	}
+
+	_ = ioutil.WriteFile(file, []byte("中文中文"), 0644)
+	text, _ = ReadText(file, 10, 7, "")
+	if text.Content != "中文" || !text.Truncated {
+		t.Errorf("long line %q", text.Content)
+	}
+
+	_ = ioutil.WriteFile(file, []byte("\xff\xfea\x00\n\x00b\x00\n\x00c\x00"), 0644)
+	text, err = ReadText(file, 2, 1024, "")
+	if err != nil || text.Charset != "UTF-16LE" || text.Content != "a\nb\n" || !text.Truncated {
+		t.Errorf("utf16 %+v %v", text, err)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	src := strings.Join([]string{
+		"# Title",
+		"",
+		"Some **bold**, *em*, ~~del~~ and `a<b>` text.",
+		"[link](docs/a.md) ![img](pic.png) [ext](https://example.com)",
+		"",
+		"- [x] done",
+		"- [ ] todo",
+		"",
+		"1. one",
+		"2. two",
+		"",
+		"> quote",
+		"",
+		"```go",
+		"fmt.Println(\"<hi>\")",
+		"```",
+		"",
+		"| a | b |",
+		"|---|--:|",
+		"| 1 | 2 |",
+		"",
+		"---",
+		"<script>alert(1)</script> [x](javascript:alert(1))",
+	}, "\n")
+	out := Markdown(src, "/files/dir")
+	for _, want := range []string{
+		"<h1>Title</h1>",
+		"<strong>bold</strong>", "<em>em</em>", "<del>del</del>", "<code>a&lt;b&gt;</code>",
+		`<a href="/files/dir/docs/a.md" rel="noopener noreferrer">link</a>`,
+		`<img src="/files/dir/pic.png" alt="img">`,
+		`href="https://example.com"`,
+		`<li><input type="checkbox" disabled checked> done</li>`,
+		"<ol>\n<li>one</li>\n<li>two</li>\n</ol>",
+		"<blockquote>\n<p>quote</p>\n</blockquote>",
+		`<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>`,
+		`<th>a</th><th style="text-align:right">b</th>`,
+		"<td>1</td>",
+		"<hr>",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`<a href="#" rel="noopener noreferrer">x</a>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<script>") || strings.Contains(out, "javascript:") {
+		t.Error("unsafe output", out)
+	}
+}
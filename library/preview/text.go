@@ -0,0 +1,177 @@
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/gogf/gf/encoding/gcharset"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// 文件预览: 识别文件类型，文本按编码转换为UTF-8并截取开头若干行，Markdown渲染为HTML
+
+// 预览类型
+const (
+	KindText     = "text"
+	KindMarkdown = "markdown"
+	KindPDF      = "pdf"
+)
+
+// markdownExts Markdown文件扩展名
+var markdownExts = map[string]bool{".md": true, ".markdown": true, ".mdown": true, ".mkd": true}
+
+// Detect 按扩展名与文件开头的内容识别预览类型，无法预览时返回空
+func Detect(name string, head []byte) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case ext == ".pdf" || bytes.HasPrefix(head, []byte("%PDF-")):
+		return KindPDF
+	case !IsText(head):
+		return ""
+	case markdownExts[ext]:
+		return KindMarkdown
+	}
+	return KindText
+}
+
+// IsText 内容是否为文本: 有UTF-16 BOM，或不含NUL且控制字符不超过1%
+func IsText(head []byte) bool {
+	if bytes.HasPrefix(head, []byte{0xFF, 0xFE}) || bytes.HasPrefix(head, []byte{0xFE, 0xFF}) {
+		return true
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	ctrl := 0
+	for _, c := range head {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != 0x1b {
+			ctrl++
+		}
+	}
+	return ctrl*100 <= len(head)
+}
+
+// candidates 非UTF-8文本依次尝试的编码，第一个能完整解码的即为结果
+var candidates = []string{"GB18030", "Big5", "Shift_JIS", "EUC-KR"}
+
+// DetectCharset 识别文本编码: BOM、UTF-8，其次依次尝试常见的中日韩编码，都不符合时为windows-1252
+func DetectCharset(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}):
+		return "UTF-8"
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE}):
+		return "UTF-16LE"
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		return "UTF-16BE"
+	case utf8.Valid(b):
+		return "UTF-8"
+	}
+	for _, cs := range candidates {
+		if s, err := gcharset.ToUTF8(cs, string(b)); err == nil && !strings.ContainsRune(s, utf8.RuneError) {
+			return cs
+		}
+	}
+	return "windows-1252"
+}
+
+// Text 文本预览
+type Text struct {
+	Charset   string `json:"charset"`   // 原文件编码
+	Content   string `json:"content"`   // 转换为UTF-8的内容
+	Lines     int    `json:"lines"`     // 返回的行数
+	Truncated bool   `json:"truncated"` // 是否只返回了开头部分
+}
+
+// ReadText 读取文件开头最多maxLines行、maxBytes字节，charset为空时自动识别编码
+func ReadText(file string, maxLines int, maxBytes int64, charset string) (Text, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Text{}, err
+	}
+	defer func() { _ = f.Close() }()
+	var (
+		buf   bytes.Buffer
+		t     Text
+		br    = bufio.NewReader(io.LimitReader(f, maxBytes+1))
+		utf16 bool
+	)
+	if head, _ := br.Peek(2); bytes.HasPrefix(head, []byte{0xFF, 0xFE}) || bytes.HasPrefix(head, []byte{0xFE, 0xFF}) {
+		// UTF-16的换行符占两个字节，按字节数截取
+		utf16 = true
+		n, err := io.Copy(&buf, br)
+		if err != nil {
+			return Text{}, err
+		}
+		if n > maxBytes {
+			buf.Truncate(int(maxBytes &^ 1))
+			t.Truncated = true
+		}
+	}
+	for !utf16 {
+		line, err := br.ReadBytes('\n')
+		if int64(buf.Len()+len(line)) > maxBytes {
+			// 超过字节数时舍弃不完整的一行，第一行就超过时截断到字符边界
+			if buf.Len() == 0 {
+				line = line[:maxBytes]
+				for i := 0; i < utf8.UTFMax && len(line) > 0 && !utf8.Valid(line); i++ {
+					line = line[:len(line)-1]
+				}
+				buf.Write(line)
+			}
+			t.Truncated = true
+			break
+		}
+		buf.Write(line)
+		if len(line) > 0 {
+			t.Lines++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Text{}, err
+		}
+		if t.Lines >= maxLines {
+			if _, err := br.Peek(1); err == nil {
+				t.Truncated = true
+			}
+			break
+		}
+	}
+	t.Charset = charset
+	if t.Charset == "" {
+		t.Charset = DetectCharset(buf.Bytes())
+	}
+	b := bytes.TrimPrefix(buf.Bytes(), []byte{0xEF, 0xBB, 0xBF})
+	if t.Charset == "UTF-8" {
+		t.Content = string(b)
+	} else if t.Content, err = gcharset.ToUTF8(t.Charset, string(b)); err != nil {
+		return Text{}, err
+	}
+	t.Content = strings.TrimPrefix(t.Content, "\uFEFF")
+	if utf16 {
+		t.Content, t.Lines = limitLines(t.Content, maxLines, &t.Truncated)
+	}
+	return t, nil
+}
+
+// limitLines 截取前maxLines行
+func limitLines(s string, maxLines int, truncated *bool) (string, int) {
+	n, i := 0, 0
+	for i < len(s) {
+		j := strings.IndexByte(s[i:], '\n')
+		if j < 0 {
+			return s, n + 1
+		}
+		i += j + 1
+		n++
+		if n >= maxLines && i < len(s) {
+			*truncated = true
+			return s[:i], n
+		}
+	}
+	return s, n
+}
@@ -0,0 +1,179 @@
+package progress
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// 逐文件上传进度: 一个请求上传多个文件时(如浏览器一次选择50张照片)，按文件报告排队、传输中、完成或失败，
+// 传输中的字节数按最短间隔合并后推送，状态变化立即推送
+
+// 文件的状态
+const (
+	Queued  = "queued"  // 已声明，尚未开始接收
+	Active  = "active"  // 接收中
+	Done    = "done"    // 已保存
+	Skipped = "skipped" // 同名文件已存在，保留原文件
+	Failed  = "failed"
+)
+
+// ErrAborted 请求结束时仍未完成的文件
+var ErrAborted = errors.New("upload aborted")
+
+// Item 一个文件的进度
+type Item struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Size  int64  `json:"size"` // 客户端声明的大小，未声明时为0
+	Bytes int64  `json:"bytes"`
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Tracker 一个上传请求中各文件的进度，emit在锁外调用
+type Tracker struct {
+	ID       string
+	mu       sync.Mutex
+	interval time.Duration
+	emit     func(Item)
+	items    []Item
+	last     time.Time
+	closed   time.Time
+}
+
+// New interval为同一请求推送字节进度的最短间隔
+func New(id string, interval time.Duration, emit func(Item)) *Tracker {
+	if emit == nil {
+		emit = func(Item) {}
+	}
+	return &Tracker{ID: id, interval: interval, emit: emit}
+}
+
+// Declare 客户端预先声明的文件(名称与大小)，全部进入排队状态
+func (t *Tracker) Declare(items []Item) {
+	t.mu.Lock()
+	var queued []Item
+	for _, it := range items {
+		it = Item{Index: len(t.items), Name: it.Name, Size: it.Size, State: Queued}
+		t.items = append(t.items, it)
+		queued = append(queued, it)
+	}
+	t.mu.Unlock()
+	for _, it := range queued {
+		t.emit(it)
+	}
+}
+
+// Start 文件开始接收，按顺序匹配第一个同名的排队项，没有声明时追加，返回序号
+func (t *Tracker) Start(name string) int {
+	t.mu.Lock()
+	index := -1
+	for i := range t.items {
+		if t.items[i].State == Queued && t.items[i].Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		index = len(t.items)
+		t.items = append(t.items, Item{Index: index, Name: name})
+	}
+	t.items[index].State = Active
+	it := t.items[index]
+	t.last = time.Now()
+	t.mu.Unlock()
+	t.emit(it)
+	return index
+}
+
+// Progress 文件已接收n字节(累计)，距上次推送不足interval时只记录
+func (t *Tracker) Progress(index int, n int64) {
+	t.mu.Lock()
+	if index < 0 || index >= len(t.items) {
+		t.mu.Unlock()
+		return
+	}
+	t.items[index].Bytes = n
+	now := time.Now()
+	if now.Sub(t.last) < t.interval {
+		t.mu.Unlock()
+		return
+	}
+	t.last = now
+	it := t.items[index]
+	t.mu.Unlock()
+	t.emit(it)
+}
+
+// Finish 文件已保存(url为下载路径)或失败
+func (t *Tracker) Finish(index int, url string, err error) {
+	if err != nil {
+		t.set(index, Failed, "", err.Error())
+	} else {
+		t.set(index, Done, url, "")
+	}
+}
+
+// Skip 同名文件已存在，未保存上传的内容，url为已有文件的下载路径
+func (t *Tracker) Skip(index int, url string) {
+	t.set(index, Skipped, url, "")
+}
+
+func (t *Tracker) set(index int, state, url, msg string) {
+	t.mu.Lock()
+	if index < 0 || index >= len(t.items) {
+		t.mu.Unlock()
+		return
+	}
+	it := &t.items[index]
+	it.State, it.URL, it.Error = state, url, msg
+	done := *it
+	t.mu.Unlock()
+	t.emit(done)
+}
+
+// Close 请求结束，仍在排队或接收中的文件标记为失败
+func (t *Tracker) Close() {
+	t.mu.Lock()
+	var failed []Item
+	for i := range t.items {
+		if it := &t.items[i]; it.State == Queued || it.State == Active {
+			it.State, it.Error = Failed, ErrAborted.Error()
+			failed = append(failed, *it)
+		}
+	}
+	t.closed = time.Now()
+	t.mu.Unlock()
+	for _, it := range failed {
+		t.emit(it)
+	}
+}
+
+// Closed 请求结束的时间，未结束时为零值
+func (t *Tracker) Closed() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// Items 全部文件进度的副本
+func (t *Tracker) Items() []Item {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Item{}, t.items...)
+}
+
+// Failed 失败的文件数
+func (t *Tracker) Failed() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var n int
+	for _, it := range t.items {
+		if it.State == Failed {
+			n++
+		}
+	}
+	return n
+}
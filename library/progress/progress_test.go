@@ -0,0 +1,54 @@
+package progress
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	var events []Item
+	tr := New("u1", time.Hour, func(it Item) { events = append(events, it) })
+	tr.Declare([]Item{{Name: "a.jpg", Size: 10}, {Name: "b.jpg", Size: 20}, {Name: "a.jpg", Size: 30}, {Name: "d.jpg"}})
+	if len(events) != 4 || events[2].Index != 2 || events[2].State != Queued {
+		t.Fatalf("declare %+v", events)
+	}
+	a := tr.Start("a.jpg")
+	// 间隔内的字节进度只记录不推送
+	tr.Progress(a, 5)
+	if a != 0 || len(events) != 5 || events[4].State != Active {
+		t.Fatalf("start %+v", events)
+	}
+	tr.Finish(a, "/files/a.jpg", nil)
+	// 同名文件匹配下一个排队项
+	if a2 := tr.Start("a.jpg"); a2 != 2 {
+		t.Error("second a", a2)
+	}
+	tr.Finish(2, "", errors.New("disk full"))
+	tr.Skip(tr.Start("d.jpg"), "/files/d.jpg")
+	// 未声明的文件追加
+	if c := tr.Start("c.jpg"); c != 4 {
+		t.Error("undeclared", c)
+	}
+	tr.Close()
+	items := tr.Items()
+	want := []string{Done, Failed, Failed, Skipped, Failed}
+	for i, it := range items {
+		if it.State != want[i] {
+			t.Errorf("%d %+v", i, it)
+		}
+	}
+	if items[0].Bytes != 5 || items[0].URL != "/files/a.jpg" || items[1].Error != ErrAborted.Error() || items[2].Error != "disk full" || items[3].URL != "/files/d.jpg" {
+		t.Errorf("%+v", items)
+	}
+	if tr.Failed() != 3 || tr.Closed().IsZero() {
+		t.Error("failed", tr.Failed())
+	}
+
+	events = nil
+	tr = New("u2", 0, func(it Item) { events = append(events, it) })
+	tr.Progress(tr.Start("x"), 7)
+	if len(events) != 2 || events[1].Bytes != 7 {
+		t.Errorf("progress %+v", events)
+	}
+}
@@ -0,0 +1,110 @@
+package protect
+
+import (
+	"sync"
+	"time"
+)
+
+// Keyring 已解锁目录的密钥，按设备记录，设备超过idle未访问时自动锁定，没有设备解锁时从内存中清除密钥
+type Keyring struct {
+	mu   sync.Mutex
+	idle time.Duration
+	keys map[string]*unlocked
+}
+
+type unlocked struct {
+	key     []byte
+	clients map[string]time.Time
+}
+
+// NewKeyring idle为0时解锁后一直有效(直到手动锁定或重启)
+func NewKeyring(idle time.Duration) *Keyring {
+	return &Keyring{idle: idle, keys: make(map[string]*unlocked)}
+}
+
+// Unlock 为设备client解锁目录
+func (k *Keyring) Unlock(rel, client string, key []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	rel = cleanPath(rel)
+	u, ok := k.keys[rel]
+	if !ok {
+		u = &unlocked{key: append([]byte(nil), key...), clients: make(map[string]time.Time)}
+		k.keys[rel] = u
+	}
+	u.clients[client] = time.Now()
+}
+
+// Key 设备已解锁时返回目录的密钥，并刷新最后访问时间
+func (k *Keyring) Key(rel, client string) ([]byte, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	u, ok := k.keys[cleanPath(rel)]
+	if !ok {
+		return nil, false
+	}
+	if _, ok := u.clients[client]; !ok {
+		return nil, false
+	}
+	u.clients[client] = time.Now()
+	// 返回副本，锁定时清除密钥不影响进行中的读写
+	return append([]byte(nil), u.key...), true
+}
+
+// Unlocked 设备是否已解锁目录，不刷新访问时间
+func (k *Keyring) Unlocked(rel, client string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	u, ok := k.keys[cleanPath(rel)]
+	if ok {
+		_, ok = u.clients[client]
+	}
+	return ok
+}
+
+// Lock 为设备锁定目录，client为空时为全部设备锁定
+func (k *Keyring) Lock(rel, client string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	rel = cleanPath(rel)
+	u, ok := k.keys[rel]
+	if !ok {
+		return
+	}
+	if client != "" {
+		delete(u.clients, client)
+	}
+	if client == "" || len(u.clients) == 0 {
+		wipe(u.key)
+		delete(k.keys, rel)
+	}
+}
+
+// Expire 锁定超过idle未访问的设备，返回全部锁定的目录
+func (k *Keyring) Expire(now time.Time) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var locked []string
+	if k.idle <= 0 {
+		return locked
+	}
+	for rel, u := range k.keys {
+		for c, t := range u.clients {
+			if now.Sub(t) > k.idle {
+				delete(u.clients, c)
+			}
+		}
+		if len(u.clients) == 0 {
+			wipe(u.key)
+			delete(k.keys, rel)
+			locked = append(locked, rel)
+		}
+	}
+	return locked
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
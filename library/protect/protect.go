@@ -0,0 +1,318 @@
+package protect
+
+import (
+	"b0pass/library/argon2"
+	"b0pass/library/e2e"
+	"b0pass/library/fsync"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 受保护目录: 目录中的文件以端到端加密相同的分块格式(library/e2e)加密保存，密钥由口令经Argon2id派生，
+// 只在解锁后保存在内存中；电脑丢失或被盗时，没有口令无法读取文件内容
+
+// MinPassphrase 口令的最短长度(字符)
+const MinPassphrase = 8
+
+// 默认的Argon2id参数(RFC 9106推荐的第二组)
+const (
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+)
+
+var (
+	ErrPassphrase = errors.New("wrong passphrase")
+	ErrWeak       = errors.New("passphrase too short")
+	ErrExist      = errors.New("folder already protected")
+	ErrNested     = errors.New("folder overlaps another protected folder")
+	ErrNoEntry    = errors.New("folder not protected")
+)
+
+// Folder 受保护目录，只保存派生密钥所需的参数与校验值
+type Folder struct {
+	Path    string    `json:"path"` // 共享目录下的相对路径，如 docs/secret
+	Salt    []byte    `json:"salt"`
+	Time    uint32    `json:"time"`
+	Memory  uint32    `json:"memory"`
+	Threads uint8     `json:"threads"`
+	Check   []byte    `json:"check"` // 用密钥计算的HMAC，验证口令
+	Created time.Time `json:"created"`
+}
+
+// NewFolder 用口令保护目录rel，返回目录与派生的密钥
+func NewFolder(rel, passphrase string) (Folder, []byte, error) {
+	if utf8.RuneCountInString(passphrase) < MinPassphrase {
+		return Folder{}, nil, ErrWeak
+	}
+	f := Folder{Path: cleanPath(rel), Salt: make([]byte, 16), Time: kdfTime, Memory: kdfMemory, Threads: kdfThreads, Created: time.Now()}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return f, nil, err
+	}
+	key := f.derive(passphrase)
+	f.Check = check(key)
+	return f, key, nil
+}
+
+func (f Folder) derive(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), f.Salt, f.Time, f.Memory, f.Threads, e2e.KeySize)
+}
+
+func check(key []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte("b0pass-protect"))
+	return m.Sum(nil)
+}
+
+// Key 由口令派生密钥，口令错误时返回ErrPassphrase
+func (f Folder) Key(passphrase string) ([]byte, error) {
+	key := f.derive(passphrase)
+	if !hmac.Equal(check(key), f.Check) {
+		return nil, ErrPassphrase
+	}
+	return key, nil
+}
+
+// Contains 共享目录下的相对路径rel是否在该目录中
+func (f Folder) Contains(rel string) bool {
+	rel = cleanPath(rel)
+	return rel == f.Path || strings.HasPrefix(rel, f.Path+"/")
+}
+
+func cleanPath(rel string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(rel)), "/")
+}
+
+// Store 受保护目录列表，持久化到文件
+type Store struct {
+	mu      sync.Mutex
+	file    string
+	folders map[string]Folder
+}
+
+// Open 打开存储文件，文件不存在时创建空存储
+func Open(file string) *Store {
+	s := &Store{file: file, folders: make(map[string]Folder)}
+	var list []Folder
+	if b, err := ioutil.ReadFile(file); err == nil {
+		_ = json.Unmarshal(b, &list)
+	}
+	for _, f := range list {
+		s.folders[f.Path] = f
+	}
+	return s
+}
+
+// Add 添加受保护目录，不能与已有的受保护目录互相包含
+func (s *Store) Add(f Folder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.folders[f.Path]; ok {
+		return ErrExist
+	}
+	for _, o := range s.folders {
+		if o.Contains(f.Path) || f.Contains(o.Path) {
+			return ErrNested
+		}
+	}
+	s.folders[f.Path] = f
+	return s.save()
+}
+
+// Get 受保护目录
+func (s *Store) Get(rel string) (Folder, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.folders[cleanPath(rel)]
+	return f, ok
+}
+
+// Find 文件所在的受保护目录
+func (s *Store) Find(rel string) (Folder, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.folders {
+		if f.Contains(rel) {
+			return f, true
+		}
+	}
+	return Folder{}, false
+}
+
+// Remove 取消保护(文件需先解密)
+func (s *Store) Remove(rel string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rel = cleanPath(rel)
+	if _, ok := s.folders[rel]; !ok {
+		return ErrNoEntry
+	}
+	delete(s.folders, rel)
+	return s.save()
+}
+
+// List 全部受保护目录，按路径排序
+func (s *Store) List() []Folder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list()
+}
+
+func (s *Store) list() []Folder {
+	list := make([]Folder, 0, len(s.folders))
+	for _, f := range s.folders {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// save 持久化到文件，原子替换文件
+func (s *Store) save() error {
+	if s.file == "" {
+		return nil
+	}
+	return fsync.WriteJSON(s.file, s.list(), 0600)
+}
+
+// IsEncrypted 文件是否已加密
+func IsEncrypted(file string) bool {
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	_, _, err = e2e.Layout(header, info.Size())
+	return err == nil
+}
+
+// OpenFile 打开加密文件并解密读取，返回的文件需由调用方关闭
+func OpenFile(file string, key []byte) (*os.File, *e2e.Reader, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		var r *e2e.Reader
+		if r, err = e2e.NewReader(f, info.Size(), key); err == nil {
+			return f, r, nil
+		}
+	}
+	_ = f.Close()
+	return nil, nil, err
+}
+
+// EncryptFile 加密文件(已加密的不处理)，先写入同目录的临时文件再替换，保留权限与修改时间
+func EncryptFile(file string, key []byte) error {
+	if IsEncrypted(file) {
+		return nil
+	}
+	return rewrite(file, func(dst io.Writer, src *os.File, size int64) error {
+		w, err := e2e.NewWriter(dst, key, e2e.DefaultChunk)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, src); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+}
+
+// DecryptFile 解密文件(未加密的不处理)，密钥错误时返回e2e.ErrKey且不修改文件
+func DecryptFile(file string, key []byte) error {
+	if !IsEncrypted(file) {
+		return nil
+	}
+	return rewrite(file, func(dst io.Writer, src *os.File, size int64) error {
+		r, err := e2e.NewReader(src, size, key)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, r)
+		return err
+	})
+}
+
+func rewrite(file string, convert func(dst io.Writer, src *os.File, size int64) error) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".protect-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	if err := convert(tmp, src, info.Size()); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	_ = os.Chmod(tmp.Name(), info.Mode().Perm())
+	_ = os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime())
+	return os.Rename(tmp.Name(), file)
+}
+
+// Walk 对目录下的每个普通文件(不含隐藏文件)执行fn，返回处理的文件数与第一个错误
+func Walk(dir string, fn func(file string) error) (int, error) {
+	var n int
+	var first error
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") && p != dir {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if err := fn(p); err != nil && first == nil {
+			first = err
+		}
+		n++
+		return nil
+	})
+	if first == nil {
+		first = err
+	}
+	return n, first
+}
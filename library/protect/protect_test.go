@@ -0,0 +1,153 @@
+package protect
+
+import (
+	"b0pass/library/e2e"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "protect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	if _, _, err := NewFolder("docs", "short"); err != ErrWeak {
+		t.Error("weak", err)
+	}
+	f, key, err := NewFolder("/docs/secret/", "correct horse")
+	if err != nil || f.Path != "docs/secret" || len(key) != e2e.KeySize {
+		t.Fatal(f, err)
+	}
+	if k, err := f.Key("correct horse"); err != nil || !bytes.Equal(k, key) {
+		t.Error("key", err)
+	}
+	if _, err := f.Key("wrong horse"); err != ErrPassphrase {
+		t.Error("wrong", err)
+	}
+	if !f.Contains("docs/secret/a.txt") || !f.Contains("/docs/secret") || f.Contains("docs/secrets/a.txt") {
+		t.Error("contains")
+	}
+
+	file := filepath.Join(dir, "protected.json")
+	s := Open(file)
+	if err := s.Add(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(Folder{Path: "docs"}); err != ErrNested {
+		t.Error("nested", err)
+	}
+	if err := s.Add(Folder{Path: "docs/secret/inner"}); err != ErrNested {
+		t.Error("inner", err)
+	}
+	s2 := Open(file)
+	if g, ok := s2.Find("docs/secret/x/y.txt"); !ok || g.Path != f.Path {
+		t.Error("find", g)
+	}
+	if g, ok := s2.Get("docs/secret"); !ok {
+		t.Error("get")
+	} else if _, err := g.Key("correct horse"); err != nil {
+		t.Error("reopen key", err)
+	}
+	if err := s2.Remove("docs/secret"); err != nil || len(Open(file).List()) != 0 {
+		t.Error("remove", err)
+	}
+	if err := s2.Remove("docs/secret"); err != ErrNoEntry {
+		t.Error("remove again", err)
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "protect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	key := e2e.NewKey()
+	data := bytes.Repeat([]byte("b0pass "), 400000)
+	file := filepath.Join(dir, "a.txt")
+	_ = ioutil.WriteFile(file, data, 0640)
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	_ = os.Chtimes(file, mtime, mtime)
+
+	if IsEncrypted(file) {
+		t.Fatal("plain")
+	}
+	if err := EncryptFile(file, key); err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(file) {
+		t.Fatal("encrypted")
+	}
+	if b, _ := ioutil.ReadFile(file); bytes.Contains(b, []byte("b0pass")) {
+		t.Error("plaintext on disk")
+	}
+	info, _ := os.Stat(file)
+	if !info.ModTime().Equal(mtime) || info.Mode().Perm() != 0640 {
+		t.Error("attributes", info.ModTime(), info.Mode())
+	}
+	// 重复加密不处理
+	if err := EncryptFile(file, key); err != nil {
+		t.Fatal(err)
+	}
+
+	f, r, err := OpenFile(file, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || !bytes.Equal(b, data) {
+		t.Error("read", err)
+	}
+	_ = f.Close()
+
+	if err := DecryptFile(file, e2e.NewKey()); err != e2e.ErrKey || !IsEncrypted(file) {
+		t.Error("wrong key", err)
+	}
+	_ = ioutil.WriteFile(filepath.Join(dir, "b.bin"), nil, 0644)
+	_ = os.Mkdir(filepath.Join(dir, ".hidden"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(dir, ".hidden", "c"), data, 0644)
+	n, err := Walk(dir, func(p string) error { return DecryptFile(p, key) })
+	if err != nil || n != 2 {
+		t.Error("walk", n, err)
+	}
+	if b, _ := ioutil.ReadFile(file); !bytes.Equal(b, data) {
+		t.Error("decrypt")
+	}
+	if list, _ := ioutil.ReadDir(dir); len(list) != 3 {
+		t.Error("temp files left", len(list))
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	k := NewKeyring(time.Minute)
+	key := []byte("0123456789abcdef0123456789abcdef")
+	k.Unlock("docs", "phone", key)
+	k.Unlock("/docs/", "laptop", key)
+	if got, ok := k.Key("docs", "phone"); !ok || !bytes.Equal(got, key) {
+		t.Error("key")
+	}
+	if k.Unlocked("docs", "tablet") {
+		t.Error("tablet")
+	}
+	k.Lock("docs", "phone")
+	if k.Unlocked("docs", "phone") || !k.Unlocked("docs", "laptop") {
+		t.Error("lock one")
+	}
+	if locked := k.Expire(time.Now().Add(2 * time.Minute)); len(locked) != 1 || locked[0] != "docs" {
+		t.Error("expire", locked)
+	}
+	if _, ok := k.Key("docs", "laptop"); ok {
+		t.Error("expired")
+	}
+	k.Unlock("docs", "phone", key)
+	k.Lock("docs", "")
+	if k.Unlocked("docs", "phone") {
+		t.Error("lock all")
+	}
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 反向代理: 只有来自受信任代理(nginx、Traefik等)的请求才采用X-Forwarded-For/X-Forwarded-Proto/X-Forwarded-Host，
+// 其余请求的这些请求头会被删除，避免客户端伪造地址绕过限流或写入日志
+
+// 代理相关的请求头
+const (
+	HeaderFor   = "X-Forwarded-For"
+	HeaderProto = "X-Forwarded-Proto"
+	HeaderHost  = "X-Forwarded-Host"
+	HeaderReal  = "X-Real-IP"
+)
+
+// Trusted 受信任的代理地址
+type Trusted []*net.IPNet
+
+// Parse 解析受信任的代理，每项为IP(如127.0.0.1、::1)或网段(如10.0.0.0/8)
+func Parse(list []string) (Trusted, error) {
+	var t Trusted
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		t = append(t, n)
+	}
+	return t, nil
+}
+
+// Contains ip是否为受信任的代理
+func (t Trusted) Contains(ip string) bool {
+	addr := net.ParseIP(strings.Trim(ip, "[]"))
+	if addr == nil {
+		return false
+	}
+	for _, n := range t {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 客户端地址: 直连地址remote不受信任时为remote，否则从X-Forwarded-For的最右边开始，
+// 跳过受信任的代理后的第一个地址；X-Forwarded-For中的地址都受信任时为最左边的地址
+func (t Trusted) ClientIP(remote, forwarded string) string {
+	if !t.Contains(remote) || forwarded == "" {
+		return remote
+	}
+	hops := strings.Split(forwarded, ",")
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(strings.Trim(hop, "[]")) == nil {
+			break
+		}
+		client = strings.Trim(hop, "[]")
+		if !t.Contains(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// Forwarded 请求是否带有代理添加的请求头(X-Forwarded-*、X-Real-IP或Forwarded)，须在Apply之前判断
+func Forwarded(r *http.Request) bool {
+	for _, h := range []string{HeaderFor, HeaderProto, HeaderHost, HeaderReal, "Forwarded"} {
+		if r.Header.Get(h) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply 按受信任的代理改写请求: 来自受信任代理时RemoteAddr与X-Real-IP改为客户端地址，
+// 否则删除X-Forwarded-*与X-Real-IP；返回是否经过受信任的代理
+func (t Trusted) Apply(r *http.Request) bool {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if len(t) == 0 || !t.Contains(remote) {
+		for _, h := range []string{HeaderFor, HeaderProto, HeaderHost, HeaderReal, "Forwarded"} {
+			r.Header.Del(h)
+		}
+		return false
+	}
+	forwarded := strings.Join(r.Header[HeaderFor], ",")
+	if forwarded == "" {
+		// 只设置了X-Real-IP的代理(如nginx的proxy_set_header X-Real-IP $remote_addr)
+		forwarded = r.Header.Get(HeaderReal)
+	}
+	client := t.ClientIP(remote, forwarded)
+	if client != remote {
+		r.RemoteAddr = net.JoinHostPort(client, "0")
+	}
+	r.Header.Set(HeaderReal, client)
+	if host := r.Header.Get(HeaderHost); host != "" {
+		r.Host = strings.TrimSpace(strings.Split(host, ",")[0])
+	}
+	return true
+}
+
+// Scheme 请求方使用的协议(http或https)，经Apply处理后的请求才能信任X-Forwarded-Proto
+func Scheme(r *http.Request) string {
+	switch strings.ToLower(strings.TrimSpace(strings.Split(r.Header.Get(HeaderProto), ",")[0])) {
+	case "https":
+		return "https"
+	case "http":
+		return "http"
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tr, err := Parse([]string{"127.0.0.1", "::1", " 10.0.0.0/8 ", ""})
+	if err != nil || len(tr) != 3 {
+		t.Fatal(tr, err)
+	}
+	for ip, want := range map[string]bool{"127.0.0.1": true, "[::1]": true, "10.1.2.3": true, "192.168.1.2": false, "bad": false} {
+		if tr.Contains(ip) != want {
+			t.Fatal(ip, want)
+		}
+	}
+	if _, err := Parse([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := Parse([]string{"example.com"}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tr, _ := Parse([]string{"127.0.0.1", "10.0.0.0/8"})
+	cases := []struct{ remote, forwarded, want string }{
+		{"192.168.1.5", "1.2.3.4", "192.168.1.5"},                      // 直连，不采用请求头
+		{"127.0.0.1", "", "127.0.0.1"},                                 // 代理未转发地址
+		{"127.0.0.1", "192.168.1.5", "192.168.1.5"},                    // 一层代理
+		{"127.0.0.1", "1.2.3.4, 192.168.1.5, 10.0.0.2", "192.168.1.5"}, // 多层代理，客户端伪造的1.2.3.4被忽略
+		{"127.0.0.1", "10.0.0.3, 10.0.0.2", "10.0.0.3"},                // 全部受信任
+		{"127.0.0.1", "garbage, 192.168.1.5", "192.168.1.5"},
+		{"127.0.0.1", "[2001:db8::1]", "2001:db8::1"},
+	}
+	for _, c := range cases {
+		if got := tr.ClientIP(c.remote, c.forwarded); got != c.want {
+			t.Fatal(c, got)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tr, _ := Parse([]string{"127.0.0.1"})
+
+	// 不受信任的来源: 删除代理请求头
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.168.1.5:4000"
+	if Forwarded(r) {
+		t.Fatal("direct request")
+	}
+	r.Header.Set(HeaderFor, "1.2.3.4")
+	r.Header.Set(HeaderReal, "1.2.3.4")
+	r.Header.Set(HeaderProto, "https")
+	if !Forwarded(r) || tr.Apply(r) || r.Header.Get(HeaderFor) != "" || r.Header.Get(HeaderReal) != "" || Scheme(r) != "http" || r.RemoteAddr != "192.168.1.5:4000" {
+		t.Fatal(r.Header, r.RemoteAddr)
+	}
+
+	// 受信任的代理
+	r = httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	r.Header.Add(HeaderFor, "1.2.3.4")
+	r.Header.Add(HeaderFor, "192.168.1.5")
+	r.Header.Set(HeaderProto, "https")
+	r.Header.Set(HeaderHost, "files.example.com")
+	if !tr.Apply(r) || r.RemoteAddr != "192.168.1.5:0" || r.Header.Get(HeaderReal) != "192.168.1.5" || Scheme(r) != "https" || r.Host != "files.example.com" {
+		t.Fatal(r.Header, r.RemoteAddr, r.Host)
+	}
+
+	// 只设置X-Real-IP的代理
+	r = httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	r.Header.Set(HeaderReal, "192.168.1.6")
+	if !tr.Apply(r) || r.RemoteAddr != "192.168.1.6:0" {
+		t.Fatal(r.RemoteAddr)
+	}
+
+	// 未配置受信任的代理
+	r = httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	r.Header.Set(HeaderFor, "1.2.3.4")
+	r.TLS = &tls.ConnectionState{}
+	if Trusted(nil).Apply(r) || r.Header.Get(HeaderFor) != "" || Scheme(r) != "https" {
+		t.Fatal(r.Header)
+	}
+}
@@ -0,0 +1,63 @@
+package qrcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Quiet 图片四周保留的空白模块数(规范要求的静区)
+const Quiet = 4
+
+// Image 每个模块绘制为scale×scale像素的黑白图片，四周保留Quiet个模块的空白
+func (c *Code) Image(scale int) *image.Paletted {
+	if scale < 1 {
+		scale = 1
+	}
+	n := (c.Size + 2*Quiet) * scale
+	img := image.NewPaletted(image.Rect(0, 0, n, n), color.Palette{color.White, color.Black})
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if c.Black(x/scale-Quiet, y/scale-Quiet) {
+				img.Pix[y*img.Stride+x] = 1
+			}
+		}
+	}
+	return img
+}
+
+// PNG 以PNG格式写出Image(scale)
+func (c *Code) PNG(w io.Writer, scale int) error {
+	return png.Encode(w, c.Image(scale))
+}
+
+// SVG 矢量图，每个模块为1个单位，四周保留Quiet个模块的空白；size为显示的宽高(像素)，为0时不指定
+// 适合打印与任意缩放
+func (c *Code) SVG(size int) string {
+	n := c.Size + 2*Quiet
+	var sb strings.Builder
+	dim := ""
+	if size > 0 {
+		dim = fmt.Sprintf(` width="%d" height="%d"`, size, size)
+	}
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d"%s shape-rendering="crispEdges">`, n, n, dim)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			// 同一行连续的深色模块合并为一个矩形
+			if !c.Black(x, y) || c.Black(x-1, y) {
+				continue
+			}
+			w := 1
+			for c.Black(x+w, y) {
+				w++
+			}
+			fmt.Fprintf(&sb, "M%d %dh%dv1h-%dz", x+Quiet, y+Quiet, w, w)
+		}
+	}
+	sb.WriteString(`"/></svg>`)
+	return sb.String()
+}
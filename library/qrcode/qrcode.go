@@ -0,0 +1,482 @@
+package qrcode
+
+import (
+	"errors"
+	"strings"
+)
+
+// QR码编码(字节模式，版本1-10)，用于在终端或PNG图片中输出访问地址
+
+// Level 纠错等级
+type Level int
+
+const (
+	L Level = iota // 约7%
+	M              // 约15%
+	Q              // 约25%
+	H              // 约30%
+)
+
+// ErrTooLong 内容超出支持的最大版本容量
+var ErrTooLong = errors.New("qrcode: content too long")
+
+// ParseLevel 解析纠错等级L、M、Q、H(不区分大小写)，为空时为M
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "L":
+		return L, nil
+	case "", "M":
+		return M, nil
+	case "Q":
+		return Q, nil
+	case "H":
+		return H, nil
+	}
+	return M, errors.New("qrcode: level must be L, M, Q or H")
+}
+
+// Code 编码结果
+type Code struct {
+	Size    int
+	modules []bool
+	fixed   []bool
+}
+
+// Black 坐标(x列,y行)处是否为深色模块
+func (c *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y*c.Size+x]
+}
+
+// blocks 每个版本各纠错等级的分块: 每块纠错码数, 组1块数, 组1数据码数, 组2块数, 组2数据码数
+var blocks = [10][4][5]int{
+	{{7, 1, 19, 0, 0}, {10, 1, 16, 0, 0}, {13, 1, 13, 0, 0}, {17, 1, 9, 0, 0}},
+	{{10, 1, 34, 0, 0}, {16, 1, 28, 0, 0}, {22, 1, 22, 0, 0}, {28, 1, 16, 0, 0}},
+	{{15, 1, 55, 0, 0}, {26, 1, 44, 0, 0}, {18, 2, 17, 0, 0}, {22, 2, 13, 0, 0}},
+	{{20, 1, 80, 0, 0}, {18, 2, 32, 0, 0}, {26, 2, 24, 0, 0}, {16, 4, 9, 0, 0}},
+	{{26, 1, 108, 0, 0}, {24, 2, 43, 0, 0}, {18, 2, 15, 2, 16}, {22, 2, 11, 2, 12}},
+	{{18, 2, 68, 0, 0}, {16, 4, 27, 0, 0}, {24, 4, 19, 0, 0}, {28, 4, 15, 0, 0}},
+	{{20, 2, 78, 0, 0}, {18, 4, 31, 0, 0}, {18, 2, 14, 4, 15}, {26, 4, 13, 1, 14}},
+	{{24, 2, 97, 0, 0}, {22, 2, 38, 2, 39}, {22, 4, 18, 2, 19}, {26, 4, 14, 2, 15}},
+	{{30, 2, 116, 0, 0}, {22, 3, 36, 2, 37}, {20, 4, 16, 4, 17}, {24, 4, 12, 4, 13}},
+	{{18, 2, 68, 2, 69}, {26, 4, 43, 1, 44}, {24, 6, 19, 2, 20}, {28, 6, 15, 2, 16}},
+}
+
+// alignments 校正图形中心坐标
+var alignments = [10][]int{
+	nil, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// levelBits 格式信息中的纠错等级编码
+var levelBits = [4]int{1, 0, 3, 2}
+
+// Encode 按字节模式编码，自动选择能容纳内容的最小版本和最佳掩码
+func Encode(text string, level Level) (*Code, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v <= len(blocks); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*dataCapacity(v, level) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+	codewords := interleave(version, level, encodeData(version, level, data))
+
+	c := &Code{Size: version*4 + 17}
+	c.modules = make([]bool, c.Size*c.Size)
+	c.fixed = make([]bool, c.Size*c.Size)
+	c.drawPatterns(version)
+	c.drawFormat(level, 0)
+	c.drawCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(level, mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormat(level, best)
+	return c, nil
+}
+
+// dataCapacity 数据码字数
+func dataCapacity(version int, level Level) int {
+	b := blocks[version-1][level]
+	return b[1]*b[2] + b[3]*b[4]
+}
+
+// encodeData 模式指示、长度、数据、终止符与填充
+func encodeData(version int, level Level, data []byte) []byte {
+	var bits []bool
+	put := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, v>>uint(i)&1 == 1)
+		}
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	put(4, 4)
+	put(len(data), countBits)
+	for _, b := range data {
+		put(int(b), 8)
+	}
+	capacity := dataCapacity(version, level) * 8
+	for i := 0; i < 4 && len(bits) < capacity; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+	for pad := 0; len(bits) < capacity; pad++ {
+		put([]int{0xEC, 0x11}[pad%2], 8)
+	}
+	out := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			out[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return out
+}
+
+// interleave 分块计算纠错码后交错排列
+func interleave(version int, level Level, data []byte) []byte {
+	b := blocks[version-1][level]
+	ecLen := b[0]
+	var dataBlocks, ecBlocks [][]byte
+	for g := 0; g < 2; g++ {
+		for i := 0; i < b[1+g*2]; i++ {
+			n := b[2+g*2]
+			dataBlocks = append(dataBlocks, data[:n])
+			ecBlocks = append(ecBlocks, reedSolomon(data[:n], ecLen))
+			data = data[n:]
+		}
+	}
+	var out []byte
+	for i := 0; ; i++ {
+		added := false
+		for _, blk := range dataBlocks {
+			if i < len(blk) {
+				out = append(out, blk[i])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	for i := 0; i < ecLen; i++ {
+		for _, blk := range ecBlocks {
+			out = append(out, blk[i])
+		}
+	}
+	return out
+}
+
+// gfMul GF(256)乘法，本原多项式0x11D
+func gfMul(a, b byte) byte {
+	var r byte
+	for b > 0 {
+		if b&1 == 1 {
+			r ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1D
+		}
+		b >>= 1
+	}
+	return r
+}
+
+// reedSolomon 计算纠错码字
+func reedSolomon(data []byte, n int) []byte {
+	gen := []byte{1}
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		next := make([]byte, len(gen)+1)
+		for j, g := range gen {
+			next[j] ^= g
+			next[j+1] ^= gfMul(g, root)
+		}
+		gen = next
+		root = gfMul(root, 2)
+	}
+	rem := make([]byte, n)
+	for _, d := range data {
+		factor := d ^ rem[0]
+		copy(rem, rem[1:])
+		rem[n-1] = 0
+		for j := 0; j < n; j++ {
+			rem[j] ^= gfMul(gen[j+1], factor)
+		}
+	}
+	return rem
+}
+
+func (c *Code) set(x, y int, black bool) {
+	c.modules[y*c.Size+x] = black
+	c.fixed[y*c.Size+x] = true
+}
+
+// drawPatterns 定位、分隔、时序、校正图形和版本信息
+func (c *Code) drawPatterns(version int) {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	for _, p := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := p[0]+dx, p[1]+dy
+				if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+					continue
+				}
+				d := abs(dx)
+				if abs(dy) > d {
+					d = abs(dy)
+				}
+				c.set(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+	pos := alignments[version-1]
+	for i, ax := range pos {
+		for j, ay := range pos {
+			if (i == 0 && j == 0) || (i == 0 && j == len(pos)-1) || (i == len(pos)-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					d := abs(dx)
+					if abs(dy) > d {
+						d = abs(dy)
+					}
+					c.set(ax+dx, ay+dy, d != 1)
+				}
+			}
+		}
+	}
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1F25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			black := bits>>uint(i)&1 == 1
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, black)
+			c.set(b, a, black)
+		}
+	}
+}
+
+// drawFormat 格式信息(纠错等级与掩码)
+func (c *Code) drawFormat(level Level, mask int) {
+	data := levelBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// drawCodewords 按之字形路径填充数据位，剩余位为0
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if c.fixed[y*c.Size+x] {
+					continue
+				}
+				if i < len(codewords)*8 {
+					c.modules[y*c.Size+x] = codewords[i/8]>>uint(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask 对数据区域异或掩码，调用两次即还原
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.fixed[y*c.Size+x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				c.modules[y*c.Size+x] = !c.modules[y*c.Size+x]
+			}
+		}
+	}
+}
+
+// penalty 掩码评分，越低越好
+func (c *Code) penalty() int {
+	n, score, dark := c.Size, 0, 0
+	line := func(get func(i int) bool) {
+		run := 1
+		for i := 1; i <= n; i++ {
+			if i < n && get(i) == get(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+			run = 1
+		}
+		for i := 0; i+7 <= n; i++ {
+			if get(i) && !get(i+1) && get(i+2) && get(i+3) && get(i+4) && !get(i+5) && get(i+6) {
+				before, after := true, true
+				for k := 1; k <= 4; k++ {
+					before = before && !get(i-k)
+					after = after && !get(i+6+k)
+				}
+				if before || after {
+					score += 40
+				}
+			}
+		}
+	}
+	for y := 0; y < n; y++ {
+		line(func(i int) bool { return c.Black(i, y) })
+	}
+	for x := 0; x < n; x++ {
+		line(func(i int) bool { return c.Black(x, i) })
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			b := c.Black(x, y)
+			if b {
+				dark++
+			}
+			if x < n-1 && y < n-1 && b == c.Black(x+1, y) && b == c.Black(x, y+1) && b == c.Black(x+1, y+1) {
+				score += 3
+			}
+		}
+	}
+	total := n * n
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return score + k*10
+}
+
+// Terminal 使用Unicode半块字符输出，每个字符表示上下两个模块，四周保留2个模块的空白
+// 浅色模块绘制为实心块，适用于深色背景的终端
+func (c *Code) Terminal() string {
+	const quiet = 2
+	var sb strings.Builder
+	for y := -quiet; y < c.Size+quiet; y += 2 {
+		for x := -quiet; x < c.Size+quiet; x++ {
+			top, bottom := !c.Black(x, y), !c.Black(x, y+1)
+			if y+1 >= c.Size+quiet {
+				bottom = false
+			}
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// ANSI 使用ANSI背景色输出，每个模块占两个字符宽，深色与浅色背景的终端均可扫描
+func (c *Code) ANSI() string {
+	const (
+		quiet = 2
+		light = "\x1b[47m  "
+		dark  = "\x1b[40m  "
+		reset = "\x1b[0m\n"
+	)
+	var sb strings.Builder
+	for y := -quiet; y < c.Size+quiet; y++ {
+		for x := -quiet; x < c.Size+quiet; x++ {
+			if c.Black(x, y) {
+				sb.WriteString(dark)
+			} else {
+				sb.WriteString(light)
+			}
+		}
+		sb.WriteString(reset)
+	}
+	return sb.String()
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,110 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	// 与qrcode.js生成结果一致
+	want := []string{
+		"#######....#..#######",
+		"#.....#.##.#..#.....#",
+		"#.###.#.......#.###.#",
+		"#.###.#...###.#.###.#",
+		"#.###.#.#.###.#.###.#",
+		"#.....#..###..#.....#",
+		"#######.#.#.#.#######",
+		".........#...........",
+		"#.#.#.#..##.#...#..#.",
+		"##.###..#.##.#.#.#..#",
+		"#..##.#...##.###..#.#",
+		"######.....###.##..##",
+		".#.#..#....#.###.#..#",
+		"........#.....##.#.##",
+		"#######..##.#...#.###",
+		"#.....#..##...#.#..##",
+		"#.###.#.###.#.#.#..##",
+		"#.###.#...##.#..##.#.",
+		"#.###.#.#.##.######.#",
+		"#.....#....###..#..#.",
+		"#######.##.#.##.##.##",
+	}
+	c, err := Encode("b0pass", M)
+	if err != nil || c.Size != 21 {
+		t.Fatal(c, err)
+	}
+	for y, row := range want {
+		for x, ch := range row {
+			if c.Black(x, y) != (ch == '#') {
+				t.Fatalf("module %d,%d mismatch", x, y)
+			}
+		}
+	}
+	if lines := strings.Split(strings.TrimSuffix(c.Terminal(), "\n"), "\n"); len(lines) != 13 {
+		t.Error("terminal lines", len(lines))
+	}
+	if lines := strings.Split(strings.TrimSuffix(c.ANSI(), "\n"), "\n"); len(lines) != 25 || strings.Count(lines[2], "  ") != 25 {
+		t.Error("ansi lines", len(lines))
+	}
+}
+
+func TestVersions(t *testing.T) {
+	url := strings.Repeat("http://192.168.100.200:8899/", 10)
+	for n, size := range map[int]int{14: 21, 26: 25, 100: 41, 213: 57} {
+		c, err := Encode(url[:n], M)
+		if err != nil || c.Size != size {
+			t.Error(n, size, c, err)
+		}
+	}
+	if _, err := Encode(url+url, H); err != ErrTooLong {
+		t.Error(err)
+	}
+}
+
+func TestImage(t *testing.T) {
+	c, _ := Encode("b0pass", M)
+	img := c.Image(4)
+	if n := (21 + 2*Quiet) * 4; img.Bounds().Dx() != n || img.Bounds().Dy() != n {
+		t.Fatal(img.Bounds())
+	}
+	// 静区为白色，左上角定位图形为黑色
+	if img.ColorIndexAt(0, 0) != 0 || img.ColorIndexAt(Quiet*4, Quiet*4) != 1 || img.ColorIndexAt(Quiet*4+3, Quiet*4+3) != 1 {
+		t.Error("modules")
+	}
+	var buf bytes.Buffer
+	if err := c.PNG(&buf, 4); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := png.Decode(&buf); err != nil || decoded.Bounds() != img.Bounds() {
+		t.Error(err)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	c, _ := Encode("b0pass", M)
+	svg := c.SVG(200)
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 29 29" width="200" height="200"`) || !strings.HasSuffix(svg, "</svg>") {
+		t.Error(svg)
+	}
+	// 第一行的定位图形合并为一个7个模块宽的矩形
+	if !strings.Contains(svg, "M4 4h7v1h-7z") {
+		t.Error("run")
+	}
+	if !strings.Contains(c.SVG(0), `viewBox="0 0 29 29" shape-rendering`) {
+		t.Error("size 0")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]Level{"": M, "l": L, "M": M, " q ": Q, "H": H} {
+		if l, err := ParseLevel(s); err != nil || l != want {
+			t.Error(s, l, err)
+		}
+	}
+	if _, err := ParseLevel("X"); err == nil {
+		t.Error("invalid")
+	}
+}
@@ -0,0 +1,114 @@
+package quota
+
+import (
+	"errors"
+	"strconv"
+)
+
+// 上传配额: 全局与按设备限制通过上传保存的文件总大小，上传前按声明的大小与实时磁盘空间预先检查
+// 用量按元数据中记录的上传者(owner字段)与大小统计，文件删除后元数据随之删除，配额自动释放
+
+// FieldOwner 元数据中记录上传者(设备标识或IP)的字段
+const FieldOwner = "owner"
+
+// 超出限制时的错误
+var (
+	ErrDevice = errors.New("device upload quota exceeded")
+	ErrTotal  = errors.New("upload quota exceeded")
+	ErrDisk   = errors.New("not enough disk space")
+)
+
+// Limits 配额(字节)，0为不限
+type Limits struct {
+	Total   int64            // 全部上传文件的总大小
+	Device  int64            // 每个设备的默认配额
+	Devices map[string]int64 // 单独指定的设备配额，key为设备标识(device:<id>)或IP
+}
+
+// DeviceLimit 设备的配额
+func (l Limits) DeviceLimit(owner string) int64 {
+	if n, ok := l.Devices[owner]; ok {
+		return n
+	}
+	return l.Device
+}
+
+// Usage 已使用的字节数
+type Usage struct {
+	Total  int64
+	Device int64
+}
+
+// Tally 遍历元数据统计全部上传者与owner的用量
+func Tally(each func(func(key string, fields map[string]string)), owner string) Usage {
+	var u Usage
+	each(func(_ string, fields map[string]string) {
+		who := fields[FieldOwner]
+		if who == "" {
+			return
+		}
+		size, _ := strconv.ParseInt(fields["size"], 10, 64)
+		u.Total += size
+		if who == owner {
+			u.Device += size
+		}
+	})
+	return u
+}
+
+// Space 一项限制的使用情况，不限时Limit为0、Remaining为-1
+type Space struct {
+	Limit     int64 `json:"limit"`
+	Used      int64 `json:"used"`
+	Remaining int64 `json:"remaining"`
+}
+
+func space(limit, used int64) Space {
+	s := Space{Limit: limit, Used: used, Remaining: -1}
+	if limit > 0 {
+		s.Remaining = limit - used
+		if s.Remaining < 0 {
+			s.Remaining = 0
+		}
+	}
+	return s
+}
+
+// Report 上传前可用空间，Remaining为各项中最小的剩余量
+type Report struct {
+	Owner     string `json:"owner"`
+	Device    Space  `json:"device"`
+	Total     Space  `json:"total"`
+	Disk      Space  `json:"disk"`
+	Remaining int64  `json:"remaining"`
+}
+
+// Compute 计算owner的可用空间，disk为共享目录所在磁盘的容量、已用与可写入的空间(未知时Remaining为-1)
+func Compute(l Limits, u Usage, owner string, disk Space) Report {
+	r := Report{
+		Owner:  owner,
+		Device: space(l.DeviceLimit(owner), u.Device),
+		Total:  space(l.Total, u.Total),
+		Disk:   disk,
+	}
+	r.Remaining = -1
+	for _, s := range []Space{r.Device, r.Total, r.Disk} {
+		if s.Remaining >= 0 && (r.Remaining < 0 || s.Remaining < r.Remaining) {
+			r.Remaining = s.Remaining
+		}
+	}
+	return r
+}
+
+// Check 写入size字节后是否超出限制
+func (r Report) Check(size int64) error {
+	switch {
+	case r.Device.Remaining >= 0 && size > r.Device.Remaining:
+		return ErrDevice
+	case r.Total.Remaining >= 0 && size > r.Total.Remaining:
+		return ErrTotal
+	case r.Disk.Remaining >= 0 && size > r.Disk.Remaining:
+		return ErrDisk
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package quota
+
+import (
+	"testing"
+)
+
+func TestTally(t *testing.T) {
+	data := map[string]map[string]string{
+		"a.txt":     {"size": "100", FieldOwner: "device:a"},
+		"b.txt":     {"size": "50", FieldOwner: "10.0.0.2"},
+		"old.txt":   {"size": "1000"},
+		"photos":    {"icon": "📷"},
+		"sub/c.txt": {"size": "7", FieldOwner: "device:a"},
+	}
+	each := func(fn func(string, map[string]string)) {
+		for k, m := range data {
+			fn(k, m)
+		}
+	}
+	if u := Tally(each, "device:a"); u.Total != 157 || u.Device != 107 {
+		t.Errorf("%+v", u)
+	}
+	if u := Tally(each, "device:x"); u.Total != 157 || u.Device != 0 {
+		t.Errorf("%+v", u)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	disk := Space{Limit: 10000, Used: 5000, Remaining: 5000}
+	l := Limits{Total: 1000, Device: 300, Devices: map[string]int64{"device:big": 0}}
+	r := Compute(l, Usage{Total: 600, Device: 200}, "device:a", disk)
+	if r.Device.Remaining != 100 || r.Total.Remaining != 400 || r.Remaining != 100 {
+		t.Errorf("%+v", r)
+	}
+	if err := r.Check(100); err != nil {
+		t.Error(err)
+	}
+	if err := r.Check(101); err != ErrDevice {
+		t.Error("device", err)
+	}
+	// 单独指定为不限的设备只受全局配额限制
+	r = Compute(l, Usage{Total: 600, Device: 500}, "device:big", disk)
+	if r.Device.Remaining != -1 || r.Remaining != 400 || r.Check(401) != ErrTotal {
+		t.Errorf("%+v", r)
+	}
+	r = Compute(Limits{}, Usage{Total: 600}, "10.0.0.2", Space{Limit: 10000, Used: 9950, Remaining: 50})
+	if r.Remaining != 50 || r.Check(51) != ErrDisk || r.Check(50) != nil {
+		t.Errorf("%+v", r)
+	}
+	// 已超出时剩余为0
+	if r := Compute(l, Usage{Total: 1200}, "x", disk); r.Total.Remaining != 0 || r.Check(1) != ErrTotal {
+		t.Errorf("%+v", r)
+	}
+}
@@ -0,0 +1,50 @@
+package receipt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// Mail 邮件发送配置
+type Mail struct {
+	Addr     string // SMTP服务器host:port
+	User     string // 为空时不认证
+	Password string
+	From     string
+}
+
+// Send 将回执发送到邮箱to，正文为摘要与回执JSON
+func (m Mail) Send(to string, rc Receipt) error {
+	if m.Addr == "" {
+		return fmt.Errorf("receipt: smtp server not configured")
+	}
+	if strings.ContainsAny(to, "\r\n") || !strings.Contains(to, "@") {
+		return fmt.Errorf("receipt: invalid email address %q", to)
+	}
+	var auth smtp.Auth
+	if m.User != "" {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		auth = smtp.PlainAuth("", m.User, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, m.message(to, rc))
+}
+
+// message 邮件内容
+func (m Mail) message(to string, rc Receipt) []byte {
+	b, _ := json.MarshalIndent(rc, "", "  ")
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\n", m.From, to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "上传回执: "+rc.File))
+	buf.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&buf, "文件: %s\r\n大小: %d\r\n时间: %s\r\n服务端: %s (%s)\r\n\r\n",
+		rc.File, rc.Size, rc.Time.Format("2006-01-02 15:04:05 MST"), rc.Server.Name, rc.Server.Key)
+	buf.WriteString("回执(可通过 /api/receipt/verify 验证):\r\n")
+	buf.Write(bytes.Replace(b, []byte("\n"), []byte("\r\n"), -1))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
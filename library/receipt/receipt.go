@@ -0,0 +1,162 @@
+package receipt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 上传回执: 由服务端密钥签名，发送方可凭回执证明送达的文件内容
+
+// ErrSignature 签名无效或回执被修改
+var ErrSignature = errors.New("receipt: invalid signature")
+
+// Server 服务端身份
+type Server struct {
+	Name string `json:"name"`
+	// Key 签名公钥的SHA-256指纹
+	Key string `json:"key"`
+}
+
+// Receipt 上传回执
+type Receipt struct {
+	File   string            `json:"file"`
+	Size   int64             `json:"size"`
+	Hashes map[string]string `json:"hashes"`
+	Time   time.Time         `json:"time"`
+	Client string            `json:"client,omitempty"`
+	Server Server            `json:"server"`
+	// Signature 除本字段外的JSON的ECDSA(P-256, SHA-256)签名，base64编码
+	Signature string `json:"signature,omitempty"`
+}
+
+// payload 签名内容
+func (rc Receipt) payload() []byte {
+	rc.Signature = ""
+	b, _ := json.Marshal(rc)
+	return b
+}
+
+// Signer 回执签名密钥
+type Signer struct {
+	key  *ecdsa.PrivateKey
+	name string
+}
+
+// LoadSigner 读取PEM格式的签名私钥，文件不存在时生成并保存
+func LoadSigner(file, name string) (*Signer, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return generate(file, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("receipt: no PEM data in " + file)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{key: key, name: name}, nil
+}
+
+// generate 生成新的签名私钥
+func generate(file, name string) (*Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+	b := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		return nil, err
+	}
+	return &Signer{key: key, name: name}, nil
+}
+
+// Server 服务端身份
+func (s *Signer) Server() Server {
+	return Server{Name: s.name, Key: Fingerprint(&s.key.PublicKey)}
+}
+
+// PublicKey PEM格式的公钥，用于离线验证回执
+func (s *Signer) PublicKey() []byte {
+	der, _ := x509.MarshalPKIXPublicKey(&s.key.PublicKey)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// Sign 填写服务端身份并签名，时间取整到秒(UTC)以便JSON往返后签名内容不变
+func (s *Signer) Sign(rc Receipt) (Receipt, error) {
+	rc.Time = rc.Time.UTC().Truncate(time.Second)
+	rc.Server = s.Server()
+	sum := sha256.Sum256(rc.payload())
+	r, ss, err := ecdsa.Sign(rand.Reader, s.key, sum[:])
+	if err != nil {
+		return rc, err
+	}
+	sig, err := asn1.Marshal(ecdsaSignature{r, ss})
+	if err != nil {
+		return rc, err
+	}
+	rc.Signature = base64.StdEncoding.EncodeToString(sig)
+	return rc, nil
+}
+
+// Verify 验证回执由本服务端签名且未被修改
+func (s *Signer) Verify(rc Receipt) error {
+	if rc.Server.Key != Fingerprint(&s.key.PublicKey) {
+		return ErrSignature
+	}
+	return Verify(rc, &s.key.PublicKey)
+}
+
+// Verify 用公钥验证回执签名
+func Verify(rc Receipt, pub *ecdsa.PublicKey) error {
+	b, err := base64.StdEncoding.DecodeString(rc.Signature)
+	if err != nil {
+		return ErrSignature
+	}
+	var sig ecdsaSignature
+	if rest, err := asn1.Unmarshal(b, &sig); err != nil || len(rest) > 0 || sig.R == nil || sig.S == nil {
+		return ErrSignature
+	}
+	sum := sha256.Sum256(rc.payload())
+	if !ecdsa.Verify(pub, sum[:], sig.R, sig.S) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// ecdsaSignature ASN.1编码的签名
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// Fingerprint 公钥指纹(DER编码的SHA-256)
+func Fingerprint(pub *ecdsa.PublicKey) string {
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
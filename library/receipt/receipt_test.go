@@ -0,0 +1,45 @@
+package receipt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "receipt")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "receipt.pem")
+	s, err := LoadSigner(file, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := s.Sign(Receipt{File: "a.txt", Size: 3, Hashes: map[string]string{"sha256": "abc"}, Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 重新读取密钥，经JSON往返后仍可验证
+	s, err = LoadSigner(file, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(rc)
+	var got Receipt
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(got); err != nil {
+		t.Fatal(err)
+	}
+	got.Size = 4
+	if err := s.Verify(got); err != ErrSignature {
+		t.Errorf("tampered receipt: %v", err)
+	}
+	if !strings.Contains(string(s.PublicKey()), "PUBLIC KEY") {
+		t.Error("bad public key")
+	}
+}
@@ -0,0 +1,340 @@
+package relay
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 设备直传: 发送方上传的数据经服务端直接转给接收方，不写入磁盘，双方须同时在线；
+// 暂存(Spool)模式先写入暂存文件，接收方可边传边下或稍后下载，下载完成后删除暂存文件
+
+var (
+	ErrNoEntry = errors.New("relay not found or expired")
+	ErrBusy    = errors.New("relay is already in progress")
+	ErrAborted = errors.New("peer disconnected before the transfer completed")
+)
+
+// Relay 一次直传
+type Relay struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"` // 未知时为-1
+	From    string    `json:"from"` // 发送方设备标识
+	To      string    `json:"to"`   // 接收方设备标识，为空时任何拿到链接的设备都可以接收
+	Spool   bool      `json:"spool"`
+	Created time.Time `json:"created"`
+}
+
+// receiver 直传模式下等待数据的接收方
+type receiver struct {
+	open func() io.Writer
+	done chan result
+}
+
+type result struct {
+	n   int64
+	err error
+}
+
+type session struct {
+	Relay
+	sending   bool
+	receiving bool
+	recv      chan *receiver
+
+	// 暂存模式
+	file    string
+	written int64
+	done    bool
+	err     error
+	changed chan struct{}
+}
+
+// Hub 进行中的直传
+type Hub struct {
+	// TTL 创建后等待对方连接(暂存模式为等待下载)的时间
+	TTL time.Duration
+
+	dir      string
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// New 创建直传中心，dir为暂存文件目录
+func New(dir string, ttl time.Duration) *Hub {
+	return &Hub{TTL: ttl, dir: dir, sessions: make(map[string]*session)}
+}
+
+// Create 创建直传，返回带ID的Relay
+func (h *Hub) Create(r Relay) Relay {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	r.ID, r.Created = hex.EncodeToString(b), time.Now()
+	s := &session{Relay: r, recv: make(chan *receiver), changed: make(chan struct{})}
+	if r.Spool {
+		s.file = filepath.Join(h.dir, r.ID+".relay")
+	}
+	h.mu.Lock()
+	h.sessions[r.ID] = s
+	h.mu.Unlock()
+	return r
+}
+
+// Get 直传信息
+func (h *Hub) Get(id string) (Relay, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sessions[id]
+	if !ok {
+		return Relay{}, false
+	}
+	return s.Relay, true
+}
+
+// Pending 发给该设备、还没有开始接收的直传，按创建时间排序
+func (h *Hub) Pending(to string) []Relay {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var list []Relay
+	for _, s := range h.sessions {
+		if s.To == to && !s.receiving {
+			list = append(list, s.Relay)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+// Cancel 取消直传并删除暂存文件
+func (h *Hub) Cancel(id string) {
+	h.mu.Lock()
+	s, ok := h.sessions[id]
+	delete(h.sessions, id)
+	h.mu.Unlock()
+	if ok && s.file != "" {
+		_ = os.Remove(s.file)
+	}
+}
+
+// Expire 取消超过TTL仍未开始(暂存模式为未下载完成且不在传输中)的直传
+func (h *Hub) Expire(now time.Time) []Relay {
+	h.mu.Lock()
+	var expired []Relay
+	for id, s := range h.sessions {
+		if !s.sending && !s.receiving && now.Sub(s.Created) > h.TTL {
+			expired = append(expired, s.Relay)
+			delete(h.sessions, id)
+			if s.file != "" {
+				_ = os.Remove(s.file)
+			}
+		}
+	}
+	h.mu.Unlock()
+	return expired
+}
+
+// acquire 标记发送或接收开始，同一方向同时只允许一个连接
+func (h *Hub) acquire(id string, send bool) (*session, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sessions[id]
+	if !ok {
+		return nil, ErrNoEntry
+	}
+	if (send && (s.sending || s.done)) || (!send && s.receiving) {
+		return nil, ErrBusy
+	}
+	if send {
+		s.sending = true
+	} else {
+		s.receiving = true
+	}
+	return s, nil
+}
+
+// release 发送或接收结束，finished为直传已完成(或已无法继续)时移除
+func (h *Hub) release(s *session, send, finished bool) {
+	h.mu.Lock()
+	if send {
+		s.sending = false
+	} else {
+		s.receiving = false
+	}
+	if finished {
+		delete(h.sessions, s.ID)
+	}
+	h.mu.Unlock()
+	if finished && s.file != "" {
+		_ = os.Remove(s.file)
+	}
+}
+
+// Send 发送方上传数据: 直传模式等待接收方连接后边读边转发，暂存模式写入暂存文件
+func (h *Hub) Send(ctx context.Context, id string, body io.Reader) (int64, error) {
+	s, err := h.acquire(id, true)
+	if err != nil {
+		return 0, err
+	}
+	if s.Spool {
+		n, err := h.spool(s, body)
+		h.release(s, true, err != nil)
+		return n, err
+	}
+	var rc *receiver
+	select {
+	case rc = <-s.recv:
+	case <-ctx.Done():
+		h.release(s, true, false)
+		return 0, ctx.Err()
+	}
+	n, err := io.Copy(rc.open(), body)
+	if err == nil && s.Size >= 0 && n != s.Size {
+		err = ErrAborted
+	}
+	rc.done <- result{n, err}
+	h.release(s, true, true)
+	return n, err
+}
+
+// Receive 接收方下载: 发送方开始传输时调用open写出响应头并取得写入位置，返回转发的字节数
+// 直传模式在发送方连接前等待；数据不完整时返回错误
+func (h *Hub) Receive(ctx context.Context, id string, open func() io.Writer) (int64, error) {
+	s, err := h.acquire(id, false)
+	if err != nil {
+		return 0, err
+	}
+	if s.Spool {
+		n, err := h.unspool(ctx, s, open)
+		h.release(s, false, err == nil)
+		return n, err
+	}
+	rc := &receiver{open: open, done: make(chan result, 1)}
+	select {
+	case s.recv <- rc:
+	case <-ctx.Done():
+		h.release(s, false, false)
+		return 0, ctx.Err()
+	}
+	res := <-rc.done
+	h.release(s, false, true)
+	return res.n, res.err
+}
+
+// spool 写入暂存文件，每次写入后通知等待的接收方
+func (h *Hub) spool(s *session, body io.Reader) (int64, error) {
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(s.file)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	buf := make([]byte, 256<<10)
+	var n int64
+	for {
+		m, rerr := body.Read(buf)
+		if m > 0 {
+			if _, werr := f.Write(buf[:m]); werr != nil {
+				rerr = werr
+			} else {
+				n += int64(m)
+				h.update(s, n, false, nil)
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			h.update(s, n, true, rerr)
+			return n, rerr
+		}
+	}
+	if s.Size >= 0 && n != s.Size {
+		h.update(s, n, true, ErrAborted)
+		return n, ErrAborted
+	}
+	h.update(s, n, true, nil)
+	return n, nil
+}
+
+// update 更新暂存进度并唤醒等待的接收方
+func (h *Hub) update(s *session, written int64, done bool, err error) {
+	h.mu.Lock()
+	s.written, s.done, s.err = written, done, err
+	close(s.changed)
+	s.changed = make(chan struct{})
+	h.mu.Unlock()
+}
+
+// state 暂存进度
+func (h *Hub) state(s *session) (written int64, done bool, err error, changed chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return s.written, s.done, s.err, s.changed
+}
+
+// unspool 从暂存文件读取，追上发送方时等待新数据
+func (h *Hub) unspool(ctx context.Context, s *session, open func() io.Writer) (int64, error) {
+	var f *os.File
+	var w io.Writer
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+	buf := make([]byte, 256<<10)
+	var n int64
+	for {
+		written, done, err, changed := h.state(s)
+		if err != nil {
+			return n, err
+		}
+		if f == nil && (written > 0 || done) {
+			if f, err = os.Open(s.file); err != nil {
+				return n, err
+			}
+			w = open()
+		}
+		for n < written {
+			m, rerr := f.Read(buf[:min64(int64(len(buf)), written-n)])
+			if m > 0 {
+				if _, werr := w.Write(buf[:m]); werr != nil {
+					return n, werr
+				}
+				n += int64(m)
+			}
+			if rerr == io.EOF && m == 0 {
+				break
+			}
+			if rerr != nil && rerr != io.EOF {
+				return n, rerr
+			}
+		}
+		if done {
+			return n, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return n, ctx.Err()
+		}
+	}
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
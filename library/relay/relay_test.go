@@ -0,0 +1,124 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDirect(t *testing.T) {
+	h := New("", time.Minute)
+	r := h.Create(Relay{Name: "a.txt", Size: 5, From: "browser:a", To: "browser:b"})
+	if list := h.Pending("browser:b"); len(list) != 1 || list[0].ID != r.ID {
+		t.Fatalf("pending %+v", list)
+	}
+	var out bytes.Buffer
+	got := make(chan error, 1)
+	go func() {
+		n, err := h.Receive(context.Background(), r.ID, func() io.Writer { return &out })
+		if n != 5 {
+			t.Errorf("received %d", n)
+		}
+		got <- err
+	}()
+	// 接收方先连接，发送方随后开始
+	time.Sleep(20 * time.Millisecond)
+	if _, err := h.Receive(context.Background(), r.ID, nil); err != ErrBusy {
+		t.Error("second receiver", err)
+	}
+	if n, err := h.Send(context.Background(), r.ID, strings.NewReader("hello")); n != 5 || err != nil {
+		t.Fatal(n, err)
+	}
+	if err := <-got; err != nil || out.String() != "hello" {
+		t.Fatalf("%q %v", out.String(), err)
+	}
+	if _, ok := h.Get(r.ID); ok {
+		t.Error("finished relay still present")
+	}
+
+	// 发送方提前断开
+	r = h.Create(Relay{Name: "b.txt", Size: 10})
+	go func() {
+		_, err := h.Receive(context.Background(), r.ID, func() io.Writer { return ioutil.Discard })
+		got <- err
+	}()
+	if _, err := h.Send(context.Background(), r.ID, strings.NewReader("short")); err != ErrAborted {
+		t.Error("send", err)
+	}
+	if err := <-got; err != ErrAborted {
+		t.Error("receive", err)
+	}
+
+	// 无人接收时发送方超时
+	r = h.Create(Relay{Name: "c.txt", Size: -1})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := h.Send(ctx, r.ID, strings.NewReader("x")); err != context.DeadlineExceeded {
+		t.Error("timeout", err)
+	}
+	if _, ok := h.Get(r.ID); !ok {
+		t.Error("relay removed after sender timeout")
+	}
+	if expired := h.Expire(time.Now().Add(2 * time.Minute)); len(expired) != 1 || expired[0].ID != r.ID {
+		t.Errorf("expire %+v", expired)
+	}
+}
+
+func TestSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	h := New(dir, time.Minute)
+	r := h.Create(Relay{Name: "a.bin", Size: -1, Spool: true})
+	pr, pw := io.Pipe()
+	sent := make(chan error, 1)
+	go func() {
+		_, err := h.Send(context.Background(), r.ID, pr)
+		sent <- err
+	}()
+	_, _ = pw.Write([]byte("part1-"))
+	var out bytes.Buffer
+	got := make(chan error, 1)
+	go func() {
+		_, err := h.Receive(context.Background(), r.ID, func() io.Writer { return &out })
+		got <- err
+	}()
+	// 接收方追上后等待新数据
+	time.Sleep(20 * time.Millisecond)
+	_, _ = pw.Write([]byte("part2"))
+	_ = pw.Close()
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-got; err != nil || out.String() != "part1-part2" {
+		t.Fatalf("%q %v", out.String(), err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, r.ID+".relay")); !os.IsNotExist(err) {
+		t.Error("spool file not removed", err)
+	}
+
+	// 发送完成后稍后下载
+	r = h.Create(Relay{Name: "b.bin", Size: 3, Spool: true})
+	if _, err := h.Send(context.Background(), r.ID, strings.NewReader("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Send(context.Background(), r.ID, strings.NewReader("abc")); err != ErrBusy {
+		t.Error("resend", err)
+	}
+	out.Reset()
+	if n, err := h.Receive(context.Background(), r.ID, func() io.Writer { return &out }); n != 3 || err != nil || out.String() != "abc" {
+		t.Errorf("%d %q %v", n, out.String(), err)
+	}
+	if _, err := h.Receive(context.Background(), r.ID, nil); err != ErrNoEntry {
+		t.Error("received twice", err)
+	}
+}
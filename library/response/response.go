@@ -1,20 +1,28 @@
 package response
 
 import (
+	"b0pass/library/i18n"
 	"github.com/gogf/gf/frame/g"
 	"github.com/gogf/gf/net/ghttp"
+	"net/http"
 )
 
+// Locale 请求的语言，返回信息按该语言翻译；为nil时不翻译
+var Locale func(r *http.Request) string
+
 // JSON 标准返回结果数据结构封装。
 // 返回固定数据结构的JSON:
 // err:  错误码(0:成功, 1:失败, >1:错误码);
-// msg:  请求结果信息;
+// msg:  请求结果信息(按请求的语言翻译);
 // data: 请求结果,根据不同接口返回结果的数据结构不同;
 func JSON(r *ghttp.Request, err int, msg string, data ...interface{}) {
 	responseData := interface{}(nil)
 	if len(data) > 0 {
 		responseData = data[0]
 	}
+	if Locale != nil {
+		msg = i18n.T(Locale(r.Request), msg)
+	}
 	_ = r.Response.WriteJson(g.Map{
 		"err":  err,
 		"msg":  msg,
@@ -22,3 +30,10 @@ func JSON(r *ghttp.Request, err int, msg string, data ...interface{}) {
 	})
 	r.Exit()
 }
+
+// Error 返回错误结果，同时设置HTTP状态码
+// 便于uppy、layui等通用上传组件按状态码识别失败
+func Error(r *ghttp.Request, status int, err int, msg string, data ...interface{}) {
+	r.Response.WriteHeader(status)
+	JSON(r, err, msg, data...)
+}
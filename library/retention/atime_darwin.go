@@ -0,0 +1,15 @@
+package retention
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime 文件的访问时间
+func accessTime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atimespec.Sec, st.Atimespec.Nsec)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package retention
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime 不支持的平台只按修改时间计算
+func accessTime(info os.FileInfo) time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,15 @@
+package retention
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime 文件的访问时间
+func accessTime(info os.FileInfo) time.Time {
+	if d, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, d.LastAccessTime.Nanoseconds())
+	}
+	return time.Time{}
+}
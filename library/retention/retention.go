@@ -0,0 +1,135 @@
+package retention
+
+import (
+	"b0pass/library/humanize"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 自动清理: 目录中超过保留时间未使用的文件，或总大小超过上限时最久未使用的文件(LRU)，
+// 由后台定期移入回收站或直接删除，避免收集目录等无限增长
+
+// 清理方式
+const (
+	ActionTrash  = "trash"
+	ActionDelete = "delete"
+)
+
+// Rule 一个目录的保留策略
+type Rule struct {
+	Dir     string        // 共享目录下的路径，如 inbox，多根目录时以根目录名开头
+	MaxAge  time.Duration // 超过该时间未使用的文件被清理，0为不按时间清理
+	MaxSize int64         // 目录总大小上限(字节)，超过时从最久未使用的文件开始清理，0为不限
+	Action  string        // trash(默认)或delete
+}
+
+// Parse 解析[[retention]]配置
+func Parse(items []interface{}) ([]Rule, error) {
+	var rules []Rule
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("retention: invalid item %v", item)
+		}
+		r := Rule{Action: ActionTrash}
+		r.Dir, _ = m["dir"].(string)
+		if strings.TrimSpace(r.Dir) == "" {
+			return nil, fmt.Errorf("retention: dir is required, use \"/\" for a whole root")
+		}
+		if v, ok := m["days"]; ok {
+			var days float64
+			if _, err := fmt.Sscan(fmt.Sprint(v), &days); err != nil || days < 0 {
+				return nil, fmt.Errorf("retention: invalid days %v for %s", v, r.Dir)
+			}
+			r.MaxAge = time.Duration(days * float64(24*time.Hour))
+		}
+		if v, ok := m["maxsize"]; ok {
+			n, err := humanize.ParseSize(fmt.Sprint(v))
+			if err != nil {
+				return nil, fmt.Errorf("retention: invalid maxsize %v for %s", v, r.Dir)
+			}
+			r.MaxSize = n
+		}
+		if v, ok := m["action"].(string); ok && v != "" {
+			if v != ActionTrash && v != ActionDelete {
+				return nil, fmt.Errorf("retention: action must be %s or %s", ActionTrash, ActionDelete)
+			}
+			r.Action = v
+		}
+		if r.MaxAge == 0 && r.MaxSize == 0 {
+			return nil, fmt.Errorf("retention: days or maxsize is required for %s", r.Dir)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// File 待清理的文件
+type File struct {
+	Path string
+	Size int64
+	Used time.Time // 最后使用时间: 修改时间与访问时间中较晚的
+}
+
+// Select 选出dir中按规则应清理的文件(跳过隐藏的文件与目录)，先按保留时间，
+// 剩余文件的总大小仍超过MaxSize时从最久未使用的开始
+func (r Rule) Select(dir string, now time.Time) ([]File, error) {
+	var files []File
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if file == dir {
+				return err
+			}
+			return nil
+		}
+		if file != dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, File{Path: file, Size: info.Size(), Used: lastUsed(info)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 最久未使用的在前
+	sort.Slice(files, func(i, j int) bool { return files[i].Used.Before(files[j].Used) })
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	var selected []File
+	for _, f := range files {
+		if (r.MaxAge > 0 && now.Sub(f.Used) > r.MaxAge) || (r.MaxSize > 0 && total > r.MaxSize) {
+			selected = append(selected, f)
+			total -= f.Size
+		}
+	}
+	return selected, nil
+}
+
+// lastUsed 修改时间与访问时间中较晚的，文件系统不记录访问时间(noatime)时为修改时间
+func lastUsed(info os.FileInfo) time.Time {
+	if t := accessTime(info); t.After(info.ModTime()) {
+		return t
+	}
+	return info.ModTime()
+}
+
+// Prune 清理后删除file所在的空目录，逐级向上直到root(不含root)
+func Prune(root, file string) {
+	root = filepath.Clean(root)
+	for dir := filepath.Dir(file); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
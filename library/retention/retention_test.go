@@ -0,0 +1,81 @@
+package retention
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	rules, err := Parse([]interface{}{
+		map[string]interface{}{"dir": "inbox", "days": int64(7)},
+		map[string]interface{}{"dir": "/", "maxsize": "1.5G", "days": 0.5, "action": "delete"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules[0].MaxAge != 7*24*time.Hour || rules[0].Action != ActionTrash {
+		t.Errorf("%+v", rules[0])
+	}
+	if rules[1].MaxSize != 3<<29 || rules[1].MaxAge != 12*time.Hour || rules[1].Action != ActionDelete {
+		t.Errorf("%+v", rules[1])
+	}
+	for _, bad := range []map[string]interface{}{
+		{"days": 1},
+		{"dir": "a"},
+		{"dir": "a", "days": "x"},
+		{"dir": "a", "maxsize": "lots"},
+		{"dir": "a", "days": 1, "action": "shred"},
+	} {
+		if _, err := Parse([]interface{}{bad}); err == nil {
+			t.Error("accepted", bad)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retention")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	now := time.Now()
+	write := func(name string, size int, age time.Duration) string {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(file), 0755)
+		_ = ioutil.WriteFile(file, make([]byte, size), 0644)
+		_ = os.Chtimes(file, now.Add(-age), now.Add(-age))
+		return file
+	}
+	old := write("sub/old.bin", 10, 10*24*time.Hour)
+	mid := write("mid.bin", 10, 3*24*time.Hour)
+	write("new.bin", 10, time.Hour)
+	write(".thumbs/x.jpg", 10, 30*24*time.Hour)
+
+	files, err := Rule{MaxAge: 7 * 24 * time.Hour}.Select(dir, now)
+	if err != nil || len(files) != 1 || files[0].Path != old {
+		t.Fatal(files, err)
+	}
+	// 总大小上限: 从最久未使用的开始
+	files, _ = Rule{MaxSize: 15}.Select(dir, now)
+	if len(files) != 2 || files[0].Path != old || files[1].Path != mid {
+		t.Error("size", files)
+	}
+	if files, _ := (Rule{MaxSize: 100}).Select(dir, now); len(files) != 0 {
+		t.Error("under limit", files)
+	}
+	if _, err := (Rule{MaxSize: 1}).Select(filepath.Join(dir, "missing"), now); err == nil {
+		t.Error("missing dir")
+	}
+
+	_ = os.Remove(old)
+	Prune(dir, old)
+	if _, err := os.Stat(filepath.Join(dir, "sub")); !os.IsNotExist(err) {
+		t.Error("empty dir not pruned")
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Error("root pruned")
+	}
+}
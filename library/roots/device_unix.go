@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package roots
+
+import (
+	"os"
+	"syscall"
+)
+
+// device 文件所在的设备号
+func device(info os.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package roots
+
+import "os"
+
+// device Windows上不区分挂载点
+func device(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
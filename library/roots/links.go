@@ -0,0 +1,169 @@
+package roots
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 符号链接与挂载点: 共享目录中的符号链接按策略跟随、列为链接或隐藏；无论哪种策略，
+// 链接的目标都必须位于某个共享根目录内，指向根目录之外(如 /etc)或悬空的链接既不列出也不能访问
+
+// 符号链接策略
+const (
+	LinksFollow = "follow" // 跟随链接，与普通文件、目录一样访问
+	LinksList   = "list"   // 列为指向目标的链接，不能通过链接的路径访问
+	LinksHide   = "hide"   // 不列出也不能访问
+)
+
+// Links 全部符号链接策略
+var Links = []string{LinksFollow, LinksList, LinksHide}
+
+var (
+	// ErrOutside 路径(经符号链接)指向共享根目录之外
+	ErrOutside = errors.New("roots: path leaves the shared roots")
+	// ErrLink 按策略不通过符号链接访问
+	ErrLink = errors.New("roots: symlink is not followed")
+	// ErrMount 挂载在共享目录下的其它文件系统未共享
+	ErrMount = errors.New("roots: mount point is not shared")
+)
+
+// Policy 符号链接与挂载点策略
+type Policy struct {
+	Links  string // 符号链接策略，为空时同LinksFollow
+	Mounts bool   // 是否进入挂载在共享目录下的其它文件系统(U盘、网络盘等)
+}
+
+// ParseLinks 解析符号链接策略，为空时返回LinksFollow
+func ParseLinks(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return LinksFollow, nil
+	}
+	for _, v := range Links {
+		if v == s {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("links must be one of %s", strings.Join(Links, ", "))
+}
+
+// Real 符号链接解析后的真实路径real在根目录内对应的路径(按配置的根目录路径表示)，
+// 根目录本身经过符号链接(如macOS的 /tmp)时同样能识别；不在任何根目录下时ok为false
+func (s Set) Real(real string) (file string, ok bool) {
+	real = filepath.Clean(real)
+	for _, r := range s {
+		base := r.Path
+		if p, err := filepath.EvalSymlinks(r.Path); err == nil {
+			base = p
+		}
+		if sub, err := filepath.Rel(base, real); err == nil && sub != ".." && !strings.HasPrefix(sub, ".."+string(filepath.Separator)) {
+			return filepath.Join(r.Path, sub), true
+		}
+	}
+	return "", false
+}
+
+// Check 检查根目录下的文件路径file(由Resolve得到)途经的每一级: 符号链接按策略处理，跟随时目标须位于根目录内；
+// 不共享挂载点时拒绝进入其它文件系统。不存在的部分(如上传的目标)无需检查
+func (s Set) Check(file string, p Policy) error {
+	root, ok := s.Of(file)
+	if !ok {
+		return ErrOutside
+	}
+	rel, err := filepath.Rel(root.Path, filepath.Clean(file))
+	if err != nil || rel == "." {
+		return nil
+	}
+	cur := root.Path
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+		info, err := os.Lstat(cur)
+		if err != nil {
+			return nil
+		}
+		target, err := s.entry(cur, info, p)
+		if err != nil {
+			return err
+		}
+		if target != "" && p.Links == LinksList {
+			return ErrLink
+		}
+	}
+	return nil
+}
+
+// Entry 目录列表中的一项，info为Lstat的结果(如ioutil.ReadDir)。符号链接返回目标的信息(名称仍为链接名)，
+// LinksList时link为目标在根目录内的路径；不应列出时ok为false
+func (s Set) Entry(dir string, info os.FileInfo, p Policy) (shown os.FileInfo, link string, ok bool) {
+	file := filepath.Join(dir, info.Name())
+	target, err := s.entry(file, info, p)
+	if err != nil {
+		return nil, "", false
+	}
+	if target != "" {
+		real, err := os.Stat(file)
+		if err != nil {
+			return nil, "", false
+		}
+		info = named{real, info.Name()}
+		if p.Links == LinksList {
+			link = target
+		}
+	}
+	return info, link, true
+}
+
+// entry 检查一级路径file，为符号链接时返回目标在根目录内的路径
+func (s Set) entry(file string, info os.FileInfo, p Policy) (string, error) {
+	if info.Mode()&os.ModeSymlink == 0 {
+		return "", s.mount(file, info, p)
+	}
+	if p.Links == LinksHide {
+		return "", ErrLink
+	}
+	// 悬空的链接同样拒绝: 写入时会在链接的目标处创建文件
+	real, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return "", ErrOutside
+	}
+	target, ok := s.Real(real)
+	if !ok {
+		return "", ErrOutside
+	}
+	if info, err = os.Stat(real); err != nil {
+		return "", err
+	}
+	return target, s.mount(target, info, p)
+}
+
+// mount 不共享挂载点时，file为目录且与所在根目录不在同一文件系统则返回ErrMount
+func (s Set) mount(file string, info os.FileInfo, p Policy) error {
+	if p.Mounts || !info.IsDir() {
+		return nil
+	}
+	root, ok := s.Of(file)
+	if !ok {
+		return nil
+	}
+	rootInfo, err := os.Stat(root.Path)
+	if err != nil {
+		return nil
+	}
+	if a, ok := device(info); ok {
+		if b, ok := device(rootInfo); ok && a != b {
+			return ErrMount
+		}
+	}
+	return nil
+}
+
+// named 使用链接名的目标信息
+type named struct {
+	os.FileInfo
+	name string
+}
+
+func (n named) Name() string { return n.name }
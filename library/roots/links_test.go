@@ -0,0 +1,108 @@
+package roots
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	mk := func(p string) string {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		_ = os.MkdirAll(p, 0755)
+		return p
+	}
+	docs, music, outside := mk("docs"), mk("music"), mk("secret")
+	_ = ioutil.WriteFile(filepath.Join(outside, "passwd"), []byte("x"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(music, "a.mp3"), []byte("mp3"), 0644)
+	mk("docs/real")
+	link := func(target, name string) {
+		if err := os.Symlink(target, filepath.Join(docs, name)); err != nil {
+			t.Skip("symlinks not supported:", err)
+		}
+	}
+	link("real", "inside")                    // 根目录内的相对链接
+	link(music, "music")                      // 指向另一个根目录
+	link(outside, "abs")                      // 指向根目录之外
+	link("../secret", "rel")                  // 相对路径跳出根目录
+	link("inside/../../secret/passwd", "dot") // 经过根目录内的链接再跳出
+	link("rel", "chain")                      // 链接的链接
+	link("missing", "dangling")               // 悬空，写入时会在目标处创建文件
+	s := Set{{Name: "docs", Path: docs}, {Name: "music", Path: music}}
+
+	follow := Policy{Links: LinksFollow, Mounts: true}
+	allowed := []string{"docs/inside", "docs/inside/new.txt", "docs/music/a.mp3", "docs/new/dir/file.txt"}
+	denied := []string{"docs/abs", "docs/abs/passwd", "docs/rel/passwd", "docs/dot", "docs/chain/passwd", "docs/dangling"}
+	for _, rel := range allowed {
+		file, _ := s.Resolve(rel)
+		if err := s.Check(file, follow); err != nil {
+			t.Errorf("follow %s: %v", rel, err)
+		}
+	}
+	for _, rel := range denied {
+		file, _ := s.Resolve(rel)
+		if err := s.Check(file, follow); err != ErrOutside {
+			t.Errorf("follow %s: %v", rel, err)
+		}
+	}
+	// 不跟随时链接本身与其下的路径都不能访问，普通路径不受影响
+	for _, links := range []string{LinksList, LinksHide} {
+		p := Policy{Links: links, Mounts: true}
+		for _, rel := range append(allowed[:3:3], denied...) {
+			file, _ := s.Resolve(rel)
+			if err := s.Check(file, p); err == nil {
+				t.Errorf("%s %s: allowed", links, rel)
+			}
+		}
+		if file, _ := s.Resolve("docs/real/a.txt"); s.Check(file, p) != nil {
+			t.Error(links, "plain path")
+		}
+	}
+	if err := s.Check("/etc/passwd", follow); err != ErrOutside {
+		t.Error("outside", err)
+	}
+
+	// 列表: 跟随时显示目标的信息，列为链接时给出目标，隐藏时不显示
+	infos, _ := ioutil.ReadDir(docs)
+	list := func(p Policy) map[string]string {
+		ret := make(map[string]string)
+		for _, info := range infos {
+			if shown, link, ok := s.Entry(docs, info, p); ok {
+				ret[shown.Name()] = link
+				if shown.Name() == "music" && !shown.IsDir() {
+					t.Error("link info not followed")
+				}
+			}
+		}
+		return ret
+	}
+	if got := list(follow); len(got) != 3 || got["inside"] != "" {
+		t.Error("follow", got)
+	}
+	if got := list(Policy{Links: LinksList}); len(got) != 3 || got["music"] != music || got["inside"] != filepath.Join(docs, "real") {
+		t.Error("list", got)
+	}
+	if got := list(Policy{Links: LinksHide}); len(got) != 1 {
+		t.Error("hide", got)
+	}
+	// 同一文件系统内的目录不是挂载点
+	if file, _ := s.Resolve("docs/real"); s.Check(file, Policy{}) != nil {
+		t.Error("mount")
+	}
+
+	if l, err := ParseLinks(" List "); l != LinksList || err != nil {
+		t.Error(l, err)
+	}
+	if l, _ := ParseLinks(""); l != LinksFollow {
+		t.Error(l)
+	}
+	if _, err := ParseLinks("skip"); err == nil {
+		t.Error("invalid")
+	}
+}
@@ -0,0 +1,173 @@
+package roots
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 多共享根目录: 配置多个时各根目录以名称作为顶层虚拟目录，如 /Photos/2024/a.jpg；只有一个时直接映射
+
+// Root 共享根目录，Icon/Color/Desc为列表中显示的图标、颜色与说明(可选)
+type Root struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Icon  string `json:"icon,omitempty"`
+	Color string `json:"color,omitempty"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+// Set 根目录集合，第一个为默认根目录
+type Set []Root
+
+// Parse 解析配置项(name/path/icon/color/desc表数组)，path为相对路径时以base为基准，name为空时取目录名
+func Parse(items []interface{}, base string) (Set, error) {
+	var s Set
+	seen := make(map[string]bool)
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("roots: invalid item %v", item)
+		}
+		p, _ := m["path"].(string)
+		if p == "" {
+			return nil, fmt.Errorf("roots: path is required")
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		name, _ := m["name"].(string)
+		if name == "" {
+			name = filepath.Base(p)
+		}
+		if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return nil, fmt.Errorf("roots: invalid name %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("roots: duplicate name %q", name)
+		}
+		seen[name] = true
+		r := Root{Name: name, Path: filepath.Clean(p)}
+		r.Icon, _ = m["icon"].(string)
+		r.Color, _ = m["color"].(string)
+		r.Desc, _ = m["desc"].(string)
+		s = append(s, r)
+	}
+	return s, nil
+}
+
+// Single 只有一个目录的根目录集合，供只配置了单个目录的服务使用
+func Single(dir string) Set {
+	return Set{{Name: filepath.Base(dir), Path: filepath.Clean(dir)}}
+}
+
+// Multi 是否有多个根目录(顶层为虚拟目录)
+func (s Set) Multi() bool {
+	return len(s) > 1
+}
+
+// IsTop 相对路径是否为多根目录的顶层虚拟目录
+func (s Set) IsTop(rel string) bool {
+	return s.Multi() && path.Clean("/"+filepath.ToSlash(rel)) == "/"
+}
+
+// List 顶层虚拟目录的目录项，以根目录名作为名称，无法访问的根目录跳过
+func (s Set) List() []os.FileInfo {
+	var infos []os.FileInfo
+	for _, r := range s {
+		if info, err := os.Stat(r.Path); err == nil && info.IsDir() {
+			infos = append(infos, named{info, r.Name})
+		}
+	}
+	return infos
+}
+
+// Top 顶层虚拟目录的信息，修改时间取各根目录中最新的
+func (s Set) Top() os.FileInfo {
+	var t topInfo
+	for _, info := range s.List() {
+		if info.ModTime().After(t.mtime) {
+			t.mtime = info.ModTime()
+		}
+	}
+	return t
+}
+
+// topInfo 顶层虚拟目录的信息(只读目录)
+type topInfo struct {
+	mtime time.Time
+}
+
+func (t topInfo) Name() string       { return "/" }
+func (t topInfo) Size() int64        { return 0 }
+func (t topInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (t topInfo) ModTime() time.Time { return t.mtime }
+func (t topInfo) IsDir() bool        { return true }
+func (t topInfo) Sys() interface{}   { return nil }
+
+// Resolve 将共享目录下的相对路径(/files之后的部分)转为文件路径
+// 多根目录时首段为根目录名；顶层虚拟目录或根目录名不存在时ok为false
+func (s Set) Resolve(rel string) (file string, ok bool) {
+	if len(s) == 0 {
+		return "", false
+	}
+	rel = path.Clean("/" + filepath.ToSlash(rel))
+	if !s.Multi() {
+		return filepath.Join(s[0].Path, filepath.FromSlash(rel)), true
+	}
+	parts := strings.SplitN(strings.TrimPrefix(rel, "/"), "/", 2)
+	for _, r := range s {
+		if r.Name == parts[0] {
+			if len(parts) == 1 {
+				return r.Path, true
+			}
+			return filepath.Join(r.Path, filepath.FromSlash(parts[1])), true
+		}
+	}
+	return "", false
+}
+
+// Rel 文件路径转为共享目录下的相对路径(不含开头的/)，不在任何根目录下时ok为false
+func (s Set) Rel(file string) (rel string, ok bool) {
+	file = filepath.Clean(file)
+	for _, r := range s {
+		sub, err := filepath.Rel(r.Path, file)
+		if err != nil || sub == ".." || strings.HasPrefix(sub, ".."+string(filepath.Separator)) {
+			continue
+		}
+		sub = filepath.ToSlash(sub)
+		if !s.Multi() {
+			return sub, true
+		}
+		if sub == "." {
+			return r.Name, true
+		}
+		return r.Name + "/" + sub, true
+	}
+	return "", false
+}
+
+// Of 文件所在的根目录
+func (s Set) Of(file string) (Root, bool) {
+	file = filepath.Clean(file)
+	for _, r := range s {
+		if file == r.Path || strings.HasPrefix(file, r.Path+string(filepath.Separator)) {
+			return r, true
+		}
+	}
+	return Root{}, false
+}
+
+// IsRoot 是否为某个根目录本身
+func (s Set) IsRoot(file string) bool {
+	file = filepath.Clean(file)
+	for _, r := range s {
+		if file == r.Path {
+			return true
+		}
+	}
+	return false
+}
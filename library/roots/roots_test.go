@@ -0,0 +1,94 @@
+package roots
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	s, err := Parse([]interface{}{
+		map[string]interface{}{"name": "Photos", "path": "/data/photos", "icon": "📷", "desc": "家庭相册"},
+		map[string]interface{}{"path": "usb"},
+	}, "/opt/b0pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Multi() || s[1].Name != "usb" || s[1].Path != filepath.Clean("/opt/b0pass/usb") || s[0].Icon != "📷" || s[0].Desc != "家庭相册" {
+		t.Fatalf("%+v", s)
+	}
+	cases := map[string]string{
+		"/Photos/2024/a.jpg":    "/data/photos/2024/a.jpg",
+		"Photos":                "/data/photos",
+		"/usb/../Photos/b.jpg":  "/data/photos/b.jpg",
+		"/Photos/../../etc/pwd": "",
+		"/":                     "",
+		"/Music/a.mp3":          "",
+	}
+	for rel, want := range cases {
+		got, ok := s.Resolve(rel)
+		if want == "" {
+			if ok {
+				t.Errorf("%q: resolved to %q", rel, got)
+			}
+			continue
+		}
+		if got != filepath.FromSlash(want) {
+			t.Errorf("%q: got %q, want %q", rel, got, want)
+		}
+	}
+	if rel, ok := s.Rel("/data/photos/2024/a.jpg"); !ok || rel != "Photos/2024/a.jpg" {
+		t.Error(rel, ok)
+	}
+	if rel, ok := s.Rel("/data/photos2/a.jpg"); ok {
+		t.Error(rel)
+	}
+	if !s.IsRoot("/data/photos/") || s.IsRoot("/data/photos/2024") {
+		t.Error("IsRoot")
+	}
+	if r, ok := s.Of("/opt/b0pass/usb/x"); !ok || r.Name != "usb" {
+		t.Error(r, ok)
+	}
+}
+
+func TestSingle(t *testing.T) {
+	s := Set{{Name: "files", Path: "/srv/files"}}
+	if got, _ := s.Resolve("/../a/b.txt"); got != filepath.FromSlash("/srv/files/a/b.txt") {
+		t.Error(got)
+	}
+	if rel, ok := s.Rel("/srv/files/a/b.txt"); !ok || rel != "a/b.txt" {
+		t.Error(rel, ok)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bad := [][]interface{}{
+		{map[string]interface{}{"name": "a"}},
+		{map[string]interface{}{"name": "a/b", "path": "/x"}},
+		{map[string]interface{}{"name": "a", "path": "/x"}, map[string]interface{}{"name": "a", "path": "/y"}},
+		{"not a table"},
+	}
+	for _, items := range bad {
+		if _, err := Parse(items, "/"); err == nil {
+			t.Errorf("%v: expected error", items)
+		}
+	}
+}
+
+func TestTop(t *testing.T) {
+	a, _ := ioutil.TempDir("", "roots-a")
+	b, _ := ioutil.TempDir("", "roots-b")
+	defer func() { _ = os.RemoveAll(a); _ = os.RemoveAll(b) }()
+	s := Set{{Name: "A", Path: a}, {Name: "B", Path: b}, {Name: "gone", Path: filepath.Join(a, "missing")}}
+	if !s.IsTop("/") || !s.IsTop("A/..") || s.IsTop("/A") || Single(a).IsTop("/") {
+		t.Error("IsTop")
+	}
+	list := s.List()
+	if len(list) != 2 || list[0].Name() != "A" || list[1].Name() != "B" || !list[0].IsDir() {
+		t.Errorf("List %v", list)
+	}
+	if top := s.Top(); !top.IsDir() || top.ModTime().IsZero() {
+		t.Errorf("Top %v", top)
+	}
+}
@@ -0,0 +1,189 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// Client 调用b0pass gRPC服务的Go客户端
+type Client struct {
+	// Addr 服务地址 host:port
+	Addr string
+	// User/Password 服务端要求认证时设置
+	User     string
+	Password string
+
+	hc *http.Client
+}
+
+// NewClient 创建客户端
+func NewClient(addr string) (*Client, error) {
+	t, err := h2cTransport()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Addr: addr, hc: &http.Client{Transport: t}}, nil
+}
+
+// call 发起调用，send在独立goroutine中写入请求消息，recv读取响应消息直到io.EOF
+func (c *Client) call(ctx context.Context, method string, send func(w io.Writer) error, recv func(r io.Reader) error) error {
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, "http://"+c.Addr+"/"+Service+"/"+method, pr)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	if c.User != "" || c.Password != "" {
+		req.SetBasicAuth(c.User, c.Password)
+	}
+	go func() {
+		_ = pw.CloseWithError(send(pw))
+	}()
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		_ = pr.CloseWithError(err)
+		return statusf(Unavailable, "%v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = pr.Close()
+		return statusf(Unknown, "unexpected HTTP status %s", resp.Status)
+	}
+	// Trailers-Only响应
+	if s := headerStatus(resp.Header); s != nil {
+		_ = pr.Close()
+		return s.err()
+	}
+	rerr := recv(resp.Body)
+	_ = pr.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if s := headerStatus(resp.Trailer); s != nil {
+		if s.Code != OK {
+			return s
+		}
+	} else if rerr == nil || rerr == io.EOF {
+		return statusf(Internal, "missing grpc-status trailer")
+	}
+	if rerr == io.EOF {
+		return nil
+	}
+	return rerr
+}
+
+func headerStatus(h http.Header) *Status {
+	v := h.Get("Grpc-Status")
+	if v == "" {
+		return nil
+	}
+	code, err := strconv.Atoi(v)
+	if err != nil {
+		return statusf(Unknown, "invalid grpc-status %q", v)
+	}
+	return &Status{Code: code, Message: decodeMessage(h.Get("Grpc-Message"))}
+}
+
+// err OK状态返回nil
+func (s *Status) err() error {
+	if s.Code == OK {
+		return nil
+	}
+	return s
+}
+
+// unary 发送单条请求消息
+func unary(m message) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		return writeMsg(w, m)
+	}
+}
+
+// recvOne 读取单条响应消息
+func recvOne(m message) func(r io.Reader) error {
+	return func(r io.Reader) error {
+		if err := readMsg(r, m); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+}
+
+// ListFiles 列出目录
+func (c *Client) ListFiles(ctx context.Context, dir string) ([]*FileInfo, error) {
+	resp := new(ListFilesResponse)
+	if err := c.call(ctx, "ListFiles", unary(&ListFilesRequest{Path: dir}), recvOne(resp)); err != nil {
+		return nil, err
+	}
+	return resp.Files, nil
+}
+
+// Upload 上传文件，head携带目标目录、文件名及可选的大小、SHA256与修改时间
+func (c *Client) Upload(ctx context.Context, head *UploadRequest, r io.Reader) (*UploadResponse, error) {
+	send := func(w io.Writer) error {
+		buf := make([]byte, chunkSize)
+		m := *head
+		for {
+			n, err := io.ReadFull(r, buf)
+			if err == io.EOF && m.Name == "" {
+				return nil
+			}
+			if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+				return err
+			}
+			m.Data = buf[:n]
+			if werr := writeMsg(w, &m); werr != nil {
+				return werr
+			}
+			if err != nil {
+				return nil
+			}
+			m = UploadRequest{}
+		}
+	}
+	resp := new(UploadResponse)
+	if err := c.call(ctx, "UploadStream", send, recvOne(resp)); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Download 从offset开始下载文件写入w，返回文件总大小
+func (c *Client) Download(ctx context.Context, file string, offset int64, w io.Writer) (int64, error) {
+	var size int64
+	recv := func(r io.Reader) error {
+		for first := true; ; first = false {
+			m := new(DownloadResponse)
+			if err := readMsg(r, m); err != nil {
+				return err
+			}
+			if first {
+				size = m.Size
+			}
+			if _, err := w.Write(m.Data); err != nil {
+				return err
+			}
+		}
+	}
+	err := c.call(ctx, "DownloadStream", unary(&DownloadRequest{Path: file, Offset: offset}), recv)
+	return size, err
+}
+
+// WatchEvents 订阅通知事件，直到ctx取消或fn返回错误
+func (c *Client) WatchEvents(ctx context.Context, types []string, fn func(e *Event) error) error {
+	recv := func(r io.Reader) error {
+		for {
+			e := new(Event)
+			if err := readMsg(r, e); err != nil {
+				return err
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return c.call(ctx, "WatchEvents", unary(&WatchEventsRequest{Types: types}), recv)
+}
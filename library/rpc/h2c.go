@@ -0,0 +1,23 @@
+//go:build go1.24
+// +build go1.24
+
+package rpc
+
+import (
+	"net"
+	"net/http"
+)
+
+// serveH2C 使用标准库的明文HTTP/2支持
+func serveH2C(srv *http.Server, l net.Listener) error {
+	p := new(http.Protocols)
+	p.SetUnencryptedHTTP2(true)
+	srv.Protocols = p
+	return srv.Serve(l)
+}
+
+func h2cTransport() (http.RoundTripper, error) {
+	p := new(http.Protocols)
+	p.SetUnencryptedHTTP2(true)
+	return &http.Transport{Protocols: p}, nil
+}
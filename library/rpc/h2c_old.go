@@ -0,0 +1,22 @@
+//go:build !go1.24
+// +build !go1.24
+
+package rpc
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ErrNoH2C 标准库明文HTTP/2需要Go 1.24及以上版本
+var ErrNoH2C = errors.New("rpc: unencrypted HTTP/2 requires Go 1.24 or later")
+
+func serveH2C(srv *http.Server, l net.Listener) error {
+	_ = l.Close()
+	return ErrNoH2C
+}
+
+func h2cTransport() (http.RoundTripper, error) {
+	return nil, ErrNoH2C
+}
@@ -0,0 +1,250 @@
+package rpc
+
+// ListFilesRequest 列出目录
+type ListFilesRequest struct {
+	Path string
+}
+
+func (m *ListFilesRequest) marshal() []byte {
+	return appendString(nil, 1, m.Path)
+}
+
+func (m *ListFilesRequest) unmarshal(b []byte) error {
+	return parseFields(b, func(f field) error {
+		if f.num == 1 {
+			m.Path = string(f.b)
+		}
+		return nil
+	})
+}
+
+// FileInfo 文件信息
+type FileInfo struct {
+	Name    string
+	Path    string
+	Size    int64
+	Dir     bool
+	ModTime int64
+}
+
+func (m *FileInfo) marshal() []byte {
+	b := appendString(nil, 1, m.Name)
+	b = appendString(b, 2, m.Path)
+	b = appendInt(b, 3, m.Size)
+	b = appendBool(b, 4, m.Dir)
+	return appendInt(b, 5, m.ModTime)
+}
+
+func (m *FileInfo) unmarshal(b []byte) error {
+	return parseFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Name = string(f.b)
+		case 2:
+			m.Path = string(f.b)
+		case 3:
+			m.Size = int64(f.u)
+		case 4:
+			m.Dir = f.u != 0
+		case 5:
+			m.ModTime = int64(f.u)
+		}
+		return nil
+	})
+}
+
+// ListFilesResponse 目录列表
+type ListFilesResponse struct {
+	Files []*FileInfo
+}
+
+func (m *ListFilesResponse) marshal() []byte {
+	var b []byte
+	for _, f := range m.Files {
+		body := f.marshal()
+		b = appendVarint(appendTag(b, 1, wireBytes), uint64(len(body)))
+		b = append(b, body...)
+	}
+	return b
+}
+
+func (m *ListFilesResponse) unmarshal(b []byte) error {
+	return parseFields(b, func(f field) error {
+		if f.num == 1 {
+			fi := new(FileInfo)
+			if err := fi.unmarshal(f.b); err != nil {
+				return err
+			}
+			m.Files = append(m.Files, fi)
+		}
+		return nil
+	})
+}
+
+// UploadRequest 上传数据，第一条消息携带Path/Name等信息
+type UploadRequest struct {
+	Path    string
+	Name    string
+	Size    int64
+	SHA256  string
+	ModTime int64
+	Data    []byte
+}
+
+func (m *UploadRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Path)
+	b = appendString(b, 2, m.Name)
+	b = appendInt(b, 3, m.Size)
+	b = appendString(b, 4, m.SHA256)
+	b = appendInt(b, 5, m.ModTime)
+	return appendBytes(b, 6, m.Data)
+}
+
+func (m *UploadRequest) unmarshal(b []byte) error {
+	return parseFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Path = string(f.b)
+		case 2:
+			m.Name = string(f.b)
+		case 3:
+			m.Size = int64(f.u)
+		case 4:
+			m.SHA256 = string(f.b)
+		case 5:
+			m.ModTime = int64(f.u)
+		case 6:
+			m.Data = f.b
+		}
+		return nil
+	})
+}
+
+// UploadResponse 上传结果
+type UploadResponse struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+func (m *UploadResponse) marshal() []byte {
+	b := appendString(nil, 1, m.Path)
+	b = appendInt(b, 2, m.Size)
+	return appendString(b, 3, m.SHA256)
+}
+
+func (m *UploadResponse) unmarshal(b []byte) error {
+	return parseFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Path = string(f.b)
+		case 2:
+			m.Size = int64(f.u)
+		case 3:
+			m.SHA256 = string(f.b)
+		}
+		return nil
+	})
+}
+
+// DownloadRequest 下载文件
+type DownloadRequest struct {
+	Path   string
+	Offset int64
+}
+
+func (m *DownloadRequest) marshal() []byte {
+	return appendInt(appendString(nil, 1, m.Path), 2, m.Offset)
+}
+
+func (m *DownloadRequest) unmarshal(b []byte) error {
+	return parseFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Path = string(f.b)
+		case 2:
+			m.Offset = int64(f.u)
+		}
+		return nil
+	})
+}
+
+// DownloadResponse 下载数据，第一条消息携带文件总大小
+type DownloadResponse struct {
+	Size int64
+	Data []byte
+}
+
+func (m *DownloadResponse) marshal() []byte {
+	return appendBytes(appendInt(nil, 1, m.Size), 2, m.Data)
+}
+
+func (m *DownloadResponse) unmarshal(b []byte) error {
+	return parseFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Size = int64(f.u)
+		case 2:
+			m.Data = f.b
+		}
+		return nil
+	})
+}
+
+// WatchEventsRequest 订阅事件，Types为空时订阅全部
+type WatchEventsRequest struct {
+	Types []string
+}
+
+func (m *WatchEventsRequest) marshal() []byte {
+	var b []byte
+	for _, t := range m.Types {
+		b = appendVarint(appendTag(b, 1, wireBytes), uint64(len(t)))
+		b = append(b, t...)
+	}
+	return b
+}
+
+func (m *WatchEventsRequest) unmarshal(b []byte) error {
+	return parseFields(b, func(f field) error {
+		if f.num == 1 {
+			m.Types = append(m.Types, string(f.b))
+		}
+		return nil
+	})
+}
+
+// Event 通知事件，Data为JSON
+type Event struct {
+	Type  string
+	Level string
+	Title string
+	Data  string
+	Time  string
+}
+
+func (m *Event) marshal() []byte {
+	b := appendString(nil, 1, m.Type)
+	b = appendString(b, 2, m.Level)
+	b = appendString(b, 3, m.Title)
+	b = appendString(b, 4, m.Data)
+	return appendString(b, 5, m.Time)
+}
+
+func (m *Event) unmarshal(b []byte) error {
+	return parseFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Type = string(f.b)
+		case 2:
+			m.Level = string(f.b)
+		case 3:
+			m.Title = string(f.b)
+		case 4:
+			m.Data = string(f.b)
+		case 5:
+			m.Time = string(f.b)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,249 @@
+//go:build go1.24
+// +build go1.24
+
+package rpc
+
+import (
+	"b0pass/library/notify"
+	"b0pass/library/roots"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	in := &ListFilesResponse{Files: []*FileInfo{{Name: "a.txt", Path: "/a.txt", Size: 300, ModTime: 1700000000}, {Name: "d", Dir: true}, {}}}
+	out := new(ListFilesResponse)
+	if err := out.unmarshal(in.marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Files) != 3 || *out.Files[0] != *in.Files[0] || !out.Files[1].Dir {
+		t.Errorf("round trip mismatch: %+v", out.Files)
+	}
+	// 未知字段应被跳过
+	b := appendInt(appendString(nil, 9, "x"), 1, 1)
+	if err := new(ListFilesRequest).unmarshal(b); err != nil {
+		t.Error(err)
+	}
+	if err := new(ListFilesRequest).unmarshal([]byte{0x0a, 0x05, 'a'}); err == nil {
+		t.Error("truncated message accepted")
+	}
+}
+
+func startServer(t *testing.T, s *Server) (*Client, func()) {
+	root, _ := ioutil.TempDir("", "rpc")
+	s.Root, s.TempDir = root, filepath.Join(root, ".tmp")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	c, err := NewClient(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, func() {
+		_ = l.Close()
+		_ = os.RemoveAll(root)
+	}
+}
+
+func code(err error) int {
+	var s *Status
+	if errors.As(err, &s) {
+		return s.Code
+	}
+	return -1
+}
+
+func TestServer(t *testing.T) {
+	var changed string
+	s := &Server{
+		User:     "u",
+		Password: "p",
+		OnChange: func(client, file string, size int64, sum string) { changed = file },
+	}
+	c, done := startServer(t, s)
+	defer done()
+	root := s.Root
+	ctx := context.Background()
+	if _, err := c.ListFiles(ctx, "/"); code(err) != Unauthenticated {
+		t.Fatalf("expected unauthenticated, got %v", err)
+	}
+	c.User, c.Password = "u", "p"
+
+	data := bytes.Repeat([]byte("0123456789"), 60000)
+	resp, err := c.Upload(ctx, &UploadRequest{Path: "/sub", Name: "a.bin", Size: int64(len(data)), ModTime: 1600000000}, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(root, "sub", "a.bin")
+	if resp.Path != "/sub/a.bin" || resp.Size != int64(len(data)) || changed != file {
+		t.Errorf("bad upload response %+v, changed %q", resp, changed)
+	}
+	if got, _ := ioutil.ReadFile(file); !bytes.Equal(got, data) {
+		t.Error("uploaded content mismatch")
+	}
+	if fi, _ := os.Stat(file); fi.ModTime().Unix() != 1600000000 {
+		t.Error("mtime not applied")
+	}
+	_, err = c.Upload(ctx, &UploadRequest{Name: "b.txt", SHA256: strings.Repeat("0", 64)}, strings.NewReader("hi"))
+	if code(err) != DataLoss {
+		t.Errorf("expected data loss, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "b.txt")); err == nil {
+		t.Error("file with bad checksum committed")
+	}
+	if _, err := c.Upload(ctx, &UploadRequest{Name: "../.."}, strings.NewReader("x")); code(err) != InvalidArgument {
+		t.Errorf("expected invalid argument, got %v", err)
+	}
+
+	files, err := c.ListFiles(ctx, "/sub")
+	if err != nil || len(files) != 1 || files[0].Path != "/sub/a.bin" || files[0].Size != int64(len(data)) {
+		t.Errorf("bad listing %+v %v", files, err)
+	}
+	if _, err := c.ListFiles(ctx, "/missing"); code(err) != NotFound {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	size, err := c.Download(ctx, "/sub/a.bin", 100, &buf)
+	if err != nil || size != int64(len(data)) || !bytes.Equal(buf.Bytes(), data[100:]) {
+		t.Errorf("bad download size=%d len=%d err=%v", size, buf.Len(), err)
+	}
+	if _, err := c.Download(ctx, "/../../etc/passwd", 0, &buf); code(err) != NotFound {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	c, done := startServer(t, &Server{ReadOnly: true})
+	defer done()
+	_, err := c.Upload(context.Background(), &UploadRequest{Name: "a"}, strings.NewReader("x"))
+	if code(err) != PermissionDenied {
+		t.Errorf("expected permission denied, got %v", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := &Server{
+		Authenticate: func(r *http.Request) (string, bool) {
+			user, _, ok := r.BasicAuth()
+			return user, ok
+		},
+		CanWrite: func(role string) bool { return role != "guest" },
+	}
+	c, done := startServer(t, s)
+	defer done()
+	ctx := context.Background()
+	if _, err := c.ListFiles(ctx, "/"); code(err) != Unauthenticated {
+		t.Fatalf("expected unauthenticated, got %v", err)
+	}
+	c.User, c.Password = "guest", "x"
+	if _, err := c.ListFiles(ctx, "/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Upload(ctx, &UploadRequest{Name: "a"}, strings.NewReader("x")); code(err) != PermissionDenied {
+		t.Errorf("expected permission denied, got %v", err)
+	}
+	c.User = "user"
+	if _, err := c.Upload(ctx, &UploadRequest{Name: "a"}, strings.NewReader("x")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	s := &Server{}
+	c, done := startServer(t, s)
+	defer done()
+	outside, _ := ioutil.TempDir("", "rpc-outside")
+	defer func() { _ = os.RemoveAll(outside) }()
+	_ = ioutil.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644)
+	if err := os.Symlink(outside, filepath.Join(s.Root, "out")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	set := roots.Set{{Name: "root", Path: s.Root}}
+	s.Allow = func(file string) bool {
+		return set.Check(file, roots.Policy{Links: roots.LinksFollow, Mounts: true}) == nil
+	}
+	ctx := context.Background()
+	var buf bytes.Buffer
+	if _, err := c.Download(ctx, "/out/secret.txt", 0, &buf); code(err) != NotFound || buf.Len() > 0 {
+		t.Errorf("read through symlink: %v", err)
+	}
+	if _, err := c.Upload(ctx, &UploadRequest{Path: "/out", Name: "new.txt"}, strings.NewReader("x")); code(err) != NotFound {
+		t.Errorf("write through symlink: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Error("file written outside the root")
+	}
+	if _, err := c.ListFiles(ctx, "/out"); code(err) != NotFound {
+		t.Errorf("list through symlink: %v", err)
+	}
+	if files, err := c.ListFiles(ctx, "/"); err != nil || len(files) != 0 {
+		t.Errorf("symlink listed: %v %v", files, err)
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	c, done := startServer(t, &Server{})
+	defer done()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got := make(chan *Event, 1)
+	go c.WatchEvents(ctx, []string{"network"}, func(e *Event) error {
+		got <- e
+		return errors.New("done")
+	})
+	// 等待订阅建立
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		notify.Send(notify.Event{Type: "storage"})
+		notify.Send(notify.Event{Type: "network", Title: "changed", Data: map[string]int{"n": 1}})
+		select {
+		case e := <-got:
+			if e.Type != "network" || e.Data != `{"n":1}` || e.Time == "" {
+				t.Errorf("bad event %+v", e)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	t.Error("event not received")
+}
+
+func TestRoots(t *testing.T) {
+	a, _ := ioutil.TempDir("", "rpc-a")
+	b, _ := ioutil.TempDir("", "rpc-b")
+	defer func() { _ = os.RemoveAll(a); _ = os.RemoveAll(b) }()
+	s := &Server{Roots: roots.Set{{Name: "A", Path: a}, {Name: "B", Path: b}}}
+	c, done := startServer(t, s)
+	defer done()
+	ctx := context.Background()
+
+	// 多根目录的顶层列出各根目录，上传须指定根目录
+	files, err := c.ListFiles(ctx, "/")
+	if err != nil || len(files) != 2 || files[0].Name != "A" || !files[1].Dir {
+		t.Fatalf("list top: %v %v", files, err)
+	}
+	if _, err := c.Upload(ctx, &UploadRequest{Path: "/B/sub", Name: "x.txt"}, strings.NewReader("x")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(b, "sub", "x.txt")); string(data) != "x" {
+		t.Errorf("bad content %q", data)
+	}
+	if _, err := c.Upload(ctx, &UploadRequest{Name: "top.txt"}, strings.NewReader("x")); code(err) != NotFound {
+		t.Errorf("upload to top: %v", err)
+	}
+	if _, err := c.Upload(ctx, &UploadRequest{Name: "A"}, strings.NewReader("x")); code(err) != InvalidArgument {
+		t.Errorf("upload over root: %v", err)
+	}
+}
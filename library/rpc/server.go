@@ -0,0 +1,435 @@
+package rpc
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/notify"
+	"b0pass/library/roots"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Service gRPC服务名
+const Service = "b0pass.B0Pass"
+
+// chunkSize 下载时每条消息携带的数据量
+const chunkSize = 256 << 10
+
+// Server gRPC服务，方法定义见b0pass.proto
+type Server struct {
+	// Root 共享根目录
+	Root string
+	// Roots 多个共享根目录(可选)，设置时以根目录名作为顶层虚拟目录，不再使用Root
+	Roots roots.Set
+	// TempDir 上传暂存目录
+	TempDir string
+	// User/Password 非空时要求Basic认证
+	User     string
+	Password string
+	// Authenticate 认证请求并返回角色(可选)，设置后不再使用User/Password
+	Authenticate func(r *http.Request) (role string, ok bool)
+	// CanWrite 该角色能否上传(可选)，未设置时按ReadOnly
+	CanWrite func(role string) bool
+	// Allow 是否允许访问文件(可选)，如按符号链接策略限制，不允许的路径视为不存在，也不在列表中出现
+	Allow func(file string) bool
+	// Writable 能否写入文件(可选)，如受保护目录，不允许时返回PermissionDenied
+	Writable func(file string) bool
+	// ReadOnly 只读模式，拒绝上传
+	ReadOnly bool
+	// OnChange 上传完成回调(可选)
+	OnChange func(client, file string, size int64, sum string)
+	// Busy 传输开始时调用(可选)，返回的函数在传输结束时调用
+	Busy func() func()
+}
+
+// ListenAndServe 以明文HTTP/2(h2c)监听
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在l上以明文HTTP/2(h2c)提供服务
+func (s *Server) Serve(l net.Listener) error {
+	return serveH2C(&http.Server{Handler: s}, l)
+}
+
+// stream 一次调用的收发
+type stream struct {
+	w     http.ResponseWriter
+	r     *http.Request
+	role  string
+	wrote bool
+}
+
+func (st *stream) recv(m message) error {
+	return readMsg(st.r.Body, m)
+}
+
+// start 发送响应头
+func (st *stream) start() {
+	if st.wrote {
+		return
+	}
+	st.w.WriteHeader(http.StatusOK)
+	st.wrote = true
+	if f, ok := st.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (st *stream) send(m message) error {
+	st.start()
+	if err := writeMsg(st.w, m); err != nil {
+		return err
+	}
+	if f, ok := st.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// finish 写入状态，尚未发送任何消息时使用Trailers-Only响应
+func (st *stream) finish(err error) {
+	code, msg := OK, ""
+	if err != nil {
+		s := toStatus(err)
+		code, msg = s.Code, s.Message
+	}
+	h := st.w.Header()
+	if st.wrote {
+		h.Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+		if msg != "" {
+			h.Set(http.TrailerPrefix+"Grpc-Message", encodeMessage(msg))
+		}
+		return
+	}
+	h.Set("Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		h.Set("Grpc-Message", encodeMessage(msg))
+	}
+	st.w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2 POST", http.StatusUnsupportedMediaType)
+		return
+	}
+	ct := r.Header.Get("Content-Type")
+	if ct != "application/grpc" && !strings.HasPrefix(ct, "application/grpc+proto") {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	st := &stream{w: w, r: r}
+	role, ok := s.authorized(r)
+	if !ok {
+		st.finish(statusf(Unauthenticated, "invalid credentials"))
+		return
+	}
+	st.role = role
+	var err error
+	switch r.URL.Path {
+	case "/" + Service + "/ListFiles":
+		err = s.listFiles(st)
+	case "/" + Service + "/UploadStream":
+		err = s.upload(st)
+	case "/" + Service + "/DownloadStream":
+		err = s.download(st)
+	case "/" + Service + "/WatchEvents":
+		err = s.watchEvents(st)
+	default:
+		err = statusf(Unimplemented, "unknown method %s", r.URL.Path)
+	}
+	st.finish(err)
+}
+
+// authorized 认证请求，返回角色
+func (s *Server) authorized(r *http.Request) (string, bool) {
+	if s.Authenticate != nil {
+		return s.Authenticate(r)
+	}
+	if s.User == "" && s.Password == "" {
+		return "", true
+	}
+	user, pass, ok := r.BasicAuth()
+	return "", ok && subtle.ConstantTimeCompare([]byte(user), []byte(s.User)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(s.Password)) == 1
+}
+
+// writable 该调用能否写入
+func (s *Server) writable(st *stream) bool {
+	if s.CanWrite != nil {
+		return s.CanWrite(st.role)
+	}
+	return !s.ReadOnly
+}
+
+func (s *Server) busy() func() {
+	if s.Busy == nil {
+		return func() {}
+	}
+	return s.Busy()
+}
+
+// roots 共享根目录集合
+func (s *Server) roots() roots.Set {
+	if len(s.Roots) > 0 {
+		return s.Roots
+	}
+	return roots.Single(s.Root)
+}
+
+// resolve 将请求路径映射到共享目录内，不允许访问、多根目录的顶层或根目录名不存在时返回NotFound
+func (s *Server) resolve(p string) (string, error) {
+	file, ok := s.roots().Resolve(p)
+	if !ok || !s.allowed(file) {
+		return "", statusf(NotFound, "%s: %v", path.Clean("/"+p), os.ErrNotExist)
+	}
+	return file, nil
+}
+
+// allowed 是否允许访问文件
+func (s *Server) allowed(file string) bool {
+	return s.Allow == nil || s.Allow(file)
+}
+
+// recvRequest 读取一元请求
+func recvRequest(st *stream, m message) error {
+	if err := st.recv(m); err != nil {
+		if err == io.EOF {
+			return statusf(InvalidArgument, "missing request message")
+		}
+		return err
+	}
+	return nil
+}
+
+// osStatus 转换文件系统错误，消息中使用请求路径而不暴露服务端目录
+func osStatus(err error, name string) error {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	switch {
+	case os.IsNotExist(err):
+		return statusf(NotFound, "%s: %v", name, err)
+	case os.IsPermission(err):
+		return statusf(PermissionDenied, "%s: %v", name, err)
+	}
+	return statusf(Internal, "%s: %v", name, err)
+}
+
+func (s *Server) listFiles(st *stream) error {
+	req := new(ListFilesRequest)
+	if err := recvRequest(st, req); err != nil {
+		return err
+	}
+	dir := path.Clean("/" + req.Path)
+	resp := new(ListFilesResponse)
+	// 多根目录的顶层列出各根目录
+	if s.roots().IsTop(dir) {
+		for _, fi := range s.roots().List() {
+			resp.Files = append(resp.Files, &FileInfo{Name: fi.Name(), Path: "/" + fi.Name(), Dir: true, ModTime: fi.ModTime().Unix()})
+		}
+		return st.send(resp)
+	}
+	local, err := s.resolve(dir)
+	if err != nil {
+		return err
+	}
+	infos, err := ioutil.ReadDir(local)
+	if err != nil {
+		return osStatus(err, dir)
+	}
+	for _, fi := range infos {
+		if strings.HasPrefix(fi.Name(), ".") || !s.allowed(filepath.Join(local, fi.Name())) {
+			continue
+		}
+		f := &FileInfo{
+			Name:    fi.Name(),
+			Path:    path.Join(dir, fi.Name()),
+			Dir:     fi.IsDir(),
+			ModTime: fi.ModTime().Unix(),
+		}
+		if !fi.IsDir() {
+			f.Size = fi.Size()
+		}
+		resp.Files = append(resp.Files, f)
+	}
+	return st.send(resp)
+}
+
+func (s *Server) upload(st *stream) error {
+	if !s.writable(st) {
+		return statusf(PermissionDenied, "read-only mode")
+	}
+	head := new(UploadRequest)
+	if err := recvRequest(st, head); err != nil {
+		return err
+	}
+	name := path.Base(path.Clean("/" + head.Name))
+	if name == "/" || strings.HasPrefix(name, ".") {
+		return statusf(InvalidArgument, "invalid file name %q", head.Name)
+	}
+	rel := path.Join(path.Clean("/"+head.Path), name)
+	file, err := s.resolve(rel)
+	if err != nil {
+		return err
+	}
+	if s.roots().IsRoot(file) {
+		return statusf(InvalidArgument, "invalid file name %q", head.Name)
+	}
+	if s.Writable != nil && !s.Writable(file) {
+		return statusf(PermissionDenied, "%s: not writable", rel)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return osStatus(err, rel)
+	}
+	defer s.busy()()
+	tmp, err := fileinfos.CreateTemp(s.TempDir, name)
+	if err != nil {
+		return osStatus(err, rel)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	h := sha256.New()
+	w := io.MultiWriter(tmp, h)
+	var size int64
+	for m := head; ; m = new(UploadRequest) {
+		if m != head {
+			if err := st.recv(m); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(m.Data); err != nil {
+			return osStatus(err, rel)
+		}
+		size += int64(len(m.Data))
+		if head.Size > 0 && size > head.Size {
+			return statusf(InvalidArgument, "received more than %d bytes", head.Size)
+		}
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if head.Size > 0 && size != head.Size {
+		return statusf(DataLoss, "size mismatch: got %d, want %d", size, head.Size)
+	}
+	if head.SHA256 != "" && !strings.EqualFold(head.SHA256, sum) {
+		return statusf(DataLoss, "sha256 mismatch: got %s", sum)
+	}
+	if err := tmp.Close(); err != nil {
+		return osStatus(err, rel)
+	}
+	if err := fileinfos.Commit(tmp.Name(), file); err != nil {
+		return osStatus(err, rel)
+	}
+	if head.ModTime > 0 {
+		t := time.Unix(head.ModTime, 0)
+		_ = os.Chtimes(file, t, t)
+	}
+	if s.OnChange != nil {
+		client, _, _ := net.SplitHostPort(st.r.RemoteAddr)
+		s.OnChange(client, file, size, sum)
+	}
+	return st.send(&UploadResponse{Path: rel, Size: size, SHA256: sum})
+}
+
+func (s *Server) download(st *stream) error {
+	req := new(DownloadRequest)
+	if err := recvRequest(st, req); err != nil {
+		return err
+	}
+	file, err := s.resolve(req.Path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return osStatus(err, req.Path)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return osStatus(err, req.Path)
+	}
+	if fi.IsDir() {
+		return statusf(InvalidArgument, "%s is a directory", req.Path)
+	}
+	if req.Offset < 0 || req.Offset > fi.Size() {
+		return statusf(InvalidArgument, "offset %d out of range", req.Offset)
+	}
+	if _, err := f.Seek(req.Offset, io.SeekStart); err != nil {
+		return osStatus(err, req.Path)
+	}
+	defer s.busy()()
+	buf := make([]byte, chunkSize)
+	first := true
+	for {
+		n, err := f.Read(buf)
+		if n > 0 || first {
+			m := &DownloadResponse{Data: buf[:n]}
+			if first {
+				m.Size, first = fi.Size(), false
+			}
+			if err := st.send(m); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return osStatus(err, req.Path)
+		}
+		if st.r.Context().Err() != nil {
+			return statusf(Canceled, "context canceled")
+		}
+	}
+}
+
+func (s *Server) watchEvents(st *stream) error {
+	req := new(WatchEventsRequest)
+	if err := recvRequest(st, req); err != nil {
+		return err
+	}
+	types := make(map[string]bool)
+	for _, t := range req.Types {
+		types[t] = true
+	}
+	events, cancel := notify.Subscribe(64)
+	defer cancel()
+	// 先发送响应头，客户端据此确认订阅已建立
+	st.start()
+	for {
+		select {
+		case <-st.r.Context().Done():
+			return statusf(Canceled, "context canceled")
+		case e := <-events:
+			if len(types) > 0 && !types[e.Type] {
+				continue
+			}
+			data, _ := json.Marshal(e.Data)
+			m := &Event{Type: e.Type, Level: e.Level, Title: e.Title, Data: string(data), Time: e.Time}
+			if err := st.send(m); err != nil {
+				return err
+			}
+		}
+	}
+}
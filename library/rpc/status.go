@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// gRPC状态码
+const (
+	OK                 = 0
+	Canceled           = 1
+	Unknown            = 2
+	InvalidArgument    = 3
+	NotFound           = 5
+	PermissionDenied   = 7
+	ResourceExhausted  = 8
+	FailedPrecondition = 9
+	Unimplemented      = 12
+	Internal           = 13
+	Unavailable        = 14
+	DataLoss           = 15
+	Unauthenticated    = 16
+)
+
+// Status 非OK的调用结果
+type Status struct {
+	Code    int
+	Message string
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("rpc error: code = %d desc = %s", s.Code, s.Message)
+}
+
+func statusf(code int, format string, a ...interface{}) *Status {
+	return &Status{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// toStatus 将任意错误转为Status
+func toStatus(err error) *Status {
+	if s, ok := err.(*Status); ok {
+		return s
+	}
+	return &Status{Code: Internal, Message: err.Error()}
+}
+
+// encodeMessage 按gRPC规范对grpc-message做百分号编码
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func decodeMessage(msg string) string {
+	if s, err := url.PathUnescape(msg); err == nil {
+		return s
+	}
+	return msg
+}
@@ -0,0 +1,161 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// protobuf编码，只实现接口用到的varint与length-delimited类型
+const (
+	wireVarint = 0
+	wire64     = 1
+	wireBytes  = 2
+	wire32     = 5
+)
+
+var errMalformed = errors.New("malformed protobuf message")
+
+// message 可编码为protobuf的消息
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, num, typ int) []byte {
+	return appendVarint(b, uint64(num)<<3|uint64(typ))
+}
+
+func appendInt(b []byte, num int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendVarint(appendTag(b, num, wireVarint), uint64(v))
+}
+
+func appendBool(b []byte, num int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendVarint(appendTag(b, num, wireVarint), 1)
+}
+
+func appendBytes(b []byte, num int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = appendVarint(appendTag(b, num, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendString(b []byte, num int, v string) []byte {
+	return appendBytes(b, num, []byte(v))
+}
+
+// field 解码出的字段，varint类型使用u，length-delimited类型使用b
+type field struct {
+	num int
+	typ int
+	u   uint64
+	b   []byte
+}
+
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// parseFields 逐个解码字段，跳过未知的定长字段
+func parseFields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		tag, n := readVarint(b)
+		if n == 0 || tag>>3 == 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		f := field{num: int(tag >> 3), typ: int(tag & 7)}
+		switch f.typ {
+		case wireVarint:
+			if f.u, n = readVarint(b); n == 0 {
+				return errMalformed
+			}
+			b = b[n:]
+		case wireBytes:
+			l, n := readVarint(b)
+			if n == 0 || uint64(len(b)-n) < l {
+				return errMalformed
+			}
+			f.b = b[n : n+int(l)]
+			b = b[n+int(l):]
+		case wire64:
+			if len(b) < 8 {
+				return errMalformed
+			}
+			f.u = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wire32:
+			if len(b) < 4 {
+				return errMalformed
+			}
+			f.u = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		default:
+			return errMalformed
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gRPC消息帧: 1字节压缩标记 + 4字节大端长度 + 消息
+const maxMessageSize = 4 << 20
+
+func writeMsg(w io.Writer, m message) error {
+	body := m.marshal()
+	frame := make([]byte, 5, 5+len(body))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(body)))
+	_, err := w.Write(append(frame, body...))
+	return err
+}
+
+// readMsg 读取一条消息，流正常结束时返回io.EOF
+func readMsg(r io.Reader, m message) error {
+	var head [5]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return statusf(Internal, "truncated message")
+		}
+		return err
+	}
+	if head[0] != 0 {
+		return statusf(Unimplemented, "compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(head[1:])
+	if n > maxMessageSize {
+		return statusf(ResourceExhausted, "message larger than %d bytes", maxMessageSize)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return statusf(Internal, "truncated message")
+	}
+	if err := m.unmarshal(body); err != nil {
+		return statusf(Internal, "%v", err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigAlgorithm    = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	emptySHA256     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	maxClockSkew    = 15 * time.Minute
+)
+
+// authorize 校验AWS Signature V4(Authorization头或预签名URL)
+// 未配置AccessKey时允许匿名访问
+func (h *Handler) authorize(r *http.Request) *apiError {
+	if h.AccessKey == "" {
+		return nil
+	}
+	q := r.URL.Query()
+	if q.Get("X-Amz-Algorithm") != "" {
+		return h.authorizePresigned(r, q)
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, sigAlgorithm+" ") {
+		return errAccessDenied
+	}
+	fields := make(map[string]string)
+	for _, kv := range strings.Split(strings.TrimPrefix(auth, sigAlgorithm+" "), ",") {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			fields[strings.TrimSpace(kv[:i])] = strings.TrimSpace(kv[i+1:])
+		}
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		amzDate = r.Header.Get("Date")
+	}
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+	if payload == "" {
+		payload = emptySHA256
+	}
+	return h.checkSignature(r, q, fields["Credential"], fields["SignedHeaders"], fields["Signature"], amzDate, payload, 0)
+}
+
+// authorizePresigned 校验预签名URL
+func (h *Handler) authorizePresigned(r *http.Request, q url.Values) *apiError {
+	if q.Get("X-Amz-Algorithm") != sigAlgorithm {
+		return errAccessDenied
+	}
+	expires, _ := strconv.Atoi(q.Get("X-Amz-Expires"))
+	sig := q.Get("X-Amz-Signature")
+	q.Del("X-Amz-Signature")
+	return h.checkSignature(r, q, q.Get("X-Amz-Credential"), q.Get("X-Amz-SignedHeaders"), sig,
+		q.Get("X-Amz-Date"), unsignedPayload, time.Duration(expires)*time.Second)
+}
+
+func (h *Handler) checkSignature(r *http.Request, q url.Values, credential, signedHeaders, signature,
+	amzDate, payload string, expires time.Duration) *apiError {
+	// Credential=AKID/20130524/us-east-1/s3/aws4_request
+	cred := strings.Split(credential, "/")
+	if len(cred) != 5 || cred[0] != h.AccessKey || cred[3] != "s3" || cred[4] != "aws4_request" {
+		return errInvalidAccessKey
+	}
+	t, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil {
+		return errAccessDenied
+	}
+	now := time.Now()
+	if expires > 0 {
+		if now.After(t.Add(expires)) {
+			return errExpired
+		}
+	} else if now.Sub(t) > maxClockSkew || t.Sub(now) > maxClockSkew {
+		return errTimeSkewed
+	}
+	want := signString(h.SecretKey, amzDate, strings.Join(cred[1:], "/"), canonicalRequest(r, q, signedHeaders, payload))
+	if !hmac.Equal([]byte(want), []byte(signature)) {
+		return errSignature
+	}
+	return nil
+}
+
+// signString 计算签名，scope为 date/region/s3/aws4_request
+func signString(secret, amzDate, scope, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := sigAlgorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	parts := strings.Split(scope, "/")
+	return hex.EncodeToString(hmacSHA256(signingKey(secret, parts[0], parts[1]), stringToSign))
+}
+
+// canonicalRequest 规范请求
+func canonicalRequest(r *http.Request, q url.Values, signedHeaders, payload string) string {
+	var headers strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		var value string
+		if name == "host" {
+			value = r.Host
+		} else {
+			value = strings.Join(r.Header[http.CanonicalHeaderKey(name)], ",")
+		}
+		headers.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	return strings.Join([]string{
+		r.Method,
+		encodePath(r.URL.Path),
+		canonicalQuery(q),
+		headers.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+}
+
+func canonicalQuery(q url.Values) string {
+	var pairs []string
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func encodePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return uriEncode(p, false)
+}
+
+// uriEncode 按AWS规则编码: 仅保留 A-Z a-z 0-9 - _ . ~
+func uriEncode(s string, encodeSlash bool) string {
+	const hexUpper = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexUpper[c>>4])
+			b.WriteByte(hexUpper[c&0xf])
+		}
+	}
+	return b.String()
+}
+
+func signingKey(secret, date, region string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, "s3")
+	return hmacSHA256(k, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	_, _ = m.Write([]byte(data))
+	return m.Sum(nil)
+}
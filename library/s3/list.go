@@ -0,0 +1,199 @@
+package s3
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxListKeys = 1000
+
+// entry 列表项，prefix为true时为CommonPrefix
+type entry struct {
+	key    string
+	info   os.FileInfo
+	prefix bool
+}
+
+func (h *Handler) listBuckets(w http.ResponseWriter) *apiError {
+	var list []os.FileInfo
+	if s := h.roots(); s.Multi() {
+		list = s.List()
+	} else {
+		var err error
+		if list, err = ioutil.ReadDir(h.Root); err != nil {
+			return internalError(err)
+		}
+	}
+	ret := listBucketsResult{Xmlns: xmlns, Owner: owner{ID: "b0pass", DisplayName: "b0pass"}}
+	for _, fi := range list {
+		if dir, ok := h.roots().Resolve(fi.Name()); ok && fi.IsDir() && !strings.HasPrefix(fi.Name(), ".") && h.allowed(dir) {
+			ret.Buckets = append(ret.Buckets, bucketInfo{
+				Name:         fi.Name(),
+				CreationDate: fi.ModTime().UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	writeXML(w, http.StatusOK, ret)
+	return nil
+}
+
+func (h *Handler) listObjects(w http.ResponseWriter, bucket, dir string, q url.Values) *apiError {
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	maxKeys := maxListKeys
+	if v, err := strconv.Atoi(q.Get("max-keys")); err == nil && v >= 0 && v < maxKeys {
+		maxKeys = v
+	}
+	ret := listObjectsResult{
+		Xmlns:     xmlns,
+		Name:      bucket,
+		Prefix:    prefix,
+		MaxKeys:   maxKeys,
+		Delimiter: delimiter,
+	}
+	v2 := q.Get("list-type") == "2"
+	var marker string
+	if v2 {
+		ret.StartAfter = q.Get("start-after")
+		ret.ContinuationToken = q.Get("continuation-token")
+		marker = ret.StartAfter
+		if b, err := base64.StdEncoding.DecodeString(ret.ContinuationToken); err == nil && len(b) > 0 {
+			marker = string(b)
+		}
+	} else {
+		marker = q.Get("marker")
+		ret.Marker = &marker
+	}
+
+	entries := h.collect(dir, prefix, delimiter)
+	var last string
+	for _, e := range entries {
+		if e.key <= marker {
+			continue
+		}
+		if len(ret.Contents)+len(ret.CommonPrefixes) >= maxKeys {
+			ret.IsTruncated = true
+			break
+		}
+		last = e.key
+		if e.prefix {
+			ret.CommonPrefixes = append(ret.CommonPrefixes, commonPrefix{Prefix: e.key})
+			continue
+		}
+		ret.Contents = append(ret.Contents, object{
+			Key:          e.key,
+			LastModified: e.info.ModTime().UTC().Format("2006-01-02T15:04:05.000Z"),
+			ETag:         h.etag(objectPath(dir, e.key), e.info),
+			Size:         size(e),
+			StorageClass: "STANDARD",
+		})
+	}
+	if ret.IsTruncated {
+		if v2 {
+			ret.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(last))
+		} else if delimiter != "" {
+			ret.NextMarker = last
+		}
+	}
+	if v2 {
+		n := len(ret.Contents) + len(ret.CommonPrefixes)
+		ret.KeyCount = &n
+	}
+	if q.Get("encoding-type") == "url" {
+		ret.EncodingType = "url"
+		ret.Prefix = url.QueryEscape(ret.Prefix)
+		ret.Delimiter = url.QueryEscape(ret.Delimiter)
+		for i := range ret.Contents {
+			ret.Contents[i].Key = url.QueryEscape(ret.Contents[i].Key)
+		}
+		for i := range ret.CommonPrefixes {
+			ret.CommonPrefixes[i].Prefix = url.QueryEscape(ret.CommonPrefixes[i].Prefix)
+		}
+	}
+	writeXML(w, http.StatusOK, ret)
+	return nil
+}
+
+func size(e entry) int64 {
+	if e.info.IsDir() {
+		return 0
+	}
+	return e.info.Size()
+}
+
+// collect 收集prefix下的对象，按key排序；空目录以"key/"占位对象表示
+// delimiter为"/"时只读取一层目录，子目录直接作为CommonPrefix
+func (h *Handler) collect(dir, prefix, delimiter string) []entry {
+	base := prefix[:strings.LastIndex(prefix, "/")+1]
+	var entries []entry
+	if delimiter == "/" {
+		list, _ := ioutil.ReadDir(objectPath(dir, base))
+		for _, fi := range list {
+			if strings.HasPrefix(fi.Name(), ".") || !h.allowed(objectPath(dir, base+fi.Name())) {
+				continue
+			}
+			key := base + fi.Name()
+			if fi.IsDir() {
+				key += "/"
+			}
+			if strings.HasPrefix(key, prefix) {
+				entries = append(entries, entry{key: key, info: fi, prefix: fi.IsDir()})
+			}
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+		return entries
+	}
+
+	start := objectPath(dir, base)
+	_ = filepath.Walk(start, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p != start && (strings.HasPrefix(fi.Name(), ".") || !h.allowed(p)) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		key := filepath.ToSlash(rel)
+		if fi.IsDir() {
+			// 仅空目录输出占位对象
+			if list, _ := ioutil.ReadDir(p); p == start || len(list) > 0 {
+				return nil
+			}
+			key += "/"
+		}
+		if strings.HasPrefix(key, prefix) {
+			entries = append(entries, entry{key: key, info: fi})
+		}
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	if delimiter == "" {
+		return entries
+	}
+	// 按delimiter归并为CommonPrefix
+	var ret []entry
+	for _, e := range entries {
+		rest := e.key[len(prefix):]
+		if i := strings.Index(rest, delimiter); i >= 0 {
+			cp := prefix + rest[:i+len(delimiter)]
+			if n := len(ret); n > 0 && ret[n-1].prefix && ret[n-1].key == cp {
+				continue
+			}
+			ret = append(ret, entry{key: cp, info: e.info, prefix: true})
+			continue
+		}
+		ret = append(ret, e)
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].key < ret[j].key })
+	return ret
+}
@@ -0,0 +1,160 @@
+package s3
+
+import (
+	"b0pass/library/bufpool"
+	"b0pass/library/fileinfos"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 分片上传暂存于 TempDir/s3-multipart/<uploadId>/，key文件记录目标对象
+const multipartDir = "s3-multipart"
+
+func (h *Handler) uploadDir(id string) (string, *apiError) {
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		return "", errNoSuchUpload
+	}
+	dir := filepath.Join(h.TempDir, multipartDir, id)
+	if _, err := os.Stat(dir); err != nil {
+		return "", errNoSuchUpload
+	}
+	return dir, nil
+}
+
+func (h *Handler) createMultipart(w http.ResponseWriter, bucket, key string) *apiError {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return internalError(err)
+	}
+	id := hex.EncodeToString(b)
+	dir := filepath.Join(h.TempDir, multipartDir, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return internalError(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "key"), []byte(bucket+"/"+key), 0644); err != nil {
+		return internalError(err)
+	}
+	writeXML(w, http.StatusOK, initiateResult{Xmlns: xmlns, Bucket: bucket, Key: key, UploadID: id})
+	return nil
+}
+
+func (h *Handler) uploadPart(w http.ResponseWriter, r *http.Request, id, partNumber string) *apiError {
+	dir, e := h.uploadDir(id)
+	if e != nil {
+		return e
+	}
+	n, err := strconv.Atoi(partNumber)
+	if err != nil || n < 1 || n > 10000 {
+		return errInvalidPart
+	}
+	tmp, err := ioutil.TempFile(dir, "tmp")
+	if err != nil {
+		return internalError(err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	_, sum, e := writeBody(tmp, r)
+	if e != nil {
+		return e
+	}
+	_ = tmp.Close()
+	// 分片文件名包含MD5，完成上传时据此校验客户端提交的ETag
+	part := filepath.Join(dir, fmt.Sprintf("%05d.%s", n, sum))
+	if old, _ := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%05d.*", n))); len(old) > 0 {
+		for _, f := range old {
+			_ = os.Remove(f)
+		}
+	}
+	if err := os.Rename(tmp.Name(), part); err != nil {
+		return internalError(err)
+	}
+	w.Header().Set("ETag", `"`+sum+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (h *Handler) completeMultipart(w http.ResponseWriter, r *http.Request, bucket, key, file, id string) *apiError {
+	dir, e := h.uploadDir(id)
+	if e != nil {
+		return e
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "key")); string(b) != bucket+"/"+key {
+		return errNoSuchUpload
+	}
+	var req completeRequest
+	if err := xml.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil || len(req.Parts) == 0 {
+		return errMalformedXML
+	}
+	tmp, err := fileinfos.CreateTemp(h.TempDir, filepath.Base(file))
+	if err != nil {
+		return internalError(err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	// 合并后的ETag为各分片MD5拼接后的MD5加分片数
+	sums := md5.New()
+	var size int64
+	prev := 0
+	for _, p := range req.Parts {
+		etag := strings.Trim(p.ETag, `"`)
+		raw, err := hex.DecodeString(etag)
+		if p.PartNumber <= prev || err != nil {
+			return errInvalidPart
+		}
+		prev = p.PartNumber
+		f, err := os.Open(filepath.Join(dir, fmt.Sprintf("%05d.%s", p.PartNumber, etag)))
+		if err != nil {
+			return errInvalidPart
+		}
+		n, err := bufpool.Copy(tmp, f)
+		_ = f.Close()
+		if err != nil {
+			return internalError(err)
+		}
+		size += n
+		_, _ = sums.Write(raw)
+	}
+	if err := tmp.Close(); err != nil {
+		return internalError(err)
+	}
+	if err := fileinfos.Commit(tmp.Name(), file); err != nil {
+		return internalError(err)
+	}
+	_ = os.RemoveAll(dir)
+	etag := fmt.Sprintf("%x-%d", sums.Sum(nil), len(req.Parts))
+	h.changed(r, http.MethodPut, file, size, "")
+	writeXML(w, http.StatusOK, completeResult{
+		Xmlns:    xmlns,
+		Location: "/" + bucket + "/" + key,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     `"` + etag + `"`,
+	})
+	return nil
+}
+
+func (h *Handler) abortMultipart(w http.ResponseWriter, id string) *apiError {
+	dir, e := h.uploadDir(id)
+	if e != nil {
+		return e
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return internalError(err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
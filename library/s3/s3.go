@@ -0,0 +1,462 @@
+package s3
+
+import (
+	"b0pass/library/bufpool"
+	"b0pass/library/fileinfos"
+	"b0pass/library/roots"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Handler 最小化的S3兼容接口(路径风格)，以共享目录的一级子目录作为bucket，多根目录时以各根目录作为bucket
+// 支持ListBuckets、ListObjects(V1/V2)、Get/Head/Put/Copy/DeleteObject、DeleteObjects与分片上传
+type Handler struct {
+	// Root 共享根目录
+	Root string
+	// Roots 多个共享根目录(可选)，设置时以根目录名作为bucket，不再使用Root
+	Roots roots.Set
+	// TempDir 上传暂存目录
+	TempDir string
+	// AccessKey/SecretKey 为空时不校验签名
+	AccessKey string
+	SecretKey string
+	// ReadOnly 只读模式
+	ReadOnly bool
+	// ETag 返回已记录的文件MD5(可选)，无记录时使用非MD5格式的ETag
+	ETag func(file string) string
+	// OnChange 写操作完成回调(可选)，method为PUT/COPY/DELETE，etag为PUT内容的MD5
+	OnChange func(r *http.Request, method, file string, size int64, etag string)
+	// Allow 是否允许访问文件(可选)，如按符号链接策略限制，不允许的路径视为不存在，也不在列表中出现
+	Allow func(file string) bool
+	// Remove 删除对象(可选)，如移入回收站，未设置时直接删除
+	Remove func(file string) error
+	// Writable 能否写入文件(可选)，如受保护目录，不允许时返回AccessDenied
+	Writable func(file string) bool
+}
+
+// apiError S3错误
+type apiError struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+var (
+	errAccessDenied     = &apiError{"AccessDenied", "Access Denied", http.StatusForbidden}
+	errInvalidAccessKey = &apiError{"InvalidAccessKeyId", "The access key does not exist", http.StatusForbidden}
+	errSignature        = &apiError{"SignatureDoesNotMatch", "The request signature does not match", http.StatusForbidden}
+	errTimeSkewed       = &apiError{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large", http.StatusForbidden}
+	errExpired          = &apiError{"AccessDenied", "Request has expired", http.StatusForbidden}
+	errNoSuchBucket     = &apiError{"NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound}
+	errNoSuchKey        = &apiError{"NoSuchKey", "The specified key does not exist", http.StatusNotFound}
+	errNoSuchUpload     = &apiError{"NoSuchUpload", "The specified multipart upload does not exist", http.StatusNotFound}
+	errInvalidPart      = &apiError{"InvalidPart", "One or more of the specified parts could not be found", http.StatusBadRequest}
+	errBadDigest        = &apiError{"XAmzContentSHA256Mismatch", "The provided content hash does not match", http.StatusBadRequest}
+	errInvalidName      = &apiError{"InvalidBucketName", "The specified bucket is not valid", http.StatusBadRequest}
+	errBucketNotEmpty   = &apiError{"BucketNotEmpty", "The bucket you tried to delete is not empty", http.StatusConflict}
+	errMalformedXML     = &apiError{"MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest}
+	errNotImplemented   = &apiError{"NotImplemented", "A header or query you provided implies functionality that is not implemented", http.StatusNotImplemented}
+)
+
+func internalError(err error) *apiError {
+	return &apiError{"InternalError", err.Error(), http.StatusInternalServerError}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "B0Pass")
+	if e := h.authorize(r); e != nil {
+		writeError(w, r, e)
+		return
+	}
+	bucket, key := splitPath(r.URL.Path)
+	q := r.URL.Query()
+	if h.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, errAccessDenied)
+		return
+	}
+	var e *apiError
+	switch {
+	case bucket == "":
+		if r.Method != http.MethodGet {
+			e = errNotImplemented
+			break
+		}
+		e = h.listBuckets(w)
+	case key == "":
+		e = h.serveBucket(w, r, bucket, q)
+	default:
+		e = h.serveObject(w, r, bucket, key, q)
+	}
+	if e != nil {
+		writeError(w, r, e)
+	}
+}
+
+// splitPath /bucket/key -> bucket, key
+func splitPath(p string) (string, string) {
+	p = strings.TrimPrefix(p, "/")
+	if i := strings.IndexByte(p, '/'); i >= 0 {
+		return p[:i], p[i+1:]
+	}
+	return p, ""
+}
+
+// roots 共享根目录集合
+func (h *Handler) roots() roots.Set {
+	if len(h.Roots) > 0 {
+		return h.Roots
+	}
+	return roots.Single(h.Root)
+}
+
+// bucketDir bucket对应的目录，bucket名不能包含路径分隔符或以.开头
+func (h *Handler) bucketDir(bucket string) (string, *apiError) {
+	if bucket == "" || strings.HasPrefix(bucket, ".") || strings.ContainsAny(bucket, `/\`) {
+		return "", errInvalidName
+	}
+	dir, ok := h.roots().Resolve(bucket)
+	if !ok || !h.allowed(dir) {
+		return "", errNoSuchBucket
+	}
+	return dir, nil
+}
+
+// remove 删除对象，目录仅在为空时可删除
+func (h *Handler) remove(file string) error {
+	if info, err := os.Stat(file); err == nil && info.IsDir() {
+		if list, _ := ioutil.ReadDir(file); len(list) > 0 {
+			return os.ErrExist
+		}
+	}
+	if h.Remove != nil {
+		return h.Remove(file)
+	}
+	return os.Remove(file)
+}
+
+// allowed 是否允许访问文件
+func (h *Handler) allowed(file string) bool {
+	return h.Allow == nil || h.Allow(file)
+}
+
+// objectPath key对应的本地路径，不会越出bucket目录
+func objectPath(dir, key string) string {
+	return filepath.Join(dir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (h *Handler) serveBucket(w http.ResponseWriter, r *http.Request, bucket string, q url.Values) *apiError {
+	dir, e := h.bucketDir(bucket)
+	if e != nil {
+		return e
+	}
+	info, err := os.Stat(dir)
+	exists := err == nil && info.IsDir()
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			return errNoSuchBucket
+		}
+		if _, ok := q["location"]; ok {
+			writeXML(w, http.StatusOK, locationResult{Xmlns: xmlns})
+			return nil
+		}
+		if _, ok := q["uploads"]; ok {
+			return errNotImplemented
+		}
+		return h.listObjects(w, bucket, dir, q)
+	case http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return nil
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return internalError(err)
+		}
+		w.Header().Set("Location", "/"+bucket)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		if !exists {
+			return errNoSuchBucket
+		}
+		// 根目录本身不能删除
+		if h.roots().IsRoot(dir) {
+			return errAccessDenied
+		}
+		if list, _ := ioutil.ReadDir(dir); len(list) > 0 {
+			return errBucketNotEmpty
+		}
+		if err := os.Remove(dir); err != nil {
+			return internalError(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPost:
+		if _, ok := q["delete"]; ok {
+			if !exists {
+				return errNoSuchBucket
+			}
+			return h.deleteObjects(w, r, dir)
+		}
+		return errNotImplemented
+	default:
+		return errNotImplemented
+	}
+	return nil
+}
+
+func (h *Handler) serveObject(w http.ResponseWriter, r *http.Request, bucket, key string, q url.Values) *apiError {
+	dir, e := h.bucketDir(bucket)
+	if e != nil {
+		return e
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return errNoSuchBucket
+	}
+	file := objectPath(dir, key)
+	if !h.allowed(file) {
+		return errNoSuchKey
+	}
+	if (r.Method == http.MethodPut || r.Method == http.MethodPost) && h.Writable != nil && !h.Writable(file) {
+		return errAccessDenied
+	}
+	uploadID := q.Get("uploadId")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if uploadID != "" {
+			return errNotImplemented
+		}
+		return h.getObject(w, r, file, key)
+	case http.MethodPut:
+		if uploadID != "" {
+			return h.uploadPart(w, r, uploadID, q.Get("partNumber"))
+		}
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			return h.copyObject(w, r, src, file)
+		}
+		return h.putObject(w, r, file, key)
+	case http.MethodPost:
+		if _, ok := q["uploads"]; ok {
+			return h.createMultipart(w, bucket, key)
+		}
+		if uploadID != "" {
+			return h.completeMultipart(w, r, bucket, key, file, uploadID)
+		}
+		return errNotImplemented
+	case http.MethodDelete:
+		if uploadID != "" {
+			return h.abortMultipart(w, uploadID)
+		}
+		return h.deleteObject(w, r, file, key)
+	}
+	return errNotImplemented
+}
+
+// etag 文件ETag: 有MD5记录时使用MD5，否则使用含"-"的格式，客户端不会将其当作MD5校验
+func (h *Handler) etag(file string, info os.FileInfo) string {
+	if h.ETag != nil {
+		if sum := h.ETag(file); sum != "" {
+			return `"` + sum + `"`
+		}
+	}
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+func (h *Handler) getObject(w http.ResponseWriter, r *http.Request, file, key string) *apiError {
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() != strings.HasSuffix(key, "/") {
+		return errNoSuchKey
+	}
+	w.Header().Set("ETag", h.etag(file, info))
+	w.Header().Set("X-Amz-Meta-Mtime", strconv.FormatInt(info.ModTime().Unix(), 10))
+	if info.IsDir() {
+		w.Header().Set("Content-Type", "application/x-directory")
+		w.Header().Set("Content-Length", "0")
+		w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return internalError(err)
+	}
+	defer func() { _ = f.Close() }()
+	if ct := mime.TypeByExtension(filepath.Ext(file)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	http.ServeContent(w, r, "", info.ModTime(), f)
+	return nil
+}
+
+// readBody 请求体(解码aws-chunked)
+func readBody(r *http.Request) io.Reader {
+	if isChunked(r) {
+		return newChunkedReader(r.Body)
+	}
+	return r.Body
+}
+
+// writeBody 写入请求体到w，返回长度与MD5，声明了内容SHA256时校验
+func writeBody(w io.Writer, r *http.Request) (int64, string, *apiError) {
+	sum := md5.New()
+	sha := sha256.New()
+	n, err := bufpool.Copy(io.MultiWriter(w, sum, sha), readBody(r))
+	if err != nil {
+		return n, "", internalError(err)
+	}
+	if want := r.Header.Get("X-Amz-Content-Sha256"); len(want) == 64 && hex.EncodeToString(sha.Sum(nil)) != strings.ToLower(want) {
+		return n, "", errBadDigest
+	}
+	return n, hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func (h *Handler) putObject(w http.ResponseWriter, r *http.Request, file, key string) *apiError {
+	// 以/结尾的空对象为目录
+	if strings.HasSuffix(key, "/") {
+		if err := os.MkdirAll(file, 0755); err != nil {
+			return internalError(err)
+		}
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	tmp, err := fileinfos.CreateTemp(h.TempDir, filepath.Base(file))
+	if err != nil {
+		return internalError(err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	n, sum, e := writeBody(tmp, r)
+	if e != nil {
+		return e
+	}
+	_ = tmp.Close()
+	if err := fileinfos.Commit(tmp.Name(), file); err != nil {
+		return internalError(err)
+	}
+	applyMtime(r, file)
+	h.changed(r, http.MethodPut, file, n, sum)
+	w.Header().Set("ETag", `"`+sum+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// applyMtime 按 x-amz-meta-mtime(rclone)恢复修改时间
+func applyMtime(r *http.Request, file string) {
+	v, err := strconv.ParseFloat(r.Header.Get("X-Amz-Meta-Mtime"), 64)
+	if err != nil || v <= 0 {
+		return
+	}
+	t := time.Unix(0, int64(v*1e9))
+	_ = os.Chtimes(file, t, t)
+}
+
+func (h *Handler) copyObject(w http.ResponseWriter, r *http.Request, src, file string) *apiError {
+	src, _ = url.PathUnescape(strings.SplitN(src, "?", 2)[0])
+	srcBucket, srcKey := splitPath("/" + strings.TrimPrefix(src, "/"))
+	dir, e := h.bucketDir(srcBucket)
+	if e != nil {
+		return e
+	}
+	srcFile := objectPath(dir, srcKey)
+	info, err := os.Stat(srcFile)
+	if err != nil || info.IsDir() || !h.allowed(srcFile) {
+		return errNoSuchKey
+	}
+	if srcFile != file {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return internalError(err)
+		}
+		if err := fileinfos.Clone(srcFile, file); err != nil {
+			return internalError(err)
+		}
+		_ = os.Chtimes(file, info.ModTime(), info.ModTime())
+		h.changed(r, "COPY", file, info.Size(), "")
+	}
+	writeXML(w, http.StatusOK, copyResult{
+		LastModified: info.ModTime().UTC().Format(time.RFC3339),
+		ETag:         h.etag(srcFile, info),
+	})
+	return nil
+}
+
+func (h *Handler) deleteObject(w http.ResponseWriter, r *http.Request, file, key string) *apiError {
+	info, err := os.Stat(file)
+	// 目录仅在为空时可删除(对应S3中的目录占位对象)
+	if err == nil && (!info.IsDir() || strings.HasSuffix(key, "/")) {
+		if h.remove(file) == nil {
+			h.changed(r, http.MethodDelete, file, 0, "")
+		}
+	}
+	// 删除不存在的对象同样返回成功
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) deleteObjects(w http.ResponseWriter, r *http.Request, dir string) *apiError {
+	var req deleteRequest
+	if err := xml.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		return errMalformedXML
+	}
+	var ret deleteResult
+	for _, o := range req.Objects {
+		file := objectPath(dir, o.Key)
+		if info, err := os.Stat(file); err == nil && h.allowed(file) && (!info.IsDir() || strings.HasSuffix(o.Key, "/")) {
+			if err := h.remove(file); err != nil {
+				ret.Errors = append(ret.Errors, deleteError{Key: o.Key, Code: "InternalError", Message: err.Error()})
+				continue
+			}
+			h.changed(r, http.MethodDelete, file, 0, "")
+		}
+		if !req.Quiet {
+			ret.Deleted = append(ret.Deleted, deletedObject{Key: o.Key})
+		}
+	}
+	writeXML(w, http.StatusOK, ret)
+	return nil
+}
+
+func (h *Handler) changed(r *http.Request, method, file string, size int64, etag string) {
+	if h.OnChange != nil {
+		h.OnChange(r, method, file, size, etag)
+	}
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Length", strconv.Itoa(len(xml.Header)+len(b)))
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, xml.Header)
+	_, _ = w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, e *apiError) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(e.Status)
+		return
+	}
+	writeXML(w, e.Status, errorResult{Code: e.Code, Message: e.Message, Resource: r.URL.Path})
+}
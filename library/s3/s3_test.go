@@ -0,0 +1,211 @@
+package s3
+
+import (
+	"b0pass/library/roots"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	// AWS文档示例: GET Object
+	r, _ := http.NewRequest("GET", "https://examplebucket.s3.amazonaws.com/test.txt", nil)
+	r.Header.Set("Range", "bytes=0-9")
+	r.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	r.Header.Set("X-Amz-Date", "20130524T000000Z")
+	canonical := canonicalRequest(r, r.URL.Query(), "host;range;x-amz-content-sha256;x-amz-date", emptySHA256)
+	sig := signString("wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", "20130524T000000Z", "20130524/us-east-1/s3/aws4_request", canonical)
+	if sig != "f0e8bdb87c964420e857bd35b5d6ed310bd44f0170aba48dd91039c6036bdb41" {
+		t.Errorf("bad signature %s", sig)
+	}
+}
+
+func TestChunkedReader(t *testing.T) {
+	body := "5;chunk-signature=abc\r\nhello\r\n6;chunk-signature=def\r\n world\r\n0;chunk-signature=ghi\r\n\r\n"
+	b, err := ioutil.ReadAll(newChunkedReader(strings.NewReader(body)))
+	if err != nil || string(b) != "hello world" {
+		t.Errorf("bad chunked body %q %v", b, err)
+	}
+	body = "3\r\nabc\r\n0\r\nx-amz-checksum-crc32:AAAA\r\n\r\n"
+	if b, err = ioutil.ReadAll(newChunkedReader(strings.NewReader(body))); err != nil || string(b) != "abc" {
+		t.Errorf("bad trailer body %q %v", b, err)
+	}
+	if _, err = ioutil.ReadAll(newChunkedReader(strings.NewReader("5\r\nab"))); err == nil {
+		t.Error("truncated body accepted")
+	}
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path, body string) (int, string) {
+	r, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func TestHandler(t *testing.T) {
+	root, _ := ioutil.TempDir("", "s3")
+	defer func() { _ = os.RemoveAll(root) }()
+	srv := httptest.NewServer(&Handler{Root: root, TempDir: filepath.Join(root, ".tmp")})
+	defer srv.Close()
+
+	if code, _ := do(t, srv, "PUT", "/bkt", ""); code != 200 {
+		t.Fatalf("create bucket: %d", code)
+	}
+	for _, key := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "empty/"} {
+		if code, body := do(t, srv, "PUT", "/bkt/"+key, "data-"+key); code != 200 {
+			t.Fatalf("put %s: %d %s", key, code, body)
+		}
+	}
+	if code, body := do(t, srv, "GET", "/bkt/dir/b.txt", ""); code != 200 || body != "data-dir/b.txt" {
+		t.Errorf("get: %d %s", code, body)
+	}
+	if code, _ := do(t, srv, "GET", "/bkt/../../etc/passwd", ""); code != 404 {
+		t.Errorf("escaped bucket: %d", code)
+	}
+
+	var list listObjectsResult
+	_, body := do(t, srv, "GET", "/bkt?list-type=2&delimiter=/", "")
+	_ = xml.Unmarshal([]byte(body), &list)
+	if len(list.Contents) != 1 || list.Contents[0].Key != "a.txt" || len(list.CommonPrefixes) != 2 {
+		t.Errorf("bad delimiter list %s", body)
+	}
+	list = listObjectsResult{}
+	_, body = do(t, srv, "GET", "/bkt?prefix=dir/&max-keys=1", "")
+	_ = xml.Unmarshal([]byte(body), &list)
+	if len(list.Contents) != 1 || list.Contents[0].Key != "dir/b.txt" || !list.IsTruncated {
+		t.Errorf("bad prefix list %s", body)
+	}
+
+	// 分片上传
+	var init initiateResult
+	_, body = do(t, srv, "POST", "/bkt/big.bin?uploads", "")
+	_ = xml.Unmarshal([]byte(body), &init)
+	id := init.UploadID
+	r, _ := http.NewRequest("PUT", srv.URL+"/bkt/big.bin?partNumber=1&uploadId="+id, strings.NewReader("part1-"))
+	resp, _ := http.DefaultClient.Do(r)
+	etag1 := resp.Header.Get("ETag")
+	r, _ = http.NewRequest("PUT", srv.URL+"/bkt/big.bin?partNumber=2&uploadId="+id, strings.NewReader("part2"))
+	resp, _ = http.DefaultClient.Do(r)
+	etag2 := resp.Header.Get("ETag")
+	complete := "<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>" + etag1 +
+		"</ETag></Part><Part><PartNumber>2</PartNumber><ETag>" + etag2 + "</ETag></Part></CompleteMultipartUpload>"
+	if code, body := do(t, srv, "POST", "/bkt/big.bin?uploadId="+id, complete); code != 200 || !strings.Contains(body, "-2") {
+		t.Errorf("complete: %d %s", code, body)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(root, "bkt", "big.bin")); string(b) != "part1-part2" {
+		t.Errorf("bad multipart content %q", b)
+	}
+
+	if code, _ := do(t, srv, "DELETE", "/bkt/a.txt", ""); code != 204 {
+		t.Errorf("delete: %d", code)
+	}
+	if code, _ := do(t, srv, "HEAD", "/bkt/a.txt", ""); code != 404 {
+		t.Errorf("head deleted: %d", code)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	root, _ := ioutil.TempDir("", "s3")
+	defer func() { _ = os.RemoveAll(root) }()
+	outside, _ := ioutil.TempDir("", "s3-outside")
+	defer func() { _ = os.RemoveAll(outside) }()
+	_ = ioutil.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644)
+	_ = os.MkdirAll(filepath.Join(root, "bkt"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "bkt", "a.txt"), []byte("a"), 0644)
+	if err := os.Symlink(outside, filepath.Join(root, "bkt", "out")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	_ = os.Symlink(outside, filepath.Join(root, "evil"))
+	set := roots.Set{{Name: "root", Path: root}}
+	srv := httptest.NewServer(&Handler{Root: root, TempDir: filepath.Join(root, ".tmp"), Allow: func(file string) bool {
+		return set.Check(file, roots.Policy{Links: roots.LinksFollow, Mounts: true}) == nil
+	}})
+	defer srv.Close()
+
+	if code, body := do(t, srv, "GET", "/bkt/out/secret.txt", ""); code != 404 || body == "secret" {
+		t.Errorf("read through symlink: %d %s", code, body)
+	}
+	if code, _ := do(t, srv, "PUT", "/bkt/out/new.txt", "x"); code != 404 {
+		t.Errorf("write through symlink: %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Error("file written outside the root")
+	}
+	if code, _ := do(t, srv, "GET", "/evil?list-type=2", ""); code != 404 {
+		t.Errorf("list symlinked bucket: %d", code)
+	}
+	for _, q := range []string{"", "&delimiter=/"} {
+		if _, body := do(t, srv, "GET", "/bkt?list-type=2"+q, ""); strings.Contains(body, "out") || !strings.Contains(body, "a.txt") {
+			t.Errorf("list%s: %s", q, body)
+		}
+	}
+	if _, body := do(t, srv, "GET", "/", ""); strings.Contains(body, "evil") {
+		t.Errorf("list buckets: %s", body)
+	}
+}
+
+func TestWritable(t *testing.T) {
+	root, _ := ioutil.TempDir("", "s3")
+	defer func() { _ = os.RemoveAll(root) }()
+	_ = os.MkdirAll(filepath.Join(root, "bkt", "locked"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "bkt", "a.txt"), []byte("a"), 0644)
+	locked := filepath.Join(root, "bkt", "locked")
+	srv := httptest.NewServer(&Handler{Root: root, TempDir: filepath.Join(root, ".tmp"), Writable: func(file string) bool {
+		return file != locked && !strings.HasPrefix(file, locked+string(filepath.Separator))
+	}})
+	defer srv.Close()
+
+	if code, _ := do(t, srv, "PUT", "/bkt/locked/p.txt", "plain"); code != 403 {
+		t.Errorf("put into locked dir: %d", code)
+	}
+	r, _ := http.NewRequest("PUT", srv.URL+"/bkt/locked/c.txt", nil)
+	r.Header.Set("X-Amz-Copy-Source", "/bkt/a.txt")
+	if resp, err := http.DefaultClient.Do(r); err != nil || resp.StatusCode != 403 {
+		t.Errorf("copy into locked dir: %v %v", resp, err)
+	}
+	if code, _ := do(t, srv, "POST", "/bkt/locked/big.bin?uploads", ""); code != 403 {
+		t.Errorf("multipart into locked dir: %d", code)
+	}
+	if list, _ := ioutil.ReadDir(locked); len(list) != 0 {
+		t.Errorf("files written into locked dir: %d", len(list))
+	}
+	if code, _ := do(t, srv, "PUT", "/bkt/b.txt", "b"); code != 200 {
+		t.Errorf("put outside locked dir: %d", code)
+	}
+}
+
+func TestRoots(t *testing.T) {
+	a, _ := ioutil.TempDir("", "s3-a")
+	b, _ := ioutil.TempDir("", "s3-b")
+	defer func() { _ = os.RemoveAll(a); _ = os.RemoveAll(b) }()
+	srv := httptest.NewServer(&Handler{Roots: roots.Set{{Name: "A", Path: a}, {Name: "B", Path: b}}, TempDir: filepath.Join(a, ".tmp")})
+	defer srv.Close()
+
+	// 多根目录时各根目录即bucket
+	if _, body := do(t, srv, "GET", "/", ""); !strings.Contains(body, "<Name>A</Name>") || !strings.Contains(body, "<Name>B</Name>") {
+		t.Errorf("list buckets: %s", body)
+	}
+	if code, _ := do(t, srv, "PUT", "/B/dir/x.txt", "x"); code != 200 {
+		t.Errorf("put into second root: %d", code)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(b, "dir", "x.txt")); string(data) != "x" {
+		t.Errorf("bad content %q", data)
+	}
+	if code, _ := do(t, srv, "PUT", "/C/x.txt", "x"); code != 404 {
+		t.Errorf("put into unknown root: %d", code)
+	}
+	_ = os.Remove(filepath.Join(b, "dir", "x.txt"))
+	_ = os.Remove(filepath.Join(b, "dir"))
+	if code, _ := do(t, srv, "DELETE", "/B", ""); code != 403 {
+		t.Errorf("delete root: %d", code)
+	}
+}
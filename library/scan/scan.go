@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 扫描状态
+const (
+	StatusPending  = "pending"
+	StatusClean    = "clean"
+	StatusInfected = "infected"
+	StatusError    = "error"
+)
+
+// Result 扫描结果
+type Result struct {
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Hook 上传完成后的扫描钩子
+type Hook interface {
+	Scan(file string) Result
+}
+
+// Command 调用外部命令扫描(如clamdscan)
+// 约定与clamdscan一致: 退出码0为正常，1为发现病毒，其他为扫描错误
+type Command struct {
+	Args []string
+}
+
+// NewCommand 根据命令行创建扫描命令，{file}为文件路径占位符，缺省时追加在末尾
+func NewCommand(cmdline string) *Command {
+	args := strings.Fields(cmdline)
+	found := false
+	for _, arg := range args {
+		if strings.Contains(arg, "{file}") {
+			found = true
+		}
+	}
+	if !found {
+		args = append(args, "{file}")
+	}
+	return &Command{Args: args}
+}
+
+// Scan 执行扫描
+func (c *Command) Scan(file string) Result {
+	if len(c.Args) == 0 {
+		return Result{Status: StatusError, Detail: "empty scan command"}
+	}
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = strings.Replace(arg, "{file}", file, -1)
+	}
+	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	detail := strings.TrimSpace(string(out))
+	if err == nil {
+		return Result{Status: StatusClean, Detail: detail}
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return Result{Status: StatusInfected, Detail: detail}
+	}
+	if detail == "" {
+		detail = err.Error()
+	}
+	return Result{Status: StatusError, Detail: detail}
+}
+
+// Quarantine 将文件移动到隔离目录，返回隔离后的路径
+func Quarantine(file, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(file)))
+	if err := os.Rename(file, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
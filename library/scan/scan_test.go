@@ -0,0 +1,43 @@
+package scan
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	cases := map[string]string{
+		"true":                 StatusClean,
+		"false":                StatusInfected,
+		"b0pass-missing-clamd": StatusError,
+	}
+	for cmdline, want := range cases {
+		if got := NewCommand(cmdline).Scan("a.txt"); got.Status != want {
+			t.Errorf("%s: got %s, want %s", cmdline, got.Status, want)
+		}
+	}
+	c := NewCommand("clamdscan --no-summary {file}")
+	if len(c.Args) != 3 {
+		t.Errorf("placeholder appended twice: %v", c.Args)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "scan")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "eicar.com")
+	_ = ioutil.WriteFile(file, []byte("x"), 0644)
+
+	dst, err := Quarantine(file, filepath.Join(dir, "quarantine"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("file not moved")
+	}
+	if _, err := os.Stat(dst); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,192 @@
+package search
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 按文件名搜索共享目录: 直接遍历目录，或在预先建立的索引中查找
+
+// Entry 搜索结果
+type Entry struct {
+	Path  string `json:"path"` // 共享目录下的相对路径
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	MTime int64  `json:"mtime"`
+	Dir   bool   `json:"dir"`
+}
+
+// Query 搜索条件: 名称须包含全部关键字(不区分大小写)，指定扩展名时须为其中之一
+type Query struct {
+	Terms []string
+	Exts  []string // 小写，不含点
+}
+
+// Parse 解析搜索文字，空格分隔关键字；ext:pdf、*.pdf、.pdf 表示扩展名，可写多个
+func Parse(q string) Query {
+	var query Query
+	for _, f := range strings.Fields(strings.ToLower(q)) {
+		switch {
+		case strings.HasPrefix(f, "ext:"):
+			for _, ext := range strings.Split(f[4:], ",") {
+				if ext = strings.TrimPrefix(ext, "."); ext != "" {
+					query.Exts = append(query.Exts, ext)
+				}
+			}
+		case strings.HasPrefix(f, "*.") && len(f) > 2:
+			query.Exts = append(query.Exts, f[2:])
+		case strings.HasPrefix(f, ".") && len(f) > 1 && !strings.Contains(f[1:], "."):
+			query.Exts = append(query.Exts, f[1:])
+		default:
+			query.Terms = append(query.Terms, f)
+		}
+	}
+	return query
+}
+
+// Empty 是否没有任何条件
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0 && len(q.Exts) == 0
+}
+
+// Match 名称是否符合条件，指定扩展名时目录不匹配
+func (q Query) Match(name string, dir bool) bool {
+	lower := strings.ToLower(name)
+	for _, t := range q.Terms {
+		if !strings.Contains(lower, t) {
+			return false
+		}
+	}
+	if len(q.Exts) == 0 {
+		return true
+	}
+	if dir {
+		return false
+	}
+	ext := strings.TrimPrefix(path.Ext(lower), ".")
+	for _, e := range q.Exts {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Root 搜索的根目录，Prefix为结果路径的前缀(多根目录时为"/根目录名")
+type Root struct {
+	Prefix string
+	Dir    string
+}
+
+// Walk 遍历各根目录(跳过隐藏文件与目录)，对匹配的项调用fn，fn返回false时停止
+func Walk(roots []Root, q Query, fn func(Entry) bool) error {
+	stop := false
+	for _, r := range roots {
+		err := Each(r, func(e Entry) bool {
+			if q.Match(e.Name, e.Dir) && !fn(e) {
+				stop = true
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if stop {
+			return nil
+		}
+	}
+	return nil
+}
+
+// errStop 提前结束遍历
+var errStop = errors.New("search: stop")
+
+// Each 遍历一个根目录的全部文件与目录(跳过隐藏文件与目录)，fn返回false时停止
+func Each(r Root, fn func(Entry) bool) error {
+	err := filepath.Walk(r.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 无权限等无法读取的目录跳过
+			if info != nil && info.IsDir() && p != r.Dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if p == r.Dir {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(r.Dir, p)
+		e := Entry{Path: r.Prefix + "/" + filepath.ToSlash(rel), Name: info.Name(), MTime: info.ModTime().Unix(), Dir: info.IsDir()}
+		if !e.Dir {
+			e.Size = info.Size()
+		}
+		if !fn(e) {
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		return nil
+	}
+	return err
+}
+
+// Index 预先建立的文件名索引，定期重建，搜索时不再遍历磁盘
+type Index struct {
+	mu      sync.RWMutex
+	entries []Entry
+	built   time.Time
+}
+
+// Build 遍历各根目录重建索引
+func (x *Index) Build(roots []Root) error {
+	var entries []Entry
+	for _, r := range roots {
+		if err := Each(r, func(e Entry) bool {
+			entries = append(entries, e)
+			return true
+		}); err != nil {
+			return err
+		}
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.entries, x.built = entries, time.Now()
+	return nil
+}
+
+// Search 在索引中查找，对匹配的项调用fn，fn返回false时停止
+func (x *Index) Search(q Query, fn func(Entry) bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	for _, e := range x.entries {
+		if q.Match(e.Name, e.Dir) && !fn(e) {
+			return
+		}
+	}
+}
+
+// Built 最近一次建立索引的时间，尚未建立时为零值
+func (x *Index) Built() time.Time {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.built
+}
+
+// Len 索引中的项数
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.entries)
+}
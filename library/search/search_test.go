@@ -0,0 +1,79 @@
+package search
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	q := Parse("Report  ext:pdf,.DOCX *.txt .md 2024.v1")
+	if strings.Join(q.Terms, ",") != "report,2024.v1" || strings.Join(q.Exts, ",") != "pdf,docx,txt,md" {
+		t.Errorf("%+v", q)
+	}
+	if !Parse("  ").Empty() {
+		t.Error("empty")
+	}
+	cases := []struct {
+		q    string
+		name string
+		dir  bool
+		want bool
+	}{
+		{"report", "Q3-Report.PDF", false, true},
+		{"report q4", "Q3-Report.pdf", false, false},
+		{"ext:pdf", "a.PDF", false, true},
+		{"ext:pdf", "a.pdf.zip", false, false},
+		{"ext:pdf", "pdf", true, false},
+		{"photos", "Photos", true, true},
+	}
+	for _, c := range cases {
+		if got := Parse(c.q).Match(c.name, c.dir); got != c.want {
+			t.Errorf("%q ~ %q: %v", c.q, c.name, got)
+		}
+	}
+}
+
+func TestWalkIndex(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "search")
+	defer func() { _ = os.RemoveAll(dir) }()
+	for _, f := range []string{"a/report.pdf", "a/b/report-old.pdf", "a/notes.txt", ".hidden/report.pdf", "a/.report.pdf"} {
+		p := filepath.Join(dir, f)
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		_ = ioutil.WriteFile(p, []byte("12345"), 0644)
+	}
+	roots := []Root{{Prefix: "/docs", Dir: dir}}
+	collect := func(search func(Query, func(Entry) bool) error, q string, limit int) []string {
+		var got []string
+		if err := search(Parse(q), func(e Entry) bool {
+			got = append(got, e.Path)
+			return len(got) < limit
+		}); err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(got)
+		return got
+	}
+	walk := func(q Query, fn func(Entry) bool) error { return Walk(roots, q, fn) }
+	if got := strings.Join(collect(walk, "report .pdf", 10), ","); got != "/docs/a/b/report-old.pdf,/docs/a/report.pdf" {
+		t.Error(got)
+	}
+	if got := collect(walk, "report", 1); len(got) != 1 {
+		t.Error("limit", got)
+	}
+
+	var x Index
+	if err := x.Build(roots); err != nil || x.Len() != 5 || x.Built().IsZero() {
+		t.Fatal(err, x.Len())
+	}
+	search := func(q Query, fn func(Entry) bool) error { x.Search(q, fn); return nil }
+	if got := strings.Join(collect(search, "ext:txt", 10), ","); got != "/docs/a/notes.txt" {
+		t.Error(got)
+	}
+	if got := strings.Join(collect(search, "b", 10), ","); got != "/docs/a/b" {
+		t.Error(got)
+	}
+}
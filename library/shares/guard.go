@@ -0,0 +1,56 @@
+package shares
+
+import (
+	"sync"
+	"time"
+)
+
+// Guard 限制每个客户端访问不存在或已过期链接的次数，防止穷举分享链接
+type Guard struct {
+	Max    int           // 每个时间窗内允许的次数，<=0不限制
+	Window time.Duration // 时间窗，超过次数后在时间窗结束前拒绝该客户端
+
+	mu     sync.Mutex
+	misses map[string]*miss
+}
+
+// miss 某客户端在当前时间窗内的次数
+type miss struct {
+	count int
+	start time.Time
+}
+
+// Blocked 客户端是否已超过次数
+func (g *Guard) Blocked(client string, now time.Time) bool {
+	if g.Max <= 0 {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	m := g.misses[client]
+	return m != nil && now.Sub(m.start) < g.Window && m.count >= g.Max
+}
+
+// Miss 记录一次未找到链接
+func (g *Guard) Miss(client string, now time.Time) {
+	if g.Max <= 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.misses == nil {
+		g.misses = make(map[string]*miss)
+	}
+	m := g.misses[client]
+	if m == nil || now.Sub(m.start) >= g.Window {
+		// 顺便清理过期的记录
+		for k, v := range g.misses {
+			if now.Sub(v.start) >= g.Window {
+				delete(g.misses, k)
+			}
+		}
+		m = &miss{start: now}
+		g.misses[client] = m
+	}
+	m.count++
+}
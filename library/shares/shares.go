@@ -0,0 +1,336 @@
+package shares
+
+import (
+	"b0pass/library/fsync"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 分享链接: 通过/s/<id>下载单个文件，记录访问与下载完成情况，发送者据此确认对方是否收到
+
+// ErrNoEntry 分享链接不存在
+var ErrNoEntry = errors.New("share not found")
+
+// 短链接: 便于扫码与手动输入，字符去掉了易混淆的0、1、i、l、o，冲突较多时自动加长；
+// /s/无需登录，ID需足够长(10位约50比特)使穷举不可行
+const (
+	shortAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+	ShortLen      = 10 // 短链接ID的最短长度
+)
+
+// Link 分享链接
+type Link struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"` // 共享目录下的相对路径
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires,omitempty"`
+	Short   bool      `json:"short,omitempty"` // 短链接
+	Stats   Stats     `json:"stats"`
+}
+
+// Valid 链接是否仍可访问
+func (l Link) Valid(now time.Time) bool {
+	return l.Expires.IsZero() || now.Before(l.Expires)
+}
+
+// Stats 访问统计
+type Stats struct {
+	Hits      int                `json:"hits"`      // 访问次数(含HEAD等不传输内容的请求)
+	Downloads int                `json:"downloads"` // 传输内容的请求
+	Completed int                `json:"completed"` // 传输到文件末尾的请求
+	Aborted   int                `json:"aborted"`   // 传输中途断开的请求
+	Bytes     int64              `json:"bytes"`
+	First     time.Time          `json:"first,omitempty"`
+	Last      time.Time          `json:"last,omitempty"`
+	Devices   map[string]*Device `json:"devices"`
+	Agents    map[string]int     `json:"agents"`    // 按客户端(浏览器/系统)
+	Referrers map[string]int     `json:"referrers"` // 按来源站点，direct为直接打开
+}
+
+// Delivered 是否至少有一次完整下载
+func (s Stats) Delivered() bool {
+	return s.Completed > 0
+}
+
+// Device 单个设备的访问情况
+type Device struct {
+	Addr      string    `json:"addr"`
+	Agent     string    `json:"agent"`
+	Hits      int       `json:"hits"`
+	Completed int       `json:"completed"`
+	Bytes     int64     `json:"bytes"`
+	First     time.Time `json:"first"`
+	Last      time.Time `json:"last"`
+}
+
+// Access 一次访问
+type Access struct {
+	Time      time.Time
+	Device    string // 设备标识，如已配对设备ID或客户端地址
+	Addr      string
+	UserAgent string
+	Referrer  string
+	Download  bool  // 是否传输了文件内容
+	Bytes     int64 // 实际传输的字节数
+	Complete  bool  // 是否完整传输到文件末尾
+	Aborted   bool  // 传输是否中途断开
+}
+
+// Store 分享链接存储，持久化到文件
+type Store struct {
+	mu    sync.Mutex
+	file  string
+	links map[string]*Link
+}
+
+// OpenStore 打开存储文件，文件不存在时创建空存储
+func OpenStore(file string) *Store {
+	s := &Store{file: file, links: make(map[string]*Link)}
+	var list []*Link
+	if b, err := ioutil.ReadFile(file); err == nil {
+		_ = json.Unmarshal(b, &list)
+	}
+	for _, l := range list {
+		s.links[l.ID] = l
+	}
+	return s
+}
+
+// Create 为共享目录下的文件rel创建分享链接，ttl为0时不过期
+func (s *Store) Create(rel string, ttl time.Duration) (Link, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return Link{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(&Link{ID: hex.EncodeToString(b), Path: path.Clean("/" + rel)}, ttl)
+}
+
+// Short 为文件rel创建短链接(如 /s/ab3xk7m2qp)，已有该文件同样不过期、或剩余有效期超过ttl一半的短链接时直接返回已有的
+func (s *Store) Short(rel string, ttl time.Duration) (Link, error) {
+	rel = path.Clean("/" + rel)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, l := range s.links {
+		if !l.Short || l.Path != rel {
+			continue
+		}
+		if ttl == 0 && l.Expires.IsZero() || ttl > 0 && !l.Expires.IsZero() && l.Expires.Sub(now) > ttl/2 {
+			return l.copy(), nil
+		}
+	}
+	for n := 0; ; n++ {
+		id, err := shortID(ShortLen + n/8)
+		if err != nil {
+			return Link{}, err
+		}
+		if _, ok := s.links[id]; !ok {
+			return s.add(&Link{ID: id, Path: rel, Short: true}, ttl)
+		}
+	}
+}
+
+// add 加入并持久化新链接，调用方持有锁
+func (s *Store) add(l *Link, ttl time.Duration) (Link, error) {
+	l.Created = time.Now()
+	if ttl > 0 {
+		l.Expires = l.Created.Add(ttl)
+	}
+	s.links[l.ID] = l
+	return l.copy(), s.save()
+}
+
+// shortID n个短链接字符，丢弃超出字母表长度整数倍的随机字节，各字符出现概率相同
+func shortID(n int) (string, error) {
+	const limit = 256 - 256%len(shortAlphabet)
+	id := make([]byte, 0, n)
+	b := make([]byte, n)
+	for len(id) < n {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for _, c := range b {
+			if int(c) < limit && len(id) < n {
+				id = append(id, shortAlphabet[int(c)%len(shortAlphabet)])
+			}
+		}
+	}
+	return string(id), nil
+}
+
+// Get 获取分享链接
+func (s *Store) Get(id string) (Link, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.links[id]
+	if !ok {
+		return Link{}, false
+	}
+	return l.copy(), true
+}
+
+// Delete 删除分享链接
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[id]; !ok {
+		return ErrNoEntry
+	}
+	delete(s.links, id)
+	return s.save()
+}
+
+// List 所有分享链接，按创建时间排序
+func (s *Store) List() []Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Link, 0, len(s.links))
+	for _, l := range s.links {
+		list = append(list, l.copy())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+// Record 记录一次访问
+func (s *Store) Record(id string, a Access) error {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.links[id]
+	if !ok {
+		return ErrNoEntry
+	}
+	st := &l.Stats
+	if st.Devices == nil {
+		st.Devices = make(map[string]*Device)
+	}
+	if st.Agents == nil {
+		st.Agents = make(map[string]int)
+	}
+	if st.Referrers == nil {
+		st.Referrers = make(map[string]int)
+	}
+	if st.First.IsZero() {
+		st.First = a.Time
+	}
+	st.Last = a.Time
+	st.Hits++
+	d := st.Devices[a.Device]
+	if d == nil {
+		d = &Device{First: a.Time}
+		st.Devices[a.Device] = d
+		// 客户端与来源按设备计数，同一设备分段下载不重复计入
+		st.Agents[Agent(a.UserAgent)]++
+		st.Referrers[Referrer(a.Referrer)]++
+	}
+	d.Addr, d.Agent, d.Last = a.Addr, Agent(a.UserAgent), a.Time
+	d.Hits++
+	if a.Download {
+		st.Downloads++
+		st.Bytes += a.Bytes
+		d.Bytes += a.Bytes
+		if a.Complete {
+			st.Completed++
+			d.Completed++
+		}
+		if a.Aborted {
+			st.Aborted++
+		}
+	}
+	return s.save()
+}
+
+// copy 深拷贝，避免调用方读取时与Record并发修改map
+func (l *Link) copy() Link {
+	c := *l
+	c.Stats.Devices = make(map[string]*Device, len(l.Stats.Devices))
+	for k, d := range l.Stats.Devices {
+		dc := *d
+		c.Stats.Devices[k] = &dc
+	}
+	c.Stats.Agents = make(map[string]int, len(l.Stats.Agents))
+	for k, v := range l.Stats.Agents {
+		c.Stats.Agents[k] = v
+	}
+	c.Stats.Referrers = make(map[string]int, len(l.Stats.Referrers))
+	for k, v := range l.Stats.Referrers {
+		c.Stats.Referrers[k] = v
+	}
+	return c
+}
+
+// save 持久化到文件，原子替换文件
+func (s *Store) save() error {
+	if s.file == "" {
+		return nil
+	}
+	list := make([]*Link, 0, len(s.links))
+	for _, l := range s.links {
+		list = append(list, l)
+	}
+	return fsync.WriteJSON(s.file, list, 0644)
+}
+
+// Agent 由User-Agent归类客户端，如 Chrome/Windows、Safari/iOS、curl
+func Agent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "unknown"
+	}
+	// 命令行与下载工具
+	for _, tool := range []string{"curl", "wget", "aria2", "b0pass", "python-requests", "go-http-client"} {
+		if strings.HasPrefix(ua, tool) {
+			return tool
+		}
+	}
+	browser := "other"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "micromessenger"):
+		browser = "WeChat"
+	case strings.Contains(ua, "firefox/"), strings.Contains(ua, "fxios/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"), strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	}
+	system := "other"
+	switch {
+	case strings.Contains(ua, "android"):
+		system = "Android"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"):
+		system = "iOS"
+	case strings.Contains(ua, "windows"):
+		system = "Windows"
+	case strings.Contains(ua, "mac os"):
+		system = "macOS"
+	case strings.Contains(ua, "linux"):
+		system = "Linux"
+	}
+	return browser + "/" + system
+}
+
+// Referrer 来源站点，直接打开时为direct
+func Referrer(referer string) string {
+	u, err := url.Parse(referer)
+	if referer == "" || err != nil || u.Host == "" {
+		return "direct"
+	}
+	return strings.ToLower(u.Host)
+}
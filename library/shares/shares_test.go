@@ -0,0 +1,126 @@
+package shares
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "shares")
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "shares.json")
+
+	s := OpenStore(file)
+	l, err := s.Create("docs/../a.zip", time.Hour)
+	if err != nil || l.Path != "/a.zip" || !l.Valid(time.Now()) || l.Valid(time.Now().Add(2*time.Hour)) {
+		t.Fatal(l, err)
+	}
+	chrome := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	accesses := []Access{
+		{Device: "phone", UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile Safari/604.1", Referrer: "https://chat.example.com/x"},
+		{Device: "phone", Download: true, Bytes: 40, Aborted: true},
+		{Device: "phone", Download: true, Bytes: 0},
+		{Device: "laptop", UserAgent: chrome, Download: true, Bytes: 100, Complete: true},
+		{Device: "laptop", UserAgent: chrome, Download: true, Bytes: 60, Complete: true},
+	}
+	for _, a := range accesses {
+		if err := s.Record(l.ID, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Record("nope", Access{}); err != ErrNoEntry {
+		t.Error(err)
+	}
+
+	s = OpenStore(file)
+	got, ok := s.Get(l.ID)
+	st := got.Stats
+	if !ok || st.Hits != 5 || st.Downloads != 4 || st.Completed != 2 || st.Aborted != 1 || st.Bytes != 200 || !st.Delivered() {
+		t.Fatalf("%+v", st)
+	}
+	if len(st.Devices) != 2 || st.Devices["laptop"].Completed != 2 || st.Devices["phone"].Bytes != 40 {
+		t.Error(st.Devices)
+	}
+	if st.Agents["Safari/iOS"] != 1 || st.Agents["Chrome/Windows"] != 1 || len(st.Agents) != 2 {
+		t.Error(st.Agents)
+	}
+	if st.Referrers["chat.example.com"] != 1 || st.Referrers["direct"] != 1 {
+		t.Error(st.Referrers)
+	}
+	if err := s.Delete(l.ID); err != nil || len(s.List()) != 0 {
+		t.Error(err)
+	}
+}
+
+func TestShort(t *testing.T) {
+	s := OpenStore("")
+	l, err := s.Short("/photos/a.jpg", 0)
+	if err != nil || !l.Short || len(l.ID) != ShortLen || strings.Trim(l.ID, shortAlphabet) != "" {
+		t.Fatal(l, err)
+	}
+	// 同样不过期、或剩余有效期超过一半的短链接重复使用
+	if again, _ := s.Short("photos/a.jpg", 0); again.ID != l.ID {
+		t.Error("not reused", again.ID)
+	}
+	timed, _ := s.Short("/photos/a.jpg", time.Hour)
+	if timed.ID == l.ID || timed.Expires.IsZero() {
+		t.Error("timed", timed)
+	}
+	if again, _ := s.Short("/photos/a.jpg", time.Hour); again.ID != timed.ID {
+		t.Error("timed not reused", again.ID)
+	}
+	if longer, _ := s.Short("/photos/a.jpg", 4*time.Hour); longer.ID == timed.ID {
+		t.Error("reused a link that expires too soon")
+	}
+	// 大量创建时ID不重复
+	for i := 0; i < 2000; i++ {
+		if _, err := s.Short(fmt.Sprintf("/f%d", i), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.List()) != 2003 {
+		t.Error(len(s.List()))
+	}
+}
+
+func TestGuard(t *testing.T) {
+	g := &Guard{Max: 3, Window: time.Minute}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if g.Blocked("a", now) {
+			t.Fatal("blocked early", i)
+		}
+		g.Miss("a", now)
+	}
+	if !g.Blocked("a", now) || g.Blocked("b", now) {
+		t.Error("misses not counted per client")
+	}
+	if g.Blocked("a", now.Add(time.Minute)) {
+		t.Error("still blocked after the window")
+	}
+	g.Miss("a", now.Add(time.Minute))
+	if g.Blocked("a", now.Add(time.Minute)) || len(g.misses) != 1 {
+		t.Error("window not reset", g.misses)
+	}
+}
+
+func TestAgent(t *testing.T) {
+	cases := map[string]string{
+		"":            "unknown",
+		"curl/8.4.0":  "curl",
+		"Wget/1.21.4": "wget",
+		"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36":            "Chrome/Android",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7; rv:121.0) Gecko/20100101 Firefox/121.0":          "Firefox/macOS",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36 Edg/120": "Edge/Windows",
+	}
+	for ua, want := range cases {
+		if got := Agent(ua); got != want {
+			t.Errorf("%q: %s, want %s", ua, got, want)
+		}
+	}
+}
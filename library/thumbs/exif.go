@@ -0,0 +1,159 @@
+package thumbs
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// EXIF标记
+const (
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+)
+
+// Exif 图片的EXIF信息
+type Exif struct {
+	Orientation int       // 方向(1~8)，没有时为1
+	Taken       time.Time // 拍摄时间(DateTimeOriginal，没有时为DateTime)，没有时区信息时按本地时间，没有时为零值
+}
+
+// ReadExif 读取JPEG开头的EXIF信息，其他格式或无法读取时Orientation为1
+func ReadExif(file string) Exif {
+	fd, err := os.Open(file)
+	if err != nil {
+		return Exif{Orientation: 1}
+	}
+	defer func() { _ = fd.Close() }()
+	head := make([]byte, 64<<10)
+	n, _ := io.ReadFull(fd, head)
+	return parseExif(head[:n])
+}
+
+// Orientation 读取JPEG的EXIF方向(1~8)，没有或无法读取时为1
+func Orientation(file string) int {
+	return ReadExif(file).Orientation
+}
+
+// parseExif 在JPEG开头的APP1(Exif)段中查找EXIF信息
+func parseExif(b []byte) Exif {
+	if len(b) < 4 || b[0] != 0xFF || b[1] != 0xD8 {
+		return Exif{Orientation: 1}
+	}
+	for i := 2; i+4 <= len(b) && b[i] == 0xFF; {
+		marker, size := b[i+1], int(binary.BigEndian.Uint16(b[i+2:]))
+		if marker == 0xDA || size < 2 || i+2+size > len(b) {
+			break
+		}
+		seg := b[i+4 : i+2+size]
+		if marker == 0xE1 && len(seg) > 14 && string(seg[:6]) == "Exif\x00\x00" {
+			return parseTIFF(seg[6:])
+		}
+		i += 2 + size
+	}
+	return Exif{Orientation: 1}
+}
+
+// tiff TIFF结构中的IFD读取
+type tiff struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+// parseTIFF 读取第一个IFD中的方向与时间，以及Exif子IFD中的拍摄时间
+func parseTIFF(b []byte) Exif {
+	e := Exif{Orientation: 1}
+	if len(b) < 8 {
+		return e
+	}
+	t := tiff{b: b}
+	switch string(b[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return e
+	}
+	var dateTime, original, offset string
+	sub := 0
+	t.each(int(t.order.Uint32(b[4:])), func(tag uint16, entry []byte) {
+		switch tag {
+		case tagOrientation:
+			if v := int(t.order.Uint16(entry[8:])); v >= 1 && v <= 8 {
+				e.Orientation = v
+			}
+		case tagDateTime:
+			dateTime = t.ascii(entry)
+		case tagExifIFD:
+			sub = int(t.order.Uint32(entry[8:]))
+		}
+	})
+	if sub > 0 {
+		t.each(sub, func(tag uint16, entry []byte) {
+			switch tag {
+			case tagDateTimeOriginal:
+				original = t.ascii(entry)
+			case tagOffsetTimeOriginal:
+				offset = t.ascii(entry)
+			}
+		})
+	}
+	if original == "" {
+		original, offset = dateTime, ""
+	}
+	e.Taken = exifTime(original, offset)
+	return e
+}
+
+// each 依次处理IFD中的各项(12字节: 标记、类型、数量、值或偏移)
+func (t tiff) each(off int, fn func(tag uint16, entry []byte)) {
+	if off <= 0 || off+2 > len(t.b) {
+		return
+	}
+	count := int(t.order.Uint16(t.b[off:]))
+	for i := 0; i < count; i++ {
+		e := off + 2 + i*12
+		if e+12 > len(t.b) {
+			return
+		}
+		fn(t.order.Uint16(t.b[e:]), t.b[e:e+12])
+	}
+}
+
+// ascii 读取ASCII类型的值，不超过4字节时直接保存在项中
+func (t tiff) ascii(entry []byte) string {
+	if t.order.Uint16(entry[2:]) != 2 {
+		return ""
+	}
+	n := int(t.order.Uint32(entry[4:]))
+	var v []byte
+	if n <= 4 {
+		v = entry[8 : 8+n]
+	} else if off := int(t.order.Uint32(entry[8:])); off >= 0 && off+n <= len(t.b) {
+		v = t.b[off : off+n]
+	}
+	return strings.TrimRight(string(v), "\x00 ")
+}
+
+// exifTime 解析EXIF时间(2006:01:02 15:04:05)，offset为时区(如+08:00)
+func exifTime(s, offset string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", s+offset); err == nil {
+			return t
+		}
+	}
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", s, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,109 @@
+package thumbs
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Fit 将宽高为sw×sh的图片缩放到w×h以内(保持比例，不放大)后的大小，w或h为0时不限制该方向
+func Fit(sw, sh, w, h int) (int, int) {
+	if sw <= 0 || sh <= 0 {
+		return 0, 0
+	}
+	scale := 1.0
+	if w > 0 && float64(w)/float64(sw) < scale {
+		scale = float64(w) / float64(sw)
+	}
+	if h > 0 && float64(h)/float64(sh) < scale {
+		scale = float64(h) / float64(sh)
+	}
+	dw, dh := int(float64(sw)*scale+0.5), int(float64(sh)*scale+0.5)
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+	return dw, dh
+}
+
+// Scale 按区域平均缩小图片到w×h以内，透明部分以白色填充
+func Scale(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dw, dh := Fit(sw, sh, w, h)
+	src, ok := img.(*image.RGBA)
+	if !ok || src.Rect.Min != (image.Point{}) {
+		// 标准库对JPEG(YCbCr)、PNG(NRGBA)等有转换的快速路径
+		src = image.NewRGBA(image.Rect(0, 0, sw, sh))
+		draw.Draw(src, src.Rect, img, b.Min, draw.Src)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*sh/dh, (dy+1)*sh/dh
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*sw/dw, (dx+1)*sw/dw
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a uint64
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride+x0*4 : y*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += uint64(row[i])
+					g += uint64(row[i+1])
+					bl += uint64(row[i+2])
+					a += uint64(row[i+3])
+				}
+			}
+			n := uint64((x1 - x0) * (y1 - y0))
+			// 预乘alpha的颜色叠加到白色背景
+			white := 255*n - a
+			o := dy*dst.Stride + dx*4
+			dst.Pix[o] = uint8((r + white) / n)
+			dst.Pix[o+1] = uint8((g + white) / n)
+			dst.Pix[o+2] = uint8((bl + white) / n)
+			dst.Pix[o+3] = 255
+		}
+	}
+	return dst
+}
+
+// Orient 按EXIF方向旋转或翻转图片，使其正向显示
+func Orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var nx, ny int
+			switch orientation {
+			case 2: // 水平翻转
+				nx, ny = w-1-x, y
+			case 3: // 旋转180°
+				nx, ny = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				nx, ny = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				nx, ny = y, x
+			case 6: // 顺时针旋转90°
+				nx, ny = h-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				nx, ny = h-1-y, w-1-x
+			case 8: // 逆时针旋转90°
+				nx, ny = y, w-1-x
+			}
+			copy(dst.Pix[ny*dst.Stride+nx*4:ny*dst.Stride+nx*4+4], img.Pix[y*img.Stride+x*4:y*img.Stride+x*4+4])
+		}
+	}
+	return dst
+}
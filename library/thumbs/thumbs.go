@@ -0,0 +1,247 @@
+package thumbs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 图片缩略图: 按扩展名选择解码器，缩放后以JPEG保存在缓存目录，源文件变化后重新生成
+
+var (
+	// ErrUnsupported 没有对应格式的解码器
+	ErrUnsupported = errors.New("thumbs: unsupported image format")
+	// ErrTooLarge 源图片像素数超过上限
+	ErrTooLarge = errors.New("thumbs: image too large")
+)
+
+// Decoder 解码图片文件
+type Decoder func(file string) (image.Image, error)
+
+var (
+	decMu    sync.RWMutex
+	decoders = map[string]Decoder{"jpg": decodeFile, "jpeg": decodeFile, "png": decodeFile, "gif": decodeFile}
+)
+
+// Register 注册扩展名(小写，不含点)的解码器，覆盖已有的
+func Register(ext string, d Decoder) {
+	decMu.Lock()
+	defer decMu.Unlock()
+	decoders[strings.ToLower(strings.TrimPrefix(ext, "."))] = d
+}
+
+// decoder 文件名对应的解码器
+func decoder(name string) Decoder {
+	decMu.RLock()
+	defer decMu.RUnlock()
+	return decoders[strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))]
+}
+
+// Supported 是否能为该文件生成缩略图
+func Supported(name string) bool {
+	return decoder(name) != nil
+}
+
+// decodeFile 标准库支持的格式(JPEG、PNG、GIF)
+func decodeFile(file string) (image.Image, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fd.Close() }()
+	img, _, err := image.Decode(fd)
+	return img, err
+}
+
+// CommandTimeout 外部命令解码的超时时间
+var CommandTimeout = 30 * time.Second
+
+// Command 外部命令解码器，args中的{file}替换为源文件路径，命令向标准输出写出PNG或JPEG
+// 如 Command("ffmpeg", "-v", "error", "-i", "{file}", "-frames:v", "1", "-f", "image2pipe", "-vcodec", "png", "-")
+func Command(name string, args ...string) Decoder {
+	return func(file string) (image.Image, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+		defer cancel()
+		a := make([]string, len(args))
+		for i, arg := range args {
+			a[i] = strings.Replace(arg, "{file}", file, -1)
+		}
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, name, a...)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v %s", name, err, strings.TrimSpace(stderr.String()))
+		}
+		img, _, err := image.Decode(bytes.NewReader(out))
+		return img, err
+	}
+}
+
+// Cache 缩略图缓存
+type Cache struct {
+	written int64 // 上次清理后新写入的字节数，原子操作在32位平台要求8字节对齐，放在最前
+	pruning int32
+
+	Dir       string // 缓存目录
+	MaxBytes  int64  // 缓存总大小上限，超过时删除最久未使用的缩略图，为0时不限制
+	MaxPixels int64  // 源图片的像素数上限(仅标准库支持的格式在解码前检查)，为0时不限制
+	Quality   int    // JPEG质量，为0时为80
+
+	mu      sync.Mutex
+	pending map[string]*call
+}
+
+// call 正在生成的缩略图，同一缩略图的并发请求等待同一次生成
+type call struct {
+	done chan struct{}
+	err  error
+}
+
+// Get 返回src缩放到w×h以内(保持比例，不放大)的缩略图文件，已缓存且源文件未变化时直接返回
+func (c *Cache) Get(src string, w, h int) (string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+	dec := decoder(src)
+	if dec == nil || info.IsDir() {
+		return "", ErrUnsupported
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%dx%d", src, info.Size(), info.ModTime().UnixNano(), w, h)))
+	key := hex.EncodeToString(sum[:])
+	file := filepath.Join(c.Dir, key[:2], key+".jpg")
+	if _, err := os.Stat(file); err == nil {
+		// 修改时间作为最近使用时间，清理时保留常用的缩略图
+		now := time.Now()
+		_ = os.Chtimes(file, now, now)
+		return file, nil
+	}
+
+	c.mu.Lock()
+	if c.pending == nil {
+		c.pending = make(map[string]*call)
+	}
+	if p, ok := c.pending[key]; ok {
+		c.mu.Unlock()
+		<-p.done
+		return file, p.err
+	}
+	p := &call{done: make(chan struct{})}
+	c.pending[key] = p
+	c.mu.Unlock()
+
+	p.err = c.generate(dec, src, file, w, h)
+	c.mu.Lock()
+	delete(c.pending, key)
+	c.mu.Unlock()
+	close(p.done)
+	return file, p.err
+}
+
+// generate 解码、缩放并写入缓存文件
+func (c *Cache) generate(dec Decoder, src, file string, w, h int) error {
+	if c.MaxPixels > 0 {
+		if fd, err := os.Open(src); err == nil {
+			cfg, _, err := image.DecodeConfig(fd)
+			_ = fd.Close()
+			if err == nil && int64(cfg.Width)*int64(cfg.Height) > c.MaxPixels {
+				return ErrTooLarge
+			}
+		}
+	}
+	img, err := dec(src)
+	if err != nil {
+		return err
+	}
+	// 旋转90°的照片缩放时宽高限制互换
+	o := Orientation(src)
+	if o >= 5 {
+		w, h = h, w
+	}
+	thumb := Orient(Scale(img, w, h), o)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".thumb-*")
+	if err != nil {
+		return err
+	}
+	quality := c.Quality
+	if quality <= 0 {
+		quality = 80
+	}
+	err = jpeg.Encode(tmp, thumb, &jpeg.Options{Quality: quality})
+	info, _ := tmp.Stat()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if info != nil && c.MaxBytes > 0 && atomic.AddInt64(&c.written, info.Size()) > c.MaxBytes/20 {
+		atomic.StoreInt64(&c.written, 0)
+		if atomic.CompareAndSwapInt32(&c.pruning, 0, 1) {
+			go func() {
+				defer atomic.StoreInt32(&c.pruning, 0)
+				_ = c.Prune()
+			}()
+		}
+	}
+	return nil
+}
+
+// Prune 缓存超过MaxBytes时删除最久未使用的缩略图，直到不超过上限的90%
+func (c *Cache) Prune() error {
+	if c.MaxBytes <= 0 {
+		return nil
+	}
+	type item struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var items []item
+	var total int64
+	err := filepath.Walk(c.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(p, ".jpg") {
+			return nil
+		}
+		items = append(items, item{p, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil || total <= c.MaxBytes {
+		return err
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].used.Before(items[j].used) })
+	for _, it := range items {
+		if total <= c.MaxBytes*9/10 {
+			break
+		}
+		if os.Remove(it.path) == nil {
+			total -= it.size
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package thumbs
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writePNG(t *testing.T, file string, w, h int) {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// 左半红色不透明，右半完全透明
+			if x < w/2 {
+				img.Set(x, y, color.NRGBA{255, 0, 0, 255})
+			}
+		}
+	}
+	fd, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = fd.Close() }()
+	if err := png.Encode(fd, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodeJPEG(t *testing.T, file string) image.Image {
+	fd, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = fd.Close() }()
+	img, err := jpeg.Decode(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestFit(t *testing.T) {
+	cases := [][6]int{
+		{4000, 3000, 256, 256, 256, 192},
+		{3000, 4000, 256, 0, 256, 341},
+		{100, 50, 256, 256, 100, 50}, // 不放大
+		{10000, 1, 100, 100, 100, 1},
+	}
+	for _, c := range cases {
+		if w, h := Fit(c[0], c[1], c[2], c[3]); w != c[4] || h != c[5] {
+			t.Errorf("%v: %dx%d", c, w, h)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thumbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	src := filepath.Join(dir, "a.png")
+	writePNG(t, src, 400, 200)
+	c := &Cache{Dir: filepath.Join(dir, ".thumbs")}
+
+	var wg sync.WaitGroup
+	files := make([]string, 4)
+	for i := range files {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f, err := c.Get(src, 100, 100)
+			if err != nil {
+				t.Error(err)
+			}
+			files[i] = f
+		}(i)
+	}
+	wg.Wait()
+	if files[0] == "" || files[0] != files[3] {
+		t.Fatal(files)
+	}
+	img := decodeJPEG(t, files[0])
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Fatal(b)
+	}
+	// 透明部分填充白色
+	if r, g, _, _ := img.At(10, 25).RGBA(); r>>8 < 200 || g>>8 > 60 {
+		t.Error("left", r>>8, g>>8)
+	}
+	if r, g, b, _ := img.At(90, 25).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Error("right", r>>8, g>>8, b>>8)
+	}
+
+	// 源文件变化后重新生成
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(src, later, later)
+	if f, err := c.Get(src, 100, 100); err != nil || f == files[0] {
+		t.Error("changed", f, err)
+	}
+	if _, err := c.Get(filepath.Join(dir, "a.txt"), 100, 100); err == nil {
+		t.Error("unsupported")
+	}
+	c.MaxPixels = 1000
+	if _, err := c.Get(src, 50, 50); err != ErrTooLarge {
+		t.Error("max pixels", err)
+	}
+
+	// 超过上限时删除最久未使用的缩略图
+	c.MaxBytes = 1
+	if err := c.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Error("prune", err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("cat")
+	}
+	dir, err := ioutil.TempDir("", "thumbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	// 外部命令输出PNG即可，这里用cat模拟转换工具
+	Register("webp", Command("cat", "{file}"))
+	defer Register("webp", nil)
+	src := filepath.Join(dir, "b.webp")
+	writePNG(t, src, 64, 64)
+	if !Supported("B.WEBP") {
+		t.Fatal("supported")
+	}
+	f, err := (&Cache{Dir: dir}).Get(src, 32, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := decodeJPEG(t, f).Bounds(); b.Dx() != 32 {
+		t.Error(b)
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	// 最小的JPEG开头: SOI + APP1(Exif, 大端, IFD0中一个方向标记=6)
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, 6, 0, 0, 0, 0, 0, 0}
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	b := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0, byte(len(seg) + 2)}, seg...)
+	b = append(b, 0xFF, 0xDA, 0, 2)
+	if e := parseExif(b); e.Orientation != 6 || !e.Taken.IsZero() {
+		t.Fatal(e)
+	}
+	if e := parseExif([]byte{0x89, 'P', 'N', 'G'}); e.Orientation != 1 {
+		t.Error(e)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	rot := Orient(img, 6)
+	if b := rot.Bounds(); b.Dx() != 2 || b.Dy() != 3 {
+		t.Fatal(b)
+	}
+	// 顺时针旋转90°后左上角移到右上角
+	if r, _, _, _ := rot.At(1, 0).RGBA(); r>>8 != 255 {
+		t.Error(rot.Pix)
+	}
+}
+
+func TestExifTime(t *testing.T) {
+	// 小端TIFF: IFD0(方向=3、Exif子IFD指针) -> 子IFD(DateTimeOriginal、OffsetTimeOriginal)
+	le := binary.LittleEndian
+	b := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	ifd := func(entries [][]byte) {
+		b = append(b, byte(len(entries)), 0)
+		for _, e := range entries {
+			b = append(b, e...)
+		}
+		b = append(b, 0, 0, 0, 0)
+	}
+	entry := func(tag, typ uint16, count, value uint32) []byte {
+		e := make([]byte, 12)
+		le.PutUint16(e, tag)
+		le.PutUint16(e[2:], typ)
+		le.PutUint32(e[4:], count)
+		le.PutUint32(e[8:], value)
+		return e
+	}
+	sub := uint32(8 + 2 + 2*12 + 4)
+	ifd([][]byte{entry(tagOrientation, 3, 1, 3), entry(tagExifIFD, 4, 1, sub)})
+	data := sub + 2 + 2*12 + 4
+	ifd([][]byte{entry(tagDateTimeOriginal, 2, 20, data), entry(tagOffsetTimeOriginal, 2, 7, data+20)})
+	b = append(b, "2024:05:01 08:30:00\x00+08:00\x00"...)
+
+	e := parseTIFF(b)
+	if e.Orientation != 3 {
+		t.Error(e.Orientation)
+	}
+	if want := time.Date(2024, 5, 1, 0, 30, 0, 0, time.UTC); !e.Taken.Equal(want) {
+		t.Error(e.Taken)
+	}
+	if tm := exifTime("2024:05:01 08:30:00", ""); tm.Location() != time.Local || tm.Hour() != 8 {
+		t.Error(tm)
+	}
+	if tm := exifTime("0000:00:00 00:00:00", ""); !tm.IsZero() {
+		t.Error(tm)
+	}
+}
@@ -0,0 +1,170 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 视频在线播放: 用ffprobe识别编码，浏览器能直接播放的原样输出(支持Range)，
+// 编码可播放但封装不支持的(如mkv中的H.264)只转封装，其余(如HEVC)用ffmpeg实时转码为H.264/AAC
+
+// 播放方式
+const (
+	Direct    = "direct"    // 原文件直接播放
+	Remux     = "remux"     // 复制音视频流，转封装为分片MP4
+	Transcode = "transcode" // 重新编码为H.264/AAC
+)
+
+var (
+	// 浏览器普遍支持的视频、音频编码(ffprobe的codec_name)
+	playableVideo = map[string]bool{"h264": true, "vp8": true, "vp9": true, "av1": true}
+	playableAudio = map[string]bool{"aac": true, "mp3": true, "opus": true, "vorbis": true, "flac": true}
+	// 浏览器普遍支持的封装(扩展名)
+	playableExt = map[string]bool{".mp4": true, ".m4v": true, ".mov": true, ".webm": true}
+	// 输出的分片MP4中可直接复制的编码
+	mp4Video = map[string]bool{"h264": true, "vp9": true, "av1": true}
+	mp4Audio = map[string]bool{"aac": true, "mp3": true}
+)
+
+// Info 视频信息
+type Info struct {
+	Video    string  `json:"video"` // 视频编码，没有视频流时为空
+	Audio    string  `json:"audio"` // 第一个音频流的编码
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	Duration float64 `json:"duration"` // 秒
+	Mode     string  `json:"mode"`     // 播放方式
+}
+
+// Decide 按扩展名与编码选择播放方式
+func Decide(ext string, info Info) string {
+	if !playableVideo[info.Video] || info.Audio != "" && !playableAudio[info.Audio] {
+		return Transcode
+	}
+	if !playableExt[strings.ToLower(ext)] {
+		if !mp4Video[info.Video] || info.Audio != "" && !mp4Audio[info.Audio] {
+			return Transcode
+		}
+		return Remux
+	}
+	return Direct
+}
+
+// Prober ffprobe探测，按路径、大小与修改时间缓存结果
+type Prober struct {
+	Cmd     string        // ffprobe路径，为空时为ffprobe
+	Timeout time.Duration // 为0时为10秒
+
+	mu    sync.Mutex
+	cache map[string]Info
+}
+
+// Probe 探测视频编码并选择播放方式
+func (p *Prober) Probe(file string) (Info, error) {
+	st, err := os.Stat(file)
+	if err != nil {
+		return Info{}, err
+	}
+	key := fmt.Sprintf("%s\x00%d\x00%d", file, st.Size(), st.ModTime().UnixNano())
+	p.mu.Lock()
+	info, ok := p.cache[key]
+	p.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+	cmd, timeout := p.Cmd, p.Timeout
+	if cmd == "" {
+		cmd = "ffprobe"
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	c := exec.CommandContext(ctx, cmd, "-v", "error", "-show_format", "-show_streams", "-of", "json", file)
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return Info{}, fmt.Errorf("%s: %v %s", cmd, err, strings.TrimSpace(stderr.String()))
+	}
+	if info, err = parseProbe(out); err != nil {
+		return Info{}, err
+	}
+	info.Mode = Decide(filepath.Ext(file), info)
+	p.mu.Lock()
+	if p.cache == nil || len(p.cache) >= 1024 {
+		p.cache = make(map[string]Info)
+	}
+	p.cache[key] = info
+	p.mu.Unlock()
+	return info, nil
+}
+
+// parseProbe 解析ffprobe的JSON输出
+func parseProbe(b []byte) (Info, error) {
+	var out struct {
+		Streams []struct {
+			Type   string `json:"codec_type"`
+			Codec  string `json:"codec_name"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+			// 封面图片以视频流的形式出现
+			Disposition struct {
+				AttachedPic int `json:"attached_pic"`
+			} `json:"disposition"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return Info{}, err
+	}
+	var info Info
+	for _, s := range out.Streams {
+		switch {
+		case s.Type == "video" && info.Video == "" && s.Disposition.AttachedPic == 0:
+			info.Video, info.Width, info.Height = s.Codec, s.Width, s.Height
+		case s.Type == "audio" && info.Audio == "":
+			info.Audio = s.Codec
+		}
+	}
+	info.Duration, _ = strconv.ParseFloat(out.Format.Duration, 64)
+	return info, nil
+}
+
+// Args ffmpeg参数: 从start秒开始，转封装或转码为分片MP4写到标准输出
+// vargs为转码时的视频编码参数，为空时为 -c:v libx264 -preset veryfast -crf 23
+func Args(mode, file string, start float64, info Info, vargs []string) []string {
+	args := []string{"-v", "error", "-nostdin"}
+	if start > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(start, 'f', 3, 64))
+	}
+	args = append(args, "-i", file, "-map", "0:v:0?", "-map", "0:a:0?", "-sn", "-dn")
+	if mode == Transcode && !mp4Video[info.Video] {
+		if len(vargs) == 0 {
+			vargs = []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "23"}
+		}
+		// 浏览器只支持8位4:2:0的H.264
+		args = append(args, vargs...)
+		args = append(args, "-pix_fmt", "yuv420p")
+	} else {
+		args = append(args, "-c:v", "copy")
+	}
+	if info.Audio == "" || mp4Audio[info.Audio] {
+		args = append(args, "-c:a", "copy")
+	} else {
+		args = append(args, "-c:a", "aac", "-b:a", "160k", "-ac", "2")
+	}
+	return append(args, "-movflags", "frag_keyframe+empty_moov+default_base_moof", "-f", "mp4", "-")
+}
@@ -0,0 +1,66 @@
+package transcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecide(t *testing.T) {
+	cases := []struct {
+		ext   string
+		video string
+		audio string
+		mode  string
+	}{
+		{".mp4", "h264", "aac", Direct},
+		{".MOV", "h264", "", Direct},
+		{".webm", "vp9", "opus", Direct},
+		{".mkv", "h264", "aac", Remux},
+		{".mkv", "vp8", "vorbis", Transcode},
+		{".mp4", "hevc", "aac", Transcode},
+		{".mp4", "h264", "ac3", Transcode},
+		{".avi", "mpeg4", "mp3", Transcode},
+	}
+	for _, c := range cases {
+		if mode := Decide(c.ext, Info{Video: c.video, Audio: c.audio}); mode != c.mode {
+			t.Errorf("%+v: %s", c, mode)
+		}
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	out := `{"streams":[
+		{"codec_type":"video","codec_name":"mjpeg","disposition":{"attached_pic":1}},
+		{"codec_type":"video","codec_name":"hevc","width":1920,"height":1080,"disposition":{"attached_pic":0}},
+		{"codec_type":"audio","codec_name":"aac"},
+		{"codec_type":"audio","codec_name":"ac3"}],
+		"format":{"duration":"12.480000"}}`
+	info, err := parseProbe([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Video != "hevc" || info.Audio != "aac" || info.Width != 1920 || info.Duration != 12.48 {
+		t.Errorf("%+v", info)
+	}
+	if _, err := parseProbe([]byte("not json")); err == nil {
+		t.Error("invalid output")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	args := strings.Join(Args(Transcode, "a.mkv", 30, Info{Video: "hevc", Audio: "ac3"}, nil), " ")
+	for _, want := range []string{"-ss 30.000 -i a.mkv", "-c:v libx264", "-pix_fmt yuv420p", "-c:a aac", "-f mp4 -"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("%q missing %q", args, want)
+		}
+	}
+	args = strings.Join(Args(Remux, "a.mkv", 0, Info{Video: "h264", Audio: "aac"}, nil), " ")
+	if strings.Contains(args, "-ss") || !strings.Contains(args, "-c:v copy") || !strings.Contains(args, "-c:a copy") {
+		t.Error(args)
+	}
+	// 只有音频需要转码时复制视频流
+	args = strings.Join(Args(Transcode, "a.mp4", 0, Info{Video: "h264", Audio: "ac3"}, []string{"-c:v", "h264_v4l2m2m"}), " ")
+	if !strings.Contains(args, "-c:v copy") || !strings.Contains(args, "-c:a aac") {
+		t.Error(args)
+	}
+}
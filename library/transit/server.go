@@ -0,0 +1,131 @@
+package transit
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server 中转服务器: 按配对标识把两个连接配成一对并双向转发
+type Server struct {
+	// Wait 先到的一端等待另一端的时间
+	Wait time.Duration
+	// MaxPending 同时等待配对的连接数上限
+	MaxPending int
+	// Log 配对与转发结束时的日志，为空时不输出
+	Log func(format string, args ...interface{})
+
+	mu      sync.Mutex
+	pending map[string]*waiter
+}
+
+type waiter struct {
+	conn   net.Conn
+	paired chan struct{}
+}
+
+// NewServer 创建中转服务器
+func NewServer(wait time.Duration) *Server {
+	return &Server{Wait: wait, MaxPending: 1000, pending: make(map[string]*waiter)}
+}
+
+// Serve 接受连接直到监听关闭
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		s.Log(format, args...)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	line, err := readLine(conn, 128)
+	fields := strings.Fields(line)
+	if err != nil || len(fields) != 2 || fields[0] != Protocol || len(fields[1]) != 64 {
+		_, _ = io.WriteString(conn, "err bad handshake\n")
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+	channel := fields[1]
+
+	s.mu.Lock()
+	if peer, ok := s.pending[channel]; ok {
+		delete(s.pending, channel)
+		s.mu.Unlock()
+		close(peer.paired)
+		s.splice(channel, peer.conn, conn)
+		return
+	}
+	if len(s.pending) >= s.MaxPending {
+		s.mu.Unlock()
+		_, _ = io.WriteString(conn, "err busy\n")
+		_ = conn.Close()
+		return
+	}
+	w := &waiter{conn: conn, paired: make(chan struct{})}
+	s.pending[channel] = w
+	s.mu.Unlock()
+
+	select {
+	case <-w.paired:
+		// 连接已交给配对的一方转发
+	case <-time.After(s.Wait):
+		s.mu.Lock()
+		if s.pending[channel] == w {
+			delete(s.pending, channel)
+			s.mu.Unlock()
+			_, _ = io.WriteString(conn, "err timeout\n")
+			_ = conn.Close()
+			return
+		}
+		s.mu.Unlock()
+	}
+}
+
+// splice 通知两端已配对并双向转发，一个方向结束时关闭对端的写方向
+func (s *Server) splice(channel string, a, b net.Conn) {
+	defer func() { _ = a.Close() }()
+	defer func() { _ = b.Close() }()
+	_, errA := io.WriteString(a, "ok\n")
+	_, errB := io.WriteString(b, "ok\n")
+	if errA != nil || errB != nil {
+		return
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	var ab, ba int64
+	copyHalf := func(dst, src net.Conn, n *int64) {
+		defer wg.Done()
+		*n, _ = io.Copy(dst, src)
+		if c, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = c.CloseWrite()
+		} else {
+			_ = dst.Close()
+		}
+	}
+	wg.Add(2)
+	go copyHalf(b, a, &ab)
+	go copyHalf(a, b, &ba)
+	wg.Wait()
+	s.logf("[Relay] %s… %s <-> %s %d/%d bytes in %s", channel[:8], a.RemoteAddr(), b.RemoteAddr(), ab, ba,
+		time.Since(start).Round(time.Millisecond))
+}
+
+// Pending 等待配对的连接数
+func (s *Server) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
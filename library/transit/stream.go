@@ -0,0 +1,211 @@
+package transit
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 加密流: 每帧为4字节密文长度与AES-256-GCM密文，nonce为帧序号与结束标记，
+// 帧被重排、删除或截断时解密失败
+
+const frameSize = 64 << 10
+
+var ErrTampered = errors.New("transfer code mismatch or data tampered")
+
+// Header 文件信息，随第一帧发送
+type Header struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type ack struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func newAEAD(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+func nonce(n uint64, last bool) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b, n)
+	if last {
+		b[11] = 1
+	}
+	return b
+}
+
+type sealer struct {
+	w    io.Writer
+	aead cipher.AEAD
+	n    uint64
+}
+
+func (s *sealer) frame(p []byte, last bool) error {
+	out := make([]byte, 4, 4+len(p)+s.aead.Overhead())
+	out = s.aead.Seal(out, nonce(s.n, last), p, nil)
+	binary.BigEndian.PutUint32(out, uint32(len(out)-4))
+	s.n++
+	_, err := s.w.Write(out)
+	return err
+}
+
+type opener struct {
+	r    io.Reader
+	aead cipher.AEAD
+	n    uint64
+}
+
+func (o *opener) frame() ([]byte, bool, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(o.r, size[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, false, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > frameSize+uint32(o.aead.Overhead()) {
+		return nil, false, ErrTampered
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(o.r, b); err != nil {
+		return nil, false, io.ErrUnexpectedEOF
+	}
+	for _, last := range []bool{false, true} {
+		if p, err := o.aead.Open(nil, nonce(o.n, last), b, nil); err == nil {
+			o.n++
+			return p, last, nil
+		}
+	}
+	return nil, false, ErrTampered
+}
+
+// Session 一次配对中的加密会话，可依次传输多个文件
+type Session struct {
+	out *sealer
+	in  *opener
+}
+
+func newSession(conn io.ReadWriter, code string, sender bool) (*Session, error) {
+	sendKey, recvKey, err := keys(code)
+	if err != nil {
+		return nil, err
+	}
+	if !sender {
+		sendKey, recvKey = recvKey, sendKey
+	}
+	return &Session{out: &sealer{w: conn, aead: newAEAD(sendKey)}, in: &opener{r: conn, aead: newAEAD(recvKey)}}, nil
+}
+
+// Sender 发送端会话
+func Sender(conn io.ReadWriter, code string) (*Session, error) {
+	return newSession(conn, code, true)
+}
+
+// Receiver 接收端会话
+func Receiver(conn io.ReadWriter, code string) (*Session, error) {
+	return newSession(conn, code, false)
+}
+
+// Send 加密发送一个文件并等待接收端确认，返回发送的字节数
+func (s *Session) Send(h Header, body io.Reader) (int64, error) {
+	if h.Name == "" {
+		return 0, errors.New("empty file name")
+	}
+	head, _ := json.Marshal(h)
+	if err := s.out.frame(head, false); err != nil {
+		return 0, err
+	}
+	var sent int64
+	buf := make([]byte, frameSize)
+	for {
+		n, err := io.ReadFull(body, buf)
+		if n > 0 {
+			if werr := s.out.frame(buf[:n], false); werr != nil {
+				return sent, werr
+			}
+			sent += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return sent, err
+		}
+	}
+	if err := s.out.frame(nil, true); err != nil {
+		return sent, err
+	}
+	p, _, err := s.in.frame()
+	if err != nil {
+		return sent, fmt.Errorf("no confirmation from receiver: %v", err)
+	}
+	var a ack
+	if err := json.Unmarshal(p, &a); err != nil || !a.OK {
+		return sent, fmt.Errorf("receiver failed: %s", a.Error)
+	}
+	return sent, nil
+}
+
+// Close 发送端通知会话结束，接收端的Receive随后返回io.EOF
+func (s *Session) Close() error {
+	return s.out.frame(nil, true)
+}
+
+// Receive 接收并解密一个文件，open根据文件信息返回写入位置；发送端结束会话时返回io.EOF，
+// 其他错误时已写入的内容不完整，由调用方丢弃
+func (s *Session) Receive(open func(Header) (io.Writer, error)) (Header, int64, error) {
+	var h Header
+	p, last, err := s.in.frame()
+	if err != nil {
+		return h, 0, err
+	}
+	if last {
+		return h, 0, io.EOF
+	}
+	if err := json.Unmarshal(p, &h); err != nil || h.Name == "" {
+		return h, 0, ErrTampered
+	}
+	fail := func(err error) error {
+		b, _ := json.Marshal(ack{Error: err.Error()})
+		_ = s.out.frame(b, true)
+		return err
+	}
+	w, err := open(h)
+	if err != nil {
+		return h, 0, fail(err)
+	}
+	var got int64
+	for {
+		p, last, err := s.in.frame()
+		if err != nil {
+			return h, got, err
+		}
+		if last {
+			break
+		}
+		if _, err := w.Write(p); err != nil {
+			return h, got, fail(err)
+		}
+		got += int64(len(p))
+	}
+	if h.Size >= 0 && got != h.Size {
+		return h, got, fail(fmt.Errorf("size mismatch: got %d of %d bytes", got, h.Size))
+	}
+	b, _ := json.Marshal(ack{OK: true})
+	return h, got, s.out.frame(b, true)
+}
@@ -0,0 +1,124 @@
+package transit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// 公网中转: 不在同一网络的两端都主动连接用户自建的中转服务器(b0pass relay-server)，
+// 服务器按一次性传输码的哈希配对两个连接并原样转发；文件由两端用传输码派生的密钥加密，中转服务器无法解密
+// 传输码含100位随机数，服务器只能看到其哈希
+
+// Protocol 握手行的协议标识
+const Protocol = "b0pass-transit/1"
+
+var (
+	ErrCode     = errors.New("invalid transfer code")
+	ErrRejected = errors.New("relay rejected the connection")
+)
+
+// NewCode 生成传输码，如 K7QF-2MZP-XW4D-9RTA-C3JH
+func NewCode() string {
+	b := make([]byte, 13)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	s := base32.StdEncoding.EncodeToString(b)[:20]
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20]
+}
+
+// normalize 传输码不区分大小写与分隔符
+func normalize(code string) (string, error) {
+	code = strings.ToUpper(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+	if len(code) != 20 {
+		return "", ErrCode
+	}
+	if _, err := base32.StdEncoding.DecodeString(code + "===="); err != nil {
+		return "", ErrCode
+	}
+	return code, nil
+}
+
+// Channel 中转服务器上的配对标识(传输码的哈希)
+func Channel(code string) (string, error) {
+	code, err := normalize(code)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte("b0pass-transit-channel:" + code))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// keys 两个方向的加密密钥
+func keys(code string) (send, recv []byte, err error) {
+	code, err = normalize(code)
+	if err != nil {
+		return nil, nil, err
+	}
+	master := sha256.Sum256([]byte("b0pass-transit-key:" + code))
+	s := sha256.Sum256(append(master[:], "sender"...))
+	r := sha256.Sum256(append(master[:], "receiver"...))
+	return s[:], r[:], nil
+}
+
+// Dial 连接中转服务器并等待另一端，配对成功后返回连接
+func Dial(ctx context.Context, addr, code string) (net.Conn, error) {
+	channel, err := Channel(code)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	if _, err = fmt.Fprintf(conn, "%s %s\n", Protocol, channel); err == nil {
+		var line string
+		if line, err = readLine(conn, 128); err == nil && line != "ok" {
+			err = fmt.Errorf("%v: %s", ErrRejected, line)
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// readLine 逐字节读取一行(不预读，之后的数据留在连接中)
+func readLine(r io.Reader, max int) (string, error) {
+	var b []byte
+	c := make([]byte, 1)
+	for len(b) < max {
+		if _, err := io.ReadFull(r, c); err != nil {
+			return "", err
+		}
+		if c[0] == '\n' {
+			return string(b), nil
+		}
+		b = append(b, c[0])
+	}
+	return "", errors.New("line too long")
+}
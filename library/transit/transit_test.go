@@ -0,0 +1,144 @@
+package transit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	code := NewCode()
+	if len(code) != 24 || strings.Count(code, "-") != 4 {
+		t.Fatal(code)
+	}
+	a, err := Channel(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不区分大小写与分隔符
+	if b, _ := Channel(strings.ToLower(strings.Replace(code, "-", "", -1))); a != b {
+		t.Error("normalize", code)
+	}
+	if _, err := Channel("ABCD-1234"); err != ErrCode {
+		t.Error("short code", err)
+	}
+	if NewCode() == code {
+		t.Error("random")
+	}
+}
+
+func relay(t *testing.T) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(2 * time.Second)
+	go func() { _ = s.Serve(ln) }()
+	return ln.Addr().String(), func() { _ = ln.Close() }
+}
+
+func TestTransfer(t *testing.T) {
+	addr, stop := relay(t)
+	defer stop()
+	data := make([]byte, 3*frameSize+123)
+	rand.Read(data)
+
+	code := NewCode()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		conn, err := Dial(ctx, addr, code)
+		if err != nil {
+			done <- err
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		s, _ := Sender(conn, code)
+		if _, err = s.Send(Header{Name: "a.bin", Size: int64(len(data))}, bytes.NewReader(data)); err == nil {
+			if _, err = s.Send(Header{Name: "b.txt", Size: 99}, strings.NewReader("short")); err == nil {
+				err = errors.New("size mismatch not reported")
+			} else if _, err = s.Send(Header{Name: "c.txt", Size: -1}, strings.NewReader("c")); err == nil {
+				err = s.Close()
+			}
+		}
+		done <- err
+	}()
+	conn, err := Dial(ctx, addr, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	s, _ := Receiver(conn, code)
+	var files []string
+	var out bytes.Buffer
+	for {
+		out.Reset()
+		h, _, err := s.Receive(func(h Header) (io.Writer, error) { return &out, nil })
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			files = append(files, h.Name+":"+err.Error())
+			continue
+		}
+		if h.Name == "a.bin" && !bytes.Equal(out.Bytes(), data) || h.Name == "c.txt" && out.String() != "c" {
+			t.Error("content mismatch", h.Name)
+		}
+		files = append(files, h.Name)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 || files[0] != "a.bin" || !strings.HasPrefix(files[1], "b.txt:size mismatch") || files[2] != "c.txt" {
+		t.Error(files)
+	}
+
+	// 先到的一端超时
+	if _, err := Dial(ctx, addr, NewCode()); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Error("wait timeout", err)
+	}
+}
+
+type pipe struct {
+	io.Reader
+	io.Writer
+}
+
+func TestTampered(t *testing.T) {
+	code := NewCode()
+	var wire bytes.Buffer
+	s, _ := Sender(pipe{strings.NewReader(""), &wire}, code)
+	if _, err := s.Send(Header{Name: "x", Size: 5}, strings.NewReader("hello")); err == nil {
+		t.Error("send without ack")
+	}
+	receive := func(b []byte, code string) error {
+		s, err := Receiver(pipe{bytes.NewReader(b), ioutil.Discard}, code)
+		if err == nil {
+			_, _, err = s.Receive(func(Header) (io.Writer, error) { return ioutil.Discard, nil })
+		}
+		return err
+	}
+	if err := receive(wire.Bytes(), code); err != nil {
+		t.Fatal(err)
+	}
+	if err := receive(wire.Bytes(), NewCode()); err != ErrTampered {
+		t.Error("wrong code", err)
+	}
+	b := append([]byte(nil), wire.Bytes()...)
+	b[len(b)-30] ^= 1
+	if err := receive(b, code); err != ErrTampered {
+		t.Error("flipped bit", err)
+	}
+	// 去掉结束帧
+	if err := receive(wire.Bytes()[:wire.Len()-20], code); err == nil {
+		t.Error("truncated")
+	}
+}
@@ -0,0 +1,194 @@
+package trash
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 回收站: 文件移动到共享根目录下的隐藏目录而不是直接删除，记录原路径与删除时间
+// 每项为 <Dir>/<id>/<原文件名> 与信息文件 <Dir>/<id>.json
+
+// ErrNoEntry 回收站中没有该项
+var ErrNoEntry = errors.New("no such item in trash")
+
+// ErrExist 还原的目标位置已有文件
+var ErrExist = errors.New("restore target already exists")
+
+// reID 项的ID，如 20200102-0123456789abcdef
+var reID = regexp.MustCompile(`^\d{8}-[0-9a-f]{16}$`)
+
+// Item 回收站中的项
+type Item struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"` // 原路径，如 /photos/a.jpg
+	Size    int64     `json:"size"` // 目录为其中文件的总大小
+	Dir     bool      `json:"dir"`
+	Deleted time.Time `json:"deleted"`
+	Reason  string    `json:"reason,omitempty"` // 移入的原因，如 inbox-archive
+}
+
+// Bin 一个共享根目录的回收站，Dir须与共享目录位于同一文件系统
+type Bin struct {
+	Dir string
+}
+
+// Move 将文件或目录移入回收站，orig为记录的原路径
+func (b Bin) Move(file, orig, reason string) (Item, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return Item{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Item{}, err
+	}
+	item := Item{
+		ID:      time.Now().Format("20060102") + "-" + hex.EncodeToString(id),
+		Path:    orig,
+		Size:    info.Size(),
+		Dir:     info.IsDir(),
+		Deleted: time.Now(),
+		Reason:  reason,
+	}
+	if item.Dir {
+		item.Size = dirSize(file)
+	}
+	if err := os.MkdirAll(filepath.Join(b.Dir, item.ID), 0755); err != nil {
+		return Item{}, err
+	}
+	b2, err := json.Marshal(item)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(b.Dir, item.ID+".json"), b2, 0644)
+	}
+	if err == nil {
+		err = os.Rename(file, b.File(item))
+	}
+	if err != nil {
+		_ = os.Remove(filepath.Join(b.Dir, item.ID+".json"))
+		_ = os.Remove(filepath.Join(b.Dir, item.ID))
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// File 回收站中该项的文件路径
+func (b Bin) File(item Item) string {
+	return filepath.Join(b.Dir, item.ID, filepath.Base(filepath.FromSlash(item.Path)))
+}
+
+// dirSize 目录下文件的总大小
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// List 回收站中的项，按删除时间从新到旧排序，信息文件损坏或内容已不存在的项被忽略
+func (b Bin) List() ([]Item, error) {
+	infos, err := ioutil.ReadDir(b.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, info := range infos {
+		id := strings.TrimSuffix(info.Name(), ".json")
+		if info.IsDir() || !reID.MatchString(id) {
+			continue
+		}
+		if item, err := b.Get(id); err == nil {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Deleted.After(items[j].Deleted) })
+	return items, nil
+}
+
+// Get 按ID读取回收站中的项
+func (b Bin) Get(id string) (Item, error) {
+	var item Item
+	if !reID.MatchString(id) {
+		return item, ErrNoEntry
+	}
+	data, err := ioutil.ReadFile(filepath.Join(b.Dir, id+".json"))
+	if err != nil || json.Unmarshal(data, &item) != nil || item.ID != id {
+		return Item{}, ErrNoEntry
+	}
+	if _, err := os.Lstat(b.File(item)); err != nil {
+		return Item{}, ErrNoEntry
+	}
+	return item, nil
+}
+
+// Restore 将项移回dest(通常为原路径对应的文件)，dest已存在时返回ErrExist，缺少的上级目录会被创建
+func (b Bin) Restore(item Item, dest string) error {
+	if _, err := os.Lstat(dest); err == nil {
+		return ErrExist
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(b.File(item), dest); err != nil {
+		return err
+	}
+	_ = os.Remove(filepath.Join(b.Dir, item.ID))
+	return os.Remove(filepath.Join(b.Dir, item.ID+".json"))
+}
+
+// Purge 彻底删除该项
+func (b Bin) Purge(item Item) error {
+	if err := os.RemoveAll(filepath.Join(b.Dir, item.ID)); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(b.Dir, item.ID+".json"))
+}
+
+// Expire 按保留策略彻底删除: 早于before删除的项，以及总大小超过maxSize(字节，0为不限)时最早删除的项
+func (b Bin) Expire(before time.Time, maxSize int64) ([]Item, error) {
+	items, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	var purged []Item
+	for _, item := range items {
+		total += item.Size
+		if item.Deleted.Before(before) || (maxSize > 0 && total > maxSize) {
+			if err := b.Purge(item); err != nil {
+				return purged, err
+			}
+			purged = append(purged, item)
+		}
+	}
+	return purged, nil
+}
+
+// Empty 清空回收站
+func (b Bin) Empty() ([]Item, error) {
+	items, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		if err := b.Purge(item); err != nil {
+			return items[:i], err
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,125 @@
+package trash
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "in", "a.txt")
+	_ = os.MkdirAll(filepath.Dir(file), 0755)
+	_ = ioutil.WriteFile(file, []byte("abc"), 0644)
+
+	b := Bin{Dir: filepath.Join(dir, ".trash")}
+	item, err := b.Move(file, "/in/a.txt", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("not moved")
+	}
+	if data, err := ioutil.ReadFile(b.File(item)); err != nil || string(data) != "abc" {
+		t.Error(string(data), err)
+	}
+	var saved Item
+	data, _ := ioutil.ReadFile(filepath.Join(b.Dir, item.ID+".json"))
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Path != "/in/a.txt" || saved.Size != 3 || saved.Reason != "test" {
+		t.Errorf("%+v %v", saved, err)
+	}
+
+	item, err = b.Move(filepath.Join(dir, "in"), "/in", "")
+	if err != nil || !item.Dir {
+		t.Fatal(item, err)
+	}
+	if _, err := b.Move(filepath.Join(dir, "missing"), "/missing", ""); err == nil {
+		t.Error("missing file")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	b := Bin{Dir: filepath.Join(dir, ".trash")}
+	if items, err := b.List(); err != nil || len(items) != 0 {
+		t.Error("empty", items, err)
+	}
+	file := filepath.Join(dir, "docs", "a.txt")
+	_ = os.MkdirAll(filepath.Dir(file), 0755)
+	_ = ioutil.WriteFile(file, []byte("abc"), 0644)
+	a, _ := b.Move(file, "/docs/a.txt", "")
+	_ = ioutil.WriteFile(file, []byte("new"), 0644)
+	bItem, _ := b.Move(file, "/docs/a.txt", "")
+	_ = os.Remove(filepath.Dir(file))
+
+	items, err := b.List()
+	if err != nil || len(items) != 2 || items[0].ID != bItem.ID {
+		t.Fatal(items, err)
+	}
+	if _, err := b.Get("../x"); err != ErrNoEntry {
+		t.Error("bad id", err)
+	}
+	if err := b.Restore(a, file); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(file); string(data) != "abc" {
+		t.Error(string(data))
+	}
+	if err := b.Restore(bItem, file); err != ErrExist {
+		t.Error("exists", err)
+	}
+	if _, err := b.Get(a.ID); err != ErrNoEntry {
+		t.Error("restored item still listed", err)
+	}
+	if err := b.Purge(bItem); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := b.List(); len(items) != 0 {
+		t.Error("purge", items)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	b := Bin{Dir: filepath.Join(dir, ".trash")}
+	var ids []string
+	for i, name := range []string{"old", "mid", "new"} {
+		file := filepath.Join(dir, name)
+		_ = ioutil.WriteFile(file, make([]byte, 10), 0644)
+		item, _ := b.Move(file, "/"+name, "")
+		// 调整删除时间
+		item.Deleted = time.Now().Add(time.Duration(i-2) * 24 * time.Hour)
+		data, _ := json.Marshal(item)
+		_ = ioutil.WriteFile(filepath.Join(b.Dir, item.ID+".json"), data, 0644)
+		ids = append(ids, item.ID)
+	}
+	purged, err := b.Expire(time.Now().Add(-36*time.Hour), 0)
+	if err != nil || len(purged) != 1 || purged[0].ID != ids[0] {
+		t.Fatal(purged, err)
+	}
+	purged, _ = b.Expire(time.Time{}, 15)
+	if len(purged) != 1 || purged[0].ID != ids[1] {
+		t.Error("size", purged)
+	}
+	if items, _ := b.List(); len(items) != 1 || items[0].ID != ids[2] {
+		t.Error(items)
+	}
+	if purged, err := b.Empty(); err != nil || len(purged) != 1 {
+		t.Error("empty", purged, err)
+	}
+}
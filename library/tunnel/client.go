@@ -0,0 +1,139 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client WebSocket隧道客户端
+type Client struct {
+	Server string // 隧道服务器地址，如 wss://tunnel.example.com/tunnel
+	Token  string
+	Port   int // 申请的公网端口，0为由服务器分配
+	Local  *Local
+
+	dialer *websocket.Dialer
+}
+
+// NewClient 创建连接server并把公网连接转发到local的客户端
+func NewClient(server, token string, port int, local *Local) *Client {
+	server = strings.TrimRight(server, "/")
+	if strings.HasPrefix(server, "http") {
+		server = "ws" + strings.TrimPrefix(server, "http")
+	}
+	return &Client{
+		Server: server,
+		Token:  token,
+		Port:   port,
+		Local:  local,
+		dialer: &websocket.Dialer{HandshakeTimeout: 15 * time.Second, Subprotocols: []string{Protocol}},
+	}
+}
+
+// dial 连接服务器的path，握手失败时返回服务器的错误信息
+func (c *Client) dial(path string, query url.Values) (*websocket.Conn, error) {
+	h := http.Header{}
+	if c.Token != "" {
+		h.Set("Authorization", "Bearer "+c.Token)
+	}
+	ws, res, err := c.dialer.Dial(c.Server+path+"?"+query.Encode(), h)
+	if err == websocket.ErrBadHandshake && res != nil {
+		body, _ := ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if res.StatusCode == http.StatusUnauthorized {
+			return nil, ErrToken
+		}
+		return nil, fmt.Errorf("tunnel: %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return ws, err
+}
+
+// Run 保持隧道连接直到stop关闭，断开后按1秒到1分钟递增的间隔重连；
+// 每次注册成功时以公网地址调用onURL
+func (c *Client) Run(stop <-chan struct{}, onURL func(string), onError func(error)) {
+	wait := time.Second
+	for {
+		start := time.Now()
+		err := c.connect(stop, onURL)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if onError != nil && err != nil {
+			onError(err)
+		}
+		if time.Since(start) > time.Minute {
+			wait = time.Second
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > time.Minute {
+			wait = time.Minute
+		}
+	}
+}
+
+// connect 注册并处理公网连接，控制连接断开或stop关闭时返回
+func (c *Client) connect(stop <-chan struct{}, onURL func(string)) error {
+	q := url.Values{}
+	if c.Port > 0 {
+		q.Set("port", strconv.Itoa(c.Port))
+	}
+	ws, err := c.dial("/connect", q)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+		case <-done:
+		}
+		_ = ws.Close()
+	}()
+	var hello message
+	if err := ws.ReadJSON(&hello); err != nil {
+		return err
+	}
+	if hello.Error != "" {
+		return errors.New("tunnel: " + hello.Error)
+	}
+	if onURL != nil {
+		onURL(hello.URL)
+	}
+	_ = ws.SetReadDeadline(time.Now().Add(3 * pingInterval))
+	ws.SetPingHandler(func(data string) error {
+		_ = ws.SetReadDeadline(time.Now().Add(3 * pingInterval))
+		return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(pingInterval))
+	})
+	for {
+		var m message
+		if err := ws.ReadJSON(&m); err != nil {
+			return err
+		}
+		if m.Open != "" {
+			go c.open(m.Open, m.From)
+		}
+	}
+}
+
+// open 为公网连接建立数据连接并转发到本机服务
+func (c *Client) open(id, from string) {
+	ws, err := c.dial("/data", url.Values{"id": {id}})
+	if err != nil {
+		return
+	}
+	_ = c.Local.Serve(&wsConn{ws: ws}, from)
+}
@@ -0,0 +1,201 @@
+package tunnel
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"github.com/gorilla/websocket"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pingInterval 控制连接的心跳间隔，超过3个间隔没有响应视为断开
+const pingInterval = 30 * time.Second
+
+// Server 公网隧道服务器(http.Handler): 为每个注册的客户端在MinPort-MaxPort中分配一个公网端口
+type Server struct {
+	// Token 客户端注册与建立数据连接时须提供的令牌，为空时不校验
+	Token string
+	// Host 公网访问地址中的主机名，为空时使用客户端连接时的Host
+	Host string
+	// MinPort、MaxPort 可分配的公网端口范围
+	MinPort, MaxPort int
+	// Wait 公网连接等待客户端建立数据连接的时间
+	Wait time.Duration
+	// Log 注册与断开时的日志，为空时不输出
+	Log func(format string, args ...interface{})
+
+	upgrader websocket.Upgrader
+	mu       sync.Mutex
+	pending  map[string]net.Conn
+}
+
+// NewServer 创建在[minPort, maxPort]中分配公网端口的服务器
+func NewServer(token string, minPort, maxPort int) *Server {
+	return &Server{
+		Token:    token,
+		MinPort:  minPort,
+		MaxPort:  maxPort,
+		Wait:     10 * time.Second,
+		upgrader: websocket.Upgrader{Subprotocols: []string{Protocol}, CheckOrigin: func(*http.Request) bool { return true }},
+		pending:  make(map[string]net.Conn),
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		s.Log(format, args...)
+	}
+}
+
+// ServeHTTP 路径以/connect结尾为控制连接，以/data结尾为数据连接，可挂在反向代理的任意路径下
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, ErrToken.Error(), http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/connect"):
+		s.connect(w, r)
+	case strings.HasSuffix(r.URL.Path, "/data"):
+		s.data(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorized 令牌通过Authorization: Bearer或token参数提供
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+// listen 监听客户端请求的端口，未指定或不在范围内时使用范围内第一个空闲端口
+func (s *Server) listen(port int) (net.Listener, error) {
+	if port >= s.MinPort && port <= s.MaxPort && port > 0 {
+		return net.Listen("tcp", ":"+strconv.Itoa(port))
+	}
+	for p := s.MinPort; p <= s.MaxPort && p > 0; p++ {
+		if ln, err := net.Listen("tcp", ":"+strconv.Itoa(p)); err == nil {
+			return ln, nil
+		}
+	}
+	return nil, ErrPort
+}
+
+// connect 控制连接: 分配公网端口，接受公网连接并通知客户端，控制连接断开时关闭端口
+func (s *Server) connect(w http.ResponseWriter, r *http.Request) {
+	port, _ := strconv.Atoi(r.URL.Query().Get("port"))
+	ln, err := s.listen(port)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer func() { _ = ln.Close() }()
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer func() { _ = ws.Close() }()
+	port = ln.Addr().(*net.TCPAddr).Port
+	host := s.Host
+	if host == "" {
+		host = hostIP(r.Host)
+	}
+	u := "http://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/"
+	if err := ws.WriteJSON(message{URL: u, Port: port}); err != nil {
+		return
+	}
+	s.logf("[Tunnel] %s registered %s", r.RemoteAddr, u)
+	defer s.logf("[Tunnel] %s closed %s", r.RemoteAddr, u)
+
+	// 读取以处理心跳与关闭，客户端不发送其他消息
+	closed := make(chan struct{})
+	_ = ws.SetReadDeadline(time.Now().Add(3 * pingInterval))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(3 * pingInterval))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := ws.NextReader(); err != nil {
+				_ = ln.Close()
+				return
+			}
+		}
+	}()
+	go func() {
+		t := time.NewTicker(pingInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-closed:
+				return
+			case <-t.C:
+				_ = ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingInterval))
+			}
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		id := s.hold(conn)
+		if err := ws.WriteJSON(message{Open: id, From: hostIP(conn.RemoteAddr().String())}); err != nil {
+			s.take(id)
+			_ = conn.Close()
+			return
+		}
+	}
+}
+
+// hold 保存等待数据连接的公网连接，超时未取走时关闭
+func (s *Server) hold(conn net.Conn) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+	s.mu.Lock()
+	s.pending[id] = conn
+	s.mu.Unlock()
+	time.AfterFunc(s.Wait, func() {
+		if c := s.take(id); c != nil {
+			_ = c.Close()
+		}
+	})
+	return id
+}
+
+func (s *Server) take(id string) net.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn := s.pending[id]
+	delete(s.pending, id)
+	return conn
+}
+
+// data 数据连接: 与等待中的公网连接对接
+func (s *Server) data(w http.ResponseWriter, r *http.Request) {
+	conn := s.take(r.URL.Query().Get("id"))
+	if conn == nil {
+		http.NotFound(w, r)
+		return
+	}
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	join(&wsConn{ws: ws}, conn)
+}
@@ -0,0 +1,148 @@
+package tunnel
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSH 通过系统的ssh命令建立远程端口转发: 服务器上Remote端口的连接经ssh转发到本机
+// 服务器的sshd须允许端口转发，公网可访问时还需GatewayPorts yes
+type SSH struct {
+	Target string // user@host 或 user@host:port
+	Remote int    // 服务器上监听的端口
+	Key    string // 私钥文件，为空时使用ssh的默认配置
+	Local  *Local
+}
+
+// host 目标中的主机名与端口
+func (s *SSH) host() (string, string) {
+	h := s.Target
+	if i := strings.LastIndexByte(h, '@'); i >= 0 {
+		h = h[i+1:]
+	}
+	if host, port, err := net.SplitHostPort(h); err == nil {
+		return host, port
+	}
+	return strings.Trim(h, "[]"), ""
+}
+
+// URL 默认的公网访问地址: 服务器的Remote端口
+func (s *SSH) URL() string {
+	host, _ := s.host()
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(s.Remote)) + "/"
+}
+
+// args ssh的参数，转发到本机listen地址
+func (s *SSH) args(listen string) []string {
+	host, port := s.host()
+	target := host
+	if i := strings.LastIndexByte(s.Target, '@'); i >= 0 {
+		target = s.Target[:i+1] + host
+	}
+	args := []string{"-N", "-T",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "ServerAliveInterval=30",
+		"-o", "ServerAliveCountMax=3",
+		"-o", "BatchMode=yes",
+		"-R", "0.0.0.0:" + strconv.Itoa(s.Remote) + ":" + listen}
+	if port != "" {
+		args = append(args, "-p", port)
+	}
+	if s.Key != "" {
+		args = append(args, "-i", s.Key)
+	}
+	return append(args, target)
+}
+
+// Run 保持ssh转发直到stop关闭，ssh退出后按1秒到1分钟递增的间隔重启；
+// ssh启动后保持运行5秒视为转发已建立，此时调用onStart
+func (s *SSH) Run(stop <-chan struct{}, onStart func(), onError func(error)) error {
+	if s.Remote <= 0 {
+		return errors.New("tunnel: ssh remote port required")
+	}
+	// ssh转发到本机的中间端口，以便区分经隧道的连接
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	host, _ := s.host()
+	origin := host
+	if ips, err := net.LookupIP(host); err == nil && len(ips) > 0 {
+		origin = ips[0].String()
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = s.Local.Serve(conn, origin) }()
+		}
+	}()
+	go func() {
+		defer func() { _ = ln.Close() }()
+		wait := time.Second
+		for {
+			start := time.Now()
+			err := s.run(stop, ln.Addr().String(), onStart)
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if onError != nil {
+				onError(err)
+			}
+			if time.Since(start) > time.Minute {
+				wait = time.Second
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(wait):
+			}
+			if wait *= 2; wait > time.Minute {
+				wait = time.Minute
+			}
+		}
+	}()
+	return nil
+}
+
+// run 运行一次ssh直到退出或stop关闭
+func (s *SSH) run(stop <-chan struct{}, listen string, onStart func()) error {
+	cmd := exec.Command("ssh", s.args(listen)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	started := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-exited:
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return errors.New("tunnel: ssh: " + msg)
+			}
+			if err == nil {
+				err = errors.New("tunnel: ssh exited")
+			}
+			return err
+		case <-started:
+			started = nil
+			if onStart != nil {
+				onStart()
+			}
+		case <-stop:
+			_ = cmd.Process.Kill()
+			return <-exited
+		}
+	}
+}
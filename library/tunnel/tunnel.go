@@ -0,0 +1,98 @@
+package tunnel
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// 反向隧道: 本机主动连接用户自建的公网服务器，公网访问经隧道转发到本机的Web服务，无需路由器端口映射。
+// WebSocket隧道: 客户端通过控制连接(<server>/connect)注册，服务器在公网端口上接受连接后经控制连接通知客户端，
+// 客户端为每个连接另建数据连接(<server>/data?id=)，服务器把两者原样对接；
+// SSH隧道: 由系统的ssh命令建立远程端口转发(ssh -R)
+
+// Protocol 控制连接的协议标识，作为WebSocket子协议
+const Protocol = "b0pass-tunnel/1"
+
+var (
+	ErrToken = errors.New("tunnel: invalid token")
+	ErrPort  = errors.New("tunnel: no public port available")
+)
+
+// message 控制连接上的消息: 注册成功后服务器返回URL与Port，之后每个公网连接发送Open与From
+type message struct {
+	URL   string `json:"url,omitempty"`
+	Port  int    `json:"port,omitempty"`
+	Open  string `json:"open,omitempty"`
+	From  string `json:"from,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Local 把隧道连接转发到本机服务，并记录每个转发连接对应的公网地址
+// 经隧道的请求在本机服务看来来自回环地址，须通过Origin还原，不能当作本机访问
+type Local struct {
+	Addr string // 本机服务地址，如 127.0.0.1:8899
+
+	mu      sync.RWMutex
+	origins map[string]string // 转发连接的本地地址 -> 公网地址
+}
+
+// NewLocal 创建转发到addr的Local
+func NewLocal(addr string) *Local {
+	return &Local{Addr: addr, origins: make(map[string]string)}
+}
+
+// Serve 连接本机服务并双向转发，origin为公网客户端的IP，任一方向结束时关闭两端
+func (l *Local) Serve(conn io.ReadWriteCloser, origin string) error {
+	local, err := net.DialTimeout("tcp", l.Addr, 10*time.Second)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	key := local.LocalAddr().String()
+	l.mu.Lock()
+	l.origins[key] = origin
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		delete(l.origins, key)
+		l.mu.Unlock()
+	}()
+	join(conn, local)
+	return nil
+}
+
+// Origin 本机服务收到的连接地址(RemoteAddr)如果来自隧道，返回公网客户端的IP
+func (l *Local) Origin(remoteAddr string) (string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	origin, ok := l.origins[remoteAddr]
+	return origin, ok
+}
+
+// join 双向转发，任一方向结束时关闭两端
+func join(a, b io.ReadWriteCloser) {
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+	_ = a.Close()
+	_ = b.Close()
+	<-done
+}
+
+// hostIP 地址中的IP部分
+func hostIP(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
@@ -0,0 +1,98 @@
+package tunnel
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freePort 取一个当前空闲的端口
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestTunnel(t *testing.T) {
+	var local *Local
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin, ok := local.Origin(r.RemoteAddr)
+		if !ok {
+			origin = "direct"
+		}
+		_, _ = w.Write([]byte(r.URL.Path + " " + origin))
+	}))
+	defer backend.Close()
+	local = NewLocal(strings.TrimPrefix(backend.URL, "http://"))
+
+	port := freePort(t)
+	srv := NewServer("secret", port, port)
+	srv.Host = "127.0.0.1"
+	hub := httptest.NewServer(srv)
+	defer hub.Close()
+
+	// 令牌错误
+	if err := NewClient(hub.URL+"/tunnel", "wrong", 0, local).connect(nil, nil); err != ErrToken {
+		t.Fatal("expected ErrToken", err)
+	}
+
+	stop := make(chan struct{})
+	urls := make(chan string, 1)
+	go NewClient(hub.URL+"/tunnel/", "secret", 0, local).Run(stop, func(u string) { urls <- u }, func(err error) { t.Log(err) })
+	var u string
+	select {
+	case u = <-urls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tunnel not registered")
+	}
+	if !strings.HasSuffix(u, ":"+strconv.Itoa(port)+"/") {
+		t.Fatal(u)
+	}
+	for i := 0; i < 3; i++ {
+		res, err := http.Get(u + "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		// 经隧道的请求还原为公网客户端地址
+		if string(b) != "/hello 127.0.0.1" {
+			t.Fatalf("%q", b)
+		}
+	}
+	close(stop)
+	// 客户端断开后公网端口关闭
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(port))
+		if err != nil {
+			break
+		}
+		c.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("public port still open")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestSSHArgs(t *testing.T) {
+	s := &SSH{Target: "me@example.com:2222", Remote: 8080, Key: "/k"}
+	want := []string{"-N", "-T", "-o", "ExitOnForwardFailure=yes", "-o", "ServerAliveInterval=30", "-o", "ServerAliveCountMax=3",
+		"-o", "BatchMode=yes", "-R", "0.0.0.0:8080:127.0.0.1:5000", "-p", "2222", "-i", "/k", "me@example.com"}
+	if got := s.args("127.0.0.1:5000"); !reflect.DeepEqual(got, want) {
+		t.Fatal(got)
+	}
+	if u := s.URL(); u != "http://example.com:8080/" {
+		t.Fatal(u)
+	}
+}
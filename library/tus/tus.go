@@ -0,0 +1,402 @@
+package tus
+
+import (
+	"b0pass/library/bufpool"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version 支持的tus协议版本
+const Version = "1.0.0"
+
+// StatusChecksumMismatch tus校验和不一致状态码
+const StatusChecksumMismatch = 460
+
+const (
+	extensions  = "creation,creation-with-upload,termination,checksum"
+	algorithms  = "md5,sha1,sha256"
+	contentType = "application/offset+octet-stream"
+)
+
+// Info 上传信息，与数据文件一起保存在TempDir中
+type Info struct {
+	ID      string            `json:"id"`
+	Size    int64             `json:"size"`
+	Meta    map[string]string `json:"meta"`
+	RawMeta string            `json:"raw_meta"`
+	Created time.Time         `json:"created"`
+}
+
+// Error 带HTTP状态码的错误，OnComplete返回后原样输出给客户端
+type Error struct {
+	Status int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Handler tus 1.0断点续传协议服务
+// 支持creation、creation-with-upload、termination、checksum扩展
+type Handler struct {
+	// Prefix 路由前缀，如/api/tus
+	Prefix string
+	// TempDir 上传数据与信息文件目录
+	TempDir string
+	// MaxSize 单个上传的最大字节数，0为不限制
+	MaxSize int64
+	// OnComplete 数据接收完整后调用，part为数据文件，返回nil后上传记录被删除
+	OnComplete func(info *Info, part string) error
+	// SyncChunks 每次写入后同步数据文件再返回新的offset，断电后已确认的offset仍有效
+	SyncChunks bool
+	// Preallocate 创建上传时为数据文件预先分配Upload-Length字节(可选)，返回*Error时按其状态码响应
+	Preallocate func(f *os.File, size int64) error
+}
+
+// locks 同一上传的请求串行处理
+var locks = struct {
+	sync.Mutex
+	m map[string]*idLock
+}{m: make(map[string]*idLock)}
+
+type idLock struct {
+	sync.Mutex
+	refs int
+}
+
+func lock(id string) func() {
+	locks.Lock()
+	l, ok := locks.m[id]
+	if !ok {
+		l = new(idLock)
+		locks.m[id] = l
+	}
+	l.refs++
+	locks.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		locks.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(locks.m, id)
+		}
+		locks.Unlock()
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set("Tus-Resumable", Version)
+	header.Set("Access-Control-Allow-Headers", "Authorization, Origin, X-Requested-With, X-HTTP-Method-Override, "+
+		"Content-Type, Upload-Length, Upload-Offset, Tus-Resumable, Upload-Metadata, Upload-Checksum, X-Durability")
+	header.Set("Access-Control-Expose-Headers", "Upload-Offset, Location, Upload-Length, Tus-Version, "+
+		"Tus-Resumable, Tus-Max-Size, Tus-Extension, Upload-Metadata")
+	method := r.Method
+	if m := r.Header.Get("X-HTTP-Method-Override"); m != "" {
+		method = strings.ToUpper(m)
+	}
+	if method == http.MethodOptions {
+		header.Set("Tus-Version", Version)
+		header.Set("Tus-Extension", extensions)
+		header.Set("Tus-Checksum-Algorithm", algorithms)
+		if h.MaxSize > 0 {
+			header.Set("Tus-Max-Size", strconv.FormatInt(h.MaxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != Version {
+		header.Set("Tus-Version", Version)
+		writeError(w, http.StatusPreconditionFailed, "unsupported tus version")
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, h.Prefix), "/")
+	if id == "" {
+		if method == http.MethodPost {
+			h.create(w, r)
+		} else {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		writeError(w, http.StatusNotFound, "upload not found")
+		return
+	}
+	defer lock(id)()
+	info, err := h.load(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "upload not found")
+		return
+	}
+	switch method {
+	case http.MethodHead:
+		offset, _ := h.offset(id)
+		header.Set("Cache-Control", "no-store")
+		header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		header.Set("Upload-Length", strconv.FormatInt(info.Size, 10))
+		if info.RawMeta != "" {
+			header.Set("Upload-Metadata", info.RawMeta)
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		if r.Header.Get("Content-Type") != contentType {
+			writeError(w, http.StatusUnsupportedMediaType, "content type must be "+contentType)
+			return
+		}
+		offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "invalid Upload-Offset")
+			return
+		}
+		h.write(w, r, info, offset)
+	case http.MethodDelete:
+		h.remove(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// create 创建上传(creation)，请求体非空时同时写入数据(creation-with-upload)
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		writeError(w, http.StatusBadRequest, "Upload-Defer-Length is not supported")
+		return
+	}
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		writeError(w, http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+	if h.MaxSize > 0 && size > h.MaxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "upload exceeds Tus-Max-Size")
+		return
+	}
+	meta, err := ParseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	info := &Info{
+		ID:      hex.EncodeToString(b),
+		Size:    size,
+		Meta:    meta,
+		RawMeta: r.Header.Get("Upload-Metadata"),
+		Created: time.Now(),
+	}
+	if err := h.save(info); err != nil {
+		if e, ok := err.(*Error); ok {
+			writeError(w, e.Status, e.Msg)
+		} else {
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	defer lock(info.ID)()
+	w.Header().Set("Location", h.Prefix+"/"+info.ID)
+	if r.Header.Get("Content-Type") == contentType || size == 0 {
+		h.write(w, r, info, 0)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// write 在offset处追加请求体，校验Upload-Checksum，数据完整时调用OnComplete
+func (h *Handler) write(w http.ResponseWriter, r *http.Request, info *Info, offset int64) {
+	status := http.StatusNoContent
+	if r.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	have, err := h.offset(info.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if have != offset {
+		writeError(w, http.StatusConflict, "Upload-Offset mismatch: have "+strconv.FormatInt(have, 10))
+		return
+	}
+	sum, want, err := checksum(r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	f, err := os.OpenFile(h.partFile(info.ID), os.O_WRONLY, 0644)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err == nil {
+		var dst io.Writer = f
+		if sum != nil {
+			dst = io.MultiWriter(f, sum)
+		}
+		var n int64
+		n, err = bufpool.Copy(dst, io.LimitReader(r.Body, info.Size-offset))
+		offset += n
+	}
+	// 校验和不一致或带校验的请求未完整接收时，丢弃本次数据
+	if sum != nil && (err != nil || !strings.EqualFold(base64.StdEncoding.EncodeToString(sum.Sum(nil)), want)) {
+		_ = f.Truncate(have)
+		_ = f.Close()
+		if err == nil {
+			writeError(w, StatusChecksumMismatch, "checksum mismatch")
+		} else {
+			writeError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+	if h.SyncChunks {
+		if serr := f.Sync(); err == nil {
+			err = serr
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	// 客户端中断时已接收的数据保留，可从新的offset继续
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if offset == info.Size && h.OnComplete != nil {
+		if err := h.OnComplete(info, h.partFile(info.ID)); err != nil {
+			h.remove(info.ID)
+			if e, ok := err.(*Error); ok {
+				writeError(w, e.Status, e.Msg)
+			} else {
+				writeError(w, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+		h.remove(info.ID)
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.WriteHeader(status)
+}
+
+// checksum 解析Upload-Checksum头: 算法名 base64(摘要)
+func checksum(value string) (hash.Hash, string, error) {
+	if value == "" {
+		return nil, "", nil
+	}
+	parts := strings.SplitN(value, " ", 2)
+	if len(parts) != 2 {
+		return nil, "", &Error{Status: http.StatusBadRequest, Msg: "invalid Upload-Checksum"}
+	}
+	switch parts[0] {
+	case "md5":
+		return md5.New(), parts[1], nil
+	case "sha1":
+		return sha1.New(), parts[1], nil
+	case "sha256":
+		return sha256.New(), parts[1], nil
+	}
+	return nil, "", &Error{Status: http.StatusBadRequest, Msg: "unsupported checksum algorithm: " + parts[0]}
+}
+
+// ParseMetadata 解析Upload-Metadata: key base64(value),key2 base64(value2)
+func ParseMetadata(value string) (map[string]string, error) {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.Fields(pair)
+		switch len(kv) {
+		case 0:
+		case 1:
+			meta[kv[0]] = ""
+		case 2:
+			v, err := base64.StdEncoding.DecodeString(kv[1])
+			if err != nil {
+				return nil, &Error{Status: http.StatusBadRequest, Msg: "invalid Upload-Metadata: " + kv[0]}
+			}
+			meta[kv[0]] = string(v)
+		default:
+			return nil, &Error{Status: http.StatusBadRequest, Msg: "invalid Upload-Metadata"}
+		}
+	}
+	return meta, nil
+}
+
+func (h *Handler) infoFile(id string) string {
+	return filepath.Join(h.TempDir, id+".info")
+}
+
+func (h *Handler) partFile(id string) string {
+	return filepath.Join(h.TempDir, id+".part")
+}
+
+func (h *Handler) save(info *Info) error {
+	if err := os.MkdirAll(h.TempDir, 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.partFile(info.ID), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if h.Preallocate != nil {
+		err = h.Preallocate(f, info.Size)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return ioutil.WriteFile(h.infoFile(info.ID), b, 0644)
+}
+
+func (h *Handler) load(id string) (*Info, error) {
+	b, err := ioutil.ReadFile(h.infoFile(id))
+	if err != nil {
+		return nil, err
+	}
+	info := new(Info)
+	return info, json.Unmarshal(b, info)
+}
+
+// offset 已接收的字节数
+func (h *Handler) offset(id string) (int64, error) {
+	fi, err := os.Stat(h.partFile(id))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (h *Handler) remove(id string) {
+	_ = os.Remove(h.infoFile(id))
+	_ = os.Remove(h.partFile(id))
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, msg)
+}
@@ -0,0 +1,122 @@
+package tus
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func request(t *testing.T, srv *httptest.Server, method, path, body string, headers ...string) *http.Response {
+	r, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	r.Header.Set("Tus-Resumable", Version)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	return resp
+}
+
+func TestHandler(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tus")
+	defer func() { _ = os.RemoveAll(dir) }()
+	var done string
+	var meta map[string]string
+	srv := httptest.NewServer(&Handler{Prefix: "/tus", TempDir: dir, MaxSize: 100,
+		OnComplete: func(info *Info, part string) error {
+			b, _ := ioutil.ReadFile(part)
+			done, meta = string(b), info.Meta
+			return nil
+		}})
+	defer srv.Close()
+
+	if resp := request(t, srv, "OPTIONS", "/tus", ""); resp.StatusCode != 204 ||
+		!strings.Contains(resp.Header.Get("Tus-Extension"), "creation") {
+		t.Errorf("options: %d %v", resp.StatusCode, resp.Header)
+	}
+	r, _ := http.NewRequest("POST", srv.URL+"/tus", nil)
+	if resp, _ := http.DefaultClient.Do(r); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("missing Tus-Resumable: %d", resp.StatusCode)
+	}
+	if resp := request(t, srv, "POST", "/tus", "", "Upload-Length", "101"); resp.StatusCode != 413 {
+		t.Errorf("too large: %d", resp.StatusCode)
+	}
+
+	resp := request(t, srv, "POST", "/tus", "", "Upload-Length", "11",
+		"Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("a.txt"))+",is_confidential")
+	loc := strings.TrimPrefix(resp.Header.Get("Location"), srv.URL)
+	if resp.StatusCode != 201 || !strings.HasPrefix(loc, "/tus/") {
+		t.Fatalf("create: %d %s", resp.StatusCode, loc)
+	}
+	octet := "application/offset+octet-stream"
+	if resp := request(t, srv, "PATCH", loc, "hello", "Content-Type", octet, "Upload-Offset", "0"); resp.Header.Get("Upload-Offset") != "5" {
+		t.Errorf("patch: %d %s", resp.StatusCode, resp.Header.Get("Upload-Offset"))
+	}
+	if resp := request(t, srv, "PATCH", loc, " world", "Content-Type", octet, "Upload-Offset", "0"); resp.StatusCode != 409 {
+		t.Errorf("offset mismatch: %d", resp.StatusCode)
+	}
+	if resp := request(t, srv, "PATCH", loc, " world", "Content-Type", octet, "Upload-Offset", "5",
+		"Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString(make([]byte, 20))); resp.StatusCode != StatusChecksumMismatch {
+		t.Errorf("checksum mismatch: %d", resp.StatusCode)
+	}
+	if resp := request(t, srv, "HEAD", loc, ""); resp.Header.Get("Upload-Offset") != "5" || resp.Header.Get("Upload-Length") != "11" {
+		t.Errorf("head after bad checksum: %v", resp.Header)
+	}
+	sum := sha1.Sum([]byte(" world"))
+	if resp := request(t, srv, "PATCH", loc, " world", "Content-Type", octet, "Upload-Offset", "5",
+		"Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString(sum[:])); resp.StatusCode != 204 {
+		t.Errorf("final patch: %d", resp.StatusCode)
+	}
+	if done != "hello world" || meta["filename"] != "a.txt" {
+		t.Errorf("complete: %q %v", done, meta)
+	}
+	if resp := request(t, srv, "HEAD", loc, ""); resp.StatusCode != 404 {
+		t.Errorf("completed upload still exists: %d", resp.StatusCode)
+	}
+
+	// creation-with-upload 与 termination
+	resp = request(t, srv, "POST", "/tus", "abc", "Upload-Length", "6", "Content-Type", octet)
+	loc = strings.TrimPrefix(resp.Header.Get("Location"), srv.URL)
+	if resp.StatusCode != 201 || resp.Header.Get("Upload-Offset") != "3" {
+		t.Errorf("creation-with-upload: %d %v", resp.StatusCode, resp.Header)
+	}
+	if resp := request(t, srv, "DELETE", loc, ""); resp.StatusCode != 204 {
+		t.Errorf("delete: %d", resp.StatusCode)
+	}
+	if resp := request(t, srv, "HEAD", loc, ""); resp.StatusCode != 404 {
+		t.Errorf("deleted upload still exists: %d", resp.StatusCode)
+	}
+}
+
+func TestPreallocate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tus")
+	defer func() { _ = os.RemoveAll(dir) }()
+	var reserved int64
+	srv := httptest.NewServer(&Handler{Prefix: "/tus", TempDir: dir,
+		Preallocate: func(f *os.File, size int64) error {
+			if size > 10 {
+				return &Error{Status: http.StatusInsufficientStorage, Msg: "no space"}
+			}
+			reserved = size
+			return nil
+		}})
+	defer srv.Close()
+
+	if resp := request(t, srv, "POST", "/tus", "", "Upload-Length", "11"); resp.StatusCode != http.StatusInsufficientStorage {
+		t.Errorf("no space: %d", resp.StatusCode)
+	}
+	if list, _ := ioutil.ReadDir(dir); len(list) != 0 {
+		t.Errorf("left %d files", len(list))
+	}
+	if resp := request(t, srv, "POST", "/tus", "", "Upload-Length", "10"); resp.StatusCode != 201 || reserved != 10 {
+		t.Errorf("create: %d %d", resp.StatusCode, reserved)
+	}
+}
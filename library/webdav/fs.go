@@ -0,0 +1,201 @@
+package webdav
+
+import (
+	"b0pass/library/fileinfos"
+	"b0pass/library/roots"
+	"context"
+	"encoding/xml"
+	"golang.org/x/net/webdav"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileSystem 将共享目录适配为webdav.FileSystem，不允许访问的路径视为不存在
+type fileSystem struct {
+	h    *Handler
+	body *bodyReader // 本次请求的请求体，用于判断上传是否完整
+}
+
+// file 共享目录下的文件，覆盖写入时先写入暂存文件，关闭时再提交到目标位置
+type file struct {
+	*os.File
+	fs     *fileSystem
+	target string // 暂存文件的目标路径，为空表示直接打开的文件
+}
+
+// topDir 多根目录的顶层虚拟目录，只能列出各根目录
+type topDir struct {
+	roots roots.Set
+}
+
+// open 将名称转换为文件路径，不允许访问时返回不存在，顶层虚拟目录返回空
+func (fs *fileSystem) open(name string) (string, error) {
+	if fs.h.roots().IsTop(name) {
+		return "", nil
+	}
+	f, _ := fs.h.resolve(name)
+	if f == "" || !fs.h.allowed(f) {
+		return "", os.ErrNotExist
+	}
+	return f, nil
+}
+
+// create 将名称转换为可写入的文件路径，顶层虚拟目录与各根目录本身不能写入
+func (fs *fileSystem) create(name string) (string, error) {
+	f, err := fs.open(name)
+	if err != nil {
+		return "", err
+	}
+	if f == "" || fs.h.roots().IsRoot(f) || !fs.h.writable(f) {
+		return "", os.ErrPermission
+	}
+	return f, nil
+}
+
+func (fs *fileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	f, err := fs.create(name)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(f, perm)
+}
+
+func (fs *fileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	f, err := fs.open(name)
+	if err != nil {
+		return nil, err
+	}
+	if flag&os.O_TRUNC == 0 {
+		if f == "" {
+			if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+				return nil, os.ErrPermission
+			}
+			return topDir{fs.h.roots()}, nil
+		}
+		of, err := os.OpenFile(f, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return &file{File: of, fs: fs}, nil
+	}
+	if f, err = fs.create(name); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(filepath.Dir(f)); err != nil || !info.IsDir() {
+		return nil, os.ErrNotExist
+	}
+	if info, err := os.Stat(f); err == nil && info.IsDir() {
+		return nil, os.ErrPermission
+	}
+	tmpDir := fs.h.TempDir
+	if tmpDir == "" {
+		r, _ := fs.h.roots().Of(f)
+		tmpDir = fileinfos.TempDir(r.Path, "")
+	}
+	tmp, err := fileinfos.CreateTemp(tmpDir, filepath.Base(f))
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: tmp, fs: fs, target: f}, nil
+}
+
+func (fs *fileSystem) RemoveAll(ctx context.Context, name string) error {
+	f, err := fs.create(name)
+	if err != nil {
+		return err
+	}
+	return fs.h.remove(f)
+}
+
+func (fs *fileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	src, err := fs.open(oldName)
+	if err != nil {
+		return err
+	}
+	if src == "" || fs.h.roots().IsRoot(src) {
+		return os.ErrPermission
+	}
+	dst, err := fs.create(newName)
+	if err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+func (fs *fileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	f, err := fs.open(name)
+	if err != nil {
+		return nil, err
+	}
+	if f == "" {
+		return fs.h.roots().Top(), nil
+	}
+	return os.Stat(f)
+}
+
+// Close 关闭文件，暂存文件在请求体完整时提交，否则丢弃
+func (f *file) Close() error {
+	err := f.File.Close()
+	if f.target == "" {
+		return err
+	}
+	defer func() { _ = os.Remove(f.File.Name()) }()
+	if err == nil && f.fs.body != nil {
+		err = f.fs.body.err
+	}
+	if err != nil {
+		return err
+	}
+	return fileinfos.Commit(f.File.Name(), f.target)
+}
+
+// Readdir 列出目录，跳过隐藏文件与不允许访问的文件，允许访问的符号链接按目标显示
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	children, err := f.File.Readdir(count)
+	list := children[:0]
+	for _, child := range children {
+		p := filepath.Join(f.File.Name(), child.Name())
+		if strings.HasPrefix(child.Name(), ".") || !f.fs.h.allowed(p) {
+			continue
+		}
+		if child.Mode()&os.ModeSymlink != 0 {
+			info, statErr := os.Stat(p)
+			if statErr != nil {
+				continue
+			}
+			child = info
+		}
+		list = append(list, child)
+	}
+	return list, err
+}
+
+// DeadProps 不保存自定义属性
+func (f *file) DeadProps() (map[xml.Name]webdav.Property, error) {
+	return nil, nil
+}
+
+// Patch 属性修改不持久化，对请求中的所有属性返回成功以兼容Windows资源管理器
+func (f *file) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	ps := webdav.Propstat{Status: http.StatusOK}
+	for _, patch := range patches {
+		for _, p := range patch.Props {
+			ps.Props = append(ps.Props, webdav.Property{XMLName: p.XMLName})
+		}
+	}
+	return []webdav.Propstat{ps}, nil
+}
+
+func (d topDir) Close() error                                 { return nil }
+func (d topDir) Read(p []byte) (int, error)                   { return 0, io.EOF }
+func (d topDir) Seek(offset int64, whence int) (int64, error) { return 0, nil }
+func (d topDir) Write(p []byte) (int, error)                  { return 0, os.ErrPermission }
+func (d topDir) Stat() (os.FileInfo, error)                   { return d.roots.Top(), nil }
+
+// Readdir 列出各根目录，无法访问的根目录跳过
+func (d topDir) Readdir(count int) ([]os.FileInfo, error) {
+	return d.roots.List(), nil
+}
@@ -0,0 +1,238 @@
+package webdav
+
+import (
+	"b0pass/library/bufpool"
+	"b0pass/library/roots"
+	"fmt"
+	"golang.org/x/net/webdav"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 基于golang.org/x/net/webdav(class 1、2)，通过FileSystem适配共享目录，
+// 以便接入暂存目录、符号链接策略、回收站与框架的文件下载
+
+// Handler 基于共享目录的WebDAV服务
+type Handler struct {
+	Prefix   string    // URL前缀，如 /dav
+	Root     string    // 共享目录
+	Roots    roots.Set // 多个共享根目录(可选)，设置时以根目录名作为顶层虚拟目录，不再使用Root
+	TempDir  string    // PUT暂存目录，为空时使用文件所在根目录下的默认暂存目录
+	ReadOnly bool      // 只读模式
+	// Locks 锁表，各请求的Handler须共用同一实例(见NewLocks)，未设置时锁只在单个请求内有效
+	Locks webdav.LockSystem
+
+	// ServeFile 文件下载(可选)，用于交给外部框架以流式输出
+	ServeFile func(w http.ResponseWriter, r *http.Request, file string)
+	// OnChange 写操作完成回调(可选)，method为WebDAV方法名，dest仅COPY/MOVE时有值
+	OnChange func(method, file, dest string, size int64)
+	// Allow 是否允许访问文件(可选)，如按符号链接策略限制，不允许的路径视为不存在，也不在列表中出现
+	Allow func(file string) bool
+	// Remove 删除文件或目录(可选)，如移入回收站，未设置时直接删除
+	Remove func(file string) error
+	// Writable 能否写入文件(可选)，如受保护目录，不允许时PUT与COPY/MOVE的目标返回403
+	Writable func(file string) bool
+}
+
+// NewLocks 创建内存锁表
+func NewLocks() webdav.LockSystem {
+	return webdav.NewMemLS()
+}
+
+// ServeHTTP 实现http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	file, name := h.resolve(r.URL.Path)
+	top := h.roots().IsTop(name)
+	if !top && (file == "" || !h.allowed(file)) {
+		http.NotFound(w, r)
+		return
+	}
+	if h.ReadOnly && isWrite(r.Method) {
+		http.Error(w, "read-only", http.StatusForbidden)
+		return
+	}
+	// 顶层虚拟目录与各根目录本身不能删除、移动或覆盖
+	fixed := top || h.roots().IsRoot(file)
+	dest := ""
+	switch r.Method {
+	case "GET", "HEAD":
+		if info, err := os.Stat(file); err == nil && !info.IsDir() && h.ServeFile != nil {
+			h.ServeFile(w, r, file)
+			return
+		}
+	case "PUT":
+		if fixed || !h.writable(file) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if cr := r.Header.Get("Content-Range"); cr != "" {
+			w.WriteHeader(h.putRange(r, file, cr))
+			return
+		}
+	case "DELETE":
+		if fixed {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	case "COPY", "MOVE":
+		if u, err := url.Parse(r.Header.Get("Destination")); err == nil && u.Path != "" {
+			dest, _ = h.resolve(u.Path)
+			if top || (fixed && r.Method == "MOVE") || dest == "" || !h.allowed(dest) || !h.writable(dest) ||
+				dest == file || h.roots().IsRoot(dest) || strings.HasPrefix(dest, file+string(filepath.Separator)) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+	}
+	locks := h.Locks
+	if locks == nil {
+		locks = NewLocks()
+	}
+	body := &bodyReader{ReadCloser: r.Body}
+	r.Body = body
+	sw := &statusWriter{ResponseWriter: w}
+	dav := &webdav.Handler{
+		Prefix:     h.Prefix,
+		FileSystem: &fileSystem{h: h, body: body},
+		LockSystem: locks,
+	}
+	dav.ServeHTTP(sw, r)
+	if sw.status >= 200 && sw.status < 300 {
+		h.changed(r.Method, file, dest)
+	}
+}
+
+// roots 共享根目录集合
+func (h *Handler) roots() roots.Set {
+	if len(h.Roots) > 0 {
+		return h.Roots
+	}
+	return roots.Single(h.Root)
+}
+
+// resolve 将URL路径转换为共享目录下的文件路径，返回文件路径与规范化的相对名称；
+// 多根目录的顶层虚拟目录或不存在的根目录名文件路径为空
+func (h *Handler) resolve(urlPath string) (string, string) {
+	name := path.Clean("/" + strings.TrimPrefix(urlPath, h.Prefix))
+	file, _ := h.roots().Resolve(name)
+	return file, name
+}
+
+// allowed 是否允许访问文件
+func (h *Handler) allowed(file string) bool {
+	return h.Allow == nil || h.Allow(file)
+}
+
+// writable 能否写入文件
+func (h *Handler) writable(file string) bool {
+	return h.Writable == nil || h.Writable(file)
+}
+
+// remove 删除文件或目录
+func (h *Handler) remove(file string) error {
+	if h.Remove != nil {
+		return h.Remove(file)
+	}
+	return os.RemoveAll(file)
+}
+
+// changed 写操作成功后触发回调，size取完成后的文件大小
+func (h *Handler) changed(method, file, dest string) {
+	if h.OnChange == nil {
+		return
+	}
+	target := file
+	switch method {
+	case "PUT", "MKCOL", "DELETE":
+	case "COPY", "MOVE":
+		target = dest
+	default:
+		return
+	}
+	var size int64
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		size = info.Size()
+	}
+	h.OnChange(method, file, dest, size)
+}
+
+// isWrite 是否为写操作
+func isWrite(method string) bool {
+	switch method {
+	case "PUT", "DELETE", "MKCOL", "COPY", "MOVE", "PROPPATCH", "LOCK", "UNLOCK":
+		return true
+	}
+	return false
+}
+
+// putRange 带Content-Range的PUT: 原位覆盖已有文件的一段(用于修复损坏的分块)，不改变文件大小
+func (h *Handler) putRange(r *http.Request, file, cr string) int {
+	var start, end, total int64
+	if _, err := fmt.Sscanf(cr, "bytes %d-%d/%d", &start, &end, &total); err != nil || start < 0 || end < start || end >= total {
+		return http.StatusBadRequest
+	}
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		return http.StatusNotFound
+	}
+	if info.Size() != total {
+		return http.StatusRequestedRangeNotSatisfiable
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY, 0)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return http.StatusInternalServerError
+	}
+	if n, err := bufpool.Copy(f, io.LimitReader(r.Body, end-start+1)); err != nil || n != end-start+1 {
+		return http.StatusBadRequest
+	}
+	if err := f.Sync(); err != nil {
+		return http.StatusInternalServerError
+	}
+	if h.OnChange != nil {
+		h.OnChange(r.Method, file, "", total)
+	}
+	return http.StatusNoContent
+}
+
+// statusWriter 记录响应状态码
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// bodyReader 记录读取请求体时的错误，上传中断时暂存文件不提交
+type bodyReader struct {
+	io.ReadCloser
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,235 @@
+package webdav
+
+import (
+	"b0pass/library/roots"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestHandler(t *testing.T) (*Handler, func()) {
+	root, err := ioutil.TempDir("", "dav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Handler{Prefix: "/dav", Root: root}, func() { _ = os.RemoveAll(root) }
+}
+
+func do(h *Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestWebDAV(t *testing.T) {
+	h, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	if w := do(h, "OPTIONS", "/dav/", ""); !strings.Contains(w.Header().Get("DAV"), "2") || !strings.Contains(w.Header().Get("Allow"), "LOCK") {
+		t.Errorf("OPTIONS %v", w.Header())
+	}
+	if w := do(h, "MKCOL", "/dav/docs", ""); w.Code != http.StatusCreated {
+		t.Fatalf("MKCOL %d", w.Code)
+	}
+	if w := do(h, "PUT", "/dav/docs/a.txt", "hello"); w.Code != http.StatusCreated {
+		t.Fatalf("PUT %d", w.Code)
+	}
+	if w := do(h, "PUT", "/dav/missing/a.txt", "hello"); w.Code != http.StatusConflict {
+		t.Errorf("PUT without parent %d", w.Code)
+	}
+	w := do(h, "PROPFIND", "/dav/docs", "", "Depth", "1")
+	if w.Code != http.StatusMultiStatus || !strings.Contains(w.Body.String(), "/dav/docs/a.txt") {
+		t.Errorf("PROPFIND %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "GET", "/dav/docs/a.txt", ""); w.Body.String() != "hello" {
+		t.Errorf("GET %q", w.Body.String())
+	}
+	if w := do(h, "COPY", "/dav/docs/a.txt", "", "Destination", "http://example.com/dav/docs/b.txt"); w.Code != http.StatusCreated {
+		t.Errorf("COPY %d", w.Code)
+	}
+	if w := do(h, "MOVE", "/dav/docs/b.txt", "", "Destination", "http://example.com/dav/c.txt", "Overwrite", "F"); w.Code != http.StatusCreated {
+		t.Errorf("MOVE %d", w.Code)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(h.Root, "c.txt")); string(data) != "hello" {
+		t.Errorf("moved content %q", data)
+	}
+	if w := do(h, "DELETE", "/dav/docs", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE %d", w.Code)
+	}
+}
+
+func TestWebDAVLock(t *testing.T) {
+	h, cleanup := newTestHandler(t)
+	defer cleanup()
+	h.Locks = NewLocks()
+
+	lockinfo := `<?xml version="1.0" encoding="utf-8"?><D:lockinfo xmlns:D="DAV:">` +
+		`<D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>`
+	w := do(h, "LOCK", "/dav/d.txt", lockinfo, "Timeout", "Second-60")
+	token := w.Header().Get("Lock-Token")
+	if w.Code != http.StatusCreated || token == "" {
+		t.Fatalf("LOCK %d", w.Code)
+	}
+	if w := do(h, "LOCK", "/dav/d.txt", lockinfo); w.Code != http.StatusLocked {
+		t.Errorf("second LOCK %d", w.Code)
+	}
+	if w := do(h, "PUT", "/dav/d.txt", "x"); w.Code != http.StatusLocked {
+		t.Errorf("PUT without token %d", w.Code)
+	}
+	if w := do(h, "PUT", "/dav/d.txt", "x", "If", "("+token+")"); w.Code != http.StatusCreated {
+		t.Errorf("PUT with token %d", w.Code)
+	}
+	if w := do(h, "UNLOCK", "/dav/d.txt", "", "Lock-Token", token); w.Code != http.StatusNoContent {
+		t.Errorf("UNLOCK %d", w.Code)
+	}
+	if w := do(h, "DELETE", "/dav/d.txt", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE after UNLOCK %d", w.Code)
+	}
+}
+
+func TestWebDAVPutAbort(t *testing.T) {
+	h, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	req := httptest.NewRequest("PUT", "/dav/a.txt", io.MultiReader(strings.NewReader("part"), errReader{}))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if _, err := os.Stat(filepath.Join(h.Root, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("partial upload committed: %v", err)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+func TestWebDAVTraversal(t *testing.T) {
+	h, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	file, _ := h.resolve("/dav/../../etc/passwd")
+	if !strings.HasPrefix(file, h.Root) {
+		t.Errorf("escaped root: %s", file)
+	}
+	if w := do(h, "DELETE", "/dav/", ""); w.Code != http.StatusForbidden {
+		t.Errorf("DELETE root %d", w.Code)
+	}
+	h.ReadOnly = true
+	if w := do(h, "PUT", "/dav/a.txt", "x"); w.Code != http.StatusForbidden {
+		t.Errorf("read-only PUT %d", w.Code)
+	}
+}
+
+func TestWebDAVAllow(t *testing.T) {
+	h, cleanup := newTestHandler(t)
+	defer cleanup()
+	outside, _ := ioutil.TempDir("", "outside")
+	defer func() { _ = os.RemoveAll(outside) }()
+	_ = ioutil.WriteFile(filepath.Join(outside, "secret.txt"), []byte("x"), 0644)
+	if err := os.Symlink(outside, filepath.Join(h.Root, "out")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	_ = os.Mkdir(filepath.Join(h.Root, "docs"), 0755)
+	h.Allow = func(file string) bool { return !strings.HasPrefix(file, filepath.Join(h.Root, "out")) }
+
+	if w := do(h, "GET", "/dav/out/secret.txt", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET through link %d", w.Code)
+	}
+	if w := do(h, "PUT", "/dav/out/new.txt", "x"); w.Code != http.StatusNotFound {
+		t.Errorf("PUT through link %d", w.Code)
+	}
+	if w := do(h, "PROPFIND", "/dav/", "", "Depth", "1"); strings.Contains(w.Body.String(), "/dav/out") || !strings.Contains(w.Body.String(), "/dav/docs") {
+		t.Errorf("PROPFIND lists %s", w.Body.String())
+	}
+	if w := do(h, "MOVE", "/dav/docs", "", "Destination", "http://example.com/dav/out/docs"); w.Code != http.StatusForbidden {
+		t.Errorf("MOVE into link %d", w.Code)
+	}
+}
+
+func TestWebDAVPutRange(t *testing.T) {
+	h, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	if w := do(h, "PUT", "/dav/a.txt", "hello world"); w.Code != http.StatusCreated {
+		t.Fatalf("PUT %d", w.Code)
+	}
+	if w := do(h, "PUT", "/dav/a.txt", "WORLD", "Content-Range", "bytes 6-10/11"); w.Code != http.StatusNoContent {
+		t.Fatalf("ranged PUT %d", w.Code)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(h.Root, "a.txt")); string(b) != "hello WORLD" {
+		t.Errorf("bad content %q", b)
+	}
+	if w := do(h, "PUT", "/dav/a.txt", "x", "Content-Range", "bytes 0-0/20"); w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("size mismatch %d", w.Code)
+	}
+	if w := do(h, "PUT", "/dav/b.txt", "x", "Content-Range", "bytes 0-0/1"); w.Code != http.StatusNotFound {
+		t.Errorf("missing file %d", w.Code)
+	}
+}
+
+func TestWebDAVRemove(t *testing.T) {
+	h, cleanup := newTestHandler(t)
+	defer cleanup()
+	var removed []string
+	h.Remove = func(file string) error {
+		removed = append(removed, file)
+		return os.RemoveAll(file)
+	}
+	do(h, "PUT", "/dav/a.txt", "a")
+	do(h, "PUT", "/dav/b.txt", "b")
+	if w := do(h, "DELETE", "/dav/a.txt", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE %d", w.Code)
+	}
+	if w := do(h, "COPY", "/dav/b.txt", "", "Destination", "http://example.com/dav/c.txt"); w.Code != http.StatusCreated {
+		t.Errorf("COPY %d", w.Code)
+	}
+	// 覆盖目标同样经过Remove
+	if w := do(h, "COPY", "/dav/b.txt", "", "Destination", "http://example.com/dav/c.txt"); w.Code != http.StatusNoContent {
+		t.Errorf("COPY overwrite %d", w.Code)
+	}
+	if len(removed) != 2 || removed[0] != filepath.Join(h.Root, "a.txt") || removed[1] != filepath.Join(h.Root, "c.txt") {
+		t.Errorf("removed %v", removed)
+	}
+}
+
+func TestWebDAVRoots(t *testing.T) {
+	a, _ := ioutil.TempDir("", "dav-a")
+	b, _ := ioutil.TempDir("", "dav-b")
+	defer func() { _ = os.RemoveAll(a); _ = os.RemoveAll(b) }()
+	h := &Handler{Prefix: "/dav", Roots: roots.Set{{Name: "A", Path: a}, {Name: "B", Path: b}}}
+
+	w := do(h, "PROPFIND", "/dav/", "", "Depth", "1")
+	if w.Code != http.StatusMultiStatus || !strings.Contains(w.Body.String(), "/dav/A/") || !strings.Contains(w.Body.String(), "/dav/B/") {
+		t.Errorf("PROPFIND top %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "PUT", "/dav/B/x.txt", "x"); w.Code != http.StatusCreated {
+		t.Fatalf("PUT %d", w.Code)
+	}
+	if _, err := os.Stat(filepath.Join(b, "x.txt")); err != nil {
+		t.Error(err)
+	}
+	if w := do(h, "MOVE", "/dav/B/x.txt", "", "Destination", "http://example.com/dav/A/x.txt"); w.Code != http.StatusCreated {
+		t.Errorf("MOVE across roots %d", w.Code)
+	}
+	for _, c := range []struct{ method, target string }{
+		{"PUT", "/dav/top.txt"},
+		{"MKCOL", "/dav/C"},
+		{"DELETE", "/dav/A"},
+		{"MOVE", "/dav/A"},
+	} {
+		if w := do(h, c.method, c.target, "", "Destination", "http://example.com/dav/C"); w.Code < 400 {
+			t.Errorf("%s %s %d", c.method, c.target, w.Code)
+		}
+	}
+	if _, err := os.Stat(a); err != nil {
+		t.Error(err)
+	}
+}
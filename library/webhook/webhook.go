@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook: 文件事件(上传完成、删除)时向配置的URL POST JSON，失败重试，可用HMAC签名
+
+// 请求头
+const (
+	HeaderEvent     = "X-B0pass-Event"
+	HeaderDelivery  = "X-B0pass-Delivery"
+	HeaderSignature = "X-B0pass-Signature" // sha256=<请求体的HMAC-SHA256>
+)
+
+// Hook 接收地址，Events为空时接收全部事件
+type Hook struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"-"`
+	Events []string `json:"events"`
+}
+
+// Event 文件事件
+type Event struct {
+	Event  string    `json:"event"`
+	Path   string    `json:"path"`
+	Size   int64     `json:"size"`
+	Hash   string    `json:"hash,omitempty"`
+	Client string    `json:"client,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Parse 解析配置项(url/secret/events表数组)
+func Parse(items []interface{}) ([]Hook, error) {
+	var hooks []Hook
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("webhook: invalid item %v", item)
+		}
+		h := Hook{}
+		h.URL, _ = m["url"].(string)
+		if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+			return nil, fmt.Errorf("webhook: invalid url %q", h.URL)
+		}
+		h.Secret, _ = m["secret"].(string)
+		if events, ok := m["events"].([]interface{}); ok {
+			for _, e := range events {
+				h.Events = append(h.Events, fmt.Sprint(e))
+			}
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, nil
+}
+
+// wants 是否接收该事件
+func (h Hook) wants(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign 请求体的签名，接收方用同样的密钥计算后比较
+func Sign(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	_, _ = m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// delivery 一次投递
+type delivery struct {
+	hook Hook
+	id   string
+	e    Event
+	body []byte
+}
+
+// Dispatcher 异步投递，队列满时丢弃
+type Dispatcher struct {
+	// Retries 失败重试次数
+	Retries int
+	// Backoff 首次重试间隔，之后每次翻倍
+	Backoff time.Duration
+	// OnError 重试后仍失败的回调(可选)
+	OnError func(h Hook, e Event, err error)
+	Client  *http.Client
+
+	mu    sync.RWMutex
+	hooks []Hook
+	queue chan delivery
+}
+
+// New 创建投递器
+func New(hooks []Hook) *Dispatcher {
+	return &Dispatcher{
+		Retries: 3,
+		Backoff: 2 * time.Second,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		hooks:   hooks,
+		queue:   make(chan delivery, 256),
+	}
+}
+
+// SetHooks 替换接收地址
+func (d *Dispatcher) SetHooks(hooks []Hook) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks = hooks
+}
+
+// Hooks 当前的接收地址
+func (d *Dispatcher) Hooks() []Hook {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]Hook{}, d.hooks...)
+}
+
+// Start 启动投递协程
+func (d *Dispatcher) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for dl := range d.queue {
+				err := d.post(dl)
+				for wait, n := d.Backoff, 0; err != nil && n < d.Retries; wait, n = wait*2, n+1 {
+					time.Sleep(wait)
+					err = d.post(dl)
+				}
+				if err != nil && d.OnError != nil {
+					d.OnError(dl.hook, dl.e, err)
+				}
+			}
+		}()
+	}
+}
+
+// Send 向接收该事件的地址投递，返回加入队列的个数
+func (d *Dispatcher) Send(e Event) int {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, h := range d.Hooks() {
+		if !h.wants(e.Event) {
+			continue
+		}
+		select {
+		case d.queue <- delivery{hook: h, id: newID(), e: e, body: body}:
+			n++
+		default:
+		}
+	}
+	return n
+}
+
+// post 发送一次，2xx视为成功
+func (d *Dispatcher) post(dl delivery) error {
+	req, err := http.NewRequest(http.MethodPost, dl.hook.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, dl.e.Event)
+	// 重试时ID不变，接收方可据此去重
+	req.Header.Set(HeaderDelivery, dl.id)
+	if dl.hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(dl.hook.Secret, dl.body))
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s: %s", dl.hook.URL, resp.Status)
+	}
+	return nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDispatcher(t *testing.T) {
+	got := make(chan Event, 1)
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// 第一次失败，验证重试
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(HeaderSignature) != Sign("s3cret", body) || r.Header.Get(HeaderEvent) != "upload" {
+			t.Errorf("bad headers %v", r.Header)
+		}
+		var e Event
+		_ = json.Unmarshal(body, &e)
+		got <- e
+	}))
+	defer srv.Close()
+
+	hooks, err := Parse([]interface{}{
+		map[string]interface{}{"url": srv.URL, "secret": "s3cret", "events": []interface{}{"upload"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(hooks)
+	d.Backoff = time.Millisecond
+	d.Start(1)
+	if n := d.Send(Event{Event: "delete", Path: "/files/a.txt"}); n != 0 {
+		t.Error("unsubscribed event sent")
+	}
+	d.Send(Event{Event: "upload", Path: "/files/a.txt", Size: 3, Hash: "abc"})
+	select {
+	case e := <-got:
+		if e.Path != "/files/a.txt" || e.Size != 3 || e.Hash != "abc" {
+			t.Errorf("bad event %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("not delivered")
+	}
+	if _, err := Parse([]interface{}{map[string]interface{}{"url": "ftp://x"}}); err == nil {
+		t.Error("invalid url accepted")
+	}
+}
@@ -3,14 +3,13 @@ package main
 import (
 	"b0pass/boot"
 	_ "b0pass/boot"
+	"b0pass/library/logger"
 	"b0pass/library/openurl"
 	_ "b0pass/router"
 	"fmt"
 	"github.com/gogf/gf/frame/g"
 	"github.com/zserge/lorca"
 	"log"
-	"os"
-	"os/signal"
 	"runtime"
 	"strconv"
 	"time"
@@ -20,8 +19,12 @@ func main() {
 
 	//处理命令行参数
 	boot.ExecArgs()
-	fmt.Printf("[ServerUrl] http://127.0.0.1:%d\n",boot.ServPort)
+	fmt.Printf("[ServerUrl] http://127.0.0.1:%d%s/\n",boot.ServPort,boot.BasePath)
+	if u := boot.HTTPSURL(); u != "" {
+		fmt.Printf("[HTTPS-Url] %s\n", u)
+	}
 	fmt.Printf("[Work-Path] %s\n",boot.PathRoot)
+	boot.PrintQR(boot.AccessURL(boot.ServPort, boot.BasePath+"/"))
 
 	//是否开启GUI模式
 	//判断是否安装谷歌浏览器
@@ -33,7 +36,7 @@ func main() {
 		//打开浏览器
 		go func() {
 			time.Sleep(1000 * time.Millisecond)
-			_ = openurl.Open("http://127.0.0.1:" + strconv.Itoa(boot.ServPort))
+			_ = openurl.Open("http://127.0.0.1:" + strconv.Itoa(boot.ServPort) + boot.BasePath + "/")
 		}()
 		g.Wait()
 	}
@@ -71,16 +74,11 @@ func main() {
 		"127.0.0.1:"+g.Config().GetString("setting.port")),
 	)
 
-	// Wait until the interrupt signal arrives
-	// or browser window is closed
-	sigc := make(chan os.Signal)
-	signal.Notify(sigc, os.Interrupt)
-	select {
-	case <-sigc:
-	case <-ui.Done():
-	}
+	// Close UI when interrupted(boot handles the signal and waits for transfers)
+	boot.OnShutdown(func() { _ = ui.Close() })
 
-	// Close UI
-	log.Println("exiting...")
-	_ = g.Server().Shutdown()
+	// Wait until the browser window is closed
+	<-ui.Done()
+	logger.Info("app", "exiting")
+	boot.Shutdown("window closed")
 }
\ No newline at end of file
@@ -0,0 +1,74 @@
+package router
+
+import (
+	"b0pass/apps/api"
+	"b0pass/boot"
+	"b0pass/library/proxy"
+	"github.com/gogf/gf/net/ghttp"
+	"strings"
+)
+
+// server 注册路由时加上路径前缀(--base-path)，使全部路由在反向代理的子路径下可用；
+// 请求前的钩子先执行prepare，之后的钩子与接口看到的是真实的客户端地址，
+// 请求路径保持不变(含前缀)，按路径判断的地方用boot.TrimBase
+type server struct {
+	*ghttp.Server
+	base string
+}
+
+// pattern 路由规则加上前缀，支持 METHOD:/path 形式
+func (s server) pattern(p string) string {
+	if i := strings.Index(p, ":/"); i >= 0 && !strings.Contains(p[:i], "/") {
+		return p[:i+1] + s.base + p[i+1:]
+	}
+	return s.base + p
+}
+
+func (s server) BindHandler(pattern string, handler ghttp.HandlerFunc) {
+	s.Server.BindHandler(s.pattern(pattern), handler)
+}
+
+func (s server) BindController(pattern string, c ghttp.Controller, methods ...string) {
+	s.Server.BindController(s.pattern(pattern), c, methods...)
+}
+
+func (s server) BindHookHandler(pattern string, hook string, handler ghttp.HandlerFunc) {
+	if hook == ghttp.HOOK_BEFORE_SERVE {
+		handler = prepared(handler)
+	}
+	s.Server.BindHookHandler(s.pattern(pattern), hook, handler)
+}
+
+func (s server) BindHookHandlerByMap(pattern string, hookMap map[string]ghttp.HandlerFunc) {
+	for hook, handler := range hookMap {
+		s.BindHookHandler(pattern, hook, handler)
+	}
+}
+
+func (s server) Group(prefix string, groups ...func(g *ghttp.RouterGroup)) *ghttp.RouterGroup {
+	return s.Server.Group(s.base+prefix, groups...)
+}
+
+// prepared 钩子执行前先执行prepare；钩子按路由层级而非注册顺序执行，因此每个钩子都需要
+func prepared(handler ghttp.HandlerFunc) ghttp.HandlerFunc {
+	return func(r *ghttp.Request) {
+		prepare(r)
+		handler(r)
+	}
+}
+
+// prepare 经隧道的请求改为公网客户端地址，其他请求按受信任的代理改写客户端地址，不受信任时删除X-Forwarded-*
+// 并记录经过了不受信任的代理；同一请求只处理一次
+func prepare(r *ghttp.Request) {
+	if r.GetParam("b0pass.prepared") != nil {
+		return
+	}
+	r.SetParam("b0pass.prepared", true)
+	if boot.TunnelOrigin(r.Request) {
+		return
+	}
+	forwarded := proxy.Forwarded(r.Request)
+	if !boot.Proxies.Apply(r.Request) && forwarded {
+		api.MarkUntrusted(r)
+	}
+}
@@ -0,0 +1,74 @@
+package router
+
+import (
+	"b0pass/boot"
+	"b0pass/library/openapi"
+	"github.com/gogf/gf/net/ghttp"
+)
+
+// OpenAPI 由路由定义生成的OpenAPI 3文档
+func OpenAPI(r *ghttp.Request) {
+	doc := openapi.Build(openapi.Info{
+		Title:       "B0Pass",
+		Version:     "1.0",
+		Description: "局域网文件传输接口。除文件类响应外均返回{err,msg,data}，err为0时成功。移动设备配对后使用Bearer令牌访问",
+		BasicAuth:   basicAuth(),
+		Bearer:      true,
+	}, apiOperations())
+	_ = r.Response.WriteJson(doc)
+}
+
+// Docs 接口文档页面，优先使用Swagger UI(需访问CDN)，离线时显示简易列表
+func Docs(r *ghttp.Request) {
+	r.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	r.Response.Write(docsPage)
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <title>B0Pass API</title>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+    <style>
+        #fallback { font-family: sans-serif; margin: 20px; }
+        #fallback td { padding: 4px 10px; border-bottom: 1px solid #eee; }
+        #fallback code { color: #c7254e; }
+    </style>
+</head>
+<body>
+<div id="swagger-ui"></div>
+<div id="fallback"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+    var spec = "openapi.json".replace(/^/, location.pathname.replace(/\/?$/, "/"));
+    if (window.SwaggerUIBundle) {
+        SwaggerUIBundle({url: spec, dom_id: "#swagger-ui"});
+    } else {
+        fetch(spec).then(function (resp) { return resp.json(); }).then(function (doc) {
+            var html = "<h2>" + doc.info.title + " API</h2><p>" + doc.info.description +
+                "</p><p><a href='" + spec + "'>openapi.json</a></p><table>";
+            Object.keys(doc.paths).sort().forEach(function (p) {
+                Object.keys(doc.paths[p]).forEach(function (m) {
+                    html += "<tr><td><b>" + m.toUpperCase() + "</b></td><td><code>" + p +
+                        "</code></td><td>" + doc.paths[p][m].summary + "</td></tr>";
+                });
+            });
+            document.getElementById("fallback").innerHTML = html + "</table>";
+        });
+    }
+</script>
+</body>
+</html>
+`
+
+// basicAuth 是否启用了使用HTTP Basic账号的认证方式
+func basicAuth() bool {
+	for _, name := range boot.Auth().Names() {
+		if name == "basic" || name == "ldap" {
+			return true
+		}
+	}
+	return false
+}
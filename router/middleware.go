@@ -1,10 +1,278 @@
 package router
 
-import "github.com/gogf/gf/net/ghttp"
+import (
+	"b0pass/apps/api"
+	"b0pass/boot"
+	"b0pass/library/authn"
+	"b0pass/library/fileinfos"
+	"b0pass/library/httpcache"
+	"b0pass/library/limiter"
+	"b0pass/library/proxy"
+	"b0pass/library/response"
+	"fmt"
+	"github.com/gogf/gf/frame/g"
+	"github.com/gogf/gf/net/ghttp"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// previewLimiter 预览类请求并发限制器
+var previewLimiter = limiter.New(
+	g.Config().GetInt("preview.global", 4),
+	g.Config().GetInt("preview.client", 2),
+	g.Config().GetInt("preview.queue", 32),
+)
+
+// previewPlaceholder 预览繁忙时返回的占位图
+const previewPlaceholder = `<svg xmlns="http://www.w3.org/2000/svg" width="64" height="64">` +
+	`<rect width="64" height="64" fill="#eee"/></svg>`
+
+// hostPaths 仅主电脑可用的接口，不返回CORS响应头，主电脑浏览器中打开的其他网页无法跨域读取或提交
+var hostPaths = hostRoutes()
 
 func MiddlewareCORS(r *ghttp.Request) {
+	if hostPaths[boot.TrimBase(r.URL.Path)] {
+		r.Middleware.Next()
+		return
+	}
 	corsOptions := r.Response.DefaultCORSOptions()
 	corsOptions.AllowDomain = []string{"*"}
+	// 跨域页面读取列表总数
+	corsOptions.ExposeHeaders = "X-Total-Count"
 	r.Response.CORS(corsOptions)
 	r.Middleware.Next()
 }
+
+// HookAuth 访问认证，按[auth]配置的认证方式依次尝试，未配置时不启用
+func HookAuth(r *ghttp.Request) {
+	chain := boot.Auth()
+	if len(chain) == 0 {
+		return
+	}
+	p := boot.TrimBase(r.URL.Path)
+	// 移动设备配对与刷新接口由配对码与刷新令牌认证
+	if p == "/api/device/pair" || p == "/api/device/refresh" {
+		return
+	}
+	// 收集、分享与加密文件链接由随机ID认证，发送者与接收方无需账号
+	if strings.HasPrefix(p, "/in/") || strings.HasPrefix(p, "/s/") || strings.HasPrefix(p, "/e/") {
+		return
+	}
+	// ACME服务验证域名时无法登录
+	if strings.HasPrefix(r.URL.Path, boot.ACMEChallengePath) {
+		return
+	}
+	// 客户端引导页只提供公开发布的程序，新电脑无需账号即可下载
+	if p == "/get" || strings.HasPrefix(p, "/get/") {
+		return
+	}
+	id, err := chain.Authenticate(r.Response.Writer, r.Request)
+	if err == nil {
+		api.SetIdentity(r, id)
+		return
+	}
+	if !chain.Challenge(r.Response.Writer, r.Request) {
+		r.Response.WriteHeader(http.StatusUnauthorized)
+	}
+	r.ExitAll()
+}
+
+// HookClient 记录访问的设备，打开网页界面时为浏览器设置设备标识
+func HookClient(r *ghttp.Request) {
+	api.TrackClient(r)
+}
+
+// HookStaticCache 静态资源设置ETag与Last-Modified，浏览器再次访问未变化的资源时得到304；开发模式下不缓存
+func HookStaticCache(r *ghttp.Request) {
+	if !r.IsFileRequest() {
+		return
+	}
+	if boot.Dev {
+		r.Response.Header().Set("Cache-Control", "no-store")
+		return
+	}
+	info, err := os.Stat(boot.UIPath("public" + path.Clean("/"+boot.TrimBase(r.URL.Path))))
+	if err != nil || info.IsDir() {
+		return
+	}
+	httpcache.Set(r.Response.Header(), info)
+}
+
+// HookDevPage 开发模式下静态页面加入自动刷新脚本后返回，不存在时交给静态文件服务
+func HookDevPage(r *ghttp.Request) {
+	if !boot.Dev || !r.IsFileRequest() || path.Ext(r.URL.Path) != ".html" {
+		return
+	}
+	b, err := ioutil.ReadFile(boot.UIPath("public" + path.Clean("/"+boot.TrimBase(r.URL.Path))))
+	if err != nil {
+		return
+	}
+	r.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	r.Response.Write(boot.DevInject(b))
+	r.ExitAll()
+}
+
+// HookDevInject 开发模式下模板渲染的页面加入自动刷新脚本
+func HookDevInject(r *ghttp.Request) {
+	if !boot.Dev || r.Response.BufferLength() == 0 {
+		return
+	}
+	ct := r.Response.Header().Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(r.Response.Buffer())
+	}
+	if strings.HasPrefix(ct, "text/html") {
+		r.Response.SetBuffer(boot.DevInject(r.Response.Buffer()))
+	}
+}
+
+// HookStopping 退出期间拒绝新请求
+func HookStopping(r *ghttp.Request) {
+	if !boot.Stopping() {
+		return
+	}
+	r.Response.Header().Set("Connection", "close")
+	r.Response.Header().Set("Retry-After", "30")
+	r.Response.WriteStatus(http.StatusServiceUnavailable, "server is shutting down")
+	r.ExitAll()
+}
+
+// HookHTTPS 启用自动证书后，经HTTP访问证书域名的请求跳转到HTTPS；启用HTTP/3时HTTPS响应通告Alt-Svc
+func HookHTTPS(r *ghttp.Request) {
+	if r.TLS != nil && boot.AltSvc != "" {
+		r.Response.Header().Set("Alt-Svc", boot.AltSvc)
+	}
+	target := boot.RedirectHTTPS(r.Request, proxy.Scheme(r.Request))
+	if target == "" {
+		return
+	}
+	code := http.StatusPermanentRedirect
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+	r.Response.Header().Set("Location", target)
+	r.Response.WriteHeader(code)
+	r.ExitAll()
+}
+
+// HookTransfer 传输期间阻止系统休眠并计入退出时等待的传输，请求结束(含客户端断开)时释放
+func HookTransfer(r *ghttp.Request) {
+	release := boot.BeginTransfer()
+	go func() {
+		<-r.Context().Done()
+		release()
+	}()
+}
+
+// HookBandwidth 上传请求体按设备平分带宽读取
+func HookBandwidth(r *ghttp.Request) {
+	if r.Body != nil {
+		r.Body = boot.Bandwidth.Reader(api.ClientKey(r), r.Body)
+	}
+}
+
+// HookUploadSlot 上传请求占用一个上传名额(upload.slots)，没有空闲名额时排队等待upload.wait秒，
+// 仍未轮到时返回429、Retry-After与排队位置，设备按Retry-After重试时保持原来的位置；请求结束(含客户端断开)时释放
+func HookUploadSlot(r *ghttp.Request) {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return
+	}
+	wait := time.Duration(g.Config().GetInt("upload.wait", 5)) * time.Second
+	release, position := boot.UploadSlots.Wait(r.Context(), api.ClientKey(r), wait)
+	if release != nil {
+		go func() {
+			<-r.Context().Done()
+			release()
+		}()
+		return
+	}
+	_, active, queued := boot.UploadSlots.Stats()
+	msg := fmt.Sprintf("上传繁忙，排在第%d位，请稍后重试", position)
+	if position == 0 {
+		msg = "上传排队已满，请稍后重试"
+	}
+	r.Response.Header().Set("Retry-After", strconv.Itoa(g.Config().GetInt("upload.retry", 3)))
+	r.Response.Header().Set("X-Queue-Position", strconv.Itoa(position))
+	r.Response.WriteHeader(http.StatusTooManyRequests)
+	_ = r.Response.WriteJson(g.Map{"err": 429, "msg": msg, "data": g.Map{"position": position, "active": active, "queued": queued}})
+	r.ExitAll()
+}
+
+// Writable 写操作接口，只读模式下拒绝(临时授权的访客设备除外)
+// 不使用分组中间件: gf的分组中间件作用于整个/api前缀，会连同只读接口一起拒绝
+func Writable(h ghttp.HandlerFunc) ghttp.HandlerFunc {
+	return func(r *ghttp.Request) {
+		if !api.CanWrite(r) {
+			if id, _ := api.Identity(r); id.Role == authn.RoleGuest {
+				response.Error(r, http.StatusForbidden, 403, "访客只能浏览与下载")
+			}
+			response.Error(r, http.StatusForbidden, 403, "只读模式，禁止写操作")
+		}
+		h(r)
+	}
+}
+
+// Preview 预览类接口(缩略图等)限流，不使用分组中间件的原因同Writable
+func Preview(h ghttp.HandlerFunc) ghttp.HandlerFunc {
+	return func(r *ghttp.Request) {
+		release, ok := acquirePreview(r)
+		if !ok {
+			return
+		}
+		defer release()
+		h(r)
+	}
+}
+
+// MiddlewarePreview 预览类接口限流
+func MiddlewarePreview(r *ghttp.Request) {
+	release, ok := acquirePreview(r)
+	if !ok {
+		return
+	}
+	defer release()
+	r.Middleware.Next()
+}
+
+// HookPreviewBefore 静态图片请求限流(画廊视图批量加载原图)
+func HookPreviewBefore(r *ghttp.Request) {
+	if !fileinfos.IfImage(r.URL.Path) {
+		return
+	}
+	if release, ok := acquirePreview(r); ok {
+		r.SetParam("preview_release", release)
+	}
+}
+
+// HookPreviewAfter 静态图片输出完成后释放名额
+func HookPreviewAfter(r *ghttp.Request) {
+	if release, ok := r.GetParam("preview_release").(func()); ok {
+		release()
+	}
+}
+
+// acquirePreview 申请预览名额，失败时输出429或占位图
+func acquirePreview(r *ghttp.Request) (func(), bool) {
+	wait := g.Config().GetInt("preview.wait", 10)
+	release, ok := previewLimiter.Acquire(r.GetClientIp(), time.Duration(wait)*time.Second)
+	if ok {
+		return release, true
+	}
+	r.Response.Header().Set("Retry-After", strconv.Itoa(wait))
+	if strings.Contains(r.Header.Get("Accept"), "image/") {
+		r.Response.Header().Set("Content-Type", "image/svg+xml")
+		r.Response.Header().Set("Cache-Control", "no-store")
+		r.Response.WriteStatus(http.StatusTooManyRequests, previewPlaceholder)
+	} else {
+		r.Response.WriteStatus(http.StatusTooManyRequests)
+	}
+	r.ExitAll()
+	return nil, false
+}
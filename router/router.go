@@ -4,12 +4,37 @@ import (
 	"b0pass/apps/api"
 	"b0pass/apps/index"
 	"b0pass/apps/sync"
+	"b0pass/boot"
 	"github.com/gogf/gf/frame/g"
 	"github.com/gogf/gf/net/ghttp"
 )
 
 func init() {
-	s := g.Server()
+	s := server{Server: g.Server(), base: boot.BasePath}
+
+	// Shutdown
+	s.BindHookHandler("/*any", ghttp.HOOK_BEFORE_SERVE, HookStopping)
+
+	// HTTPS
+	s.BindHookHandler("/*any", ghttp.HOOK_BEFORE_SERVE, HookHTTPS)
+
+	// Auth
+	s.BindHookHandler("/*any", ghttp.HOOK_BEFORE_SERVE, HookAuth)
+
+	// Clients
+	s.BindHookHandler("/*any", ghttp.HOOK_BEFORE_SERVE, HookClient)
+
+	// Static assets
+	s.BindHookHandler("/*any", ghttp.HOOK_BEFORE_SERVE, HookStaticCache)
+
+	// Dev (--dev)
+	s.BindHookHandlerByMap("/*any", map[string]ghttp.HandlerFunc{
+		ghttp.HOOK_BEFORE_SERVE:  HookDevPage,
+		ghttp.HOOK_BEFORE_OUTPUT: HookDevInject,
+	})
+
+	// ACME http-01 (不加路径前缀)
+	s.Server.BindHandler("GET:"+boot.ACMEChallengePath+":token", api.ACMEChallenge)
 
 	// Index
 	s.BindController("/", new(index.Controller))
@@ -18,21 +43,90 @@ func init() {
 	//s.BindController("/chat", new(chat.Controller))
 	s.BindController("/sync", new(sync.Controller))
 
+	// Files
+	s.BindHandler("/files/*any", api.Files)
+
+	// Plain text (curl)
+	for _, method := range []string{"PUT", "POST"} {
+		s.BindHandler(method+":/up/*any", Writable(api.PlainUpload))
+	}
+	s.BindHandler("GET:/ls/*any", api.PlainList)
+	s.BindHandler("/f/*any", api.PlainFile)
+
+	// Share
+	for _, method := range []string{"GET", "HEAD"} {
+		s.BindHandler(method+":/s/:id", api.ShareFile)
+	}
+
+	// End-to-end encrypted
+	s.BindHandler("GET:/e/:id", api.E2EPage)
+	s.BindHandler("GET:/e/:id/info", api.E2EInfo)
+	for _, method := range []string{"GET", "HEAD"} {
+		s.BindHandler(method+":/e/:id/blob", api.E2EBlob)
+		s.BindHandler(method+":/e/:id/plain", api.E2EPlain)
+	}
+
+	// Inbox
+	s.BindHandler("GET:/in/:id", api.InboxPage)
+	for _, method := range []string{"PUT", "POST"} {
+		s.BindHandler(method+":/in/:id", Writable(api.InboxUpload))
+	}
+
+	// Client bootstrap
+	s.BindHandler("GET:/get", api.GetPage)
+	s.BindHandler("GET:/get/info", api.GetInfo)
+	s.BindHandler("GET:/get/install.sh", api.GetScript)
+	s.BindHandler("GET:/get/b0pass", api.GetBinary)
+
+	// Transfer
+	for _, pattern := range transferPatterns() {
+		s.BindHookHandler(pattern, ghttp.HOOK_BEFORE_SERVE, HookTransfer)
+	}
+
+	// Bandwidth
+	for _, pattern := range append(uploadPatterns, relayPattern) {
+		s.BindHookHandler(pattern, ghttp.HOOK_BEFORE_SERVE, HookBandwidth)
+	}
+
+	// Upload slots
+	for _, pattern := range uploadPatterns {
+		s.BindHookHandler(pattern, ghttp.HOOK_BEFORE_SERVE, HookUploadSlot)
+	}
+
+	// Preview
+	s.BindHookHandlerByMap("/files/*any", map[string]ghttp.HandlerFunc{
+		ghttp.HOOK_BEFORE_SERVE: HookPreviewBefore,
+		ghttp.HOOK_AFTER_OUTPUT: HookPreviewAfter,
+	})
+
+	// WebDAV
+	s.BindHandler("/dav/*any", api.DAV)
+
 	// Api
 	s.Group("/api", func(g *ghttp.RouterGroup) {
 		//cors
 		g.Middleware(MiddlewareCORS)
-		//file
-		g.POST("/upload", api.Upload)
-		g.GET("/lists", api.Lists)
-		g.GET("/delete", api.Delete)
-		g.GET("/dump", api.Dump)
-		g.GET("/upload", api.UploadShow)
-		//server
-		g.GET("/sip", api.GetIp)
-		g.ALL("/subpath", api.GetSubPath)
-		g.ALL("/textdata", api.GetTextData)
-		g.GET("/openurl",api.OpenUrl)
+		bindRoutes(g)
+		//docs
+		g.GET("/docs", Docs)
+		g.GET("/docs/openapi.json", OpenAPI)
+		//dev
+		g.GET("/dev/reload", api.DevReload)
 	})
 
 }
+
+// uploadPatterns 上传请求: 传输期间阻止休眠、按设备平分带宽并占用上传名额
+var uploadPatterns = []string{"/up/*any", "/in/:id", "/dav/*any", "/api/upload", "/api/upload/tar", "/api/tus/*any", "/api/device/upload", "/api/e2e"}
+
+// downloadPatterns 下载请求: 传输期间阻止休眠
+var downloadPatterns = []string{"/files/*any", "/f/*any", "/s/:id", "/api/zip", "/e/:id/blob", "/e/:id/plain"}
+
+// relayPattern 中转同时是上传与下载，计入带宽但不占用上传名额
+const relayPattern = "/api/relay/:id"
+
+// transferPatterns 退出时等待完成的传输请求
+func transferPatterns() []string {
+	patterns := append([]string{relayPattern}, uploadPatterns...)
+	return append(patterns, downloadPatterns...)
+}
@@ -0,0 +1,370 @@
+package router
+
+import (
+	"b0pass/apps/api"
+	"b0pass/library/openapi"
+	"github.com/gogf/gf/net/ghttp"
+	"net/http"
+)
+
+// apiRoute /api下的接口，同时用于绑定路由与生成OpenAPI文档(/api/docs)
+type apiRoute struct {
+	openapi.Operation
+	handler ghttp.HandlerFunc
+	// writable 写操作，只读模式下拒绝
+	writable bool
+	// preview 预览类接口，按preview配置限制并发
+	preview bool
+	// host 仅主电脑可用的接口，不允许跨域访问
+	host bool
+}
+
+var (
+	paramPath       = openapi.Param{Name: "path", In: "form", Desc: "上传子目录"}
+	paramMtime      = openapi.Param{Name: "mtime", In: "form", Type: "integer", Desc: "修改时间(Unix秒)"}
+	paramReceipt    = openapi.Param{Name: "receipt", Type: "boolean", Desc: "为1时返回签名的上传回执"}
+	paramEmail      = openapi.Param{Name: "email", Desc: "同时将回执发送到该邮箱(需配置receipt.smtp)"}
+	paramDurability = openapi.Param{Name: "durability", Desc: "同步到磁盘的时机: none、on-complete或per-chunk，缺省为upload.durability(也可用X-Durability头)"}
+	paramConflict   = openapi.Param{Name: "conflict", Desc: "同名文件已存在时: overwrite覆盖、skip跳过、rename自动重命名或fail返回409，缺省为upload.conflict(也可用X-Conflict头)"}
+	paramLocale     = openapi.Param{Name: "locale", Desc: "返回信息与大小、日期等文字的语言: zh或en，缺省按Accept-Language"}
+	paramAll        = openapi.Param{Name: "all", Type: "boolean", Desc: "为1时不隐藏点文件、系统文件与[hidden]中的通配符"}
+	paramExclude    = openapi.Param{Name: "exclude", Desc: "追加隐藏的通配符，逗号分隔，如 *.log,build/*"}
+	errUpload       = map[int]string{
+		http.StatusBadRequest:          "参数错误",
+		http.StatusForbidden:           "只读模式",
+		http.StatusConflict:            "分片offset与已上传大小不一致，或同名文件已存在(conflict=fail)",
+		http.StatusTooManyRequests:     "上传名额已满，按Retry-After重试(X-Queue-Position为排队位置)",
+		http.StatusUnprocessableEntity: "校验值不一致，上传已丢弃",
+		http.StatusInsufficientStorage: "磁盘空间不足",
+	}
+)
+
+var apiRoutes = []apiRoute{
+	{Operation: openapi.Operation{Method: "POST", Path: "/upload", Tag: "上传", Summary: "上传文件，携带id时为分片续传(id由协商接口得到)；在文件之前用files字段声明全部文件时可在一个请求中上传多个文件，每个文件接收完立即保存，各文件的进度推送progress事件",
+		Params: []openapi.Param{
+			{Name: "upload-file", In: "form", Type: "file", Required: true},
+			paramPath, paramMtime,
+			{Name: "id", In: "form", Desc: "分片上传ID"},
+			{Name: "offset", In: "form", Type: "integer", Desc: "分片起始位置"},
+			{Name: "size", In: "form", Type: "integer", Desc: "文件总大小(分片上传必填)"},
+			{Name: "algo", In: "form", Desc: "校验算法，默认sha256"},
+			{Name: "hash", In: "form", Desc: "整个文件的校验值"},
+			{Name: "progress", In: "form", Desc: "进度ID，与推送的progress事件及/api/upload/progress对应"},
+			{Name: "files", In: "form", Desc: `声明的文件(JSON)，如 [{"name":"a.jpg","size":123,"hash":""}]，此时其它字段须位于文件之前，部分文件失败时返回207`},
+			paramReceipt, paramEmail, paramDurability, paramConflict,
+		}, Errors: errUpload}, handler: api.Upload, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/upload/progress", Tag: "上传", Summary: "当前设备的上传请求中各文件的进度(queued、active、done、failed)，请求结束后保留10分钟",
+		Params: []openapi.Param{{Name: "id", Required: true, Desc: "上传时progress字段的值"}}}, handler: api.UploadProgress},
+	{Operation: openapi.Operation{Method: "GET", Path: "/upload/slots", Tag: "上传", Summary: "上传名额(upload.slots): 上限、进行中的上传数、排队的设备数及当前设备的排队位置；名额已满时上传请求排队等待upload.wait秒后返回429与Retry-After"}, handler: api.UploadSlots},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/upload/negotiate", Tag: "上传", Summary: "上传前协商: 续传、秒传、重命名、跳过、同名冲突或空间不足",
+		Params: []openapi.Param{
+			{Name: "name", Required: true}, {Name: "path"},
+			{Name: "size", Type: "integer"}, {Name: "hash"}, {Name: "algo"},
+			{Name: "algos", Desc: "客户端支持的校验算法，逗号分隔"}, paramConflict,
+		}}, handler: api.Negotiate, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/filename", Tag: "上传", Summary: "文件名保存时的调整结果: Unicode规范化、替换Windows与Android上不允许的字符、避开保留名与长度限制",
+		Params: []openapi.Param{
+			{Name: "name", Required: true, Desc: "文件名，可指定多个"},
+			{Name: "form", Desc: "规范化形式nfc或nfd，缺省为filename.normalize"},
+		}}, handler: api.Filename},
+	{Operation: openapi.Operation{Method: "POST,OPTIONS", Path: "/tus", Tag: "上传", Summary: "tus 1.0创建上传",
+		Raw: "tus协议响应(201 Location)", Errors: errUpload}, handler: api.Tus, writable: true},
+	{Operation: openapi.Operation{Method: "HEAD,PATCH,DELETE", Path: "/tus/*any", Tag: "上传", Summary: "tus 1.0查询进度、追加数据与终止上传",
+		Raw: "tus协议响应(204 Upload-Offset)", Errors: errUpload}, handler: api.Tus, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/delete", Tag: "文件", Summary: "删除文件或目录，启用回收站时移入回收站，项的ID在X-Trash-Id响应头中",
+		Params: []openapi.Param{{Name: "f", Required: true, Desc: "如 /files/a.txt"}, {Name: "permanent", Type: "boolean", Desc: "为1时直接删除，不移入回收站"}},
+		Errors: map[int]string{http.StatusNotFound: "文件不存在"}}, handler: api.Delete, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/trash", Tag: "回收站", Summary: "回收站中的项(原路径、大小、删除时间与到期时间)，按删除时间从新到旧排序"}, handler: api.TrashList},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/trash/restore", Tag: "回收站", Summary: "将回收站中的项还原到原路径，缺少的上级目录会被创建",
+		Params: []openapi.Param{{Name: "id", Required: true}, {Name: "to", Desc: "还原到新的位置，如 /files/docs/a(1).txt"}},
+		Errors: map[int]string{http.StatusNotFound: "回收站中没有该项", http.StatusConflict: "目标位置已有同名文件"}}, handler: api.TrashRestore, writable: true},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/trash/purge", Tag: "回收站", Summary: "彻底删除回收站中的项",
+		Params: []openapi.Param{{Name: "id", Desc: "为空时清空全部回收站"}}}, handler: api.TrashPurge, writable: true},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/style", Tag: "文件", Summary: "设置共享根目录或文件夹的图标、颜色与说明(列表接口返回icon/color/desc)，参数为空时清除",
+		Params: []openapi.Param{{Name: "f", Required: true, Desc: "如 /files/photos"}, {Name: "icon", Desc: "图标(emoji或图标名)"},
+			{Name: "color", Desc: "#rgb、#rrggbb或颜色名"}, {Name: "desc", Desc: "说明，最长200字"}}}, handler: api.Style, writable: true},
+	{Operation: openapi.Operation{Method: "POST", Path: "/batch", Tag: "上传", Summary: "创建目录树上传会话，返回每个文件的续传状态",
+		JSON: `{"path":"目标目录","files":[{"path":"sub/a.txt","size":1,"mtime":0}]}`, Errors: errUpload}, handler: api.BatchCreate, writable: true},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/batch/done", Tag: "上传", Summary: "结束目录树上传会话",
+		Params: []openapi.Param{{Name: "id", Required: true}}}, handler: api.BatchDone, writable: true},
+
+	{Operation: openapi.Operation{Method: "PUT,POST,HEAD,GET", Path: "/device/upload", Tag: "设备", Summary: "移动端分片上传: HEAD查询Upload-Offset，PUT从Upload-Offset追加原始数据，中断时已接收的数据保留",
+		Params: []openapi.Param{
+			{Name: "name", Required: true}, {Name: "size", Type: "integer", Required: true}, {Name: "path"},
+			{Name: "mtime", Type: "integer"}, {Name: "algo"}, {Name: "hash"}, paramReceipt, paramEmail, paramDurability, paramConflict,
+			{Name: "Upload-Offset", In: "header", Type: "integer", Desc: "PUT/POST时必填，须与已接收的大小一致"},
+		}, Errors: map[int]string{
+			http.StatusUnauthorized:        "访问令牌无效或已过期",
+			http.StatusConflict:            "Upload-Offset与已接收的大小不一致，或同名文件已存在(conflict=fail)",
+			http.StatusUnprocessableEntity: "校验值不一致，上传已丢弃",
+			http.StatusInsufficientStorage: "磁盘空间不足",
+		}}, handler: api.DeviceUpload, writable: true},
+
+	{Operation: openapi.Operation{Method: "GET", Path: "/device", Tag: "设备", Summary: "当前设备的标识与名称(浏览器首次访问时设置设备标识Cookie)"}, handler: api.DeviceSelf},
+	{Operation: openapi.Operation{Method: "PUT", Path: "/device", Tag: "设备", Summary: "设置当前设备的名称，用于传输记录、收集链接的文件名与在线设备列表，为空时恢复按User-Agent识别的默认名称",
+		JSON: `{"name":"小明的iPhone"}`}, handler: api.DeviceRename},
+	{Operation: openapi.Operation{Method: "GET", Path: "/clients", Tag: "设备", Summary: "最近访问过的设备及其名称",
+		Params: []openapi.Param{{Name: "minutes", Type: "integer", Desc: "最近多少分钟内，默认10"}}}, handler: api.ClientList},
+	{Operation: openapi.Operation{Method: "GET", Path: "/events", Tag: "设备", Summary: "服务端推送(text/event-stream): 收到新文件等通知及其他设备发来的文件", Raw: "text/event-stream",
+		Params: []openapi.Param{{Name: "types", Desc: "只接收的事件类型，逗号分隔，如 upload,handoff"}}}, handler: api.Events},
+	{Operation: openapi.Operation{Method: "POST", Path: "/send", Tag: "设备", Summary: "向另一台在线设备发送文件或消息，对方收到handoff事件",
+		JSON:   `{"to":"设备key","file":"/files/a.pdf","text":"附言"}`,
+		Errors: map[int]string{http.StatusNotFound: "设备不在线或文件不存在"}}, handler: api.Send},
+	{Operation: openapi.Operation{Method: "POST", Path: "/relay", Tag: "设备", Summary: "创建设备直传，发送方上传的数据经服务端直接转给接收方而不写入磁盘；to为接收设备的key，对方收到relay事件",
+		JSON: `{"to":"设备key","name":"a.zip","size":123,"spool":false}`}, handler: api.RelayCreate},
+	{Operation: openapi.Operation{Method: "GET", Path: "/relay", Tag: "设备", Summary: "发给当前设备、还没有开始接收的直传"}, handler: api.RelayList},
+	{Operation: openapi.Operation{Method: "GET,HEAD,PUT,POST", Path: "/relay/:id", Tag: "设备", Summary: "直传收发: PUT发送方上传原始数据，GET接收方下载，先到的一方最多等待relay.ttl分钟",
+		Errors: map[int]string{http.StatusConflict: "已有发送方或接收方", http.StatusGatewayTimeout: "对方未连接"}}, handler: api.RelayStream},
+	{Operation: openapi.Operation{Method: "GET", Path: "/signal", Tag: "设备", Summary: "WebRTC配置(ICE服务器)及当前设备的key"}, handler: api.SignalConfig},
+	{Operation: openapi.Operation{Method: "POST", Path: "/signal", Tag: "设备", Summary: "向另一台在线设备转发WebRTC信令(对方收到signal事件)，浏览器之间建立数据通道点对点传输文件",
+		JSON:   `{"to":"设备key","session":"会话ID","kind":"offer","data":{}}`,
+		Errors: map[int]string{http.StatusNotFound: "设备不在线"}}, handler: api.Signal},
+	{Operation: openapi.Operation{Method: "POST", Path: "/transit/send", Tag: "设备", Summary: "经公网中转服务器(transit.relay)端到端加密发送共享文件，返回传输码，对方用 b0pass receive --relay 地址 --code 传输码 接收",
+		JSON: `{"files":["/files/a.zip"]}`, Errors: map[int]string{http.StatusNotFound: "未配置中转服务器或文件不存在"}}, handler: api.TransitSend},
+	{Operation: openapi.Operation{Method: "POST", Path: "/transit/receive", Tag: "设备", Summary: "用对方给出的传输码经公网中转服务器接收文件，保存到共享目录下的path",
+		JSON: `{"code":"K7QF-2MZP-XW4D-9RTA-C3JH","path":"inbox"}`, Errors: map[int]string{http.StatusNotFound: "未配置中转服务器"}}, handler: api.TransitReceive, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/transit", Tag: "设备", Summary: "当前设备发起的公网中转任务(状态waiting、transferring、done、failed)，完成时推送transit事件",
+		Params: []openapi.Param{{Name: "id", Desc: "任务id，缺省时返回全部"}}}, handler: api.TransitStatus},
+	{Operation: openapi.Operation{Method: "POST", Path: "/e2e", Tag: "加密", Summary: "上传浏览器加密后的文件(请求体为B0E1格式密文，X-E2E-Meta请求头为加密的文件信息)，服务端只保存密文；下载链接为/e/<id>#密钥",
+		Params: []openapi.Param{{Name: "days", Type: "integer", Desc: "保存天数，不超过e2e.days"}},
+		Errors: map[int]string{http.StatusBadRequest: "不是加密后的文件", http.StatusRequestEntityTooLarge: "超过上传大小上限"}}, handler: api.E2EUpload, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/e2e", Tag: "加密", Summary: "当前设备上传的加密文件"}, handler: api.E2EList},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/e2e/delete", Tag: "加密", Summary: "删除加密文件(仅上传的设备)",
+		Params: []openapi.Param{{Name: "id", Required: true}}}, handler: api.E2EDelete, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/protect", Tag: "加密", Summary: "受保护目录(文件加密保存)，unlocked为当前设备是否已解锁，busy为进行中的encrypting或decrypting"}, handler: api.ProtectList},
+	{Operation: openapi.Operation{Method: "POST", Path: "/protect", Tag: "加密", Summary: "用口令保护目录，已有文件在后台加密(完成时推送protect事件)，之后上传的文件立即加密；当前设备自动解锁",
+		JSON:   `{"path":"/files/docs/secret","passphrase":"至少8个字符"}`,
+		Errors: map[int]string{http.StatusBadRequest: "口令太短或不是子目录", http.StatusConflict: "该目录或其上级、下级目录已受保护"}}, handler: api.ProtectCreate, writable: true},
+	{Operation: openapi.Operation{Method: "POST", Path: "/protect/unlock", Tag: "加密", Summary: "为当前设备解锁受保护目录，之后下载时自动解密，超过protect.idle分钟未访问时自动锁定；也可在下载请求头X-Passphrase中直接提供口令",
+		JSON:   `{"path":"/files/docs/secret","passphrase":"口令"}`,
+		Errors: map[int]string{http.StatusForbidden: "口令错误"}}, handler: api.ProtectUnlock},
+	{Operation: openapi.Operation{Method: "POST", Path: "/protect/lock", Tag: "加密", Summary: "为当前设备锁定受保护目录", JSON: `{"path":"/files/docs/secret"}`}, handler: api.ProtectLock},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/protect/remove", Tag: "加密", Summary: "取消保护，文件在后台解密后恢复为普通目录",
+		JSON:   `{"path":"/files/docs/secret","passphrase":"口令"}`,
+		Errors: map[int]string{http.StatusForbidden: "口令错误", http.StatusConflict: "正在加密"}}, handler: api.ProtectRemove, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/device/code", Tag: "设备", Summary: "生成一次性配对码与二维码内容(仅主电脑)"}, handler: api.DeviceCode, host: true},
+	{Operation: openapi.Operation{Method: "POST", Path: "/device/pair", Tag: "设备", Summary: "使用配对码配对，返回访问令牌与刷新令牌",
+		JSON: `{"code":"配对码","name":"设备名","platform":"android|ios"}`, Errors: map[int]string{http.StatusUnauthorized: "配对码无效或已过期"}}, handler: api.DevicePair},
+	{Operation: openapi.Operation{Method: "POST", Path: "/device/refresh", Tag: "设备", Summary: "使用刷新令牌换取新令牌(刷新令牌随即轮换)",
+		JSON: `{"refresh_token":""}`, Errors: map[int]string{http.StatusUnauthorized: "刷新令牌无效"}}, handler: api.DeviceRefresh},
+	{Operation: openapi.Operation{Method: "POST", Path: "/device/push", Tag: "设备", Summary: "注册推送(需Bearer令牌)，provider为空时取消",
+		JSON: `{"provider":"fcm|apns|webpush","token":""}`, Errors: map[int]string{http.StatusUnauthorized: "访问令牌无效或已过期"}}, handler: api.DevicePush},
+	{Operation: openapi.Operation{Method: "GET", Path: "/device/list", Tag: "设备", Summary: "已配对的设备(仅主电脑)",
+		Params: []openapi.Param{paramLocale}}, handler: api.DeviceList, host: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/device/revoke", Tag: "设备", Summary: "取消配对(仅主电脑)",
+		Params: []openapi.Param{{Name: "id", Required: true}}}, handler: api.DeviceRevoke, host: true},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/guest/grant", Tag: "设备", Summary: "只读模式下临时授予访客设备上传、删除权限，到期自动撤销(仅主电脑)",
+		Params: []openapi.Param{
+			{Name: "device", Required: true, Desc: "客户端IP或已配对设备ID"},
+			{Name: "minutes", Type: "integer", Desc: "授权时长(1-1440)，默认30"},
+		}}, handler: api.GuestGrant, host: true},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/guest/revoke", Tag: "设备", Summary: "撤销临时授权(仅主电脑)",
+		Params: []openapi.Param{{Name: "device", Required: true}}}, handler: api.GuestRevoke, host: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/guest/list", Tag: "设备", Summary: "当前的临时授权(仅主电脑)",
+		Params: []openapi.Param{paramLocale}}, handler: api.GuestList, host: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/session", Tag: "设备", Summary: "界面启动时获取设备标识(配对设备按令牌，浏览器按Cookie)、该设备保存的偏好及生效的语言"}, handler: api.Session},
+	{Operation: openapi.Operation{Method: "GET", Path: "/i18n/:lang", Tag: "设备", Summary: "界面文字与接口信息的翻译，如/api/i18n/en.json；lang为zh、en或语言标签(如en-US)，不支持时为setting.locale；接口返回的msg按?locale=、设备偏好或Accept-Language翻译", Raw: "翻译的JSON对象，键为中文原文或ui.开头的界面文字名称"}, handler: api.I18n},
+	{Operation: openapi.Operation{Method: "GET", Path: "/prefs", Tag: "设备", Summary: "当前设备的偏好: 语言、主题、默认视图与下载格式"}, handler: api.PrefsGet},
+	{Operation: openapi.Operation{Method: "PUT", Path: "/prefs", Tag: "设备", Summary: "修改当前设备的偏好，只修改请求中出现的项，值为空时恢复默认",
+		JSON:   `{"locale":"zh|en","theme":"light|dark|auto","view":"list|grid|gallery","download":"zip|tar|tar.gz|tar.zst"}`,
+		Errors: map[int]string{http.StatusBadRequest: "偏好项或值不合法"}}, handler: api.PrefsSet},
+	{Operation: openapi.Operation{Method: "POST", Path: "/inbox", Tag: "收集", Summary: "创建收集链接，发送者通过/in/<id>上传，文件按模板命名",
+		JSON: `{"path":"目标目录","template":"{date}-{device}-{orig}","ttl":24,"archive":true}`}, handler: api.InboxCreate, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/inbox", Tag: "收集", Summary: "收集链接列表"}, handler: api.InboxList},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/inbox/close", Tag: "收集", Summary: "关闭收集链接，开启归档的会话在后台打包为带日期的归档与sha256清单，原文件移入回收站",
+		Params: []openapi.Param{{Name: "id", Required: true}}}, handler: api.InboxClose, writable: true},
+	{Operation: openapi.Operation{Method: "POST", Path: "/share", Tag: "分享", Summary: "创建文件的分享链接，接收方通过/s/<id>下载",
+		JSON: `{"path":"/files/a.zip","ttl":24}`}, handler: api.ShareCreate, writable: true},
+	{Operation: openapi.Operation{Method: "POST", Path: "/share/short", Tag: "分享", Summary: "为单个文件创建短链接(如/s/ab3xk7m2qp)并给出二维码地址，手机扫码即可下载该文件；ttl为0时使用默认有效期share.ttl，-1为不过期，同一文件返回仍有效的同一个短链接",
+		JSON: `{"path":"/files/a.zip","ttl":0}`}, handler: api.ShareShort, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/share/qr", Tag: "分享", Summary: "分享链接完整地址的二维码，在本机通过localhost访问时使用内网地址",
+		Params: []openapi.Param{{Name: "id", Required: true}, {Name: "scale", Type: "integer", Desc: "每个模块的像素数(1-32)，缺省为8"}}, Raw: "image/png"}, handler: api.ShareQR},
+	{Operation: openapi.Operation{Method: "GET", Path: "/share", Tag: "分享", Summary: "分享链接列表(含访问统计)"}, handler: api.ShareList},
+	{Operation: openapi.Operation{Method: "GET", Path: "/share/stats", Tag: "分享", Summary: "分享链接的访问统计: 访问次数、独立设备、客户端、来源及下载是否完成",
+		Params: []openapi.Param{{Name: "id", Required: true}}}, handler: api.ShareStats},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/share/delete", Tag: "分享", Summary: "删除分享链接",
+		Params: []openapi.Param{{Name: "id", Required: true}}}, handler: api.ShareDelete, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/lists", Tag: "文件", Summary: "共享目录文件列表，按名称顺序；指定sort或limit时过滤后的总数在X-Total-Count响应头中，都不指定时以分块编码边读边输出(没有X-Total-Count)；目录的size为估算值时complete为false，计算完成后推送dirsize通知",
+		Params: []openapi.Param{{Name: "filter", Desc: "名称包含的文字，或通配符如 *.jpg"}, {Name: "sort", Desc: "name、size或mtime，目录在前"},
+			{Name: "order", Desc: "asc或desc"}, {Name: "page", Type: "integer", Desc: "页码，从1开始"},
+			{Name: "limit", Type: "integer", Desc: "每页数量(最多1000)，指定page时缺省为100"}, paramLocale, paramAll, paramExclude}}, handler: api.Lists},
+	{Operation: openapi.Operation{Method: "GET", Path: "/search", Tag: "文件", Summary: "按文件名递归搜索共享目录，返回路径、大小与修改时间",
+		Params: []openapi.Param{{Name: "q", Required: true, Desc: "空格分隔的关键字，ext:pdf 或 *.pdf 限定扩展名"},
+			{Name: "content", Type: "boolean", Desc: "为1时关键字也匹配文本文件的内容(需启用index.enabled)"},
+			{Name: "limit", Type: "integer", Desc: "最多返回的结果数，缺省为search.limit"}}}, handler: api.Search},
+	{Operation: openapi.Operation{Method: "GET", Path: "/thumb", Tag: "文件", Summary: "图片缩略图(JPEG、PNG、GIF，配置thumb.decoders后支持WebP、HEIC等)，生成后缓存在共享目录下的.thumbs",
+		Params: []openapi.Param{{Name: "path", Required: true, Desc: "如 /files/photos/a.jpg"},
+			{Name: "w", Type: "integer", Desc: "最大宽度，缺省为thumb.size"}, {Name: "h", Type: "integer", Desc: "最大高度，缺省为thumb.size"}},
+		Raw: "image/jpeg", Errors: map[int]string{
+			http.StatusNotFound:              "文件不存在",
+			http.StatusRequestEntityTooLarge: "图片像素数超过thumb.pixels",
+			http.StatusUnsupportedMediaType:  "不支持该图片格式",
+			http.StatusUnprocessableEntity:   "图片无法解码",
+			http.StatusTooManyRequests:       "预览请求繁忙，稍后重试",
+		}}, handler: api.Thumb, preview: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/gallery", Tag: "文件", Summary: "目录中全部图片的显示尺寸(已按EXIF方向旋转)、拍摄时间与方向，按拍摄时间排序，首次读取后缓存",
+		Params: []openapi.Param{{Name: "path", Required: true, Desc: "如 /files/手机/DCIM"}},
+		Errors: map[int]string{http.StatusNotFound: "目录不存在", http.StatusTooManyRequests: "预览请求繁忙，稍后重试"}}, handler: api.Gallery, preview: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/stat", Tag: "文件", Summary: "文件详情: 大小、修改时间、权限、按内容识别的MIME类型、已记录或已索引的校验值、图片与视频的尺寸(视频需要ffprobe)",
+		Params: []openapi.Param{{Name: "path", Required: true, Desc: "如 /files/a.jpg"}},
+		Errors: map[int]string{http.StatusNotFound: "文件不存在", http.StatusTooManyRequests: "预览请求繁忙，稍后重试"}}, handler: api.Stat, preview: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/playlist", Tag: "文件", Summary: "目录中音频文件的播放列表，标题、艺术家、专辑与时长取自ID3标签(其他格式的时长由ffprobe读取)",
+		Params: []openapi.Param{{Name: "path", Required: true, Desc: "如 /files/音乐"}, {Name: "format", Desc: "json(默认)或m3u"}},
+		Errors: map[int]string{http.StatusNotFound: "目录不存在", http.StatusTooManyRequests: "预览请求繁忙，稍后重试"}}, handler: api.Playlist, preview: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/preview", Tag: "文件", Summary: "文件预览: 文本与日志返回开头若干行(自动识别编码)，Markdown返回渲染后的HTML，PDF内联输出，图片按EXIF方向旋转并缩放",
+		Params: []openapi.Param{{Name: "path", Required: true, Desc: "如 /files/logs/a.log"},
+			{Name: "lines", Type: "integer", Desc: "文本返回的行数，缺省为preview.lines"}, {Name: "charset", Desc: "文本编码，缺省时自动识别，如 GBK"},
+			{Name: "size", Type: "integer", Desc: "图片最大边长，不超过preview.image"}},
+		Errors: map[int]string{
+			http.StatusNotFound:              "文件不存在",
+			http.StatusRequestEntityTooLarge: "图片像素数超过thumb.pixels",
+			http.StatusUnsupportedMediaType:  "不支持预览该类型的文件",
+			http.StatusUnprocessableEntity:   "文本无法读取或图片无法解码",
+			http.StatusTooManyRequests:       "预览请求繁忙，稍后重试",
+		}}, handler: api.Preview, preview: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/stream", Tag: "文件", Summary: "视频在线播放: 浏览器支持的编码按Range输出原文件，HEVC、mkv等由ffmpeg实时转码或转封装为分片MP4(响应头X-Stream-Mode)",
+		Params: []openapi.Param{{Name: "path", Required: true, Desc: "如 /files/手机/a.mov"},
+			{Name: "start", Type: "number", Desc: "转码时的起始秒数"}, {Name: "mode", Desc: "为direct时不转码"}},
+		Raw: "video/*", Errors: map[int]string{
+			http.StatusNotFound:                     "文件不存在",
+			http.StatusRequestedRangeNotSatisfiable: "start超出视频时长",
+			http.StatusServiceUnavailable:           "同时转码的视频数已达到stream.max",
+		}}, handler: api.Stream},
+	{Operation: openapi.Operation{Method: "GET", Path: "/stream/info", Tag: "文件", Summary: "视频编码、分辨率、时长与播放方式(direct、remux、transcode)",
+		Params: []openapi.Param{{Name: "path", Required: true}},
+		Errors: map[int]string{http.StatusNotFound: "文件不存在"}}, handler: api.StreamInfo, preview: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/index/duplicates", Tag: "文件", Summary: "内容相同的文件分组及可节省的空间(需启用index.enabled)",
+		Params: []openapi.Param{{Name: "min", Type: "integer", Desc: "只列出不小于该大小(字节)的文件"}}}, handler: api.IndexDuplicates},
+	{Operation: openapi.Operation{Method: "GET", Path: "/index/recent", Tag: "文件", Summary: "时间段内收到的文件，经本机上传的附带上传客户端(需启用index.enabled)",
+		Params: []openapi.Param{{Name: "from", Desc: "开始时间，如 2024-05-01"}, {Name: "to", Desc: "结束时间，如 2024-05-31"}}}, handler: api.IndexRecent},
+	{Operation: openapi.Operation{Method: "GET", Path: "/dump", Tag: "服务", Summary: "程序路径"}, handler: api.Dump},
+	{Operation: openapi.Operation{Method: "GET", Path: "/zip", Tag: "文件", Summary: "将目录打包流式下载(zip、tar、tar.gz、tar.zst)",
+		Params: []openapi.Param{{Name: "f", Required: true, Desc: "如 /files/photos"}, {Name: "format", Desc: "zip、tar、tar.gz或tar.zst，缺省为archive.format"},
+			{Name: "level", Type: "integer", Desc: "压缩级别，zip为0时仅存储，缺省为配置值"}, paramAll, paramExclude}, Raw: "application/zip"}, handler: api.Zip},
+	{Operation: openapi.Operation{Method: "GET", Path: "/batch", Tag: "上传", Summary: "查询目录树上传会话进度",
+		Params: []openapi.Param{{Name: "id", Required: true}}}, handler: api.BatchStatus},
+	{Operation: openapi.Operation{Method: "GET", Path: "/batch/tree", Tag: "文件", Summary: "递归列出目录下的文件(整个目录下载)",
+		Params: []openapi.Param{{Name: "path"}, paramAll, paramExclude}}, handler: api.BatchTree},
+	{Operation: openapi.Operation{Method: "PUT", Path: "/upload/tar", Tag: "上传", Summary: "以一个tar流(可gzip压缩)上传目录树，服务端边接收边解包，适合大量小文件",
+		Params: []openapi.Param{{Name: "path", Desc: "解包到的子目录"}, {Name: "overwrite", Type: "boolean", Desc: "为1时覆盖同名文件，同conflict=overwrite"}, paramDurability, paramConflict},
+		Raw:    "请求体为tar或tar.gz", Errors: errUpload}, handler: api.UploadTar, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/upload", Tag: "上传", Summary: "简易上传页面", Raw: "text/html"}, handler: api.UploadShow},
+	{Operation: openapi.Operation{Method: "GET", Path: "/peers", Tag: "镜像", Summary: "文件来源列表(本机与镜像)及在线状态"}, handler: api.Peers},
+	{Operation: openapi.Operation{Method: "GET", Path: "/sources", Tag: "镜像", Summary: "文件的下载地址，已复制到镜像的文件同时返回镜像地址",
+		Params: []openapi.Param{{Name: "f", Required: true, Desc: "如 /files/a.txt"}}}, handler: api.Sources},
+	{Operation: openapi.Operation{Method: "POST", Path: "/adopt", Tag: "文件", Summary: "将主电脑上已有的文件或目录收录到共享目录(移动、链接或复制)并计算哈希，后台执行，返回任务id(仅主电脑)",
+		JSON: `{"src":["/home/me/video.mkv"],"path":"目标子目录","mode":"move","overwrite":false}`}, handler: api.AdoptStart, writable: true, host: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/adopt", Tag: "文件", Summary: "收录任务的进度与各文件结果(方式、大小、哈希或错误)",
+		Params: []openapi.Param{{Name: "id", Required: true}}}, handler: api.AdoptStatus, host: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/actions", Tag: "文件", Summary: "文件或目录的可用操作(预览、下载、分享、校验值、投屏、打印、解压、删除)及能力标记，enabled为false时reason为原因",
+		Params: []openapi.Param{{Name: "f", Desc: "如 /files/a.jpg，为空时返回全部操作的定义"}}}, handler: api.FileActions},
+	{Operation: openapi.Operation{Method: "GET", Path: "/hash", Tag: "文件", Summary: "文件的校验值，上传时已记录且文件未变化时直接返回(recorded为true)",
+		Params: []openapi.Param{{Name: "f", Required: true}, {Name: "algo", Desc: "sha256(默认)、blake3或md5"}, {Name: "fresh", Type: "boolean", Desc: "为1时从磁盘重新计算"}}}, handler: api.Hash},
+	{Operation: openapi.Operation{Method: "GET", Path: "/chunks", Tag: "镜像", Summary: "文件的分块哈希，bad为与上传时记录不一致(已损坏)的分块序号，镜像复制据此只重传损坏的分块，网页端下载时据此逐块校验",
+		Params: []openapi.Param{{Name: "f", Required: true, Desc: "如 /files/a.iso"}, {Name: "chunk", Type: "integer", Desc: "分块大小(字节)，缺省为记录时的大小"},
+			{Name: "fresh", Type: "boolean", Desc: "为1时从磁盘重新计算"}, {Name: "async", Type: "boolean", Desc: "为1且尚未记录时在后台计算，返回202与pending"}}}, handler: api.Chunks},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/mirror/verify", Tag: "镜像", Summary: "重新复制文件到镜像，镜像上已有的文件比较分块哈希后只重传损坏的分块",
+		Params: []openapi.Param{{Name: "f", Required: true, Desc: "如 /files/a.iso"}}}, handler: api.MirrorVerify},
+	{Operation: openapi.Operation{Method: "PUT", Path: "/mirror/batch", Tag: "镜像", Summary: "接收主机批量复制的小文件，请求体为tar流，返回已保存的路径",
+		Errors: map[int]string{507: "磁盘空间不足"}}, handler: api.MirrorBatch, writable: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/sip", Tag: "服务", Summary: "服务地址列表"}, handler: api.GetIp},
+	{Operation: openapi.Operation{Method: "GET", Path: "/nearby", Tag: "服务", Summary: "局域网中的其他b0pass实例(组播发现)，url为其访问地址",
+		Params: []openapi.Param{{Name: "wake", Type: "boolean", Desc: "为1时先发送查询，等待1秒收集各实例的应答"}}, Errors: map[int]string{503: "局域网发现未启用"}}, handler: api.Nearby},
+	{Operation: openapi.Operation{Method: "GET", Path: "/qr", Tag: "服务", Summary: "生成二维码(PNG、SVG或终端文本)，data为空时编码本服务的地址，可用于网页、终端与打印",
+		Params: []openapi.Param{{Name: "data", Desc: "编码的内容"}, {Name: "scheme", Desc: "data为空时使用，http或https，缺省与本次请求一致"},
+			{Name: "host", Desc: "data为空时使用，网卡地址(可带端口)，缺省为首个内网地址"}, {Name: "path", Desc: "data为空时使用，缺省为/"},
+			{Name: "size", Type: "integer", Desc: "图片边长(像素，21-2000)，缺省为200"}, {Name: "level", Desc: "纠错等级L、M(默认)、Q或H"},
+			{Name: "format", Desc: "png(默认)、svg、txt(Unicode字符)或ansi(ANSI颜色)"}}, Raw: "image/png"}, handler: api.QR},
+	{Operation: openapi.Operation{Method: "GET", Path: "/quota", Tag: "服务", Summary: "当前设备的上传配额与剩余空间(设备配额、总配额与磁盘空间中最小的剩余量)，remaining为-1表示不限，供界面在上传前提示",
+		Params: []openapi.Param{{Name: "size", Type: "integer", Desc: "将要上传的字节数，指定时ok表示能否上传，reason为原因"}}}, handler: api.Quota},
+	{Operation: openapi.Operation{Method: "GET", Path: "/status", Tag: "服务", Summary: "服务状态(磁盘空间、文件句柄、休眠抑制等)"}, handler: api.Status},
+	{Operation: openapi.Operation{Method: "GET", Path: "/stats", Tag: "服务", Summary: "各共享根目录所在磁盘的总空间、剩余空间、可用于上传的空间(扣除storage.block阈值与storage.margin余量)及上传排队情况"}, handler: api.Stats},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/subpath", Tag: "服务", Summary: "读取或保存(code=1)上传子目录",
+		Params: []openapi.Param{{Name: "path", In: "form"}, {Name: "code", In: "form"}}}, handler: api.GetSubPath},
+	{Operation: openapi.Operation{Method: "ALL", Path: "/textdata", Tag: "服务", Summary: "读取或保存(code=1)共享文本",
+		Params: []openapi.Param{{Name: "data", In: "form"}, {Name: "code", In: "form"}}}, handler: api.GetTextData},
+	{Operation: openapi.Operation{Method: "GET", Path: "/openurl", Tag: "服务", Summary: "在主电脑上打开文件或网址",
+		Params: []openapi.Param{{Name: "url", Required: true}}}, handler: api.OpenUrl},
+	{Operation: openapi.Operation{Method: "GET", Path: "/audit", Tag: "服务", Summary: "审计日志",
+		Params: []openapi.Param{{Name: "op", Desc: "upload/download/delete等"}, {Name: "limit", Type: "integer"}, paramLocale}}, handler: api.Audit},
+	{Operation: openapi.Operation{Method: "GET", Path: "/history", Tag: "服务", Summary: "已完成的传输记录(文件、大小、耗时、平均速度与设备)，按时间从新到旧",
+		Params: []openapi.Param{{Name: "device", Desc: "设备标识(device:<id>)、设备名或客户端地址"}, {Name: "op", Desc: "upload或download"}, {Name: "q", Desc: "路径包含的文字"},
+			{Name: "from", Desc: "开始时间，如 2024-05-01、2024-05 或RFC3339"}, {Name: "to", Desc: "结束时间(含)"}, {Name: "limit", Type: "integer", Desc: "默认100，0为不限"}}}, handler: api.History},
+	{Operation: openapi.Operation{Method: "GET", Path: "/history/export", Tag: "服务", Summary: "导出传输记录、设备与审计事件", Raw: "text/csv",
+		Params: []openapi.Param{{Name: "format", Desc: "csv(默认)或json"}, {Name: "from", Desc: "开始时间，如 2024-05-01、2024-05 或RFC3339"}, {Name: "to", Desc: "结束时间(含)"}}}, handler: api.HistoryExport},
+	{Operation: openapi.Operation{Method: "GET", Path: "/receipt", Tag: "上传", Summary: "文件最近一次上传的签名回执(文件名、大小、哈希、时间与服务端身份)",
+		Params: []openapi.Param{{Name: "f", Required: true, Desc: "如 /files/a.txt"}}}, handler: api.Receipt},
+	{Operation: openapi.Operation{Method: "POST", Path: "/receipt/verify", Tag: "上传", Summary: "验证回执签名，current表示服务端的文件仍与回执一致",
+		JSON:   `{"file":"/files/a.txt","size":1,"hashes":{},"time":"","server":{},"signature":""}`,
+		Errors: map[int]string{http.StatusUnprocessableEntity: "签名无效或回执被修改"}}, handler: api.ReceiptVerify},
+	{Operation: openapi.Operation{Method: "GET", Path: "/receipt/key", Tag: "上传", Summary: "回执签名公钥，用于离线验证", Raw: "application/x-pem-file"}, handler: api.ReceiptKey},
+	{Operation: openapi.Operation{Method: "POST", Path: "/admin/snapshot", Tag: "服务", Summary: "保存运行时状态快照(设置、访客授权、目录上传会话与镜像复制队列)，下次启动时自动恢复(仅主电脑)"}, handler: api.AdminSnapshot, host: true},
+	{Operation: openapi.Operation{Method: "GET", Path: "/admin/settings", Tag: "服务", Summary: "可在运行中修改的设置与设备名(仅主电脑)"}, handler: api.AdminSettings, host: true},
+	{Operation: openapi.Operation{Method: "PUT", Path: "/admin/settings", Tag: "服务", Summary: "修改设置与设备名(仅主电脑)，立即生效并写入b0pass.yaml",
+		JSON: `{"settings":{"auth.readonly":true},"devices":{"设备ID":"新名称"}}`}, handler: api.AdminSettingsSave, host: true},
+}
+
+// bindRoutes 绑定接口，写操作接口在只读模式下拒绝
+func bindRoutes(g *ghttp.RouterGroup) {
+	for _, rt := range apiRoutes {
+		h := rt.handler
+		if rt.writable {
+			h = Writable(h)
+		}
+		if rt.preview {
+			h = Preview(h)
+		}
+		switch rt.Method {
+		case http.MethodGet:
+			g.GET(rt.Path, h)
+		case http.MethodPost:
+			g.POST(rt.Path, h)
+		case http.MethodPut:
+			g.PUT(rt.Path, h)
+		default:
+			g.ALL(rt.Path, h)
+		}
+	}
+}
+
+// hostRoutes 仅主电脑可用的接口路径(含/api前缀)
+func hostRoutes() map[string]bool {
+	paths := make(map[string]bool)
+	for _, rt := range apiRoutes {
+		if rt.host {
+			paths["/api"+rt.Path] = true
+		}
+	}
+	return paths
+}
+
+// apiOperations 文档中的接口，路径补全/api前缀
+func apiOperations() []openapi.Operation {
+	ops := make([]openapi.Operation, 0, len(apiRoutes)+7)
+	for _, rt := range apiRoutes {
+		op := rt.Operation
+		op.Path = "/api" + op.Path
+		if rt.writable {
+			op.Summary += "(只读模式下禁用)"
+		}
+		ops = append(ops, op)
+	}
+	return append(ops,
+		openapi.Operation{Method: "GET", Path: "/files/*path", Tag: "文件", Summary: "下载文件(支持Range)或浏览目录，Content-Type按文件内容识别，浏览器不能直接打开的类型作为附件下载",
+			Params: []openapi.Param{{Name: "download", Type: "boolean", Desc: "为1时总是作为附件下载"}, paramAll, paramExclude}, Raw: "文件内容"},
+		openapi.Operation{Method: "PUT,POST", Path: "/up/*path", Tag: "终端", Summary: "curl上传(-T或-F)，返回下载地址", Params: []openapi.Param{
+			{Name: "name", In: "query", Type: "string", Desc: "路径以/结尾时的文件名，缺省时按上传时间命名"}, paramConflict,
+		}, Raw: "下载地址，每行一个"},
+		openapi.Operation{Method: "GET", Path: "/ls/*path", Tag: "终端", Summary: "纯文本目录列表", Params: []openapi.Param{paramAll, paramExclude}, Raw: "大小 修改时间 名称"},
+		openapi.Operation{Method: "GET", Path: "/f/*path", Tag: "终端", Summary: "下载文件，目录时返回纯文本列表", Params: []openapi.Param{paramAll, paramExclude}, Raw: "文件内容"},
+		openapi.Operation{Method: "GET", Path: "/e/:id", Tag: "加密", Summary: "加密文件的下载页面(无需登录)，密钥在链接的#片段中，由浏览器解密", Raw: "text/html"},
+		openapi.Operation{Method: "GET", Path: "/e/:id/info", Tag: "加密", Summary: "加密文件的信息: 加密的文件信息(meta)、密文与明文大小、分块大小"},
+		openapi.Operation{Method: "GET", Path: "/e/:id/blob", Tag: "加密", Summary: "下载密文(支持Range)", Raw: "application/octet-stream"},
+		openapi.Operation{Method: "GET", Path: "/e/:id/plain", Tag: "加密", Summary: "由服务端解密后下载(支持Range)，供可信的一方使用，密钥通过X-E2E-Key请求头提供且不会被保存",
+			Errors: map[int]string{http.StatusForbidden: "密钥不正确"}, Raw: "文件内容"},
+		openapi.Operation{Method: "GET", Path: "/in/:id", Tag: "收集", Summary: "收集链接上传页面(无需登录)", Raw: "text/html"},
+		openapi.Operation{Method: "PUT,POST", Path: "/in/:id", Tag: "收集", Summary: "上传到收集链接(PUT原始数据或POST multipart)，文件按模板命名",
+			Params: []openapi.Param{{Name: "name", In: "query", Desc: "PUT时的原文件名"}}, Raw: "保存的路径，每行一个"},
+		openapi.Operation{Method: "GET", Path: "/api/docs/openapi.json", Tag: "服务", Summary: "OpenAPI文档", Raw: "application/json"},
+	)
+}
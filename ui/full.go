@@ -0,0 +1,17 @@
+//go:build !minimalui
+// +build !minimalui
+
+package ui
+
+import "embed"
+
+// Variant 界面版本
+const Variant = "full"
+
+// templateDir 嵌入的模板目录
+const templateDir = "template"
+
+// FS 嵌入的完整界面
+//
+//go:embed public template
+var FS embed.FS
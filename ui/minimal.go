@@ -0,0 +1,20 @@
+//go:build minimalui
+// +build minimalui
+
+package ui
+
+import "embed"
+
+// Variant 界面版本
+const Variant = "minimal"
+
+// templateDir 嵌入的模板目录，输出为template
+const templateDir = "minimal"
+
+// FS 嵌入的精简界面: 原生JS的首页与文件列表，以及分享、收集、加密传输等独立页面
+//
+//go:embed minimal
+//go:embed public/favicon.ico public/assets/css/main.css
+//go:embed public/js/base.js public/js/i18n.js public/js/e2e.js public/js/libs/jquery.min.js
+//go:embed public/page/get.html public/page/inbox.html public/page/e2e.html
+var FS embed.FS
@@ -0,0 +1,15 @@
+/**
+ * 路径前缀: 部署在反向代理的子路径(--base-path)下时为 /b0pass 之类，否则为空字符串
+ * 由本文件的地址推算(位于 <前缀>/js/base.js)，页面与脚本中的接口地址以它开头，须在其他脚本之前载入
+ */
+var b0base = (function () {
+    var s = document.currentScript;
+    if (!s) {
+        var all = document.getElementsByTagName("script");
+        s = all[all.length - 1];
+    }
+    var a = document.createElement("a");
+    a.href = s.src;
+    var p = a.pathname.charAt(0) === "/" ? a.pathname : "/" + a.pathname;
+    return p.replace(/\/js\/base\.js$/, "");
+})();
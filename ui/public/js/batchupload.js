@@ -0,0 +1,84 @@
+/**
+ * 一个请求上传多个文件并逐个显示进度(/api/upload的files字段)，进度来自服务端推送(/api/events)的progress事件
+ *
+ * batchUpload(files, path, onItem)  files为选择的File列表，path为上传子目录；
+ *                                   onItem(item)在某个文件的状态或进度变化时调用，item为{index,name,size,bytes,state,error,url}，
+ *                                   state为queued、active、done、failed；返回Promise，结果为各文件的最终状态
+ *                                   上传名额已满(429)时按Retry-After重试，onQueue(position)报告排队位置(可选)
+ */
+function batchUpload(files, path, onItem, onQueue) {
+    var id = Date.now().toString(36) + Math.random().toString(36).slice(2, 8);
+    return new Promise(function (resolve, reject) {
+        var source = null;
+        var send = function () {
+            var form = new FormData();
+            // 字段须位于文件之前
+            form.append("path", path || "");
+            form.append("progress", id);
+            form.append("files", JSON.stringify(Array.prototype.map.call(files, function (f) {
+                return {name: f.name, size: f.size};
+            })));
+            Array.prototype.forEach.call(files, function (f) {
+                form.append("upload-file", f, f.name);
+            });
+            var xhr = new XMLHttpRequest();
+            xhr.open("POST", b0base + "/api/upload");
+            xhr.onload = function () {
+                if (xhr.status === 429) {
+                    if (onQueue) {
+                        onQueue(parseInt(xhr.getResponseHeader("X-Queue-Position"), 10) || 0);
+                    }
+                    setTimeout(send, (parseInt(xhr.getResponseHeader("Retry-After"), 10) || 3) * 1000);
+                    return;
+                }
+                if (source) {
+                    source.close();
+                }
+                var result;
+                try {
+                    result = JSON.parse(xhr.responseText);
+                } catch (e) {
+                    reject(new Error(xhr.statusText));
+                    return;
+                }
+                if (!result.data || !result.data.files) {
+                    reject(new Error(result.msg || xhr.statusText));
+                    return;
+                }
+                result.data.files.forEach(onItem);
+                resolve(result.data.files);
+            };
+            xhr.onerror = function () {
+                if (source) {
+                    source.close();
+                }
+                reject(new Error("上传中断"));
+            };
+            xhr.send(form);
+        };
+        if (typeof EventSource !== "function") {
+            send();
+            return;
+        }
+        // 先订阅推送再开始上传，不漏掉排队状态
+        source = new EventSource(b0base + "/api/events?types=progress");
+        var started = false;
+        source.onmessage = function (result) {
+            var e = JSON.parse(result.data);
+            if (e.type === "hello" && !started) {
+                started = true;
+                send();
+            } else if (e.type === "progress" && e.data.id === id) {
+                onItem(e.data);
+            }
+        };
+        source.onerror = function () {
+            if (!started) {
+                started = true;
+                source.close();
+                source = null;
+                send();
+            }
+        };
+    });
+}
@@ -0,0 +1,189 @@
+/**
+ * 端到端加密传输(需要WebCrypto，浏览器只在HTTPS或localhost下提供，调用前用e2eSupported()检测)
+ * 密钥在浏览器中生成，只放在链接的#片段里(不会发送给服务器)，服务器只保存密文，格式见library/e2e
+ *
+ * e2eUpload(file, days, onProgress)  加密并上传，返回 {id, key, url}，url为带密钥的下载链接
+ * e2eInfo(id, key)                  解密文件信息，返回 {name, type, size}
+ * e2eDownload(id, key, onProgress)  下载并逐块解密，有File System Access API时边下载边写入，否则下载完成后保存
+ *
+ * onProgress(done, total) 按字节报告进度
+ */
+var E2E_CHUNK = 1 << 20, E2E_TAG = 16, E2E_HEADER = 8;
+
+function e2eSupported() {
+    return !!(window.crypto && window.crypto.subtle);
+}
+
+function e2eB64(buf) {
+    var s = "";
+    new Uint8Array(buf).forEach(function (b) { s += String.fromCharCode(b); });
+    return btoa(s).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function e2eUnb64(s) {
+    var bin = atob(s.replace(/-/g, "+").replace(/_/g, "/"));
+    var out = new Uint8Array(bin.length);
+    for (var i = 0; i < bin.length; i++) {
+        out[i] = bin.charCodeAt(i);
+    }
+    return out;
+}
+
+// 第n块的nonce: 8字节序号与标记(最后一块为1，文件信息为2且序号全为0xFF)
+function e2eNonce(n, flag) {
+    var iv = new Uint8Array(12), view = new DataView(iv.buffer);
+    if (n < 0) {
+        iv.fill(0xFF, 0, 8);
+    } else {
+        view.setUint32(0, Math.floor(n / 4294967296));
+        view.setUint32(4, n >>> 0);
+    }
+    iv[11] = flag;
+    return iv;
+}
+
+function e2eImport(raw) {
+    return window.crypto.subtle.importKey("raw", raw, "AES-GCM", false, ["encrypt", "decrypt"]);
+}
+
+async function e2eUpload(file, days, onProgress) {
+    const raw = window.crypto.getRandomValues(new Uint8Array(32));
+    const key = await e2eImport(raw);
+    const meta = await window.crypto.subtle.encrypt({name: "AES-GCM", iv: e2eNonce(-1, 2)}, key,
+        new TextEncoder().encode(JSON.stringify({name: file.name, type: file.type})));
+    const header = new Uint8Array(E2E_HEADER);
+    header.set(new TextEncoder().encode("B0E1"));
+    new DataView(header.buffer).setUint32(4, E2E_CHUNK);
+    const parts = [header];
+    const count = Math.max(1, Math.ceil(file.size / E2E_CHUNK));
+    for (let i = 0; i < count; i++) {
+        const plain = await file.slice(i * E2E_CHUNK, (i + 1) * E2E_CHUNK).arrayBuffer();
+        parts.push(await window.crypto.subtle.encrypt({name: "AES-GCM", iv: e2eNonce(i, i === count - 1 ? 1 : 0)}, key, plain));
+        if (onProgress) {
+            onProgress(Math.min((i + 1) * E2E_CHUNK, file.size) / 2, file.size);
+        }
+    }
+    const body = new Blob(parts);
+    const result = await new Promise(function (resolve, reject) {
+        const xhr = new XMLHttpRequest();
+        xhr.open("POST", b0base + "/api/e2e" + (days ? "?days=" + days : ""));
+        xhr.setRequestHeader("X-E2E-Meta", e2eB64(meta));
+        xhr.upload.onprogress = function (e) {
+            if (onProgress) {
+                onProgress(file.size / 2 + e.loaded / body.size * file.size / 2, file.size);
+            }
+        };
+        xhr.onload = function () {
+            let rs = {};
+            try { rs = JSON.parse(xhr.responseText); } catch (e) {}
+            if (xhr.status !== 200 || rs.err !== 0) {
+                reject(new Error(rs.msg || xhr.statusText));
+            } else {
+                resolve(rs.data);
+            }
+        };
+        xhr.onerror = function () { reject(new Error("网络错误")); };
+        xhr.send(body);
+    });
+    const k = e2eB64(raw);
+    return {id: result.id, key: k, url: location.origin + b0base + "/e/" + result.id + "#" + k, expires: result.expires};
+}
+
+async function e2eInfo(id, k) {
+    const resp = await fetch(b0base + "/e/" + id + "/info");
+    const rs = await resp.json();
+    if (!resp.ok || rs.err !== 0) {
+        throw new Error(rs.msg || resp.statusText);
+    }
+    const key = await e2eImport(e2eUnb64(k));
+    let meta;
+    try {
+        meta = await window.crypto.subtle.decrypt({name: "AES-GCM", iv: e2eNonce(-1, 2)}, key, e2eUnb64(rs.data.meta));
+    } catch (e) {
+        throw new Error("密钥不正确，请检查链接是否完整");
+    }
+    const info = JSON.parse(new TextDecoder().decode(meta));
+    info.size = rs.data.plain;
+    info.chunk = rs.data.chunk;
+    info.cipher = rs.data.size;
+    return info;
+}
+
+async function e2eDownload(id, k, onProgress) {
+    const info = await e2eInfo(id, k);
+    let writable = null;
+    if (typeof window.showSaveFilePicker === "function") {
+        const handle = await window.showSaveFilePicker({suggestedName: info.name});
+        writable = await handle.createWritable();
+    }
+    const key = await e2eImport(e2eUnb64(k));
+    const resp = await fetch(b0base + "/e/" + id + "/blob", {cache: "no-store"});
+    if (!resp.ok) {
+        throw new Error(resp.statusText);
+    }
+    const reader = resp.body.getReader();
+    const frame = info.chunk + E2E_TAG;
+    const parts = [];
+    let buf = new Uint8Array(0), skip = E2E_HEADER, index = 0, done = 0, received = 0;
+    const open = async function (data, last) {
+        let plain;
+        try {
+            plain = await window.crypto.subtle.decrypt({name: "AES-GCM", iv: e2eNonce(index, last ? 1 : 0)}, key, data);
+        } catch (e) {
+            throw new Error("第" + (index + 1) + "块解密失败，文件已损坏或密钥不正确");
+        }
+        index++;
+        done += plain.byteLength;
+        if (writable) {
+            await writable.write(plain);
+        } else {
+            parts.push(plain);
+        }
+        if (onProgress) {
+            onProgress(done, info.size);
+        }
+    };
+    try {
+        for (;;) {
+            const part = await reader.read();
+            if (part.done) {
+                break;
+            }
+            received += part.value.length;
+            const joined = new Uint8Array(buf.length + part.value.length);
+            joined.set(buf);
+            joined.set(part.value, buf.length);
+            buf = joined.subarray(Math.min(skip, joined.length));
+            skip -= joined.length - buf.length;
+            // 后面还有数据的才不是最后一块，最后一块读到结尾后再解密
+            while (buf.length > frame) {
+                await open(buf.slice(0, frame), false);
+                buf = buf.subarray(frame);
+            }
+        }
+        if (received !== info.cipher) {
+            throw new Error("下载不完整(" + received + "/" + info.cipher + "字节)");
+        }
+        await open(buf.slice(), true);
+        if (done !== info.size) {
+            throw new Error("下载不完整(" + done + "/" + info.size + "字节)");
+        }
+    } catch (e) {
+        reader.cancel().catch(function () {});
+        if (writable) {
+            await writable.abort();
+        }
+        throw e;
+    }
+    if (writable) {
+        await writable.close();
+        return info;
+    }
+    const a = document.createElement("a");
+    a.href = URL.createObjectURL(new Blob(parts, {type: info.type || "application/octet-stream"}));
+    a.download = info.name;
+    document.body.appendChild(a);
+    a.click();
+    setTimeout(function () { URL.revokeObjectURL(a.href); a.remove(); }, 60000);
+    return info;
+}
@@ -0,0 +1,27 @@
+/**
+ * 服务端推送(/api/events): 收到新文件、其他设备发来文件时提示
+ *
+ * eventsListen(onEvent)  订阅推送，断线后浏览器自动重连；onEvent(e)收到通知事件，e.type为upload、handoff等
+ * eventsKey              本设备的key(其他设备通过/api/send向本设备发送文件时指定)
+ */
+var eventsKey = "";
+
+function eventsListen(onEvent) {
+    if (typeof EventSource !== "function") {
+        return null;
+    }
+    var source = new EventSource(b0base + "/api/events");
+    source.onmessage = function (result) {
+        var e = JSON.parse(result.data);
+        if (e.type === "hello") {
+            eventsKey = e.data.key;
+            return;
+        }
+        // 本设备上传的文件不提示
+        if (e.data && e.data.from_key === eventsKey && e.type !== "handoff") {
+            return;
+        }
+        onEvent(e);
+    };
+    return source;
+}
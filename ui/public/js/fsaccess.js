@@ -0,0 +1,168 @@
+/**
+ * File System Access API 目录上传/下载(仅Chromium支持，调用前用fsSupported()检测)
+ *
+ * fsUploadDir(base, onProgress)   选择本地目录并上传到共享目录base下，中断后重新选择同一目录即可续传
+ * fsDownloadDir(dir, onProgress)  选择本地目录并将共享目录dir整个下载到其中，已下载的部分不会重复传输
+ *
+ * onProgress(done, total, file) 按字节报告进度，上传名额已满排队时file为"排队中(第N位)"
+ */
+var FS_CHUNK = 4 << 20;
+
+function fsSupported() {
+    return typeof window.showDirectoryPicker === "function";
+}
+
+// 递归收集目录下的文件(跳过隐藏文件)
+async function fsWalk(handle, prefix, list) {
+    for await (const entry of handle.values()) {
+        if (entry.name.charAt(0) === ".") {
+            continue;
+        }
+        const p = prefix ? prefix + "/" + entry.name : entry.name;
+        if (entry.kind === "directory") {
+            await fsWalk(entry, p, list);
+        } else {
+            list.push({path: p, handle: entry, file: await entry.getFile()});
+        }
+    }
+    return list;
+}
+
+async function fsJSON(resp) {
+    const result = await resp.json();
+    if (!resp.ok || result.err !== 0) {
+        throw new Error(result.msg || resp.statusText);
+    }
+    return result.data;
+}
+
+// 上传名额已满(429)时按Retry-After重试，重试时保持排队位置
+async function fsUpload(form, onQueue) {
+    for (;;) {
+        const resp = await fetch(b0base + "/api/upload", {method: "POST", body: form});
+        if (resp.status !== 429) {
+            return fsJSON(resp);
+        }
+        if (onQueue) {
+            onQueue(parseInt(resp.headers.get("X-Queue-Position"), 10) || 0);
+        }
+        const retry = parseInt(resp.headers.get("Retry-After"), 10) || 3;
+        await new Promise(function (resolve) { setTimeout(resolve, retry * 1000); });
+    }
+}
+
+async function fsUploadDir(base, onProgress) {
+    const root = await window.showDirectoryPicker();
+    const local = await fsWalk(root, "", []);
+    const files = local.map(function (f) {
+        return {path: f.path, size: f.file.size, mtime: Math.floor(f.file.lastModified / 1000)};
+    });
+    const dest = (base ? base.replace(/\/+$/, "") + "/" : "") + root.name;
+    const sess = await fsJSON(await fetch(b0base + "/api/batch", {
+        method: "POST",
+        headers: {"Content-Type": "application/json"},
+        body: JSON.stringify({path: dest, files: files})
+    }));
+    let done = sess.completed;
+    for (let i = 0; i < sess.states.length; i++) {
+        const st = sess.states[i];
+        if (st.status === "done") {
+            continue;
+        }
+        const f = local.find(function (l) { return l.path === st.path; });
+        const slash = st.path.lastIndexOf("/");
+        const dir = sess.path + (slash > 0 ? "/" + st.path.substring(0, slash) : "");
+        let offset = st.offset;
+        do {
+            const end = Math.min(offset + FS_CHUNK, st.size);
+            const form = new FormData();
+            form.append("path", dir);
+            form.append("mtime", st.mtime);
+            // 本地已修改的文件覆盖服务端的旧版本
+            form.append("conflict", "overwrite");
+            if (st.size > 0) {
+                form.append("id", st.id);
+                form.append("offset", offset);
+                form.append("size", st.size);
+            }
+            form.append("upload-file", f.file.slice(offset, end), f.file.name);
+            await fsUpload(form, function (position) {
+                if (onProgress) {
+                    onProgress(done, sess.size, position > 0 ? "排队中(第" + position + "位)" : "排队中");
+                }
+            });
+            done += end - offset;
+            offset = end;
+            if (onProgress) {
+                onProgress(done, sess.size, st.path);
+            }
+        } while (offset < st.size);
+    }
+    return fsJSON(await fetch(b0base + "/api/batch/done?id=" + sess.id, {method: "POST"}));
+}
+
+// 当前页面的隐藏文件参数(all、exclude)，与列表显示的文件保持一致
+function fsHiddenQuery() {
+    const q = new URLSearchParams(window.location.search), ret = new URLSearchParams();
+    ["all", "exclude"].forEach(function (k) {
+        if (q.get(k)) {
+            ret.set(k, q.get(k));
+        }
+    });
+    const s = ret.toString();
+    return s ? "&" + s : "";
+}
+
+async function fsDownloadDir(dir, onProgress) {
+    const tree = await fsJSON(await fetch(b0base + "/api/batch/tree?path=" + encodeURIComponent(dir) + fsHiddenQuery()));
+    const root = await window.showDirectoryPicker({mode: "readwrite"});
+    let total = 0, done = 0;
+    tree.files.forEach(function (f) { total += f.size; });
+    for (let i = 0; i < tree.files.length; i++) {
+        const f = tree.files[i];
+        const parts = f.path.split("/");
+        let handle = root;
+        for (let j = 0; j < parts.length - 1; j++) {
+            handle = await handle.getDirectoryHandle(parts[j], {create: true});
+        }
+        const fileHandle = await handle.getFileHandle(parts[parts.length - 1], {create: true});
+        let offset = (await fileHandle.getFile()).size;
+        if (offset > f.size) {
+            offset = 0;
+        }
+        done += offset;
+        if (offset < f.size) {
+            const url = "/files/" + (tree.path ? tree.path + "/" : "") + f.path;
+            const resp = await fetch(b0base + url.split("/").map(encodeURIComponent).join("/"), {
+                headers: offset > 0 ? {"Range": "bytes=" + offset + "-"} : {}
+            });
+            if (!resp.ok) {
+                throw new Error(f.path + ": " + resp.statusText);
+            }
+            // 服务端未按Range返回时从头写入
+            if (resp.status !== 206) {
+                done -= offset;
+                offset = 0;
+            }
+            const writable = await fileHandle.createWritable({keepExistingData: offset > 0});
+            await writable.truncate(offset);
+            await writable.seek(offset);
+            const reader = resp.body.getReader();
+            for (;;) {
+                const chunk = await reader.read();
+                if (chunk.done) {
+                    break;
+                }
+                await writable.write(chunk.value);
+                done += chunk.value.length;
+                if (onProgress) {
+                    onProgress(done, total, f.path);
+                }
+            }
+            await writable.close();
+        } else if (onProgress) {
+            onProgress(done, total, f.path);
+        }
+    }
+    return tree;
+}
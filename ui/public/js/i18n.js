@@ -0,0 +1,32 @@
+/**
+ * 界面文字的翻译: 带data-i18n属性的元素按/api/i18n/<语言>.json替换文字，
+ * 语言为页面地址的?locale=参数，否则为服务端按设备偏好与Accept-Language选择的语言(/api/session)；中文界面不替换
+ * 须在base.js之后载入，翻译后的文字可通过 b0i18n[名称] 取得
+ */
+var b0i18n = {};
+(function () {
+    var q = new URLSearchParams(location.search).get("locale");
+    var lang = q ? Promise.resolve(q) : fetch(b0base + "/api/session").then(function (res) {
+        return res.json();
+    }).then(function (s) {
+        return s.data.locale;
+    });
+    lang.then(function (lang) {
+        if (/^zh/i.test(lang)) {
+            return;
+        }
+        return fetch(b0base + "/api/i18n/" + encodeURIComponent(lang) + ".json").then(function (res) {
+            return res.json();
+        }).then(function (messages) {
+            b0i18n = messages;
+            document.documentElement.lang = lang;
+            Array.prototype.forEach.call(document.querySelectorAll("[data-i18n]"), function (el) {
+                var text = messages[el.getAttribute("data-i18n")];
+                if (text) {
+                    el.textContent = text;
+                }
+            });
+        });
+    }).catch(function () {
+    });
+})();
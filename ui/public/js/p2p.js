@@ -0,0 +1,226 @@
+/**
+ * 浏览器之间点对点传输文件(WebRTC数据通道)，服务端只经/api/signal与/api/events转发信令，不经手文件内容
+ *
+ * p2pSupported()                        浏览器是否支持
+ * p2pSend(key, file, onProgress)        向设备key(见/api/clients)发送文件(File对象)，对方接受后开始传输，返回Promise
+ * p2pHandle(e, confirmFn, onProgress)   处理/api/events中type为signal的事件；
+ *                                       收到发送请求时调用confirmFn(from, name, size)询问是否接收(返回Promise<boolean>)，
+ *                                       confirmFn为空时只处理本页面发起的传输
+ *
+ * onProgress(done, total, name) 按字节报告进度
+ */
+var P2P_CHUNK = 64 * 1024;
+var P2P_HIGH = 8 * 1024 * 1024;
+var p2pSessions = {};
+var p2pConf = null;
+
+function p2pSupported() {
+    return typeof window.RTCPeerConnection === "function";
+}
+
+async function p2pConfig() {
+    if (!p2pConf) {
+        const resp = await fetch(b0base + "/api/signal");
+        const result = await resp.json();
+        if (result.err !== 0) {
+            throw new Error(result.msg);
+        }
+        p2pConf = result.data;
+    }
+    return p2pConf;
+}
+
+async function p2pSignal(to, session, kind, data) {
+    const resp = await fetch(b0base + "/api/signal", {
+        method: "POST",
+        headers: {"Content-Type": "application/json"},
+        body: JSON.stringify({to: to, session: session, kind: kind, data: data})
+    });
+    const result = await resp.json();
+    if (result.err !== 0) {
+        throw new Error(result.msg);
+    }
+}
+
+function p2pClose(id) {
+    const s = p2pSessions[id];
+    if (s) {
+        delete p2pSessions[id];
+        s.pc.close();
+    }
+}
+
+// p2pPeer 建立连接，ICE候选在对方的SDP到达前暂存
+async function p2pPeer(id, to) {
+    const conf = await p2pConfig();
+    const pc = new RTCPeerConnection({iceServers: conf.ice_servers});
+    const s = {pc: pc, to: to, pending: [], reject: function () {}};
+    pc.onicecandidate = function (e) {
+        if (e.candidate) {
+            p2pSignal(to, id, "candidate", e.candidate.toJSON()).catch(function () {});
+        }
+    };
+    pc.onconnectionstatechange = function () {
+        if (pc.connectionState === "failed") {
+            s.reject(new Error("无法与对方建立直连(可能不在同一局域网)"));
+        }
+    };
+    p2pSessions[id] = s;
+    return s;
+}
+
+async function p2pRemote(s, desc) {
+    await s.pc.setRemoteDescription(desc);
+    const pending = s.pending;
+    s.pending = [];
+    for (let i = 0; i < pending.length; i++) {
+        await s.pc.addIceCandidate(pending[i]);
+    }
+}
+
+async function p2pSend(key, file, onProgress) {
+    const id = Date.now().toString(36) + Math.random().toString(36).slice(2);
+    const s = await p2pPeer(id, key);
+    const dc = s.pc.createDataChannel("file", {ordered: true});
+    dc.binaryType = "arraybuffer";
+    dc.bufferedAmountLowThreshold = P2P_HIGH / 8;
+    try {
+        const opened = new Promise(function (resolve, reject) {
+            dc.onopen = resolve;
+            s.reject = reject;
+        });
+        await s.pc.setLocalDescription(await s.pc.createOffer());
+        await p2pSignal(key, id, "offer", {sdp: s.pc.localDescription, name: file.name, size: file.size, mime: file.type});
+        await opened;
+        const done = new Promise(function (resolve, reject) {
+            dc.onmessage = function (m) {
+                if (m.data === "done") {
+                    resolve();
+                }
+            };
+            dc.onclose = function () {
+                reject(new Error("对方已断开"));
+            };
+            s.reject = reject;
+        });
+        let offset = 0;
+        while (offset < file.size) {
+            if (dc.bufferedAmount > P2P_HIGH) {
+                await new Promise(function (resolve) { dc.onbufferedamountlow = resolve; });
+            }
+            const buf = await file.slice(offset, offset + P2P_CHUNK).arrayBuffer();
+            dc.send(buf);
+            offset += buf.byteLength;
+            if (onProgress) {
+                onProgress(offset, file.size, file.name);
+            }
+        }
+        // 等待对方确认全部写入
+        await done;
+    } finally {
+        p2pClose(id);
+    }
+}
+
+async function p2pHandle(e, confirmFn, onProgress) {
+    const d = e.data, s = p2pSessions[d.session];
+    if (d.kind === "offer" && confirmFn && !s) {
+        return p2pReceive(d, confirmFn, onProgress);
+    }
+    if (!s) {
+        return;
+    }
+    if (d.kind === "answer") {
+        await p2pRemote(s, d.data.sdp);
+    } else if (d.kind === "candidate") {
+        if (s.pc.remoteDescription) {
+            await s.pc.addIceCandidate(d.data);
+        } else {
+            s.pending.push(d.data);
+        }
+    } else if (d.kind === "bye") {
+        s.reject(new Error(d.data && d.data.reason === "declined" ? "对方拒绝接收" : "对方已取消"));
+        p2pClose(d.session);
+    }
+}
+
+// p2pReceive 接收文件: 支持File System Access API时边收边写入，否则收完后下载
+async function p2pReceive(d, confirmFn, onProgress) {
+    const id = d.session, info = d.data;
+    if (!await confirmFn(d.from, info.name, info.size)) {
+        await p2pSignal(d.from_key, id, "bye", {reason: "declined"});
+        return;
+    }
+    let writable = null;
+    if (typeof window.showSaveFilePicker === "function") {
+        try {
+            const handle = await window.showSaveFilePicker({suggestedName: info.name});
+            writable = await handle.createWritable();
+        } catch (err) {
+            await p2pSignal(d.from_key, id, "bye", {reason: "declined"});
+            return;
+        }
+    }
+    const s = await p2pPeer(id, d.from_key);
+    const finished = new Promise(function (resolve, reject) {
+        s.reject = reject;
+        s.pc.ondatachannel = function (ev) {
+            const dc = ev.channel;
+            dc.binaryType = "arraybuffer";
+            const parts = [];
+            let received = 0, chain = Promise.resolve();
+            const complete = function () {
+                chain.then(function () {
+                    return writable ? writable.close() : null;
+                }).then(function () {
+                    if (!writable) {
+                        const a = document.createElement("a");
+                        a.href = URL.createObjectURL(new Blob(parts, {type: info.mime || "application/octet-stream"}));
+                        a.download = info.name;
+                        a.click();
+                        setTimeout(function () { URL.revokeObjectURL(a.href); }, 60000);
+                    }
+                    dc.send("done");
+                    resolve();
+                }, reject);
+            };
+            dc.onmessage = function (m) {
+                received += m.data.byteLength;
+                if (writable) {
+                    const data = m.data;
+                    chain = chain.then(function () { return writable.write(data); });
+                } else {
+                    parts.push(m.data);
+                }
+                if (onProgress) {
+                    onProgress(received, info.size, info.name);
+                }
+                if (received >= info.size) {
+                    complete();
+                }
+            };
+            dc.onclose = function () {
+                if (received < info.size) {
+                    reject(new Error("对方已断开，文件未接收完整"));
+                }
+            };
+            if (info.size === 0) {
+                dc.onopen = complete;
+            }
+        };
+    });
+    try {
+        await p2pRemote(s, info.sdp);
+        await s.pc.setLocalDescription(await s.pc.createAnswer());
+        await p2pSignal(d.from_key, id, "answer", {sdp: s.pc.localDescription});
+        await finished;
+    } catch (err) {
+        if (writable) {
+            writable.abort().catch(function () {});
+        }
+        throw err;
+    } finally {
+        // 等待确认消息发出后再关闭
+        setTimeout(function () { p2pClose(id); }, 1000);
+    }
+}
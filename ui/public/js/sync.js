@@ -5,8 +5,7 @@
  * syncSend(data)
  *
  */
-var url = window.location.origin.replace("http://", "");
-url = "ws://" + url + "/sync/web-socket";
+var url = window.location.origin.replace(/^http/, "ws") + b0base + "/sync/web-socket";
 var ws  = new WebSocket(url);
 ws.onmessage = function (result) {
     var data=JSON.parse(result.data);
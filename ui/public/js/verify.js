@@ -0,0 +1,100 @@
+/**
+ * 边下载边校验大文件(需要WebCrypto与File System Access API，仅Chromium在HTTPS或localhost下支持，调用前用verifySupported()检测)
+ *
+ * verifyDownload(path, onProgress)  选择保存位置后下载共享文件path，按服务端的分块哈希逐块校验，
+ *                                   任一分块不一致时放弃写入(不会留下损坏的文件)并抛出错误，err.chunks为损坏的分块序号
+ *
+ * onProgress(done, total, state) 按字节报告进度，state为hashing(服务端正在计算分块哈希)或downloading
+ */
+var VERIFY_POLL = 2000;
+
+function verifySupported() {
+    return !!(window.crypto && window.crypto.subtle && typeof window.showSaveFilePicker === "function");
+}
+
+function verifyHex(buf) {
+    return Array.prototype.map.call(new Uint8Array(buf), function (b) {
+        return ("0" + b.toString(16)).slice(-2);
+    }).join("");
+}
+
+// 分块哈希列表，服务端尚未计算完成时(202)轮询
+async function verifyChunks(path, onProgress) {
+    for (;;) {
+        const resp = await fetch(b0base + "/api/chunks?async=1&f=" + encodeURIComponent("/files/" + path));
+        const result = await resp.json();
+        if (!resp.ok || result.err !== 0) {
+            throw new Error(result.msg || resp.statusText);
+        }
+        if (!result.data.pending) {
+            return result.data;
+        }
+        if (onProgress) {
+            onProgress(0, result.data.size, "hashing");
+        }
+        await new Promise(function (resolve) { setTimeout(resolve, VERIFY_POLL); });
+    }
+}
+
+async function verifyDownload(path, onProgress) {
+    const name = path.substring(path.lastIndexOf("/") + 1);
+    // 文件选择框需在用户点击后立即打开
+    const handle = await window.showSaveFilePicker({suggestedName: name});
+    const list = await verifyChunks(path, onProgress);
+    const resp = await fetch(b0base + "/files/" + path.split("/").map(encodeURIComponent).join("/"), {cache: "no-store"});
+    if (!resp.ok) {
+        throw new Error(name + ": " + resp.statusText);
+    }
+    const writable = await handle.createWritable();
+    const reader = resp.body.getReader();
+    const chunk = list.chunk;
+    let buf = new Uint8Array(Math.min(chunk, list.size)), fill = 0, index = 0, done = 0;
+    const check = async function (data) {
+        const sum = verifyHex(await window.crypto.subtle.digest("SHA-256", data));
+        if (sum !== list.hashes[index]) {
+            const err = new Error(name + " 第" + (index + 1) + "块(" + (index * chunk) + "字节起)校验失败，文件已损坏");
+            err.chunks = [index];
+            throw err;
+        }
+        index++;
+    };
+    try {
+        for (;;) {
+            const part = await reader.read();
+            if (part.done) {
+                break;
+            }
+            let data = part.value;
+            await writable.write(data);
+            while (data.length > 0) {
+                const n = Math.min(buf.length - fill, data.length);
+                buf.set(data.subarray(0, n), fill);
+                fill += n;
+                data = data.subarray(n);
+                if (fill === buf.length) {
+                    await check(buf);
+                    fill = 0;
+                }
+            }
+            done += part.value.length;
+            if (onProgress) {
+                onProgress(done, list.size, "downloading");
+            }
+        }
+        if (fill > 0) {
+            await check(buf.subarray(0, fill));
+        }
+        if (done !== list.size || index !== list.hashes.length) {
+            const err = new Error(name + " 下载不完整(" + done + "/" + list.size + "字节)");
+            err.chunks = [index];
+            throw err;
+        }
+    } catch (e) {
+        reader.cancel().catch(function () {});
+        // 放弃写入，保存位置保持原样
+        await writable.abort();
+        throw e;
+    }
+    await writable.close();
+    return list;
+}
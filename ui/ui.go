@@ -0,0 +1,68 @@
+// Package ui 网页界面的静态资源(public)与页面模板(template)，编译时嵌入程序；
+// 以 -tags minimalui 编译时只嵌入精简界面(不含layui、vue等前端库)，用于体积受限的设备
+package ui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	versionOnce sync.Once
+	version     string
+)
+
+// Walk 按输出路径(public/...、template/...)遍历嵌入的文件
+func Walk(fn func(name string, data []byte) error) error {
+	for _, root := range []string{"public", templateDir} {
+		err := fs.WalkDir(FS, root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			b, err := FS.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if root == templateDir {
+				p = "template" + strings.TrimPrefix(p, templateDir)
+			}
+			return fn(p, b)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Version 界面版本与嵌入内容的摘要，内容变化时改变
+func Version() string {
+	versionOnce.Do(func() {
+		h := sha256.New()
+		_ = Walk(func(name string, data []byte) error {
+			h.Write([]byte(name))
+			h.Write([]byte{0})
+			h.Write(data)
+			return nil
+		})
+		version = Variant + "-" + hex.EncodeToString(h.Sum(nil))[:12]
+	})
+	return version
+}
+
+// Extract 把嵌入的文件写入dir，目录不存在时创建
+func Extract(dir string) error {
+	return Walk(func(name string, data []byte) error {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(file, data, 0644)
+	})
+}
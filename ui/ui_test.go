@@ -0,0 +1,32 @@
+package ui
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ui")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	if err := Extract(dir); err != nil {
+		t.Fatal(err)
+	}
+	// 控制器与接口使用的模板及页面在各界面版本中都存在
+	for _, name := range []string{
+		"template/index.html", "template/file-lists.html", "template/sync.html",
+		"public/favicon.ico", "public/js/base.js",
+		"public/page/get.html", "public/page/inbox.html", "public/page/e2e.html",
+	} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Error(err)
+		}
+	}
+	if v := Version(); len(v) != len(Variant)+13 || v != Version() {
+		t.Fatal(v)
+	}
+}